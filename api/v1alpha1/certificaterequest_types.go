@@ -33,6 +33,17 @@ type CertificateRequestSpec struct {
 	// CertificateSecret is the reference to the secret where certificates are stored.
 	CertificateSecret corev1.ObjectReference `json:"certificateSecret"`
 
+	// CertificateSecretTemplate controls the type and the keys of the secret where certificates
+	// are stored. Defaults to a kubernetes.io/tls secret holding tls.crt and tls.key.
+	// +optional
+	CertificateSecretTemplate *CertificateSecretTemplate `json:"certificateSecretTemplate,omitempty"`
+
+	// CSR is a PEM encoded certificate signing request for DnsNames, whose private key is kept
+	// outside of the cluster, for example in an HSM. When it is set the CSR finalizes the ACME order
+	// and only the certificate chain is stored; no private key is generated or stored.
+	// +optional
+	CSR string `json:"csr,omitempty"`
+
 	// Platform contains specific cloud provider information such as credentials and secrets for the cluster infrastructure.
 	Platform Platform `json:"platform"`
 
@@ -54,6 +65,117 @@ type CertificateRequestSpec struct {
 	// WebConsoleURL is the URL for the cluster's web console UI.
 	// +optional
 	WebConsoleURL string `json:"webConsoleURL,omitempty"`
+
+	// RevokeOnDelete overrides whether the certificate is revoked when the CertificateRequest is
+	// deleted. Defaults to the revokeOnDelete of the operator configuration.
+	// +optional
+	RevokeOnDelete *bool `json:"revokeOnDelete,omitempty"`
+
+	// Exports are the cloud secret stores the certificate is also stored in, for load balancers
+	// terminating TLS outside of the cluster. They are updated with each renewal and removed with
+	// the CertificateRequest, using the credentials of the Platform.
+	// +optional
+	Exports []CertificateExport `json:"exports,omitempty"`
+
+	// IssuerRef is the ACME directory, and the account in it, the certificate is requested from
+	// instead of those of the operator configuration. A change applies from the next issuance.
+	// +optional
+	IssuerRef *ACMEIssuerReference `json:"issuerRef,omitempty"`
+}
+
+// ACMEIssuerReference is an ACME directory and the account the operator holds in it.
+type ACMEIssuerReference struct {
+	// Environment is the ACME directory: Staging, Production, or Custom for the directory at
+	// DirectoryURL.
+	// +kubebuilder:validation:Enum=Staging;Production;Custom
+	Environment ACMEEnvironment `json:"environment"`
+
+	// DirectoryURL is the URL of the ACME directory of the Custom environment, such as a private
+	// Pebble or Boulder instance.
+	// +optional
+	DirectoryURL string `json:"directoryURL,omitempty"`
+
+	// AccountSecretName is the secret of the operator namespace holding the account in the ACME
+	// directory, with the same keys as the lets-encrypt-account secret.
+	AccountSecretName string `json:"accountSecretName"`
+}
+
+// CertificateExport is a cloud secret store the certificate is stored in. Exactly one of its
+// fields is set.
+type CertificateExport struct {
+	// AWSSecretsManager stores the certificate in a secret of AWS Secrets Manager.
+	// +optional
+	AWSSecretsManager *AWSSecretsManagerExport `json:"awsSecretsManager,omitempty"`
+
+	// ACM imports the certificate into AWS Certificate Manager.
+	// +optional
+	ACM *ACMExport `json:"acm,omitempty"`
+
+	// AzureKeyVault imports the certificate into an Azure Key Vault.
+	// +optional
+	AzureKeyVault *AzureKeyVaultExport `json:"azureKeyVault,omitempty"`
+
+	// GCPSecretManager stores the certificate in a secret of GCP Secret Manager.
+	// +optional
+	GCPSecretManager *GCPSecretManagerExport `json:"gcpSecretManager,omitempty"`
+}
+
+// AWSSecretsManagerExport is a secret of AWS Secrets Manager, in the region of the Platform, holding
+// the certificate chain and the private key as JSON under tls.crt and tls.key.
+type AWSSecretsManagerExport struct {
+	// SecretName is the name of the secret.
+	SecretName string `json:"secretName"`
+}
+
+// ACMExport is a certificate imported into AWS Certificate Manager, in the region of the Platform.
+type ACMExport struct {
+}
+
+// AzureKeyVaultExport is a certificate of an Azure Key Vault.
+type AzureKeyVaultExport struct {
+	// VaultName is the name of the key vault.
+	VaultName string `json:"vaultName"`
+
+	// CertificateName is the name of the certificate in the key vault.
+	CertificateName string `json:"certificateName"`
+}
+
+// GCPSecretManagerExport is a secret of GCP Secret Manager holding the certificate chain and the
+// private key as JSON under tls.crt and tls.key.
+type GCPSecretManagerExport struct {
+	// Project is the project of the secret. Defaults to the project of the Platform credentials.
+	// +optional
+	Project string `json:"project,omitempty"`
+
+	// SecretID is the ID of the secret.
+	SecretID string `json:"secretID"`
+}
+
+// CertificateSecretTemplate controls the type and the keys of the secret where certificates are stored.
+type CertificateSecretTemplate struct {
+
+	// Type is the type of the secret, kubernetes.io/tls or Opaque. A kubernetes.io/tls secret always
+	// holds tls.crt and tls.key, custom keys are written alongside them. The type of an existing
+	// secret is kept. Defaults to kubernetes.io/tls.
+	// +kubebuilder:validation:Enum=kubernetes.io/tls;Opaque
+	// +optional
+	Type corev1.SecretType `json:"type,omitempty"`
+
+	// CertificateKey is the key holding the certificate chain. Defaults to tls.crt.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +optional
+	CertificateKey string `json:"certificateKey,omitempty"`
+
+	// PrivateKeyKey is the key holding the private key. Defaults to tls.key.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +optional
+	PrivateKeyKey string `json:"privateKeyKey,omitempty"`
+
+	// CAKey is the key holding the certificate of the issuer, such as ca.crt. The issuer
+	// certificate isn't stored on its own when it is empty.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +optional
+	CAKey string `json:"caKey,omitempty"`
 }
 
 // CertificateRequestCondition defines conditions required for certificate requests.
@@ -86,6 +208,54 @@ type CertificateRequestCondition struct {
 // within the CertificateRequestCondition struct
 type CertificateRequestConditionType string
 
+const (
+	// CertificateRequestReissueBeforeDaysInvalid is set when the ReissueBeforeDays of the
+	// CertificateRequest is not shorter than the lifetime of its certificate, a shorter period
+	// is used instead.
+	CertificateRequestReissueBeforeDaysInvalid CertificateRequestConditionType = "ReissueBeforeDaysInvalid"
+
+	// CertificateRequestDelegationMismatch is set when the StrictDelegationCheck of the operator is
+	// enabled and the public DNS doesn't delegate the ACMEDNSDomain to the nameservers of its cloud
+	// provider zone. No certificate is issued until the delegation is fixed.
+	CertificateRequestDelegationMismatch CertificateRequestConditionType = "DelegationMismatch"
+
+	// CertificateRequestCertificateSecretTooLarge is set when the issued certificate doesn't fit in
+	// a secret, even with its CA bundle stored in a separate secret. The certificate is not stored.
+	CertificateRequestCertificateSecretTooLarge CertificateRequestConditionType = "CertificateSecretTooLarge"
+
+	// CertificateRequestNotAuthorized is set when the CertificateRequestPolicy of the operator
+	// doesn't allow the CertificateRequest. It is left alone until the policy allows it.
+	CertificateRequestNotAuthorized CertificateRequestConditionType = "NotAuthorized"
+
+	// CertificateRequestStalled is set when the CertificateRequest has existed for longer than the
+	// stalled threshold of the operator without a certificate ever being issued for it.
+	CertificateRequestStalled CertificateRequestConditionType = "Stalled"
+
+	// CertificateRequestRenewalDeferred is set when the certificate is due for renewal but the
+	// maintenance window of the cluster is closed and the renewal isn't urgent.
+	CertificateRequestRenewalDeferred CertificateRequestConditionType = "RenewalDeferred"
+
+	// CertificateRequestZoneDelegationPending is set while the public DNS doesn't delegate the
+	// ACMEDNSDomain to the nameservers of a newly created cloud provider zone yet. Issuance waits for
+	// the delegation to propagate, for a bounded time, before placing challenges.
+	CertificateRequestZoneDelegationPending CertificateRequestConditionType = "ZoneDelegationPending"
+
+	// CertificateRequestCertificateCorrupt is set when the certificate secret holds a certificate
+	// that can't be parsed, after a manual edit or a truncation for instance. The certificate is
+	// reissued, and the condition is removed once the new one is stored.
+	CertificateRequestCertificateCorrupt CertificateRequestConditionType = "CertificateCorrupt"
+
+	// CertificateRequestPolicyViolation is set when the certificate issued by the ACME server doesn't
+	// pass the CertificateQualityPolicy of the operator. The certificate is not stored, and the
+	// condition is removed once a certificate passing the policy is issued.
+	CertificateRequestPolicyViolation CertificateRequestConditionType = "PolicyViolation"
+
+	// CertificateRequestDuplicate is set when an older CertificateRequest of the same namespace
+	// requests the same DNS names. The certificate is issued for the older one only, and the
+	// condition is removed once the CertificateRequest is the oldest requesting its DNS names.
+	CertificateRequestDuplicate CertificateRequestConditionType = "Duplicate"
+)
+
 // CertificateRequestStatus defines the observed state of CertificateRequest
 // +k8s:openapi-gen=true
 type CertificateRequestStatus struct {
@@ -113,11 +283,223 @@ type CertificateRequestStatus struct {
 	// +optional
 	SerialNumber string `json:"serialNumber,omitempty"`
 
+	// CertificateIssuer is the ACME directory, and the account in it, that issued the certificate
+	// stored in the secret. The certificate is revoked through them.
+	// +optional
+	CertificateIssuer *ACMEIssuerReference `json:"certificateIssuer,omitempty"`
+
+	// DNSProvider is the DNS service used to answer the ACME challenges of the last issuance.
+	// +optional
+	DNSProvider string `json:"dnsProvider,omitempty"`
+
+	// DNSZone is the hosted zone ID (Route53) or zone name (Cloud DNS) the ACME challenge
+	// records of the last issuance were written to.
+	// +optional
+	DNSZone string `json:"dnsZone,omitempty"`
+
+	// ChallengeFQDNs is the list of ACME challenge records written during the last issuance.
+	// +optional
+	ChallengeFQDNs []string `json:"challengeFQDNs,omitempty"`
+
+	// IssuanceStage is the last completed stage of the current or last issuance.
+	// +optional
+	IssuanceStage IssuanceStage `json:"issuanceStage,omitempty"`
+
+	// IssuanceHistory lists the last certificates issued for the CertificateRequest, oldest first.
+	// +optional
+	IssuanceHistory []CertificateIssuance `json:"issuanceHistory,omitempty"`
+
+	// OrderURL is the URL of the ACME order of an issuance that hasn't been stored yet.
+	// +optional
+	OrderURL string `json:"orderURL,omitempty"`
+
+	// OrderExpires is when the ACME order of OrderURL expires at the ACME server, after which it
+	// can no longer be finalized.
+	// +optional
+	OrderExpires *metav1.Time `json:"orderExpires,omitempty"`
+
+	// OrderDnsNames are the DnsNames of the spec when the ACME order of OrderURL was created. The
+	// order is not resumed once they changed.
+	// +optional
+	OrderDnsNames []string `json:"orderDnsNames,omitempty"`
+
+	// OrderIssuer is the ACME directory, and the account in it, the order of OrderURL was created
+	// with. The order is resumed or abandoned through them.
+	// +optional
+	OrderIssuer *ACMEIssuerReference `json:"orderIssuer,omitempty"`
+
+	// DomainValidations reports the result of the ACME challenge of each domain of the last issuance.
+	// +optional
+	DomainValidations []DomainValidation `json:"domainValidations,omitempty"`
+
+	// PartialIssuance is set when the stored certificate was issued for the validated domains only.
+	// +optional
+	PartialIssuance *PartialIssuance `json:"partialIssuance,omitempty"`
+
+	// LastFailure is the ACME problem document of the last issuance failure reported by Let's Encrypt.
+	// +optional
+	LastFailure *ACMEFailure `json:"lastFailure,omitempty"`
+
+	// ZoneRecords identifies the CAA and ownership TXT records written for the cluster, they are
+	// removed when the ClusterDeployment is deleted.
+	// +optional
+	ZoneRecords *ZoneRecords `json:"zoneRecords,omitempty"`
+
+	// Exports reports the certificate stored in each cloud secret store of the spec.
+	// +optional
+	Exports []CertificateExportStatus `json:"exports,omitempty"`
+
 	// Conditions includes more detailed status for the Certificate Request
 	// +optional
 	Conditions []CertificateRequestCondition `json:"conditions,omitempty"`
 }
 
+// DomainValidation is the result of the ACME challenge of one domain of a certificate.
+type DomainValidation struct {
+	// Domain is the domain the challenge was answered for.
+	Domain string `json:"domain"`
+
+	// Validated is true once Let's Encrypt has validated the domain.
+	Validated bool `json:"validated"`
+
+	// Message holds the error, including any ACME problem details, of a failed validation.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PartialIssuance records the domains left out of a certificate issued for the validated domains
+// only, and when their validation is retried.
+type PartialIssuance struct {
+	// FailedDomains are the DnsNames the certificate was issued without.
+	FailedDomains []string `json:"failedDomains"`
+
+	// Attempts is the number of consecutive issuances that left some domains out.
+	Attempts int `json:"attempts"`
+
+	// RetryAfter is when the failed domains are validated again. Until then, their absence from the
+	// certificate doesn't get it reissued.
+	RetryAfter metav1.Time `json:"retryAfter"`
+}
+
+// ACMEFailure is an ACME problem document (RFC 8555 section 6.7) returned by Let's Encrypt.
+type ACMEFailure struct {
+	// Time is when the problem was reported.
+	Time metav1.Time `json:"time"`
+
+	// Type is the URN of the problem, such as urn:ietf:params:acme:error:caa.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Detail is the human-readable explanation of the problem.
+	// +optional
+	Detail string `json:"detail,omitempty"`
+
+	// Status is the HTTP status code of the response holding the problem.
+	// +optional
+	Status int `json:"status,omitempty"`
+
+	// Subproblems are the problems of the individual identifiers of the request.
+	// +optional
+	Subproblems []ACMESubproblem `json:"subproblems,omitempty"`
+}
+
+// ACMESubproblem is the problem of one identifier of an ACME request.
+type ACMESubproblem struct {
+	// Identifier is the domain the problem is about.
+	Identifier string `json:"identifier"`
+
+	// Type is the URN of the problem.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Detail is the human-readable explanation of the problem.
+	// +optional
+	Detail string `json:"detail,omitempty"`
+}
+
+// ZoneRecords identifies the CAA and ownership TXT records written for a cluster.
+type ZoneRecords struct {
+	// DNSZone is the hosted zone ID (Route53) or zone name (Cloud DNS) the records were written to.
+	DNSZone string `json:"dnsZone"`
+
+	// ClusterID is the cluster ID held by the ownership TXT record.
+	ClusterID string `json:"clusterID"`
+}
+
+// CertificateExportStatus reports the certificate stored in a cloud secret store.
+type CertificateExportStatus struct {
+	// Name identifies the export, such as aws-secrets-manager/<secretName>.
+	Name string `json:"name"`
+
+	// Reference is the identifier of the stored certificate in the store, such as the ARN of an
+	// ACM certificate. The certificate is removed from the store through it.
+	// +optional
+	Reference string `json:"reference,omitempty"`
+
+	// SerialNumber is the serial number of the certificate last stored.
+	// +optional
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	// LastExportTime is when the certificate was last stored.
+	// +optional
+	LastExportTime *metav1.Time `json:"lastExportTime,omitempty"`
+
+	// Error is the error of the last failed attempt to store the certificate.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// IssuanceStage is a stage of the certificate issuance pipeline. The stages are completed in the
+// order OrderCreated, ChallengesPlaced, Validated, Finalized and Stored.
+type IssuanceStage string
+
+const (
+	// IssuanceStageOrderCreated means an ACME order was created for the certificate
+	IssuanceStageOrderCreated IssuanceStage = "OrderCreated"
+	// IssuanceStageChallengesPlaced means DNS records for the order's challenges are being placed
+	IssuanceStageChallengesPlaced IssuanceStage = "ChallengesPlaced"
+	// IssuanceStageValidated means all authorizations of the order are valid
+	IssuanceStageValidated IssuanceStage = "Validated"
+	// IssuanceStageFinalized means the order was finalized with a certificate signing request
+	IssuanceStageFinalized IssuanceStage = "Finalized"
+	// IssuanceStageStored means the issued certificate was stored in the certificate secret
+	IssuanceStageStored IssuanceStage = "Stored"
+)
+
+// IssuanceTrigger is why a certificate was issued.
+type IssuanceTrigger string
+
+const (
+	// IssuanceTriggerCreate is the first issuance of a CertificateRequest
+	IssuanceTriggerCreate IssuanceTrigger = "create"
+	// IssuanceTriggerScheduledRenewal is the reissuance of a certificate nearing expiry
+	IssuanceTriggerScheduledRenewal IssuanceTrigger = "scheduled-renewal"
+	// IssuanceTriggerForcedRenewal is a reissuance requested with the renew-requested-at annotation
+	IssuanceTriggerForcedRenewal IssuanceTrigger = "forced-renewal"
+	// IssuanceTriggerReKey is the reissuance of a certificate for a supplied CSR of another key
+	IssuanceTriggerReKey IssuanceTrigger = "re-key"
+	// IssuanceTriggerSANChange is the reissuance of a certificate missing DNS names of the spec
+	IssuanceTriggerSANChange IssuanceTrigger = "san-change"
+	// IssuanceTriggerCorruptionRecovery is the reissuance of a certificate that couldn't be parsed
+	IssuanceTriggerCorruptionRecovery IssuanceTrigger = "corruption-recovery"
+)
+
+// CertificateIssuance records a certificate issued for a CertificateRequest.
+type CertificateIssuance struct {
+	// Time is when the certificate was recorded as issued.
+	Time metav1.Time `json:"time"`
+
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber string `json:"serialNumber"`
+
+	// NotAfter is the expiration time of the certificate.
+	// +optional
+	NotAfter string `json:"notAfter,omitempty"`
+
+	// Trigger is why the certificate was issued.
+	Trigger IssuanceTrigger `json:"trigger"`
+}
+
 // +kubebuilder:object:root=true
 
 // CertificateRequest is the Schema for the certificaterequests API
@@ -159,6 +541,10 @@ type AWSPlatformSecrets struct {
 	Credentials corev1.LocalObjectReference `json:"credentials"`
 	// Region specifies the AWS region where the cluster will be created.
 	Region string `json:"region"`
+	// Partition is the AWS partition of the account, it is derived from Region when empty.
+	// +kubebuilder:validation:Enum=aws;aws-us-gov;aws-cn
+	// +optional
+	Partition string `json:"partition,omitempty"`
 }
 
 // GCPPlatformSecrets contains secrets for clusters on the GCP platform.
@@ -175,6 +561,12 @@ type AzurePlatformSecrets struct {
 
 	// ResourceGroupName refers to the resource group that contains the dns zone.
 	ResourceGroupName string `json:"resourceGroupName"`
+
+	// Cloud is the Azure cloud of the subscription, it defaults to the azureCloud platform default
+	// of the operator configuration.
+	// +kubebuilder:validation:Enum=AzurePublicCloud;AzureUSGovernmentCloud;AzureChinaCloud
+	// +optional
+	Cloud string `json:"cloud,omitempty"`
 }
 
 // MockPlatformSecrets indicates a mock client should be generated, which
@@ -194,6 +586,26 @@ const (
 	// CertmanOperatorFinalizerLabel is a K8's finalizer. An arbitrary string that when
 	// present ensures a hard delete of a resource is not possible.
 	CertmanOperatorFinalizerLabel = "certificaterequests.certman.managed.openshift.io"
+
+	// CanaryCertificateRequestLabel marks the canary CertificateRequest the operator maintains for
+	// itself. It has no ClusterDeployment.
+	CanaryCertificateRequestLabel = "certman.managed.openshift.io/canary"
+
+	// RequesterLabel names the operator that created a CertificateRequest through the requester
+	// package. It identifies the CertificateRequests of each requester for ownership and quotas.
+	RequesterLabel = "certman.managed.openshift.io/requester"
+
+	// CertificateBundleLabel names the certificate bundle of the ClusterDeployment a
+	// CertificateRequest was generated for. CertificateBundlePartLabel numbers the
+	// CertificateRequest, from 1, among the CertificateBundlePartsLabel ones the domains of the
+	// bundle are split across.
+	CertificateBundleLabel      = "certman.managed.openshift.io/certificate-bundle"
+	CertificateBundlePartLabel  = "certman.managed.openshift.io/certificate-bundle-part"
+	CertificateBundlePartsLabel = "certman.managed.openshift.io/certificate-bundle-parts"
+
+	// StatusTimeLayout is the layout of the NotBefore and NotAfter of the status, which hold the
+	// String() of the certificate times.
+	StatusTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
 )
 
 func init() {
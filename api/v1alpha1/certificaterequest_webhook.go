@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-certman-managed-openshift-io-v1alpha1-certificaterequest,mutating=false,failurePolicy=fail,sideEffects=None,groups=certman.managed.openshift.io,resources=certificaterequests,verbs=create;update,versions=v1alpha1,name=vcertificaterequest.certman.managed.openshift.io,admissionReviewVersions=v1
+
+// SetupWebhookWithManager registers the validating webhook of CertificateRequests with mgr.
+func (r *CertificateRequest) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&certificateRequestValidator{}).
+		Complete()
+}
+
+// certificateRequestValidator rejects the CertificateRequests whose Platform doesn't set exactly
+// one provider.
+type certificateRequestValidator struct{}
+
+var _ admission.CustomValidator = &certificateRequestValidator{}
+
+// ValidateCreate validates the Platform of a new CertificateRequest.
+func (v *certificateRequestValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	cr, ok := obj.(*CertificateRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a CertificateRequest, got %T", obj)
+	}
+	return nil, validatePlatform(cr)
+}
+
+// ValidateUpdate validates the Platform of an updated CertificateRequest when it changes, so that
+// CertificateRequests created before the validation can still have their finalizer removed.
+func (v *certificateRequestValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldCR, ok := oldObj.(*CertificateRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a CertificateRequest, got %T", oldObj)
+	}
+	cr, ok := newObj.(*CertificateRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a CertificateRequest, got %T", newObj)
+	}
+	if apiequality.Semantic.DeepEqual(oldCR.Spec.Platform, cr.Spec.Platform) {
+		return nil, nil
+	}
+	return nil, validatePlatform(cr)
+}
+
+// ValidateDelete allows every deletion.
+func (v *certificateRequestValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validatePlatform returns an Invalid error for cr when its Platform isn't valid.
+func validatePlatform(cr *CertificateRequest) error {
+	if err := cr.Spec.Platform.Validate(); err != nil {
+		return apierrors.NewInvalid(GroupVersion.WithKind("CertificateRequest").GroupKind(), cr.Name, field.ErrorList{
+			field.Invalid(field.NewPath("spec", "platform"), cr.Spec.Platform.providers(), err.Error()),
+		})
+	}
+	return nil
+}
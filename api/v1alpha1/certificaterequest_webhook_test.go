@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestPlatformValidate(t *testing.T) {
+	tests := []struct {
+		Name             string
+		Platform         Platform
+		ExpectedProvider PlatformProvider
+		ExpectedErr      error
+	}{
+		{
+			Name:             "aws",
+			Platform:         Platform{AWS: &AWSPlatformSecrets{}},
+			ExpectedProvider: PlatformProviderAWS,
+		},
+		{
+			Name:             "mock",
+			Platform:         Platform{Mock: &MockPlatformSecrets{}},
+			ExpectedProvider: PlatformProviderMock,
+		},
+		{
+			Name:             "none",
+			Platform:         Platform{},
+			ExpectedProvider: PlatformProviderNone,
+			ExpectedErr:      ErrNoPlatform,
+		},
+		{
+			Name:             "several",
+			Platform:         Platform{GCP: &GCPPlatformSecrets{}, Azure: &AzurePlatformSecrets{}},
+			ExpectedProvider: PlatformProviderNone,
+			ExpectedErr:      ErrMultiplePlatforms,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			if provider := test.Platform.Provider(); provider != test.ExpectedProvider {
+				t.Errorf("expected provider %q, got %q", test.ExpectedProvider, provider)
+			}
+			if err := test.Platform.Validate(); !errors.Is(err, test.ExpectedErr) {
+				t.Errorf("expected error %v, got %v", test.ExpectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCertificateRequestValidator(t *testing.T) {
+	validator := &certificateRequestValidator{}
+	valid := &CertificateRequest{Spec: CertificateRequestSpec{Platform: Platform{AWS: &AWSPlatformSecrets{}}}}
+	invalid := &CertificateRequest{Spec: CertificateRequestSpec{Platform: Platform{AWS: &AWSPlatformSecrets{}, Mock: &MockPlatformSecrets{}}}}
+
+	if _, err := validator.ValidateCreate(context.TODO(), valid); err != nil {
+		t.Errorf("expected a valid platform to be accepted, got %v", err)
+	}
+	if _, err := validator.ValidateCreate(context.TODO(), invalid); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an invalid error, got %v", err)
+	}
+	if _, err := validator.ValidateUpdate(context.TODO(), valid, invalid); !apierrors.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a changed platform, got %v", err)
+	}
+
+	// a CertificateRequest created before the validation can still be updated, to remove its finalizer
+	updated := invalid.DeepCopy()
+	updated.Finalizers = nil
+	if _, err := validator.ValidateUpdate(context.TODO(), invalid, updated); err != nil {
+		t.Errorf("expected an unchanged platform to be accepted, got %v", err)
+	}
+}
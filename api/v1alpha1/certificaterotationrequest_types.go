@@ -0,0 +1,127 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CertificateRotationRequestSpec selects the certificates to reissue and paces their reissuance
+type CertificateRotationRequestSpec struct {
+
+	// Selector matches the labels of the CertificateRequests, in every namespace, whose certificates
+	// are reissued. An empty selector matches every CertificateRequest.
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// IssuedBefore is the time from which certificates count as rotated, such as the switch to a new
+	// intermediate CA. Certificates issued before it are reissued. Defaults to the creation time of
+	// the CertificateRotationRequest.
+	// +optional
+	IssuedBefore *metav1.Time `json:"issuedBefore,omitempty"`
+
+	// Deadline is when every certificate should be reissued. The reissuances are spread evenly until
+	// then. Without a deadline they are started as fast as MaxConcurrent allows.
+	// +optional
+	Deadline *metav1.Time `json:"deadline,omitempty"`
+
+	// MaxConcurrent is the number of certificates being reissued at a time. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+
+	// Paused stops new reissuances from being started, the ones in progress complete.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// CertificateRotationRequestStatus reports the progress of a CertificateRotationRequest
+type CertificateRotationRequestStatus struct {
+
+	// ObservedGeneration is the generation of the spec last acted on by the operator.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// StartTime is when the operator started the rotation.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when every matched certificate was found rotated.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Matched is the number of CertificateRequests with a certificate that the Selector matches.
+	// +optional
+	Matched int `json:"matched,omitempty"`
+
+	// Rotated is the number of matched certificates issued after IssuedBefore.
+	// +optional
+	Rotated int `json:"rotated,omitempty"`
+
+	// InProgress is the number of matched certificates whose reissuance is requested.
+	// +optional
+	InProgress int `json:"inProgress,omitempty"`
+
+	// Pending is the number of matched certificates whose reissuance isn't requested yet.
+	// +optional
+	Pending int `json:"pending,omitempty"`
+
+	// InProgressCertificateRequests lists the CertificateRequests being reissued, as namespace/name.
+	// +optional
+	InProgressCertificateRequests []string `json:"inProgressCertificateRequests,omitempty"`
+
+	// Conditions reports whether the rotation is complete.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CertificateRotationRequest reissues the certificates of the CertificateRequests matching a
+// selector, a few at a time, such as during the transition to a new intermediate CA.
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Matched",type="integer",JSONPath=".status.matched"
+// +kubebuilder:printcolumn:name="Rotated",type="integer",JSONPath=".status.rotated"
+// +kubebuilder:printcolumn:name="InProgress",type="integer",JSONPath=".status.inProgress"
+// +kubebuilder:printcolumn:name="Deadline",type="string",JSONPath=".spec.deadline"
+// +kubebuilder:printcolumn:name="Complete",type="string",JSONPath=".status.conditions[?(@.type==\"Complete\")].status"
+type CertificateRotationRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CertificateRotationRequestSpec   `json:"spec,omitempty"`
+	Status CertificateRotationRequestStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CertificateRotationRequestList contains a list of CertificateRotationRequest
+type CertificateRotationRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CertificateRotationRequest `json:"items"`
+}
+
+const (
+	// CertificateRotationComplete is the condition reporting whether every matched certificate of a
+	// CertificateRotationRequest is rotated.
+	CertificateRotationComplete = "Complete"
+)
+
+func init() {
+	SchemeBuilder.Register(&CertificateRotationRequest{}, &CertificateRotationRequestList{})
+}
@@ -0,0 +1,378 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IngressDomainPolicy controls the SANs requested for the ingress domains of a ClusterDeployment
+type IngressDomainPolicy string
+
+const (
+	// IngressDomainPolicyWildcard requests a wildcard SAN for each ingress domain
+	IngressDomainPolicyWildcard IngressDomainPolicy = "Wildcard"
+	// IngressDomainPolicyExact requests each ingress domain as declared, without a wildcard
+	IngressDomainPolicyExact IngressDomainPolicy = "Exact"
+	// IngressDomainPolicyBoth requests both the wildcard and the exact SAN for each ingress domain
+	IngressDomainPolicyBoth IngressDomainPolicy = "Both"
+)
+
+// ManagedLabelPolicy controls what is done with ClusterDeployments of managed products that lack
+// the api.openshift.com/managed label
+type ManagedLabelPolicy string
+
+const (
+	// ManagedLabelPolicyWarn reports the ClusterDeployments through a metric and leaves them alone
+	ManagedLabelPolicyWarn ManagedLabelPolicy = "Warn"
+	// ManagedLabelPolicyDefault sets the managed label on the ClusterDeployments
+	ManagedLabelPolicyDefault ManagedLabelPolicy = "Default"
+)
+
+// ACMEEnvironment selects the ACME directory certificates are requested from
+type ACMEEnvironment string
+
+const (
+	// ACMEEnvironmentStaging uses the Let's Encrypt staging directory, whose certificates aren't trusted
+	ACMEEnvironmentStaging ACMEEnvironment = "Staging"
+	// ACMEEnvironmentProduction uses the Let's Encrypt production directory
+	ACMEEnvironmentProduction ACMEEnvironment = "Production"
+	// ACMEEnvironmentCustom uses the directory set in ACMEDirectoryURL
+	ACMEEnvironmentCustom ACMEEnvironment = "Custom"
+)
+
+// CanaryConfig configures the canary CertificateRequest the operator issues and renews for itself
+type CanaryConfig struct {
+	// Domain is the name of a public Route53 hosted zone the operator controls. The canary
+	// certificate is requested for it.
+	Domain string `json:"domain"`
+
+	// Credentials refers to a secret in the operator namespace holding the AWS credentials of the
+	// account of the hosted zone.
+	Credentials corev1.LocalObjectReference `json:"credentials"`
+
+	// Region is the AWS region of the account of the hosted zone.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Interval is how often the canary certificate is renewed. Defaults to 24 hours.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// CertificateRequestPolicy lists the CertificateRequests the operator acts on besides the ones of
+// ClusterDeployments. A CertificateRequest is allowed when its namespace is listed or its labels
+// match the selector.
+type CertificateRequestPolicy struct {
+	// AllowedNamespaces are the namespaces whose CertificateRequests are allowed.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// Selector matches the labels of the allowed CertificateRequests.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// RequesterQuotas limits the number of CertificateRequests of each requester, named by the
+	// certman.managed.openshift.io/requester label, or "namespace/<namespace>" for the unlabelled
+	// CertificateRequests of a namespace. The most recent CertificateRequests beyond the limit are
+	// not authorized. Requesters that aren't listed aren't limited.
+	// +optional
+	RequesterQuotas map[string]int `json:"requesterQuotas,omitempty"`
+}
+
+// CertificateQualityPolicy lists the checks the certificates issued by the ACME server must pass
+// before they are stored, such as to catch a misconfigured private ACME CA issuing weak
+// certificates. Every certificate of the chain is checked, except for the validity period, which
+// is only checked on the certificate itself.
+type CertificateQualityPolicy struct {
+	// AllowSHA1Signatures allows certificates signed with SHA-1.
+	// +optional
+	AllowSHA1Signatures bool `json:"allowSHA1Signatures,omitempty"`
+
+	// MinRSAKeySize is the minimum size in bits of RSA keys. Defaults to 2048.
+	// +optional
+	MinRSAKeySize int `json:"minRSAKeySize,omitempty"`
+
+	// MinECDSAKeySize is the minimum size in bits of the curve of ECDSA keys. Defaults to 256, the
+	// size of P-256.
+	// +optional
+	MinECDSAKeySize int `json:"minECDSAKeySize,omitempty"`
+
+	// MinEmbeddedSCTs is the minimum number of signed certificate timestamps from distinct
+	// certificate transparency logs embedded in the certificate, as some security baselines require
+	// of publicly trusted certificates. They aren't checked by default.
+	// +optional
+	MinEmbeddedSCTs int `json:"minEmbeddedSCTs,omitempty"`
+
+	// MaxValidity is the longest validity period of the certificate. It isn't limited by default.
+	// +optional
+	MaxValidity *metav1.Duration `json:"maxValidity,omitempty"`
+
+	// DisallowedIssuers are the common names and organizations of the issuers whose certificates
+	// are rejected.
+	// +optional
+	DisallowedIssuers []string `json:"disallowedIssuers,omitempty"`
+}
+
+// DNSChangeMetadata describes the DNS records the operator writes in the audit trails of the DNS
+// providers, so the changes made in DNS accounts shared with other writers can be traced back to
+// their cluster.
+type DNSChangeMetadata struct {
+	// Comment is the comment of the Route53 change batches, truncated to the 256 characters Route53
+	// accepts. {cluster}, {certificaterequest} and {version} are replaced with the namespace/name
+	// of the ClusterDeployment and of the CertificateRequest the records are written for, and the
+	// version of the operator. Defaults to "certman-operator {version}: {certificaterequest} of
+	// cluster {cluster}".
+	// +optional
+	Comment string `json:"comment,omitempty"`
+
+	// Tags are added to the metadata of the record sets written to Azure DNS, besides the
+	// certman_cluster, certman_certificaterequest and certman_operator_version ones. Route53 and
+	// Cloud DNS don't tag record sets.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// PlatformDefaults holds the regions and clouds the DNS provider clients are built for when the
+// platform of a CertificateRequest doesn't set them, so the operator can run in other partitions
+// and clouds than the commercial ones.
+type PlatformDefaults struct {
+	// AWSRegion is the region of the AWS platforms that don't set one. Defaults to the default
+	// region of their partition, or of AWSPartition when they don't set a partition either.
+	// +optional
+	AWSRegion string `json:"awsRegion,omitempty"`
+
+	// AWSPartition is the partition of the AWS platforms that set neither a region nor a partition.
+	// +kubebuilder:validation:Enum=aws;aws-us-gov;aws-cn
+	// +optional
+	AWSPartition string `json:"awsPartition,omitempty"`
+
+	// FedrampAWSRegion is the region of the account of the FedRAMP hosted zone when the
+	// FEDRAMP_AWS_REGION environment variable isn't set. Defaults to us-east-1.
+	// +optional
+	FedrampAWSRegion string `json:"fedrampAWSRegion,omitempty"`
+
+	// AzureCloud is the cloud of the Azure platforms that don't set one. Defaults to
+	// AzurePublicCloud.
+	// +kubebuilder:validation:Enum=AzurePublicCloud;AzureUSGovernmentCloud;AzureChinaCloud
+	// +optional
+	AzureCloud string `json:"azureCloud,omitempty"`
+}
+
+// CertmanOperatorConfigSpec defines the configuration of the operator
+type CertmanOperatorConfigSpec struct {
+
+	// DefaultNotificationEmailAddress is the email address to which Let's Encrypt certificate
+	// expiry notifications should be sent.
+	// +kubebuilder:validation:Pattern=`^[^@\s]+@[^@\s]+$`
+	DefaultNotificationEmailAddress string `json:"defaultNotificationEmailAddress"`
+
+	// ReissueBeforeDays is the number of days before expiration to reissue certificates of
+	// CertificateRequests that don't set their own.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=89
+	// +optional
+	ReissueBeforeDays int `json:"reissueBeforeDays,omitempty"`
+
+	// KeepAcmeChallengeRecords leaves the ACME challenge records in the DNS zones after issuance,
+	// for debugging.
+	// +optional
+	KeepAcmeChallengeRecords *bool `json:"keepAcmeChallengeRecords,omitempty"`
+
+	// IngressDomainPolicy is the default policy for the SANs requested for ingress domains.
+	// Defaults to Wildcard.
+	// +kubebuilder:validation:Enum=Wildcard;Exact;Both
+	// +optional
+	IngressDomainPolicy IngressDomainPolicy `json:"ingressDomainPolicy,omitempty"`
+
+	// AllowedDNSZones lists DNS zones, besides the base domain of each cluster, that certificate
+	// domains may be requested in.
+	// +optional
+	AllowedDNSZones []string `json:"allowedDNSZones,omitempty"`
+
+	// ApprovedBaseDomains restricts issuance to these domains and their subdomains, whatever the
+	// base domain of a cluster or the DNS names of a CertificateRequest. Certificates may be issued
+	// for any domain when it is empty.
+	// +optional
+	ApprovedBaseDomains []string `json:"approvedBaseDomains,omitempty"`
+
+	// ChallengeValidationTimeout is how long to wait for the ACME challenge of each domain to be
+	// validated. Defaults to 5 minutes.
+	// +optional
+	ChallengeValidationTimeout *metav1.Duration `json:"challengeValidationTimeout,omitempty"`
+
+	// ReconcileDeadline is how long a reconcile issuing a certificate runs before the issuance is
+	// checkpointed between two ACME challenges and requeued, so a slow issuance doesn't hold a
+	// worker. Defaults to 10 minutes.
+	// +optional
+	ReconcileDeadline *metav1.Duration `json:"reconcileDeadline,omitempty"`
+
+	// AllowPartialIssuance issues certificates for the domains that were validated when the
+	// challenges of other domains of a CertificateRequest fail.
+	// +optional
+	AllowPartialIssuance *bool `json:"allowPartialIssuance,omitempty"`
+
+	// ManageZoneRecords writes a CAA record allowing Let's Encrypt to issue certificates and a
+	// certman-managed=<cluster-id> ownership TXT record for the base domain of each cluster. The
+	// records are removed when the ClusterDeployment is deleted.
+	// +optional
+	ManageZoneRecords *bool `json:"manageZoneRecords,omitempty"`
+
+	// StrictDelegationCheck refuses to issue certificates for a base domain that the public DNS
+	// doesn't delegate to the nameservers of its cloud provider zone, as its challenges would fail.
+	// +optional
+	StrictDelegationCheck *bool `json:"strictDelegationCheck,omitempty"`
+
+	// DelegatedZoneCredentials names Secrets in the operator namespace holding AWS credentials of
+	// accounts that subdomains of clusters are delegated to. Their hosted zones are searched for
+	// the zone authoritative for each ACME challenge.
+	// +optional
+	DelegatedZoneCredentials []string `json:"delegatedZoneCredentials,omitempty"`
+
+	// ManagedLabelPolicy is what to do with ClusterDeployments of managed products that lack the
+	// api.openshift.com/managed label and so get no certificates. Defaults to Warn.
+	// +kubebuilder:validation:Enum=Warn;Default
+	// +optional
+	ManagedLabelPolicy ManagedLabelPolicy `json:"managedLabelPolicy,omitempty"`
+
+	// ACMEEnvironment is the ACME directory certificates are requested from: Staging, Production, or
+	// Custom for the directory at ACMEDirectoryURL. The account in the lets-encrypt-account secret
+	// must belong to it. When it isn't set, the directory is guessed from the account URL, which is
+	// deprecated.
+	// +kubebuilder:validation:Enum=Staging;Production;Custom
+	// +optional
+	ACMEEnvironment ACMEEnvironment `json:"acmeEnvironment,omitempty"`
+
+	// ACMEDirectoryURL is the URL of the ACME directory used by the Custom ACMEEnvironment.
+	// +optional
+	ACMEDirectoryURL string `json:"acmeDirectoryURL,omitempty"`
+
+	// Canary has the operator maintain a CertificateRequest of its own that is renewed on a short
+	// cycle, probing Let's Encrypt, DNS and the operator independently of any cluster.
+	// +optional
+	Canary *CanaryConfig `json:"canary,omitempty"`
+
+	// CertificateRequestPolicy restricts the CertificateRequests that aren't controlled by a
+	// ClusterDeployment the operator acts on. The others get a NotAuthorized condition. When it
+	// isn't set, every CertificateRequest is acted on.
+	// +optional
+	CertificateRequestPolicy *CertificateRequestPolicy `json:"certificateRequestPolicy,omitempty"`
+
+	// RevokeOnDelete revokes the certificate of a CertificateRequest when it is deleted. Defaults to
+	// true; staging environments may disable it. CertificateRequests can override it.
+	// +optional
+	RevokeOnDelete *bool `json:"revokeOnDelete,omitempty"`
+
+	// SyncCertificatesToClusters has a Hive SyncSet copy the certificate secret of each cluster to
+	// its openshift-config namespace. The SyncSet is updated with each renewal and deleted with the
+	// CertificateRequest.
+	// +optional
+	SyncCertificatesToClusters *bool `json:"syncCertificatesToClusters,omitempty"`
+
+	// MaintenanceWindow restricts the renewals of certificates to a recurring window, as a cron
+	// schedule of its openings in UTC followed by how long it stays open, such as "0 2 * * 6,0 4h".
+	// Renewals outside of it are deferred unless the certificate expires within UrgentRenewalDays
+	// or the renewal was requested. ClusterDeployments can override it.
+	// +optional
+	MaintenanceWindow string `json:"maintenanceWindow,omitempty"`
+
+	// UrgentRenewalDays is the number of days before expiration from which certificates are
+	// renewed outside of the MaintenanceWindow. Defaults to 14.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	UrgentRenewalDays int `json:"urgentRenewalDays,omitempty"`
+
+	// ACMEAccountWeeklyIssuanceCeiling is the number of certificates the operator issues with an
+	// ACME account in 7 days, kept under the limits of the ACME server. Issuances beyond it wait for
+	// older ones to leave the 7 days. Issuance isn't limited when it isn't set.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ACMEAccountWeeklyIssuanceCeiling int `json:"acmeAccountWeeklyIssuanceCeiling,omitempty"`
+
+	// DNSChangeMetadata describes the DNS changes of the operator to the DNS providers. When it
+	// isn't set, the changes are described with the defaults of its fields.
+	// +optional
+	DNSChangeMetadata *DNSChangeMetadata `json:"dnsChangeMetadata,omitempty"`
+
+	// PlatformDefaults sets the regions and clouds of the CertificateRequests whose platform
+	// doesn't. When it isn't set, the defaults of its fields apply.
+	// +optional
+	PlatformDefaults *PlatformDefaults `json:"platformDefaults,omitempty"`
+
+	// CertificateQualityPolicy rejects the issued certificates that don't pass its checks, with a
+	// PolicyViolation condition on their CertificateRequest. When it isn't set, certificates aren't
+	// checked.
+	// +optional
+	CertificateQualityPolicy *CertificateQualityPolicy `json:"certificateQualityPolicy,omitempty"`
+}
+
+// CertmanOperatorConfigStatus reports the configuration applied by the operator
+type CertmanOperatorConfigStatus struct {
+
+	// ObservedGeneration is the generation of the spec last applied by the operator.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions reports whether the configuration is applied.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// OperatorVersion is the version of the operator that applied the configuration.
+	// +optional
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+
+	// ConfigHash is the hash of the applied configuration, as reported by the
+	// certman_operator_build_info metric.
+	// +optional
+	ConfigHash string `json:"configHash,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CertmanOperatorConfig is the Schema for the certmanoperatorconfigs API. The operator only reads
+// the object named certman-operator and falls back to the certman-operator ConfigMap for the fields
+// it doesn't set, or entirely when it doesn't exist.
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Email",type="string",JSONPath=".spec.defaultNotificationEmailAddress"
+// +kubebuilder:printcolumn:name="Applied",type="string",JSONPath=".status.conditions[?(@.type==\"Applied\")].status"
+type CertmanOperatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CertmanOperatorConfigSpec   `json:"spec,omitempty"`
+	Status CertmanOperatorConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CertmanOperatorConfigList contains a list of CertmanOperatorConfig
+type CertmanOperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CertmanOperatorConfig `json:"items"`
+}
+
+const (
+	// CertmanOperatorConfigApplied is the condition reporting whether a CertmanOperatorConfig is in use.
+	CertmanOperatorConfigApplied = "Applied"
+)
+
+func init() {
+	SchemeBuilder.Register(&CertmanOperatorConfig{}, &CertmanOperatorConfigList{})
+}
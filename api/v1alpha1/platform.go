@@ -0,0 +1,89 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PlatformProvider is the DNS provider a Platform answers the challenges with.
+type PlatformProvider string
+
+const (
+	// PlatformProviderNone is the provider of a Platform with no block, or more than one, set
+	PlatformProviderNone  PlatformProvider = ""
+	PlatformProviderAWS   PlatformProvider = "aws"
+	PlatformProviderGCP   PlatformProvider = "gcp"
+	PlatformProviderAzure PlatformProvider = "azure"
+	// PlatformProviderMock doesn't call any DNS API, it explicitly sets no provider for fake clusters
+	// and tests
+	PlatformProviderMock PlatformProvider = "mock"
+)
+
+var (
+	// ErrNoPlatform is returned by Validate for a Platform with no provider block set.
+	ErrNoPlatform = errors.New("no platform is set, one of aws, gcp, azure or mock is required")
+	// ErrMultiplePlatforms is returned by Validate for a Platform with several provider blocks set.
+	ErrMultiplePlatforms = errors.New("more than one platform is set")
+)
+
+// providers returns the providers whose block is set in p.
+func (p Platform) providers() []PlatformProvider {
+	providers := []PlatformProvider{}
+	if p.AWS != nil {
+		providers = append(providers, PlatformProviderAWS)
+	}
+	if p.GCP != nil {
+		providers = append(providers, PlatformProviderGCP)
+	}
+	if p.Azure != nil {
+		providers = append(providers, PlatformProviderAzure)
+	}
+	if p.Mock != nil {
+		providers = append(providers, PlatformProviderMock)
+	}
+	return providers
+}
+
+// Provider returns the provider whose block is set in p, PlatformProviderNone unless exactly one
+// is set.
+func (p Platform) Provider() PlatformProvider {
+	providers := p.providers()
+	if len(providers) != 1 {
+		return PlatformProviderNone
+	}
+	return providers[0]
+}
+
+// Validate returns an error unless exactly one provider block is set in p.
+func (p Platform) Validate() error {
+	providers := p.providers()
+	switch len(providers) {
+	case 0:
+		return ErrNoPlatform
+	case 1:
+		return nil
+	}
+
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, string(provider))
+	}
+	return fmt.Errorf("%w: %s", ErrMultiplePlatforms, strings.Join(names, ", "))
+}
@@ -5,9 +5,91 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACMEFailure) DeepCopyInto(out *ACMEFailure) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Subproblems != nil {
+		in, out := &in.Subproblems, &out.Subproblems
+		*out = make([]ACMESubproblem, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACMEFailure.
+func (in *ACMEFailure) DeepCopy() *ACMEFailure {
+	if in == nil {
+		return nil
+	}
+	out := new(ACMEFailure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACMEIssuerReference) DeepCopyInto(out *ACMEIssuerReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACMEIssuerReference.
+func (in *ACMEIssuerReference) DeepCopy() *ACMEIssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ACMEIssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACMESubproblem) DeepCopyInto(out *ACMESubproblem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACMESubproblem.
+func (in *ACMESubproblem) DeepCopy() *ACMESubproblem {
+	if in == nil {
+		return nil
+	}
+	out := new(ACMESubproblem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ACMExport) DeepCopyInto(out *ACMExport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ACMExport.
+func (in *ACMExport) DeepCopy() *ACMExport {
+	if in == nil {
+		return nil
+	}
+	out := new(ACMExport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSSecretsManagerExport) DeepCopyInto(out *AWSSecretsManagerExport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSSecretsManagerExport.
+func (in *AWSSecretsManagerExport) DeepCopy() *AWSSecretsManagerExport {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSSecretsManagerExport)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSPlatformSecrets) DeepCopyInto(out *AWSPlatformSecrets) {
 	*out = *in
@@ -40,6 +122,169 @@ func (in *AzurePlatformSecrets) DeepCopy() *AzurePlatformSecrets {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureKeyVaultExport) DeepCopyInto(out *AzureKeyVaultExport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureKeyVaultExport.
+func (in *AzureKeyVaultExport) DeepCopy() *AzureKeyVaultExport {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureKeyVaultExport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryConfig) DeepCopyInto(out *CanaryConfig) {
+	*out = *in
+	out.Credentials = in.Credentials
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryConfig.
+func (in *CanaryConfig) DeepCopy() *CanaryConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateRequestPolicy) DeepCopyInto(out *CertificateRequestPolicy) {
+	*out = *in
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequesterQuotas != nil {
+		in, out := &in.RequesterQuotas, &out.RequesterQuotas
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateRequestPolicy.
+func (in *CertificateRequestPolicy) DeepCopy() *CertificateRequestPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateRequestPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateExport) DeepCopyInto(out *CertificateExport) {
+	*out = *in
+	if in.AWSSecretsManager != nil {
+		in, out := &in.AWSSecretsManager, &out.AWSSecretsManager
+		*out = new(AWSSecretsManagerExport)
+		**out = **in
+	}
+	if in.ACM != nil {
+		in, out := &in.ACM, &out.ACM
+		*out = new(ACMExport)
+		**out = **in
+	}
+	if in.AzureKeyVault != nil {
+		in, out := &in.AzureKeyVault, &out.AzureKeyVault
+		*out = new(AzureKeyVaultExport)
+		**out = **in
+	}
+	if in.GCPSecretManager != nil {
+		in, out := &in.GCPSecretManager, &out.GCPSecretManager
+		*out = new(GCPSecretManagerExport)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateExport.
+func (in *CertificateExport) DeepCopy() *CertificateExport {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateExport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateExportStatus) DeepCopyInto(out *CertificateExportStatus) {
+	*out = *in
+	if in.LastExportTime != nil {
+		in, out := &in.LastExportTime, &out.LastExportTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateExportStatus.
+func (in *CertificateExportStatus) DeepCopy() *CertificateExportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateExportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateIssuance) DeepCopyInto(out *CertificateIssuance) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateIssuance.
+func (in *CertificateIssuance) DeepCopy() *CertificateIssuance {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateIssuance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateQualityPolicy) DeepCopyInto(out *CertificateQualityPolicy) {
+	*out = *in
+	if in.MaxValidity != nil {
+		in, out := &in.MaxValidity, &out.MaxValidity
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DisallowedIssuers != nil {
+		in, out := &in.DisallowedIssuers, &out.DisallowedIssuers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateQualityPolicy.
+func (in *CertificateQualityPolicy) DeepCopy() *CertificateQualityPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateQualityPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertificateRequest) DeepCopyInto(out *CertificateRequest) {
 	*out = *in
@@ -136,12 +381,34 @@ func (in *CertificateRequestList) DeepCopyObject() runtime.Object {
 func (in *CertificateRequestSpec) DeepCopyInto(out *CertificateRequestSpec) {
 	*out = *in
 	out.CertificateSecret = in.CertificateSecret
+	if in.CertificateSecretTemplate != nil {
+		in, out := &in.CertificateSecretTemplate, &out.CertificateSecretTemplate
+		*out = new(CertificateSecretTemplate)
+		**out = **in
+	}
 	in.Platform.DeepCopyInto(&out.Platform)
 	if in.DnsNames != nil {
 		in, out := &in.DnsNames, &out.DnsNames
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RevokeOnDelete != nil {
+		in, out := &in.RevokeOnDelete, &out.RevokeOnDelete
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Exports != nil {
+		in, out := &in.Exports, &out.Exports
+		*out = make([]CertificateExport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IssuerRef != nil {
+		in, out := &in.IssuerRef, &out.IssuerRef
+		*out = new(ACMEIssuerReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateRequestSpec.
@@ -157,6 +424,64 @@ func (in *CertificateRequestSpec) DeepCopy() *CertificateRequestSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertificateRequestStatus) DeepCopyInto(out *CertificateRequestStatus) {
 	*out = *in
+	if in.CertificateIssuer != nil {
+		in, out := &in.CertificateIssuer, &out.CertificateIssuer
+		*out = new(ACMEIssuerReference)
+		**out = **in
+	}
+	if in.ChallengeFQDNs != nil {
+		in, out := &in.ChallengeFQDNs, &out.ChallengeFQDNs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IssuanceHistory != nil {
+		in, out := &in.IssuanceHistory, &out.IssuanceHistory
+		*out = make([]CertificateIssuance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OrderExpires != nil {
+		in, out := &in.OrderExpires, &out.OrderExpires
+		*out = (*in).DeepCopy()
+	}
+	if in.OrderDnsNames != nil {
+		in, out := &in.OrderDnsNames, &out.OrderDnsNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OrderIssuer != nil {
+		in, out := &in.OrderIssuer, &out.OrderIssuer
+		*out = new(ACMEIssuerReference)
+		**out = **in
+	}
+	if in.DomainValidations != nil {
+		in, out := &in.DomainValidations, &out.DomainValidations
+		*out = make([]DomainValidation, len(*in))
+		copy(*out, *in)
+	}
+	if in.PartialIssuance != nil {
+		in, out := &in.PartialIssuance, &out.PartialIssuance
+		*out = new(PartialIssuance)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastFailure != nil {
+		in, out := &in.LastFailure, &out.LastFailure
+		*out = new(ACMEFailure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ZoneRecords != nil {
+		in, out := &in.ZoneRecords, &out.ZoneRecords
+		*out = new(ZoneRecords)
+		**out = **in
+	}
+	if in.Exports != nil {
+		in, out := &in.Exports, &out.Exports
+		*out = make([]CertificateExportStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]CertificateRequestCondition, len(*in))
@@ -176,6 +501,352 @@ func (in *CertificateRequestStatus) DeepCopy() *CertificateRequestStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateRotationRequest) DeepCopyInto(out *CertificateRotationRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateRotationRequest.
+func (in *CertificateRotationRequest) DeepCopy() *CertificateRotationRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateRotationRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CertificateRotationRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateRotationRequestList) DeepCopyInto(out *CertificateRotationRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CertificateRotationRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateRotationRequestList.
+func (in *CertificateRotationRequestList) DeepCopy() *CertificateRotationRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateRotationRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CertificateRotationRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateRotationRequestSpec) DeepCopyInto(out *CertificateRotationRequestSpec) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+	if in.IssuedBefore != nil {
+		in, out := &in.IssuedBefore, &out.IssuedBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.Deadline != nil {
+		in, out := &in.Deadline, &out.Deadline
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateRotationRequestSpec.
+func (in *CertificateRotationRequestSpec) DeepCopy() *CertificateRotationRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateRotationRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateRotationRequestStatus) DeepCopyInto(out *CertificateRotationRequestStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.InProgressCertificateRequests != nil {
+		in, out := &in.InProgressCertificateRequests, &out.InProgressCertificateRequests
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateRotationRequestStatus.
+func (in *CertificateRotationRequestStatus) DeepCopy() *CertificateRotationRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateRotationRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertificateSecretTemplate) DeepCopyInto(out *CertificateSecretTemplate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertificateSecretTemplate.
+func (in *CertificateSecretTemplate) DeepCopy() *CertificateSecretTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(CertificateSecretTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertmanOperatorConfig) DeepCopyInto(out *CertmanOperatorConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertmanOperatorConfig.
+func (in *CertmanOperatorConfig) DeepCopy() *CertmanOperatorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CertmanOperatorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CertmanOperatorConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertmanOperatorConfigList) DeepCopyInto(out *CertmanOperatorConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CertmanOperatorConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertmanOperatorConfigList.
+func (in *CertmanOperatorConfigList) DeepCopy() *CertmanOperatorConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(CertmanOperatorConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CertmanOperatorConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertmanOperatorConfigSpec) DeepCopyInto(out *CertmanOperatorConfigSpec) {
+	*out = *in
+	if in.KeepAcmeChallengeRecords != nil {
+		in, out := &in.KeepAcmeChallengeRecords, &out.KeepAcmeChallengeRecords
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowedDNSZones != nil {
+		in, out := &in.AllowedDNSZones, &out.AllowedDNSZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ApprovedBaseDomains != nil {
+		in, out := &in.ApprovedBaseDomains, &out.ApprovedBaseDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChallengeValidationTimeout != nil {
+		in, out := &in.ChallengeValidationTimeout, &out.ChallengeValidationTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ReconcileDeadline != nil {
+		in, out := &in.ReconcileDeadline, &out.ReconcileDeadline
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.AllowPartialIssuance != nil {
+		in, out := &in.AllowPartialIssuance, &out.AllowPartialIssuance
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ManageZoneRecords != nil {
+		in, out := &in.ManageZoneRecords, &out.ManageZoneRecords
+		*out = new(bool)
+		**out = **in
+	}
+	if in.StrictDelegationCheck != nil {
+		in, out := &in.StrictDelegationCheck, &out.StrictDelegationCheck
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DelegatedZoneCredentials != nil {
+		in, out := &in.DelegatedZoneCredentials, &out.DelegatedZoneCredentials
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CertificateRequestPolicy != nil {
+		in, out := &in.CertificateRequestPolicy, &out.CertificateRequestPolicy
+		*out = new(CertificateRequestPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RevokeOnDelete != nil {
+		in, out := &in.RevokeOnDelete, &out.RevokeOnDelete
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SyncCertificatesToClusters != nil {
+		in, out := &in.SyncCertificatesToClusters, &out.SyncCertificatesToClusters
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DNSChangeMetadata != nil {
+		in, out := &in.DNSChangeMetadata, &out.DNSChangeMetadata
+		*out = new(DNSChangeMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PlatformDefaults != nil {
+		in, out := &in.PlatformDefaults, &out.PlatformDefaults
+		*out = new(PlatformDefaults)
+		**out = **in
+	}
+	if in.CertificateQualityPolicy != nil {
+		in, out := &in.CertificateQualityPolicy, &out.CertificateQualityPolicy
+		*out = new(CertificateQualityPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertmanOperatorConfigSpec.
+func (in *CertmanOperatorConfigSpec) DeepCopy() *CertmanOperatorConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CertmanOperatorConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertmanOperatorConfigStatus) DeepCopyInto(out *CertmanOperatorConfigStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertmanOperatorConfigStatus.
+func (in *CertmanOperatorConfigStatus) DeepCopy() *CertmanOperatorConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CertmanOperatorConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSChangeMetadata) DeepCopyInto(out *DNSChangeMetadata) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSChangeMetadata.
+func (in *DNSChangeMetadata) DeepCopy() *DNSChangeMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSChangeMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DomainValidation) DeepCopyInto(out *DomainValidation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DomainValidation.
+func (in *DomainValidation) DeepCopy() *DomainValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(DomainValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCPPlatformSecrets) DeepCopyInto(out *GCPPlatformSecrets) {
 	*out = *in
@@ -192,6 +863,21 @@ func (in *GCPPlatformSecrets) DeepCopy() *GCPPlatformSecrets {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPSecretManagerExport) DeepCopyInto(out *GCPSecretManagerExport) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPSecretManagerExport.
+func (in *GCPSecretManagerExport) DeepCopy() *GCPSecretManagerExport {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPSecretManagerExport)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MockPlatformSecrets) DeepCopyInto(out *MockPlatformSecrets) {
 	*out = *in
@@ -207,6 +893,27 @@ func (in *MockPlatformSecrets) DeepCopy() *MockPlatformSecrets {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PartialIssuance) DeepCopyInto(out *PartialIssuance) {
+	*out = *in
+	if in.FailedDomains != nil {
+		in, out := &in.FailedDomains, &out.FailedDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.RetryAfter.DeepCopyInto(&out.RetryAfter)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PartialIssuance.
+func (in *PartialIssuance) DeepCopy() *PartialIssuance {
+	if in == nil {
+		return nil
+	}
+	out := new(PartialIssuance)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Platform) DeepCopyInto(out *Platform) {
 	*out = *in
@@ -241,3 +948,33 @@ func (in *Platform) DeepCopy() *Platform {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlatformDefaults) DeepCopyInto(out *PlatformDefaults) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlatformDefaults.
+func (in *PlatformDefaults) DeepCopy() *PlatformDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(PlatformDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZoneRecords) DeepCopyInto(out *ZoneRecords) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZoneRecords.
+func (in *ZoneRecords) DeepCopy() *ZoneRecords {
+	if in == nil {
+		return nil
+	}
+	out := new(ZoneRecords)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -198,6 +198,69 @@ func schema_openshift_certman_operator_api_v1alpha1_CertificateRequestStatus(ref
 							Format:      "",
 						},
 					},
+					"dnsProvider": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DNSProvider is the DNS service used to answer the ACME challenges of the last issuance.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"dnsZone": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DNSZone is the hosted zone ID (Route53) or zone name (Cloud DNS) the ACME challenge records of the last issuance were written to.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"challengeFQDNs": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ChallengeFQDNs is the list of ACME challenge records written during the last issuance.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+					"issuanceStage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "IssuanceStage is the last completed stage of the current or last issuance.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"orderURL": {
+						SchemaProps: spec.SchemaProps{
+							Description: "OrderURL is the URL of the ACME order of an issuance that hasn't been stored yet.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"domainValidations": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DomainValidations reports the result of the ACME challenge of each domain of the last issuance.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/openshift/certman-operator/api/v1alpha1.DomainValidation"),
+									},
+								},
+							},
+						},
+					},
+					"zoneRecords": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ZoneRecords identifies the CAA and ownership TXT records written for the cluster, they are removed when the ClusterDeployment is deleted.",
+							Ref:         ref("github.com/openshift/certman-operator/api/v1alpha1.ZoneRecords"),
+						},
+					},
 					"conditions": {
 						SchemaProps: spec.SchemaProps{
 							Description: "Conditions includes more detailed status for the Certificate Request",
@@ -216,6 +279,6 @@ func schema_openshift_certman_operator_api_v1alpha1_CertificateRequestStatus(ref
 			},
 		},
 		Dependencies: []string{
-			"github.com/openshift/certman-operator/api/v1alpha1.CertificateRequestCondition"},
+			"github.com/openshift/certman-operator/api/v1alpha1.CertificateRequestCondition", "github.com/openshift/certman-operator/api/v1alpha1.DomainValidation", "github.com/openshift/certman-operator/api/v1alpha1.ZoneRecords"},
 	}
 }
@@ -15,6 +15,9 @@
 package config
 
 const (
-	OperatorName      string = "certman-operator"
-	OperatorNamespace string = "certman-operator"
+	OperatorName string = "certman-operator"
 )
+
+// OperatorNamespace is the namespace holding the configuration, accounts and state of the operator.
+// It is resolved when the operator starts and keeps its default when running outside a cluster.
+var OperatorNamespace string = "certman-operator"
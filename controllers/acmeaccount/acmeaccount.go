@@ -0,0 +1,153 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acmeaccount
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/leclient"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap in the operator namespace describing the ACME
+	// account of the operator
+	ConfigMapName = "certman-operator-acme-account"
+
+	// syncInterval is how often the account is compared with the configuration
+	syncInterval = time.Hour
+)
+
+var log = logf.Log.WithName("acmeaccount")
+
+var _ manager.LeaderElectionRunnable = &Syncer{}
+
+// Syncer keeps the contact of the ACME account of the operator in line with the default
+// notification email, which Let's Encrypt sends the expiry notifications to, and reports the
+// registration of the account through the ACME account metrics and ConfigMap.
+type Syncer struct {
+	Client client.Client
+	// NewLetsEncryptClient builds the client of the ACME account, it defaults to leclient.NewClient
+	NewLetsEncryptClient func(kubeClient client.Client) (*leclient.LetsEncryptClient, error)
+}
+
+// Start syncs the account every syncInterval until ctx is done.
+func (s *Syncer) Start(ctx context.Context) error {
+	ticker := time.NewTicker(syncInterval)
+	defer ticker.Stop()
+
+	for {
+		s.sync(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns true, the account is only updated by the leader.
+func (s *Syncer) NeedLeaderElection() bool {
+	return true
+}
+
+// sync fetches the registration of the account, updates its contact when it drifted from the
+// default notification email, and reports the registration and the issuance inventory.
+func (s *Syncer) sync(ctx context.Context) {
+	newClient := s.NewLetsEncryptClient
+	if newClient == nil {
+		newClient = leclient.NewClient
+	}
+	leClient, err := newClient(s.Client)
+	if err != nil {
+		log.Error(err, "could not build the ACME client")
+		return
+	}
+	environment := string(leClient.GetEnvironment())
+
+	if err := leClient.FetchAccount(); err != nil {
+		log.Error(err, "could not fetch the ACME account")
+		return
+	}
+
+	email, err := utils.GetDefaultNotificationEmailAddress(s.Client)
+	if err != nil {
+		log.Error(err, "could not get the default notification email")
+	}
+
+	drift := email != "" && !reflect.DeepEqual(leClient.GetAccountContact(), []string{fmt.Sprintf("mailto:%s", email)})
+	if drift {
+		log.Info(fmt.Sprintf("updating the contact of the ACME account from %v to %s", leClient.GetAccountContact(), email), "account", leClient.GetAccountURL())
+		if err := leClient.UpdateAccount(email); err != nil {
+			log.Error(err, "could not update the contact of the ACME account")
+		} else {
+			drift = false
+			localmetrics.IncrementACMEAccountContactUpdateCount(environment)
+		}
+	}
+
+	localmetrics.SetACMEAccount(environment, leClient.GetAccountStatus(), drift)
+
+	if err := s.ensureConfigMap(ctx, leClient, environment); err != nil {
+		log.Error(err, "could not update the ACME account ConfigMap")
+	}
+
+	s.reportInventory(ctx, time.Now())
+}
+
+// ensureConfigMap creates or updates the ACME account ConfigMap with the registration of the
+// account of leClient.
+func (s *Syncer) ensureConfigMap(ctx context.Context, leClient *leclient.LetsEncryptClient, environment string) error {
+	data := map[string]string{
+		"accountURL":  leClient.GetAccountURL(),
+		"contact":     strings.Join(leClient.GetAccountContact(), ","),
+		"status":      leClient.GetAccountStatus(),
+		"environment": environment,
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := s.Client.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: ConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		cm.Namespace = config.OperatorNamespace
+		cm.Name = ConfigMapName
+		cm.Data = data
+		return s.Client.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(cm.Data, data) {
+		return nil
+	}
+	cm.Data = data
+	return s.Client.Update(ctx, cm)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acmeaccount
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eggsampler/acme"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	acmemock "github.com/openshift/certman-operator/pkg/acmeclient/mock"
+	"github.com/openshift/certman-operator/pkg/leclient"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const testAccountURL = "https://acme-staging-v02.api.letsencrypt.org/acme/acct/1234"
+
+func TestSync(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	tests := []struct {
+		name            string
+		available       bool
+		contact         []string
+		expectUpdate    bool
+		expectConfigMap bool
+		expectedContact string
+	}{
+		{
+			name:            "drifted contact is updated",
+			available:       true,
+			contact:         []string{"mailto:old@bar.com"},
+			expectUpdate:    true,
+			expectConfigMap: true,
+			expectedContact: "mailto:foo@bar.com",
+		},
+		{
+			name:            "contact in line with the configuration is left alone",
+			available:       true,
+			contact:         []string{"mailto:foo@bar.com"},
+			expectConfigMap: true,
+			expectedContact: "mailto:foo@bar.com",
+		},
+		{
+			name:    "nothing is reported while the ACME server is unavailable",
+			contact: []string{"mailto:old@bar.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName},
+				Spec:       certmanv1alpha1.CertmanOperatorConfigSpec{DefaultNotificationEmailAddress: "foo@bar.com"},
+			}
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(operatorConfig).Build()
+
+			acmeClient := &acmemock.FakeAcmeClient{
+				Available: test.available,
+				Account:   acme.Account{URL: testAccountURL, Contact: test.contact, Status: "valid"},
+			}
+			s := &Syncer{
+				Client: kubeClient,
+				NewLetsEncryptClient: func(client.Client) (*leclient.LetsEncryptClient, error) {
+					return &leclient.LetsEncryptClient{
+						Client:      acmeClient,
+						Account:     acme.Account{URL: testAccountURL},
+						Environment: certmanv1alpha1.ACMEEnvironmentStaging,
+					}, nil
+				},
+			}
+			updatesBefore := testutil.ToFloat64(localmetrics.MetricACMEAccountContactUpdateCount.WithLabelValues("staging"))
+
+			s.sync(context.TODO())
+
+			assert.Equal(t, test.expectUpdate, acmeClient.UpdateAccountCalled)
+			if test.expectUpdate {
+				assert.Equal(t, updatesBefore+1, testutil.ToFloat64(localmetrics.MetricACMEAccountContactUpdateCount.WithLabelValues("staging")))
+			}
+
+			cm := &corev1.ConfigMap{}
+			err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: ConfigMapName}, cm)
+			if !test.expectConfigMap {
+				assert.True(t, errors.IsNotFound(err), "expected no ConfigMap, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{
+				"accountURL":  testAccountURL,
+				"contact":     test.expectedContact,
+				"status":      "valid",
+				"environment": "Staging",
+			}, cm.Data)
+			assert.Equal(t, 1.0, testutil.ToFloat64(localmetrics.MetricACMEAccountStatus.WithLabelValues("staging", "valid")))
+			assert.Equal(t, 0.0, testutil.ToFloat64(localmetrics.MetricACMEAccountContactDrift.WithLabelValues("staging")))
+		})
+	}
+}
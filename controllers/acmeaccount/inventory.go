@@ -0,0 +1,256 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acmeaccount
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	// InventoryConfigMapName is the name of the ConfigMap in the operator namespace listing the
+	// certificates issued with each ACME account
+	InventoryConfigMapName = "certman-operator-issuance-inventory"
+
+	// InventoryWindow is how long issued certificates are kept in the inventory, the period of the
+	// weekly issuance ceiling
+	InventoryWindow = 7 * 24 * time.Hour
+
+	// inventoryKeyPrefix precedes the hash of the account URL in the keys of the inventory
+	// ConfigMap, as URLs aren't valid ConfigMap keys
+	inventoryKeyPrefix = "account-"
+)
+
+// IssuedCertificate is a certificate issued with an ACME account.
+type IssuedCertificate struct {
+	// CertificateRequest is the namespace/name of the CertificateRequest the certificate was
+	// issued for
+	CertificateRequest string    `json:"certificateRequest"`
+	IssuedAt           time.Time `json:"issuedAt"`
+}
+
+// AccountInventory lists the certificates issued with an ACME account within the InventoryWindow.
+type AccountInventory struct {
+	AccountURL string              `json:"accountURL"`
+	Issued     []IssuedCertificate `json:"issued"`
+}
+
+// IssuedSince returns the number of certificates of the account issued at or after since.
+func (a AccountInventory) IssuedSince(since time.Time) int {
+	issued := 0
+	for _, certificate := range a.Issued {
+		if !certificate.IssuedAt.Before(since) {
+			issued++
+		}
+	}
+	return issued
+}
+
+// Inventory keeps the certificates issued with each ACME account in the InventoryConfigMapName
+// ConfigMap, so the count survives restarts of the operator and is shared by its replicas. It
+// backs the weekly issuance ceiling of the accounts and tells which account has room left.
+type Inventory struct {
+	Client client.Client
+}
+
+// Accounts returns the inventory of each account, sorted by account URL. Entries that can't be
+// decoded are skipped.
+func (i *Inventory) Accounts(ctx context.Context) ([]AccountInventory, error) {
+	cm, err := i.get(ctx)
+	if err != nil || cm == nil {
+		return nil, err
+	}
+
+	accounts := []AccountInventory{}
+	for key, value := range cm.Data {
+		account, err := decodeAccountInventory(value)
+		if err != nil {
+			log.Error(err, "skipping the undecodable inventory of an ACME account", "key", key)
+			continue
+		}
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(a, b int) bool {
+		return accounts[a].AccountURL < accounts[b].AccountURL
+	})
+	return accounts, nil
+}
+
+// Account returns the inventory of the account at accountURL, which is empty when no certificate
+// was recorded for it.
+func (i *Inventory) Account(ctx context.Context, accountURL string) (AccountInventory, error) {
+	cm, err := i.get(ctx)
+	if err != nil {
+		return AccountInventory{}, err
+	}
+	if cm == nil || cm.Data[inventoryKey(accountURL)] == "" {
+		return AccountInventory{AccountURL: accountURL}, nil
+	}
+	return decodeAccountInventory(cm.Data[inventoryKey(accountURL)])
+}
+
+// Record adds the certificate issued at issuedAt for the CertificateRequest namespace/name with
+// the account at accountURL, and drops the certificates of the account issued before the
+// InventoryWindow. An inventory that can't be decoded is started over. Updates racing with other
+// issuances are retried.
+func (i *Inventory) Record(ctx context.Context, accountURL, certificateRequest string, issuedAt time.Time) (AccountInventory, error) {
+	account := AccountInventory{}
+	retriable := func(err error) bool {
+		return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+	}
+	err := retry.OnError(retry.DefaultBackoff, retriable, func() error {
+		cm, err := i.get(ctx)
+		if err != nil {
+			return err
+		}
+		create := cm == nil
+		if create {
+			cm = &corev1.ConfigMap{}
+			cm.Namespace = config.OperatorNamespace
+			cm.Name = InventoryConfigMapName
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+
+		account = AccountInventory{AccountURL: accountURL}
+		if value := cm.Data[inventoryKey(accountURL)]; value != "" {
+			if account, err = decodeAccountInventory(value); err != nil {
+				log.Error(err, "starting over the undecodable inventory of the ACME account", "account", accountURL)
+				account = AccountInventory{AccountURL: accountURL}
+			}
+		}
+		account.Issued = append(account.Issued, IssuedCertificate{CertificateRequest: certificateRequest, IssuedAt: issuedAt.UTC()})
+		account.prune(issuedAt.Add(-InventoryWindow))
+
+		value, err := json.Marshal(account)
+		if err != nil {
+			return err
+		}
+		cm.Data[inventoryKey(accountURL)] = string(value)
+		if create {
+			return i.Client.Create(ctx, cm)
+		}
+		return i.Client.Update(ctx, cm)
+	})
+	if err != nil {
+		return AccountInventory{}, err
+	}
+
+	localmetrics.SetACMEAccountIssuedInLastWeek(accountURL, account.IssuedSince(issuedAt.Add(-InventoryWindow)))
+	return account, nil
+}
+
+// get returns the inventory ConfigMap, or nil when no certificate was recorded yet.
+func (i *Inventory) get(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	err := i.Client.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: InventoryConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// prune drops the certificates issued before since.
+func (a *AccountInventory) prune(since time.Time) {
+	issued := a.Issued[:0]
+	for _, certificate := range a.Issued {
+		if !certificate.IssuedAt.Before(since) {
+			issued = append(issued, certificate)
+		}
+	}
+	a.Issued = issued
+}
+
+// inventoryKey returns the key of the inventory ConfigMap holding the account at accountURL.
+func inventoryKey(accountURL string) string {
+	sum := sha256.Sum256([]byte(accountURL))
+	return inventoryKeyPrefix + hex.EncodeToString(sum[:8])
+}
+
+// decodeAccountInventory decodes the inventory of an account from its ConfigMap value.
+func decodeAccountInventory(value string) (AccountInventory, error) {
+	account := AccountInventory{}
+	if err := json.Unmarshal([]byte(value), &account); err != nil {
+		return AccountInventory{}, err
+	}
+	if account.AccountURL == "" {
+		return AccountInventory{}, fmt.Errorf("the inventory does not name its ACME account")
+	}
+	return account, nil
+}
+
+// reportInventory reports the certificates issued with each account in the InventoryWindow before
+// now, as they age out of it without new issuances.
+func (s *Syncer) reportInventory(ctx context.Context, now time.Time) {
+	inventory := &Inventory{Client: s.Client}
+	accounts, err := inventory.Accounts(ctx)
+	if err != nil {
+		log.Error(err, "could not read the issuance inventory of the ACME accounts")
+		return
+	}
+	for _, account := range accounts {
+		localmetrics.SetACMEAccountIssuedInLastWeek(account.AccountURL, account.IssuedSince(now.Add(-InventoryWindow)))
+	}
+}
+
+// WriteInventory writes a table of the certificates issued with each of accounts in the day and
+// in the InventoryWindow before now, along with the weekly issuance ceiling, 0 when there is none.
+func WriteInventory(w io.Writer, accounts []AccountInventory, now time.Time, ceiling int) error {
+	limit := "none"
+	if ceiling > 0 {
+		limit = fmt.Sprint(ceiling)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"ACCOUNT", "LAST DAY", "LAST WEEK", "WEEKLY CEILING", "LAST ISSUED"}, "\t"))
+	for _, account := range accounts {
+		lastIssued := time.Time{}
+		for _, certificate := range account.Issued {
+			if certificate.IssuedAt.After(lastIssued) {
+				lastIssued = certificate.IssuedAt
+			}
+		}
+		last := "-"
+		if !lastIssued.IsZero() {
+			last = lastIssued.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", account.AccountURL,
+			account.IssuedSince(now.Add(-24*time.Hour)), account.IssuedSince(now.Add(-InventoryWindow)), limit, last)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acmeaccount
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestInventoryRecord(t *testing.T) {
+	const otherAccountURL = "https://acme-v02.api.letsencrypt.org/acme/acct/5678"
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	inventory := &Inventory{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+
+	account, err := inventory.Account(context.TODO(), testAccountURL)
+	assert.NoError(t, err)
+	assert.Equal(t, AccountInventory{AccountURL: testAccountURL}, account)
+
+	// the first certificate creates the inventory, the ones older than a week are dropped
+	for _, issuedAt := range []time.Time{now.Add(-8 * 24 * time.Hour), now.Add(-2 * 24 * time.Hour), now} {
+		_, err := inventory.Record(context.TODO(), testAccountURL, "uhc-production-1234/mycluster-primary-cert-bundle", issuedAt)
+		assert.NoError(t, err)
+	}
+	_, err = inventory.Record(context.TODO(), otherAccountURL, "uhc-production-5678/other-primary-cert-bundle", now)
+	assert.NoError(t, err)
+
+	account, err = inventory.Account(context.TODO(), testAccountURL)
+	assert.NoError(t, err)
+	assert.Len(t, account.Issued, 2)
+	assert.Equal(t, 2, account.IssuedSince(now.Add(-InventoryWindow)))
+	assert.Equal(t, 1, account.IssuedSince(now.Add(-24*time.Hour)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(localmetrics.MetricACMEAccountIssuedInLastWeek.WithLabelValues(testAccountURL)))
+
+	accounts, err := inventory.Accounts(context.TODO())
+	assert.NoError(t, err)
+	// sorted by account URL, acme-staging-v02 comes before acme-v02
+	if assert.Len(t, accounts, 2) {
+		assert.Equal(t, testAccountURL, accounts[0].AccountURL)
+		assert.Equal(t, otherAccountURL, accounts[1].AccountURL)
+	}
+
+	output := &bytes.Buffer{}
+	assert.NoError(t, WriteInventory(output, accounts, now, 100))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, []string{testAccountURL, "1", "2", "100", "2024-03-10T12:00:00Z"}, strings.Fields(lines[1]))
+		assert.Equal(t, []string{otherAccountURL, "1", "1", "100", "2024-03-10T12:00:00Z"}, strings.Fields(lines[2]))
+	}
+}
+
+func TestInventoryRecordStartsOverUndecodable(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: InventoryConfigMapName},
+		Data:       map[string]string{inventoryKey(testAccountURL): "not json"},
+	}
+	inventory := &Inventory{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(cm).Build()}
+
+	_, err := inventory.Account(context.TODO(), testAccountURL)
+	assert.Error(t, err)
+
+	account, err := inventory.Record(context.TODO(), testAccountURL, "uhc-production-1234/mycluster-primary-cert-bundle", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, account.IssuedSince(now.Add(-InventoryWindow)))
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buildinfo
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/version"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap in the operator namespace describing the build
+	// and the configuration of the running operator
+	ConfigMapName = "certman-operator-build-info"
+	// VersionAnnotation is set on the ConfigMap to the version of the running operator
+	VersionAnnotation = "certman.managed.openshift.io/operator-version"
+
+	// checkInterval is how often the configuration hash is recomputed
+	checkInterval = time.Minute
+)
+
+var log = logf.Log.WithName("buildinfo")
+
+var _ manager.LeaderElectionRunnable = &Reporter{}
+
+// Reporter reports the build and the configuration of the operator through the build info metric
+// and the build info ConfigMap, so fleet tooling can tell which build and configuration each
+// shard runs.
+type Reporter struct {
+	Client  client.Client
+	Fedramp bool
+}
+
+// Start reports the build info every checkInterval until ctx is done.
+func (r *Reporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		r.report(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns true, the ConfigMap describes the replica that is in charge.
+func (r *Reporter) NeedLeaderElection() bool {
+	return true
+}
+
+// report sets the build info metric and brings the build info ConfigMap in line with it.
+func (r *Reporter) report(ctx context.Context) {
+	configHash, err := utils.ConfigHash(r.Client)
+	if err != nil {
+		log.Error(err, "could not hash the operator configuration")
+		return
+	}
+
+	localmetrics.SetBuildInfo(version.Version, version.Commit(), runtime.Version(), r.Fedramp, configHash)
+
+	if err := r.ensureConfigMap(ctx, configHash); err != nil {
+		log.Error(err, "could not update the build info ConfigMap")
+	}
+}
+
+// ensureConfigMap creates or updates the build info ConfigMap.
+func (r *Reporter) ensureConfigMap(ctx context.Context, configHash string) error {
+	data := map[string]string{
+		"version":    version.Version,
+		"gitSHA":     version.Commit(),
+		"goVersion":  runtime.Version(),
+		"fedramp":    strconv.FormatBool(r.Fedramp),
+		"configHash": configHash,
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: ConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		cm.Namespace = config.OperatorNamespace
+		cm.Name = ConfigMapName
+		cm.Annotations = map[string]string{VersionAnnotation: version.Version}
+		cm.Data = data
+		log.Info("creating the build info ConfigMap", "version", version.Version, "configHash", configHash)
+		return r.Client.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.Annotations[VersionAnnotation] == version.Version && reflect.DeepEqual(cm.Data, data) {
+		return nil
+	}
+
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[VersionAnnotation] = version.Version
+	cm.Data = data
+	log.Info("updating the build info ConfigMap", "version", version.Version, "configHash", configHash)
+	return r.Client.Update(ctx, cm)
+}
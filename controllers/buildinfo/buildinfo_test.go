@@ -0,0 +1,95 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buildinfo
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/version"
+)
+
+func TestReport(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	defer func(v, commit string) {
+		version.Version, version.GitCommit = v, commit
+	}(version.Version, version.GitCommit)
+	version.Version, version.GitCommit = "0.1.500-g0123456", "0123456"
+
+	operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName},
+		Spec:       certmanv1alpha1.CertmanOperatorConfigSpec{DefaultNotificationEmailAddress: "foo@bar.com"},
+	}
+	staleConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   config.OperatorNamespace,
+			Name:        ConfigMapName,
+			Annotations: map[string]string{VersionAnnotation: "0.1.400-gfedcba9"},
+		},
+		Data: map[string]string{"version": "0.1.400-gfedcba9"},
+	}
+
+	for _, existing := range []bool{false, true} {
+		kubeClientBuilder := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(operatorConfig)
+		if existing {
+			kubeClientBuilder = kubeClientBuilder.WithObjects(staleConfigMap.DeepCopy())
+		}
+		kubeClient := kubeClientBuilder.Build()
+
+		r := &Reporter{Client: kubeClient, Fedramp: true}
+		r.report(context.TODO())
+
+		configHash, err := utils.ConfigHash(kubeClient)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, configHash)
+
+		cm := &corev1.ConfigMap{}
+		assert.NoError(t, kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: ConfigMapName}, cm))
+		assert.Equal(t, "0.1.500-g0123456", cm.Annotations[VersionAnnotation])
+		assert.Equal(t, map[string]string{
+			"version":    "0.1.500-g0123456",
+			"gitSHA":     "0123456",
+			"goVersion":  runtime.Version(),
+			"fedramp":    "true",
+			"configHash": configHash,
+		}, cm.Data)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(localmetrics.MetricBuildInfo.With(prometheus.Labels{
+			"version":     "0.1.500-g0123456",
+			"git_sha":     "0123456",
+			"go_version":  runtime.Version(),
+			"fedramp":     "true",
+			"config_hash": configHash,
+		})))
+	}
+}
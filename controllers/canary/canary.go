@@ -0,0 +1,224 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	// CertificateRequestName is the name of the canary CertificateRequest in the operator namespace
+	CertificateRequestName = "certman-canary"
+	secretName             = "certman-canary-tls" //#nosec - G101: Potential hardcoded credentials
+
+	// defaultInterval is how often the canary certificate is renewed when the configuration doesn't say
+	defaultInterval = 24 * time.Hour
+	// checkInterval is how often the canary is checked
+	checkInterval = 5 * time.Minute
+	// renewalGracePeriod is how long an issuance or a renewal may take before the canary fails
+	renewalGracePeriod = time.Hour
+)
+
+var log = logf.Log.WithName("canary")
+
+var _ manager.LeaderElectionRunnable = &Canary{}
+
+// Canary maintains the canary CertificateRequest of the operator and reports its health. The
+// certificate is renewed every interval by requesting a renewal from the CertificateRequest
+// controller, so each cycle goes through Let's Encrypt, DNS and the operator like the certificates
+// of clusters do.
+type Canary struct {
+	Client client.Client
+}
+
+// Start checks the canary every checkInterval until ctx is done.
+func (c *Canary) Start(ctx context.Context) error {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		c.check(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns true, the canary is only maintained by the leader.
+func (c *Canary) NeedLeaderElection() bool {
+	return true
+}
+
+// check brings the canary CertificateRequest in line with the configuration, requests the
+// renewal of a certificate older than the interval, and reports whether the certificate was
+// issued and renewed on schedule.
+func (c *Canary) check(ctx context.Context, now time.Time) {
+	canaryConfig := utils.GetCanaryConfig(c.Client)
+	if canaryConfig == nil {
+		if err := c.deleteCertificateRequest(ctx); err != nil {
+			log.Error(err, "could not delete the canary CertificateRequest")
+		}
+		localmetrics.ClearCanary()
+		return
+	}
+
+	interval := defaultInterval
+	if canaryConfig.Interval != nil && canaryConfig.Interval.Duration > 0 {
+		interval = canaryConfig.Interval.Duration
+	}
+
+	cr, err := c.ensureCertificateRequest(ctx, canaryConfig)
+	if err != nil {
+		log.Error(err, "could not sync the canary CertificateRequest")
+		localmetrics.UpdateCanary(canaryConfig.Domain, false, time.Time{})
+		return
+	}
+
+	certificate, err := certificaterequest.GetCertificate(c.Client, cr)
+	if err != nil || certificate == nil {
+		// The first certificate is still being issued
+		issuing := now.Sub(cr.CreationTimestamp.Time) < renewalGracePeriod
+		if !issuing {
+			log.Info(fmt.Sprintf("no canary certificate was issued within %v", renewalGracePeriod))
+		}
+		localmetrics.UpdateCanary(canaryConfig.Domain, issuing, time.Time{})
+		return
+	}
+
+	age := now.Sub(certificate.NotBefore)
+	if age >= interval {
+		if err := c.requestRenewal(ctx, cr, now); err != nil {
+			log.Error(err, "could not request the renewal of the canary certificate")
+		}
+	}
+
+	renewedOnSchedule := age < interval+renewalGracePeriod
+	if !renewedOnSchedule {
+		log.Info(fmt.Sprintf("canary certificate issued at %v was not renewed within %v", certificate.NotBefore, interval+renewalGracePeriod))
+	}
+	localmetrics.UpdateCanary(canaryConfig.Domain, renewedOnSchedule, certificate.NotBefore)
+}
+
+// ensureCertificateRequest creates or updates the canary CertificateRequest for canaryConfig.
+func (c *Canary) ensureCertificateRequest(ctx context.Context, canaryConfig *certmanv1alpha1.CanaryConfig) (*certmanv1alpha1.CertificateRequest, error) {
+	email, err := utils.GetDefaultNotificationEmailAddress(c.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := certmanv1alpha1.CertificateRequestSpec{
+		ACMEDNSDomain: canaryConfig.Domain,
+		CertificateSecret: corev1.ObjectReference{
+			Kind:      "Secret",
+			Namespace: config.OperatorNamespace,
+			Name:      secretName,
+		},
+		Platform: certmanv1alpha1.Platform{
+			AWS: &certmanv1alpha1.AWSPlatformSecrets{
+				Credentials: canaryConfig.Credentials,
+				Region:      canaryConfig.Region,
+			},
+		},
+		DnsNames: []string{canaryConfig.Domain},
+		Email:    email,
+	}
+
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err = c.Client.Get(ctx, types.NamespacedName{Name: CertificateRequestName, Namespace: config.OperatorNamespace}, cr)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+
+		log.Info("creating the canary CertificateRequest", logging.DomainKey, canaryConfig.Domain)
+		cr = &certmanv1alpha1.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      CertificateRequestName,
+				Namespace: config.OperatorNamespace,
+				Labels: map[string]string{
+					certmanv1alpha1.CanaryCertificateRequestLabel: "true",
+				},
+			},
+			Spec: spec,
+		}
+		return cr, c.Client.Create(ctx, cr)
+	}
+
+	if !reflect.DeepEqual(cr.Spec, spec) {
+		log.Info("updating the canary CertificateRequest", logging.DomainKey, canaryConfig.Domain)
+		cr.Spec = spec
+		return cr, c.Client.Update(ctx, cr)
+	}
+
+	return cr, nil
+}
+
+// requestRenewal asks the CertificateRequest controller to reissue the canary certificate, unless
+// a renewal is already pending.
+func (c *Canary) requestRenewal(ctx context.Context, cr *certmanv1alpha1.CertificateRequest, now time.Time) error {
+	if _, ok := cr.Annotations[certificaterequest.RenewRequestedAtAnnotation]; ok {
+		return nil
+	}
+
+	log.Info("requesting the renewal of the canary certificate")
+	baseToPatch := client.MergeFrom(cr.DeepCopy())
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	cr.Annotations[certificaterequest.RenewRequestedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	return c.Client.Patch(ctx, cr, baseToPatch)
+}
+
+// deleteCertificateRequest deletes the canary CertificateRequest of a disabled canary, its
+// certificate is revoked by the CertificateRequest controller.
+func (c *Canary) deleteCertificateRequest(ctx context.Context) error {
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := c.Client.Get(ctx, types.NamespacedName{Name: CertificateRequestName, Namespace: config.OperatorNamespace}, cr)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !cr.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	log.Info("deleting the canary CertificateRequest of the disabled canary")
+	return c.Client.Delete(ctx, cr)
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canary
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const testDomain = "canary.example.com"
+
+func TestCanaryCheck(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	now := time.Now()
+
+	tests := []struct {
+		name             string
+		canary           *certmanv1alpha1.CanaryConfig
+		existingCR       bool
+		certificateAge   time.Duration
+		expectCR         bool
+		expectRenewal    bool
+		expectedSuccess  float64
+		expectedReported int
+	}{
+		{
+			name:             "fresh canary certificate is healthy",
+			canary:           &certmanv1alpha1.CanaryConfig{Domain: testDomain},
+			existingCR:       true,
+			certificateAge:   time.Hour,
+			expectCR:         true,
+			expectedSuccess:  1,
+			expectedReported: 1,
+		},
+		{
+			name:             "renewal of a canary certificate older than the interval is requested",
+			canary:           &certmanv1alpha1.CanaryConfig{Domain: testDomain, Interval: &metav1.Duration{Duration: 6 * time.Hour}},
+			existingCR:       true,
+			certificateAge:   6*time.Hour + time.Minute,
+			expectCR:         true,
+			expectRenewal:    true,
+			expectedSuccess:  1,
+			expectedReported: 1,
+		},
+		{
+			name:             "canary certificate that wasn't renewed fails",
+			canary:           &certmanv1alpha1.CanaryConfig{Domain: testDomain},
+			existingCR:       true,
+			certificateAge:   48 * time.Hour,
+			expectCR:         true,
+			expectRenewal:    true,
+			expectedSuccess:  0,
+			expectedReported: 1,
+		},
+		{
+			name:             "disabled canary is deleted",
+			existingCR:       true,
+			certificateAge:   time.Hour,
+			expectedReported: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName},
+				Spec: certmanv1alpha1.CertmanOperatorConfigSpec{
+					DefaultNotificationEmailAddress: "foo@bar.com",
+					Canary:                          test.canary,
+				},
+			}
+			objects := []runtime.Object{operatorConfig}
+			if test.existingCR {
+				objects = append(objects, canaryCertificateRequest(), canarySecret(t, now.Add(-test.certificateAge)))
+			}
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objects...).Build()
+			localmetrics.ClearCanary()
+
+			c := &Canary{Client: kubeClient}
+			c.check(context.TODO(), now)
+
+			cr := &certmanv1alpha1.CertificateRequest{}
+			err := kubeClient.Get(context.TODO(), types.NamespacedName{Name: CertificateRequestName, Namespace: config.OperatorNamespace}, cr)
+			if !test.expectCR {
+				assert.True(t, errors.IsNotFound(err), "expected the canary CertificateRequest to be deleted, got %v", err)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, []string{testDomain}, cr.Spec.DnsNames)
+				_, renewalRequested := cr.Annotations[certificaterequest.RenewRequestedAtAnnotation]
+				assert.Equal(t, test.expectRenewal, renewalRequested)
+			}
+
+			assert.Equal(t, test.expectedReported, testutil.CollectAndCount(localmetrics.MetricCanarySuccess))
+			if test.expectedReported > 0 {
+				assert.Equal(t, test.expectedSuccess, testutil.ToFloat64(localmetrics.MetricCanarySuccess.WithLabelValues(testDomain)))
+			}
+		})
+	}
+}
+
+func canaryCertificateRequest() *certmanv1alpha1.CertificateRequest {
+	return &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      CertificateRequestName,
+			Namespace: config.OperatorNamespace,
+			Labels: map[string]string{
+				certmanv1alpha1.CanaryCertificateRequestLabel: "true",
+			},
+		},
+		Spec: certmanv1alpha1.CertificateRequestSpec{
+			CertificateSecret: corev1.ObjectReference{
+				Kind:      "Secret",
+				Namespace: config.OperatorNamespace,
+				Name:      secretName,
+			},
+		},
+	}
+}
+
+// canarySecret returns the canary certificate secret with a self-signed certificate issued at notBefore.
+func canarySecret(t *testing.T, notBefore time.Time) *corev1.Secret {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: testDomain},
+		DNSNames:     []string{testDomain},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: config.OperatorNamespace,
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+}
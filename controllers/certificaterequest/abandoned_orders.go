@@ -0,0 +1,132 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/leclient"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// the reasons an ACME order is abandoned before it is finalized
+const (
+	// abandonedOrderExpired orders expired at the ACME server, which released their authorizations
+	abandonedOrderExpired = "expired"
+	// abandonedOrderReplaced orders couldn't be resumed and a new order was created instead
+	abandonedOrderReplaced = "replaced"
+	// abandonedOrderDeleted orders belong to a deleted CertificateRequest
+	abandonedOrderDeleted = "deleted"
+)
+
+// recordOrder records the ACME order leClient created in the status of cr, with its expiry, so
+// that an interrupted issuance can resume it.
+func recordOrder(cr *certmanv1alpha1.CertificateRequest, leClient leclient.LetsEncryptClientInterface) {
+	cr.Status.OrderURL = leClient.GetOrderURL()
+	cr.Status.OrderDnsNames = append([]string(nil), cr.Spec.DnsNames...)
+	cr.Status.OrderIssuer = leClient.GetIssuer().DeepCopy()
+	cr.Status.OrderExpires = nil
+	if expires := leClient.GetOrderExpires(); !expires.IsZero() {
+		orderExpires := metav1.NewTime(expires)
+		cr.Status.OrderExpires = &orderExpires
+	}
+}
+
+// forgetOrder removes the ACME order from the status of cr.
+func forgetOrder(cr *certmanv1alpha1.CertificateRequest) {
+	cr.Status.OrderURL = ""
+	cr.Status.OrderDnsNames = nil
+	cr.Status.OrderIssuer = nil
+	cr.Status.OrderExpires = nil
+}
+
+// orderIssuer returns the ACME issuer of the order recorded in the status of cr. Orders recorded
+// without their issuer were created with the issuer of the spec.
+func orderIssuer(cr *certmanv1alpha1.CertificateRequest) *certmanv1alpha1.ACMEIssuerReference {
+	if cr.Status.OrderIssuer != nil {
+		return cr.Status.OrderIssuer
+	}
+	return cr.Spec.IssuerRef
+}
+
+// abandonOrderOfIssuer abandons the order recorded in the status of cr through a client of the
+// issuer it was created with, which is no longer the issuer of the spec.
+func (r *CertificateRequestReconciler) abandonOrderOfIssuer(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, reason string) {
+	leClient, err := leclient.NewIssuerClient(r.Client, orderIssuer(cr))
+	if err != nil {
+		reqLogger.Error(err, "could not get a client of the issuer of the order, its authorizations are released when it expires", "URL", cr.Status.OrderURL)
+		forgetOrder(cr)
+		return
+	}
+	r.abandonOrder(reqLogger, cr, leClient, reason)
+}
+
+// orderExpired returns true when the ACME order recorded in the status of cr expired at now.
+func orderExpired(cr *certmanv1alpha1.CertificateRequest, now time.Time) bool {
+	return cr.Status.OrderExpires != nil && !now.Before(cr.Status.OrderExpires.Time)
+}
+
+// abandonOrder forgets the ACME order recorded in the status of cr, which won't be finalized,
+// after deactivating its pending authorizations. Let's Encrypt limits the pending authorizations
+// of an account, and those of an abandoned order would otherwise only be released once it
+// expires. An expired order isn't fetched, the ACME server already released its authorizations.
+// Failures are logged and otherwise ignored since the authorizations expire with the order anyway.
+func (r *CertificateRequestReconciler) abandonOrder(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, leClient leclient.LetsEncryptClientInterface, reason string) {
+	orderURL := cr.Status.OrderURL
+	if orderURL == "" {
+		return
+	}
+	if orderExpired(cr, r.now()) {
+		reason = abandonedOrderExpired
+	}
+	forgetOrder(cr)
+
+	localmetrics.IncrementAbandonedOrderCount(reason)
+	if reason == abandonedOrderExpired {
+		reqLogger.Info("the order of the previous issuance expired", "URL", orderURL)
+		return
+	}
+
+	if leClient.GetOrderURL() != orderURL {
+		if err := leClient.FetchOrder(orderURL); err != nil {
+			reqLogger.Error(err, "could not fetch the abandoned order, its authorizations are released when it expires", "URL", orderURL)
+			return
+		}
+	}
+
+	deactivated := 0
+	for _, authURL := range leClient.OrderAuthorization() {
+		if err := leClient.FetchAuthorization(authURL); err != nil {
+			reqLogger.Error(err, "could not fetch an authorization of the abandoned order", "URL", authURL)
+			continue
+		}
+		if leClient.GetAuthorizationStatus() != leclient.StatusPending {
+			continue
+		}
+		if err := leClient.DeactivateAuthorization(authURL); err != nil {
+			reqLogger.Error(err, "could not deactivate an authorization of the abandoned order", "URL", authURL)
+			continue
+		}
+		deactivated++
+	}
+	reqLogger.Info(fmt.Sprintf("abandoned the order of the previous issuance, %d pending authorizations deactivated", deactivated), "URL", orderURL)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eggsampler/acme"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	acmemock "github.com/openshift/certman-operator/pkg/acmeclient/mock"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/leclient"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestAbandonOrder(t *testing.T) {
+	now := time.Now()
+	orderURL := "https://acme.example.com/order/1"
+
+	tests := []struct {
+		Name                string
+		Expires             time.Time
+		ExpectedReason      string
+		ExpectedFetch       bool
+		ExpectedDeactivated []string
+	}{
+		{
+			Name:                "pending order",
+			Expires:             now.Add(24 * time.Hour),
+			ExpectedReason:      abandonedOrderReplaced,
+			ExpectedFetch:       true,
+			ExpectedDeactivated: []string{"https://acme.example.com/authz/pending"},
+		},
+		{
+			Name:           "expired order",
+			Expires:        now.Add(-time.Hour),
+			ExpectedReason: abandonedOrderExpired,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			fakeAcmeClient := acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{
+				Available: true,
+				NewOrderResult: acme.Order{
+					URL:     orderURL,
+					Status:  leclient.StatusPending,
+					Expires: test.Expires,
+					Authorizations: []string{
+						"https://acme.example.com/authz/pending",
+						"https://acme.example.com/authz/valid",
+					},
+				},
+				FetchAuthorizationResults: map[string]acme.Authorization{
+					"https://acme.example.com/authz/pending": {Status: leclient.StatusPending},
+					"https://acme.example.com/authz/valid":   {Status: leclient.StatusValid},
+				},
+			})
+			leClient := &leclient.LetsEncryptClient{Client: fakeAcmeClient}
+
+			cr := certRequest.DeepCopy()
+			orderExpires := metav1.NewTime(test.Expires)
+			cr.Status.OrderURL = orderURL
+			cr.Status.OrderExpires = &orderExpires
+
+			before := testutil.ToFloat64(localmetrics.MetricAbandonedOrderCount.WithLabelValues(test.ExpectedReason))
+
+			rcr := CertificateRequestReconciler{Clock: clock.NewFake(now)}
+			rcr.abandonOrder(logr.Discard(), cr, leClient, abandonedOrderReplaced)
+
+			if cr.Status.OrderURL != "" || cr.Status.OrderExpires != nil {
+				t.Errorf("expected the order to be forgotten, got %q expiring %v", cr.Status.OrderURL, cr.Status.OrderExpires)
+			}
+			if fakeAcmeClient.FetchOrderCalled != test.ExpectedFetch {
+				t.Errorf("expected the order to be fetched %v, got %v", test.ExpectedFetch, fakeAcmeClient.FetchOrderCalled)
+			}
+			if !reflect.DeepEqual(fakeAcmeClient.DeactivatedAuthorizations, test.ExpectedDeactivated) {
+				t.Errorf("expected authorizations %v to be deactivated, got %v", test.ExpectedDeactivated, fakeAcmeClient.DeactivatedAuthorizations)
+			}
+			if after := testutil.ToFloat64(localmetrics.MetricAbandonedOrderCount.WithLabelValues(test.ExpectedReason)); after != before+1 {
+				t.Errorf("expected the %s abandoned order count to be incremented, got %v then %v", test.ExpectedReason, before, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// fakeClusterAnnotation marks the ClusterDeployments hive simulates instead of installing
+const fakeClusterAnnotation = "hive.openshift.io/fake-cluster"
+
+// errProductionIssuanceForFakeCluster is returned when a production certificate is requested for a
+// fake cluster
+var errProductionIssuanceForFakeCluster = errors.New("refusing to issue a production certificate for a fake cluster")
+
+// checkACMEEnvironment refuses issuance from the production ACME environment when cr, or cd, the
+// ClusterDeployment owning it, carries the fake cluster annotation. Fake clusters are test
+// fixtures, their certificates would only use up the production rate limits.
+func (r *CertificateRequestReconciler) checkACMEEnvironment(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment, environment certmanv1alpha1.ACMEEnvironment) error {
+	if environment != certmanv1alpha1.ACMEEnvironmentProduction || IsCanary(cr) {
+		return nil
+	}
+
+	if cr.Annotations[fakeClusterAnnotation] == "true" {
+		return refuseProductionIssuance(reqLogger, cr, certificateRequestType, cr.Name)
+	}
+
+	if cd != nil && cd.Annotations[fakeClusterAnnotation] == "true" {
+		return refuseProductionIssuance(reqLogger, cr, clusterDeploymentType, cd.Name)
+	}
+
+	return nil
+}
+
+func refuseProductionIssuance(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, kind string, name string) error {
+	err := fmt.Errorf("%w: %s %s/%s has the %s annotation", errProductionIssuanceForFakeCluster, kind, cr.Namespace, name, fakeClusterAnnotation)
+	reqLogger.Error(err, "SAFETY INTERLOCK: production issuance refused, use the Staging ACME environment for fake clusters")
+	return err
+}
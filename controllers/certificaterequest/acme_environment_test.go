@@ -0,0 +1,88 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestCheckACMEEnvironment(t *testing.T) {
+	fakeClusterDeployment := clusterDeploymentComplete.DeepCopy()
+	fakeClusterDeployment.Annotations = map[string]string{fakeClusterAnnotation: "true"}
+
+	fakeCertRequest := certRequest.DeepCopy()
+	fakeCertRequest.Annotations = map[string]string{fakeClusterAnnotation: "true"}
+
+	tests := []struct {
+		name          string
+		environment   certmanv1alpha1.ACMEEnvironment
+		cr            *certmanv1alpha1.CertificateRequest
+		cd            *hivev1.ClusterDeployment
+		expectRefusal bool
+	}{
+		{
+			name:        "production for a real cluster",
+			environment: certmanv1alpha1.ACMEEnvironmentProduction,
+			cr:          certRequest,
+			cd:          clusterDeploymentComplete,
+		},
+		{
+			name:          "production for a fake cluster",
+			environment:   certmanv1alpha1.ACMEEnvironmentProduction,
+			cr:            certRequest,
+			cd:            fakeClusterDeployment,
+			expectRefusal: true,
+		},
+		{
+			name:          "production for an annotated CertificateRequest",
+			environment:   certmanv1alpha1.ACMEEnvironmentProduction,
+			cr:            fakeCertRequest,
+			expectRefusal: true,
+		},
+		{
+			name:        "production without ClusterDeployment",
+			environment: certmanv1alpha1.ACMEEnvironmentProduction,
+			cr:          certRequest,
+		},
+		{
+			name:        "staging for a fake cluster",
+			environment: certmanv1alpha1.ACMEEnvironmentStaging,
+			cr:          certRequest,
+			cd:          fakeClusterDeployment,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rcr := CertificateRequestReconciler{Client: setUpTestClient(t, nil)}
+
+			err := rcr.checkACMEEnvironment(logr.Discard(), test.cr, test.cd, test.environment)
+			if test.expectRefusal {
+				assert.True(t, errors.Is(err, errProductionIssuanceForFakeCluster), "expected a refusal, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
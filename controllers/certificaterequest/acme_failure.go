@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/eggsampler/acme"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// acmeProblemEventReason is the reason of the events reporting an ACME problem document
+const acmeProblemEventReason = "ACMEProblem"
+
+// newACMEFailure returns the ACME problem document held by err, or nil when err isn't an ACME problem.
+func newACMEFailure(err error, now metav1.Time) *certmanv1alpha1.ACMEFailure {
+	problem := acme.Problem{}
+	if !errors.As(err, &problem) {
+		return nil
+	}
+
+	failure := &certmanv1alpha1.ACMEFailure{
+		Time:   now,
+		Type:   problem.Type,
+		Detail: problem.Detail,
+		Status: problem.Status,
+	}
+	for _, subproblem := range problem.SubProblems {
+		failure.Subproblems = append(failure.Subproblems, certmanv1alpha1.ACMESubproblem{
+			Identifier: subproblem.Identifier.Value,
+			Type:       subproblem.Type,
+			Detail:     subproblem.Detail,
+		})
+	}
+
+	return failure
+}
+
+// formatACMEFailure returns the problem and each of its subproblems, one per line.
+func formatACMEFailure(failure *certmanv1alpha1.ACMEFailure) string {
+	lines := []string{fmt.Sprintf("%s: %s", failure.Type, failure.Detail)}
+	for _, subproblem := range failure.Subproblems {
+		lines = append(lines, fmt.Sprintf("%s: %s: %s", subproblem.Identifier, subproblem.Type, subproblem.Detail))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// recordACMEFailure stores the ACME problem document held by err in the LastFailure status of cr and
+// reports it in a Warning event, so failures caused by the customer's DNS, such as a CAA record
+// forbidding Let's Encrypt, can be told from the operator's. Errors that aren't ACME problems are
+// ignored, and failing to store the status is only logged.
+func (r *CertificateRequestReconciler) recordACMEFailure(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, err error) {
+	failure := newACMEFailure(err, metav1.Now())
+	if failure == nil {
+		return
+	}
+
+	message := formatACMEFailure(failure)
+	reqLogger.Info("let's encrypt reported a problem", "Problem", message)
+	if r.Recorder != nil {
+		r.Recorder.Event(cr, corev1.EventTypeWarning, acmeProblemEventReason, message)
+	}
+
+	cr.Status.LastFailure = failure
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		reqLogger.Error(err, "could not record the acme problem")
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/eggsampler/acme"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// caaProblem is the problem the ACME server answers an order with when it rejects some of its
+// identifiers. It is unmarshalled like the client does, as the subproblems have no named type.
+var caaProblem = func() acme.Problem {
+	problem := acme.Problem{}
+	if err := json.Unmarshal([]byte(`{
+		"type": "urn:ietf:params:acme:error:rejectedIdentifier",
+		"detail": "Error creating new order :: Cannot issue for 2 identifiers",
+		"status": 400,
+		"subproblems": [
+			{
+				"type": "urn:ietf:params:acme:error:caa",
+				"detail": "CAA record for api.gibberish.goes.here prevents issuance",
+				"identifier": {"type": "dns", "value": "api.gibberish.goes.here"}
+			},
+			{
+				"type": "urn:ietf:params:acme:error:dns",
+				"detail": "NXDOMAIN looking up TXT for _acme-challenge.apps.gibberish.goes.here",
+				"identifier": {"type": "dns", "value": "apps.gibberish.goes.here"}
+			}
+		]
+	}`), &problem); err != nil {
+		panic(err)
+	}
+	return problem
+}()
+
+func TestRecordACMEFailure(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		expectFailure bool
+	}{
+		{
+			name:          "acme problem",
+			err:           caaProblem,
+			expectFailure: true,
+		},
+		{
+			name:          "wrapped acme problem",
+			err:           fmt.Errorf("failed to create order: %w", caaProblem),
+			expectFailure: true,
+		},
+		{
+			name: "other error",
+			err:  errors.New("failed to get write access to DNS record"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testClient := setUpTestClient(t, []runtime.Object{certRequest})
+			recorder := record.NewFakeRecorder(10)
+			rcr := CertificateRequestReconciler{
+				Client:        testClient,
+				ClientBuilder: setUpFakeAWSClient,
+				Recorder:      recorder,
+			}
+
+			cr := &certmanv1alpha1.CertificateRequest{}
+			err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: certRequest.Namespace, Name: certRequest.Name}, cr)
+			assert.NoError(t, err)
+
+			rcr.recordACMEFailure(logr.Discard(), cr, test.err)
+
+			actual := &certmanv1alpha1.CertificateRequest{}
+			err = testClient.Get(context.TODO(), types.NamespacedName{Namespace: certRequest.Namespace, Name: certRequest.Name}, actual)
+			assert.NoError(t, err)
+
+			if !test.expectFailure {
+				assert.Nil(t, actual.Status.LastFailure)
+				assert.Empty(t, recorder.Events)
+				return
+			}
+
+			if assert.NotNil(t, actual.Status.LastFailure) {
+				assert.Equal(t, caaProblem.Type, actual.Status.LastFailure.Type)
+				assert.Equal(t, caaProblem.Detail, actual.Status.LastFailure.Detail)
+				assert.Equal(t, 400, actual.Status.LastFailure.Status)
+				assert.Equal(t, []certmanv1alpha1.ACMESubproblem{
+					{
+						Identifier: "api.gibberish.goes.here",
+						Type:       "urn:ietf:params:acme:error:caa",
+						Detail:     "CAA record for api.gibberish.goes.here prevents issuance",
+					},
+					{
+						Identifier: "apps.gibberish.goes.here",
+						Type:       "urn:ietf:params:acme:error:dns",
+						Detail:     "NXDOMAIN looking up TXT for _acme-challenge.apps.gibberish.goes.here",
+					},
+				}, actual.Status.LastFailure.Subproblems)
+			}
+
+			if assert.Len(t, recorder.Events, 1) {
+				event := <-recorder.Events
+				assert.Contains(t, event, "Warning "+acmeProblemEventReason)
+				assert.Contains(t, event, "api.gibberish.goes.here: urn:ietf:params:acme:error:caa: CAA record for api.gibberish.goes.here prevents issuance")
+			}
+		})
+	}
+}
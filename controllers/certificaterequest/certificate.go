@@ -19,9 +19,9 @@ package certificaterequest
 import (
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 
-	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
@@ -35,7 +35,7 @@ func GetCertificate(kubeClient client.Client, cr *certmanv1alpha1.CertificateReq
 		return nil, err
 	}
 
-	data := crtSecret.Data[corev1.TLSCertKey]
+	data := crtSecret.Data[secretKeys(cr).certificate]
 	if data == nil {
 		return nil, fmt.Errorf("certificate data was not found in secret %v", cr.Spec.CertificateSecret.Name)
 	}
@@ -48,13 +48,23 @@ func GetCertificate(kubeClient client.Client, cr *certmanv1alpha1.CertificateReq
 	return certificate, nil
 }
 
-// ParseCertificateData returns a decoded x509 certificate to the caller.
+// ErrCorruptCertificate is returned for certificate data that can't be parsed
+var ErrCorruptCertificate = errors.New("corrupt certificate")
+
+// ParseCertificateData returns a decoded x509 certificate to the caller. The first PEM block of
+// data must be a certificate, ErrCorruptCertificate is returned otherwise.
 func ParseCertificateData(data []byte) (*x509.Certificate, error) {
 	keyBlock, _ := pem.Decode(data)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("%w: no PEM encoded data found", ErrCorruptCertificate)
+	}
+	if keyBlock.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%w: found a %s PEM block", ErrCorruptCertificate, keyBlock.Type)
+	}
 
 	certificate, err := x509.ParseCertificate(keyBlock.Bytes)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrCorruptCertificate, err)
 	}
 
 	return certificate, nil
@@ -0,0 +1,108 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const certificateCorruptEventReason = "CertificateCorrupt"
+
+// reportCorruptCertificate sets the CertificateRequestCertificateCorrupt condition of cr for the
+// parse error err of its stored certificate, which is then reissued, and records a warning event
+// the first time the corruption is found.
+func (r *CertificateRequestReconciler) reportCorruptCertificate(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, err error) {
+	message := fmt.Sprintf("the certificate of secret %s is reissued: %v", cr.Spec.CertificateSecret.Name, err)
+	reqLogger.Info(message)
+
+	if !setCertificateCorruptCondition(cr, message) {
+		return
+	}
+	if r.Recorder != nil {
+		r.Recorder.Event(cr, corev1.EventTypeWarning, certificateCorruptEventReason, message)
+	}
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		reqLogger.Error(err, "could not set the certificate corrupt condition")
+	}
+}
+
+// setCertificateCorruptCondition sets the CertificateRequestCertificateCorrupt condition of cr
+// with message. It returns true when the conditions of cr changed.
+func setCertificateCorruptCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestCertificateCorrupt {
+			index = i
+			break
+		}
+	}
+
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "CertificateUnparseable"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestCertificateCorrupt,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// hasCertificateCorruptCondition returns true when the stored certificate of cr was found corrupt
+// and hasn't been replaced yet.
+func hasCertificateCorruptCondition(cr *certmanv1alpha1.CertificateRequest) bool {
+	for _, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestCertificateCorrupt {
+			return true
+		}
+	}
+	return false
+}
+
+// clearCertificateCorrupt removes the CertificateRequestCertificateCorrupt condition of cr once a
+// new certificate is stored, which is counted as a recovery. The condition is stored with the rest
+// of the status of the issued certificate.
+func clearCertificateCorrupt(cr *certmanv1alpha1.CertificateRequest) {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestCertificateCorrupt {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			localmetrics.IncrementCorruptCertificateRecoveryCount()
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// reportCertificateHealth sets the certman_operator_certificate_unhealthy metric of cr from its
+// certificate secret, nil when the secret doesn't exist.
+func (r *CertificateRequestReconciler) reportCertificateHealth(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, secret *corev1.Secret) {
+	problem := certificateHealthProblem(cr, secret, r.now())
+	if problem != "" {
+		reqLogger.Info("no valid certificate is served: " + problem)
+	}
+	localmetrics.SetCertificateUnhealthy(cr.Namespace, cr.Name, problem != "")
+}
+
+// certificateHealthProblem returns why secret doesn't serve a valid certificate for cr at now: it
+// is missing, its certificate can't be parsed or it expired. An empty string is returned when the
+// certificate is valid.
+func certificateHealthProblem(cr *certmanv1alpha1.CertificateRequest, secret *corev1.Secret, now time.Time) string {
+	if secret == nil {
+		return fmt.Sprintf("certificate secret %s does not exist", cr.Spec.CertificateSecret.Name)
+	}
+
+	key := secretKeys(cr).certificate
+	data, ok := secret.Data[key]
+	if !ok {
+		return fmt.Sprintf("certificate secret %s has no %s", secret.Name, key)
+	}
+	certificate, err := ParseCertificateData(data)
+	if err != nil {
+		return fmt.Sprintf("could not parse %s of certificate secret %s: %v", key, secret.Name, err)
+	}
+	if now.After(certificate.NotAfter) {
+		return fmt.Sprintf("the certificate of secret %s expired at %s", secret.Name, certificate.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestReportCertificateHealth(t *testing.T) {
+	certificate, key := testKeyPair(t, certRequest.Spec.DnsNames...)
+
+	secret := func(data map[string][]byte) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: testHiveNamespace, Name: testHiveSecretName},
+			Data:       data,
+		}
+	}
+
+	testCases := []struct {
+		Name            string
+		Secret          *v1.Secret
+		Elapsed         time.Duration
+		ExpectUnhealthy bool
+	}{
+		{
+			Name:   "valid certificate",
+			Secret: secret(map[string][]byte{v1.TLSCertKey: certificate, v1.TLSPrivateKeyKey: key}),
+		},
+		{
+			Name:            "missing secret",
+			ExpectUnhealthy: true,
+		},
+		{
+			Name:            "missing certificate",
+			Secret:          secret(map[string][]byte{v1.TLSPrivateKeyKey: key}),
+			ExpectUnhealthy: true,
+		},
+		{
+			Name:            "corrupt PEM",
+			Secret:          secret(map[string][]byte{v1.TLSCertKey: []byte("not a certificate")}),
+			ExpectUnhealthy: true,
+		},
+		{
+			Name:            "corrupt certificate",
+			Secret:          secret(map[string][]byte{v1.TLSCertKey: key}),
+			ExpectUnhealthy: true,
+		},
+		{
+			Name:            "expired certificate",
+			Secret:          secret(map[string][]byte{v1.TLSCertKey: certificate, v1.TLSPrivateKeyKey: key}),
+			Elapsed:         91 * 24 * time.Hour,
+			ExpectUnhealthy: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			rcr := CertificateRequestReconciler{Clock: clock.NewFake(time.Now().Add(test.Elapsed))}
+
+			rcr.reportCertificateHealth(logr.Discard(), cr, test.Secret)
+
+			expected := 0.0
+			if test.ExpectUnhealthy {
+				expected = 1
+			}
+			if actual := testutil.ToFloat64(localmetrics.MetricCertificateUnhealthy.WithLabelValues(cr.Namespace, cr.Name)); actual != expected {
+				t.Errorf("expected certman_operator_certificate_unhealthy %v, got %v", expected, actual)
+			}
+		})
+	}
+}
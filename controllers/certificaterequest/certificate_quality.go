@@ -0,0 +1,260 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+const (
+	// defaultMinRSAKeySize is the minimum RSA key size of a CertificateQualityPolicy that doesn't set one
+	defaultMinRSAKeySize = 2048
+	// defaultMinECDSAKeySize is the minimum ECDSA curve size of a CertificateQualityPolicy that
+	// doesn't set one, the size of P-256
+	defaultMinECDSAKeySize = 256
+
+	policyViolationEventReason = "PolicyViolation"
+)
+
+// errCertificatePolicyViolation is returned when an issued certificate doesn't pass the
+// CertificateQualityPolicy of the operator
+var errCertificatePolicyViolation = errors.New("certificate violates the certificate quality policy")
+
+// sctListExtensionOID is the extension embedding the signed certificate timestamps of the
+// certificate transparency logs the certificate was submitted to, from RFC 6962
+var sctListExtensionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// sha1SignatureAlgorithms are rejected unless the policy allows SHA-1, the older ones always are
+var (
+	sha1SignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+		x509.SHA1WithRSA:   true,
+		x509.DSAWithSHA1:   true,
+		x509.ECDSAWithSHA1: true,
+	}
+	brokenSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+		x509.MD2WithRSA: true,
+		x509.MD5WithRSA: true,
+	}
+)
+
+// checkCertificateQuality checks the chain of certificates issued for cr against the
+// CertificateQualityPolicy of the operator before they are stored. When the chain violates the
+// policy, the CertificateRequestPolicyViolation condition is set on cr, a warning event is recorded
+// and an error is returned.
+func (r *CertificateRequestReconciler) checkCertificateQuality(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, certs []*x509.Certificate) error {
+	policy, err := utils.GetCertificateQualityPolicy(r.Client)
+	if err != nil {
+		reqLogger.Error(err, "could not read the certificate quality policy")
+		return err
+	}
+
+	violations := certificateQualityViolations(policy, certs)
+	if len(violations) == 0 {
+		clearPolicyViolation(cr)
+		return nil
+	}
+
+	message := fmt.Sprintf("the issued certificate is not stored: %s", strings.Join(violations, "; "))
+	reqLogger.Info(message)
+	if setPolicyViolationCondition(cr, message) {
+		if r.Recorder != nil {
+			r.Recorder.Event(cr, corev1.EventTypeWarning, policyViolationEventReason, message)
+		}
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			reqLogger.Error(err, "could not set the policy violation condition")
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errCertificatePolicyViolation, message)
+}
+
+// certificateQualityViolations returns how certs, the certificate followed by its intermediates,
+// violate policy. A nil policy isn't violated.
+func certificateQualityViolations(policy *certmanv1alpha1.CertificateQualityPolicy, certs []*x509.Certificate) []string {
+	violations := []string{}
+	if policy == nil {
+		return violations
+	}
+
+	minRSAKeySize := policy.MinRSAKeySize
+	if minRSAKeySize <= 0 {
+		minRSAKeySize = defaultMinRSAKeySize
+	}
+	minECDSAKeySize := policy.MinECDSAKeySize
+	if minECDSAKeySize <= 0 {
+		minECDSAKeySize = defaultMinECDSAKeySize
+	}
+
+	for i, cert := range certs {
+		name := "the certificate"
+		if i > 0 {
+			name = fmt.Sprintf("intermediate %q", cert.Subject.CommonName)
+		}
+
+		if brokenSignatureAlgorithms[cert.SignatureAlgorithm] || (!policy.AllowSHA1Signatures && sha1SignatureAlgorithms[cert.SignatureAlgorithm]) {
+			violations = append(violations, fmt.Sprintf("%s is signed with %s", name, cert.SignatureAlgorithm))
+		}
+
+		switch key := cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if size := key.N.BitLen(); size < minRSAKeySize {
+				violations = append(violations, fmt.Sprintf("%s has a %d bit RSA key, the minimum is %d", name, size, minRSAKeySize))
+			}
+		case *ecdsa.PublicKey:
+			if size := key.Curve.Params().BitSize; size < minECDSAKeySize {
+				violations = append(violations, fmt.Sprintf("%s has a %d bit ECDSA key, the minimum is %d", name, size, minECDSAKeySize))
+			}
+		}
+
+		if i == 0 && policy.MaxValidity != nil && policy.MaxValidity.Duration > 0 {
+			if validity := cert.NotAfter.Sub(cert.NotBefore); validity > policy.MaxValidity.Duration {
+				violations = append(violations, fmt.Sprintf("%s is valid for %s, the maximum is %s", name, validity, policy.MaxValidity.Duration))
+			}
+		}
+
+		if i == 0 && policy.MinEmbeddedSCTs > 0 {
+			logs, err := embeddedSCTLogs(cert)
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("%s has a malformed SCT list: %v", name, err))
+			} else if logs < policy.MinEmbeddedSCTs {
+				violations = append(violations, fmt.Sprintf("%s embeds SCTs from %d distinct logs, the minimum is %d", name, logs, policy.MinEmbeddedSCTs))
+			}
+		}
+
+		if issuer := disallowedIssuer(policy.DisallowedIssuers, cert); issuer != "" {
+			violations = append(violations, fmt.Sprintf("%s is issued by disallowed issuer %q", name, issuer))
+		}
+	}
+
+	return violations
+}
+
+// embeddedSCTLogs returns the number of distinct certificate transparency logs whose signed
+// certificate timestamps are embedded in cert. The SCTs themselves aren't verified, the ACME server
+// is trusted to embed the ones it got from the logs.
+func embeddedSCTLogs(cert *x509.Certificate) (int, error) {
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(sctListExtensionOID) {
+			continue
+		}
+
+		var list []byte
+		rest, err := asn1.Unmarshal(extension.Value, &list)
+		if err != nil {
+			return 0, err
+		}
+		if len(rest) > 0 {
+			return 0, errors.New("trailing data after the SCT list")
+		}
+		if len(list) < 2 || int(binary.BigEndian.Uint16(list)) != len(list)-2 {
+			return 0, errors.New("invalid length of the SCT list")
+		}
+
+		logs := map[string]bool{}
+		for list = list[2:]; len(list) > 0; {
+			if len(list) < 2 || int(binary.BigEndian.Uint16(list)) > len(list)-2 {
+				return 0, errors.New("truncated SCT")
+			}
+			length := int(binary.BigEndian.Uint16(list))
+			sct := list[2 : 2+length]
+			list = list[2+length:]
+
+			// an SCT starts with its version and the 32 byte ID of its log
+			if len(sct) < 33 {
+				return 0, errors.New("truncated SCT")
+			}
+			logs[string(sct[1:33])] = true
+		}
+		return len(logs), nil
+	}
+	return 0, nil
+}
+
+// disallowedIssuer returns the common name or organization of the issuer of cert that is listed in
+// disallowed, ignoring case, or an empty string when none is.
+func disallowedIssuer(disallowed []string, cert *x509.Certificate) string {
+	names := append([]string{cert.Issuer.CommonName}, cert.Issuer.Organization...)
+	for _, issuer := range disallowed {
+		for _, name := range names {
+			if name != "" && strings.EqualFold(strings.TrimSpace(issuer), name) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// setPolicyViolationCondition sets the CertificateRequestPolicyViolation condition of cr with
+// message. It returns true when the conditions of cr changed.
+func setPolicyViolationCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestPolicyViolation {
+			index = i
+			break
+		}
+	}
+
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "CertificateQualityPolicy"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestPolicyViolation,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// clearPolicyViolation removes the CertificateRequestPolicyViolation condition of cr, which is
+// stored with the rest of the status once a certificate passing the policy is issued.
+func clearPolicyViolation(cr *certmanv1alpha1.CertificateRequest) {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestPolicyViolation {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+)
+
+// testRSAPublicKey returns an RSA public key of size bits, which is only ever measured.
+func testRSAPublicKey(size int) *rsa.PublicKey {
+	return &rsa.PublicKey{N: new(big.Int).Lsh(big.NewInt(1), uint(size-1)), E: 65537}
+}
+
+// testQualityChain returns a certificate valid for 90 days and its intermediate, both passing the
+// default checks.
+func testQualityChain() []*x509.Certificate {
+	now := time.Now()
+	return []*x509.Certificate{
+		{
+			Subject:            pkix.Name{CommonName: "api.example.com"},
+			Issuer:             pkix.Name{CommonName: "R3", Organization: []string{"Let's Encrypt"}},
+			SignatureAlgorithm: x509.SHA256WithRSA,
+			PublicKey:          testRSAPublicKey(2048),
+			NotBefore:          now,
+			NotAfter:           now.Add(90 * 24 * time.Hour),
+		},
+		{
+			Subject:            pkix.Name{CommonName: "R3", Organization: []string{"Let's Encrypt"}},
+			Issuer:             pkix.Name{CommonName: "ISRG Root X1", Organization: []string{"Internet Security Research Group"}},
+			SignatureAlgorithm: x509.SHA256WithRSA,
+			PublicKey:          &ecdsa.PublicKey{Curve: elliptic.P384()},
+			NotBefore:          now,
+			NotAfter:           now.Add(5 * 365 * 24 * time.Hour),
+		},
+	}
+}
+
+// testSCTListExtension returns the extension embedding an SCT of each log of logIDs, each log ID
+// filling the 32 bytes of the ID of a different log.
+func testSCTListExtension(logIDs ...byte) pkix.Extension {
+	list := []byte{}
+	for _, logID := range logIDs {
+		// version, log ID, timestamp, empty extensions and a dummy signature
+		sct := append([]byte{0}, bytes.Repeat([]byte{logID}, 32)...)
+		sct = append(sct, make([]byte, 8+2)...)
+		sct = append(sct, 4, 3, 0, 2, 0xca, 0xfe)
+		list = binary.BigEndian.AppendUint16(list, uint16(len(sct)))
+		list = append(list, sct...)
+	}
+	value, err := asn1.Marshal(append(binary.BigEndian.AppendUint16(nil, uint16(len(list))), list...))
+	if err != nil {
+		panic(err)
+	}
+	return pkix.Extension{Id: sctListExtensionOID, Value: value}
+}
+
+func TestCertificateQualityViolations(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *certmanv1alpha1.CertificateQualityPolicy
+		modify   func(chain []*x509.Certificate)
+		expected []string
+	}{
+		{
+			name:   "no policy",
+			modify: func(chain []*x509.Certificate) { chain[0].PublicKey = testRSAPublicKey(1024) },
+		},
+		{
+			name:   "passes the defaults",
+			policy: &certmanv1alpha1.CertificateQualityPolicy{},
+		},
+		{
+			name:     "SHA-1 intermediate",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{},
+			modify:   func(chain []*x509.Certificate) { chain[1].SignatureAlgorithm = x509.SHA1WithRSA },
+			expected: []string{`intermediate "R3" is signed with SHA1-RSA`},
+		},
+		{
+			name:   "SHA-1 allowed",
+			policy: &certmanv1alpha1.CertificateQualityPolicy{AllowSHA1Signatures: true},
+			modify: func(chain []*x509.Certificate) { chain[1].SignatureAlgorithm = x509.SHA1WithRSA },
+		},
+		{
+			name:     "MD5 is never allowed",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{AllowSHA1Signatures: true},
+			modify:   func(chain []*x509.Certificate) { chain[0].SignatureAlgorithm = x509.MD5WithRSA },
+			expected: []string{"the certificate is signed with MD5-RSA"},
+		},
+		{
+			name:     "weak RSA key",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{},
+			modify:   func(chain []*x509.Certificate) { chain[0].PublicKey = testRSAPublicKey(1024) },
+			expected: []string{"the certificate has a 1024 bit RSA key, the minimum is 2048"},
+		},
+		{
+			name:     "RSA key under a raised minimum",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{MinRSAKeySize: 3072},
+			expected: []string{"the certificate has a 2048 bit RSA key, the minimum is 3072"},
+		},
+		{
+			name:     "weak ECDSA key",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{},
+			modify:   func(chain []*x509.Certificate) { chain[0].PublicKey = &ecdsa.PublicKey{Curve: elliptic.P224()} },
+			expected: []string{"the certificate has a 224 bit ECDSA key, the minimum is 256"},
+		},
+		{
+			name:     "valid for too long",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{MaxValidity: &metav1.Duration{Duration: 30 * 24 * time.Hour}},
+			expected: []string{"the certificate is valid for 2160h0m0s, the maximum is 720h0m0s"},
+		},
+		{
+			name:   "SCTs from enough logs",
+			policy: &certmanv1alpha1.CertificateQualityPolicy{MinEmbeddedSCTs: 2},
+			modify: func(chain []*x509.Certificate) {
+				chain[0].Extensions = []pkix.Extension{testSCTListExtension(1, 2)}
+			},
+		},
+		{
+			name:   "SCTs from the same log",
+			policy: &certmanv1alpha1.CertificateQualityPolicy{MinEmbeddedSCTs: 2},
+			modify: func(chain []*x509.Certificate) {
+				chain[0].Extensions = []pkix.Extension{testSCTListExtension(1, 1)}
+			},
+			expected: []string{"the certificate embeds SCTs from 1 distinct logs, the minimum is 2"},
+		},
+		{
+			name:     "no SCTs",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{MinEmbeddedSCTs: 2},
+			expected: []string{"the certificate embeds SCTs from 0 distinct logs, the minimum is 2"},
+		},
+		{
+			name:   "malformed SCT list",
+			policy: &certmanv1alpha1.CertificateQualityPolicy{MinEmbeddedSCTs: 1},
+			modify: func(chain []*x509.Certificate) {
+				extension := testSCTListExtension(1)
+				extension.Value = extension.Value[:len(extension.Value)-4]
+				chain[0].Extensions = []pkix.Extension{extension}
+			},
+			expected: []string{"the certificate has a malformed SCT list: asn1: syntax error: data truncated"},
+		},
+		{
+			name:     "disallowed issuer",
+			policy:   &certmanv1alpha1.CertificateQualityPolicy{DisallowedIssuers: []string{"internet security research group"}},
+			expected: []string{`intermediate "R3" is issued by disallowed issuer "Internet Security Research Group"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chain := testQualityChain()
+			if test.modify != nil {
+				test.modify(chain)
+			}
+			violations := certificateQualityViolations(test.policy, chain)
+			if test.expected == nil {
+				assert.Empty(t, violations)
+				return
+			}
+			assert.Equal(t, test.expected, violations)
+		})
+	}
+}
+
+func TestCheckCertificateQuality(t *testing.T) {
+	assert.NoError(t, certmanv1alpha1.AddToScheme(scheme.Scheme))
+
+	operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName},
+		Spec: certmanv1alpha1.CertmanOperatorConfigSpec{
+			CertificateQualityPolicy: &certmanv1alpha1.CertificateQualityPolicy{},
+		},
+	}
+	cr := certRequest.DeepCopy()
+	recorder := record.NewFakeRecorder(10)
+	rcr := CertificateRequestReconciler{Client: setUpTestClient(t, []runtime.Object{cr, operatorConfig}), Recorder: recorder}
+
+	weak := testQualityChain()
+	weak[0].PublicKey = testRSAPublicKey(1024)
+	err := rcr.checkCertificateQuality(logr.Discard(), cr, weak)
+	assert.True(t, errors.Is(err, errCertificatePolicyViolation), "expected a policy violation, got %v", err)
+	if assert.Len(t, cr.Status.Conditions, 1) {
+		assert.Equal(t, certmanv1alpha1.CertificateRequestPolicyViolation, cr.Status.Conditions[0].Type)
+		assert.Contains(t, *cr.Status.Conditions[0].Message, "1024 bit RSA key")
+	}
+	assert.Len(t, recorder.Events, 1)
+
+	// the same violation isn't reported twice
+	err = rcr.checkCertificateQuality(logr.Discard(), cr, weak)
+	assert.True(t, errors.Is(err, errCertificatePolicyViolation))
+	assert.Len(t, recorder.Events, 1)
+
+	assert.NoError(t, rcr.checkCertificateQuality(logr.Discard(), cr, testQualityChain()))
+	assert.Empty(t, cr.Status.Conditions)
+}
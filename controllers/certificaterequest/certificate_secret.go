@@ -0,0 +1,125 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// certificateSecretKeys are the keys of the secret a certificate is stored in
+type certificateSecretKeys struct {
+	certificate string
+	privateKey  string
+	// ca is empty when the issuer certificate isn't stored on its own
+	ca string
+}
+
+// secretKeys returns the keys of the certificate secret of cr, following its CertificateSecretTemplate.
+func secretKeys(cr *certmanv1alpha1.CertificateRequest) certificateSecretKeys {
+	keys := certificateSecretKeys{
+		certificate: corev1.TLSCertKey,
+		privateKey:  corev1.TLSPrivateKeyKey,
+	}
+
+	template := cr.Spec.CertificateSecretTemplate
+	if template == nil {
+		return keys
+	}
+	if template.CertificateKey != "" {
+		keys.certificate = template.CertificateKey
+	}
+	if template.PrivateKeyKey != "" {
+		keys.privateKey = template.PrivateKeyKey
+	}
+	keys.ca = template.CAKey
+
+	return keys
+}
+
+// secretType returns the type of the certificate secret created for cr.
+func secretType(cr *certmanv1alpha1.CertificateRequest) corev1.SecretType {
+	if cr.Spec.CertificateSecretTemplate == nil || cr.Spec.CertificateSecretTemplate.Type == "" {
+		return corev1.SecretTypeTLS
+	}
+	return cr.Spec.CertificateSecretTemplate.Type
+}
+
+// validateCertificateSecretTemplate returns an error when the CertificateSecretTemplate of cr
+// can't be used to store a certificate.
+func validateCertificateSecretTemplate(cr *certmanv1alpha1.CertificateRequest) error {
+	switch t := secretType(cr); t {
+	case corev1.SecretTypeTLS, corev1.SecretTypeOpaque:
+	default:
+		return fmt.Errorf("certificate secret type %s is not supported, use %s or %s", t, corev1.SecretTypeTLS, corev1.SecretTypeOpaque)
+	}
+
+	keys := secretKeys(cr)
+	names := []string{keys.certificate, keys.privateKey}
+	if keys.ca != "" {
+		names = append(names, keys.ca)
+	}
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		if errs := validation.IsConfigMapKey(name); len(errs) > 0 {
+			return fmt.Errorf("certificate secret key %q is invalid: %s", name, strings.Join(errs, ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("certificate secret key %q is used more than once", name)
+		}
+		seen[name] = true
+	}
+
+	// tls.crt and tls.key of a kubernetes.io/tls secret always hold the chain and the private key
+	if secretType(cr) == corev1.SecretTypeTLS {
+		if keys.certificate == corev1.TLSPrivateKeyKey || keys.privateKey == corev1.TLSCertKey ||
+			keys.ca == corev1.TLSCertKey || keys.ca == corev1.TLSPrivateKeyKey {
+			return fmt.Errorf("%s and %s of a %s certificate secret can't hold other data", corev1.TLSCertKey, corev1.TLSPrivateKeyKey, corev1.SecretTypeTLS)
+		}
+	}
+
+	return nil
+}
+
+// setCertificateSecretData stores the certificate chain, the issuer certificate and the private
+// key in certificateSecret under the keys of cr. A kubernetes.io/tls secret also gets tls.crt and
+// tls.key, which its type requires. key is nil when the private key is kept outside of the
+// cluster, it is then not stored and tls.key is empty.
+func setCertificateSecretData(certificateSecret *corev1.Secret, cr *certmanv1alpha1.CertificateRequest, chain []byte, issuer []byte, key []byte) {
+	keys := secretKeys(cr)
+
+	data := map[string][]byte{}
+	if certificateSecret.Type == corev1.SecretTypeTLS {
+		data[corev1.TLSCertKey] = chain
+		data[corev1.TLSPrivateKeyKey] = append([]byte{}, key...)
+	}
+	data[keys.certificate] = chain
+	if key != nil {
+		data[keys.privateKey] = key
+	}
+	if keys.ca != "" {
+		data[keys.ca] = issuer
+	}
+
+	certificateSecret.Data = data
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestCertificateSecretTemplate(t *testing.T) {
+	chain, issuer, key := []byte("chain"), []byte("issuer"), []byte("key")
+
+	tests := []struct {
+		name         string
+		template     *certmanv1alpha1.CertificateSecretTemplate
+		expectErr    bool
+		expectedType corev1.SecretType
+		expectedData map[string][]byte
+	}{
+		{
+			name:         "default",
+			expectedType: corev1.SecretTypeTLS,
+			expectedData: map[string][]byte{"tls.crt": chain, "tls.key": key},
+		},
+		{
+			name:         "tls with additional keys",
+			template:     &certmanv1alpha1.CertificateSecretTemplate{CertificateKey: "fullchain.pem", PrivateKeyKey: "privkey.pem", CAKey: "ca.crt"},
+			expectedType: corev1.SecretTypeTLS,
+			expectedData: map[string][]byte{"tls.crt": chain, "tls.key": key, "fullchain.pem": chain, "privkey.pem": key, "ca.crt": issuer},
+		},
+		{
+			name:         "opaque with custom keys",
+			template:     &certmanv1alpha1.CertificateSecretTemplate{Type: corev1.SecretTypeOpaque, CertificateKey: "cert", PrivateKeyKey: "key"},
+			expectedType: corev1.SecretTypeOpaque,
+			expectedData: map[string][]byte{"cert": chain, "key": key},
+		},
+		{
+			name:         "opaque with default keys",
+			template:     &certmanv1alpha1.CertificateSecretTemplate{Type: corev1.SecretTypeOpaque, CAKey: "ca.crt"},
+			expectedType: corev1.SecretTypeOpaque,
+			expectedData: map[string][]byte{"tls.crt": chain, "tls.key": key, "ca.crt": issuer},
+		},
+		{
+			name:      "unsupported type",
+			template:  &certmanv1alpha1.CertificateSecretTemplate{Type: corev1.SecretTypeDockerConfigJson},
+			expectErr: true,
+		},
+		{
+			name:      "invalid key",
+			template:  &certmanv1alpha1.CertificateSecretTemplate{CertificateKey: "tls/crt"},
+			expectErr: true,
+		},
+		{
+			name:      "key used twice",
+			template:  &certmanv1alpha1.CertificateSecretTemplate{Type: corev1.SecretTypeOpaque, CertificateKey: "cert", CAKey: "cert"},
+			expectErr: true,
+		},
+		{
+			name:      "tls key holding the chain",
+			template:  &certmanv1alpha1.CertificateSecretTemplate{CertificateKey: "tls.key", PrivateKeyKey: "privkey.pem"},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			cr.Spec.CertificateSecretTemplate = test.template
+
+			err := validateCertificateSecretTemplate(cr)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			secret := newSecret(cr)
+			assert.Equal(t, test.expectedType, secret.Type)
+
+			setCertificateSecretData(secret, cr, chain, issuer, key)
+			assert.Equal(t, test.expectedData, secret.Data)
+		})
+	}
+}
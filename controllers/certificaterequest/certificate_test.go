@@ -17,8 +17,10 @@ limitations under the License.
 package certificaterequest
 
 import (
+	"errors"
 	"testing"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
@@ -35,6 +37,16 @@ func TestParseCertificateData(t *testing.T) {
 			data:    leAccountPrivKey,
 			wantErr: true,
 		},
+		{
+			name:    "no PEM data test",
+			data:    []byte("not a certificate"),
+			wantErr: true,
+		},
+		{
+			name:    "valid certificate test",
+			data:    validCertSecret.Data[v1.TLSCertKey],
+			wantErr: false,
+		},
 	}
 
 	for _, test := range tests {
@@ -43,6 +55,9 @@ func TestParseCertificateData(t *testing.T) {
 			if (err != nil) != test.wantErr {
 				t.Errorf("ParseCertificateData() Got unexpected error: %v", err)
 			}
+			if err != nil && !errors.Is(err, ErrCorruptCertificate) {
+				t.Errorf("ParseCertificateData() error %v is not ErrCorruptCertificate", err)
+			}
 		})
 	}
 }
@@ -21,8 +21,7 @@ import (
 	gerrors "errors"
 
 	"fmt"
-	"os"
-	"strings"
+	"runtime/debug"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -33,37 +32,52 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/controllers/utils"
 	cClient "github.com/openshift/certman-operator/pkg/clients"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/exporters"
 	"github.com/openshift/certman-operator/pkg/leclient"
 	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+	"github.com/openshift/certman-operator/pkg/sharding"
 )
 
 const (
 	controllerName                        = "controller_certificaterequest"
-	maxConcurrentReconciles               = 10
-	hiveRelocationAnnotation              = "hive.openshift.io/relocate"
-	hiveRelocationOutgoingValue           = "outgoing"
 	hiveRelocationCertificateRequstStatus = "Not reconciling: ClusterDeployment is relocating"
-	fedrampEnvVariable                    = "FEDRAMP"
-	fedrampHostedZoneIDVariable           = "HOSTED_ZONE_ID"
 	clusterDeploymentType                 = "ClusterDeployment"
+	certificateRequestType                = "CertificateRequest"
+
+	// reasons reported when the deletion of a CertificateRequest is blocked by the finalizer
+	finalizerBlockedRevocation         = "revocation_failed"
+	finalizerBlockedZoneRecordDeletion = "zone_record_deletion_failed"
+	finalizerBlockedExportDeletion     = "export_deletion_failed"
+	finalizerBlockedFinalizerRemoval   = "finalizer_removal_failed"
+
+	// NotAfterAnnotation is set on certificate secrets to the expiry of their certificate, in RFC 3339
+	NotAfterAnnotation = "certman.managed.openshift.io/not-after"
+	// RenewalScheduledAtAnnotation is set on certificate secrets to the time from which their
+	// certificate is reissued, in RFC 3339
+	RenewalScheduledAtAnnotation = "certman.managed.openshift.io/renewal-scheduled-at"
+	// RenewRequestedAtAnnotation asks for the certificate of a CertificateRequest to be reissued. It
+	// holds the time of the request, in RFC 3339, and is removed once the certificate is reissued.
+	RenewRequestedAtAnnotation = "certman.managed.openshift.io/renew-requested-at"
 )
 
-var fedramp = os.Getenv(fedrampEnvVariable) == "true"
-var fedrampHostedZoneID = os.Getenv(fedrampHostedZoneIDVariable)
-var log = logf.Log.WithName(controllerName)
-
 var _ reconcile.Reconciler = &CertificateRequestReconciler{}
 
 // CertificateRequestReconciler reconciles a CertificateRequest object
@@ -71,29 +85,123 @@ type CertificateRequestReconciler struct {
 	Client        client.Client
 	Scheme        *runtime.Scheme
 	ClientBuilder func(reqLogger logr.Logger, kubeClient client.Client, platfromSecret certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (cClient.Client, error)
+	Recorder      record.EventRecorder
+	// Shard, when set, restricts the controller to the namespaces this replica owns, and the
+	// controller then runs on every replica instead of only the leader
+	Shard *sharding.Shard
+	// Clock tells the time renewals and expiry metrics are evaluated at, it defaults to the system
+	// clock
+	Clock clock.Clock
+	// Fedramp, when enabled, has the challenges of every cluster answered in its hosted zone. It
+	// must match the Fedramp of the client builder.
+	Fedramp cTypes.Fedramp
+	// ReconcileWorkers is the number of CertificateRequests reconciled at a time, it defaults to
+	// DefaultReconcileWorkers
+	ReconcileWorkers int
+	// IssuanceWorkers, when above 0, moves the issuance and revocation of certificates off the
+	// reconciles onto that many workers of their own
+	IssuanceWorkers int
+	// ExporterBuilder builds the exporters storing certificates in the cloud secret stores of the
+	// exports of CertificateRequests, exports are left alone when it is nil
+	ExporterBuilder exporters.Builder
+	// RequeueIntervals overrides how long a CertificateRequest waiting on something outside of the
+	// operator waits before it is reconciled again
+	RequeueIntervals utils.RequeueIntervals
+
+	issuance *issuanceWorkers
+}
+
+// now returns the current time of the Clock of r.
+func (r *CertificateRequestReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
 }
 
 // Reconcile reads that state of the cluster for a CertificateRequest object and makes changes based on the state read
-// and what is in the CertificateRequest.Spec
-func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
-	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+// and what is in the CertificateRequest.Spec. A panic while reconciling is turned into an error rather than crashing
+// the operator.
+func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+	ctx = logging.IntoContext(ctx, logging.FromContext(ctx, logging.CertificateRequestKey, logging.ObjectName(request.Namespace, request.Name)))
+	defer func() {
+		if p := recover(); p != nil {
+			result, err = reconcile.Result{}, r.handleReconcilePanic(ctx, request, p)
+		}
+	}()
+
+	result, err = r.reconcileCertificateRequest(ctx, request)
+	if err == nil {
+		r.clearReconcilePanics(ctx, request)
+	}
+	return result, err
+}
+
+// clearReconcilePanics forgets the panics recorded on the CertificateRequest of request after it was
+// reconciled successfully. Failures are logged, the count is cleared by a later reconcile.
+func (r *CertificateRequestReconciler) clearReconcilePanics(ctx context.Context, request reconcile.Request) {
+	cr := &certmanv1alpha1.CertificateRequest{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, cr); err != nil {
+		if !errors.IsNotFound(err) {
+			logging.FromContext(ctx).Error(err, "unable to clear the panics recorded on CertificateRequest")
+		}
+		return
+	}
+	if err := utils.ClearReconcilePanics(r.Client, cr); err != nil && !errors.IsNotFound(err) {
+		logging.FromContext(ctx).Error(err, "unable to clear the panics recorded on CertificateRequest")
+	}
+}
+
+// handleReconcilePanic logs a panic recovered while reconciling request with the logger of ctx and records it on
+// the CertificateRequest, which is marked as a poison pill and skipped by further reconciles once it has panicked
+// too many times.
+func (r *CertificateRequestReconciler) handleReconcilePanic(ctx context.Context, request reconcile.Request, p interface{}) error {
+	reqLogger := logging.FromContext(ctx)
+	panicErr := fmt.Errorf("recovered from panic: %v", p)
+	reqLogger.Error(panicErr, "panic while reconciling CertificateRequest", "stacktrace", string(debug.Stack()))
+	localmetrics.IncrementReconcilePanicCount(controllerName)
+
+	cr := &certmanv1alpha1.CertificateRequest{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, cr); err != nil {
+		reqLogger.Error(err, "unable to record panic on CertificateRequest")
+		return panicErr
+	}
+
+	poisoned, err := utils.RecordReconcilePanic(r.Client, cr)
+	if err != nil {
+		reqLogger.Error(err, "unable to record panic on CertificateRequest")
+		return panicErr
+	}
+	if poisoned {
+		reqLogger.Info(fmt.Sprintf("CertificateRequest panicked %d times and is marked with the %s annotation, it will not be reconciled until the annotation is removed", utils.MaxReconcilePanics, utils.PoisonPillAnnotation))
+	}
+
+	return panicErr
+}
+
+// reconcileCertificateRequest does the actual work of Reconcile.
+func (r *CertificateRequestReconciler) reconcileCertificateRequest(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := logging.FromContext(ctx)
+
+	// The namespace may have moved to another replica since the request was queued
+	if r.Shard != nil && !r.Shard.Owns(request.Namespace) {
+		reqLogger.Info("not reconciling: the namespace is owned by another replica")
+		return reconcile.Result{}, nil
+	}
+
+	if r.ownedByIssuanceWorkers(ctx, request) {
+		reqLogger.Info("not reconciling: the CertificateRequest is with the issuance workers")
+		return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitIssuanceWorkers)}, nil
+	}
 
 	reqLogger.Info("reconciling CertificateRequest")
-	envvar, present := os.LookupEnv(fedrampEnvVariable)
-	if len(envvar) == 0 || !present {
-		reqLogger.Info("FEDRAMP environment variable unset, defaulting to false")
-	} else {
-		reqLogger.Info(fmt.Sprintf("running in FedRAMP environment: %t", fedramp))
-	}
-
-	if fedramp {
-		envvar, present = os.LookupEnv(fedrampHostedZoneIDVariable)
-		if len(envvar) == 0 || !present {
-			err := gerrors.New("HOSTED_ZONE_ID environment variable is unset but is required in FedRAMP environment")
-			reqLogger.Error(err, "HOSTED_ZONE_ID environment variable is unset but is required in FedRAMP environment")
-			return reconcile.Result{}, nil
+	if r.Fedramp.Enabled {
+		if r.Fedramp.HostedZoneID == "" {
+			err := fmt.Errorf("%s environment variable is unset but is required in FedRAMP environment", cTypes.FedrampHostedZoneIDVariable)
+			reqLogger.Error(err, err.Error())
+			return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitFedrampConfig)}, nil
 		}
-		reqLogger.Info(fmt.Sprintf("running in FedRAMP zone: %s", fedrampHostedZoneID))
+		reqLogger.Info(fmt.Sprintf("running in FedRAMP zone: %s", r.Fedramp.HostedZoneID))
 	}
 
 	timer := prometheus.NewTimer(localmetrics.MetricCertificateRequestReconcileDuration)
@@ -101,6 +209,7 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, request re
 		reconcileDuration := timer.ObserveDuration()
 		reqLogger.WithValues("Duration", reconcileDuration).Info("Reconcile complete.")
 	}()
+	ctx = withReconcileDeadline(ctx, r.now().Add(utils.GetReconcileDeadline(r.Client)))
 
 	// Init the certificate request counter if nor already done
 	localmetrics.CheckInitCounter(r.Client)
@@ -113,116 +222,113 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, request re
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Update metrics to show it's missing and set CertValidDuration to 0
-			localmetrics.UpdateCertValidDuration(r.Client, nil, time.Now(), request.Namespace, request.Namespace)
+			localmetrics.UpdateCertValidDuration(r.Client, nil, r.now(), request.Namespace, request.Namespace)
+			localmetrics.ForgetCertRequest(request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		reqLogger.Error(err, err.Error())
-		localmetrics.UpdateCertValidDuration(r.Client, nil, time.Now(), cr.Namespace, cr.Namespace)
+		localmetrics.UpdateCertValidDuration(r.Client, nil, r.now(), cr.Namespace, cr.Namespace)
 		return reconcile.Result{}, err
 	}
+	reqLogger = logging.WithProvider(reqLogger, cr.Spec.Platform)
 
 	// Handle the presence of a deletion timestamp.
 	if !cr.DeletionTimestamp.IsZero() {
 		// Set CertValidDuration to 0 for certificates being deleted
-		localmetrics.UpdateCertValidDuration(r.Client, nil, time.Now(), cr.Namespace, cr.Namespace)
+		localmetrics.UpdateCertValidDuration(r.Client, nil, r.now(), cr.Namespace, cr.Namespace)
+		if utils.ContainsString(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) &&
+			r.deferToIssuanceWorkers(ctx, reqLogger, request) {
+			return reconcile.Result{}, nil
+		}
 		return r.finalizeCertificateRequest(reqLogger, cr)
 	}
 
-	// Add finalizer if not exists
-	if !utils.ContainsString(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
-		reqLogger.Info("adding finalizer to the certificate request")
-		localmetrics.IncrementCertRequestsCounter()
-		baseToPatch := client.MergeFrom(cr.DeepCopy())
-		cr.ObjectMeta.Finalizers = append(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
-		if err := r.Client.Patch(context.TODO(), cr, baseToPatch); err != nil {
-			reqLogger.Error(err, err.Error())
-			return reconcile.Result{}, err
-		}
+	// Skip objects that keep crashing the reconcile loop. Deletions still go through so a poison
+	// pill doesn't block the deletion of its namespace.
+	if utils.IsPoisonPill(cr) {
+		reqLogger.Info(fmt.Sprintf("not reconciling: CertificateRequest is marked with the %s annotation", utils.PoisonPillAnnotation))
+		localmetrics.IncrementPoisonPillSkipCount(controllerName)
+		return reconcile.Result{}, nil
 	}
 
-	// Just in case something else ever adds itself as an owner of the certificaterequest,
-	// loop through the owner references to find which one is the clusterdeployment
-	clusterDeploymentName := ""
-
-	for _, o := range cr.ObjectMeta.OwnerReferences {
-		if o.Kind == clusterDeploymentType {
-			clusterDeploymentName = o.Name
-		}
-	}
-	if clusterDeploymentName == "" {
-		// Assume there's only one clusterdeployment in a namespace and that it's the owner of this certificaterequest
-		// We have to assume this so that if/when a CertificateRequest loses its OwnerReferences, it can still reconcile
-		cdList := &hivev1.ClusterDeploymentList{}
-		err = r.Client.List(context.TODO(), cdList)
+	// The canary CertificateRequest of the operator isn't part of any cluster. The ClusterDeployment
+	// is only fetched here, the rest of the reconcile works with this copy.
+	var cd *hivev1.ClusterDeployment
+	if !IsCanary(cr) {
+		cd, err = r.getClusterDeployment(reqLogger, cr)
 		if err != nil {
-			reqLogger.Error(err, err.Error())
 			return reconcile.Result{}, err
 		}
-
-		// If we still can't find a clusterdeployment, throw an error
-		if len(cdList.Items) == 0 {
-			err = gerrors.New("ClusterDeployment not found")
-			reqLogger.Error(err, "ClusterDeployment not found")
-			return reconcile.Result{}, err
-		}
-
-		clusterDeploymentName = cdList.Items[0].Name
 	}
 
-	cd := &hivev1.ClusterDeployment{}
-	err = r.Client.Get(context.TODO(), types.NamespacedName{Namespace: request.Namespace, Name: clusterDeploymentName}, cd)
+	// Only act on the CertificateRequests the policy of the operator allows
+	authorized, err := r.checkAuthorization(reqLogger, cr, cd)
 	if err != nil {
-		reqLogger.Error(err, err.Error())
 		return reconcile.Result{}, err
 	}
+	if !authorized {
+		return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitNotAuthorized)}, nil
+	}
 
-	// If the ownerreference isn't there, add it
-	if len(cr.OwnerReferences) == 0 {
+	// Add finalizer if not exists
+	if !utils.ContainsString(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
+		reqLogger.Info("adding finalizer to the certificate request")
 		baseToPatch := client.MergeFrom(cr.DeepCopy())
-		missingOwnerReference := metav1.OwnerReference{
-			APIVersion:         fmt.Sprintf("%s/%s", hivev1.HiveAPIGroup, hivev1.HiveAPIVersion),
-			Kind:               "ClusterDeployment",
-			Name:               cd.Name,
-			UID:                cd.UID,
-			Controller:         boolPointer(true),
-			BlockOwnerDeletion: boolPointer(true),
-		}
-		cr.OwnerReferences = []metav1.OwnerReference{missingOwnerReference}
-
-		reqLogger.WithValues("CertificateRequest.Name", cr.Name, "OwnerReference.Name", missingOwnerReference.Name).Info("adding OwnerReference to CertificateRequest")
+		cr.ObjectMeta.Finalizers = append(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
 		if err := r.Client.Patch(context.TODO(), cr, baseToPatch); err != nil {
 			reqLogger.Error(err, err.Error())
 			return reconcile.Result{}, err
 		}
 	}
+	localmetrics.RecordCertRequest(cr.Namespace, cr.Name)
 
-	// Fetch the clusterdeployment and bail out if there's an outgoing migration annotation
-	relocating, err := relocationBailOut(r.Client, types.NamespacedName{Namespace: request.Namespace, Name: cd.Name})
-	if err != nil {
-		if !errors.IsNotFound(err) {
-			// If the ClusterDeployment was deleted by some other means, then we should just proceed anyways (we could be deleting this object)
-			// Otherwise raise an error and requeue.
-			reqLogger.Error(err, err.Error())
+	// CertificateRequests are only adopted once authorized, adoption would exempt them from the policy
+	clusterDeploymentName := ""
+	if !IsCanary(cr) {
+		if cd == nil {
+			err = gerrors.New("ClusterDeployment not found")
+			reqLogger.Error(err, "ClusterDeployment not found")
+			return reconcile.Result{}, err
+		}
+		if err := r.adoptClusterDeployment(reqLogger, cr, cd); err != nil {
 			return reconcile.Result{}, err
 		}
+		clusterDeploymentName = cd.Name
+		reqLogger = logging.WithCluster(reqLogger, cd.Namespace, cd.Name)
 	}
+	ctx = logging.IntoContext(ctx, reqLogger)
 
-	if relocating {
+	// Features being rolled out are enabled per cluster, with the annotation of the ClusterDeployment
+	var cdAnnotations map[string]string
+	if cd != nil {
+		cdAnnotations = cd.Annotations
+	}
+	ctx = utils.FeaturesIntoContext(ctx, utils.GetFeatures(r.Client, cdAnnotations))
+
+	// Bail out if there's an outgoing migration annotation
+	if cd != nil && utils.IsRelocating(cd) {
 		reqLogger.Info("Not reconciling, clusterdeployment is relocating")
+		return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitRelocation)}, r.reportRelocating(cr)
+	}
 
-		cr.Status.Status = hiveRelocationCertificateRequstStatus
-		err = r.Client.Update(context.TODO(), cr)
-		if err != nil {
+	// Of the CertificateRequests of a namespace requesting the same DNS names, only the oldest
+	// issues certificates, the others get a copy of its certificate
+	canonical, err := r.checkDuplicate(reqLogger, cr)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	duplicate := canonical != nil
+	if duplicate {
+		if err := r.copyCanonicalCertificate(reqLogger, cr, canonical); err != nil {
+			reqLogger.Error(err, "could not copy the certificate of the canonical CertificateRequest")
 			return reconcile.Result{}, err
 		}
-
-		return reconcile.Result{}, nil
 	}
 
 	found := &corev1.Secret{}
 
-	leClient, err := leclient.NewClient(r.Client)
+	leClient, err := leclient.NewIssuerClient(r.Client, cr.Spec.IssuerRef)
 	if err != nil {
 		reqLogger.Error(err, "failed to get letsencrypt client")
 		return reconcile.Result{}, err
@@ -233,14 +339,41 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, request re
 	// Issue new certificates if the secret does not already exist
 	if err != nil {
 		if errors.IsNotFound(err) {
+			r.reportCertificateHealth(reqLogger, cr, nil)
+			if r.issuanceDisabled(reqLogger, cr) {
+				return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitFeatureGate)}, nil
+			}
+			if duplicate {
+				return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitDuplicate)}, nil
+			}
+			if r.issuanceCeilingReached(reqLogger, leClient) {
+				return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitIssuanceCeiling)}, nil
+			}
+			if r.deferToIssuanceWorkers(ctx, reqLogger, request) {
+				return reconcile.Result{}, nil
+			}
+			if relocating, err := r.relocationStarted(reqLogger, cr, cd); relocating || err != nil {
+				return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitRelocation)}, err
+			}
 			reqLogger.Info("requesting new certificates as secret was not found")
-			return r.createCertificateSecret(reqLogger, cr, leClient)
+			return r.createCertificateSecret(ctx, reqLogger, cr, cd, leClient)
 		}
 
 		reqLogger.Error(err, err.Error())
 		return reconcile.Result{}, err
 	}
 
+	// A restored secret still points at the CertificateRequest that was backed up
+	if _, err := r.adoptRestoredSecret(reqLogger, cr, found); err != nil {
+		reqLogger.Error(err, "could not adopt the restored certificate secret")
+		return reconcile.Result{}, err
+	}
+	if err := r.adoptCABundleSecret(reqLogger, cr, found); err != nil {
+		reqLogger.Error(err, "could not adopt the restored CA bundle secret")
+		return reconcile.Result{}, err
+	}
+	r.reportCertificateHealth(reqLogger, cr, found)
+
 	reqLogger.Info("checking if certificates need to be reissued")
 
 	// Reissue Certificates
@@ -250,36 +383,75 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, request re
 		return reconcile.Result{}, err
 	}
 
-	// Fetch the clusterdeployment and bail out if there's an outgoing migration annotation again
-	relocating, err = relocationBailOut(r.Client, types.NamespacedName{Namespace: request.Namespace, Name: clusterDeploymentName})
-	if err != nil {
-		return reconcile.Result{}, err
-	}
-	if relocating {
-		reqLogger.Info("Not reconciling, clusterdeployment is relocating")
-
-		cr.Status.Status = hiveRelocationCertificateRequstStatus
-		err = r.Client.Update(context.TODO(), cr)
+	// Renewals that aren't urgent wait for the maintenance window of the cluster
+	if shouldReissue {
+		deferred, err := r.deferRenewal(reqLogger, cr, cd, r.now())
 		if err != nil {
+			reqLogger.Error(err, "could not update the RenewalDeferred condition")
 			return reconcile.Result{}, err
 		}
+		shouldReissue = !deferred
+	}
 
-		return reconcile.Result{}, nil
+	// the status and the secret sync go on while a feature gate holds the renewal back
+	result := reconcile.Result{}
+	if shouldReissue && r.issuanceDisabled(reqLogger, cr) {
+		shouldReissue = false
+		result.RequeueAfter = r.RequeueIntervals.After(utils.WaitFeatureGate)
+	}
+	// a duplicate copies the renewals of the canonical CertificateRequest, whose secret doesn't
+	// trigger its reconciles
+	if duplicate {
+		shouldReissue = false
+		result.RequeueAfter = r.RequeueIntervals.After(utils.WaitDuplicate)
+	}
+	if shouldReissue && r.issuanceCeilingReached(reqLogger, leClient) {
+		shouldReissue = false
+		result.RequeueAfter = r.RequeueIntervals.After(utils.WaitIssuanceCeiling)
+	}
+	// the domains left out by a partial issuance are retried once their backoff elapsed
+	if !shouldReissue && result.RequeueAfter == 0 && cr.Status.PartialIssuance != nil {
+		if retryIn := cr.Status.PartialIssuance.RetryAfter.Sub(r.now()); retryIn > 0 {
+			result.RequeueAfter = retryIn
+		}
 	}
 
 	if shouldReissue {
-		err := r.IssueCertificate(reqLogger, cr, found, leClient)
+		if r.deferToIssuanceWorkers(ctx, reqLogger, request) {
+			return reconcile.Result{}, nil
+		}
+		if relocating, err := r.relocationStarted(reqLogger, cr, cd); relocating || err != nil {
+			return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitRelocation)}, err
+		}
+
+		// the certificate being replaced tells why it is reissued, a secret without a valid
+		// certificate gets its first one
+		previous, _ := GetCertificate(r.Client, cr)
+		trigger := issuanceTrigger(cr, previous)
+
+		err := r.IssueCertificate(ctx, reqLogger, cr, cd, found, leClient)
+		if gerrors.Is(err, errReconcileDeadline) {
+			return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitReconcileDeadline)}, nil
+		}
 		if err != nil {
+			r.recordACMEFailure(reqLogger, cr, err)
 			return reconcile.Result{}, err
 		}
 
-		localmetrics.AddCertificateIssuance("renewal")
+		localmetrics.AddCertificateIssuance(string(trigger), string(leClient.GetEnvironment()))
+		r.recordAccountIssuance(reqLogger, cr, leClient)
 		err = r.Client.Update(context.TODO(), found)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
+		r.setIssuanceStage(reqLogger, cr, certmanv1alpha1.IssuanceStageStored)
 
-		err = r.updateStatus(reqLogger, cr)
+		err = r.clearRenewalRequest(reqLogger, cr)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		err = r.updateStatus(reqLogger, cr, leClient.GetEnvironment(), trigger)
 		if err != nil {
 			reqLogger.Error(err, err.Error())
 		}
@@ -287,21 +459,129 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, request re
 		reqLogger.Info("certificate has been reissued.")
 		return reconcile.Result{}, nil
 	}
-	err = r.updateStatus(reqLogger, cr)
+
+	// The reissued secret triggers another reconcile, which syncs it
+	if err := r.syncCertificateSecret(reqLogger, cr, clusterDeploymentName, found); err != nil {
+		reqLogger.Error(err, "could not sync the certificate secret to the cluster")
+		return reconcile.Result{}, err
+	}
+
+	err = r.updateStatus(reqLogger, cr, leClient.GetEnvironment(), "")
 	if err != nil {
 		reqLogger.Error(err, "Failed to update CertificateRequest status")
 		// Set CertValidDuration to 0 if we couldn't update the status
-		localmetrics.UpdateCertValidDuration(r.Client, nil, time.Now(), cr.Namespace, cr.Namespace)
+		localmetrics.UpdateCertValidDuration(r.Client, nil, r.now(), cr.Namespace, cr.Namespace)
+	}
+
+	// The exports follow the certificate of the secret, so a reissued one is exported on the
+	// reconcile triggered by the secret update
+	if _, err := r.syncExports(ctx, reqLogger, request, cr, cd, found); err != nil {
+		reqLogger.Error(err, "could not sync the certificate to its exports")
+		return reconcile.Result{}, err
 	}
 	// reqLogger.Info("Skip reconcile as valid certificates exist", "Secret.Namespace", found.Namespace, "Secret.Name", found.Name)
-	return reconcile.Result{}, nil
+	return result, nil
+}
+
+// getClusterDeployment returns the ClusterDeployment owning cr, or nil when there is none. A
+// CertificateRequest that lost its owner references belongs to the only ClusterDeployment of its
+// namespace.
+func (r *CertificateRequestReconciler) getClusterDeployment(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (*hivev1.ClusterDeployment, error) {
+	// Just in case something else ever adds itself as an owner of the certificaterequest,
+	// loop through the owner references to find which one is the clusterdeployment
+	clusterDeploymentName := ""
+
+	for _, o := range cr.ObjectMeta.OwnerReferences {
+		if o.Kind == clusterDeploymentType {
+			clusterDeploymentName = o.Name
+		}
+	}
+
+	if clusterDeploymentName == "" {
+		// Assume there's only one clusterdeployment in a namespace and that it's the owner of this certificaterequest
+		// We have to assume this so that if/when a CertificateRequest loses its OwnerReferences, it can still reconcile
+		cdList := &hivev1.ClusterDeploymentList{}
+		err := r.Client.List(context.TODO(), cdList, client.InNamespace(cr.Namespace))
+		if err != nil {
+			reqLogger.Error(err, err.Error())
+			return nil, err
+		}
+		if len(cdList.Items) == 0 {
+			return nil, nil
+		}
+
+		// the listed ClusterDeployment is used as is, without fetching it again
+		return &cdList.Items[0], nil
+	}
+
+	cd := &hivev1.ClusterDeployment{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: clusterDeploymentName}, cd)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		reqLogger.Error(err, err.Error())
+		return nil, err
+	}
+	return cd, nil
+}
+
+// adoptClusterDeployment adds the owner reference of cd to cr when cr has none.
+func (r *CertificateRequestReconciler) adoptClusterDeployment(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment) error {
+	if len(cr.OwnerReferences) != 0 {
+		return nil
+	}
+
+	baseToPatch := client.MergeFrom(cr.DeepCopy())
+	missingOwnerReference := metav1.OwnerReference{
+		APIVersion:         fmt.Sprintf("%s/%s", hivev1.HiveAPIGroup, hivev1.HiveAPIVersion),
+		Kind:               "ClusterDeployment",
+		Name:               cd.Name,
+		UID:                cd.UID,
+		Controller:         boolPointer(true),
+		BlockOwnerDeletion: boolPointer(true),
+	}
+	cr.OwnerReferences = []metav1.OwnerReference{missingOwnerReference}
+
+	reqLogger.Info("adding OwnerReference to CertificateRequest", logging.ClusterKey, logging.ObjectName(cd.Namespace, missingOwnerReference.Name))
+	if err := r.Client.Patch(context.TODO(), cr, baseToPatch); err != nil {
+		reqLogger.Error(err, err.Error())
+		return err
+	}
+	return nil
+}
+
+// relocationStarted fetches cd again right before an issuance, and reports cr as relocating when an
+// outgoing relocation of cd started since the reconcile began. The issuance would otherwise race
+// the relocation of the cluster, which moves the CertificateRequest with its secret.
+func (r *CertificateRequestReconciler) relocationStarted(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment) (bool, error) {
+	if cd == nil {
+		return false, nil
+	}
+
+	current := &hivev1.ClusterDeployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cd.Namespace, Name: cd.Name}, current); err != nil {
+		reqLogger.Error(err, err.Error())
+		return false, err
+	}
+	if !utils.IsRelocating(current) {
+		return false, nil
+	}
+
+	reqLogger.Info("Not reconciling, clusterdeployment is relocating")
+	return true, r.reportRelocating(cr)
+}
+
+// IsCanary returns true if cr is the canary CertificateRequest of the operator.
+func IsCanary(cr *certmanv1alpha1.CertificateRequest) bool {
+	return cr.Labels[certmanv1alpha1.CanaryCertificateRequestLabel] == "true"
 }
 
 // newSecret returns secret assigned to the secret name that is passed as the
 // certificaterequest argument.
 func newSecret(cr *certmanv1alpha1.CertificateRequest) *corev1.Secret {
 	return &corev1.Secret{
-		Type: corev1.SecretTypeTLS,
+		Type: secretType(cr),
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      cr.Spec.CertificateSecret.Name,
 			Namespace: cr.Namespace,
@@ -322,12 +602,32 @@ func (r *CertificateRequestReconciler) getClient(reqLogger logr.Logger, cr *cert
 }
 
 // Helper function for Reconcile handles CertificateRequests with a deletion timestamp by
-// revoking the certificate and removing the finalizer if it exists.
+// removing the zone records of a deleted cluster and the exported certificates, revoking the
+// certificate and removing the finalizer if it exists.
 func (r *CertificateRequestReconciler) finalizeCertificateRequest(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (reconcile.Result, error) {
 	if utils.ContainsString(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
+		if err := r.deleteZoneRecords(reqLogger, cr); err != nil {
+			reqLogger.Error(err, "could not delete zone records")
+			localmetrics.UpdateFinalizerBlockedDeletion(certificateRequestType, cr.Namespace, cr.Name, finalizerBlockedZoneRecordDeletion, cr.DeletionTimestamp.Time, r.now())
+			return reconcile.Result{}, err
+		}
+
+		if err := r.deleteExports(reqLogger, cr); err != nil {
+			reqLogger.Error(err, "could not delete the exported certificates")
+			localmetrics.UpdateFinalizerBlockedDeletion(certificateRequestType, cr.Namespace, cr.Name, finalizerBlockedExportDeletion, cr.DeletionTimestamp.Time, r.now())
+			return reconcile.Result{}, err
+		}
+
+		// the pending authorizations of an issuance in progress would count against the account
+		// until its order expires
+		if cr.Status.OrderURL != "" {
+			r.abandonOrderOfIssuer(reqLogger, cr, abandonedOrderDeleted)
+		}
+
 		reqLogger.Info("revoking certificate and deleting secret")
 		if err := r.revokeCertificateAndDeleteSecret(reqLogger, cr); err != nil {
 			reqLogger.Error(err, err.Error())
+			localmetrics.UpdateFinalizerBlockedDeletion(certificateRequestType, cr.Namespace, cr.Name, finalizerBlockedRevocation, cr.DeletionTimestamp.Time, r.now())
 			return reconcile.Result{}, err
 		}
 
@@ -336,17 +636,24 @@ func (r *CertificateRequestReconciler) finalizeCertificateRequest(reqLogger logr
 		cr.ObjectMeta.Finalizers = utils.RemoveString(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
 		if err := r.Client.Patch(context.TODO(), cr, baseToPatch); err != nil {
 			reqLogger.Error(err, err.Error())
+			localmetrics.UpdateFinalizerBlockedDeletion(certificateRequestType, cr.Namespace, cr.Name, finalizerBlockedFinalizerRemoval, cr.DeletionTimestamp.Time, r.now())
 			return reconcile.Result{}, err
 		}
+		localmetrics.ClearFinalizerBlockedDeletion(certificateRequestType, cr.Namespace, cr.Name)
 	}
 
-	localmetrics.DecrementCertRequestsCounter()
+	localmetrics.ForgetCertRequest(cr.Namespace, cr.Name)
+	localmetrics.ForgetCertificateIssuance(cr.Namespace, cr.Name)
+	localmetrics.ClearCertificateSecretSize(cr.Namespace, cr.Name)
+	localmetrics.ClearCertificateUnhealthy(cr.Namespace, cr.Name)
+	localmetrics.SetCertificateRequestNotAuthorized(cr.Namespace, cr.Name, false)
+	localmetrics.SetCertificateRequestStalled(cr.Namespace, cr.Name, false)
 	reqLogger.Info("certificaterequest has been deleted")
 	return reconcile.Result{}, nil
 }
 
 // Helper function for Reconcile creates a Secret object containing a newly issued certificate.
-func (r *CertificateRequestReconciler) createCertificateSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, leClient leclient.LetsEncryptClientInterface) (reconcile.Result, error) {
+func (r *CertificateRequestReconciler) createCertificateSecret(ctx context.Context, reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment, leClient leclient.LetsEncryptClientInterface) (reconcile.Result, error) {
 	certificateSecret := newSecret(cr)
 
 	// Set CertificateRequest cr as the owner and controller
@@ -355,8 +662,16 @@ func (r *CertificateRequestReconciler) createCertificateSecret(reqLogger logr.Lo
 		return reconcile.Result{}, err
 	}
 
-	err := r.IssueCertificate(reqLogger, cr, certificateSecret, leClient)
+	err := r.IssueCertificate(ctx, reqLogger, cr, cd, certificateSecret, leClient)
+	if gerrors.Is(err, errZoneDelegationPending) {
+		// the ZoneDelegationPending condition reports the wait, it isn't a failed issuance
+		return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitZoneDelegation)}, nil
+	}
+	if gerrors.Is(err, errReconcileDeadline) {
+		return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitReconcileDeadline)}, nil
+	}
 	if err != nil {
+		r.recordACMEFailure(reqLogger, cr, err)
 		updateErr := r.updateStatusError(reqLogger, cr, err)
 		if updateErr != nil {
 			reqLogger.Error(updateErr, updateErr.Error())
@@ -366,7 +681,8 @@ func (r *CertificateRequestReconciler) createCertificateSecret(reqLogger logr.Lo
 	}
 
 	reqLogger.Info("creating secret with certificates")
-	localmetrics.AddCertificateIssuance("create")
+	localmetrics.AddCertificateIssuance(string(certmanv1alpha1.IssuanceTriggerCreate), string(leClient.GetEnvironment()))
+	r.recordAccountIssuance(reqLogger, cr, leClient)
 
 	err = r.Client.Create(context.TODO(), certificateSecret)
 	if err != nil {
@@ -382,9 +698,10 @@ func (r *CertificateRequestReconciler) createCertificateSecret(reqLogger logr.Lo
 			return reconcile.Result{}, err
 		}
 	}
+	r.setIssuanceStage(reqLogger, cr, certmanv1alpha1.IssuanceStageStored)
 
 	reqLogger.Info("updating certificate request status")
-	err = r.updateStatus(reqLogger, cr)
+	err = r.updateStatus(reqLogger, cr, leClient.GetEnvironment(), certmanv1alpha1.IssuanceTriggerCreate)
 	if err != nil {
 		reqLogger.Error(err, "could not update the status of the CertificateRequest")
 		return reconcile.Result{}, err
@@ -394,10 +711,20 @@ func (r *CertificateRequestReconciler) createCertificateSecret(reqLogger logr.Lo
 	return reconcile.Result{}, nil
 }
 
-// revokeCertificateAndDeleteSecret revokes certificate if it exists
+// revokeCertificateAndDeleteSecret revokes certificate if it exists, unless revocation on delete is
+// disabled for cr or by the operator configuration.
 func (r *CertificateRequestReconciler) revokeCertificateAndDeleteSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
 	//todo - actually delete secret when revoking
 
+	revoke := utils.RevokeOnDelete(r.Client)
+	if cr.Spec.RevokeOnDelete != nil {
+		revoke = *cr.Spec.RevokeOnDelete
+	}
+	if !revoke {
+		reqLogger.Info("revocation on delete is disabled, not revoking certificate")
+		return nil
+	}
+
 	exists, err := SecretExists(r.Client, cr.Spec.CertificateSecret.Name, cr.Namespace)
 	if err != nil {
 		return fmt.Errorf("error checking if secret exists: %w", err)
@@ -407,6 +734,11 @@ func (r *CertificateRequestReconciler) revokeCertificateAndDeleteSecret(reqLogge
 		return nil
 	}
 
+	if utils.GetFeatureGates(r.Client).DisableRevocation {
+		localmetrics.IncrementFeatureGateSkipCount(cTypes.DisableRevocation)
+		return errRevocationDisabled
+	}
+
 	error := r.RevokeCertificate(reqLogger, cr)
 	if error != nil {
 		// TODO: handle error from certificate missing
@@ -418,34 +750,48 @@ func (r *CertificateRequestReconciler) revokeCertificateAndDeleteSecret(reqLogge
 
 }
 
-// relocationBailOut checks to see if there's a cluster relocation in progress
-func relocationBailOut(k client.Client, nsn types.NamespacedName) (relocating bool, err error) {
-	relocating = false
-
-	cd := &hivev1.ClusterDeployment{}
-	err = k.Get(context.TODO(), nsn, cd)
-	if err != nil {
-		return
-	}
-
-	// bail out of the loop if there's an outgoing relocation annotation
-	for a, v := range cd.Annotations {
-		if a == hiveRelocationAnnotation && strings.Split(v, "/")[1] == hiveRelocationOutgoingValue {
-			relocating = true
-		}
+// reportRelocating sets the status of cr to tell it isn't reconciled while its ClusterDeployment
+// relocates. The status is only written when it changes, a long relocation would otherwise
+// rewrite it on every reconcile.
+func (r *CertificateRequestReconciler) reportRelocating(cr *certmanv1alpha1.CertificateRequest) error {
+	if cr.Status.Status == hiveRelocationCertificateRequstStatus {
+		return nil
 	}
-
-	return
+	cr.Status.Status = hiveRelocationCertificateRequstStatus
+	return r.Client.Status().Update(context.TODO(), cr)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *CertificateRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	workers := r.ReconcileWorkers
+	if workers <= 0 {
+		workers = DefaultReconcileWorkers
+	}
+	options := controller.Options{
+		MaxConcurrentReconciles: workers,
+		RateLimiter:             workqueue.NewItemExponentialFailureRateLimiter(1*time.Second, 30*time.Second),
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&certmanv1alpha1.CertificateRequest{}).
 		Owns(&corev1.Secret{}).
-		WithOptions(controller.Options{
-			MaxConcurrentReconciles: maxConcurrentReconciles,
-			RateLimiter:             workqueue.NewItemExponentialFailureRateLimiter(1*time.Second, 30*time.Second),
-		}).
-		Complete(r)
+		Owns(&hivev1.SyncSet{})
+
+	if r.Shard != nil {
+		needLeaderElection := false
+		options.NeedLeaderElection = &needLeaderElection
+		b = b.WatchesRawSource(&source.Channel{Source: r.Shard.Events}, &handler.EnqueueRequestForObject{}).
+			WithEventFilter(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return r.Shard.Owns(obj.GetNamespace())
+			}))
+	}
+
+	if r.IssuanceWorkers > 0 {
+		r.issuance = newIssuanceWorkers(r, r.IssuanceWorkers)
+		if err := mgr.Add(r.issuance); err != nil {
+			return err
+		}
+	}
+
+	return b.WithOptions(options).Complete(r)
 }
@@ -21,8 +21,10 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/go-logr/logr"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -30,6 +32,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/testutils"
 )
 
 func TestReconcile(t *testing.T) {
@@ -395,49 +399,277 @@ func TestReconcile(t *testing.T) {
 	}
 }
 
-func TestRelocationBailOut(t *testing.T) {
+func TestReconcileClusterDeploymentLookups(t *testing.T) {
+	orphan := certRequest.DeepCopy()
+	orphan.OwnerReferences = nil
+
 	tests := []struct {
-		Name           string
-		NamespacedName types.NamespacedName
-		KubeObjects    []runtime.Object
-		Expected       bool
-		ExpectErr      bool
+		Name          string
+		KubeObjects   []runtime.Object
+		ExpectedGets  int
+		ExpectedLists int
 	}{
 		{
-			Name:           "clusterdeployment is relocating",
-			NamespacedName: types.NamespacedName{Namespace: clusterDeploymentOutgoing.Namespace, Name: clusterDeploymentOutgoing.Name},
-			KubeObjects:    []runtime.Object{clusterDeploymentOutgoing},
-			Expected:       true,
-			ExpectErr:      false,
+			Name:         "clusterdeployment is relocating",
+			KubeObjects:  []runtime.Object{mockLESecret(), clusterDeploymentOutgoing.DeepCopy(), certRequest.DeepCopy(), validCertSecret.DeepCopy()},
+			ExpectedGets: 1,
 		},
 		{
-			Name:           "clusterdeployment is not relocating",
-			NamespacedName: types.NamespacedName{Namespace: clusterDeploymentComplete.Namespace, Name: clusterDeploymentComplete.Name},
-			KubeObjects:    []runtime.Object{clusterDeploymentComplete},
-			Expected:       false,
-			ExpectErr:      false,
+			Name:         "clusterdeployment is not relocating",
+			KubeObjects:  []runtime.Object{mockLESecret(), clusterDeploymentComplete.DeepCopy(), certRequest.DeepCopy(), validCertSecret.DeepCopy()},
+			ExpectedGets: 1,
 		},
 		{
-			Name:           "clusterdeployment is not found",
-			NamespacedName: types.NamespacedName{},
-			KubeObjects:    []runtime.Object{},
-			Expected:       false,
-			ExpectErr:      true,
+			Name:          "certificaterequest is adopted",
+			KubeObjects:   []runtime.Object{mockLESecret(), clusterDeploymentComplete.DeepCopy(), orphan, validCertSecret.DeepCopy()},
+			ExpectedLists: 1,
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.Name, func(t *testing.T) {
-			k := setUpTestClient(t, test.KubeObjects)
+			testClient := testutils.NewFaultClient(setUpTestClient(t, test.KubeObjects))
+			rcr := CertificateRequestReconciler{Client: testClient, ClientBuilder: setUpFakeAWSClient}
 
-			relocating, err := relocationBailOut(k, test.NamespacedName)
-			if (err != nil) && !test.ExpectErr {
-				t.Errorf("relocationBailOut(): test.ExpectErr: %v, got: %v", test.ExpectErr, err)
+			_, err := rcr.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
 			}
 
-			if relocating != test.Expected {
-				t.Errorf("relocationBailOut(): expected: %t got: %t", test.Expected, relocating)
+			// the certificate metrics look up a ClusterDeployment named after the domain of the
+			// certificate, only the lookups of the owner count
+			if gets := testClient.NamedObjectCalls(testutils.Get, &hivev1.ClusterDeployment{}, testHiveClusterDeploymentName); gets != test.ExpectedGets {
+				t.Errorf("expected %d ClusterDeployment gets, got %d", test.ExpectedGets, gets)
+			}
+			if lists := testClient.ObjectCalls(testutils.List, &hivev1.ClusterDeploymentList{}); lists != test.ExpectedLists {
+				t.Errorf("expected %d ClusterDeployment lists, got %d", test.ExpectedLists, lists)
 			}
 		})
 	}
 }
+
+// BenchmarkReconcile reports the ClusterDeployment lookups of a reconcile of a CertificateRequest
+// holding a valid certificate.
+func BenchmarkReconcile(b *testing.B) {
+	testClient := testutils.NewFaultClient(setUpTestClient(b, []runtime.Object{mockLESecret(), clusterDeploymentComplete.DeepCopy(), certRequest.DeepCopy(), validCertSecret.DeepCopy()}))
+	rcr := CertificateRequestReconciler{Client: testClient, ClientBuilder: setUpFakeAWSClient}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rcr.Reconcile(context.TODO(), request); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+	b.StopTimer()
+
+	lookups := testClient.NamedObjectCalls(testutils.Get, &hivev1.ClusterDeployment{}, testHiveClusterDeploymentName) + testClient.ObjectCalls(testutils.List, &hivev1.ClusterDeploymentList{})
+	b.ReportMetric(float64(lookups)/float64(b.N), "cd-lookups/op")
+	b.ReportMetric(float64(testClient.Calls(testutils.Get)+testClient.Calls(testutils.List))/float64(b.N), "reads/op")
+}
+
+func TestReconcilePoisonPill(t *testing.T) {
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}}
+
+	t.Run("panics are recorded until the certificaterequest is a poison pill", func(t *testing.T) {
+		testClient := setUpTestClient(t, []runtime.Object{certRequest.DeepCopy()})
+		rcr := CertificateRequestReconciler{
+			Client:        testClient,
+			ClientBuilder: setUpFakeAWSClient,
+		}
+
+		for i := 0; i < utils.MaxReconcilePanics; i++ {
+			if err := rcr.handleReconcilePanic(context.TODO(), request, "boom"); err == nil {
+				t.Errorf("handleReconcilePanic() expected an error")
+			}
+		}
+
+		actualCertificateRequest := &certmanv1alpha1.CertificateRequest{}
+		if err := testClient.Get(context.TODO(), request.NamespacedName, actualCertificateRequest); err != nil {
+			t.Fatalf("unexpected error getting certificate request: %s", err)
+		}
+		if !utils.IsPoisonPill(actualCertificateRequest) {
+			t.Errorf("expected certificaterequest to be marked as a poison pill, annotations: %v", actualCertificateRequest.Annotations)
+		}
+	})
+
+	t.Run("panics are not recorded when the certificaterequest can't be patched", func(t *testing.T) {
+		testClient := testutils.NewFaultClient(setUpTestClient(t, []runtime.Object{certRequest.DeepCopy()}),
+			testutils.Fault{Verb: testutils.Patch, Object: &certmanv1alpha1.CertificateRequest{}, Class: testutils.Conflict})
+		rcr := CertificateRequestReconciler{
+			Client:        testClient,
+			ClientBuilder: setUpFakeAWSClient,
+		}
+
+		if err := rcr.handleReconcilePanic(context.TODO(), request, "boom"); err == nil {
+			t.Errorf("handleReconcilePanic() expected an error")
+		}
+
+		actualCertificateRequest := &certmanv1alpha1.CertificateRequest{}
+		if err := testClient.Get(context.TODO(), request.NamespacedName, actualCertificateRequest); err != nil {
+			t.Fatalf("unexpected error getting certificate request: %s", err)
+		}
+		if _, ok := actualCertificateRequest.Annotations[utils.ReconcilePanicCountAnnotation]; ok {
+			t.Errorf("expected the panic not to be recorded, annotations: %v", actualCertificateRequest.Annotations)
+		}
+	})
+
+	t.Run("a successful reconcile clears the recorded panics", func(t *testing.T) {
+		panicked := certRequest.DeepCopy()
+		panicked.Annotations = map[string]string{utils.ReconcilePanicCountAnnotation: "2"}
+		testClient := setUpTestClient(t, []runtime.Object{mockLESecret(), clusterDeploymentComplete.DeepCopy(), panicked, validCertSecret.DeepCopy()})
+		rcr := CertificateRequestReconciler{
+			Client:        testClient,
+			ClientBuilder: setUpFakeAWSClient,
+		}
+
+		if _, err := rcr.Reconcile(context.TODO(), request); err != nil {
+			t.Fatalf("Reconcile() unexpected error: %s", err)
+		}
+
+		actualCertificateRequest := &certmanv1alpha1.CertificateRequest{}
+		if err := testClient.Get(context.TODO(), request.NamespacedName, actualCertificateRequest); err != nil {
+			t.Fatalf("unexpected error getting certificate request: %s", err)
+		}
+		if _, ok := actualCertificateRequest.Annotations[utils.ReconcilePanicCountAnnotation]; ok {
+			t.Errorf("expected the recorded panics to be cleared, annotations: %v", actualCertificateRequest.Annotations)
+		}
+	})
+
+	t.Run("poison pill certificaterequests are not reconciled", func(t *testing.T) {
+		poisonedCertRequest := certRequest.DeepCopy()
+		poisonedCertRequest.Annotations = map[string]string{utils.PoisonPillAnnotation: "true"}
+		testClient := setUpTestClient(t, []runtime.Object{poisonedCertRequest})
+		rcr := CertificateRequestReconciler{
+			Client:        testClient,
+			ClientBuilder: setUpFakeAWSClient,
+		}
+
+		if _, err := rcr.Reconcile(context.TODO(), request); err != nil {
+			t.Errorf("Reconcile() unexpected error: %s", err)
+		}
+
+		actualCertificateRequest := &certmanv1alpha1.CertificateRequest{}
+		if err := testClient.Get(context.TODO(), request.NamespacedName, actualCertificateRequest); err != nil {
+			t.Fatalf("unexpected error getting certificate request: %s", err)
+		}
+		if len(actualCertificateRequest.Finalizers) != 0 {
+			t.Errorf("expected poison pill certificaterequest to be left untouched, finalizers: %v", actualCertificateRequest.Finalizers)
+		}
+	})
+}
+
+func TestReconcileAPIErrors(t *testing.T) {
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}}
+
+	tests := []struct {
+		name     string
+		fault    testutils.Fault
+		expected func(error) bool
+	}{
+		{
+			name:     "timeout getting the certificaterequest",
+			fault:    testutils.Fault{Verb: testutils.Get, Object: &certmanv1alpha1.CertificateRequest{}, Class: testutils.Timeout},
+			expected: errors.IsTimeout,
+		},
+		{
+			name:     "apiserver error getting the certificaterequest",
+			fault:    testutils.Fault{Verb: testutils.Get, Object: &certmanv1alpha1.CertificateRequest{}},
+			expected: errors.IsInternalError,
+		},
+		{
+			name:     "conflict adding the finalizer",
+			fault:    testutils.Fault{Verb: testutils.Patch, Object: &certmanv1alpha1.CertificateRequest{}, Class: testutils.Conflict},
+			expected: errors.IsConflict,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testClient := testutils.NewFaultClient(setUpTestClient(t, []runtime.Object{certRequest.DeepCopy()}), test.fault)
+			rcr := CertificateRequestReconciler{
+				Client:        testClient,
+				ClientBuilder: setUpFakeAWSClient,
+			}
+
+			_, err := rcr.Reconcile(context.TODO(), request)
+			if !test.expected(err) {
+				t.Errorf("Reconcile() expected the injected error to be returned, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRelocationStarted(t *testing.T) {
+	key := types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}
+
+	for _, test := range []struct {
+		name             string
+		current          *hivev1.ClusterDeployment
+		expectRelocating bool
+	}{
+		{name: "clusterdeployment still not relocating", current: clusterDeploymentComplete},
+		{name: "relocation started during the reconcile", current: clusterDeploymentOutgoing, expectRelocating: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testClient := setUpTestClient(t, []runtime.Object{certRequest.DeepCopy(), test.current.DeepCopy()})
+			rcr := CertificateRequestReconciler{Client: testClient}
+
+			cr := &certmanv1alpha1.CertificateRequest{}
+			if err := testClient.Get(context.TODO(), key, cr); err != nil {
+				t.Fatalf("unexpected error getting certificate request: %s", err)
+			}
+
+			// the copy fetched at the start of the reconcile wasn't relocating yet
+			relocating, err := rcr.relocationStarted(logr.Discard(), cr, clusterDeploymentComplete.DeepCopy())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if relocating != test.expectRelocating {
+				t.Errorf("expected relocating %t, got %t", test.expectRelocating, relocating)
+			}
+
+			actual := &certmanv1alpha1.CertificateRequest{}
+			if err := testClient.Get(context.TODO(), key, actual); err != nil {
+				t.Fatalf("unexpected error getting certificate request: %s", err)
+			}
+			if reported := actual.Status.Status == hiveRelocationCertificateRequstStatus; reported != test.expectRelocating {
+				t.Errorf("expected the relocation reported %t, got status %q", test.expectRelocating, actual.Status.Status)
+			}
+		})
+	}
+}
+
+func TestReportRelocating(t *testing.T) {
+	testClient := setUpTestClient(t, []runtime.Object{certRequest.DeepCopy()})
+	rcr := CertificateRequestReconciler{Client: testClient}
+
+	key := types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}
+	cr := &certmanv1alpha1.CertificateRequest{}
+	if err := testClient.Get(context.TODO(), key, cr); err != nil {
+		t.Fatalf("unexpected error getting certificate request: %s", err)
+	}
+	if err := rcr.reportRelocating(cr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	relocating := &certmanv1alpha1.CertificateRequest{}
+	if err := testClient.Get(context.TODO(), key, relocating); err != nil {
+		t.Fatalf("unexpected error getting certificate request: %s", err)
+	}
+	if relocating.Status.Status != hiveRelocationCertificateRequstStatus {
+		t.Errorf("expected status %q, got %q", hiveRelocationCertificateRequstStatus, relocating.Status.Status)
+	}
+
+	// a second reconcile during the relocation doesn't write the status again
+	if err := rcr.reportRelocating(relocating); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	unchanged := &certmanv1alpha1.CertificateRequest{}
+	if err := testClient.Get(context.TODO(), key, unchanged); err != nil {
+		t.Fatalf("unexpected error getting certificate request: %s", err)
+	}
+	if unchanged.ResourceVersion != relocating.ResourceVersion {
+		t.Errorf("expected resource version %s to be unchanged, got %s", relocating.ResourceVersion, unchanged.ResourceVersion)
+	}
+}
@@ -25,8 +25,10 @@ const (
 	dnsServerRequestTimeout           = 60
 	maxAttemptsForDnsPropagationCheck = 10  // Try 10 times (5 minutes total)
 	waitTimePeriodDnsPropagationCheck = 30  // Wait 30 seconds between checks
+	waitTimePeriodChallengeValidation = 5   // Wait 5 seconds between checks of a challenge being validated
 	maxNegativeCacheTTL               = 600 // Sleep no more than 10 minutes
 	reissueCertificateBeforeDays      = 45  // This helps us avoid getting email notifications from Let's Encrypt.
+	reissueBeforeLifetimeDivisor      = 3   // An invalid reissueBeforeDays is replaced by a third of the certificate lifetime.
 	rSAKeyBitSize                     = 2048
 
 	// From golang.org/x/net/dns/dnsmessage
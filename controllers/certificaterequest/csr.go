@@ -0,0 +1,118 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// newCertificateSigningRequest generates a private key and a CSR for domains signed with it. The
+// private key is returned PEM encoded.
+func newCertificateSigningRequest(domains []string) (*x509.CertificateRequest, []byte, error) {
+	certKey, err := rsa.GenerateKey(rand.Reader, rSAKeyBitSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tpl := &x509.CertificateRequest{
+		SignatureAlgorithm: x509.SHA256WithRSA,
+		PublicKeyAlgorithm: x509.RSA,
+		PublicKey:          certKey.Public(),
+		Subject:            pkix.Name{CommonName: domains[0]},
+		DNSNames:           domains,
+	}
+
+	csrDer, err := x509.CreateCertificateRequest(rand.Reader, tpl, certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrDer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(certKey),
+	})
+
+	return csr, key, nil
+}
+
+// parseSuppliedCSR returns the CSR of cr, or nil when it has none. The CSR must be signed by its
+// key and be for the DnsNames of cr exactly, as Let's Encrypt only finalizes an order with a CSR
+// for the names of the order.
+func parseSuppliedCSR(cr *certmanv1alpha1.CertificateRequest) (*x509.CertificateRequest, error) {
+	if cr.Spec.CSR == "" {
+		return nil, nil
+	}
+
+	block, _ := pem.Decode([]byte(cr.Spec.CSR))
+	if block == nil || (block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST") {
+		return nil, fmt.Errorf("csr is not a PEM encoded certificate signing request")
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse csr: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("csr signature is invalid: %w", err)
+	}
+
+	names := csrNames(csr)
+	expected := normalizedNames(cr.Spec.DnsNames)
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		return nil, fmt.Errorf("csr is for %v, expected the dnsNames %v", names, expected)
+	}
+
+	return csr, nil
+}
+
+// csrNames returns the sorted DNS names of csr, including its common name.
+func csrNames(csr *x509.CertificateRequest) []string {
+	names := append([]string{}, csr.DNSNames...)
+	if csr.Subject.CommonName != "" {
+		names = append(names, csr.Subject.CommonName)
+	}
+	return normalizedNames(names)
+}
+
+// normalizedNames returns names in lower case, sorted and without duplicates.
+func normalizedNames(names []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+
+	"github.com/eggsampler/acme"
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	acmemock "github.com/openshift/certman-operator/pkg/acmeclient/mock"
+	"github.com/openshift/certman-operator/pkg/leclient"
+)
+
+// testCSR returns a PEM encoded CSR for commonName and dnsNames signed with a new key.
+func testCSR(t *testing.T, commonName string, dnsNames ...string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: commonName},
+		DNSNames: dnsNames,
+	}, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+func TestParseSuppliedCSR(t *testing.T) {
+	testCases := []struct {
+		Name        string
+		CSR         string
+		DnsNames    []string
+		ExpectCSR   bool
+		ExpectError bool
+	}{
+		{
+			Name:     "no csr",
+			DnsNames: []string{"api.example.com"},
+		},
+		{
+			Name:      "csr for the dns names",
+			CSR:       testCSR(t, "api.example.com", "api.example.com", "*.apps.example.com"),
+			DnsNames:  []string{"*.apps.example.com", "API.example.com"},
+			ExpectCSR: true,
+		},
+		{
+			Name:        "csr for other names",
+			CSR:         testCSR(t, "api.example.com", "api.example.com"),
+			DnsNames:    []string{"api.example.com", "*.apps.example.com"},
+			ExpectError: true,
+		},
+		{
+			Name:        "common name outside of the dns names",
+			CSR:         testCSR(t, "other.example.com", "api.example.com"),
+			DnsNames:    []string{"api.example.com"},
+			ExpectError: true,
+		},
+		{
+			Name:        "not a csr",
+			CSR:         "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+			DnsNames:    []string{"api.example.com"},
+			ExpectError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			cr := &certmanv1alpha1.CertificateRequest{
+				Spec: certmanv1alpha1.CertificateRequestSpec{CSR: test.CSR, DnsNames: test.DnsNames},
+			}
+
+			csr, err := parseSuppliedCSR(cr)
+			if test.ExpectError != (err != nil) {
+				t.Fatalf("expected error %t, got %v", test.ExpectError, err)
+			}
+			if test.ExpectCSR != (csr != nil) {
+				t.Errorf("expected a csr %t, got %v", test.ExpectCSR, csr)
+			}
+		})
+	}
+}
+
+func TestIssueCertificateWithSuppliedCSR(t *testing.T) {
+	testZoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-zone",
+			Namespace: testHiveNamespace,
+		},
+		Status: hivev1.DNSZoneStatus{
+			AWS: &hivev1.AWSDNSZoneStatus{
+				ZoneID: &testZoneID,
+			},
+		},
+	}
+	testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret, dnsZone})
+
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}, cr)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// the status updates of the issuance reload cr, the supplied csr has to be stored
+	cr.Spec.CSR = testCSR(t, cr.Spec.DnsNames[0], cr.Spec.DnsNames...)
+	if err := testClient.Update(context.TODO(), cr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	csr := cr.Spec.CSR
+
+	acmeClient := acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{
+		Available: true,
+		NewOrderResult: acme.Order{
+			Authorizations: []string{"proto://a.fake.url"},
+		},
+		FetchAuthorizationResult: acme.Authorization{
+			Identifier: acme.Identifier{
+				Value: "api.gibberish.goes.here",
+			},
+		},
+	})
+
+	rcr := CertificateRequestReconciler{
+		Client:        testClient,
+		ClientBuilder: setUpFakeAWSClient,
+	}
+	certificateSecret := &v1.Secret{Type: v1.SecretTypeTLS}
+	err = rcr.IssueCertificate(context.TODO(), logr.Discard(), cr, nil, certificateSecret, &leclient.LetsEncryptClient{Client: acmeClient})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	block, _ := pem.Decode([]byte(csr))
+	if acmeClient.FinalizeOrderCSR == nil || string(acmeClient.FinalizeOrderCSR.Raw) != string(block.Bytes) {
+		t.Errorf("expected the order to be finalized with the supplied csr")
+	}
+	if len(certificateSecret.Data[v1.TLSCertKey]) == 0 {
+		t.Errorf("expected the certificate chain to be stored")
+	}
+	if len(certificateSecret.Data[v1.TLSPrivateKeyKey]) != 0 {
+		t.Errorf("expected no private key to be stored, got %q", certificateSecret.Data[v1.TLSPrivateKeyKey])
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/clients/nameserver"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+// the nameserver queries of DebugChallenges, replaced in tests
+var (
+	authoritativeNameservers = nameserver.AuthoritativeNameservers
+	lookupTXT                = nameserver.LookupTXT
+)
+
+// challengeDebugger writes the result of each step checked by DebugChallenges.
+type challengeDebugger struct {
+	w      io.Writer
+	failed bool
+}
+
+// report writes the result of step for subject, which failed when err is set.
+func (d *challengeDebugger) report(subject string, step string, err error, detail string) {
+	result := "ok"
+	if err != nil {
+		d.failed = true
+		result = "FAILED"
+		detail = err.Error()
+	}
+	fmt.Fprintf(d.w, "%-8s %s: %s: %s\n", result, subject, step, detail)
+}
+
+// DebugChallenges goes through the DNS steps of the challenges of the CertificateRequest key and
+// writes the result of each to w: the write access test of the DNS client, and for each domain the
+// hosted zone the challenge record would be written to, the nameservers the public DNS delegates
+// the record to, and the TXT records they serve for it. Nothing is changed: no ACME order is created,
+// the CertificateRequest is left alone, and the write test removes its record as issuance does.
+// false is returned when a step failed.
+func (r *CertificateRequestReconciler) DebugChallenges(reqLogger logr.Logger, w io.Writer, key types.NamespacedName) (bool, error) {
+	cr := &certmanv1alpha1.CertificateRequest{}
+	if err := r.Client.Get(context.TODO(), key, cr); err != nil {
+		return false, err
+	}
+
+	d := &challengeDebugger{w: w}
+
+	dnsClient, err := r.getClient(reqLogger, cr)
+	if err != nil {
+		d.report(key.String(), "dns client", err, "")
+		return false, nil
+	}
+	d.report(key.String(), "dns client", nil, fmt.Sprintf("using %s", dnsClient.GetDNSName()))
+
+	writable, err := dnsClient.ValidateDNSWriteAccess(reqLogger, cr)
+	if err == nil && !writable {
+		err = fmt.Errorf("no public hosted zone named %s that can be written to", cr.Spec.ACMEDNSDomain)
+	}
+	d.report(key.String(), "write test", err, fmt.Sprintf("wrote and deleted a test record in %s", cr.Spec.ACMEDNSDomain))
+
+	// The canary has no hive DNSZone, its zone is only found by the authoritative zone lookup
+	dnsZone := ""
+	if !IsCanary(cr) {
+		dnsZone, err = r.FindZoneIDForChallenge(cr.Namespace, dnsClient)
+		d.report(key.String(), "cluster zone", err, dnsZone)
+	}
+
+	for _, domain := range cr.Spec.DnsNames {
+		fqdn := fmt.Sprintf("%s.%s", cTypes.AcmeChallengeSubDomain, strings.TrimPrefix(domain, "*."))
+
+		zone, err := dnsClient.GetAuthoritativeZone(reqLogger, fqdn, cr, dnsZone)
+		if err == nil && zone == "" {
+			err = fmt.Errorf("no hosted zone found for %s", fqdn)
+		}
+		d.report(domain, "hosted zone", err, fmt.Sprintf("%s would be written to %s", fqdn, zone))
+
+		delegatedZone, nameservers, err := authoritativeNameservers(fqdn)
+		d.report(domain, "nameservers", err, fmt.Sprintf("%s is delegated to %s", delegatedZone, strings.Join(nameservers, ", ")))
+		if err != nil {
+			continue
+		}
+
+		for _, ns := range nameservers {
+			values, err := lookupTXT(ns, fqdn)
+			detail := "no TXT records"
+			if len(values) > 0 {
+				detail = fmt.Sprintf("TXT %s", strings.Join(values, ", "))
+			}
+			d.report(domain, fmt.Sprintf("%s at %s", fqdn, ns), err, detail)
+		}
+	}
+
+	return !d.failed, nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestDebugChallenges(t *testing.T) {
+	zoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testHiveNamespace, Name: "dnszone"},
+		Status:     hivev1.DNSZoneStatus{AWS: &hivev1.AWSDNSZoneStatus{ZoneID: &zoneID}},
+	}
+
+	tests := []struct {
+		name          string
+		txtErr        error
+		expectedOK    bool
+		expectedLines []string
+	}{
+		{
+			name:       "all steps pass",
+			expectedOK: true,
+			expectedLines: []string{
+				"ok       uhc-doesntexist-123456/clustername-1313-primary-cert-bundle: write test",
+				"ok       api.gibberish.goes.here: hosted zone: _acme-challenge.api.gibberish.goes.here would be written to Z0123456789",
+				"ok       api.gibberish.goes.here: nameservers: gibberish.goes.here. is delegated to ns1.example.com., ns2.example.com.",
+				"ok       api.gibberish.goes.here: _acme-challenge.api.gibberish.goes.here at ns1.example.com.: TXT stale-token",
+			},
+		},
+		{
+			name:   "nameserver query fails",
+			txtErr: errors.New("i/o timeout"),
+			expectedLines: []string{
+				"FAILED   api.gibberish.goes.here: _acme-challenge.api.gibberish.goes.here at ns2.example.com.: i/o timeout",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(ns func(string) (string, []string, error), txt func(string, string) ([]string, error)) {
+				authoritativeNameservers, lookupTXT = ns, txt
+			}(authoritativeNameservers, lookupTXT)
+			authoritativeNameservers = func(fqdn string) (string, []string, error) {
+				return "gibberish.goes.here.", []string{"ns1.example.com.", "ns2.example.com."}, nil
+			}
+			lookupTXT = func(nameserver string, fqdn string) ([]string, error) {
+				if nameserver == "ns2.example.com." && test.txtErr != nil {
+					return nil, test.txtErr
+				}
+				return []string{"stale-token"}, nil
+			}
+
+			testClient := setUpTestClient(t, []runtime.Object{certRequest, dnsZone})
+			rcr := CertificateRequestReconciler{
+				Client:        testClient,
+				ClientBuilder: setUpFakeAWSClient,
+			}
+
+			out := &bytes.Buffer{}
+			ok, err := rcr.DebugChallenges(logr.Discard(), out, types.NamespacedName{Namespace: certRequest.Namespace, Name: certRequest.Name})
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedOK, ok)
+			for _, line := range test.expectedLines {
+				assert.Contains(t, out.String(), line)
+			}
+
+			// the CertificateRequest is left alone
+			actual := &certmanv1alpha1.CertificateRequest{}
+			assert.NoError(t, testClient.Get(context.TODO(), types.NamespacedName{Namespace: certRequest.Namespace, Name: certRequest.Name}, actual))
+			assert.Equal(t, certRequest.Status, actual.Status)
+		})
+	}
+}
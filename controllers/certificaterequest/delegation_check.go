@@ -0,0 +1,159 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	cClient "github.com/openshift/certman-operator/pkg/clients"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// errDelegationMismatch is returned when the base domain of a CertificateRequest is delegated to
+// nameservers other than the ones of its cloud provider zone
+var errDelegationMismatch = errors.New("base domain is not delegated to its DNS zone")
+
+// checkDelegation fails when the StrictDelegationCheck of the operator is enabled and the public DNS
+// delegates the base domain of cr to nameservers other than the ones of its cloud provider zone. The
+// challenge records written to that zone would never be seen by Let's Encrypt, so issuance stops
+// before creating an order. The DelegationMismatch condition of cr and the delegation mismatch metric
+// report the result.
+func (r *CertificateRequestReconciler) checkDelegation(reqLogger logr.Logger, dnsClient cClient.Client, cr *certmanv1alpha1.CertificateRequest) error {
+	if !utils.StrictDelegationCheck(r.Client) {
+		clearDelegationMismatch(cr)
+		return nil
+	}
+
+	// The canary has no hive DNSZone, its zone is looked up by name
+	dnsZone := ""
+	if !IsCanary(cr) {
+		var err error
+		dnsZone, err = r.FindZoneIDForChallenge(cr.Namespace, dnsClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	zoneNameservers, err := dnsClient.GetZoneNameservers(reqLogger, cr, dnsZone)
+	if err != nil {
+		return err
+	}
+	if len(zoneNameservers) == 0 {
+		reqLogger.Info(fmt.Sprintf("not checking the delegation of %v: its %v zone has no nameservers", cr.Spec.ACMEDNSDomain, dnsClient.GetDNSName()))
+		clearDelegationMismatch(cr)
+		return nil
+	}
+
+	delegatedZone, publicNameservers, err := authoritativeNameservers(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		return fmt.Errorf("could not look up the delegation of %v: %w", cr.Spec.ACMEDNSDomain, err)
+	}
+
+	unexpected := nameserversOutside(publicNameservers, zoneNameservers)
+	if len(unexpected) == 0 {
+		reqLogger.Info(fmt.Sprintf("%v is delegated to its %v zone", cr.Spec.ACMEDNSDomain, dnsClient.GetDNSName()))
+		clearDelegationMismatch(cr)
+		return nil
+	}
+
+	message := fmt.Sprintf("%v is delegated to %v by %v, which are not nameservers of its %v zone (%v)",
+		cr.Spec.ACMEDNSDomain, strings.Join(unexpected, ", "), delegatedZone, dnsClient.GetDNSName(), strings.Join(zoneNameservers, ", "))
+	localmetrics.SetDelegationMismatch(cr.Namespace, cr.Name, true)
+	if setDelegationMismatchCondition(cr, message) {
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			reqLogger.Error(err, "could not set the delegation mismatch condition")
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errDelegationMismatch, message)
+}
+
+// nameserversOutside returns the nameservers that aren't one of zoneNameservers.
+func nameserversOutside(nameservers []string, zoneNameservers []string) []string {
+	normalize := func(ns string) string {
+		return strings.ToLower(strings.TrimSuffix(ns, "."))
+	}
+
+	known := map[string]bool{}
+	for _, ns := range zoneNameservers {
+		known[normalize(ns)] = true
+	}
+
+	outside := []string{}
+	for _, ns := range nameservers {
+		if !known[normalize(ns)] {
+			outside = append(outside, ns)
+		}
+	}
+	return outside
+}
+
+// setDelegationMismatchCondition sets the CertificateRequestDelegationMismatch condition of cr with
+// message. It returns true when the conditions of cr changed.
+func setDelegationMismatchCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestDelegationMismatch {
+			index = i
+			break
+		}
+	}
+
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "NameserversNotInZone"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestDelegationMismatch,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// clearDelegationMismatch removes the CertificateRequestDelegationMismatch condition of cr, which is
+// stored with the rest of the status once the certificate is issued, and its metric.
+func clearDelegationMismatch(cr *certmanv1alpha1.CertificateRequest) {
+	localmetrics.SetDelegationMismatch(cr.Namespace, cr.Name, false)
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestDelegationMismatch {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			return
+		}
+	}
+}
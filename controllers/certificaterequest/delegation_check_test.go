@@ -0,0 +1,117 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestCheckDelegation(t *testing.T) {
+	zoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testHiveNamespace, Name: "dnszone"},
+		Status:     hivev1.DNSZoneStatus{AWS: &hivev1.AWSDNSZoneStatus{ZoneID: &zoneID}},
+	}
+
+	tests := []struct {
+		name              string
+		strict            string
+		publicNameservers []string
+		expectMismatch    bool
+	}{
+		{
+			name:              "delegated to the provider zone",
+			strict:            "true",
+			publicNameservers: []string{"NS-1.awsdns-01.com", "ns-2.awsdns-02.net."},
+		},
+		{
+			name:              "delegated elsewhere",
+			strict:            "true",
+			publicNameservers: []string{"ns1.customer-dns.example.", "ns2.customer-dns.example."},
+			expectMismatch:    true,
+		},
+		{
+			name:              "check disabled",
+			strict:            "false",
+			publicNameservers: []string{"ns1.customer-dns.example.", "ns2.customer-dns.example."},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(ns func(string) (string, []string, error)) {
+				authoritativeNameservers = ns
+			}(authoritativeNameservers)
+			authoritativeNameservers = func(fqdn string) (string, []string, error) {
+				return testHiveACMEDomain + ".", test.publicNameservers, nil
+			}
+
+			operatorConfigMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.OperatorName,
+					Namespace: config.OperatorNamespace,
+				},
+				Data: map[string]string{
+					cTypes.StrictDelegationCheck: test.strict,
+				},
+			}
+			testClient := setUpTestClient(t, []runtime.Object{certRequest, dnsZone, operatorConfigMap})
+			rcr := CertificateRequestReconciler{Client: testClient}
+
+			cr := &certmanv1alpha1.CertificateRequest{}
+			key := types.NamespacedName{Namespace: certRequest.Namespace, Name: certRequest.Name}
+			assert.NoError(t, testClient.Get(context.TODO(), key, cr))
+
+			err := rcr.checkDelegation(logr.Discard(), FakeAWSClient{}, cr)
+
+			actual := &certmanv1alpha1.CertificateRequest{}
+			assert.NoError(t, testClient.Get(context.TODO(), key, actual))
+			metric := testutil.ToFloat64(localmetrics.MetricDelegationMismatch.WithLabelValues(cr.Namespace, cr.Name))
+			if !test.expectMismatch {
+				assert.NoError(t, err)
+				assert.Empty(t, actual.Status.Conditions)
+				assert.Equal(t, 0.0, metric)
+				return
+			}
+
+			assert.True(t, errors.Is(err, errDelegationMismatch), "expected a delegation mismatch, got %v", err)
+			assert.Equal(t, 1.0, metric)
+			if assert.Len(t, actual.Status.Conditions, 1) {
+				condition := actual.Status.Conditions[0]
+				assert.Equal(t, certmanv1alpha1.CertificateRequestDelegationMismatch, condition.Type)
+				assert.Equal(t, v1.ConditionTrue, condition.Status)
+				assert.Contains(t, *condition.Message, "ns1.customer-dns.example.")
+			}
+		})
+	}
+}
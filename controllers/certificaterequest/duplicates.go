@@ -0,0 +1,246 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// duplicateEventReason is the reason of the event emitted when a CertificateRequest is found to
+// duplicate another one
+const duplicateEventReason = "Duplicate"
+
+// checkDuplicate returns the canonical CertificateRequest when another CertificateRequest of the
+// namespace of cr requests the same DNS names from the same ACME issuer, so a single certificate is
+// issued for them rather than one for each, or nil when cr issues its own. The Duplicate condition
+// of cr names the canonical CertificateRequest, and is removed once cr is the canonical one, such
+// as after the other is deleted.
+func (r *CertificateRequestReconciler) checkDuplicate(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (*certmanv1alpha1.CertificateRequest, error) {
+	canonical, err := r.canonicalCertificateRequest(cr)
+	if err != nil {
+		reqLogger.Error(err, "could not look for CertificateRequests duplicating this one")
+		return nil, err
+	}
+
+	wasDuplicate := false
+	for _, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestDuplicate {
+			wasDuplicate = true
+		}
+	}
+
+	message := ""
+	changed := false
+	if canonical == nil {
+		changed = clearDuplicate(cr)
+	} else {
+		message = fmt.Sprintf("CertificateRequest %s requests the same DNS names and is issued instead", canonical.Name)
+		reqLogger.Info(fmt.Sprintf("not issuing the certificate: %s", message))
+		changed = setDuplicateCondition(cr, message)
+	}
+	if !changed {
+		return canonical, nil
+	}
+
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		reqLogger.Error(err, "could not update the Duplicate condition")
+		return nil, err
+	}
+	if canonical != nil && !wasDuplicate && r.Recorder != nil {
+		r.Recorder.Event(cr, corev1.EventTypeWarning, duplicateEventReason, message)
+	}
+	return canonical, nil
+}
+
+// copyCanonicalCertificate copies the certificate and private key of the secret of canonical into
+// the secret of cr, its duplicate, creating the secret when cr has none yet. A duplicate isn't
+// issued, this is how it gets the first certificate and the renewals of canonical. Nothing is
+// copied until canonical has its certificate.
+func (r *CertificateRequestReconciler) copyCanonicalCertificate(reqLogger logr.Logger, cr, canonical *certmanv1alpha1.CertificateRequest) error {
+	if canonical.Spec.CertificateSecret.Name == cr.Spec.CertificateSecret.Name {
+		return nil
+	}
+
+	canonicalSecret := &corev1.Secret{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: canonical.Namespace, Name: canonical.Spec.CertificateSecret.Name}, canonicalSecret)
+	if errors.IsNotFound(err) || (err == nil && len(canonicalSecret.Data[corev1.TLSCertKey]) == 0) {
+		reqLogger.Info(fmt.Sprintf("not copying the certificate of %s: it has none yet", canonical.Name))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: cr.Spec.CertificateSecret.Name}, secret)
+	if errors.IsNotFound(err) {
+		secret = newSecret(cr)
+		if err := controllerutil.SetControllerReference(cr, secret, r.Scheme); err != nil {
+			return err
+		}
+		secret.Data = map[string][]byte{
+			corev1.TLSCertKey:       canonicalSecret.Data[corev1.TLSCertKey],
+			corev1.TLSPrivateKeyKey: canonicalSecret.Data[corev1.TLSPrivateKeyKey],
+		}
+		reqLogger.Info(fmt.Sprintf("creating the certificate secret with the certificate of %s", canonical.Name))
+		return r.Client.Create(context.TODO(), secret)
+	}
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(secret.Data[corev1.TLSCertKey], canonicalSecret.Data[corev1.TLSCertKey]) &&
+		bytes.Equal(secret.Data[corev1.TLSPrivateKeyKey], canonicalSecret.Data[corev1.TLSPrivateKeyKey]) {
+		return nil
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[corev1.TLSCertKey] = canonicalSecret.Data[corev1.TLSCertKey]
+	secret.Data[corev1.TLSPrivateKeyKey] = canonicalSecret.Data[corev1.TLSPrivateKeyKey]
+	reqLogger.Info(fmt.Sprintf("copying the certificate of %s to the certificate secret", canonical.Name))
+	return r.Client.Update(context.TODO(), secret)
+}
+
+// canonicalCertificateRequest returns the CertificateRequest issuing the certificate for the DNS
+// names of cr when other CertificateRequests of its namespace request the same from the same
+// issuer, or nil when cr issues it. The oldest one is canonical, the first by name among those
+// created at the same time, so the choice doesn't change as the certificates are issued and
+// renewed.
+func (r *CertificateRequestReconciler) canonicalCertificateRequest(cr *certmanv1alpha1.CertificateRequest) (*certmanv1alpha1.CertificateRequest, error) {
+	names := dnsNamesKey(cr.Spec.DnsNames)
+	if names == "" {
+		return nil, nil
+	}
+
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := r.Client.List(context.TODO(), crList, client.InNamespace(cr.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var canonical *certmanv1alpha1.CertificateRequest
+	for i := range crList.Items {
+		other := &crList.Items[i]
+		if other.DeletionTimestamp != nil || dnsNamesKey(other.Spec.DnsNames) != names {
+			continue
+		}
+		if !sameIssuer(other.Spec.IssuerRef, cr.Spec.IssuerRef) {
+			continue
+		}
+		if canonical == nil || createdBefore(other, canonical) {
+			canonical = other
+		}
+	}
+	if canonical == nil || canonical.Name == cr.Name {
+		return nil, nil
+	}
+	return canonical, nil
+}
+
+// dnsNamesKey returns the DNS names, lowercased and sorted without duplicates or trailing dots, so
+// the same set of names gives the same key whatever its order.
+func dnsNamesKey(dnsNames []string) string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, name := range dnsNames {
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// sameIssuer returns true when a and b are the same ACME issuer, nil being the issuer of the
+// operator configuration.
+func sameIssuer(a, b *certmanv1alpha1.ACMEIssuerReference) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// createdBefore returns true when a was created before b, or at the same time with a name sorting
+// before the name of b.
+func createdBefore(a, b *certmanv1alpha1.CertificateRequest) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+// setDuplicateCondition sets the CertificateRequestDuplicate condition of cr with message. It
+// returns true when the conditions of cr changed.
+func setDuplicateCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestDuplicate {
+			index = i
+			break
+		}
+	}
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "SameDNSNames"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestDuplicate,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// clearDuplicate removes the CertificateRequestDuplicate condition of cr. It returns true when the
+// conditions of cr changed.
+func clearDuplicate(cr *certmanv1alpha1.CertificateRequest) bool {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestDuplicate {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
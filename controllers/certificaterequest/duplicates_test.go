@@ -0,0 +1,144 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+func TestCheckDuplicate(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	request := func(name string, age time.Duration, dnsNames ...string) *certmanv1alpha1.CertificateRequest {
+		cr := certRequest.DeepCopy()
+		cr.Name = name
+		cr.CreationTimestamp = metav1.NewTime(created.Add(-age))
+		cr.Spec.DnsNames = dnsNames
+		return cr
+	}
+	canonical := request("migrated-primary-cert-bundle", 2*time.Hour, "api.example.com", "*.apps.example.com")
+	duplicate := request("mycluster-primary-cert-bundle", time.Hour, "*.apps.example.com", "API.example.com.")
+	other := request("mycluster-other-cert-bundle", time.Hour, "api.example.com")
+	otherIssuer := request("mycluster-pebble-cert-bundle", time.Hour, "api.example.com", "*.apps.example.com")
+	otherIssuer.Spec.IssuerRef = &certmanv1alpha1.ACMEIssuerReference{
+		Environment:       certmanv1alpha1.ACMEEnvironmentCustom,
+		DirectoryURL:      "https://pebble.example.com/dir",
+		AccountSecretName: "pebble-account",
+	}
+
+	testClient := setUpTestClient(t, []runtime.Object{canonical, duplicate, other, otherIssuer})
+	rcr := CertificateRequestReconciler{Client: testClient}
+
+	check := func(cr *certmanv1alpha1.CertificateRequest) (bool, *certmanv1alpha1.CertificateRequest) {
+		t.Helper()
+		actual := &certmanv1alpha1.CertificateRequest{}
+		key := types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}
+		assert.NoError(t, testClient.Get(context.TODO(), key, actual))
+		canonical, err := rcr.checkDuplicate(logr.Discard(), actual)
+		assert.NoError(t, err)
+		assert.NoError(t, testClient.Get(context.TODO(), key, actual))
+		return canonical != nil, actual
+	}
+
+	isDuplicate, actual := check(canonical)
+	assert.False(t, isDuplicate)
+	assert.Empty(t, actual.Status.Conditions)
+
+	isDuplicate, actual = check(other)
+	assert.False(t, isDuplicate)
+	assert.Empty(t, actual.Status.Conditions)
+
+	// the same names requested from another ACME issuer are another certificate
+	isDuplicate, actual = check(otherIssuer)
+	assert.False(t, isDuplicate)
+	assert.Empty(t, actual.Status.Conditions)
+
+	isDuplicate, actual = check(duplicate)
+	assert.True(t, isDuplicate)
+	if assert.Len(t, actual.Status.Conditions, 1) {
+		assert.Equal(t, certmanv1alpha1.CertificateRequestDuplicate, actual.Status.Conditions[0].Type)
+		assert.Contains(t, *actual.Status.Conditions[0].Message, canonical.Name)
+	}
+
+	// the duplicate issues the certificate once the canonical CertificateRequest is deleted
+	assert.NoError(t, testClient.Delete(context.TODO(), canonical))
+	isDuplicate, actual = check(duplicate)
+	assert.False(t, isDuplicate)
+	assert.Empty(t, actual.Status.Conditions)
+}
+
+func TestReconcileDuplicateCopiesCanonicalCertificate(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	canonical := certRequest.DeepCopy()
+	canonical.Name = "migrated-primary-cert-bundle"
+	canonical.CreationTimestamp = metav1.NewTime(created)
+	canonical.Spec.CertificateSecret.Name = "migrated-primary-cert-bundle-secret"
+	canonicalSecret := validCertSecret.DeepCopy()
+	canonicalSecret.Name = canonical.Spec.CertificateSecret.Name
+	canonicalSecret.Data[corev1.TLSPrivateKeyKey] = []byte("canonical key")
+
+	duplicate := certRequest.DeepCopy()
+	duplicate.CreationTimestamp = metav1.NewTime(created.Add(time.Hour))
+
+	for _, test := range []struct {
+		name          string
+		objects       []runtime.Object
+		createsSecret bool
+	}{
+		{
+			name:    "duplicate nearing expiry gets the renewed certificate",
+			objects: []runtime.Object{expiredCertSecret.DeepCopy()},
+		},
+		{
+			name:          "duplicate without a secret gets one",
+			createsSecret: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			objects := append([]runtime.Object{mockLESecret(), clusterDeploymentComplete.DeepCopy(), canonical.DeepCopy(), canonicalSecret.DeepCopy(), duplicate.DeepCopy()}, test.objects...)
+			testClient := setUpTestClient(t, objects)
+			rcr := CertificateRequestReconciler{Client: testClient, ClientBuilder: setUpFakeAWSClient, Scheme: testClient.Scheme()}
+
+			result, err := rcr.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: duplicate.Namespace, Name: duplicate.Name}})
+			assert.NoError(t, err)
+			assert.Equal(t, utils.DefaultRequeueIntervals[utils.WaitDuplicate], result.RequeueAfter)
+
+			secret := &corev1.Secret{}
+			assert.NoError(t, testClient.Get(context.TODO(), types.NamespacedName{Namespace: duplicate.Namespace, Name: duplicate.Spec.CertificateSecret.Name}, secret))
+			assert.Equal(t, canonicalSecret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSCertKey])
+			assert.Equal(t, canonicalSecret.Data[corev1.TLSPrivateKeyKey], secret.Data[corev1.TLSPrivateKeyKey])
+			if test.createsSecret {
+				owner := metav1.GetControllerOf(secret)
+				if assert.NotNil(t, owner) {
+					assert.Equal(t, duplicate.Name, owner.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	gerrors "errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/exporters"
+)
+
+const (
+	exportFailedEventReason = "CertificateExportFailed"
+
+	// ACMImportAnnotation set to "true" on a CertificateRequest of an AWS cluster imports its
+	// certificate into AWS Certificate Manager, like an acm export in its spec, for the load
+	// balancers fronting the cluster
+	ACMImportAnnotation = "certman.managed.openshift.io/acm-import"
+	// ClusterIDTag tags the exported certificates with the ID of their cluster, in the secret stores
+	// supporting tags
+	ClusterIDTag = "certman.managed.openshift.io/cluster-id"
+)
+
+// syncExports stores the certificate of secret in the exports of cr that don't hold it yet, and
+// deletes the certificates of the exports no longer asked for. cd is the ClusterDeployment of cr,
+// nil for the canary. The secret stores are called by the issuance workers, it returns true when
+// the work was handed over to them.
+func (r *CertificateRequestReconciler) syncExports(ctx context.Context, reqLogger logr.Logger, request reconcile.Request, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment, secret *corev1.Secret) (bool, error) {
+	desired := desiredExports(cr)
+	if r.ExporterBuilder == nil || (len(desired) == 0 && len(cr.Status.Exports) == 0) {
+		return false, nil
+	}
+
+	keys := secretKeys(cr)
+	certificate, err := ParseCertificateData(secret.Data[keys.certificate])
+	if err != nil {
+		return false, err
+	}
+	serialNumber := certificate.SerialNumber.String()
+
+	if exportsInSync(cr, desired, serialNumber) {
+		return false, nil
+	}
+	if r.deferToIssuanceWorkers(ctx, reqLogger, request) {
+		return true, nil
+	}
+
+	chain := secret.Data[keys.certificate]
+	if keys.ca != "" {
+		chain = append(append([]byte{}, chain...), secret.Data[keys.ca]...)
+	}
+	exported := exporters.Certificate{Chain: chain}
+	if cr.Spec.CSR == "" {
+		exported.PrivateKey = secret.Data[keys.privateKey]
+	}
+	clusterDeploymentName := ""
+	if cd != nil {
+		clusterDeploymentName = cd.Name
+		if clusterID := clusterIDForZoneRecords(cd); clusterID != "" {
+			exported.Tags = map[string]string{ClusterIDTag: clusterID}
+		}
+	}
+
+	statuses := map[string]certmanv1alpha1.CertificateExportStatus{}
+	for _, status := range cr.Status.Exports {
+		statuses[status.Name] = status
+	}
+
+	var errs []error
+	exports := []certmanv1alpha1.CertificateExportStatus{}
+	for _, export := range desired {
+		name := exporters.Name(export)
+		status, ok := statuses[name]
+		delete(statuses, name)
+		if !ok {
+			status = certmanv1alpha1.CertificateExportStatus{Name: name}
+		}
+
+		if status.SerialNumber != serialNumber || status.Error != "" {
+			if err := r.export(reqLogger, cr, clusterDeploymentName, export, exported, &status); err != nil {
+				if r.Recorder != nil {
+					r.Recorder.Event(cr, corev1.EventTypeWarning, exportFailedEventReason, fmt.Sprintf("could not store the certificate in %s: %v", name, err))
+				}
+				errs = append(errs, fmt.Errorf("could not store the certificate in %s: %w", name, err))
+			} else {
+				status.SerialNumber = serialNumber
+			}
+		}
+		exports = append(exports, status)
+	}
+
+	// the exports left are no longer asked for
+	for _, status := range cr.Status.Exports {
+		if _, ok := statuses[status.Name]; !ok {
+			continue
+		}
+		if err := r.deleteExport(reqLogger, cr, clusterDeploymentName, status); err != nil {
+			status.Error = err.Error()
+			exports = append(exports, status)
+			errs = append(errs, fmt.Errorf("could not delete the certificate of %s: %w", status.Name, err))
+		}
+	}
+
+	cr.Status.Exports = exports
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		return false, err
+	}
+	return false, gerrors.Join(errs...)
+}
+
+// desiredExports returns the exports of the spec of cr, along with the ACM export asked for by the
+// ACMImportAnnotation.
+func desiredExports(cr *certmanv1alpha1.CertificateRequest) []certmanv1alpha1.CertificateExport {
+	if cr.Annotations[ACMImportAnnotation] != "true" {
+		return cr.Spec.Exports
+	}
+	for _, export := range cr.Spec.Exports {
+		if export.ACM != nil {
+			return cr.Spec.Exports
+		}
+	}
+	return append(append([]certmanv1alpha1.CertificateExport{}, cr.Spec.Exports...), certmanv1alpha1.CertificateExport{ACM: &certmanv1alpha1.ACMExport{}})
+}
+
+// exportsInSync returns true when every export of desired holds the certificate of serialNumber
+// and no other export is left in the status of cr.
+func exportsInSync(cr *certmanv1alpha1.CertificateRequest, desired []certmanv1alpha1.CertificateExport, serialNumber string) bool {
+	if len(desired) != len(cr.Status.Exports) {
+		return false
+	}
+
+	statuses := map[string]certmanv1alpha1.CertificateExportStatus{}
+	for _, status := range cr.Status.Exports {
+		statuses[status.Name] = status
+	}
+	for _, export := range desired {
+		status, ok := statuses[exporters.Name(export)]
+		if !ok || status.SerialNumber != serialNumber || status.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// export stores certificate in export, recording the result in status.
+func (r *CertificateRequestReconciler) export(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, clusterDeploymentName string, export certmanv1alpha1.CertificateExport, certificate exporters.Certificate, status *certmanv1alpha1.CertificateExportStatus) error {
+	exporter, err := r.ExporterBuilder(reqLogger, r.Client, cr.Spec.Platform, cr.Namespace, clusterDeploymentName, export)
+	if err == nil {
+		reqLogger.Info(fmt.Sprintf("storing the certificate in %s", status.Name))
+		var reference string
+		reference, err = exporter.Export(reqLogger, certificate, status.Reference)
+		if err == nil {
+			status.Reference = reference
+		}
+	}
+	if err != nil {
+		status.Error = err.Error()
+		return err
+	}
+
+	now := metav1.NewTime(r.now())
+	status.LastExportTime = &now
+	status.Error = ""
+	return nil
+}
+
+// deleteExport deletes the certificate of the export of status from its secret store.
+func (r *CertificateRequestReconciler) deleteExport(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, clusterDeploymentName string, status certmanv1alpha1.CertificateExportStatus) error {
+	// the export failed before anything was stored
+	if status.Reference == "" {
+		return nil
+	}
+
+	export, err := exporters.ExportFromName(status.Name)
+	if err != nil {
+		return err
+	}
+	exporter, err := r.ExporterBuilder(reqLogger, r.Client, cr.Spec.Platform, cr.Namespace, clusterDeploymentName, export)
+	if err != nil {
+		return err
+	}
+	reqLogger.Info(fmt.Sprintf("deleting the certificate of %s", status.Name))
+	return exporter.Delete(reqLogger, status.Reference)
+}
+
+// deleteExports deletes the certificates of every export of cr from their secret stores, when
+// cr is deleted.
+func (r *CertificateRequestReconciler) deleteExports(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
+	if r.ExporterBuilder == nil {
+		return nil
+	}
+
+	clusterDeploymentName := ""
+	for _, ownerRef := range cr.OwnerReferences {
+		if ownerRef.Kind == clusterDeploymentType {
+			clusterDeploymentName = ownerRef.Name
+		}
+	}
+	for _, status := range cr.Status.Exports {
+		if err := r.deleteExport(reqLogger, cr, clusterDeploymentName, status); err != nil {
+			return fmt.Errorf("could not delete the certificate of %s: %w", status.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/exporters"
+	"github.com/openshift/certman-operator/pkg/testutils"
+)
+
+// fakeExporter records the certificates stored and deleted through it.
+type fakeExporter struct {
+	exported []exporters.Certificate
+	deleted  []string
+}
+
+func (e *fakeExporter) Export(reqLogger logr.Logger, certificate exporters.Certificate, reference string) (string, error) {
+	e.exported = append(e.exported, certificate)
+	return fmt.Sprintf("reference-%d", len(e.exported)), nil
+}
+
+func (e *fakeExporter) Delete(reqLogger logr.Logger, reference string) error {
+	e.deleted = append(e.deleted, reference)
+	return nil
+}
+
+func TestSyncExports(t *testing.T) {
+	certificate, key := testKeyPair(t, certRequest.Spec.DnsNames...)
+
+	cr := certRequest.DeepCopy()
+	cr.Spec.Exports = []certmanv1alpha1.CertificateExport{{
+		AWSSecretsManager: &certmanv1alpha1.AWSSecretsManagerExport{SecretName: "api-certificate"},
+	}}
+	cr.Status.Exports = []certmanv1alpha1.CertificateExportStatus{{
+		Name:         "acm",
+		Reference:    "arn:aws:acm:us-east-1:123456789012:certificate/removed",
+		SerialNumber: "1",
+	}}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: cr.Spec.CertificateSecret.Name, Namespace: cr.Namespace},
+		Data: map[string][]byte{
+			v1.TLSCertKey:       certificate,
+			v1.TLSPrivateKeyKey: key,
+		},
+	}
+
+	testClient := setUpTestClient(t, []runtime.Object{cr, secret})
+	exporter := &fakeExporter{}
+	r := CertificateRequestReconciler{
+		Client: testClient,
+		ExporterBuilder: func(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string, export certmanv1alpha1.CertificateExport) (exporters.Exporter, error) {
+			return exporter, nil
+		},
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}}
+
+	current := &certmanv1alpha1.CertificateRequest{}
+	if err := testClient.Get(context.TODO(), request.NamespacedName, current); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := r.syncExports(context.TODO(), logr.Discard(), request, current, nil, secret); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(exporter.exported) != 1 || string(exporter.exported[0].PrivateKey) != string(key) {
+		t.Fatalf("expected the certificate and its key to be exported once, got %d exports", len(exporter.exported))
+	}
+	if len(exporter.deleted) != 1 || exporter.deleted[0] != "arn:aws:acm:us-east-1:123456789012:certificate/removed" {
+		t.Errorf("expected the certificate of the removed export to be deleted, got %v", exporter.deleted)
+	}
+
+	updated := &certmanv1alpha1.CertificateRequest{}
+	if err := testClient.Get(context.TODO(), request.NamespacedName, updated); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(updated.Status.Exports) != 1 {
+		t.Fatalf("expected 1 export in the status, got %v", updated.Status.Exports)
+	}
+	status := updated.Status.Exports[0]
+	if status.Name != "aws-secrets-manager/api-certificate" || status.Reference != "reference-1" || status.SerialNumber != "1" || status.LastExportTime == nil {
+		t.Errorf("unexpected export status %+v", status)
+	}
+
+	// the exports hold the certificate of the secret
+	if _, err := r.syncExports(context.TODO(), logr.Discard(), request, updated, nil, secret); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(exporter.exported) != 1 {
+		t.Errorf("expected the certificate not to be exported again, got %d exports", len(exporter.exported))
+	}
+}
+
+func TestSyncExportsStatusConflict(t *testing.T) {
+	certificate, key := testKeyPair(t, certRequest.Spec.DnsNames...)
+
+	cr := certRequest.DeepCopy()
+	cr.Spec.Exports = []certmanv1alpha1.CertificateExport{{ACM: &certmanv1alpha1.ACMExport{}}}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: cr.Spec.CertificateSecret.Name, Namespace: cr.Namespace},
+		Data: map[string][]byte{
+			v1.TLSCertKey:       certificate,
+			v1.TLSPrivateKeyKey: key,
+		},
+	}
+
+	testClient := testutils.NewFaultClient(setUpTestClient(t, []runtime.Object{cr, secret}),
+		testutils.Fault{Verb: testutils.StatusUpdate, Object: &certmanv1alpha1.CertificateRequest{}, Class: testutils.Conflict})
+	exporter := &fakeExporter{}
+	r := CertificateRequestReconciler{
+		Client: testClient,
+		ExporterBuilder: func(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string, export certmanv1alpha1.CertificateExport) (exporters.Exporter, error) {
+			return exporter, nil
+		},
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}}
+
+	current := &certmanv1alpha1.CertificateRequest{}
+	if err := testClient.Get(context.TODO(), request.NamespacedName, current); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := r.syncExports(context.TODO(), logr.Discard(), request, current, nil, secret); !errors.IsConflict(err) {
+		t.Fatalf("expected the conflict updating the status to be returned, got %v", err)
+	}
+	if len(exporter.exported) != 1 {
+		t.Errorf("expected the certificate to be exported, got %d exports", len(exporter.exported))
+	}
+}
+
+func TestDesiredExports(t *testing.T) {
+	secretsManager := certmanv1alpha1.CertificateExport{
+		AWSSecretsManager: &certmanv1alpha1.AWSSecretsManagerExport{SecretName: "api-certificate"},
+	}
+	acm := certmanv1alpha1.CertificateExport{ACM: &certmanv1alpha1.ACMExport{}}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		exports     []certmanv1alpha1.CertificateExport
+		expected    []string
+	}{
+		{
+			name:     "exports of the spec",
+			exports:  []certmanv1alpha1.CertificateExport{secretsManager},
+			expected: []string{"aws-secrets-manager/api-certificate"},
+		},
+		{
+			name:        "ACM import annotation",
+			annotations: map[string]string{ACMImportAnnotation: "true"},
+			exports:     []certmanv1alpha1.CertificateExport{secretsManager},
+			expected:    []string{"aws-secrets-manager/api-certificate", "acm"},
+		},
+		{
+			name:        "ACM import annotation with an acm export",
+			annotations: map[string]string{ACMImportAnnotation: "true"},
+			exports:     []certmanv1alpha1.CertificateExport{acm},
+			expected:    []string{"acm"},
+		},
+		{
+			name:        "ACM import annotation set to false",
+			annotations: map[string]string{ACMImportAnnotation: "false"},
+			expected:    []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			cr.Annotations = test.annotations
+			cr.Spec.Exports = test.exports
+
+			names := []string{}
+			for _, export := range desiredExports(cr) {
+				names = append(names, exporters.Name(export))
+			}
+			if fmt.Sprint(names) != fmt.Sprint(test.expected) {
+				t.Errorf("expected exports %v, got %v", test.expected, names)
+			}
+			if len(cr.Spec.Exports) != len(test.exports) {
+				t.Errorf("expected the spec to be left as is, got %v", cr.Spec.Exports)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// errRevocationDisabled keeps the finalizer of a CertificateRequest while revocation is disabled, so
+// its certificate is revoked once the gate is lifted rather than never
+var errRevocationDisabled = errors.New("revocation is disabled by the " + cTypes.DisableRevocation + " feature gate")
+
+// issuanceDisabled returns true when a feature gate keeps the certificate of cr from being issued,
+// either because issuance is disabled or because the DNS provider of cr is.
+func (r *CertificateRequestReconciler) issuanceDisabled(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) bool {
+	gates := utils.GetFeatureGates(r.Client)
+
+	gate := ""
+	switch {
+	case gates.DisableIssuance:
+		gate = cTypes.DisableIssuance
+	case gates.ProviderDisabled(cr.Spec.Platform):
+		gate = cTypes.DisableProvider
+	default:
+		return false
+	}
+
+	reqLogger.Info(fmt.Sprintf("not issuing the certificate: the %s feature gate is set", gate))
+	localmetrics.IncrementFeatureGateSkipCount(gate)
+	return true
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+func TestFeatureGates(t *testing.T) {
+	testCases := []struct {
+		Name                    string
+		Gates                   map[string]string
+		ExpectIssuanceDisabled  bool
+		ExpectRevocationSkipped bool
+	}{
+		{
+			Name: "no feature gates",
+		},
+		{
+			Name:                   "issuance disabled",
+			Gates:                  map[string]string{cTypes.DisableIssuance: "true"},
+			ExpectIssuanceDisabled: true,
+		},
+		{
+			Name:                   "provider of the CertificateRequest disabled",
+			Gates:                  map[string]string{cTypes.DisableProvider: "gcp, AWS"},
+			ExpectIssuanceDisabled: true,
+		},
+		{
+			Name:  "other provider disabled",
+			Gates: map[string]string{cTypes.DisableProvider: "azure"},
+		},
+		{
+			Name:                    "revocation disabled",
+			Gates:                   map[string]string{cTypes.DisableRevocation: "true"},
+			ExpectRevocationSkipped: true,
+		},
+		{
+			Name:  "invalid value",
+			Gates: map[string]string{cTypes.DisableIssuance: "yes please"},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			objects := []runtime.Object{certRequest, validCertSecret}
+			if test.Gates != nil {
+				objects = append(objects, &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      utils.FeatureGatesConfigMapName,
+						Namespace: config.OperatorNamespace,
+					},
+					Data: test.Gates,
+				})
+			}
+			rcr := CertificateRequestReconciler{
+				Client:        setUpTestClient(t, objects),
+				ClientBuilder: setUpFakeAWSClient,
+			}
+
+			cr := certRequest.DeepCopy()
+			cr.Spec.Platform.AWS = &certmanv1alpha1.AWSPlatformSecrets{Region: "us-east-1"}
+			if disabled := rcr.issuanceDisabled(logr.Discard(), cr); disabled != test.ExpectIssuanceDisabled {
+				t.Errorf("expected issuance disabled %t, got %t", test.ExpectIssuanceDisabled, disabled)
+			}
+
+			// the lets-encrypt account secret is missing, so an attempted revocation fails otherwise
+			err := rcr.revokeCertificateAndDeleteSecret(logr.Discard(), cr)
+			if skipped := errors.Is(err, errRevocationDisabled); skipped != test.ExpectRevocationSkipped {
+				t.Errorf("expected revocation skipped %t, got error %v", test.ExpectRevocationSkipped, err)
+			}
+		})
+	}
+}
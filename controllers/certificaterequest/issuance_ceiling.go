@@ -0,0 +1,66 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/acmeaccount"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/leclient"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// issuanceCeilingReached returns true when the ACME account of leClient already issued its weekly
+// issuance ceiling, so a certificate waits for older issuances to leave the inventory window. An
+// inventory that can't be read doesn't hold issuance back, the ACME server still enforces its own
+// limits.
+func (r *CertificateRequestReconciler) issuanceCeilingReached(reqLogger logr.Logger, leClient leclient.LetsEncryptClientInterface) bool {
+	ceiling := utils.GetACMEAccountWeeklyIssuanceCeiling(r.Client)
+	if ceiling <= 0 {
+		return false
+	}
+
+	inventory := &acmeaccount.Inventory{Client: r.Client}
+	account, err := inventory.Account(context.TODO(), leClient.GetAccountURL())
+	if err != nil {
+		reqLogger.Error(err, "could not read the issuance inventory of the ACME account, not enforcing its weekly ceiling")
+		return false
+	}
+
+	issued := account.IssuedSince(r.now().Add(-acmeaccount.InventoryWindow))
+	if issued < ceiling {
+		return false
+	}
+
+	reqLogger.Info(fmt.Sprintf("not issuing the certificate: the ACME account issued %d certificates in the last 7 days, its weekly ceiling is %d", issued, ceiling))
+	localmetrics.IncrementACMEAccountIssuanceCeilingCount(leClient.GetAccountURL())
+	return true
+}
+
+// recordAccountIssuance adds the certificate just issued for cr to the issuance inventory of the
+// ACME account of leClient. The certificate is already issued, a failure is only logged.
+func (r *CertificateRequestReconciler) recordAccountIssuance(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, leClient leclient.LetsEncryptClientInterface) {
+	inventory := &acmeaccount.Inventory{Client: r.Client}
+	if _, err := inventory.Record(context.TODO(), leClient.GetAccountURL(), cr.Namespace+"/"+cr.Name, r.now()); err != nil {
+		reqLogger.Error(err, "could not record the certificate in the issuance inventory of the ACME account")
+	}
+}
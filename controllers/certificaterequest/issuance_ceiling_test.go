@@ -0,0 +1,90 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eggsampler/acme"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/acmeaccount"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/leclient"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestIssuanceCeilingReached(t *testing.T) {
+	const accountURL = "https://acme-v02.api.letsencrypt.org/acme/acct/ceiling"
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		ceiling       string
+		expectReached bool
+	}{
+		{
+			name: "no ceiling",
+		},
+		{
+			name:    "under the ceiling",
+			ceiling: "3",
+		},
+		{
+			name:          "ceiling reached",
+			ceiling:       "2",
+			expectReached: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName, Namespace: config.OperatorNamespace},
+				Data:       map[string]string{cTypes.ACMEAccountWeeklyCeiling: test.ceiling},
+			}
+			testClient := setUpTestClient(t, []runtime.Object{cm})
+			rcr := CertificateRequestReconciler{Client: testClient, Clock: clock.NewFake(now)}
+			leClient := &leclient.LetsEncryptClient{Account: acme.Account{URL: accountURL}}
+
+			// an issuance older than a week doesn't count
+			inventory := &acmeaccount.Inventory{Client: testClient}
+			for _, issuedAt := range []time.Time{now.Add(-8 * 24 * time.Hour), now.Add(-time.Hour), now.Add(-time.Minute)} {
+				if _, err := inventory.Record(context.TODO(), accountURL, testHiveNamespace+"/"+testHiveCertificateRequestName, issuedAt); err != nil {
+					t.Fatalf("could not record the issuance: %s", err)
+				}
+			}
+
+			before := testutil.ToFloat64(localmetrics.MetricACMEAccountIssuanceCeilingCount.WithLabelValues(accountURL))
+			if reached := rcr.issuanceCeilingReached(logr.Discard(), leClient); reached != test.expectReached {
+				t.Errorf("expected the ceiling to be reached: %t, got %t", test.expectReached, reached)
+			}
+			deferred := testutil.ToFloat64(localmetrics.MetricACMEAccountIssuanceCeilingCount.WithLabelValues(accountURL)) - before
+			if test.expectReached != (deferred == 1) {
+				t.Errorf("expected the deferral to be counted: %t, counted %v", test.expectReached, deferred)
+			}
+		})
+	}
+}
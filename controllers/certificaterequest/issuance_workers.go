@@ -0,0 +1,170 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	// DefaultReconcileWorkers is the default number of CertificateRequests reconciled at a time
+	DefaultReconcileWorkers = 10
+
+	// DefaultIssuanceWorkers is the default number of CertificateRequests whose certificates are
+	// issued or revoked at a time apart from the reconciles. The issuance workers are off by
+	// default, certificates are issued and revoked within the reconciles.
+	DefaultIssuanceWorkers = 0
+
+	// issuanceQueueName names the workqueue metrics of the issuance workers
+	issuanceQueueName = "certificaterequest_issuance"
+)
+
+// issuanceWorkerKey marks the context of the reconciles run by the issuance workers
+type issuanceWorkerKey struct{}
+
+// issuanceWorkers reconcile the CertificateRequests that need the ACME server or a DNS provider, to
+// issue, renew or revoke their certificates, on a bounded pool of workers of their own. The
+// reconciles of the controller, which hand these CertificateRequests over, then only read and
+// write the cluster, so status, ownership and metrics updates keep up during a renewal storm.
+//
+// A CertificateRequest handed over belongs to the workers until they are done with it, queued,
+// waiting to be requeued or in flight, and the controller doesn't reconcile it meanwhile.
+type issuanceWorkers struct {
+	reconciler         *CertificateRequestReconciler
+	queue              workqueue.RateLimitingInterface
+	workers            int
+	needLeaderElection bool
+
+	mu   sync.Mutex
+	busy map[reconcile.Request]bool
+}
+
+func newIssuanceWorkers(r *CertificateRequestReconciler, workers int) *issuanceWorkers {
+	return &issuanceWorkers{
+		reconciler: r,
+		queue: workqueue.NewRateLimitingQueueWithConfig(
+			workqueue.NewItemExponentialFailureRateLimiter(1*time.Second, 30*time.Second),
+			workqueue.RateLimitingQueueConfig{Name: issuanceQueueName},
+		),
+		workers: workers,
+		busy:    map[reconcile.Request]bool{},
+		// the workers follow the controller, which runs on every replica when sharded
+		needLeaderElection: r.Shard == nil,
+	}
+}
+
+// Start runs the workers until ctx is done.
+func (w *issuanceWorkers) Start(ctx context.Context) error {
+	workerCtx := context.WithValue(ctx, issuanceWorkerKey{}, true)
+	workerCtx = logging.IntoContext(workerCtx, logging.FromContext(ctx).WithName(issuanceQueueName))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for w.processNextItem(workerCtx) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	w.queue.ShutDown()
+	wg.Wait()
+	return nil
+}
+
+// NeedLeaderElection returns true unless the controller is sharded.
+func (w *issuanceWorkers) NeedLeaderElection() bool {
+	return w.needLeaderElection
+}
+
+// processNextItem reconciles the next CertificateRequest of the queue, requeueing it like the
+// controller would. It returns false once the queue is shut down.
+func (w *issuanceWorkers) processNextItem(ctx context.Context) bool {
+	item, shutdown := w.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer w.queue.Done(item)
+
+	request := item.(reconcile.Request)
+	result, err := w.reconciler.Reconcile(ctx, request)
+	switch {
+	case err != nil:
+		logging.FromContext(ctx).Error(err, "issuance failed", logging.CertificateRequestKey, logging.ObjectName(request.Namespace, request.Name))
+		w.queue.AddRateLimited(item)
+	case result.RequeueAfter > 0:
+		w.queue.Forget(item)
+		w.queue.AddAfter(item, result.RequeueAfter)
+	case result.Requeue:
+		w.queue.AddRateLimited(item)
+	default:
+		w.queue.Forget(item)
+		w.release(request)
+	}
+	return true
+}
+
+// add hands request over to the workers, which own it until they release it.
+func (w *issuanceWorkers) add(request reconcile.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.busy[request] = true
+	w.queue.Add(request)
+}
+
+// release gives request back to the controller once the workers are done with it.
+func (w *issuanceWorkers) release(request reconcile.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.busy, request)
+}
+
+// owns returns true when request is queued, waiting to be requeued or in flight in the workers.
+func (w *issuanceWorkers) owns(request reconcile.Request) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.busy[request]
+}
+
+// deferToIssuanceWorkers hands request over to the issuance workers and returns true, unless r has
+// no issuance workers or ctx is the one of a worker, in which case the work is done in place.
+func (r *CertificateRequestReconciler) deferToIssuanceWorkers(ctx context.Context, reqLogger logr.Logger, request reconcile.Request) bool {
+	if r.issuance == nil || ctx.Value(issuanceWorkerKey{}) != nil {
+		return false
+	}
+
+	reqLogger.Info("handing the CertificateRequest over to the issuance workers")
+	r.issuance.add(request)
+	return true
+}
+
+// ownedByIssuanceWorkers returns true when request was handed over to the issuance workers, which
+// haven't given it back yet, and ctx isn't the one of a worker. The controller then leaves the
+// CertificateRequest to the workers, so they don't both update it at once.
+func (r *CertificateRequestReconciler) ownedByIssuanceWorkers(ctx context.Context, request reconcile.Request) bool {
+	return r.issuance != nil && ctx.Value(issuanceWorkerKey{}) == nil && r.issuance.owns(request)
+}
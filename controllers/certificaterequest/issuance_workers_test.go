@@ -0,0 +1,111 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+func TestIssuanceWorkers(t *testing.T) {
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}}
+
+	t.Run("issuance is done in place without issuance workers", func(t *testing.T) {
+		rcr := CertificateRequestReconciler{
+			Client:        setUpTestClient(t, []runtime.Object{certRequest}),
+			ClientBuilder: setUpFakeAWSClient,
+		}
+
+		if rcr.deferToIssuanceWorkers(context.TODO(), logr.Discard(), request) {
+			t.Errorf("expected the issuance not to be deferred")
+		}
+	})
+
+	t.Run("issuance is handed over to the issuance workers", func(t *testing.T) {
+		rcr := &CertificateRequestReconciler{
+			Client:        setUpTestClient(t, []runtime.Object{certRequest}),
+			ClientBuilder: setUpFakeAWSClient,
+		}
+		rcr.issuance = newIssuanceWorkers(rcr, 1)
+		defer rcr.issuance.queue.ShutDown()
+
+		if !rcr.deferToIssuanceWorkers(context.TODO(), logr.Discard(), request) {
+			t.Errorf("expected the issuance to be deferred")
+		}
+		if rcr.issuance.queue.Len() != 1 {
+			t.Errorf("expected 1 queued CertificateRequest, got %d", rcr.issuance.queue.Len())
+		}
+
+		if !rcr.ownedByIssuanceWorkers(context.TODO(), request) {
+			t.Errorf("expected the queued CertificateRequest to be owned by the issuance workers")
+		}
+
+		// the worker doesn't hand the CertificateRequest over again
+		workerCtx := context.WithValue(context.TODO(), issuanceWorkerKey{}, true)
+		if rcr.deferToIssuanceWorkers(workerCtx, logr.Discard(), request) {
+			t.Errorf("expected the issuance worker to issue in place")
+		}
+	})
+
+	t.Run("the worker forgets CertificateRequests that are gone", func(t *testing.T) {
+		rcr := &CertificateRequestReconciler{
+			Client:        setUpTestClient(t, []runtime.Object{}),
+			ClientBuilder: setUpFakeAWSClient,
+		}
+		rcr.issuance = newIssuanceWorkers(rcr, 1)
+		defer rcr.issuance.queue.ShutDown()
+
+		rcr.issuance.queue.Add(request)
+		if !rcr.issuance.processNextItem(context.WithValue(context.TODO(), issuanceWorkerKey{}, true)) {
+			t.Fatalf("expected the queue to be running")
+		}
+		if rcr.issuance.queue.Len() != 0 {
+			t.Errorf("expected an empty queue, got %d queued CertificateRequests", rcr.issuance.queue.Len())
+		}
+		if rcr.ownedByIssuanceWorkers(context.TODO(), request) {
+			t.Errorf("expected the CertificateRequest to be given back to the controller")
+		}
+	})
+
+	t.Run("the controller requeues CertificateRequests owned by the issuance workers", func(t *testing.T) {
+		rcr := &CertificateRequestReconciler{
+			Client:        setUpTestClient(t, []runtime.Object{certRequest}),
+			ClientBuilder: setUpFakeAWSClient,
+		}
+		rcr.issuance = newIssuanceWorkers(rcr, 1)
+		defer rcr.issuance.queue.ShutDown()
+
+		rcr.issuance.add(request)
+		result, err := rcr.Reconcile(context.TODO(), request)
+		if err != nil {
+			t.Fatalf("Reconcile() unexpected error: %v", err)
+		}
+		if expected := utils.DefaultRequeueIntervals[utils.WaitIssuanceWorkers]; result.RequeueAfter != expected {
+			t.Errorf("expected a requeue after %v, got %v", expected, result.RequeueAfter)
+		}
+		if rcr.issuance.queue.Len() != 1 {
+			t.Errorf("expected the CertificateRequest to stay queued once, got %d queued CertificateRequests", rcr.issuance.queue.Len())
+		}
+	})
+}
@@ -18,16 +18,13 @@ package certificaterequest
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/pem"
 	"errors"
 	"flag"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	cClient "github.com/openshift/certman-operator/pkg/clients"
@@ -36,8 +33,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
 	"github.com/openshift/certman-operator/pkg/leclient"
 	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+	"github.com/openshift/certman-operator/pkg/zonelock"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 )
 
@@ -48,11 +49,33 @@ const (
 // IssueCertificate validates DNS write access then assess letsencrypt endpoint (prod or stage) based on leclient url.
 // It then iterates through the CertificateRequest.Spec.DnsNames, authorizes to letsencrypt and sets a challenge in the
 // form of resource record. Certificates are then generated and issued to kubernetes via corev1.
-func (r *CertificateRequestReconciler) IssueCertificate(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, certificateSecret *corev1.Secret, leClient leclient.LetsEncryptClientInterface) error {
+//
+// Issuance goes through the stages OrderCreated, ChallengesPlaced, Validated and Finalized, each recorded in the
+// CertificateRequest status as it completes. An issuance interrupted before Finalized continues with its ACME order
+// instead of creating a new one, and skips the authorizations that are already valid so their DNS records aren't
+// placed again. The caller records the Stored stage once the certificates are in the secret.
+//
+// The features of the cluster are those carried by ctx, cd is the ClusterDeployment owning cr, nil for the canary.
+func (r *CertificateRequestReconciler) IssueCertificate(ctx context.Context, reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment, certificateSecret *corev1.Secret, leClient leclient.LetsEncryptClientInterface) error {
 	timer := prometheus.NewTimer(localmetrics.MetricIssueCertificateDuration)
 
 	defer timer.ObserveDuration()
 
+	if err := validateCertificateSecretTemplate(cr); err != nil {
+		reqLogger.Error(err, "invalid certificate secret template")
+		return err
+	}
+
+	if err := r.checkACMEEnvironment(reqLogger, cr, cd, leClient.GetEnvironment()); err != nil {
+		return err
+	}
+
+	suppliedCSR, err := parseSuppliedCSR(cr)
+	if err != nil {
+		reqLogger.Error(err, "invalid certificate signing request")
+		return err
+	}
+
 	// Get DNS client from CR.
 	dnsClient, err := r.getClient(reqLogger, cr)
 	if err != nil {
@@ -74,6 +97,18 @@ func (r *CertificateRequestReconciler) IssueCertificate(reqLogger logr.Logger, c
 		return err
 	}
 
+	if err := r.checkZoneDelegation(reqLogger, dnsClient, cr); err != nil {
+		reqLogger.Info("not issuing until the base domain is delegated to its new DNS zone")
+		return err
+	}
+
+	if err := r.checkDelegation(reqLogger, dnsClient, cr); err != nil {
+		reqLogger.Error(err, "failed to check the delegation of the base domain")
+		return err
+	}
+
+	r.ensureZoneRecords(reqLogger, dnsClient, cr, cd)
+
 	err = leClient.UpdateAccount(cr.Spec.Email)
 	if err != nil {
 		// if letsencrypt is down, return a better message and update the metric
@@ -90,87 +125,99 @@ func (r *CertificateRequestReconciler) IssueCertificate(reqLogger logr.Logger, c
 
 	certDomains = append(certDomains, cr.Spec.DnsNames...)
 
-	err = leClient.CreateOrder(cr.Spec.DnsNames)
-	if err != nil {
-		reqLogger.Error(err, "failed to create order")
-		return err
+	// the order of an issuance started with another issuer can't be resumed with this one
+	if cr.Status.OrderURL != "" && cr.Status.OrderIssuer != nil && !sameIssuer(cr.Status.OrderIssuer, leClient.GetIssuer()) {
+		reqLogger.Info("the issuer changed since the order of the interrupted issuance was created, creating a new order")
+		r.abandonOrderOfIssuer(reqLogger, cr, abandonedOrderReplaced)
 	}
-	URL := leClient.GetOrderURL()
-	reqLogger.Info("created a new order with Let's Encrypt.", "URL", URL)
 
-	for _, authURL := range leClient.OrderAuthorization() {
-		err := leClient.FetchAuthorization(authURL)
+	if resumeOrder(reqLogger, cr, leClient, r.now()) {
+		reqLogger.Info(fmt.Sprintf("resuming issuance from stage %s", cr.Status.IssuanceStage), "URL", cr.Status.OrderURL)
+	} else {
+		// the order of an interrupted issuance that can't be resumed is replaced
+		r.abandonOrder(reqLogger, cr, leClient, abandonedOrderReplaced)
+
+		err = leClient.CreateOrder(cr.Spec.DnsNames)
 		if err != nil {
-			reqLogger.Error(err, "could not fetch authorizations")
+			reqLogger.Error(err, "failed to create order")
 			return err
 		}
+		URL := leClient.GetOrderURL()
+		reqLogger.Info("created a new order with Let's Encrypt.", "URL", URL)
+
+		// record where the challenges of this issuance are answered so it can be seen in the status
+		cr.Status.DNSProvider = dnsClient.GetDNSName()
+		cr.Status.DNSZone = ""
+		cr.Status.ChallengeFQDNs = nil
+		cr.Status.DomainValidations = nil
+		recordOrder(cr, leClient)
+		r.setIssuanceStage(reqLogger, cr, certmanv1alpha1.IssuanceStageOrderCreated)
+	}
 
-		domain, domErr := leClient.GetAuthorizationIndentifier()
-		if domErr != nil {
-			return fmt.Errorf("could not read domain for authorization")
+	// Challenge records are normally removed as soon as their authorization is valid. Whatever
+	// is left behind, including records of a failed issuance, is swept once we return.
+	keepChallengeRecords := utils.KeepAcmeChallengeRecords(r.Client)
+	defer func() {
+		if keepChallengeRecords {
+			reqLogger.Info("keeping acme challenge resource records as configured")
+			return
 		}
-		leClient.SetChallengeType()
-
-		DNS01KeyAuthorization, keyAuthErr := leClient.GetDNS01KeyAuthorization()
-		if keyAuthErr != nil {
-			return fmt.Errorf("could not get authorization key for dns challenge")
+		// records left behind are swept again after the next issuance, and by the finalizer
+		if err := dnsClient.DeleteAcmeChallengeResourceRecords(reqLogger, cr); err != nil {
+			failDNSCleanup(reqLogger, challengeRecordsCleanup, err, fmt.Sprintf("error occurred deleting acme challenge resource records from %v", dnsClient.GetDNSName()))
 		}
+	}()
 
-		var fqdn string
-		dnsZone, err := r.FindZoneIDForChallenge(cr.Namespace, dnsClient)
+	// a ready order has all of its authorizations valid already
+	if leClient.GetOrderStatus() != leclient.StatusReady {
+		timeout := utils.GetChallengeValidationTimeout(r.Client)
+		checkPropagation := utils.FeaturesFromContext(ctx).Enabled(utils.FeaturePropagationCheck)
+		err = r.completeAuthorizations(ctx, reqLogger, dnsClient, leClient, cr, keepChallengeRecords, checkPropagation, timeout)
 		if err != nil {
 			return err
 		}
 
-		fqdn, err = dnsClient.AnswerDNSChallenge(reqLogger, DNS01KeyAuthorization, domain, cr, dnsZone)
-		if err != nil {
-			return err
+		failedDomains := []string{}
+		for _, validation := range cr.Status.DomainValidations {
+			if !validation.Validated {
+				failedDomains = append(failedDomains, validation.Domain)
+			}
 		}
+		if len(failedDomains) > 0 {
+			err = fmt.Errorf("could not validate domains %s", strings.Join(failedDomains, ", "))
+			certDomains = validatedDomains(cr.Spec.DnsNames, cr.Status.DomainValidations)
+			// the supplied CSR is for every name, it can't finalize an order for some of them
+			if !utils.AllowPartialIssuance(r.Client) || len(certDomains) == 0 || suppliedCSR != nil {
+				reqLogger.Error(err, "challenge validation failed")
+				return err
+			}
 
-		// don't try verifying DNS while in testing
-		// TODO refactor VerifyDnsResourceRecordUpdate() to accept a mock client interface
-		if flag.Lookup("test.v") == nil {
-			dnsChangesVerified := VerifyDnsResourceRecordUpdate(reqLogger, fqdn, DNS01KeyAuthorization)
-			if !dnsChangesVerified {
-				return fmt.Errorf("cannot complete Let's Encrypt challenege as DNS changes could not be verified")
+			// An order with invalid authorizations can't be finalized. A new order for the validated
+			// domains reuses their valid authorizations.
+			reqLogger.Info(fmt.Sprintf("%v, issuing the certificate for the validated domains %v only", err, certDomains))
+			err = leClient.CreateOrder(certDomains)
+			if err != nil {
+				reqLogger.Error(err, "failed to create order for the validated domains")
+				return err
 			}
+			recordOrder(cr, leClient)
 		}
+	} else {
+		// a resumed order may be for the validated domains only
+		certDomains = validatedDomains(cr.Spec.DnsNames, cr.Status.DomainValidations)
+	}
+	r.setIssuanceStage(reqLogger, cr, certmanv1alpha1.IssuanceStageValidated)
 
-		reqLogger.Info(fmt.Sprintf("updating challenge for authorization %v: %v", domain, leClient.GetChallengeURL()))
-		err = leClient.UpdateChallenge()
+	// the private key of a supplied CSR is kept outside of the cluster
+	csr, key := suppliedCSR, []byte(nil)
+	if csr != nil {
+		reqLogger.Info("using the certificate signing request of the CertificateRequest")
+	} else {
+		reqLogger.Info("generating new key and certificate signing request")
+		csr, key, err = newCertificateSigningRequest(certDomains)
 		if err != nil {
-			reqLogger.Error(err, fmt.Sprintf("error updating authorization %s challenge: %v", domain, err))
 			return err
 		}
-
-		reqLogger.Info("challenge successfully completed")
-	}
-
-	reqLogger.Info("generating new key")
-
-	certKey, err := rsa.GenerateKey(rand.Reader, rSAKeyBitSize)
-	if err != nil {
-		return err
-	}
-
-	reqLogger.Info("creating certificate signing request")
-
-	tpl := &x509.CertificateRequest{
-		SignatureAlgorithm: x509.SHA256WithRSA,
-		PublicKeyAlgorithm: x509.RSA,
-		PublicKey:          certKey.Public(),
-		Subject:            pkix.Name{CommonName: certDomains[0]},
-		DNSNames:           certDomains,
-	}
-
-	csrDer, err := x509.CreateCertificateRequest(rand.Reader, tpl, certKey)
-	if err != nil {
-		return err
-	}
-
-	csr, err := x509.ParseCertificateRequest(csrDer)
-	if err != nil {
-		return err
 	}
 
 	reqLogger.Info("finalizing order")
@@ -179,6 +226,7 @@ func (r *CertificateRequestReconciler) IssueCertificate(reqLogger logr.Logger, c
 	if err != nil {
 		return err
 	}
+	r.setIssuanceStage(reqLogger, cr, certmanv1alpha1.IssuanceStageFinalized)
 
 	reqLogger.Info("fetching certificates")
 
@@ -187,6 +235,10 @@ func (r *CertificateRequestReconciler) IssueCertificate(reqLogger logr.Logger, c
 		return err
 	}
 
+	if err := r.checkCertificateQuality(reqLogger, cr, certs); err != nil {
+		return err
+	}
+
 	var pemData []string
 
 	for _, c := range certs {
@@ -196,35 +248,179 @@ func (r *CertificateRequestReconciler) IssueCertificate(reqLogger logr.Logger, c
 		})))
 	}
 
-	key := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(certKey),
-	})
-
 	certificateSecret.Labels = map[string]string{
-		"certificate_request": cr.Name,
+		CertificateRequestSecretLabel: cr.Name,
 	}
 
-	certificateSecret.Data = map[string][]byte{
-		corev1.TLSCertKey:       []byte(pemData[0] + pemData[1]), // create fullchain
-		corev1.TLSPrivateKeyKey: key,
+	// create fullchain
+	setCertificateSecretData(certificateSecret, cr, []byte(pemData[0]+pemData[1]), []byte(pemData[1]), key)
+	if err := r.fitCertificateSecret(reqLogger, cr, certificateSecret); err != nil {
+		reqLogger.Error(err, "certificates can't be stored")
+		return err
 	}
+	clearCertificateCorrupt(cr)
+	recordPartialIssuance(cr, certDomains, r.now())
 
 	reqLogger.Info("certificates are now available")
 
-	// After resolving all new challenges, and storing the cert, delete the challenge records
-	// that were used from dns in this zone.
-	err = dnsClient.DeleteAcmeChallengeResourceRecords(reqLogger, cr)
-	if err != nil {
-		reqLogger.Error(err, "error occurred deleting acme challenge resource records from %v", dnsClient.GetDNSName())
+	return nil
+}
+
+// completeAuthorizations answers the DNS challenge of each authorization of the order that isn't valid yet.
+// A failed challenge doesn't stop the others. The result of each domain is recorded in the DomainValidations
+// status of cr, and only errors that aren't specific to a domain are returned. Once the reconcile
+// deadline of ctx passed, the remaining authorizations are left to the next reconcile and
+// errReconcileDeadline is returned.
+func (r *CertificateRequestReconciler) completeAuthorizations(ctx context.Context, reqLogger logr.Logger, dnsClient cClient.Client, leClient leclient.LetsEncryptClientInterface, cr *certmanv1alpha1.CertificateRequest, keepChallengeRecords, checkPropagation bool, timeout time.Duration) error {
+	cr.Status.DomainValidations = nil
+
+	answered := 0
+	for _, authURL := range leClient.OrderAuthorization() {
+		err := leClient.FetchAuthorization(authURL)
+		if err != nil {
+			reqLogger.Error(err, "could not fetch authorizations")
+			return err
+		}
+
+		domain, domErr := leClient.GetAuthorizationIndentifier()
+		if domErr != nil {
+			return fmt.Errorf("could not read domain for authorization")
+		}
+
+		if leClient.GetAuthorizationStatus() == leclient.StatusValid {
+			reqLogger.Info(fmt.Sprintf("authorization for %v is already valid", domain))
+			recordDomainValidation(cr, domain, nil)
+			continue
+		}
+		// at least one challenge is answered by each reconcile so the issuance always progresses
+		if answered > 0 && reconcileDeadlinePassed(ctx, r.now()) {
+			return r.checkpointIssuance(reqLogger, cr)
+		}
+		answered++
+		leClient.SetChallengeType()
+
+		DNS01KeyAuthorization, keyAuthErr := leClient.GetDNS01KeyAuthorization()
+		if keyAuthErr != nil {
+			return fmt.Errorf("could not get authorization key for dns challenge")
+		}
+
+		// The canary has no hive DNSZone, its zone is only found by the lookup below
+		dnsZone := ""
+		if !IsCanary(cr) {
+			dnsZone, err = r.FindZoneIDForChallenge(cr.Namespace, dnsClient)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The challenge of a subdomain delegated to another zone is answered in that zone
+		fqdn := fmt.Sprintf("%s.%s", cTypes.AcmeChallengeSubDomain, strings.TrimPrefix(domain, "*."))
+		dnsZone, err = dnsClient.GetAuthoritativeZone(reqLogger, fqdn, cr, dnsZone)
+		if err != nil {
+			return err
+		}
+
+		if cr.Status.IssuanceStage != certmanv1alpha1.IssuanceStageChallengesPlaced {
+			r.setIssuanceStage(reqLogger, cr, certmanv1alpha1.IssuanceStageChallengesPlaced)
+		}
+
+		err = r.completeChallenge(ctx, reqLogger, dnsClient, leClient, cr, domain, DNS01KeyAuthorization, dnsZone, keepChallengeRecords, checkPropagation, timeout)
+		if err != nil {
+			reqLogger.Error(err, fmt.Sprintf("challenge for %v failed", domain))
+			r.recordACMEFailure(reqLogger, cr, err)
+		}
+		recordDomainValidation(cr, domain, err)
 	}
 
 	return nil
 }
 
+// recordDomainValidation adds the result of the challenge of domain to the DomainValidations status of cr.
+func recordDomainValidation(cr *certmanv1alpha1.CertificateRequest, domain string, err error) {
+	validation := certmanv1alpha1.DomainValidation{
+		Domain:    domain,
+		Validated: err == nil,
+	}
+	if err != nil {
+		validation.Message = err.Error()
+	}
+	cr.Status.DomainValidations = append(cr.Status.DomainValidations, validation)
+}
+
+// validatedDomains returns the dnsNames whose challenge didn't fail. Authorizations of wildcard names
+// are for the name without the wildcard label, so both are matched.
+func validatedDomains(dnsNames []string, validations []certmanv1alpha1.DomainValidation) []string {
+	domains := []string{}
+	for _, name := range dnsNames {
+		failed := false
+		for _, validation := range validations {
+			if !validation.Validated && validation.Domain == strings.TrimPrefix(name, "*.") {
+				failed = true
+				break
+			}
+		}
+		if !failed {
+			domains = append(domains, name)
+		}
+	}
+	return domains
+}
+
+// resumeOrder loads the ACME order of an issuance that was interrupted before its order was finalized.
+// It returns false when there is no such order, it can no longer be used at now, or it was created
+// before the DnsNames of cr changed, and a new order is needed.
+// The private key of a finalized order is never persisted, so those are not resumed either; a new order
+// for the same domains reuses the valid authorizations and doesn't place DNS records again.
+func resumeOrder(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, leClient leclient.LetsEncryptClientInterface, now time.Time) bool {
+	switch cr.Status.IssuanceStage {
+	case certmanv1alpha1.IssuanceStageOrderCreated, certmanv1alpha1.IssuanceStageChallengesPlaced, certmanv1alpha1.IssuanceStageValidated:
+	default:
+		return false
+	}
+
+	if cr.Status.OrderURL == "" || orderExpired(cr, now) {
+		return false
+	}
+
+	// the CSR of an order for other names would be rejected when finalizing it
+	if dnsNamesKey(cr.Status.OrderDnsNames) != dnsNamesKey(cr.Spec.DnsNames) {
+		reqLogger.Info("the dnsNames changed since the order of the interrupted issuance was created, creating a new order")
+		return false
+	}
+
+	err := leClient.FetchOrder(cr.Status.OrderURL)
+	if err != nil {
+		reqLogger.Error(err, "could not fetch the order of the interrupted issuance, creating a new order")
+		return false
+	}
+
+	status := leClient.GetOrderStatus()
+	if status != leclient.StatusPending && status != leclient.StatusReady {
+		reqLogger.Info(fmt.Sprintf("order of the interrupted issuance is %s, creating a new order", status))
+		return false
+	}
+
+	return true
+}
+
+// setIssuanceStage records stage as the last completed stage of the issuance of cr. Failing to persist
+// it only means a later issuance can't resume from it, so the error is logged and otherwise ignored.
+func (r *CertificateRequestReconciler) setIssuanceStage(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, stage certmanv1alpha1.IssuanceStage) {
+	cr.Status.IssuanceStage = stage
+	if stage == certmanv1alpha1.IssuanceStageStored {
+		cr.Status.CertificateIssuer = cr.Status.OrderIssuer
+		forgetOrder(cr)
+	}
+
+	reqLogger.Info(fmt.Sprintf("issuance stage %s completed", stage))
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		reqLogger.Error(err, fmt.Sprintf("could not record issuance stage %s", stage))
+	}
+}
+
 func (r *CertificateRequestReconciler) FindZoneIDForChallenge(namespace string, dnsClient cClient.Client) (string, error) {
-	if fedramp {
-		fedrampZoneid, err := dnsClient.GetFedrampHostedZoneIDPath(fedrampHostedZoneID)
+	if r.Fedramp.Enabled {
+		fedrampZoneid, err := dnsClient.GetFedrampHostedZoneIDPath(r.Fedramp.HostedZoneID)
 		if err != nil {
 			return "", err
 		}
@@ -257,3 +453,135 @@ func (r *CertificateRequestReconciler) FindZoneIDForChallenge(namespace string,
 
 	return "", fmt.Errorf("unexpected error: not aws or gcp don't know what to do here")
 }
+
+// completeChallenge answers the DNS challenge of domain in dnsZone and has Let's Encrypt validate it,
+// waiting up to timeout for the challenge record to be served unless checkPropagation is false.
+// The challenge record is locked for the whole exchange so that CertificateRequests for the same
+// domain, possibly reconciled by other operator replicas, do not overwrite each other's challenge.
+// Waiting for the lock stops with ctx.
+func (r *CertificateRequestReconciler) completeChallenge(ctx context.Context, reqLogger logr.Logger, dnsClient cClient.Client, leClient leclient.LetsEncryptClientInterface, cr *certmanv1alpha1.CertificateRequest, domain string, DNS01KeyAuthorization string, dnsZone string, keepChallengeRecords, checkPropagation bool, timeout time.Duration) error {
+	reqLogger = logging.WithDomain(reqLogger, domain)
+	record := fmt.Sprintf("%s.%s", cTypes.AcmeChallengeSubDomain, strings.TrimPrefix(domain, "*."))
+	unlockRecord, err := zonelock.Lock(ctx, r.Client, dnsZone, record)
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("could not lock record %v of dns zone %v", record, dnsZone))
+		return err
+	}
+	defer unlockRecord()
+
+	fqdn, err := dnsClient.AnswerDNSChallenge(reqLogger, DNS01KeyAuthorization, domain, cr, dnsZone)
+	if err != nil {
+		return err
+	}
+	cr.Status.DNSZone = dnsZone
+	cr.Status.ChallengeFQDNs = append(cr.Status.ChallengeFQDNs, fqdn)
+
+	// don't try verifying DNS while in testing
+	// TODO refactor VerifyDnsResourceRecordUpdate() to accept a mock client interface
+	if !checkPropagation {
+		reqLogger.Info(fmt.Sprintf("not verifying the propagation of resource record %v: the %s feature is disabled", fqdn, utils.FeaturePropagationCheck))
+	} else if flag.Lookup("test.v") == nil {
+		dnsChangesVerified := verifyChallengeRecord(reqLogger, dnsClient, fqdn, DNS01KeyAuthorization, cr, dnsZone, timeout)
+		if !dnsChangesVerified {
+			return fmt.Errorf("cannot complete Let's Encrypt challenege as DNS changes could not be verified")
+		}
+	}
+
+	// an earlier reconcile may already have asked the ACME server to validate the challenge
+	switch leClient.GetChallengeStatus() {
+	case leclient.StatusValid:
+		reqLogger.Info(fmt.Sprintf("challenge for authorization %v is already valid", domain))
+	case leclient.StatusProcessing:
+		reqLogger.Info(fmt.Sprintf("challenge for authorization %v is already being validated, waiting for its outcome", domain))
+		err = r.waitForAuthorization(reqLogger, leClient, domain, timeout)
+		if err != nil {
+			reqLogger.Error(err, fmt.Sprintf("authorization %s was not validated", domain))
+			if !keepChallengeRecords {
+				r.deleteChallengeRecord(reqLogger, dnsClient, cr, domain, dnsZone)
+			}
+			return err
+		}
+	default:
+		reqLogger.Info(fmt.Sprintf("updating challenge for authorization %v: %v", domain, leClient.GetChallengeURL()))
+		err = leClient.UpdateChallenge()
+		if err != nil {
+			reqLogger.Error(err, fmt.Sprintf("error updating authorization %s challenge: %v", domain, err))
+			return err
+		}
+	}
+
+	reqLogger.Info("challenge successfully completed")
+
+	if !keepChallengeRecords {
+		r.deleteChallengeRecord(reqLogger, dnsClient, cr, domain, dnsZone)
+	}
+
+	return nil
+}
+
+// deleteChallengeRecord deletes the challenge record of domain in dnsZone. Failing to is not fatal,
+// the final sweep will try again.
+func (r *CertificateRequestReconciler) deleteChallengeRecord(reqLogger logr.Logger, dnsClient cClient.Client, cr *certmanv1alpha1.CertificateRequest, domain string, dnsZone string) {
+	err := dnsClient.DeleteAcmeChallengeResourceRecord(reqLogger, domain, cr, dnsZone)
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("error deleting acme challenge resource record for %v", domain))
+	}
+}
+
+// waitForAuthorization polls the current authorization of leClient, for domain, until the ACME server
+// is done validating its challenge. It returns an error when the authorization turned out invalid or
+// was still being validated after timeout.
+func (r *CertificateRequestReconciler) waitForAuthorization(reqLogger logr.Logger, leClient leclient.LetsEncryptClientInterface, domain string, timeout time.Duration) error {
+	authURL := leClient.GetAuthorizationURL()
+	deadline := r.now().Add(timeout)
+	for {
+		err := leClient.FetchAuthorization(authURL)
+		if err != nil {
+			return err
+		}
+
+		status := leClient.GetAuthorizationStatus()
+		switch status {
+		case leclient.StatusValid:
+			return nil
+		case leclient.StatusPending:
+			// the challenge is still being processed
+		default:
+			return fmt.Errorf("authorization for %v is %v", domain, status)
+		}
+
+		if r.now().After(deadline) {
+			return fmt.Errorf("authorization for %v was still being validated after %v", domain, timeout)
+		}
+		reqLogger.Info(fmt.Sprintf("authorization for %v is still %v, checking again in %d seconds", domain, status, waitTimePeriodChallengeValidation))
+		time.Sleep(time.Duration(waitTimePeriodChallengeValidation) * time.Second)
+	}
+}
+
+// verifyChallengeRecord waits up to timeout for the authoritative nameservers of the DNS provider to serve txtValue for fqdn.
+// If the provider's nameservers cannot be queried, it falls back to verifying the record through public DNS.
+func verifyChallengeRecord(reqLogger logr.Logger, dnsClient cClient.Client, fqdn string, txtValue string, cr *certmanv1alpha1.CertificateRequest, dnsZone string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	wait := time.Duration(waitTimePeriodDnsPropagationCheck) * time.Second
+
+	for attempt := 1; ; attempt++ {
+		reqLogger.Info(fmt.Sprintf("attempt %v to verify resource record %v is served by the %v nameservers", attempt, fqdn, dnsClient.GetDNSName()))
+
+		visible, err := dnsClient.VerifyRecordVisible(reqLogger, fqdn, txtValue, cr, dnsZone)
+		if err != nil {
+			reqLogger.Error(err, fmt.Sprintf("unable to query the %v nameservers, falling back to public DNS", dnsClient.GetDNSName()))
+			return VerifyDnsResourceRecordUpdate(reqLogger, fqdn, txtValue)
+		}
+		if visible {
+			return true
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			break
+		}
+		time.Sleep(wait)
+	}
+
+	reqLogger.Info(fmt.Sprintf("unable to verify that resource record %v is served by the %v nameservers within %v", fqdn, dnsClient.GetDNSName(), timeout))
+	return false
+}
@@ -18,9 +18,12 @@ package certificaterequest
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/eggsampler/acme"
 	"github.com/go-logr/logr"
@@ -31,9 +34,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
 	acmemock "github.com/openshift/certman-operator/pkg/acmeclient/mock"
+	cClient "github.com/openshift/certman-operator/pkg/clients"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
 	"github.com/openshift/certman-operator/pkg/leclient"
 	"github.com/openshift/certman-operator/pkg/localmetrics"
 )
@@ -109,7 +116,7 @@ func TestIssueCertificate(t *testing.T) {
 				Client:        testClient,
 				ClientBuilder: setUpFakeAWSClient,
 			}
-			testErr := rcr.IssueCertificate(nullLogger, cr, s, test.LEClient)
+			testErr := rcr.IssueCertificate(context.TODO(), nullLogger, cr, nil, s, test.LEClient)
 			if err != nil && !test.ExpectError {
 				t.Errorf("got unexpected error: %s", err)
 			}
@@ -273,7 +280,8 @@ func TestFindZoneIDForChallenge(t *testing.T) {
 
 			testClient := setUpTestClient(t, tc.KubeObjects)
 			reconciler := &CertificateRequestReconciler{
-				Client: testClient,
+				Client:  testClient,
+				Fedramp: cTypes.Fedramp{Enabled: tc.fedramp, HostedZoneID: tc.fedrampHostedZoneID},
 			}
 
 			mockClient := &dnschallenge.MockClient{
@@ -296,7 +304,419 @@ func TestFindZoneIDForChallenge(t *testing.T) {
 
 			//fedramp
 			if tc.expectedFedrampHostedZoneID != mockClient.FedrampHostedZoneID && tc.fedramp == true {
-				t.Fatalf("unexpected zone id - Expected Fedramp Zone Id: %v - Got - %v", tc.expectedFedrampHostedZoneID, fedrampHostedZoneID)
+				t.Fatalf("unexpected zone id - Expected Fedramp Zone Id: %v - Got - %v", tc.expectedFedrampHostedZoneID, mockClient.FedrampHostedZoneID)
+			}
+		})
+	}
+}
+
+func TestIssueCertificateRecordsDNSStatus(t *testing.T) {
+	testZoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-zone",
+			Namespace: testHiveNamespace,
+		},
+		Status: hivev1.DNSZoneStatus{
+			AWS: &hivev1.AWSDNSZoneStatus{
+				ZoneID: &testZoneID,
+			},
+		},
+	}
+
+	testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret, dnsZone})
+
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}, cr)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// stale values from a previous issuance should be replaced
+	cr.Status.ChallengeFQDNs = []string{"_acme-challenge.stale.record"}
+
+	leClient := &leclient.LetsEncryptClient{
+		Client: acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{
+			Available: true,
+			NewOrderResult: acme.Order{
+				Authorizations: []string{"proto://a.fake.url"},
+			},
+			FetchAuthorizationResult: acme.Authorization{
+				Identifier: acme.Identifier{
+					Value: "api.gibberish.goes.here",
+				},
+			},
+		}),
+	}
+
+	rcr := CertificateRequestReconciler{
+		Client:        testClient,
+		ClientBuilder: setUpFakeAWSClient,
+	}
+	err = rcr.IssueCertificate(context.TODO(), logr.Discard(), cr, nil, &v1.Secret{}, leClient)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if cr.Status.DNSProvider != "Route53" {
+		t.Errorf("expected dns provider %q, got %q", "Route53", cr.Status.DNSProvider)
+	}
+	if cr.Status.DNSZone != "Z0123456789" {
+		t.Errorf("expected dns zone %q, got %q", "Z0123456789", cr.Status.DNSZone)
+	}
+	if !reflect.DeepEqual(cr.Status.ChallengeFQDNs, []string{testHiveACMEDomain}) {
+		t.Errorf("expected challenge fqdns %v, got %v", []string{testHiveACMEDomain}, cr.Status.ChallengeFQDNs)
+	}
+}
+
+func TestIssueCertificateResumesOrder(t *testing.T) {
+	testCases := []struct {
+		Name                 string
+		Stage                certmanv1alpha1.IssuanceStage
+		OrderStatus          string
+		AuthorizationStatus  string
+		ChallengeStatus      string
+		PolledStatus         string
+		OrderDnsNames        []string
+		OrderIssuer          *certmanv1alpha1.ACMEIssuerReference
+		ExpectNewOrder       bool
+		ExpectChallengeCheck bool
+		ExpectError          bool
+	}{
+		{
+			Name:                 "new issuance creates an order",
+			ExpectNewOrder:       true,
+			ExpectChallengeCheck: true,
+		},
+		{
+			Name:                 "resumes pending order and skips valid authorizations",
+			Stage:                certmanv1alpha1.IssuanceStageChallengesPlaced,
+			OrderStatus:          leclient.StatusPending,
+			AuthorizationStatus:  leclient.StatusValid,
+			ExpectNewOrder:       false,
+			ExpectChallengeCheck: false,
+		},
+		{
+			Name:                 "resumes pending order without updating a processing challenge",
+			Stage:                certmanv1alpha1.IssuanceStageChallengesPlaced,
+			OrderStatus:          leclient.StatusPending,
+			AuthorizationStatus:  leclient.StatusPending,
+			ChallengeStatus:      leclient.StatusProcessing,
+			PolledStatus:         leclient.StatusValid,
+			ExpectNewOrder:       false,
+			ExpectChallengeCheck: false,
+		},
+		{
+			Name:                 "fails when a processing challenge turns out invalid",
+			Stage:                certmanv1alpha1.IssuanceStageChallengesPlaced,
+			OrderStatus:          leclient.StatusPending,
+			AuthorizationStatus:  leclient.StatusPending,
+			ChallengeStatus:      leclient.StatusProcessing,
+			PolledStatus:         "invalid",
+			ExpectNewOrder:       false,
+			ExpectChallengeCheck: false,
+			ExpectError:          true,
+		},
+		{
+			Name:                 "resumes ready order without fetching authorizations",
+			Stage:                certmanv1alpha1.IssuanceStageValidated,
+			OrderStatus:          leclient.StatusReady,
+			ExpectNewOrder:       false,
+			ExpectChallengeCheck: false,
+		},
+		{
+			Name:                 "order created before the dnsNames changed is replaced",
+			Stage:                certmanv1alpha1.IssuanceStageChallengesPlaced,
+			OrderStatus:          leclient.StatusPending,
+			AuthorizationStatus:  leclient.StatusValid,
+			OrderDnsNames:        []string{"removed.gibberish.goes.here"},
+			ExpectNewOrder:       true,
+			ExpectChallengeCheck: false,
+		},
+		{
+			Name:                 "order of another issuer is replaced",
+			Stage:                certmanv1alpha1.IssuanceStageChallengesPlaced,
+			OrderStatus:          leclient.StatusPending,
+			AuthorizationStatus:  leclient.StatusValid,
+			OrderIssuer:          &certmanv1alpha1.ACMEIssuerReference{Environment: certmanv1alpha1.ACMEEnvironmentStaging, AccountSecretName: "staging-account"},
+			ExpectNewOrder:       true,
+			ExpectChallengeCheck: false,
+		},
+		{
+			Name:                 "invalid order is replaced",
+			Stage:                certmanv1alpha1.IssuanceStageChallengesPlaced,
+			OrderStatus:          "invalid",
+			ExpectNewOrder:       true,
+			ExpectChallengeCheck: true,
+		},
+		{
+			Name:                 "finalized order is replaced",
+			Stage:                certmanv1alpha1.IssuanceStageFinalized,
+			OrderStatus:          leclient.StatusValid,
+			ExpectNewOrder:       true,
+			ExpectChallengeCheck: true,
+		},
+	}
+
+	testZoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-zone",
+			Namespace: testHiveNamespace,
+		},
+		Status: hivev1.DNSZoneStatus{
+			AWS: &hivev1.AWSDNSZoneStatus{
+				ZoneID: &testZoneID,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret, dnsZone})
+
+			cr := &certmanv1alpha1.CertificateRequest{}
+			err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}, cr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			cr.Status.IssuanceStage = test.Stage
+			if test.Stage != "" {
+				cr.Status.OrderURL = "proto://a.fake.order"
+				cr.Status.OrderDnsNames = cr.Spec.DnsNames
+				if test.OrderDnsNames != nil {
+					cr.Status.OrderDnsNames = test.OrderDnsNames
+				}
+				cr.Status.OrderIssuer = test.OrderIssuer
+			}
+
+			authorization := acme.Authorization{
+				Status: test.AuthorizationStatus,
+				Identifier: acme.Identifier{
+					Value: "issue-certificate-auth-id",
+				},
+				ChallengeMap: map[string]acme.Challenge{
+					"dns-01": {Status: test.ChallengeStatus},
+				},
+			}
+			var authorizations []acme.Authorization
+			if test.PolledStatus != "" {
+				polled := authorization
+				polled.Status = test.PolledStatus
+				authorizations = []acme.Authorization{authorization, polled}
+			}
+			fakeAcmeClient := acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{
+				Available: true,
+				NewOrderResult: acme.Order{
+					URL:            "proto://a.fake.order",
+					Status:         test.OrderStatus,
+					Authorizations: []string{"proto://a.fake.url"},
+				},
+				FetchAuthorizationResult:   authorization,
+				FetchAuthorizationSequence: authorizations,
+			})
+			leClient := &leclient.LetsEncryptClient{Client: fakeAcmeClient}
+
+			rcr := CertificateRequestReconciler{
+				Client:        testClient,
+				ClientBuilder: setUpFakeAWSClient,
+			}
+			err = rcr.IssueCertificate(context.TODO(), logr.Discard(), cr, nil, &v1.Secret{}, leClient)
+			if test.ExpectError {
+				if err == nil {
+					t.Fatal("expected an error, got none")
+				}
+				if fakeAcmeClient.UpdateChallengeCalled {
+					t.Error("expected the processing challenge not to be updated")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if fakeAcmeClient.NewOrderCalled != test.ExpectNewOrder {
+				t.Errorf("expected new order %v, got %v", test.ExpectNewOrder, fakeAcmeClient.NewOrderCalled)
+			}
+			if fakeAcmeClient.UpdateChallengeCalled != test.ExpectChallengeCheck {
+				t.Errorf("expected challenge update %v, got %v", test.ExpectChallengeCheck, fakeAcmeClient.UpdateChallengeCalled)
+			}
+			if cr.Status.IssuanceStage != certmanv1alpha1.IssuanceStageFinalized {
+				t.Errorf("expected issuance stage %s, got %s", certmanv1alpha1.IssuanceStageFinalized, cr.Status.IssuanceStage)
+			}
+		})
+	}
+}
+
+func TestIssueCertificateReconcileDeadline(t *testing.T) {
+	firstDomain := "api.gibberish.goes.here"
+	secondDomain := "apps.gibberish.goes.here"
+
+	testZoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-zone",
+			Namespace: testHiveNamespace,
+		},
+		Status: hivev1.DNSZoneStatus{
+			AWS: &hivev1.AWSDNSZoneStatus{
+				ZoneID: &testZoneID,
+			},
+		},
+	}
+	testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret, dnsZone})
+
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}, cr)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cr.Spec.DnsNames = []string{firstDomain, secondDomain}
+
+	fakeAcmeClient := acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{
+		Available: true,
+		NewOrderResult: acme.Order{
+			URL:            "proto://a.fake.order",
+			Authorizations: []string{"proto://first.fake.url", "proto://second.fake.url"},
+		},
+		FetchAuthorizationResults: map[string]acme.Authorization{
+			"proto://first.fake.url":  {Identifier: acme.Identifier{Value: firstDomain}},
+			"proto://second.fake.url": {Identifier: acme.Identifier{Value: secondDomain}},
+		},
+	})
+	leClient := &leclient.LetsEncryptClient{Client: fakeAcmeClient}
+
+	rcr := CertificateRequestReconciler{
+		Client:        testClient,
+		ClientBuilder: setUpFakeAWSClient,
+	}
+	// the deadline passed before the issuance started, one challenge is still answered
+	ctx := withReconcileDeadline(context.TODO(), time.Now().Add(-time.Minute))
+	err = rcr.IssueCertificate(ctx, logr.Discard(), cr, nil, &v1.Secret{}, leClient)
+	if !errors.Is(err, errReconcileDeadline) {
+		t.Fatalf("expected the reconcile deadline, got %v", err)
+	}
+
+	checkpointed := &certmanv1alpha1.CertificateRequest{}
+	err = testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}, checkpointed)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if checkpointed.Status.IssuanceStage != certmanv1alpha1.IssuanceStageChallengesPlaced || checkpointed.Status.OrderURL != "proto://a.fake.order" {
+		t.Errorf("expected the order to be checkpointed at stage %s, got %s %q", certmanv1alpha1.IssuanceStageChallengesPlaced, checkpointed.Status.IssuanceStage, checkpointed.Status.OrderURL)
+	}
+	expectedValidations := []certmanv1alpha1.DomainValidation{{Domain: firstDomain, Validated: true}}
+	if !reflect.DeepEqual(expectedValidations, checkpointed.Status.DomainValidations) {
+		t.Errorf("expected domain validations %v, got %v", expectedValidations, checkpointed.Status.DomainValidations)
+	}
+}
+
+// failingDomainDNSClient fails to answer the DNS challenge of failDomain
+type failingDomainDNSClient struct {
+	FakeAWSClient
+	failDomain string
+}
+
+func (f failingDomainDNSClient) AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeToken string, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error) {
+	if domain == f.failDomain {
+		return "", fmt.Errorf("no route to %s", domain)
+	}
+	return f.FakeAWSClient.AnswerDNSChallenge(reqLogger, acmeChallengeToken, domain, cr, dnsZone)
+}
+
+func TestIssueCertificatePartialFailure(t *testing.T) {
+	goodDomain := "api.gibberish.goes.here"
+	badDomain := "bad.gibberish.goes.here"
+
+	testZoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-zone",
+			Namespace: testHiveNamespace,
+		},
+		Status: hivev1.DNSZoneStatus{
+			AWS: &hivev1.AWSDNSZoneStatus{
+				ZoneID: &testZoneID,
+			},
+		},
+	}
+
+	testCases := []struct {
+		Name                 string
+		AllowPartialIssuance string
+		ExpectError          bool
+	}{
+		{
+			Name:                 "fails when partial issuance is not allowed",
+			AllowPartialIssuance: "false",
+			ExpectError:          true,
+		},
+		{
+			Name:                 "issues for validated domains when partial issuance is allowed",
+			AllowPartialIssuance: "true",
+			ExpectError:          false,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			operatorConfigMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.OperatorName,
+					Namespace: config.OperatorNamespace,
+				},
+				Data: map[string]string{
+					cTypes.AllowPartialIssuance: test.AllowPartialIssuance,
+				},
+			}
+			testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret, dnsZone, operatorConfigMap})
+
+			cr := &certmanv1alpha1.CertificateRequest{}
+			err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}, cr)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			cr.Spec.DnsNames = []string{goodDomain, badDomain}
+
+			fakeAcmeClient := acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{
+				Available: true,
+				NewOrderResult: acme.Order{
+					Authorizations: []string{"proto://good.fake.url", "proto://bad.fake.url"},
+				},
+				FetchAuthorizationResults: map[string]acme.Authorization{
+					"proto://good.fake.url": {Identifier: acme.Identifier{Value: goodDomain}},
+					"proto://bad.fake.url":  {Identifier: acme.Identifier{Value: badDomain}},
+				},
+			})
+			leClient := &leclient.LetsEncryptClient{Client: fakeAcmeClient}
+
+			rcr := CertificateRequestReconciler{
+				Client: testClient,
+				ClientBuilder: func(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (cClient.Client, error) {
+					return failingDomainDNSClient{failDomain: badDomain}, nil
+				},
+			}
+			err = rcr.IssueCertificate(context.TODO(), logr.Discard(), cr, nil, &v1.Secret{}, leClient)
+
+			expectedValidations := []certmanv1alpha1.DomainValidation{
+				{Domain: goodDomain, Validated: true},
+				{Domain: badDomain, Validated: false, Message: "no route to " + badDomain},
+			}
+			if !reflect.DeepEqual(expectedValidations, cr.Status.DomainValidations) {
+				t.Errorf("expected domain validations %v, got %v", expectedValidations, cr.Status.DomainValidations)
+			}
+
+			if test.ExpectError {
+				if err == nil || !strings.Contains(err.Error(), badDomain) {
+					t.Errorf("expected an error listing %s, got %v", badDomain, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			expectedIdentifiers := []acme.Identifier{{Type: "dns", Value: goodDomain}}
+			if !reflect.DeepEqual(expectedIdentifiers, fakeAcmeClient.Identifiers) {
+				t.Errorf("expected the last order for %v, got %v", expectedIdentifiers, fakeAcmeClient.Identifiers)
 			}
 		})
 	}
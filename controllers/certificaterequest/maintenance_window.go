@@ -0,0 +1,167 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/maintenance"
+)
+
+const (
+	// MaintenanceWindowAnnotation on a ClusterDeployment overrides the maintenance window of the
+	// operator configuration for the renewals of its certificates.
+	MaintenanceWindowAnnotation = "certman.managed.openshift.io/maintenance-window"
+
+	// renewalDeferredEventReason is the reason of the event emitted when a renewal is deferred
+	renewalDeferredEventReason = "RenewalDeferred"
+)
+
+// deferRenewal returns true when the renewal of the certificate of cr, which is due, is to wait
+// for the maintenance window of cd, as renewing it reloads the API servers of the cluster. The
+// RenewalDeferred condition of cr is updated accordingly.
+func (r *CertificateRequestReconciler) deferRenewal(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment, now time.Time) (bool, error) {
+	spec := utils.GetMaintenanceWindow(r.Client)
+	if cd != nil && cd.Annotations[MaintenanceWindowAnnotation] != "" {
+		spec = cd.Annotations[MaintenanceWindowAnnotation]
+	}
+
+	deferred, message := r.renewalDeferral(reqLogger, cr, spec, now)
+	wasDeferred := false
+	for _, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestRenewalDeferred {
+			wasDeferred = true
+		}
+	}
+
+	changed := false
+	if deferred {
+		reqLogger.Info(message)
+		changed = setRenewalDeferredCondition(cr, message)
+	} else {
+		changed = clearRenewalDeferred(cr)
+	}
+	if !changed {
+		return deferred, nil
+	}
+
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		return false, err
+	}
+	// the message is updated as the certificate ages, the event is only emitted once
+	if deferred && !wasDeferred && r.Recorder != nil {
+		r.Recorder.Event(cr, corev1.EventTypeNormal, renewalDeferredEventReason, message)
+	}
+	return deferred, nil
+}
+
+// renewalDeferral returns true, with the reason, when the maintenance window spec is closed at now
+// and the renewal of the certificate of cr isn't urgent. A renewal is urgent when it was requested,
+// when the certificate is missing or doesn't cover the domains of cr, and when it expires within
+// the urgent renewal days of the operator configuration. An invalid spec defers nothing.
+func (r *CertificateRequestReconciler) renewalDeferral(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, spec string, now time.Time) (bool, string) {
+	if spec == "" {
+		return false, ""
+	}
+
+	if _, ok := cr.Annotations[RenewRequestedAtAnnotation]; ok {
+		reqLogger.Info("renewing outside of the maintenance window as the renewal was requested")
+		return false, ""
+	}
+
+	window, err := maintenance.Parse(spec)
+	if err != nil {
+		reqLogger.Error(err, "ignoring the invalid maintenance window")
+		return false, ""
+	}
+	if window.Contains(now) {
+		return false, ""
+	}
+
+	certificate, err := GetCertificate(r.Client, cr)
+	if err != nil || certificate == nil {
+		return false, ""
+	}
+	for _, name := range cr.Spec.DnsNames {
+		if !utils.ContainsString(certificate.DNSNames, name) {
+			return false, ""
+		}
+	}
+
+	daysLeft := int(certificate.NotAfter.Sub(now).Hours() / 24)
+	if daysLeft <= utils.GetUrgentRenewalDays(r.Client) {
+		reqLogger.Info(fmt.Sprintf("renewing outside of the maintenance window as the certificate expires in %d days", daysLeft))
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("the renewal of the certificate, which expires in %d days, is deferred to the maintenance window %q", daysLeft, spec)
+}
+
+// setRenewalDeferredCondition sets the CertificateRequestRenewalDeferred condition of cr with
+// message. It returns true when the conditions of cr changed.
+func setRenewalDeferredCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestRenewalDeferred {
+			index = i
+			break
+		}
+	}
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "MaintenanceWindowClosed"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestRenewalDeferred,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// clearRenewalDeferred removes the CertificateRequestRenewalDeferred condition of cr. It returns
+// true when the conditions of cr changed.
+func clearRenewalDeferred(cr *certmanv1alpha1.CertificateRequest) bool {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestRenewalDeferred {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
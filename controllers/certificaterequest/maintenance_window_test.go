@@ -0,0 +1,154 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+func TestDeferRenewal(t *testing.T) {
+	// a Wednesday, while the Saturday window is closed
+	wednesday := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	saturdayWindow := "0 2 * * 6 4h"
+
+	testCases := []struct {
+		Name               string
+		Window             string
+		CDWindow           string
+		RenewalRequested   bool
+		Now                time.Time
+		AlreadyDeferred    bool
+		ExpectDeferred     bool
+		ExpectEventEmitted bool
+	}{
+		{
+			Name: "no maintenance window",
+			Now:  wednesday,
+		},
+		{
+			Name:               "closed maintenance window",
+			Window:             saturdayWindow,
+			Now:                wednesday,
+			ExpectDeferred:     true,
+			ExpectEventEmitted: true,
+		},
+		{
+			Name:            "open maintenance window",
+			Window:          saturdayWindow,
+			Now:             time.Date(2024, 1, 6, 3, 0, 0, 0, time.UTC),
+			AlreadyDeferred: true,
+		},
+		{
+			Name:     "maintenance window of the ClusterDeployment",
+			Window:   saturdayWindow,
+			CDWindow: "0 10 * * 3 4h",
+			Now:      wednesday,
+		},
+		{
+			Name:             "requested renewal",
+			Window:           saturdayWindow,
+			RenewalRequested: true,
+			Now:              wednesday,
+			AlreadyDeferred:  true,
+		},
+		{
+			Name:   "urgent renewal",
+			Window: saturdayWindow,
+			// the test certificate expires on 2121-01-30
+			Now: time.Date(2121, 1, 27, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:   "invalid maintenance window",
+			Window: "at night",
+			Now:    wednesday,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			if test.RenewalRequested {
+				cr.Annotations = map[string]string{RenewRequestedAtAnnotation: test.Now.Format(time.RFC3339)}
+			}
+			if test.AlreadyDeferred {
+				setRenewalDeferredCondition(cr, "deferred")
+			}
+			cd := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        testHiveClusterDeploymentName,
+					Namespace:   testHiveNamespace,
+					Annotations: map[string]string{MaintenanceWindowAnnotation: test.CDWindow},
+				},
+			}
+			operatorConfigMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.OperatorName,
+					Namespace: config.OperatorNamespace,
+				},
+				Data: map[string]string{
+					cTypes.MaintenanceWindow: test.Window,
+				},
+			}
+			testClient := setUpTestClient(t, []runtime.Object{cr, validCertSecret, operatorConfigMap})
+			recorder := record.NewFakeRecorder(10)
+			rcr := CertificateRequestReconciler{Client: testClient, Recorder: recorder}
+
+			current := &certmanv1alpha1.CertificateRequest{}
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}, current); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			deferred, err := rcr.deferRenewal(logr.Discard(), current, cd, test.Now)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if deferred != test.ExpectDeferred {
+				t.Errorf("expected the renewal to be deferred: %t, got %t", test.ExpectDeferred, deferred)
+			}
+
+			got := &certmanv1alpha1.CertificateRequest{}
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}, got); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			hasCondition := false
+			for _, condition := range got.Status.Conditions {
+				if condition.Type == certmanv1alpha1.CertificateRequestRenewalDeferred {
+					hasCondition = true
+				}
+			}
+			if hasCondition != test.ExpectDeferred {
+				t.Errorf("expected the RenewalDeferred condition to be set: %t", test.ExpectDeferred)
+			}
+			if emitted := len(recorder.Events) > 0; emitted != test.ExpectEventEmitted {
+				t.Errorf("expected an event to be emitted: %t", test.ExpectEventEmitted)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	// CertificateRequestSecretLabel names the CertificateRequest a certificate or CA bundle secret
+	// was written for.
+	CertificateRequestSecretLabel = "certificate_request"
+
+	// orphanedSecretCheckInterval is how often the OrphanedSecretCollector looks for orphaned secrets
+	orphanedSecretCheckInterval = time.Hour
+	// orphanedSecretGracePeriod is how old a secret must be before it is considered orphaned, so a
+	// cache lagging behind the CertificateRequests doesn't get a new secret collected
+	orphanedSecretGracePeriod = time.Hour
+)
+
+var _ manager.LeaderElectionRunnable = &OrphanedSecretCollector{}
+
+// OrphanedSecretCollector finds the secrets labeled with CertificateRequestSecretLabel whose
+// CertificateRequest no longer exists. Their owner references normally have them garbage
+// collected along with the CertificateRequest, but secrets restored from backups or stripped of
+// their owner references outlive it. Each one is reported by the
+// certman_operator_orphaned_certificate_secret metric, and deleted unless DryRun is set.
+type OrphanedSecretCollector struct {
+	Client client.Client
+	// DryRun reports the orphaned secrets without deleting them
+	DryRun bool
+	// Clock tells the age of the secrets, it defaults to the system clock
+	Clock clock.Clock
+}
+
+// now returns the current time of the Clock of c.
+func (c *OrphanedSecretCollector) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
+}
+
+// Start looks for orphaned secrets every orphanedSecretCheckInterval until ctx is done.
+func (c *OrphanedSecretCollector) Start(ctx context.Context) error {
+	ctx = logging.IntoContext(ctx, logging.FromContext(ctx).WithName("orphaned_secrets"))
+	ticker := time.NewTicker(orphanedSecretCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		c.collect(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns true, the secrets are only deleted by the leader.
+func (c *OrphanedSecretCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// collect reports, and unless in dry run deletes, the orphaned secrets.
+func (c *OrphanedSecretCollector) collect(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	now := c.now()
+	secrets := &corev1.SecretList{}
+	if err := c.Client.List(ctx, secrets, client.HasLabels{CertificateRequestSecretLabel}); err != nil {
+		logger.Error(err, "could not list certificate secrets to find the orphaned ones")
+		return
+	}
+	// forget the secrets deleted since the last check
+	localmetrics.ResetOrphanedCertificateSecrets()
+
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.DeletionTimestamp != nil {
+			continue
+		}
+
+		orphaned, err := c.orphaned(ctx, secret, now)
+		if err != nil {
+			// the next check retries
+			logger.Error(err, "could not look up the CertificateRequest of secret", "secret", logging.ObjectName(secret.Namespace, secret.Name))
+			continue
+		}
+		if orphaned {
+			orphaned = !c.delete(ctx, secret)
+		}
+		localmetrics.SetOrphanedCertificateSecret(secret.Namespace, secret.Name, orphaned)
+	}
+}
+
+// delete deletes the orphaned secret, unless in dry run. It returns true when the secret is gone.
+func (c *OrphanedSecretCollector) delete(ctx context.Context, secret *corev1.Secret) bool {
+	logger := logging.FromContext(ctx).WithValues("secret", logging.ObjectName(secret.Namespace, secret.Name),
+		logging.CertificateRequestKey, logging.ObjectName(secret.Namespace, secret.Labels[CertificateRequestSecretLabel]))
+	if c.DryRun {
+		logger.Info("dry run: not deleting orphaned certificate secret")
+		return false
+	}
+
+	logger.Info("deleting orphaned certificate secret")
+	if err := c.Client.Delete(ctx, secret); err != nil && !kerr.IsNotFound(err) {
+		logger.Error(err, "could not delete orphaned certificate secret")
+		return false
+	}
+	return true
+}
+
+// orphaned returns true when secret is older than orphanedSecretGracePeriod at now and the
+// CertificateRequest named by its label doesn't exist. Secrets whose owner references are stale
+// are left to the CertificateRequest of the same name, which adopts them.
+func (c *OrphanedSecretCollector) orphaned(ctx context.Context, secret *corev1.Secret, now time.Time) (bool, error) {
+	if secret.Labels[CertificateRequestSecretLabel] == "" || now.Sub(secret.CreationTimestamp.Time) < orphanedSecretGracePeriod {
+		return false, nil
+	}
+
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := c.Client.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Labels[CertificateRequestSecretLabel]}, cr)
+	if kerr.IsNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
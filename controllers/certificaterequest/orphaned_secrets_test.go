@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestOrphanedSecretCollector(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Name           string
+		CR             string
+		Age            time.Duration
+		DryRun         bool
+		ExpectDeleted  bool
+		ExpectReported bool
+	}{
+		{
+			Name: "secret of an existing CertificateRequest",
+			CR:   testHiveCertificateRequestName,
+			Age:  2 * time.Hour,
+		},
+		{
+			Name:          "orphaned secret",
+			CR:            "deleted-cr",
+			Age:           2 * time.Hour,
+			ExpectDeleted: true,
+		},
+		{
+			Name:           "orphaned secret in dry run",
+			CR:             "deleted-cr",
+			Age:            2 * time.Hour,
+			DryRun:         true,
+			ExpectReported: true,
+		},
+		{
+			Name: "new secret within the grace period",
+			CR:   "deleted-cr",
+			Age:  time.Minute,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         testHiveNamespace,
+					Name:              "orphan-candidate",
+					CreationTimestamp: metav1.NewTime(created),
+					Labels:            map[string]string{CertificateRequestSecretLabel: test.CR},
+				},
+			}
+			testClient := setUpTestClient(t, []runtime.Object{certRequest.DeepCopy(), secret})
+
+			c := &OrphanedSecretCollector{Client: testClient, DryRun: test.DryRun, Clock: clock.NewFake(created.Add(test.Age))}
+			c.collect(context.TODO())
+
+			err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}, &corev1.Secret{})
+			if test.ExpectDeleted && !kerr.IsNotFound(err) {
+				t.Errorf("expected the secret to be deleted, got %v", err)
+			}
+			if !test.ExpectDeleted && err != nil {
+				t.Errorf("expected the secret to be kept, got %s", err)
+			}
+
+			expectedMetric := 0
+			if test.ExpectReported {
+				expectedMetric = 1
+			}
+			if count := testutil.CollectAndCount(localmetrics.MetricOrphanedCertificateSecret); count != expectedMetric {
+				t.Errorf("expected %d orphaned secret metrics, got %d", expectedMetric, count)
+			}
+			localmetrics.ResetOrphanedCertificateSecrets()
+		})
+	}
+}
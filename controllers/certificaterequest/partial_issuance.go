@@ -0,0 +1,75 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+const (
+	// partialIssuanceRetryBackoff is how long the domains left out of a certificate wait before
+	// being validated again. It doubles with each consecutive partial issuance.
+	partialIssuanceRetryBackoff = time.Hour
+	// maxPartialIssuanceRetryBackoff caps the backoff of the domains left out of a certificate
+	maxPartialIssuanceRetryBackoff = 24 * time.Hour
+)
+
+// recordPartialIssuance records in the status of cr the DnsNames missing from certDomains, the
+// domains of the certificate being stored, and when their validation is retried. A certificate for
+// every name clears it.
+func recordPartialIssuance(cr *certmanv1alpha1.CertificateRequest, certDomains []string, now time.Time) {
+	failed := []string{}
+	for _, name := range cr.Spec.DnsNames {
+		if !utils.ContainsString(certDomains, name) {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) == 0 {
+		cr.Status.PartialIssuance = nil
+		return
+	}
+
+	attempts := 1
+	if cr.Status.PartialIssuance != nil {
+		attempts = cr.Status.PartialIssuance.Attempts + 1
+	}
+	backoff := partialIssuanceRetryBackoff
+	for i := 1; i < attempts && backoff < maxPartialIssuanceRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxPartialIssuanceRetryBackoff {
+		backoff = maxPartialIssuanceRetryBackoff
+	}
+
+	cr.Status.PartialIssuance = &certmanv1alpha1.PartialIssuance{
+		FailedDomains: failed,
+		Attempts:      attempts,
+		RetryAfter:    metav1.NewTime(now.Add(backoff)),
+	}
+}
+
+// partialIssuanceBackingOff returns true when name was left out of the certificate of cr by a
+// partial issuance whose backoff hasn't elapsed at now.
+func partialIssuanceBackingOff(cr *certmanv1alpha1.CertificateRequest, name string, now time.Time) bool {
+	partial := cr.Status.PartialIssuance
+	return partial != nil && now.Before(partial.RetryAfter.Time) && utils.ContainsString(partial.FailedDomains, name)
+}
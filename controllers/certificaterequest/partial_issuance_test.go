@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+)
+
+func TestRecordPartialIssuance(t *testing.T) {
+	now := time.Now()
+	failedDomain := "failed.gibberish.goes.here"
+
+	cr := certRequest.DeepCopy()
+	cr.Spec.DnsNames = append(cr.Spec.DnsNames, failedDomain)
+	certDomains := certRequest.Spec.DnsNames
+
+	expectedBackoffs := []time.Duration{time.Hour, 2 * time.Hour, 4 * time.Hour, 8 * time.Hour, 16 * time.Hour, 24 * time.Hour, 24 * time.Hour}
+	for i, expected := range expectedBackoffs {
+		recordPartialIssuance(cr, certDomains, now)
+
+		partial := cr.Status.PartialIssuance
+		if partial == nil {
+			t.Fatalf("attempt %d: expected the partial issuance to be recorded", i+1)
+		}
+		if partial.Attempts != i+1 {
+			t.Errorf("expected attempt %d, got %d", i+1, partial.Attempts)
+		}
+		if !reflect.DeepEqual(partial.FailedDomains, []string{failedDomain}) {
+			t.Errorf("expected failed domains %v, got %v", []string{failedDomain}, partial.FailedDomains)
+		}
+		if backoff := partial.RetryAfter.Sub(now); backoff != expected {
+			t.Errorf("attempt %d: expected a backoff of %v, got %v", i+1, expected, backoff)
+		}
+	}
+
+	recordPartialIssuance(cr, cr.Spec.DnsNames, now)
+	if cr.Status.PartialIssuance != nil {
+		t.Errorf("expected a certificate for every name to clear the partial issuance, got %v", cr.Status.PartialIssuance)
+	}
+}
+
+func TestShouldReissueAfterPartialIssuance(t *testing.T) {
+	// the certificate of validCertSecret expires on 2121-01-30
+	notAfter := time.Date(2121, 1, 30, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(notAfter.Add(-100 * 24 * time.Hour))
+
+	cr := certRequest.DeepCopy()
+	cr.Spec.DnsNames = append(cr.Spec.DnsNames, "failed.gibberish.goes.here")
+	recordPartialIssuance(cr, certRequest.Spec.DnsNames, fakeClock.Now())
+
+	testClient := setUpTestClient(t, []runtime.Object{cr, validCertSecret})
+	rcr := CertificateRequestReconciler{
+		Client:        testClient,
+		ClientBuilder: setUpFakeAWSClient,
+		Clock:         fakeClock,
+	}
+
+	got, err := rcr.ShouldReissue(logr.Discard(), cr)
+	if err != nil {
+		t.Fatalf("ShouldReissue() unexpected error: %v", err)
+	}
+	if got {
+		t.Errorf("ShouldReissue() = %v before the backoff of the failed domain elapsed, want = %v", got, false)
+	}
+
+	fakeClock.Step(partialIssuanceRetryBackoff)
+	got, err = rcr.ShouldReissue(logr.Discard(), cr)
+	if err != nil {
+		t.Fatalf("ShouldReissue() unexpected error: %v", err)
+	}
+	if !got {
+		t.Errorf("ShouldReissue() = %v once the backoff of the failed domain elapsed, want = %v", got, true)
+	}
+}
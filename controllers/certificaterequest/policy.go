@@ -0,0 +1,245 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// checkAuthorization returns true when the CertificateRequestPolicy of the operator allows the
+// operator to act on cr, its domains are under the approved base domains and its requester is
+// within its quota. Otherwise the NotAuthorized condition is set on cr.
+func (r *CertificateRequestReconciler) checkAuthorization(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment) (bool, error) {
+	policy, err := utils.GetCertificateRequestPolicy(r.Client)
+	if err != nil {
+		reqLogger.Error(err, "could not read the CertificateRequest policy")
+		return false, err
+	}
+	approvedDomains, err := utils.GetApprovedBaseDomains(r.Client)
+	if err != nil {
+		reqLogger.Error(err, "could not read the approved base domains")
+		return false, err
+	}
+
+	message, err := r.notAuthorizedMessage(policy, cr, cd)
+	if err != nil {
+		reqLogger.Error(err, "could not evaluate the CertificateRequest policy")
+		return false, err
+	}
+	if message == "" {
+		message = unapprovedDomainsMessage(approvedDomains, cr)
+	}
+	if message == "" {
+		message, err = r.requesterQuotaMessage(policy, cr)
+		if err != nil {
+			reqLogger.Error(err, "could not evaluate the quota of the requester")
+			return false, err
+		}
+	}
+
+	localmetrics.SetCertificateRequestNotAuthorized(cr.Namespace, cr.Name, message != "")
+	changed := false
+	if message == "" {
+		changed = clearNotAuthorized(cr)
+	} else {
+		reqLogger.Info(fmt.Sprintf("not reconciling: %s", message))
+		changed = setNotAuthorizedCondition(cr, message)
+	}
+	if changed {
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			reqLogger.Error(err, "could not update the NotAuthorized condition")
+			return false, err
+		}
+	}
+
+	return message == "", nil
+}
+
+// notAuthorizedMessage returns why policy doesn't allow cr, or an empty string when it does. The
+// CertificateRequests of ClusterDeployments and the canary are always allowed.
+func (r *CertificateRequestReconciler) notAuthorizedMessage(policy *certmanv1alpha1.CertificateRequestPolicy, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment) (string, error) {
+	if policy == nil || IsCanary(cr) || controlledByClusterDeployment(cr, cd) {
+		return "", nil
+	}
+
+	for _, namespace := range policy.AllowedNamespaces {
+		if namespace == cr.Namespace {
+			return "", nil
+		}
+	}
+
+	if policy.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(policy.Selector)
+		if err != nil {
+			return "", err
+		}
+		if !selector.Empty() && selector.Matches(labels.Set(cr.Labels)) {
+			return "", nil
+		}
+	}
+
+	return fmt.Sprintf("the CertificateRequest policy doesn't allow CertificateRequests in namespace %s with labels %v", cr.Namespace, labels.Set(cr.Labels)), nil
+}
+
+// controlledByClusterDeployment returns true when the controller of cr is cd, the existing Hive
+// ClusterDeployment of its namespace, with the UID of the owner reference. Anyone allowed to create
+// a CertificateRequest can set its owner references, so they don't exempt it from the policy alone.
+func controlledByClusterDeployment(cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment) bool {
+	owner := metav1.GetControllerOf(cr)
+	if cd == nil || owner == nil || owner.Kind != clusterDeploymentType {
+		return false
+	}
+	if gv, err := schema.ParseGroupVersion(owner.APIVersion); err != nil || gv.Group != hivev1.SchemeGroupVersion.Group {
+		return false
+	}
+	return cd.Name == owner.Name && cd.UID == owner.UID
+}
+
+// unapprovedDomainsMessage returns which DNS names of cr are outside of approvedDomains, or an
+// empty string when they all are under one of them or approvedDomains is empty. Unlike the
+// CertificateRequestPolicy, it applies to the CertificateRequests of ClusterDeployments and the
+// canary too, so a ClusterDeployment with a foreign base domain can't have certificates issued.
+func unapprovedDomainsMessage(approvedDomains []string, cr *certmanv1alpha1.CertificateRequest) string {
+	if len(approvedDomains) == 0 {
+		return ""
+	}
+	unapproved := utils.DomainsOutsideZones(cr.Spec.DnsNames, approvedDomains)
+	if len(unapproved) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("domains %s are not under the approved base domains %s", strings.Join(unapproved, ", "), strings.Join(approvedDomains, ", "))
+}
+
+// namespaceRequesterPrefix prefixes the namespace of the CertificateRequests without a
+// RequesterLabel, such as those of ClusterDeployments, to name the requester they count against.
+const namespaceRequesterPrefix = "namespace/"
+
+// requesterOf returns the requester cr counts against: the one named by its RequesterLabel, or its
+// namespace when it isn't labelled, so unlabelled CertificateRequests can't bypass the quotas.
+func requesterOf(cr *certmanv1alpha1.CertificateRequest) string {
+	if requester := cr.Labels[certmanv1alpha1.RequesterLabel]; requester != "" {
+		return requester
+	}
+	return namespaceRequesterPrefix + cr.Namespace
+}
+
+// requesterQuotaMessage returns why cr is beyond the quota policy sets for its requester, or an
+// empty string when it is within it. The oldest CertificateRequests of the requester are within the
+// quota, so the ones that already have certificates keep them when the quota is lowered.
+func (r *CertificateRequestReconciler) requesterQuotaMessage(policy *certmanv1alpha1.CertificateRequestPolicy, cr *certmanv1alpha1.CertificateRequest) (string, error) {
+	if policy == nil {
+		return "", nil
+	}
+	requester := requesterOf(cr)
+	quota, limited := policy.RequesterQuotas[requester]
+	if !limited {
+		return "", nil
+	}
+
+	// the CertificateRequests of a namespace requester are the unlabelled ones of the namespace
+	listOption := client.ListOption(client.MatchingLabels{certmanv1alpha1.RequesterLabel: requester})
+	if cr.Labels[certmanv1alpha1.RequesterLabel] == "" {
+		listOption = client.InNamespace(cr.Namespace)
+	}
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := r.Client.List(context.TODO(), crList, listOption); err != nil {
+		return "", err
+	}
+	requested := []certmanv1alpha1.CertificateRequest{}
+	for _, item := range crList.Items {
+		if item.DeletionTimestamp == nil && requesterOf(&item) == requester {
+			requested = append(requested, item)
+		}
+	}
+	sort.Slice(requested, func(i, j int) bool {
+		a, b := requested[i], requested[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+
+	for i := 0; i < len(requested) && i < quota; i++ {
+		if requested[i].Namespace == cr.Namespace && requested[i].Name == cr.Name {
+			return "", nil
+		}
+	}
+
+	return fmt.Sprintf("requester %s has reached its quota of %d CertificateRequests", requester, quota), nil
+}
+
+// setNotAuthorizedCondition sets the CertificateRequestNotAuthorized condition of cr with message.
+// It returns true when the conditions of cr changed.
+func setNotAuthorizedCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestNotAuthorized {
+			index = i
+			break
+		}
+	}
+
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "PolicyRejected"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestNotAuthorized,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// clearNotAuthorized removes the CertificateRequestNotAuthorized condition of cr. It returns true
+// when the conditions of cr changed.
+func clearNotAuthorized(cr *certmanv1alpha1.CertificateRequest) bool {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestNotAuthorized {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
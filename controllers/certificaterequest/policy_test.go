@@ -0,0 +1,243 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestCheckAuthorization(t *testing.T) {
+	selfService := certRequest.DeepCopy()
+	selfService.OwnerReferences = nil
+
+	labelled := selfService.DeepCopy()
+	labelled.Labels = map[string]string{"certman.managed.openshift.io/self-service": "true"}
+
+	impostorCD := clusterDeploymentComplete.DeepCopy()
+	impostorCD.UID = types.UID("another-uid")
+
+	tests := []struct {
+		name             string
+		config           map[string]string
+		cr               *certmanv1alpha1.CertificateRequest
+		objects          []runtime.Object
+		expectAuthorized bool
+	}{
+		{
+			name:             "no policy",
+			cr:               selfService,
+			expectAuthorized: true,
+		},
+		{
+			name:             "controlled by a ClusterDeployment",
+			config:           map[string]string{cTypes.AllowedNamespaces: "other"},
+			cr:               certRequest,
+			objects:          []runtime.Object{clusterDeploymentComplete},
+			expectAuthorized: true,
+		},
+		{
+			name:   "controlled by a ClusterDeployment that doesn't exist",
+			config: map[string]string{cTypes.AllowedNamespaces: "other"},
+			cr:     certRequest,
+		},
+		{
+			name:    "controlled by a ClusterDeployment of another UID",
+			config:  map[string]string{cTypes.AllowedNamespaces: "other"},
+			cr:      certRequest,
+			objects: []runtime.Object{impostorCD},
+		},
+		{
+			name:             "allowed namespace",
+			config:           map[string]string{cTypes.AllowedNamespaces: "other, " + testHiveNamespace},
+			cr:               selfService,
+			expectAuthorized: true,
+		},
+		{
+			name:             "matching labels",
+			config:           map[string]string{cTypes.CertificateRequestSelector: "certman.managed.openshift.io/self-service=true"},
+			cr:               labelled,
+			expectAuthorized: true,
+		},
+		{
+			name:   "not allowed",
+			config: map[string]string{cTypes.AllowedNamespaces: "other", cTypes.CertificateRequestSelector: "certman.managed.openshift.io/self-service=true"},
+			cr:     selfService,
+		},
+		{
+			name:   "domains outside of the approved base domains",
+			config: map[string]string{cTypes.ApprovedBaseDomains: "openshiftapps.com"},
+			cr:     certRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := append([]runtime.Object{test.cr}, test.objects...)
+			if test.config != nil {
+				objects = append(objects, &v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName, Namespace: config.OperatorNamespace},
+					Data:       test.config,
+				})
+			}
+			testClient := setUpTestClient(t, objects)
+			rcr := CertificateRequestReconciler{Client: testClient}
+
+			cr := &certmanv1alpha1.CertificateRequest{}
+			key := types.NamespacedName{Namespace: test.cr.Namespace, Name: test.cr.Name}
+			assert.NoError(t, testClient.Get(context.TODO(), key, cr))
+
+			cd, err := rcr.getClusterDeployment(logr.Discard(), cr)
+			assert.NoError(t, err)
+			authorized, err := rcr.checkAuthorization(logr.Discard(), cr, cd)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectAuthorized, authorized)
+
+			actual := &certmanv1alpha1.CertificateRequest{}
+			assert.NoError(t, testClient.Get(context.TODO(), key, actual))
+			metric := testutil.ToFloat64(localmetrics.MetricCertificateRequestNotAuthorized.WithLabelValues(cr.Namespace, cr.Name))
+			if test.expectAuthorized {
+				assert.Empty(t, actual.Status.Conditions)
+				assert.Equal(t, 0.0, metric)
+				return
+			}
+			assert.Equal(t, 1.0, metric)
+			if assert.Len(t, actual.Status.Conditions, 1) {
+				assert.Equal(t, certmanv1alpha1.CertificateRequestNotAuthorized, actual.Status.Conditions[0].Type)
+			}
+
+			// the condition is removed once the policy allows the CertificateRequest
+			cm := &v1.ConfigMap{}
+			assert.NoError(t, testClient.Get(context.TODO(), types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace}, cm))
+			cm.Data[cTypes.AllowedNamespaces] = testHiveNamespace
+			delete(cm.Data, cTypes.ApprovedBaseDomains)
+			assert.NoError(t, testClient.Update(context.TODO(), cm))
+
+			authorized, err = rcr.checkAuthorization(logr.Discard(), actual, cd)
+			assert.NoError(t, err)
+			assert.True(t, authorized)
+			assert.NoError(t, testClient.Get(context.TODO(), key, actual))
+			assert.Empty(t, actual.Status.Conditions)
+		})
+	}
+}
+
+func TestRequesterQuota(t *testing.T) {
+	requested := func(name string, created time.Time) *certmanv1alpha1.CertificateRequest {
+		cr := certRequest.DeepCopy()
+		cr.Name = name
+		cr.OwnerReferences = nil
+		cr.CreationTimestamp = metav1.NewTime(created)
+		cr.Labels = map[string]string{certmanv1alpha1.RequesterLabel: "ingress-addon-operator"}
+		return cr
+	}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldest := requested("oldest", created)
+	newest := requested("newest", created.Add(time.Hour))
+	operatorConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName, Namespace: config.OperatorNamespace},
+		Data: map[string]string{
+			cTypes.CertificateRequestSelector: certmanv1alpha1.RequesterLabel,
+			cTypes.RequesterQuotas:            "ingress-addon-operator=1, other=5",
+		},
+	}
+
+	testClient := setUpTestClient(t, []runtime.Object{oldest, newest, operatorConfigMap})
+	rcr := CertificateRequestReconciler{Client: testClient}
+
+	for _, test := range []struct {
+		cr               *certmanv1alpha1.CertificateRequest
+		expectAuthorized bool
+	}{
+		{cr: oldest, expectAuthorized: true},
+		{cr: newest, expectAuthorized: false},
+	} {
+		cr := &certmanv1alpha1.CertificateRequest{}
+		assert.NoError(t, testClient.Get(context.TODO(), types.NamespacedName{Namespace: test.cr.Namespace, Name: test.cr.Name}, cr))
+
+		cd, err := rcr.getClusterDeployment(logr.Discard(), cr)
+		assert.NoError(t, err)
+		authorized, err := rcr.checkAuthorization(logr.Discard(), cr, cd)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectAuthorized, authorized, "authorization of %s", test.cr.Name)
+	}
+
+	// the newest is within the quota once the oldest is deleted
+	assert.NoError(t, testClient.Delete(context.TODO(), oldest))
+	cr := &certmanv1alpha1.CertificateRequest{}
+	assert.NoError(t, testClient.Get(context.TODO(), types.NamespacedName{Namespace: newest.Namespace, Name: newest.Name}, cr))
+	authorized, err := rcr.checkAuthorization(logr.Discard(), cr, nil)
+	assert.NoError(t, err)
+	assert.True(t, authorized)
+	assert.Empty(t, cr.Status.Conditions)
+}
+
+func TestNamespaceRequesterQuota(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldest := certRequest.DeepCopy()
+	oldest.Name = "oldest"
+	oldest.CreationTimestamp = metav1.NewTime(created)
+	newest := certRequest.DeepCopy()
+	newest.Name = "newest"
+	newest.CreationTimestamp = metav1.NewTime(created.Add(time.Hour))
+	// a labelled CertificateRequest of the namespace counts against its own requester
+	labelled := certRequest.DeepCopy()
+	labelled.Name = "labelled"
+	labelled.CreationTimestamp = metav1.NewTime(created.Add(-time.Hour))
+	labelled.Labels = map[string]string{certmanv1alpha1.RequesterLabel: "ingress-addon-operator"}
+	operatorConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName, Namespace: config.OperatorNamespace},
+		Data: map[string]string{
+			cTypes.RequesterQuotas: namespaceRequesterPrefix + testHiveNamespace + "=1",
+		},
+	}
+
+	testClient := setUpTestClient(t, []runtime.Object{oldest, newest, labelled, clusterDeploymentComplete, operatorConfigMap})
+	rcr := CertificateRequestReconciler{Client: testClient}
+
+	for _, test := range []struct {
+		cr               *certmanv1alpha1.CertificateRequest
+		expectAuthorized bool
+	}{
+		{cr: labelled, expectAuthorized: true},
+		{cr: oldest, expectAuthorized: true},
+		{cr: newest, expectAuthorized: false},
+	} {
+		cr := &certmanv1alpha1.CertificateRequest{}
+		assert.NoError(t, testClient.Get(context.TODO(), types.NamespacedName{Namespace: test.cr.Namespace, Name: test.cr.Name}, cr))
+
+		cd, err := rcr.getClusterDeployment(logr.Discard(), cr)
+		assert.NoError(t, err)
+		authorized, err := rcr.checkAuthorization(logr.Discard(), cr, cd)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectAuthorized, authorized, "authorization of %s", test.cr.Name)
+	}
+}
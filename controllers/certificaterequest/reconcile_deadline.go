@@ -0,0 +1,61 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// errReconcileDeadline is returned by an issuance checkpointed because its reconcile ran past the
+// reconcile deadline. It isn't a failure: the next reconcile resumes the issuance.
+var errReconcileDeadline = errors.New("the issuance ran past the reconcile deadline")
+
+// reconcileDeadlineKey is the context key of the deadline of the reconcile
+type reconcileDeadlineKey struct{}
+
+// withReconcileDeadline returns a copy of ctx carrying the soft deadline of the reconcile. Unlike
+// a context deadline it cancels nothing, the issuance only checks it between its steps.
+func withReconcileDeadline(ctx context.Context, deadline time.Time) context.Context {
+	return context.WithValue(ctx, reconcileDeadlineKey{}, deadline)
+}
+
+// reconcileDeadlinePassed returns true when ctx carries a reconcile deadline that is before now.
+func reconcileDeadlinePassed(ctx context.Context, now time.Time) bool {
+	deadline, ok := ctx.Value(reconcileDeadlineKey{}).(time.Time)
+	return ok && now.After(deadline)
+}
+
+// checkpointIssuance records the progress of the issuance of cr in its status, its order and stage
+// along with the domains validated so far, so the next reconcile resumes it, and returns
+// errReconcileDeadline.
+func (r *CertificateRequestReconciler) checkpointIssuance(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
+	reqLogger.Info(fmt.Sprintf("checkpointing the issuance at stage %s: the reconcile deadline passed", cr.Status.IssuanceStage))
+	localmetrics.IncrementReconcileDeadlineExceededCount()
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		reqLogger.Error(err, "could not checkpoint the issuance")
+		return err
+	}
+	return errReconcileDeadline
+}
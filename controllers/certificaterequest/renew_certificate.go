@@ -17,11 +17,17 @@ limitations under the License.
 package certificaterequest
 
 import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/controllers/utils"
@@ -30,11 +36,7 @@ import (
 // ShouldReissue retrieves a reissueCertificateBeforeDays int and returns `true` to the caller if it is <= the expiry of the CertificateRequest.
 func (r *CertificateRequestReconciler) ShouldReissue(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (bool, error) {
 
-	reissueBeforeDays := cr.Spec.ReissueBeforeDays
-
-	if reissueBeforeDays <= 0 {
-		reissueBeforeDays = reissueCertificateBeforeDays
-	}
+	reissueBeforeDays := r.reissueBeforeDays(cr)
 
 	reqLogger.Info(fmt.Sprintf("certificate is configured to be reissued %d days before expiry", reissueBeforeDays))
 
@@ -43,13 +45,17 @@ func (r *CertificateRequestReconciler) ShouldReissue(reqLogger logr.Logger, cr *
 		return false, err
 	}
 
-	data := crtSecret.Data[corev1.TLSCertKey]
+	data := crtSecret.Data[secretKeys(cr).certificate]
 	if data == nil {
 		reqLogger.Info(fmt.Sprintf("certificate data was not found in secret %v", cr.Spec.CertificateSecret.Name))
 		return true, nil
 	}
 
 	certificate, err := ParseCertificateData(data)
+	if errors.Is(err, ErrCorruptCertificate) {
+		r.reportCorruptCertificate(reqLogger, cr, err)
+		return true, nil
+	}
 	if err != nil {
 		reqLogger.Error(err, err.Error())
 		return false, err
@@ -57,18 +63,38 @@ func (r *CertificateRequestReconciler) ShouldReissue(reqLogger logr.Logger, cr *
 
 	if certificate != nil {
 
+		var valid bool
+		reissueBeforeDays, valid = effectiveReissueBeforeDays(reissueBeforeDays, certificate)
+		if !valid {
+			reqLogger.Info(fmt.Sprintf("reissueBeforeDays is not shorter than the lifetime of the certificate, reissuing %d days before expiry instead", reissueBeforeDays))
+		}
+
 		notAfter := certificate.NotAfter
-		currentTime := time.Now().In(time.UTC)
+		currentTime := r.now().In(time.UTC)
 		timeDiff := notAfter.Sub(currentTime)
 		daysCertificateValidFor := int(timeDiff.Hours() / 24)
 		shouldReissue := daysCertificateValidFor <= reissueBeforeDays
 
 		for _, DNSName := range cr.Spec.DnsNames {
 			if !utils.ContainsString(certificate.DNSNames, DNSName) {
+				if partialIssuanceBackingOff(cr, DNSName, currentTime) {
+					reqLogger.Info(fmt.Sprintf("dnsname: %s failed validation, retrying it after %s", DNSName, cr.Status.PartialIssuance.RetryAfter))
+					continue
+				}
 				reqLogger.Info(fmt.Sprintf("dnsname: %s not found in existing cert %s", DNSName, certificate.DNSNames))
 				shouldReissue = true
 			}
 		}
+
+		if csrChangesKey(cr, certificate) {
+			reqLogger.Info("the supplied csr is for another key than the existing cert")
+			shouldReissue = true
+		}
+
+		if _, ok := cr.Annotations[RenewRequestedAtAnnotation]; ok {
+			reqLogger.Info(fmt.Sprintf("renewal was requested at %s", cr.Annotations[RenewRequestedAtAnnotation]))
+			shouldReissue = true
+		}
 		if shouldReissue {
 			reqLogger.Info(fmt.Sprintf("certificate is valid from (notBefore) %v and until (notAfter) %v and is valid for %d days and will be reissued", certificate.NotBefore.String(), certificate.NotAfter.String(), daysCertificateValidFor))
 		} else {
@@ -80,3 +106,162 @@ func (r *CertificateRequestReconciler) ShouldReissue(reqLogger logr.Logger, cr *
 
 	return false, nil
 }
+
+// issuanceTrigger returns why the certificate of cr is issued, from the certificate it replaces,
+// which is nil for the first issuance and when the stored one is corrupt.
+func issuanceTrigger(cr *certmanv1alpha1.CertificateRequest, certificate *x509.Certificate) certmanv1alpha1.IssuanceTrigger {
+	if certificate == nil {
+		if hasCertificateCorruptCondition(cr) {
+			return certmanv1alpha1.IssuanceTriggerCorruptionRecovery
+		}
+		return certmanv1alpha1.IssuanceTriggerCreate
+	}
+
+	if _, ok := cr.Annotations[RenewRequestedAtAnnotation]; ok {
+		return certmanv1alpha1.IssuanceTriggerForcedRenewal
+	}
+
+	for _, DNSName := range cr.Spec.DnsNames {
+		if !utils.ContainsString(certificate.DNSNames, DNSName) {
+			return certmanv1alpha1.IssuanceTriggerSANChange
+		}
+	}
+
+	if csrChangesKey(cr, certificate) {
+		return certmanv1alpha1.IssuanceTriggerReKey
+	}
+
+	return certmanv1alpha1.IssuanceTriggerScheduledRenewal
+}
+
+// csrChangesKey returns true when cr has a valid supplied CSR for another key than certificate, so
+// the certificate is reissued for the new key.
+func csrChangesKey(cr *certmanv1alpha1.CertificateRequest, certificate *x509.Certificate) bool {
+	csr, err := parseSuppliedCSR(cr)
+	if err != nil || csr == nil {
+		return false
+	}
+
+	publicKey, ok := csr.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && !publicKey.Equal(certificate.PublicKey)
+}
+
+// reissueBeforeDays returns how many days before expiry the certificate of cr is reissued, from
+// its spec, then the operator configuration, then reissueCertificateBeforeDays.
+func (r *CertificateRequestReconciler) reissueBeforeDays(cr *certmanv1alpha1.CertificateRequest) int {
+	reissueBeforeDays := cr.Spec.ReissueBeforeDays
+
+	if reissueBeforeDays <= 0 {
+		reissueBeforeDays = utils.GetDefaultReissueBeforeDays(r.Client)
+	}
+
+	if reissueBeforeDays <= 0 {
+		reissueBeforeDays = reissueCertificateBeforeDays
+	}
+
+	return reissueBeforeDays
+}
+
+// effectiveReissueBeforeDays returns reissueBeforeDays, and true, when it is shorter than the
+// lifetime of certificate. Otherwise the certificate would be reissued as soon as it is issued, so a
+// third of its lifetime is returned along with false.
+func effectiveReissueBeforeDays(reissueBeforeDays int, certificate *x509.Certificate) (int, bool) {
+	lifetimeDays := int(certificate.NotAfter.Sub(certificate.NotBefore).Hours() / 24)
+	if reissueBeforeDays < lifetimeDays {
+		return reissueBeforeDays, true
+	}
+
+	return lifetimeDays / reissueBeforeLifetimeDivisor, false
+}
+
+// setReissueBeforeDaysCondition sets the CertificateRequestReissueBeforeDaysInvalid condition of cr
+// when reissueBeforeDays isn't shorter than the lifetime of certificate, and removes it otherwise.
+// It returns true when the conditions of cr changed.
+func setReissueBeforeDaysCondition(cr *certmanv1alpha1.CertificateRequest, reissueBeforeDays int, certificate *x509.Certificate) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestReissueBeforeDaysInvalid {
+			index = i
+			break
+		}
+	}
+
+	effectiveDays, valid := effectiveReissueBeforeDays(reissueBeforeDays, certificate)
+	if valid {
+		if index == -1 {
+			return false
+		}
+		cr.Status.Conditions = append(cr.Status.Conditions[:index], cr.Status.Conditions[index+1:]...)
+		return true
+	}
+
+	message := fmt.Sprintf("reissueBeforeDays %d is not shorter than the lifetime of the certificate, it is reissued %d days before expiry instead", reissueBeforeDays, effectiveDays)
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "ReissueBeforeDaysExceedsLifetime"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestReissueBeforeDaysInvalid,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// renewalScheduledAt returns the time from which ShouldReissue reissues a certificate expiring at
+// notAfter. Whole days of validity are compared to reissueBeforeDays, so the certificate is due as
+// soon as less than reissueBeforeDays+1 days are left.
+func renewalScheduledAt(notAfter time.Time, reissueBeforeDays int) time.Time {
+	return notAfter.Add(-time.Duration(reissueBeforeDays+1) * 24 * time.Hour)
+}
+
+// annotateCertificateSecret sets the NotAfterAnnotation and RenewalScheduledAtAnnotation of the
+// secret holding certificate, so its freshness can be checked without parsing the certificate.
+// The secret is only patched when the annotations change.
+func (r *CertificateRequestReconciler) annotateCertificateSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, certificate *x509.Certificate) error {
+	secret, err := GetSecret(r.Client, cr.Spec.CertificateSecret.Name, cr.Namespace)
+	if err != nil {
+		return err
+	}
+
+	notAfter := certificate.NotAfter.UTC().Format(time.RFC3339)
+	reissueBeforeDays, _ := effectiveReissueBeforeDays(r.reissueBeforeDays(cr), certificate)
+	renewalAt := renewalScheduledAt(certificate.NotAfter, reissueBeforeDays).UTC().Format(time.RFC3339)
+	if secret.Annotations[NotAfterAnnotation] == notAfter && secret.Annotations[RenewalScheduledAtAnnotation] == renewalAt {
+		return nil
+	}
+
+	reqLogger.Info(fmt.Sprintf("annotating secret %v with not-after %v and renewal-scheduled-at %v", secret.Name, notAfter, renewalAt))
+	baseToPatch := client.MergeFrom(secret.DeepCopy())
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[NotAfterAnnotation] = notAfter
+	secret.Annotations[RenewalScheduledAtAnnotation] = renewalAt
+
+	return r.Client.Patch(context.TODO(), secret, baseToPatch)
+}
+
+// clearRenewalRequest removes the RenewRequestedAtAnnotation of cr once its certificate is reissued.
+func (r *CertificateRequestReconciler) clearRenewalRequest(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
+	if _, ok := cr.Annotations[RenewRequestedAtAnnotation]; !ok {
+		return nil
+	}
+
+	reqLogger.Info("certificate was reissued as requested, removing the renewal request")
+	baseToPatch := client.MergeFrom(cr.DeepCopy())
+	delete(cr.Annotations, RenewRequestedAtAnnotation)
+	return r.Client.Patch(context.TODO(), cr, baseToPatch)
+}
@@ -17,12 +17,21 @@ limitations under the License.
 package certificaterequest
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
 )
 
 func TestShouldReissue(t *testing.T) {
@@ -61,3 +70,244 @@ func TestShouldReissue(t *testing.T) {
 	}
 
 }
+
+func TestShouldReissueRequestedRenewal(t *testing.T) {
+	renewalRequested := certRequest.DeepCopy()
+	renewalRequested.Annotations = map[string]string{RenewRequestedAtAnnotation: "2021-02-24T00:00:00Z"}
+
+	testClient := setUpTestClient(t, []runtime.Object{renewalRequested, validCertSecret})
+	rcr := CertificateRequestReconciler{
+		Client:        testClient,
+		ClientBuilder: setUpFakeAWSClient,
+	}
+
+	got, err := rcr.ShouldReissue(logr.Discard(), renewalRequested)
+	if err != nil {
+		t.Fatalf("ShouldReissue() unexpected error: %v", err)
+	}
+	if !got {
+		t.Errorf("ShouldReissue() = %v, want = %v", got, true)
+	}
+}
+
+func TestShouldReissueAsCertificateAges(t *testing.T) {
+	// the certificate of validCertSecret expires on 2121-01-30
+	notAfter := time.Date(2121, 1, 30, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(notAfter.Add(-100 * 24 * time.Hour))
+
+	testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret})
+	rcr := CertificateRequestReconciler{
+		Client:        testClient,
+		ClientBuilder: setUpFakeAWSClient,
+		Clock:         fakeClock,
+	}
+
+	got, err := rcr.ShouldReissue(logr.Discard(), certRequest)
+	if err != nil {
+		t.Fatalf("ShouldReissue() unexpected error: %v", err)
+	}
+	if got {
+		t.Errorf("ShouldReissue() = %v 100 days before expiry, want = %v", got, false)
+	}
+
+	fakeClock.Step(90 * 24 * time.Hour)
+	got, err = rcr.ShouldReissue(logr.Discard(), certRequest)
+	if err != nil {
+		t.Fatalf("ShouldReissue() unexpected error: %v", err)
+	}
+	if !got {
+		t.Errorf("ShouldReissue() = %v 10 days before expiry, want = %v", got, true)
+	}
+}
+
+func TestShouldReissueCorruptCertificate(t *testing.T) {
+	data := validCertSecret.Data[v1.TLSCertKey]
+	tests := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "truncated certificate", data: data[:len(data)/2]},
+		{desc: "no PEM data", data: []byte("edited by hand")},
+		{desc: "private key", data: leAccountPrivKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			secret := validCertSecret.DeepCopy()
+			secret.Data[v1.TLSCertKey] = test.data
+
+			testClient := setUpTestClient(t, []runtime.Object{cr, secret})
+			rcr := CertificateRequestReconciler{
+				Client:        testClient,
+				ClientBuilder: setUpFakeAWSClient,
+			}
+
+			got, err := rcr.ShouldReissue(logr.Discard(), cr)
+			if err != nil {
+				t.Fatalf("ShouldReissue() unexpected error: %v", err)
+			}
+			if !got {
+				t.Errorf("ShouldReissue() = %v, want = %v", got, true)
+			}
+
+			actual := &certmanv1alpha1.CertificateRequest{}
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}, actual); err != nil {
+				t.Fatalf("unexpected error getting certificate request: %s", err)
+			}
+			if !hasCertificateCorruptCondition(actual) {
+				t.Errorf("expected the %s condition, got %v", certmanv1alpha1.CertificateRequestCertificateCorrupt, actual.Status.Conditions)
+			}
+			if trigger := issuanceTrigger(actual, nil); trigger != certmanv1alpha1.IssuanceTriggerCorruptionRecovery {
+				t.Errorf("issuanceTrigger() = %s, want = %s", trigger, certmanv1alpha1.IssuanceTriggerCorruptionRecovery)
+			}
+
+			recoveries := testutil.ToFloat64(localmetrics.MetricCorruptCertificateRecoveryCount)
+			clearCertificateCorrupt(actual)
+			if hasCertificateCorruptCondition(actual) {
+				t.Errorf("expected the %s condition to be removed", certmanv1alpha1.CertificateRequestCertificateCorrupt)
+			}
+			if got := testutil.ToFloat64(localmetrics.MetricCorruptCertificateRecoveryCount); got != recoveries+1 {
+				t.Errorf("expected %v corrupt certificate recoveries, got %v", recoveries+1, got)
+			}
+		})
+	}
+}
+
+func TestIssuanceTrigger(t *testing.T) {
+	block, _ := pem.Decode(validCertSecret.Data[v1.TLSCertKey])
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	forced := certRequest.DeepCopy()
+	forced.Annotations = map[string]string{RenewRequestedAtAnnotation: "2021-02-24T00:00:00Z"}
+	sanChange := certRequest.DeepCopy()
+	sanChange.Spec.DnsNames = append(sanChange.Spec.DnsNames, "*.apps.gibberish.goes.here")
+	reKey := certRequest.DeepCopy()
+	reKey.Spec.CSR = testCSR(t, "api.gibberish.goes.here", "api.gibberish.goes.here")
+
+	tests := []struct {
+		desc        string
+		cr          *certmanv1alpha1.CertificateRequest
+		certificate *x509.Certificate
+		want        certmanv1alpha1.IssuanceTrigger
+	}{
+		{desc: "no certificate", cr: certRequest, want: certmanv1alpha1.IssuanceTriggerCreate},
+		{desc: "certificate nearing expiry", cr: certRequest, certificate: certificate, want: certmanv1alpha1.IssuanceTriggerScheduledRenewal},
+		{desc: "requested renewal", cr: forced, certificate: certificate, want: certmanv1alpha1.IssuanceTriggerForcedRenewal},
+		{desc: "new DNS name", cr: sanChange, certificate: certificate, want: certmanv1alpha1.IssuanceTriggerSANChange},
+		{desc: "csr of a new key", cr: reKey, certificate: certificate, want: certmanv1alpha1.IssuanceTriggerReKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := issuanceTrigger(test.cr, test.certificate); got != test.want {
+				t.Errorf("issuanceTrigger() = %s, want = %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAnnotateCertificateSecret(t *testing.T) {
+	testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret})
+	rcr := CertificateRequestReconciler{
+		Client:        testClient,
+		ClientBuilder: setUpFakeAWSClient,
+	}
+
+	certificate, err := GetCertificate(testClient, certRequest)
+	if err != nil {
+		t.Fatalf("GetCertificate() unexpected error: %v", err)
+	}
+
+	err = rcr.annotateCertificateSecret(logr.Discard(), certRequest, certificate)
+	if err != nil {
+		t.Fatalf("annotateCertificateSecret() unexpected error: %v", err)
+	}
+
+	secret, err := GetSecret(testClient, testHiveSecretName, testHiveNamespace)
+	if err != nil {
+		t.Fatalf("GetSecret() unexpected error: %v", err)
+	}
+
+	// validCertSecret expires on 2121-01-30 21:31:08 UTC and certRequest is reissued 10 days before expiry
+	expected := map[string]string{
+		NotAfterAnnotation:           "2121-01-30T21:31:08Z",
+		RenewalScheduledAtAnnotation: "2121-01-19T21:31:08Z",
+	}
+	for annotation, value := range expected {
+		if secret.Annotations[annotation] != value {
+			t.Errorf("annotateCertificateSecret() %v = %v, want = %v", annotation, secret.Annotations[annotation], value)
+		}
+	}
+}
+
+func TestSetReissueBeforeDaysCondition(t *testing.T) {
+	notBefore := time.Date(2021, time.February, 23, 21, 31, 8, 0, time.UTC)
+	certificate := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(90 * 24 * time.Hour)}
+
+	tests := []struct {
+		desc              string
+		reissueBeforeDays int
+		wantDays          int
+		wantCondition     bool
+	}{
+		{
+			desc:              "reissueBeforeDays shorter than the lifetime is used",
+			reissueBeforeDays: 45,
+			wantDays:          45,
+		},
+		{
+			desc:              "reissueBeforeDays longer than the lifetime is replaced",
+			reissueBeforeDays: 10000,
+			wantDays:          30,
+			wantCondition:     true,
+		},
+		{
+			desc:              "reissueBeforeDays equal to the lifetime is replaced",
+			reissueBeforeDays: 90,
+			wantDays:          30,
+			wantCondition:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			days, _ := effectiveReissueBeforeDays(test.reissueBeforeDays, certificate)
+			if days != test.wantDays {
+				t.Errorf("effectiveReissueBeforeDays() = %v, want = %v", days, test.wantDays)
+			}
+
+			cr := certRequest.DeepCopy()
+			changed := setReissueBeforeDaysCondition(cr, test.reissueBeforeDays, certificate)
+			if changed != test.wantCondition {
+				t.Errorf("setReissueBeforeDaysCondition() = %v, want = %v", changed, test.wantCondition)
+			}
+			if hasCondition(cr, certmanv1alpha1.CertificateRequestReissueBeforeDaysInvalid) != test.wantCondition {
+				t.Errorf("condition %v set = %v, want = %v", certmanv1alpha1.CertificateRequestReissueBeforeDaysInvalid, !test.wantCondition, test.wantCondition)
+			}
+
+			// setting the condition again changes nothing
+			if setReissueBeforeDaysCondition(cr, test.reissueBeforeDays, certificate) {
+				t.Errorf("setReissueBeforeDaysCondition() changed the conditions twice")
+			}
+
+			// a valid reissueBeforeDays clears the condition
+			changed = setReissueBeforeDaysCondition(cr, 10, certificate)
+			if changed != test.wantCondition || hasCondition(cr, certmanv1alpha1.CertificateRequestReissueBeforeDaysInvalid) {
+				t.Errorf("setReissueBeforeDaysCondition() didn't clear the condition")
+			}
+		})
+	}
+}
+
+func hasCondition(cr *certmanv1alpha1.CertificateRequest, conditionType certmanv1alpha1.CertificateRequestConditionType) bool {
+	for _, condition := range cr.Status.Conditions {
+		if condition.Type == conditionType {
+			return true
+		}
+	}
+	return false
+}
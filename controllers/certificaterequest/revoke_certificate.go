@@ -17,15 +17,26 @@ limitations under the License.
 package certificaterequest
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/go-logr/logr"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
 	"github.com/openshift/certman-operator/pkg/leclient"
 )
 
+// certificateIssuer returns the ACME issuer of the certificate of cr. Certificates stored without
+// their issuer were issued by the issuer of the spec.
+func certificateIssuer(cr *certmanv1alpha1.CertificateRequest) *certmanv1alpha1.ACMEIssuerReference {
+	if cr.Status.CertificateIssuer != nil {
+		return cr.Status.CertificateIssuer
+	}
+	return cr.Spec.IssuerRef
+}
+
 // RevokeCertificate validates which letsencrypt endpoint is to be used along with corresponding account.
 // Then revokes certificate upon matching the CommonName of LetsEncryptCertIssuingAuthority.
 // Associated ACME challenge resources are also removed.
@@ -36,7 +47,7 @@ func (r *CertificateRequestReconciler) RevokeCertificate(reqLogger logr.Logger,
 		reqLogger.Error(err, err.Error())
 		return err
 	}
-	leClient, err := leclient.NewClient(r.Client)
+	leClient, err := leclient.NewIssuerClient(r.Client, certificateIssuer(cr))
 	if err != nil {
 		reqLogger.Error(err, "failed to get letsencrypt client")
 		return err
@@ -60,8 +71,12 @@ func (r *CertificateRequestReconciler) RevokeCertificate(reqLogger logr.Logger,
 	}
 
 	err = dnsClient.DeleteAcmeChallengeResourceRecords(reqLogger, cr)
-	if err != nil {
-		reqLogger.Error(err, "error occurred deleting acme challenge resource records.")
+	if errors.Is(err, cTypes.ErrZoneNotFound) {
+		skipDNSCleanup(reqLogger, challengeRecordsCleanup, zoneNotFound, fmt.Sprintf("not deleting acme challenge resource records: %v", err))
+	} else if err != nil {
+		// the finalizer is retried rather than leaving the records behind
+		failDNSCleanup(reqLogger, challengeRecordsCleanup, err, "error occurred deleting acme challenge resource records")
+		return fmt.Errorf("could not delete the acme challenge resource records: %w", err)
 	}
 
 	return nil
@@ -20,7 +20,13 @@ import (
 	"testing"
 
 	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
 )
 
 func TestRevokeCertificate(t *testing.T) {
@@ -40,3 +46,78 @@ func TestRevokeCertificate(t *testing.T) {
 		}
 	})
 }
+
+func TestCertificateIssuer(t *testing.T) {
+	specIssuer := &certmanv1alpha1.ACMEIssuerReference{Environment: certmanv1alpha1.ACMEEnvironmentProduction, AccountSecretName: "production-account"}
+	issuedBy := &certmanv1alpha1.ACMEIssuerReference{Environment: certmanv1alpha1.ACMEEnvironmentStaging, AccountSecretName: "staging-account"}
+
+	cr := certRequest.DeepCopy()
+	cr.Spec.IssuerRef = specIssuer
+	if issuer := certificateIssuer(cr); issuer != specIssuer {
+		t.Errorf("expected a certificate stored without its issuer to be revoked through the issuer of the spec, got %v", issuer)
+	}
+
+	// the issuer of the spec changed since the certificate was issued
+	cr.Status.CertificateIssuer = issuedBy
+	if issuer := certificateIssuer(cr); issuer != issuedBy {
+		t.Errorf("expected the certificate to be revoked through the issuer that issued it, got %v", issuer)
+	}
+}
+
+func TestRevokeCertificateAndDeleteSecret(t *testing.T) {
+	disabled := false
+	enabled := true
+
+	testCases := []struct {
+		Name           string
+		ConfigValue    string
+		RevokeOnDelete *bool
+		ExpectRevoke   bool
+	}{
+		{
+			Name:         "revokes by default",
+			ExpectRevoke: true,
+		},
+		{
+			Name:        "skips revocation disabled by the operator configuration",
+			ConfigValue: "false",
+		},
+		{
+			Name:           "skips revocation disabled by the CertificateRequest",
+			RevokeOnDelete: &disabled,
+		},
+		{
+			Name:           "revokes when the CertificateRequest overrides the operator configuration",
+			ConfigValue:    "false",
+			RevokeOnDelete: &enabled,
+			ExpectRevoke:   true,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			operatorConfigMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.OperatorName,
+					Namespace: config.OperatorNamespace,
+				},
+				Data: map[string]string{
+					cTypes.RevokeOnDelete: test.ConfigValue,
+				},
+			}
+			rcr := CertificateRequestReconciler{
+				Client:        setUpTestClient(t, []runtime.Object{certRequest, validCertSecret, operatorConfigMap}),
+				ClientBuilder: setUpFakeAWSClient,
+			}
+
+			cr := certRequest.DeepCopy()
+			cr.Spec.RevokeOnDelete = test.RevokeOnDelete
+
+			// the lets-encrypt account secret is missing, so attempting the revocation fails
+			err := rcr.revokeCertificateAndDeleteSecret(logr.Discard(), cr)
+			if test.ExpectRevoke != (err != nil) {
+				t.Errorf("expected revocation %t, got error %v", test.ExpectRevoke, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const secretAdoptedEventReason = "CertificateSecretAdopted"
+
+// the kinds of secrets of a CertificateRequest whose owner references are repaired
+const (
+	certificateSecretKind = "certificate"
+	caBundleSecretKind    = "ca_bundle"
+)
+
+// adoptRestoredSecret makes cr the controller of its certificate secret again when the secret is
+// owned by a CertificateRequest of the same name with another UID. This happens when a namespace is
+// restored from a backup, by Velero or OADP for instance, or when cr is deleted and created again:
+// the new objects get new UIDs while the owner references keep the old ones, and the garbage
+// collector would delete the secret for its missing owner. The certificate of an adopted secret is
+// reissued when the secret doesn't hold a certificate matching its private key. It returns true
+// when secret was adopted.
+func (r *CertificateRequestReconciler) adoptRestoredSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, secret *corev1.Secret) (bool, error) {
+	adopted, err := r.repairOwnerReferences(reqLogger, cr, secret, certificateSecretKind)
+	if err != nil || !adopted {
+		return false, err
+	}
+	if r.Recorder != nil {
+		r.Recorder.Event(cr, corev1.EventTypeNormal, secretAdoptedEventReason, fmt.Sprintf("adopted the certificate secret %s of a previous CertificateRequest", secret.Name))
+	}
+
+	if err := validateSecretData(cr, secret); err != nil {
+		reqLogger.Info(fmt.Sprintf("reissuing the certificate of the adopted secret: %v", err))
+		if err := r.requestRenewal(cr); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// adoptCABundleSecret repairs the owner references of the CA bundle secret named by the
+// CABundleSecretAnnotation of certificateSecret, like adoptRestoredSecret does for the certificate
+// secret.
+func (r *CertificateRequestReconciler) adoptCABundleSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, certificateSecret *corev1.Secret) error {
+	name, ok := certificateSecret.Annotations[CABundleSecretAnnotation]
+	if !ok {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: name}, secret); err != nil {
+		if kerr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	_, err := r.repairOwnerReferences(reqLogger, cr, secret, caBundleSecretKind)
+	return err
+}
+
+// repairOwnerReferences removes the owner references of secret to CertificateRequests with the
+// name of cr and another UID, and makes cr an owner of secret in their place: its controller,
+// unless secret is controlled by another object. Secrets without such references are left as they
+// are. It returns true when secret was repaired.
+func (r *CertificateRequestReconciler) repairOwnerReferences(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, secret *corev1.Secret, kind string) (bool, error) {
+	ownerReferences := []metav1.OwnerReference{}
+	staleUIDs := []string{}
+	for _, ref := range secret.OwnerReferences {
+		if ref.Kind == certificateRequestType && ref.Name == cr.Name && ref.UID != cr.UID {
+			staleUIDs = append(staleUIDs, string(ref.UID))
+			continue
+		}
+		ownerReferences = append(ownerReferences, ref)
+	}
+	if len(staleUIDs) == 0 {
+		return false, nil
+	}
+
+	reqLogger.Info(fmt.Sprintf("repairing the owner references of secret %s, which point at the CertificateRequest with the previous UID %s", secret.Name, strings.Join(staleUIDs, ", ")))
+	secret.OwnerReferences = ownerReferences
+	owned := false
+	for _, ref := range ownerReferences {
+		owned = owned || ref.UID == cr.UID
+	}
+	var err error
+	switch {
+	case owned:
+		// cr is already an owner of secret
+	case metav1.GetControllerOf(secret) == nil:
+		err = controllerutil.SetControllerReference(cr, secret, r.Scheme)
+	default:
+		err = controllerutil.SetOwnerReference(cr, secret, r.Scheme)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := r.Client.Update(context.TODO(), secret); err != nil {
+		return false, err
+	}
+	localmetrics.IncrementSecretOwnerReferenceRepairCount(kind)
+	return true, nil
+}
+
+// validateSecretData returns an error when secret doesn't hold a certificate under the keys of cr,
+// along with its private key unless the key of cr is kept outside of the cluster.
+func validateSecretData(cr *certmanv1alpha1.CertificateRequest, secret *corev1.Secret) error {
+	keys := secretKeys(cr)
+
+	certificate := secret.Data[keys.certificate]
+	if block, _ := pem.Decode(certificate); block == nil {
+		return fmt.Errorf("%s does not hold a PEM encoded certificate", keys.certificate)
+	}
+	if _, err := ParseCertificateData(certificate); err != nil {
+		return fmt.Errorf("could not parse %s: %w", keys.certificate, err)
+	}
+
+	// csrChangesKey compares a certificate to the key of a supplied CSR
+	if cr.Spec.CSR != "" {
+		return nil
+	}
+	if _, err := tls.X509KeyPair(certificate, secret.Data[keys.privateKey]); err != nil {
+		return fmt.Errorf("%s does not match %s: %w", keys.privateKey, keys.certificate, err)
+	}
+	return nil
+}
+
+// requestRenewal sets the RenewRequestedAtAnnotation of cr, unless a renewal is already requested.
+func (r *CertificateRequestReconciler) requestRenewal(cr *certmanv1alpha1.CertificateRequest) error {
+	if _, ok := cr.Annotations[RenewRequestedAtAnnotation]; ok {
+		return nil
+	}
+
+	baseToPatch := client.MergeFrom(cr.DeepCopy())
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	cr.Annotations[RenewRequestedAtAnnotation] = r.now().UTC().Format(time.RFC3339)
+	return r.Client.Patch(context.TODO(), cr, baseToPatch)
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// testKeyPair returns a PEM encoded self-signed certificate for dnsNames and its private key.
+func testKeyPair(t *testing.T, dnsNames ...string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestAdoptRestoredSecret(t *testing.T) {
+	certificate, key := testKeyPair(t, certRequest.Spec.DnsNames...)
+	_, otherKey := testKeyPair(t, certRequest.Spec.DnsNames...)
+
+	controllerReference := func(kind, name string, uid types.UID) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{
+			APIVersion: certmanv1alpha1.GroupVersion.String(),
+			Kind:       kind,
+			Name:       name,
+			UID:        uid,
+			Controller: boolPointer(true),
+		}}
+	}
+
+	testCases := []struct {
+		Name            string
+		OwnerReferences []metav1.OwnerReference
+		PrivateKey      []byte
+		ExpectAdopted   bool
+		ExpectRenewal   bool
+	}{
+		{
+			Name:            "restored secret",
+			OwnerReferences: controllerReference(certificateRequestType, certRequest.Name, "backed-up-uid"),
+			PrivateKey:      key,
+			ExpectAdopted:   true,
+		},
+		{
+			Name:            "restored secret with a mismatched private key",
+			OwnerReferences: controllerReference(certificateRequestType, certRequest.Name, "backed-up-uid"),
+			PrivateKey:      otherKey,
+			ExpectAdopted:   true,
+			ExpectRenewal:   true,
+		},
+		{
+			Name:            "restored secret without a private key",
+			OwnerReferences: controllerReference(certificateRequestType, certRequest.Name, "backed-up-uid"),
+			ExpectAdopted:   true,
+			ExpectRenewal:   true,
+		},
+		{
+			Name:            "secret controlled by the CertificateRequest",
+			OwnerReferences: controllerReference(certificateRequestType, certRequest.Name, "current-uid"),
+			PrivateKey:      key,
+		},
+		{
+			Name:       "secret without a controller",
+			PrivateKey: key,
+		},
+		{
+			Name:            "secret controlled by another CertificateRequest",
+			OwnerReferences: controllerReference(certificateRequestType, "another", "backed-up-uid"),
+			PrivateKey:      key,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			cr.UID = "current-uid"
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       testHiveNamespace,
+					Name:            testHiveSecretName,
+					OwnerReferences: test.OwnerReferences,
+				},
+				Type: v1.SecretTypeTLS,
+				Data: map[string][]byte{
+					v1.TLSCertKey:       certificate,
+					v1.TLSPrivateKeyKey: test.PrivateKey,
+				},
+			}
+			testClient := setUpTestClient(t, []runtime.Object{cr, secret})
+			rcr := CertificateRequestReconciler{
+				Client:        testClient,
+				ClientBuilder: setUpFakeAWSClient,
+				Scheme:        scheme.Scheme,
+			}
+
+			found := &v1.Secret{}
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveSecretName}, found); err != nil {
+				t.Fatalf("unexpected error getting secret: %s", err)
+			}
+			adopted, err := rcr.adoptRestoredSecret(logr.Discard(), cr, found)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if adopted != test.ExpectAdopted {
+				t.Errorf("expected adopted %t, got %t", test.ExpectAdopted, adopted)
+			}
+
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveSecretName}, found); err != nil {
+				t.Fatalf("unexpected error getting secret: %s", err)
+			}
+			owner := metav1.GetControllerOf(found)
+			if test.ExpectAdopted && (owner == nil || owner.UID != cr.UID || len(found.OwnerReferences) != 1) {
+				t.Errorf("expected the secret to be controlled by the CertificateRequest only, owner references: %v", found.OwnerReferences)
+			}
+			if !test.ExpectAdopted && len(found.OwnerReferences) != len(test.OwnerReferences) {
+				t.Errorf("expected the owner references to be left untouched, got %v", found.OwnerReferences)
+			}
+
+			actual := &certmanv1alpha1.CertificateRequest{}
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: testHiveCertificateRequestName}, actual); err != nil {
+				t.Fatalf("unexpected error getting certificate request: %s", err)
+			}
+			if _, renewal := actual.Annotations[RenewRequestedAtAnnotation]; renewal != test.ExpectRenewal {
+				t.Errorf("expected renewal requested %t, annotations: %v", test.ExpectRenewal, actual.Annotations)
+			}
+		})
+	}
+}
+
+func TestRepairOwnerReferences(t *testing.T) {
+	ownerReference := func(kind, name string, uid types.UID, controller bool) metav1.OwnerReference {
+		return metav1.OwnerReference{
+			APIVersion: certmanv1alpha1.GroupVersion.String(),
+			Kind:       kind,
+			Name:       name,
+			UID:        uid,
+			Controller: boolPointer(controller),
+		}
+	}
+
+	testCases := []struct {
+		Name             string
+		OwnerReferences  []metav1.OwnerReference
+		ExpectRepaired   bool
+		ExpectController types.UID
+	}{
+		{
+			Name:             "stale owner reference",
+			OwnerReferences:  []metav1.OwnerReference{ownerReference(certificateRequestType, certRequest.Name, "deleted-uid", false)},
+			ExpectRepaired:   true,
+			ExpectController: "current-uid",
+		},
+		{
+			Name: "stale owner reference of a secret controlled by another object",
+			OwnerReferences: []metav1.OwnerReference{
+				ownerReference("ClusterDeployment", "cluster", "cluster-uid", true),
+				ownerReference(certificateRequestType, certRequest.Name, "deleted-uid", false),
+			},
+			ExpectRepaired:   true,
+			ExpectController: "cluster-uid",
+		},
+		{
+			Name: "stale and current owner references",
+			OwnerReferences: []metav1.OwnerReference{
+				ownerReference(certificateRequestType, certRequest.Name, "current-uid", true),
+				ownerReference(certificateRequestType, certRequest.Name, "deleted-uid", false),
+			},
+			ExpectRepaired:   true,
+			ExpectController: "current-uid",
+		},
+		{
+			Name:             "current owner reference",
+			OwnerReferences:  []metav1.OwnerReference{ownerReference(certificateRequestType, certRequest.Name, "current-uid", true)},
+			ExpectController: "current-uid",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			cr.UID = "current-uid"
+			secret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:       testHiveNamespace,
+					Name:            caBundleSecretName(cr),
+					OwnerReferences: test.OwnerReferences,
+				},
+			}
+			certificateSecret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   testHiveNamespace,
+					Name:        testHiveSecretName,
+					Annotations: map[string]string{CABundleSecretAnnotation: caBundleSecretName(cr)},
+				},
+			}
+			testClient := setUpTestClient(t, []runtime.Object{cr, secret, certificateSecret})
+			rcr := CertificateRequestReconciler{
+				Client:        testClient,
+				ClientBuilder: setUpFakeAWSClient,
+				Scheme:        scheme.Scheme,
+			}
+
+			repairs := testutil.ToFloat64(localmetrics.MetricSecretOwnerReferenceRepairCount.WithLabelValues(caBundleSecretKind))
+			if err := rcr.adoptCABundleSecret(logr.Discard(), cr, certificateSecret); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			found := &v1.Secret{}
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: testHiveNamespace, Name: caBundleSecretName(cr)}, found); err != nil {
+				t.Fatalf("unexpected error getting secret: %s", err)
+			}
+			for _, ref := range found.OwnerReferences {
+				if ref.Kind == certificateRequestType && ref.UID != cr.UID {
+					t.Errorf("expected the stale owner references to be removed, got %v", found.OwnerReferences)
+				}
+			}
+			if owner := metav1.GetControllerOf(found); owner == nil || owner.UID != test.ExpectController {
+				t.Errorf("expected the secret to be controlled by %s, owner references: %v", test.ExpectController, found.OwnerReferences)
+			}
+
+			expectedRepairs := repairs
+			if test.ExpectRepaired {
+				expectedRepairs++
+			}
+			if actual := testutil.ToFloat64(localmetrics.MetricSecretOwnerReferenceRepairCount.WithLabelValues(caBundleSecretKind)); actual != expectedRepairs {
+				t.Errorf("expected %v repairs, got %v", expectedRepairs, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	// CABundleSecretAnnotation is set on certificate secrets whose CA bundle didn't fit alongside the
+	// certificate, to the name of the secret holding it
+	CABundleSecretAnnotation = "certman.managed.openshift.io/ca-bundle-secret"
+
+	caBundleSecretSuffix = "-ca-bundle"
+)
+
+// maxCertificateSecretSize is the size the apiserver allows for the data of a secret
+var maxCertificateSecretSize = corev1.MaxSecretSize
+
+// errCertificateSecretTooLarge is returned when an issued certificate doesn't fit in its secret
+var errCertificateSecretTooLarge = errors.New("certificate secret is too large")
+
+// secretDataSize returns the size of the data of secret as the apiserver counts it.
+func secretDataSize(secret *corev1.Secret) int {
+	size := 0
+	for _, value := range secret.Data {
+		size += len(value)
+	}
+	return size
+}
+
+// caBundleSecretName returns the name of the secret the CA bundle of cr is moved to when it doesn't
+// fit in the certificate secret.
+func caBundleSecretName(cr *certmanv1alpha1.CertificateRequest) string {
+	return cr.Spec.CertificateSecret.Name + caBundleSecretSuffix
+}
+
+// fitCertificateSecret makes sure the data of certificateSecret can be stored. When it is larger
+// than the apiserver allows, the CA bundle of the CertificateSecretTemplate of cr is moved to a
+// separate secret, named by the CABundleSecretAnnotation of certificateSecret. When the certificate
+// and its private key still don't fit, the CertificateSecretTooLarge condition is set on cr and an
+// error is returned, instead of letting the apiserver reject the secret.
+func (r *CertificateRequestReconciler) fitCertificateSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, certificateSecret *corev1.Secret) error {
+	size := secretDataSize(certificateSecret)
+	caKey := secretKeys(cr).ca
+
+	if size <= maxCertificateSecretSize {
+		delete(certificateSecret.Annotations, CABundleSecretAnnotation)
+		if err := r.deleteCABundleSecret(reqLogger, cr); err != nil {
+			return err
+		}
+		clearCertificateSecretTooLarge(cr)
+		localmetrics.SetCertificateSecretSize(cr.Namespace, cr.Name, size)
+		return nil
+	}
+
+	reqLogger.Info(fmt.Sprintf("certificate secret data is %d bytes, more than the %d bytes allowed", size, maxCertificateSecretSize))
+
+	caBundle, ok := certificateSecret.Data[caKey]
+	if caKey != "" && ok && size-len(caBundle) <= maxCertificateSecretSize {
+		if err := r.writeCABundleSecret(reqLogger, cr, caKey, caBundle); err != nil {
+			return err
+		}
+		delete(certificateSecret.Data, caKey)
+		if certificateSecret.Annotations == nil {
+			certificateSecret.Annotations = map[string]string{}
+		}
+		certificateSecret.Annotations[CABundleSecretAnnotation] = caBundleSecretName(cr)
+		clearCertificateSecretTooLarge(cr)
+		localmetrics.SetCertificateSecretSize(cr.Namespace, cr.Name, size-len(caBundle))
+		return nil
+	}
+
+	message := fmt.Sprintf("the data of certificate secret %s is %d bytes, more than the %d bytes a secret can hold", cr.Spec.CertificateSecret.Name, size, maxCertificateSecretSize)
+	if caKey != "" {
+		message += fmt.Sprintf(" even without its CA bundle %s", caKey)
+	}
+	message += ", request fewer DNS names"
+	if setCertificateSecretTooLargeCondition(cr, message) {
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			reqLogger.Error(err, "could not set the certificate secret too large condition")
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errCertificateSecretTooLarge, message)
+}
+
+// writeCABundleSecret stores caBundle under caKey in the CA bundle secret of cr.
+func (r *CertificateRequestReconciler) writeCABundleSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, caKey string, caBundle []byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      caBundleSecretName(cr),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				CertificateRequestSecretLabel: cr.Name,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{caKey: caBundle},
+	}
+	if err := controllerutil.SetControllerReference(cr, secret, r.Scheme); err != nil {
+		return err
+	}
+
+	reqLogger.Info(fmt.Sprintf("storing the CA bundle in secret %s/%s", secret.Namespace, secret.Name))
+	err := r.Client.Create(context.TODO(), secret)
+	if kerr.IsAlreadyExists(err) {
+		err = r.Client.Update(context.TODO(), secret)
+	}
+	return err
+}
+
+// deleteCABundleSecret deletes the CA bundle secret of cr, once the CA bundle fits in the
+// certificate secret again.
+func (r *CertificateRequestReconciler) deleteCABundleSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
+	exists, err := SecretExists(r.Client, caBundleSecretName(cr), cr.Namespace)
+	if err != nil || !exists {
+		return err
+	}
+
+	reqLogger.Info(fmt.Sprintf("deleting CA bundle secret %s/%s", cr.Namespace, caBundleSecretName(cr)))
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: caBundleSecretName(cr), Namespace: cr.Namespace}}
+	if err := r.Client.Delete(context.TODO(), secret); err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// setCertificateSecretTooLargeCondition sets the CertificateRequestCertificateSecretTooLarge
+// condition of cr with message. It returns true when the conditions of cr changed.
+func setCertificateSecretTooLargeCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestCertificateSecretTooLarge {
+			index = i
+			break
+		}
+	}
+
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "SecretSizeLimitExceeded"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestCertificateSecretTooLarge,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// clearCertificateSecretTooLarge removes the CertificateRequestCertificateSecretTooLarge condition
+// of cr, which is stored with the rest of the status once the certificate is issued.
+func clearCertificateSecretTooLarge(cr *certmanv1alpha1.CertificateRequest) {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestCertificateSecretTooLarge {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestFitCertificateSecret(t *testing.T) {
+	defer func(max int) {
+		maxCertificateSecretSize = max
+	}(maxCertificateSecretSize)
+	maxCertificateSecretSize = 100
+
+	chain, issuer, key := make([]byte, 40), make([]byte, 30), make([]byte, 40)
+
+	staleCABundle := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testHiveNamespace, Name: testHiveSecretName + caBundleSecretSuffix},
+	}
+
+	tests := []struct {
+		name           string
+		template       *certmanv1alpha1.CertificateSecretTemplate
+		chain          []byte
+		objects        []runtime.Object
+		expectErr      bool
+		expectCABundle bool
+	}{
+		{
+			name:     "fits",
+			template: &certmanv1alpha1.CertificateSecretTemplate{Type: v1.SecretTypeOpaque, CAKey: "ca.crt"},
+			chain:    chain[:10],
+			objects:  []runtime.Object{staleCABundle},
+		},
+		{
+			name:           "CA bundle moved to its own secret",
+			template:       &certmanv1alpha1.CertificateSecretTemplate{Type: v1.SecretTypeOpaque, CAKey: "ca.crt"},
+			chain:          chain,
+			expectCABundle: true,
+		},
+		{
+			name:      "too large without CA bundle",
+			chain:     make([]byte, 80),
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			cr.Spec.CertificateSecretTemplate = test.template
+			testClient := setUpTestClient(t, append(test.objects, cr))
+			rcr := CertificateRequestReconciler{Client: testClient, Scheme: scheme.Scheme}
+
+			secret := newSecret(cr)
+			setCertificateSecretData(secret, cr, test.chain, issuer, key)
+
+			err := rcr.fitCertificateSecret(logr.Discard(), cr, secret)
+			if test.expectErr {
+				assert.True(t, errors.Is(err, errCertificateSecretTooLarge), "expected a too large secret, got %v", err)
+				if assert.Len(t, cr.Status.Conditions, 1) {
+					assert.Equal(t, certmanv1alpha1.CertificateRequestCertificateSecretTooLarge, cr.Status.Conditions[0].Type)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, cr.Status.Conditions)
+			assert.LessOrEqual(t, secretDataSize(secret), maxCertificateSecretSize)
+
+			caBundle := &v1.Secret{}
+			err = testClient.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: caBundleSecretName(cr)}, caBundle)
+			if !test.expectCABundle {
+				assert.True(t, kerr.IsNotFound(err), "expected no CA bundle secret, got %v", err)
+				assert.Equal(t, issuer, secret.Data["ca.crt"])
+				assert.Empty(t, secret.Annotations[CABundleSecretAnnotation])
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, issuer, caBundle.Data["ca.crt"])
+			assert.NotContains(t, secret.Data, "ca.crt")
+			assert.Equal(t, caBundleSecretName(cr), secret.Annotations[CABundleSecretAnnotation])
+		})
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	// DefaultStalledThreshold is how long a CertificateRequest may exist without a certificate
+	// before it is reported as stalled, when the StalledWatchdog doesn't set one
+	DefaultStalledThreshold = 2 * time.Hour
+	// stalledCheckInterval is how often the StalledWatchdog checks the CertificateRequests
+	stalledCheckInterval = 5 * time.Minute
+	// stalledEventReason is the reason of the event emitted when a CertificateRequest stalls
+	stalledEventReason = "Stalled"
+)
+
+var _ manager.LeaderElectionRunnable = &StalledWatchdog{}
+
+// StalledWatchdog reports the CertificateRequests that have existed for longer than Threshold
+// without ever being issued a certificate, such as those of clusters whose provisioning failed
+// early enough that no reconcile reports an error anymore. Each one gets the Stalled condition,
+// a certman_operator_certificate_request_stalled metric and a warning event.
+type StalledWatchdog struct {
+	Client   client.Client
+	Recorder record.EventRecorder
+	// Threshold defaults to DefaultStalledThreshold
+	Threshold time.Duration
+}
+
+// Start checks the CertificateRequests every stalledCheckInterval until ctx is done.
+func (w *StalledWatchdog) Start(ctx context.Context) error {
+	ctx = logging.IntoContext(ctx, logging.FromContext(ctx).WithName("stalled"))
+	ticker := time.NewTicker(stalledCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		w.check(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns true, the CertificateRequests are only updated by the leader.
+func (w *StalledWatchdog) NeedLeaderElection() bool {
+	return true
+}
+
+// check sets the Stalled condition of the CertificateRequests that stalled at now, and removes it
+// from those that were issued a certificate since.
+func (w *StalledWatchdog) check(ctx context.Context, now time.Time) {
+	threshold := w.Threshold
+	if threshold <= 0 {
+		threshold = DefaultStalledThreshold
+	}
+
+	logger := logging.FromContext(ctx)
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := w.Client.List(ctx, crList); err != nil {
+		logger.Error(err, "could not list CertificateRequests to find the stalled ones")
+		return
+	}
+
+	for i := range crList.Items {
+		cr := &crList.Items[i]
+		if cr.DeletionTimestamp != nil {
+			continue
+		}
+
+		age := now.Sub(cr.CreationTimestamp.Time)
+		stalled := !cr.Status.Issued && age > threshold
+		localmetrics.SetCertificateRequestStalled(cr.Namespace, cr.Name, stalled)
+
+		changed := false
+		if stalled {
+			message := fmt.Sprintf("no certificate was issued in the %v since the CertificateRequest was created", age.Truncate(time.Minute))
+			changed = setStalledCondition(cr, message)
+			if changed && w.Recorder != nil {
+				w.Recorder.Event(cr, corev1.EventTypeWarning, stalledEventReason, message)
+			}
+		} else {
+			changed = clearStalled(cr)
+		}
+		if !changed {
+			continue
+		}
+
+		if err := w.Client.Status().Update(ctx, cr); err != nil {
+			// the next check retries
+			logger.Error(err, "could not update the Stalled condition", logging.CertificateRequestKey, logging.ObjectName(cr.Namespace, cr.Name))
+		}
+	}
+}
+
+// setStalledCondition sets the CertificateRequestStalled condition of cr with message, unless it is
+// already set. The message isn't updated as the CertificateRequest ages. It returns true when the
+// conditions of cr changed.
+func setStalledCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	for _, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestStalled {
+			return false
+		}
+	}
+
+	now := metav1.Now()
+	reason := "NeverIssued"
+	cr.Status.Conditions = append(cr.Status.Conditions, certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestStalled,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	})
+
+	return true
+}
+
+// clearStalled removes the CertificateRequestStalled condition of cr. It returns true when the
+// conditions of cr changed.
+func clearStalled(cr *certmanv1alpha1.CertificateRequest) bool {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestStalled {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestStalledWatchdog(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Name          string
+		Issued        bool
+		Age           time.Duration
+		ExpectStalled bool
+	}{
+		{
+			Name: "new CertificateRequest",
+			Age:  time.Hour,
+		},
+		{
+			Name:          "CertificateRequest never issued past the threshold",
+			Age:           3 * time.Hour,
+			ExpectStalled: true,
+		},
+		{
+			Name:   "issued CertificateRequest past the threshold",
+			Issued: true,
+			Age:    3 * time.Hour,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.Name, func(t *testing.T) {
+			cr := certRequest.DeepCopy()
+			cr.CreationTimestamp = metav1.NewTime(created)
+			cr.Status.Issued = test.Issued
+			testClient := setUpTestClient(t, []runtime.Object{cr})
+			recorder := record.NewFakeRecorder(10)
+
+			w := &StalledWatchdog{Client: testClient, Recorder: recorder}
+			w.check(context.TODO(), created.Add(test.Age))
+			// a second check doesn't repeat the event
+			w.check(context.TODO(), created.Add(test.Age+time.Minute))
+
+			got := &certmanv1alpha1.CertificateRequest{}
+			if err := testClient.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}, got); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			stalled := false
+			for _, condition := range got.Status.Conditions {
+				if condition.Type == certmanv1alpha1.CertificateRequestStalled {
+					stalled = true
+				}
+			}
+			if stalled != test.ExpectStalled {
+				t.Errorf("expected stalled condition %t, got %t", test.ExpectStalled, stalled)
+			}
+
+			expectedEvents := 0
+			expectedMetric := 0
+			if test.ExpectStalled {
+				expectedEvents, expectedMetric = 1, 1
+			}
+			if len(recorder.Events) != expectedEvents {
+				t.Errorf("expected %d events, got %d", expectedEvents, len(recorder.Events))
+			}
+			if count := testutil.CollectAndCount(localmetrics.MetricCertificateRequestStalled); count != expectedMetric {
+				t.Errorf("expected %d stalled metrics, got %d", expectedMetric, count)
+			}
+			localmetrics.SetCertificateRequestStalled(cr.Namespace, cr.Name, false)
+		})
+	}
+}
+
+func TestStalledConditionIsClearedOnceIssued(t *testing.T) {
+	cr := certRequest.DeepCopy()
+	setStalledCondition(cr, "no certificate was issued")
+
+	if setStalledCondition(cr, "no certificate was issued in a while") {
+		t.Errorf("expected an existing stalled condition to be left unchanged")
+	}
+	if !clearStalled(cr) {
+		t.Errorf("expected the stalled condition to be removed")
+	}
+	if clearStalled(cr) {
+		t.Errorf("expected no change without a stalled condition")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+const (
+	// syncSetSuffix is appended to the name of a CertificateRequest to name its SyncSet
+	syncSetSuffix = "-certificate"
+	// spokeCertificateNamespace is the namespace of the spoke cluster certificates are synced to
+	spokeCertificateNamespace = "openshift-config"
+
+	// SyncSetHashAnnotation is set on the SyncSets of certificates to the hash of the spec they were
+	// last written with, as the embedded secret isn't returned byte for byte by the apiserver.
+	SyncSetHashAnnotation = "certman.managed.openshift.io/syncset-hash"
+)
+
+// syncSetName returns the name of the SyncSet distributing the certificate of cr.
+func syncSetName(cr *certmanv1alpha1.CertificateRequest) string {
+	return cr.Name + syncSetSuffix
+}
+
+// syncCertificateSecret makes the SyncSet of cr embed certificateSecret, targeted at the
+// openshift-config namespace of the cluster clusterDeploymentName, when the operator configuration
+// enables it. The SyncSet is updated with each renewal and, being owned by cr, removed with it.
+// It is deleted when the configuration doesn't enable it.
+func (r *CertificateRequestReconciler) syncCertificateSecret(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, clusterDeploymentName string, certificateSecret *corev1.Secret) error {
+	existing := &hivev1.SyncSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: syncSetName(cr)}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	if !utils.SyncCertificatesToClusters(r.Client) || clusterDeploymentName == "" {
+		if !found {
+			return nil
+		}
+		reqLogger.Info("deleting the SyncSet of the certificate as syncing certificates to clusters is disabled")
+		if err := r.Client.Delete(context.TODO(), existing); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	spec, err := newSyncSetSpec(clusterDeploymentName, certificateSecret)
+	if err != nil {
+		return err
+	}
+	hash, err := syncSetSpecHash(spec)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		syncSet := &hivev1.SyncSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            syncSetName(cr),
+				Namespace:       cr.Namespace,
+				Annotations:     map[string]string{SyncSetHashAnnotation: hash},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cr, certmanv1alpha1.GroupVersion.WithKind(certificateRequestType))},
+			},
+			Spec: spec,
+		}
+		reqLogger.Info("creating the SyncSet of the certificate")
+		return r.Client.Create(context.TODO(), syncSet)
+	}
+
+	if existing.Annotations[SyncSetHashAnnotation] == hash {
+		return nil
+	}
+	reqLogger.Info("updating the SyncSet of the certificate")
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	existing.Annotations[SyncSetHashAnnotation] = hash
+	existing.Spec = spec
+	return r.Client.Update(context.TODO(), existing)
+}
+
+// syncSetSpecHash returns the hex encoded sha256 of spec.
+func syncSetSpecHash(spec hivev1.SyncSetSpec) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newSyncSetSpec returns the spec of a SyncSet applying a copy of certificateSecret to the
+// openshift-config namespace of the cluster clusterDeploymentName. The copy is deleted from the
+// cluster when the SyncSet is deleted.
+func newSyncSetSpec(clusterDeploymentName string, certificateSecret *corev1.Secret) (hivev1.SyncSetSpec, error) {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      certificateSecret.Name,
+			Namespace: spokeCertificateNamespace,
+		},
+		Type: certificateSecret.Type,
+		Data: certificateSecret.Data,
+	}
+
+	raw, err := json.Marshal(secret)
+	if err != nil {
+		return hivev1.SyncSetSpec{}, err
+	}
+
+	return hivev1.SyncSetSpec{
+		SyncSetCommonSpec: hivev1.SyncSetCommonSpec{
+			Resources:         []runtime.RawExtension{{Raw: raw}},
+			ResourceApplyMode: hivev1.SyncResourceApplyMode,
+		},
+		ClusterDeploymentRefs: []corev1.LocalObjectReference{{Name: clusterDeploymentName}},
+	}, nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+func TestSyncCertificateSecret(t *testing.T) {
+	operatorConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.OperatorName,
+			Namespace: config.OperatorNamespace,
+		},
+		Data: map[string]string{
+			cTypes.SyncCertificatesToClusters: "true",
+		},
+	}
+	syncSetKey := types.NamespacedName{Namespace: certRequest.Namespace, Name: syncSetName(certRequest)}
+
+	testClient := setUpTestClient(t, []runtime.Object{certRequest, validCertSecret, operatorConfigMap})
+	rcr := CertificateRequestReconciler{Client: testClient}
+
+	secret := validCertSecret.DeepCopy()
+	if err := rcr.syncCertificateSecret(logr.Discard(), certRequest, testHiveClusterDeploymentName, secret); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	syncSet := &hivev1.SyncSet{}
+	if err := testClient.Get(context.TODO(), syncSetKey, syncSet); err != nil {
+		t.Fatalf("expected the SyncSet to be created: %s", err)
+	}
+	if len(syncSet.Spec.ClusterDeploymentRefs) != 1 || syncSet.Spec.ClusterDeploymentRefs[0].Name != testHiveClusterDeploymentName {
+		t.Errorf("expected the SyncSet to target %s, got %v", testHiveClusterDeploymentName, syncSet.Spec.ClusterDeploymentRefs)
+	}
+	if syncSet.Spec.ResourceApplyMode != hivev1.SyncResourceApplyMode {
+		t.Errorf("expected resource apply mode %s, got %s", hivev1.SyncResourceApplyMode, syncSet.Spec.ResourceApplyMode)
+	}
+	if len(syncSet.OwnerReferences) != 1 || syncSet.OwnerReferences[0].Name != certRequest.Name {
+		t.Errorf("expected the SyncSet to be owned by the CertificateRequest, got %v", syncSet.OwnerReferences)
+	}
+
+	embedded := embeddedSecret(t, syncSet)
+	if embedded.Namespace != spokeCertificateNamespace || embedded.Name != secret.Name {
+		t.Errorf("expected the secret %s/%s, got %s/%s", spokeCertificateNamespace, secret.Name, embedded.Namespace, embedded.Name)
+	}
+
+	// a renewal updates the embedded secret
+	secret.Data[v1.TLSCertKey] = []byte("renewed")
+	if err := rcr.syncCertificateSecret(logr.Discard(), certRequest, testHiveClusterDeploymentName, secret); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := testClient.Get(context.TODO(), syncSetKey, syncSet); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(embeddedSecret(t, syncSet).Data[v1.TLSCertKey]) != "renewed" {
+		t.Errorf("expected the SyncSet to embed the renewed certificate")
+	}
+
+	// disabling the sync deletes the SyncSet
+	operatorConfigMap.Data[cTypes.SyncCertificatesToClusters] = "false"
+	if err := testClient.Update(context.TODO(), operatorConfigMap); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := rcr.syncCertificateSecret(logr.Discard(), certRequest, testHiveClusterDeploymentName, secret); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := testClient.Get(context.TODO(), syncSetKey, syncSet); !errors.IsNotFound(err) {
+		t.Errorf("expected the SyncSet to be deleted, got %v", err)
+	}
+}
+
+// embeddedSecret returns the secret embedded in syncSet.
+func embeddedSecret(t *testing.T, syncSet *hivev1.SyncSet) *v1.Secret {
+	t.Helper()
+
+	if len(syncSet.Spec.Resources) != 1 {
+		t.Fatalf("expected the SyncSet to embed one resource, got %d", len(syncSet.Spec.Resources))
+	}
+	secret := &v1.Secret{}
+	if err := json.Unmarshal(syncSet.Spec.Resources[0].Raw, secret); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return secret
+}
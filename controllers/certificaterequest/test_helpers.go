@@ -44,6 +44,7 @@ var testHiveCertificateRequestName = fmt.Sprintf("%s-primary-cert-bundle", testH
 var testHiveSecretName = "primary-cert-bundle-secret" //#nosec - G101: Potential hardcoded credentials
 var testHiveACMEDomain = "not.a.valid.tld"
 var testHiveFedRampZoneID = "Z10091REDACTEDW6I"
+var testZoneNameservers = []string{"ns-1.awsdns-01.com.", "ns-2.awsdns-02.net."}
 
 var clusterDeploymentIncoming = &hivev1.ClusterDeployment{
 	TypeMeta: metav1.TypeMeta{
@@ -212,6 +213,13 @@ var testLESecret = &corev1.Secret{
 	},
 }
 
+// mockLESecret returns a copy of testLESecret whose account is served by the mock ACME client
+func mockLESecret() *corev1.Secret {
+	secret := testLESecret.DeepCopy()
+	secret.Data["account-url"] = []byte("proto://use.mock.acme.client")
+	return secret
+}
+
 /*
 Mock certman-operator/pkg/client/aws
 The fake AWS client implements the certman-operator/pkg/clients.Client interface
@@ -237,6 +245,30 @@ func (f FakeAWSClient) DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger,
 	return nil
 }
 
+func (f FakeAWSClient) DeleteAcmeChallengeResourceRecord(reqLogger logr.Logger, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) error {
+	return nil
+}
+
+func (f FakeAWSClient) VerifyRecordVisible(reqLogger logr.Logger, fqdn string, value string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (bool, error) {
+	return true, nil
+}
+
+func (f FakeAWSClient) GetAuthoritativeZone(reqLogger logr.Logger, fqdn string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error) {
+	return dnsZone, nil
+}
+
+func (f FakeAWSClient) GetZoneNameservers(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string) ([]string, error) {
+	return testZoneNameservers, nil
+}
+
+func (f FakeAWSClient) EnsureZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	return nil
+}
+
+func (f FakeAWSClient) DeleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	return nil
+}
+
 func (f FakeAWSClient) ValidateDNSWriteAccess(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (bool, error) {
 	return true, nil
 }
@@ -248,13 +280,14 @@ func setUpFakeAWSClient(reqLogger logr.Logger, kubeClient client.Client, platfro
 
 // setUpTestClient sets up a test kube client loaded with the provided cloud
 // account secret and runtime objects (certificaterequest, secret, etc)
-func setUpTestClient(t *testing.T, objects []runtime.Object) client.Client {
+func setUpTestClient(t testing.TB, objects []runtime.Object) client.Client {
 	t.Helper()
 
 	s := scheme.Scheme
-	s.AddKnownTypes(certmanv1alpha1.GroupVersion, certRequest)
-	s.AddKnownTypes(hivev1.SchemeGroupVersion, clusterDeploymentComplete)
+	s.AddKnownTypes(certmanv1alpha1.GroupVersion, certRequest, &certmanv1alpha1.CertificateRequestList{})
+	s.AddKnownTypes(hivev1.SchemeGroupVersion, clusterDeploymentComplete, &hivev1.ClusterDeploymentList{})
 	s.AddKnownTypes(hivev1.SchemeGroupVersion, &hivev1.DNSZoneList{})
 	s.AddKnownTypes(hivev1.SchemeGroupVersion, &hivev1.DNSZone{})
+	s.AddKnownTypes(hivev1.SchemeGroupVersion, &hivev1.SyncSet{}, &hivev1.SyncSetList{})
 	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objects...).WithStatusSubresource(certRequest).Build()
 }
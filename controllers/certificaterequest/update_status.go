@@ -18,6 +18,7 @@ package certificaterequest
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"strings"
 	"time"
@@ -26,11 +27,17 @@ import (
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/pkg/localmetrics"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// maxIssuanceHistory is the number of issuances kept in the IssuanceHistory of a CertificateRequest
+const maxIssuanceHistory = 10
+
 // updateStatus attempts to retrieve a certificate and check its Issued state. If not Issued,
-// the required CertificateRequest variables are populated and updated.
-func (r *CertificateRequestReconciler) updateStatus(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
+// the required CertificateRequest variables are populated and updated, and the issuance is
+// counted for the ACME environment. A new certificate is added to the issuance history with
+// trigger, which is inferred from the status when it is empty.
+func (r *CertificateRequestReconciler) updateStatus(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, environment certmanv1alpha1.ACMEEnvironment, trigger certmanv1alpha1.IssuanceTrigger) error {
 	if cr == nil {
 		return fmt.Errorf("CertificateRequest is nil")
 	}
@@ -40,25 +47,36 @@ func (r *CertificateRequestReconciler) updateStatus(reqLogger logr.Logger, cr *c
 
 	certificate, err := GetCertificate(r.Client, cr)
 	if err != nil {
-		localmetrics.UpdateCertValidDuration(r.Client, nil, time.Now(), cr.Namespace, cr.Namespace)
+		localmetrics.UpdateCertValidDuration(r.Client, nil, r.now(), cr.Namespace, cr.Namespace)
 		return err
 	}
 
 	if certificate == nil {
-		localmetrics.UpdateCertValidDuration(r.Client, nil, time.Now(), cr.Namespace, cr.Namespace)
+		localmetrics.UpdateCertValidDuration(r.Client, nil, r.now(), cr.Namespace, cr.Namespace)
 		return fmt.Errorf("no certificate found for %s/%s", cr.Namespace, cr.Name)
 	}
 
 	// Certificate exists, update metrics and status
-	localmetrics.UpdateCertValidDuration(r.Client, certificate, time.Now(), clusterName, cr.Namespace)
+	localmetrics.UpdateCertValidDuration(r.Client, certificate, r.now(), clusterName, cr.Namespace)
 	reqLogger.Info("metrics for UpdateCertValidDuration updated")
+	localmetrics.RecordCertificateIssuance(cr.Namespace, cr.Name, cr.Spec.DnsNames, certificate.NotBefore)
+
+	if err := r.annotateCertificateSecret(reqLogger, cr, certificate); err != nil {
+		reqLogger.Error(err, "could not annotate the certificate secret")
+		return err
+	}
 
-	if !cr.Status.Issued ||
+	issued := !cr.Status.Issued ||
 		cr.Status.IssuerName != certificate.Issuer.CommonName ||
 		cr.Status.NotBefore != certificate.NotBefore.String() ||
 		cr.Status.NotAfter != certificate.NotAfter.String() ||
-		cr.Status.SerialNumber != certificate.SerialNumber.String() {
+		cr.Status.SerialNumber != certificate.SerialNumber.String()
+	conditionsChanged := setReissueBeforeDaysCondition(cr, r.reissueBeforeDays(cr), certificate)
 
+	if issued || conditionsChanged {
+		if cr.Status.SerialNumber != certificate.SerialNumber.String() {
+			recordIssuance(cr, certificate, trigger, r.now())
+		}
 		cr.Status.Issued = true
 		cr.Status.IssuerName = certificate.Issuer.CommonName
 		cr.Status.NotBefore = certificate.NotBefore.String()
@@ -71,12 +89,36 @@ func (r *CertificateRequestReconciler) updateStatus(reqLogger logr.Logger, cr *c
 			reqLogger.Error(err, "Failed to update CertificateRequest status")
 			return err
 		}
-		localmetrics.AddCertificateIssuance("issue")
+		if issued {
+			localmetrics.AddCertificateIssuance("issue", string(environment))
+		}
 	}
 
 	return nil
 }
 
+// recordIssuance adds certificate to the issuance history of cr, dropping the oldest entries beyond
+// maxIssuanceHistory. An empty trigger is a scheduled renewal, or the creation when cr had no
+// certificate.
+func recordIssuance(cr *certmanv1alpha1.CertificateRequest, certificate *x509.Certificate, trigger certmanv1alpha1.IssuanceTrigger, now time.Time) {
+	if trigger == "" {
+		trigger = certmanv1alpha1.IssuanceTriggerScheduledRenewal
+		if cr.Status.SerialNumber == "" {
+			trigger = certmanv1alpha1.IssuanceTriggerCreate
+		}
+	}
+
+	cr.Status.IssuanceHistory = append(cr.Status.IssuanceHistory, certmanv1alpha1.CertificateIssuance{
+		Time:         metav1.NewTime(now),
+		SerialNumber: certificate.SerialNumber.String(),
+		NotAfter:     certificate.NotAfter.String(),
+		Trigger:      trigger,
+	})
+	if len(cr.Status.IssuanceHistory) > maxIssuanceHistory {
+		cr.Status.IssuanceHistory = cr.Status.IssuanceHistory[len(cr.Status.IssuanceHistory)-maxIssuanceHistory:]
+	}
+}
+
 // Function for handling a generic ACME error from cert issuer.
 // Function will add a condition to the CertificateRequest with the return body from issuing cert request.
 func acmeError(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, err error) (certmanv1alpha1.CertificateRequestCondition, error) {
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestRecordIssuance(t *testing.T) {
+	block, _ := pem.Decode(validCertSecret.Data[v1.TLSCertKey])
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("infers the trigger from the status", func(t *testing.T) {
+		cr := &certmanv1alpha1.CertificateRequest{}
+		recordIssuance(cr, certificate, "", now)
+
+		cr.Status.SerialNumber = certificate.SerialNumber.String()
+		recordIssuance(cr, certificate, "", now)
+
+		if len(cr.Status.IssuanceHistory) != 2 {
+			t.Fatalf("expected 2 issuances, got %d", len(cr.Status.IssuanceHistory))
+		}
+		if trigger := cr.Status.IssuanceHistory[0].Trigger; trigger != certmanv1alpha1.IssuanceTriggerCreate {
+			t.Errorf("expected trigger %s, got %s", certmanv1alpha1.IssuanceTriggerCreate, trigger)
+		}
+		if trigger := cr.Status.IssuanceHistory[1].Trigger; trigger != certmanv1alpha1.IssuanceTriggerScheduledRenewal {
+			t.Errorf("expected trigger %s, got %s", certmanv1alpha1.IssuanceTriggerScheduledRenewal, trigger)
+		}
+	})
+
+	t.Run("keeps the last issuances", func(t *testing.T) {
+		cr := &certmanv1alpha1.CertificateRequest{}
+		for i := 0; i < maxIssuanceHistory+3; i++ {
+			issued := *certificate
+			issued.SerialNumber = big.NewInt(int64(i))
+			recordIssuance(cr, &issued, certmanv1alpha1.IssuanceTriggerForcedRenewal, now.Add(time.Duration(i)*time.Hour))
+		}
+
+		if len(cr.Status.IssuanceHistory) != maxIssuanceHistory {
+			t.Fatalf("expected %d issuances, got %d", maxIssuanceHistory, len(cr.Status.IssuanceHistory))
+		}
+		if serial := cr.Status.IssuanceHistory[0].SerialNumber; serial != "3" {
+			t.Errorf("expected the oldest issuances to be dropped, first serial is %s", serial)
+		}
+		last := cr.Status.IssuanceHistory[maxIssuanceHistory-1]
+		if last.SerialNumber != fmt.Sprint(maxIssuanceHistory+2) || last.Trigger != certmanv1alpha1.IssuanceTriggerForcedRenewal {
+			t.Errorf("expected the last issuance to be the forced one, got %+v", last)
+		}
+	})
+}
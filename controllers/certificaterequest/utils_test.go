@@ -1,14 +1,14 @@
 package certificaterequest
 
 import (
-	"context"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/certman-operator/pkg/testutils"
 )
 
 func TestSecretExists(t *testing.T) {
@@ -45,7 +45,7 @@ func TestSecretExists(t *testing.T) {
 			name: "Error occurred",
 			setupClient: func() client.Client {
 				// Create a fake client that will always return an error
-				return &errorClient{fake.NewClientBuilder().Build()}
+				return testutils.NewFaultClient(fake.NewClientBuilder().Build(), testutils.Fault{Verb: testutils.Get})
 			},
 			expectedExist: false,
 			expectedErr:   true,
@@ -67,12 +67,3 @@ func TestSecretExists(t *testing.T) {
 		})
 	}
 }
-
-// errorClient is a wrapper around the fake client that always returns an error on Get
-type errorClient struct {
-	client.Client
-}
-
-func (e *errorClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
-	return runtime.NewMissingKindErr("Error for testing")
-}
@@ -0,0 +1,153 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	cClient "github.com/openshift/certman-operator/pkg/clients"
+)
+
+// zoneDelegationGracePeriod is how long after the creation of the DNSZone of a cluster issuance
+// waits for the public DNS to delegate the base domain to it
+const zoneDelegationGracePeriod = 30 * time.Minute
+
+// errZoneDelegationPending is returned while the public DNS doesn't delegate the base domain of a
+// CertificateRequest to its newly created DNS zone
+var errZoneDelegationPending = errors.New("the delegation of the base domain to its new DNS zone has not propagated yet")
+
+// checkZoneDelegation fails with errZoneDelegationPending while the DNSZone of the cluster of cr was
+// created less than zoneDelegationGracePeriod ago and the public DNS doesn't delegate the base domain
+// of cr to its nameservers yet. Hive creates the zone moments before the first issuance, and the
+// challenge records written to it would not be seen by Let's Encrypt until the NS records of the
+// zone show at its parent. Past the grace period issuance goes ahead and the delegation is left to
+// checkDelegation. The ZoneDelegationPending condition of cr reports the wait.
+func (r *CertificateRequestReconciler) checkZoneDelegation(reqLogger logr.Logger, dnsClient cClient.Client, cr *certmanv1alpha1.CertificateRequest) error {
+	// The canary and FedRAMP clusters use long lived zones rather than a DNSZone of their own
+	if IsCanary(cr) || r.Fedramp.Enabled {
+		r.clearZoneDelegationPending(reqLogger, cr)
+		return nil
+	}
+
+	dnsZones := hivev1.DNSZoneList{}
+	if err := r.Client.List(context.TODO(), &dnsZones, &client.ListOptions{Namespace: cr.Namespace}); err != nil {
+		return err
+	}
+	if len(dnsZones.Items) != 1 {
+		// FindZoneIDForChallenge reports the missing zone
+		return nil
+	}
+	deadline := dnsZones.Items[0].CreationTimestamp.Add(zoneDelegationGracePeriod)
+	if !r.now().Before(deadline) {
+		r.clearZoneDelegationPending(reqLogger, cr)
+		return nil
+	}
+
+	dnsZone, err := r.FindZoneIDForChallenge(cr.Namespace, dnsClient)
+	if err != nil {
+		return err
+	}
+	zoneNameservers, err := dnsClient.GetZoneNameservers(reqLogger, cr, dnsZone)
+	if err != nil {
+		return err
+	}
+	if len(zoneNameservers) == 0 {
+		r.clearZoneDelegationPending(reqLogger, cr)
+		return nil
+	}
+
+	delegatedZone, publicNameservers, err := authoritativeNameservers(cr.Spec.ACMEDNSDomain)
+	if err == nil && len(nameserversOutside(publicNameservers, zoneNameservers)) == 0 {
+		reqLogger.Info(fmt.Sprintf("%v is delegated to its new %v zone", cr.Spec.ACMEDNSDomain, dnsClient.GetDNSName()))
+		r.clearZoneDelegationPending(reqLogger, cr)
+		return nil
+	}
+
+	found := fmt.Sprintf("the public DNS delegates it to %v by %v", strings.Join(publicNameservers, ", "), delegatedZone)
+	if err != nil {
+		found = fmt.Sprintf("the delegation could not be looked up: %v", err)
+	}
+	message := fmt.Sprintf("waiting until %v for %v to be delegated to the nameservers of its new %v zone (%v), %v",
+		deadline.UTC().Format(time.RFC3339), cr.Spec.ACMEDNSDomain, dnsClient.GetDNSName(), strings.Join(zoneNameservers, ", "), found)
+	reqLogger.Info(message)
+	if setZoneDelegationPendingCondition(cr, message) {
+		if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+			reqLogger.Error(err, "could not set the zone delegation pending condition")
+		}
+	}
+
+	return fmt.Errorf("%w: %s", errZoneDelegationPending, message)
+}
+
+// setZoneDelegationPendingCondition sets the CertificateRequestZoneDelegationPending condition of cr
+// with message. It returns true when the conditions of cr changed.
+func setZoneDelegationPendingCondition(cr *certmanv1alpha1.CertificateRequest, message string) bool {
+	index := -1
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestZoneDelegationPending {
+			index = i
+			break
+		}
+	}
+
+	if index != -1 && cr.Status.Conditions[index].Message != nil && *cr.Status.Conditions[index].Message == message {
+		return false
+	}
+
+	now := metav1.Now()
+	reason := "NameserversNotPropagated"
+	condition := certmanv1alpha1.CertificateRequestCondition{
+		Type:               certmanv1alpha1.CertificateRequestZoneDelegationPending,
+		Status:             corev1.ConditionTrue,
+		LastProbeTime:      &now,
+		LastTransitionTime: &now,
+		Reason:             &reason,
+		Message:            &message,
+	}
+	if index == -1 {
+		cr.Status.Conditions = append(cr.Status.Conditions, condition)
+	} else {
+		condition.LastTransitionTime = cr.Status.Conditions[index].LastTransitionTime
+		cr.Status.Conditions[index] = condition
+	}
+
+	return true
+}
+
+// clearZoneDelegationPending removes the CertificateRequestZoneDelegationPending condition of cr.
+func (r *CertificateRequestReconciler) clearZoneDelegationPending(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) {
+	for i, condition := range cr.Status.Conditions {
+		if condition.Type == certmanv1alpha1.CertificateRequestZoneDelegationPending {
+			cr.Status.Conditions = append(cr.Status.Conditions[:i], cr.Status.Conditions[i+1:]...)
+			if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+				reqLogger.Error(err, "could not clear the zone delegation pending condition")
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/clock"
+)
+
+func TestCheckZoneDelegation(t *testing.T) {
+	zoneID := "/hostedzone/Z0123456789"
+	zoneCreated := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	parentNameservers := []string{"ns1.parent-dns.example.", "ns2.parent-dns.example."}
+
+	tests := []struct {
+		name              string
+		zoneAge           time.Duration
+		publicNameservers []string
+		alreadyPending    bool
+		expectPending     bool
+	}{
+		{
+			name:              "new zone delegated",
+			zoneAge:           time.Minute,
+			publicNameservers: testZoneNameservers,
+			alreadyPending:    true,
+		},
+		{
+			name:              "new zone not delegated yet",
+			zoneAge:           time.Minute,
+			publicNameservers: parentNameservers,
+			expectPending:     true,
+		},
+		{
+			name:              "zone not delegated past the grace period",
+			zoneAge:           zoneDelegationGracePeriod,
+			publicNameservers: parentNameservers,
+			alreadyPending:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(ns func(string) (string, []string, error)) {
+				authoritativeNameservers = ns
+			}(authoritativeNameservers)
+			authoritativeNameservers = func(fqdn string) (string, []string, error) {
+				return "goes.here.", test.publicNameservers, nil
+			}
+
+			dnsZone := &hivev1.DNSZone{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         testHiveNamespace,
+					Name:              "dnszone",
+					CreationTimestamp: metav1.NewTime(zoneCreated),
+				},
+				Status: hivev1.DNSZoneStatus{AWS: &hivev1.AWSDNSZoneStatus{ZoneID: &zoneID}},
+			}
+			cr := certRequest.DeepCopy()
+			if test.alreadyPending {
+				setZoneDelegationPendingCondition(cr, "waiting")
+			}
+			testClient := setUpTestClient(t, []runtime.Object{cr, dnsZone})
+			rcr := CertificateRequestReconciler{Client: testClient, Clock: clock.NewFake(zoneCreated.Add(test.zoneAge))}
+
+			current := &certmanv1alpha1.CertificateRequest{}
+			key := types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}
+			assert.NoError(t, testClient.Get(context.TODO(), key, current))
+
+			err := rcr.checkZoneDelegation(logr.Discard(), FakeAWSClient{}, current)
+
+			actual := &certmanv1alpha1.CertificateRequest{}
+			assert.NoError(t, testClient.Get(context.TODO(), key, actual))
+			if !test.expectPending {
+				assert.NoError(t, err)
+				assert.Empty(t, actual.Status.Conditions)
+				return
+			}
+
+			assert.True(t, errors.Is(err, errZoneDelegationPending), "expected a pending delegation, got %v", err)
+			if assert.Len(t, actual.Status.Conditions, 1) {
+				condition := actual.Status.Conditions[0]
+				assert.Equal(t, certmanv1alpha1.CertificateRequestZoneDelegationPending, condition.Type)
+				assert.Equal(t, v1.ConditionTrue, condition.Status)
+				assert.Contains(t, *condition.Message, "2024-01-03T12:30:00Z")
+				assert.Contains(t, *condition.Message, "ns1.parent-dns.example.")
+			}
+		})
+	}
+}
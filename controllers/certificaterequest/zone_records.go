@@ -0,0 +1,169 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"context"
+	gerrors "errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	cClient "github.com/openshift/certman-operator/pkg/clients"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+)
+
+var errNoClusterDeploymentOwner = gerrors.New("CertificateRequest has no ClusterDeployment owner")
+
+const (
+	// the records and reasons of the skipped DNS cleanups reported by
+	// certman_operator_skipped_dns_cleanups_count
+	zoneRecordsCleanup      = "zone_records"
+	challengeRecordsCleanup = "challenge_records"
+	dnsZoneDeleted          = "dnszone_deleted"
+	zoneNotFound            = "zone_not_found"
+)
+
+// ensureZoneRecords writes the CAA record allowing Let's Encrypt to issue certificates and the
+// ownership TXT record of cd, the cluster owning cr, when the operator configuration enables them.
+// The records don't gate issuance, so failures are only logged and retried on the next issuance.
+func (r *CertificateRequestReconciler) ensureZoneRecords(reqLogger logr.Logger, dnsClient cClient.Client, cr *certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment) {
+	if !utils.ManageZoneRecords(r.Client) || IsCanary(cr) {
+		return
+	}
+	if cd == nil {
+		reqLogger.Info("not writing zone records: the CertificateRequest has no ClusterDeployment")
+		return
+	}
+
+	clusterID := clusterIDForZoneRecords(cd)
+	if clusterID == "" {
+		reqLogger.Info("not writing zone records: the ClusterDeployment has no cluster ID yet")
+		return
+	}
+
+	dnsZone, err := r.FindZoneIDForChallenge(cr.Namespace, dnsClient)
+	if err != nil {
+		reqLogger.Error(err, "could not find the dns zone to write zone records to")
+		return
+	}
+
+	if err := dnsClient.EnsureZoneRecords(reqLogger, cr, dnsZone, clusterID); err != nil {
+		reqLogger.Error(err, fmt.Sprintf("could not write zone records to %v", dnsZone))
+		return
+	}
+
+	cr.Status.ZoneRecords = &certmanv1alpha1.ZoneRecords{
+		DNSZone:   dnsZone,
+		ClusterID: clusterID,
+	}
+}
+
+// deleteZoneRecords removes the zone records written for cr once its ClusterDeployment is being
+// deleted. A DNS client can't be built for a ClusterDeployment that is already gone, in which
+// case the records are left behind with the zone. Hive may also delete the DNSZone, and with it
+// the zone, before the CertificateRequest is finalized: the records went with the zone, so the
+// cleanup is skipped rather than blocking the deletion.
+func (r *CertificateRequestReconciler) deleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
+	if cr.Status.ZoneRecords == nil {
+		return nil
+	}
+
+	cd, err := r.getOwnerClusterDeployment(cr)
+	if gerrors.Is(err, errNoClusterDeploymentOwner) {
+		reqLogger.Info(fmt.Sprintf("not deleting zone records from %v: %v", cr.Status.ZoneRecords.DNSZone, err))
+		return nil
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && cd.DeletionTimestamp.IsZero() {
+		// another CertificateRequest of the cluster may still rely on the records
+		return nil
+	}
+
+	if !r.Fedramp.Enabled {
+		dnsZones := hivev1.DNSZoneList{}
+		if err := r.Client.List(context.TODO(), &dnsZones, client.InNamespace(cr.Namespace)); err != nil {
+			return err
+		}
+		if len(dnsZones.Items) == 0 {
+			skipDNSCleanup(reqLogger, zoneRecordsCleanup, dnsZoneDeleted, fmt.Sprintf("not deleting zone records from %v: the DNSZone was deleted", cr.Status.ZoneRecords.DNSZone))
+			return nil
+		}
+	}
+
+	dnsClient, err := r.getClient(reqLogger, cr)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			reqLogger.Info(fmt.Sprintf("not deleting zone records from %v: %v", cr.Status.ZoneRecords.DNSZone, err))
+			return nil
+		}
+		return err
+	}
+
+	err = dnsClient.DeleteZoneRecords(reqLogger, cr, cr.Status.ZoneRecords.DNSZone, cr.Status.ZoneRecords.ClusterID)
+	if gerrors.Is(err, cTypes.ErrZoneNotFound) {
+		skipDNSCleanup(reqLogger, zoneRecordsCleanup, zoneNotFound, fmt.Sprintf("not deleting zone records: %v", err))
+		return nil
+	}
+	return err
+}
+
+// skipDNSCleanup logs message about a cleanup of records skipped for reason and counts it.
+func skipDNSCleanup(reqLogger logr.Logger, records, reason, message string) {
+	reqLogger.Info(message)
+	localmetrics.IncrementSkippedDNSCleanupCount(records, reason)
+}
+
+// failDNSCleanup logs err, which failed a cleanup of records, with message and counts it.
+func failDNSCleanup(reqLogger logr.Logger, records string, err error, message string) {
+	reqLogger.Error(err, message)
+	localmetrics.IncrementFailedDNSCleanupCount(records)
+}
+
+// getOwnerClusterDeployment returns the ClusterDeployment owning cr.
+func (r *CertificateRequestReconciler) getOwnerClusterDeployment(cr *certmanv1alpha1.CertificateRequest) (*hivev1.ClusterDeployment, error) {
+	clusterDeploymentName := ""
+	for _, ownerRef := range cr.OwnerReferences {
+		if ownerRef.Kind == clusterDeploymentType {
+			clusterDeploymentName = ownerRef.Name
+		}
+	}
+	if clusterDeploymentName == "" {
+		return nil, errNoClusterDeploymentOwner
+	}
+
+	cd := &hivev1.ClusterDeployment{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: clusterDeploymentName}, cd)
+	return cd, err
+}
+
+// clusterIDForZoneRecords returns the cluster ID held by the ownership TXT record of cd.
+func clusterIDForZoneRecords(cd *hivev1.ClusterDeployment) string {
+	if cd.Spec.ClusterMetadata == nil {
+		return ""
+	}
+	return cd.Spec.ClusterMetadata.ClusterID
+}
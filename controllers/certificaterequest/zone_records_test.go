@@ -0,0 +1,232 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterequest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	cClient "github.com/openshift/certman-operator/pkg/clients"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// zoneRecordsDNSClient records the zone records written and deleted through it.
+type zoneRecordsDNSClient struct {
+	FakeAWSClient
+	ensured *certmanv1alpha1.ZoneRecords
+	deleted *certmanv1alpha1.ZoneRecords
+	// deleteErr is returned by DeleteZoneRecords instead of deleting the records
+	deleteErr error
+}
+
+func (c *zoneRecordsDNSClient) EnsureZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	c.ensured = &certmanv1alpha1.ZoneRecords{DNSZone: dnsZone, ClusterID: clusterID}
+	return nil
+}
+
+func (c *zoneRecordsDNSClient) DeleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	if c.deleteErr != nil {
+		return c.deleteErr
+	}
+	c.deleted = &certmanv1alpha1.ZoneRecords{DNSZone: dnsZone, ClusterID: clusterID}
+	return nil
+}
+
+var testZoneRecordsClusterID = "0a1b2c3d-fake-cluster-id"
+
+func zoneRecordsClusterDeployment(deleting bool) *hivev1.ClusterDeployment {
+	cd := &hivev1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testHiveNamespace,
+			Name:      testHiveClusterDeploymentName,
+		},
+		Spec: hivev1.ClusterDeploymentSpec{
+			ClusterMetadata: &hivev1.ClusterMetadata{
+				ClusterID: testZoneRecordsClusterID,
+			},
+		},
+	}
+	if deleting {
+		now := metav1.Now()
+		cd.DeletionTimestamp = &now
+		cd.Finalizers = []string{"hive.openshift.io/deprovision"}
+	}
+	return cd
+}
+
+func TestEnsureZoneRecords(t *testing.T) {
+	testZoneID := "/hostedzone/Z0123456789"
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-zone",
+			Namespace: testHiveNamespace,
+		},
+		Status: hivev1.DNSZoneStatus{
+			AWS: &hivev1.AWSDNSZoneStatus{
+				ZoneID: &testZoneID,
+			},
+		},
+	}
+
+	tests := []struct {
+		Name              string
+		ManageZoneRecords string
+		Expected          *certmanv1alpha1.ZoneRecords
+	}{
+		{
+			Name:              "writes the records when enabled",
+			ManageZoneRecords: "true",
+			Expected:          &certmanv1alpha1.ZoneRecords{DNSZone: "Z0123456789", ClusterID: testZoneRecordsClusterID},
+		},
+		{
+			Name:              "doesn't write the records by default",
+			ManageZoneRecords: "",
+			Expected:          nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			operatorConfigMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.OperatorName,
+					Namespace: config.OperatorNamespace,
+				},
+				Data: map[string]string{
+					cTypes.ManageZoneRecords: test.ManageZoneRecords,
+				},
+			}
+			cd := zoneRecordsClusterDeployment(false)
+			testClient := setUpTestClient(t, []runtime.Object{cd, dnsZone, operatorConfigMap})
+			dnsClient := &zoneRecordsDNSClient{}
+			rcr := CertificateRequestReconciler{Client: testClient}
+			cr := certRequest.DeepCopy()
+
+			rcr.ensureZoneRecords(logr.Discard(), dnsClient, cr, cd)
+
+			if test.Expected == nil {
+				if dnsClient.ensured != nil || cr.Status.ZoneRecords != nil {
+					t.Errorf("expected no zone records, got %v", cr.Status.ZoneRecords)
+				}
+				return
+			}
+			if dnsClient.ensured == nil || *dnsClient.ensured != *test.Expected {
+				t.Errorf("expected zone records %v to be written, got %v", test.Expected, dnsClient.ensured)
+			}
+			if cr.Status.ZoneRecords == nil || *cr.Status.ZoneRecords != *test.Expected {
+				t.Errorf("expected status zone records %v, got %v", test.Expected, cr.Status.ZoneRecords)
+			}
+		})
+	}
+}
+
+func TestDeleteZoneRecords(t *testing.T) {
+	zoneRecords := &certmanv1alpha1.ZoneRecords{DNSZone: "Z0123456789", ClusterID: testZoneRecordsClusterID}
+	dnsZone := &hivev1.DNSZone{ObjectMeta: metav1.ObjectMeta{Namespace: testHiveNamespace, Name: "dnszone"}}
+
+	tests := []struct {
+		Name          string
+		KubeObjects   []runtime.Object
+		ZoneRecords   *certmanv1alpha1.ZoneRecords
+		DeleteErr     error
+		ExpectDeleted bool
+		// ExpectSkipped is the reason the cleanup is expected to be skipped for
+		ExpectSkipped string
+	}{
+		{
+			Name:          "deletes the records when the cluster is being deleted",
+			KubeObjects:   []runtime.Object{zoneRecordsClusterDeployment(true), dnsZone},
+			ZoneRecords:   zoneRecords,
+			ExpectDeleted: true,
+		},
+		{
+			Name:          "keeps the records while the cluster exists",
+			KubeObjects:   []runtime.Object{zoneRecordsClusterDeployment(false), dnsZone},
+			ZoneRecords:   zoneRecords,
+			ExpectDeleted: false,
+		},
+		{
+			Name:          "does nothing when no records were written",
+			KubeObjects:   []runtime.Object{zoneRecordsClusterDeployment(true), dnsZone},
+			ZoneRecords:   nil,
+			ExpectDeleted: false,
+		},
+		{
+			Name:          "skips the cleanup once the DNSZone was deleted",
+			KubeObjects:   []runtime.Object{zoneRecordsClusterDeployment(true)},
+			ZoneRecords:   zoneRecords,
+			ExpectSkipped: dnsZoneDeleted,
+		},
+		{
+			Name:          "skips the cleanup when the zone no longer exists",
+			KubeObjects:   []runtime.Object{zoneRecordsClusterDeployment(true), dnsZone},
+			ZoneRecords:   zoneRecords,
+			DeleteErr:     fmt.Errorf("%w: NoSuchHostedZone", cTypes.ErrZoneNotFound),
+			ExpectSkipped: zoneNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			testClient := setUpTestClient(t, test.KubeObjects)
+			dnsClient := &zoneRecordsDNSClient{deleteErr: test.DeleteErr}
+			rcr := CertificateRequestReconciler{
+				Client: testClient,
+				ClientBuilder: func(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (cClient.Client, error) {
+					return dnsClient, nil
+				},
+			}
+			cr := certRequest.DeepCopy()
+			cr.Status.ZoneRecords = test.ZoneRecords
+			skipped := map[string]float64{}
+			for _, reason := range []string{dnsZoneDeleted, zoneNotFound} {
+				skipped[reason] = testutil.ToFloat64(localmetrics.MetricSkippedDNSCleanupCount.WithLabelValues(zoneRecordsCleanup, reason))
+			}
+
+			if err := rcr.deleteZoneRecords(logr.Discard(), cr); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if test.ExpectDeleted != (dnsClient.deleted != nil) {
+				t.Errorf("expected records deleted: %t, got %v", test.ExpectDeleted, dnsClient.deleted)
+			}
+			if test.ExpectDeleted && *dnsClient.deleted != *zoneRecords {
+				t.Errorf("expected zone records %v to be deleted, got %v", zoneRecords, dnsClient.deleted)
+			}
+			for reason, before := range skipped {
+				expected := before
+				if reason == test.ExpectSkipped {
+					expected++
+				}
+				if count := testutil.ToFloat64(localmetrics.MetricSkippedDNSCleanupCount.WithLabelValues(zoneRecordsCleanup, reason)); count != expected {
+					t.Errorf("expected %v cleanups skipped for %s, got %v", expected, reason, count)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterotation
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	controllerName = "controller_certificaterotation"
+
+	// defaultMaxConcurrent is the number of certificates reissued at a time when the
+	// CertificateRotationRequest doesn't set it
+	defaultMaxConcurrent = 10
+	// rotationRecheckInterval is how often a rotation in progress checks the reissuances it started
+	// and starts the next ones
+	rotationRecheckInterval = time.Minute
+	// rotationStartedEventReason is the reason of the events listing the reissuances started
+	rotationStartedEventReason = "ReissuanceRequested"
+)
+
+var _ reconcile.Reconciler = &CertificateRotationReconciler{}
+
+// CertificateRotationReconciler reissues the certificates selected by CertificateRotationRequests,
+// requesting the renewal of a few CertificateRequests at a time.
+type CertificateRotationReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	// Clock tells the time the reissuances are paced at, it defaults to the system clock
+	Clock clock.Clock
+	// Recorder records the reissuances started as events on the CertificateRotationRequest. No
+	// events are recorded when it is nil.
+	Recorder record.EventRecorder
+}
+
+// now returns the current time of the Clock of r.
+func (r *CertificateRotationReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// rotationProgress sorts the CertificateRequests matched by a CertificateRotationRequest by the
+// state of their certificate.
+type rotationProgress struct {
+	matched int
+	rotated int
+	// inProgress are the CertificateRequests with a renewal requested, as namespace/name
+	inProgress []string
+	// pending are the CertificateRequests whose certificate is yet to be reissued, sorted by
+	// namespace and name
+	pending []*certmanv1alpha1.CertificateRequest
+}
+
+// Reconcile requests the renewal of the pending certificates of a CertificateRotationRequest, as
+// many as its MaxConcurrent and Deadline allow, and reports its progress. Renewals are requested
+// through the renew-requested-at annotation, which the CertificateRequest controller removes once
+// the certificate is reissued. The rotation is checked again every minute until it completes.
+func (r *CertificateRotationReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := logging.FromContext(ctx)
+
+	rotation := &certmanv1alpha1.CertificateRotationRequest{}
+	err := r.Client.Get(ctx, request.NamespacedName, rotation)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if !rotation.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	// a completed rotation is left alone until its spec changes
+	if rotation.Status.ObservedGeneration == rotation.Generation &&
+		meta.IsStatusConditionTrue(rotation.Status.Conditions, certmanv1alpha1.CertificateRotationComplete) {
+		return reconcile.Result{}, nil
+	}
+
+	original := rotation.Status.DeepCopy()
+	now := r.now()
+	rotation.Status.ObservedGeneration = rotation.Generation
+	if rotation.Status.StartTime == nil {
+		startTime := metav1.NewTime(now)
+		rotation.Status.StartTime = &startTime
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&rotation.Spec.Selector)
+	if err != nil {
+		// the spec has to change for the rotation to go on
+		reqLogger.Error(err, "invalid selector")
+		r.setComplete(rotation, metav1.ConditionFalse, "InvalidSelector", err.Error())
+		return reconcile.Result{}, r.updateStatus(ctx, rotation, original)
+	}
+
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := r.Client.List(ctx, crList, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	progress := newRotationProgress(crList.Items, issuedBefore(rotation))
+
+	// the reissuances are requested one at a time, so a failure only holds back the ones after it
+	started := []string{}
+	var startErr error
+	for _, cr := range progress.pending[:reissuancesToStart(rotation, progress, now)] {
+		if err := r.requestRenewal(ctx, cr, now); err != nil {
+			startErr = fmt.Errorf("could not request the renewal of CertificateRequest %s/%s: %w", cr.Namespace, cr.Name, err)
+			break
+		}
+		reqLogger.Info("requested the reissuance of a certificate", logging.CertificateRequestKey, logging.ObjectName(cr.Namespace, cr.Name))
+		started = append(started, cr.Namespace+"/"+cr.Name)
+	}
+	progress.inProgress = append(progress.inProgress, started...)
+	progress.pending = progress.pending[len(started):]
+	sort.Strings(progress.inProgress)
+
+	if len(started) > 0 && r.Recorder != nil {
+		r.Recorder.Eventf(rotation, corev1.EventTypeNormal, rotationStartedEventReason,
+			"requested the reissuance of %d certificates, %d pending", len(started), len(progress.pending))
+	}
+
+	rotation.Status.Matched = progress.matched
+	rotation.Status.Rotated = progress.rotated
+	rotation.Status.InProgress = len(progress.inProgress)
+	rotation.Status.Pending = len(progress.pending)
+	rotation.Status.InProgressCertificateRequests = progress.inProgress
+
+	message := fmt.Sprintf("%d of %d certificates rotated", progress.rotated, progress.matched)
+	switch {
+	case len(progress.inProgress) == 0 && len(progress.pending) == 0:
+		r.setComplete(rotation, metav1.ConditionTrue, "Rotated", message)
+		if rotation.Status.CompletionTime == nil {
+			completionTime := metav1.NewTime(now)
+			rotation.Status.CompletionTime = &completionTime
+		}
+	case rotation.Spec.Paused:
+		r.setComplete(rotation, metav1.ConditionFalse, "Paused", message)
+		rotation.Status.CompletionTime = nil
+	default:
+		r.setComplete(rotation, metav1.ConditionFalse, "InProgress", message)
+		rotation.Status.CompletionTime = nil
+	}
+
+	if err := r.updateStatus(ctx, rotation, original); err != nil {
+		return reconcile.Result{}, goerrors.Join(startErr, err)
+	}
+	if startErr != nil {
+		return reconcile.Result{}, startErr
+	}
+	if meta.IsStatusConditionTrue(rotation.Status.Conditions, certmanv1alpha1.CertificateRotationComplete) {
+		reqLogger.Info("rotation complete", "rotated", progress.rotated)
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{RequeueAfter: rotationRecheckInterval}, nil
+}
+
+// newRotationProgress sorts crs by the state of their certificate. Certificates issued at or after
+// rotatedAfter are rotated. CertificateRequests without a certificate get a new one anyway and
+// aren't matched.
+func newRotationProgress(crs []certmanv1alpha1.CertificateRequest, rotatedAfter time.Time) rotationProgress {
+	progress := rotationProgress{inProgress: []string{}, pending: []*certmanv1alpha1.CertificateRequest{}}
+	for i := range crs {
+		cr := &crs[i]
+		issued, ok := issuedAt(cr)
+		if !ok || !cr.DeletionTimestamp.IsZero() {
+			continue
+		}
+		progress.matched++
+
+		switch _, requested := cr.Annotations[certificaterequest.RenewRequestedAtAnnotation]; {
+		case !issued.Before(rotatedAfter):
+			progress.rotated++
+		case requested:
+			progress.inProgress = append(progress.inProgress, cr.Namespace+"/"+cr.Name)
+		default:
+			progress.pending = append(progress.pending, cr)
+		}
+	}
+
+	sort.Strings(progress.inProgress)
+	sort.Slice(progress.pending, func(i, j int) bool {
+		if progress.pending[i].Namespace != progress.pending[j].Namespace {
+			return progress.pending[i].Namespace < progress.pending[j].Namespace
+		}
+		return progress.pending[i].Name < progress.pending[j].Name
+	})
+	return progress
+}
+
+// issuedAt returns when the current certificate of cr was issued, from its issuance history or, for
+// CertificateRequests issued before the history was recorded, the NotBefore of its status.
+func issuedAt(cr *certmanv1alpha1.CertificateRequest) (time.Time, bool) {
+	if history := cr.Status.IssuanceHistory; len(history) > 0 {
+		return history[len(history)-1].Time.Time, true
+	}
+	if cr.Status.NotBefore == "" {
+		return time.Time{}, false
+	}
+	notBefore, err := time.Parse(certmanv1alpha1.StatusTimeLayout, cr.Status.NotBefore)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return notBefore, true
+}
+
+// issuedBefore returns the time from which the certificates of rotation count as rotated.
+func issuedBefore(rotation *certmanv1alpha1.CertificateRotationRequest) time.Time {
+	if rotation.Spec.IssuedBefore != nil {
+		return rotation.Spec.IssuedBefore.Time
+	}
+	return rotation.CreationTimestamp.Time
+}
+
+// reissuancesToStart returns how many of the pending certificates of rotation are reissued now:
+// none while it is paused, and never more than MaxConcurrent at a time. With a Deadline, the
+// reissuances are started in proportion to the time elapsed since the start of the rotation, so
+// that the last ones start at the deadline.
+func reissuancesToStart(rotation *certmanv1alpha1.CertificateRotationRequest, progress rotationProgress, now time.Time) int {
+	if rotation.Spec.Paused {
+		return 0
+	}
+
+	maxConcurrent := rotation.Spec.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultMaxConcurrent
+	}
+	count := maxConcurrent - len(progress.inProgress)
+
+	if rotation.Spec.Deadline != nil && rotation.Status.StartTime != nil {
+		due := progress.matched
+		window := rotation.Spec.Deadline.Sub(rotation.Status.StartTime.Time)
+		if elapsed := now.Sub(rotation.Status.StartTime.Time); window > 0 && elapsed < window {
+			due = int(math.Ceil(float64(progress.matched) * float64(elapsed) / float64(window)))
+			// the first reissuance starts with the rotation
+			if due < 1 {
+				due = 1
+			}
+		}
+		if started := progress.rotated + len(progress.inProgress); due-started < count {
+			count = due - started
+		}
+	}
+
+	if count > len(progress.pending) {
+		count = len(progress.pending)
+	}
+	if count < 0 {
+		count = 0
+	}
+	return count
+}
+
+// requestRenewal sets the renew-requested-at annotation of cr, which has the CertificateRequest
+// controller reissue its certificate.
+func (r *CertificateRotationReconciler) requestRenewal(ctx context.Context, cr *certmanv1alpha1.CertificateRequest, now time.Time) error {
+	baseToPatch := client.MergeFrom(cr.DeepCopy())
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	cr.Annotations[certificaterequest.RenewRequestedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	return r.Client.Patch(ctx, cr, baseToPatch)
+}
+
+// setComplete sets the Complete condition of rotation.
+func (r *CertificateRotationReconciler) setComplete(rotation *certmanv1alpha1.CertificateRotationRequest, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&rotation.Status.Conditions, metav1.Condition{
+		Type:               certmanv1alpha1.CertificateRotationComplete,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: rotation.Generation,
+	})
+}
+
+// updateStatus writes the status of rotation when it differs from original.
+func (r *CertificateRotationReconciler) updateStatus(ctx context.Context, rotation *certmanv1alpha1.CertificateRotationRequest, original *certmanv1alpha1.CertificateRotationRequestStatus) error {
+	if apiequality.Semantic.DeepEqual(&rotation.Status, original) {
+		return nil
+	}
+	return r.Client.Status().Update(ctx, rotation)
+}
+
+// SetupWithManager sets up the controller with the Manager. Status updates don't trigger reconciles,
+// rotations in progress are requeued on their own.
+func (r *CertificateRotationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("certificaterotation").
+		For(&certmanv1alpha1.CertificateRotationRequest{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificaterotation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/pkg/clock"
+)
+
+const testRotationName = "intermediate-ca"
+
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// testCertificateRequest returns a CertificateRequest of the fleet whose certificate was issued at
+// issued.
+func testCertificateRequest(namespace string, issued time.Time) *certmanv1alpha1.CertificateRequest {
+	return &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "primary-cert-bundle",
+			Namespace: namespace,
+			Labels:    map[string]string{"fleet": "production"},
+		},
+		Status: certmanv1alpha1.CertificateRequestStatus{
+			Issued: true,
+			IssuanceHistory: []certmanv1alpha1.CertificateIssuance{
+				{Time: metav1.NewTime(issued), SerialNumber: "1", Trigger: certmanv1alpha1.IssuanceTriggerCreate},
+			},
+		},
+	}
+}
+
+func testRotation(spec certmanv1alpha1.CertificateRotationRequestSpec) *certmanv1alpha1.CertificateRotationRequest {
+	return &certmanv1alpha1.CertificateRotationRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              testRotationName,
+			Generation:        1,
+			CreationTimestamp: metav1.NewTime(testNow.Add(-time.Hour)),
+		},
+		Spec: spec,
+	}
+}
+
+func TestReconcileCertificateRotation(t *testing.T) {
+	assert.NoError(t, certmanv1alpha1.AddToScheme(scheme.Scheme))
+
+	old := testNow.Add(-30 * 24 * time.Hour)
+	crs := []client.Object{
+		testCertificateRequest("cluster-a", old),
+		testCertificateRequest("cluster-b", old),
+		testCertificateRequest("cluster-c", old),
+		testCertificateRequest("cluster-d", testNow.Add(-time.Minute)),
+	}
+	other := testCertificateRequest("cluster-e", old)
+	other.Labels = map[string]string{"fleet": "staging"}
+	unissued := testCertificateRequest("cluster-f", old)
+	unissued.Status = certmanv1alpha1.CertificateRequestStatus{}
+
+	issuedBefore := metav1.NewTime(testNow.Add(-time.Hour))
+	rotation := testRotation(certmanv1alpha1.CertificateRotationRequestSpec{
+		Selector:      metav1.LabelSelector{MatchLabels: map[string]string{"fleet": "production"}},
+		IssuedBefore:  &issuedBefore,
+		MaxConcurrent: 2,
+	})
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithObjects(append(crs, other, unissued, rotation)...).
+		WithStatusSubresource(rotation).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &CertificateRotationReconciler{Client: fakeClient, Scheme: scheme.Scheme, Clock: clock.NewFake(testNow), Recorder: recorder}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testRotationName}}
+
+	result, err := r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, rotationRecheckInterval, result.RequeueAfter)
+
+	actual := &certmanv1alpha1.CertificateRotationRequest{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actual))
+	assert.Equal(t, 4, actual.Status.Matched)
+	assert.Equal(t, 1, actual.Status.Rotated)
+	assert.Equal(t, 2, actual.Status.InProgress)
+	assert.Equal(t, 1, actual.Status.Pending)
+	assert.Equal(t, []string{"cluster-a/primary-cert-bundle", "cluster-b/primary-cert-bundle"}, actual.Status.InProgressCertificateRequests)
+	assert.False(t, meta.IsStatusConditionTrue(actual.Status.Conditions, certmanv1alpha1.CertificateRotationComplete))
+	assert.Len(t, recorder.Events, 1)
+
+	for namespace, requested := range map[string]bool{"cluster-a": true, "cluster-b": true, "cluster-c": false, "cluster-d": false, "cluster-e": false, "cluster-f": false} {
+		cr := &certmanv1alpha1.CertificateRequest{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "primary-cert-bundle"}, cr))
+		_, ok := cr.Annotations[certificaterequest.RenewRequestedAtAnnotation]
+		assert.Equal(t, requested, ok, "renewal requested for %s", namespace)
+	}
+
+	// the CertificateRequest controller reissues every certificate
+	for _, namespace := range []string{"cluster-a", "cluster-b", "cluster-c"} {
+		cr := &certmanv1alpha1.CertificateRequest{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "primary-cert-bundle"}, cr))
+		delete(cr.Annotations, certificaterequest.RenewRequestedAtAnnotation)
+		cr.Status.IssuanceHistory = append(cr.Status.IssuanceHistory, certmanv1alpha1.CertificateIssuance{
+			Time: metav1.NewTime(testNow), SerialNumber: "2", Trigger: certmanv1alpha1.IssuanceTriggerForcedRenewal,
+		})
+		assert.NoError(t, fakeClient.Update(context.TODO(), cr))
+	}
+
+	result, err = r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actual))
+	assert.Equal(t, 4, actual.Status.Rotated)
+	assert.Zero(t, actual.Status.InProgress)
+	assert.Zero(t, actual.Status.Pending)
+	assert.True(t, meta.IsStatusConditionTrue(actual.Status.Conditions, certmanv1alpha1.CertificateRotationComplete))
+	assert.NotNil(t, actual.Status.CompletionTime)
+}
+
+func TestReissuancesToStart(t *testing.T) {
+	pending := func(count int) []*certmanv1alpha1.CertificateRequest {
+		crs := []*certmanv1alpha1.CertificateRequest{}
+		for i := 0; i < count; i++ {
+			crs = append(crs, testCertificateRequest(fmt.Sprintf("cluster-%d", i), testNow))
+		}
+		return crs
+	}
+	deadline := metav1.NewTime(testNow.Add(10 * time.Hour))
+	startTime := metav1.NewTime(testNow)
+
+	tests := []struct {
+		name     string
+		spec     certmanv1alpha1.CertificateRotationRequestSpec
+		progress rotationProgress
+		now      time.Time
+		expected int
+	}{
+		{
+			name:     "defaults to 10 at a time",
+			progress: rotationProgress{matched: 50, pending: pending(50)},
+			now:      testNow,
+			expected: defaultMaxConcurrent,
+		},
+		{
+			name:     "keeps to the concurrency limit",
+			spec:     certmanv1alpha1.CertificateRotationRequestSpec{MaxConcurrent: 5},
+			progress: rotationProgress{matched: 50, inProgress: []string{"a", "b", "c"}, pending: pending(47)},
+			now:      testNow,
+			expected: 2,
+		},
+		{
+			name:     "starts nothing while paused",
+			spec:     certmanv1alpha1.CertificateRotationRequestSpec{Paused: true},
+			progress: rotationProgress{matched: 50, pending: pending(50)},
+			now:      testNow,
+			expected: 0,
+		},
+		{
+			name:     "starts one at the start of a paced rotation",
+			spec:     certmanv1alpha1.CertificateRotationRequestSpec{Deadline: &deadline},
+			progress: rotationProgress{matched: 100, pending: pending(100)},
+			now:      testNow,
+			expected: 1,
+		},
+		{
+			name:     "paces the reissuances until the deadline",
+			spec:     certmanv1alpha1.CertificateRotationRequestSpec{Deadline: &deadline, MaxConcurrent: 50},
+			progress: rotationProgress{matched: 100, rotated: 5, inProgress: []string{"a", "b"}, pending: pending(93)},
+			now:      testNow.Add(time.Hour),
+			expected: 3,
+		},
+		{
+			name:     "starts the rest after the deadline",
+			spec:     certmanv1alpha1.CertificateRotationRequestSpec{Deadline: &deadline, MaxConcurrent: 50},
+			progress: rotationProgress{matched: 100, rotated: 90, pending: pending(10)},
+			now:      testNow.Add(11 * time.Hour),
+			expected: 10,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rotation := testRotation(test.spec)
+			rotation.Status.StartTime = &startTime
+			assert.Equal(t, test.expected, reissuancesToStart(rotation, test.progress, test.now))
+		})
+	}
+}
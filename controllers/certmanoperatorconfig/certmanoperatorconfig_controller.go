@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certmanoperatorconfig
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/logging"
+	"github.com/openshift/certman-operator/pkg/version"
+)
+
+const controllerName = "controller_certmanoperatorconfig"
+
+var _ reconcile.Reconciler = &CertmanOperatorConfigReconciler{}
+
+// CertmanOperatorConfigReconciler reports which CertmanOperatorConfig the operator applies
+type CertmanOperatorConfigReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile marks the CertmanOperatorConfig named after the operator as applied. The other
+// controllers read the configuration on every reconcile, so there is nothing else to roll out.
+// Objects with any other name are reported as ignored.
+func (r *CertmanOperatorConfigReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := logging.FromContext(ctx)
+
+	operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{}
+	err := r.Client.Get(ctx, request.NamespacedName, operatorConfig)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	condition := metav1.Condition{
+		Type:    certmanv1alpha1.CertmanOperatorConfigApplied,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Applied",
+		Message: "Configuration is in use by the operator",
+	}
+	if operatorConfig.Name != config.OperatorName {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "Ignored"
+		condition.Message = "Only the CertmanOperatorConfig named " + config.OperatorName + " is used"
+	}
+	condition.ObservedGeneration = operatorConfig.Generation
+
+	operatorVersion, configHash := "", ""
+	if condition.Status == metav1.ConditionTrue {
+		operatorVersion = version.Version
+		if configHash, err = utils.ConfigHash(r.Client); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if operatorConfig.Status.ObservedGeneration == operatorConfig.Generation &&
+		operatorConfig.Status.OperatorVersion == operatorVersion &&
+		operatorConfig.Status.ConfigHash == configHash &&
+		meta.IsStatusConditionPresentAndEqual(operatorConfig.Status.Conditions, condition.Type, condition.Status) {
+		return reconcile.Result{}, nil
+	}
+
+	operatorConfig.Status.ObservedGeneration = operatorConfig.Generation
+	operatorConfig.Status.OperatorVersion = operatorVersion
+	operatorConfig.Status.ConfigHash = configHash
+	meta.SetStatusCondition(&operatorConfig.Status.Conditions, condition)
+
+	reqLogger.Info("updating status", "applied", condition.Status)
+	return reconcile.Result{}, r.Client.Status().Update(ctx, operatorConfig)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CertmanOperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&certmanv1alpha1.CertmanOperatorConfig{}).
+		Complete(r)
+}
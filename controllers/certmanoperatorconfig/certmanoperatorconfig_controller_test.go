@@ -0,0 +1,94 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certmanoperatorconfig
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+)
+
+func TestReconcileCertmanOperatorConfig(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	tests := []struct {
+		name           string
+		configName     string
+		expectedStatus metav1.ConditionStatus
+	}{
+		{
+			name:           "operator config is applied",
+			configName:     config.OperatorName,
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:           "other configs are ignored",
+			configName:     "other",
+			expectedStatus: metav1.ConditionFalse,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       test.configName,
+					Generation: 2,
+				},
+				Spec: certmanv1alpha1.CertmanOperatorConfigSpec{
+					DefaultNotificationEmailAddress: "foo@bar.com",
+				},
+			}
+			kubeClient := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithRuntimeObjects(operatorConfig).
+				WithStatusSubresource(operatorConfig).
+				Build()
+
+			r := &CertmanOperatorConfigReconciler{Client: kubeClient, Scheme: scheme.Scheme}
+			_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: test.configName}})
+			assert.NoError(t, err)
+
+			actual := &certmanv1alpha1.CertmanOperatorConfig{}
+			err = kubeClient.Get(context.TODO(), types.NamespacedName{Name: test.configName}, actual)
+			assert.NoError(t, err)
+			assert.Equal(t, actual.Generation, actual.Status.ObservedGeneration)
+			if test.expectedStatus == metav1.ConditionTrue {
+				assert.NotEmpty(t, actual.Status.OperatorVersion)
+				assert.NotEmpty(t, actual.Status.ConfigHash)
+			} else {
+				assert.Empty(t, actual.Status.ConfigHash)
+			}
+
+			condition := meta.FindStatusCondition(actual.Status.Conditions, certmanv1alpha1.CertmanOperatorConfigApplied)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, test.expectedStatus, condition.Status)
+			}
+		})
+	}
+}
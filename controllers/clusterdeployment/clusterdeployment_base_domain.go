@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdeployment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+// migrateBaseDomain prepares cr, whose ACMEDNSDomain is no longer the base domain of cd, for the
+// certificate of the new domain before its spec is updated. The records cr left in the zone of the
+// old domain are removed on a best-effort basis, the issuance in progress is dropped since its ACME
+// order is for the old domains, and a renewal is requested so the certificate is reissued even when
+// none of its DNS names changed.
+func (r *ClusterDeploymentReconciler) migrateBaseDomain(cd *hivev1.ClusterDeployment, cr *certmanv1alpha1.CertificateRequest, logger logr.Logger) error {
+	logger.Info(fmt.Sprintf("the base domain of the cluster changed from %v to %v, reissuing the certificate of %v", cr.Spec.ACMEDNSDomain, cd.Spec.BaseDomain, cr.Name))
+
+	r.cleanUpOldZoneRecords(cd, cr, logger)
+
+	cr.Status.OrderURL = ""
+	cr.Status.IssuanceStage = ""
+	cr.Status.DNSZone = ""
+	cr.Status.ChallengeFQDNs = nil
+	cr.Status.DomainValidations = nil
+	cr.Status.LastFailure = nil
+	cr.Status.ZoneRecords = nil
+	if err := r.Client.Status().Update(context.TODO(), cr); err != nil {
+		logger.Error(err, "error resetting the issuance of certificaterequest", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, cr.Name))
+		return err
+	}
+
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	cr.Annotations[certificaterequest.RenewRequestedAtAnnotation] = r.now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+// cleanUpOldZoneRecords deletes the challenge records and the zone records cr left in the zone of
+// its current ACMEDNSDomain. Failures are logged, the records don't keep the certificate of the new
+// domain from being issued.
+func (r *ClusterDeploymentReconciler) cleanUpOldZoneRecords(cd *hivev1.ClusterDeployment, cr *certmanv1alpha1.CertificateRequest, logger logr.Logger) {
+	if r.ClientBuilder == nil {
+		return
+	}
+	crLogger := logging.WithCertificateRequest(logger, cr)
+
+	dnsClient, err := r.ClientBuilder(crLogger, r.Client, cr.Spec.Platform, cr.Namespace, cd.Name)
+	if err != nil {
+		crLogger.Error(err, fmt.Sprintf("could not build the platform client to clean up the records of %v", cr.Spec.ACMEDNSDomain))
+		return
+	}
+	if err := dnsClient.DeleteAcmeChallengeResourceRecords(crLogger, cr); err != nil {
+		crLogger.Error(err, fmt.Sprintf("could not clean up the challenge records of %v", cr.Spec.ACMEDNSDomain))
+		localmetrics.IncrementFailedDNSCleanupCount("challenge_records")
+	}
+	if cr.Status.ZoneRecords != nil {
+		if err := dnsClient.DeleteZoneRecords(crLogger, cr, cr.Status.ZoneRecords.DNSZone, cr.Status.ZoneRecords.ClusterID); err != nil {
+			crLogger.Error(err, fmt.Sprintf("could not clean up the zone records of %v", cr.Spec.ACMEDNSDomain))
+			localmetrics.IncrementFailedDNSCleanupCount("zone_records")
+		}
+	}
+}
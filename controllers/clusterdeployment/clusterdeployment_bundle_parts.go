@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdeployment
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// maxCertificateDomains is the number of names Let's Encrypt allows on a single certificate
+const maxCertificateDomains = 100
+
+// packCertificateDomains splits domains into parts of at most max domains. The first part holds
+// the first domains as declared, so that the API and default ingress domains of a bundle stay on
+// its original certificate. The remaining domains are sorted before being split, so that the same
+// domains always end up on the same part whatever order they are declared in.
+func packCertificateDomains(domains []string, max int) [][]string {
+	if len(domains) <= max {
+		return [][]string{domains}
+	}
+
+	parts := [][]string{domains[:max]}
+	rest := append([]string{}, domains[max:]...)
+	sort.Strings(rest)
+	for len(rest) > max {
+		parts = append(parts, rest[:max])
+		rest = rest[max:]
+	}
+	return append(parts, rest)
+}
+
+// createCertificateRequests constructs the CertificateRequests of the certificate bundle cb of cd,
+// splitting its domains across as many as needed to stay under maxCertificateDomains. The first
+// CertificateRequest keeps the name and certificate secret of the bundle, the next ones get a
+// "-part-<n>" suffix on both. All of them are labeled with the bundle and their part. Without split,
+// the bundle gets a single CertificateRequest for all of its domains, whatever their number.
+func createCertificateRequests(cb hivev1.CertificateBundleSpec, domains []string, cd *hivev1.ClusterDeployment, emailAddress string, split bool) []certmanv1alpha1.CertificateRequest {
+	parts := [][]string{domains}
+	if split {
+		parts = packCertificateDomains(domains, maxCertificateDomains)
+	}
+
+	certReqs := []certmanv1alpha1.CertificateRequest{}
+	for i, partDomains := range parts {
+		secretName := cb.CertificateSecretRef.Name
+		if i > 0 {
+			secretName = fmt.Sprintf("%s-part-%d", secretName, i+1)
+		}
+
+		certReq := createCertificateRequest(cb.Name, secretName, partDomains, cd, emailAddress)
+		if i > 0 {
+			certReq.Name = fmt.Sprintf("%s-part-%d", certReq.Name, i+1)
+		}
+		certReq.Labels = map[string]string{
+			certmanv1alpha1.CertificateBundleLabel:      cb.Name,
+			certmanv1alpha1.CertificateBundlePartLabel:  strconv.Itoa(i + 1),
+			certmanv1alpha1.CertificateBundlePartsLabel: strconv.Itoa(len(parts)),
+		}
+		certReqs = append(certReqs, certReq)
+	}
+	return certReqs
+}
+
+// certificateBundleLabelsChanged returns true when the certificate bundle labels of current differ
+// from the desired ones.
+func certificateBundleLabelsChanged(current, desired *certmanv1alpha1.CertificateRequest) bool {
+	for _, label := range []string{certmanv1alpha1.CertificateBundleLabel, certmanv1alpha1.CertificateBundlePartLabel, certmanv1alpha1.CertificateBundlePartsLabel} {
+		if current.Labels[label] != desired.Labels[label] {
+			return true
+		}
+	}
+	return false
+}
+
+// isCertificateBundlePart returns true when cr is one of the CertificateRequests generated for
+// the certificate bundle certBundleName of cd.
+func isCertificateBundlePart(cd *hivev1.ClusterDeployment, cr certmanv1alpha1.CertificateRequest, certBundleName string) bool {
+	if bundle, ok := cr.Labels[certmanv1alpha1.CertificateBundleLabel]; ok {
+		return bundle == certBundleName
+	}
+	// CertificateRequests generated before the bundles were labeled
+	return cr.Name == certificateRequestName(cd, certBundleName)
+}
+
+// certificateBundleStatusName returns the name of the certificate bundle cr reports the status of
+// in the status of cd.
+func certificateBundleStatusName(cd *hivev1.ClusterDeployment, cr certmanv1alpha1.CertificateRequest) string {
+	if bundle, ok := cr.Labels[certmanv1alpha1.CertificateBundleLabel]; ok {
+		return strings.ToLower(bundle)
+	}
+	return strings.TrimPrefix(cr.Name, cd.Name+"-")
+}
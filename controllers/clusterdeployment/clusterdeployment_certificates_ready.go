@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdeployment
+
+import (
+	goerrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+const (
+	// CertificatesReadyCondition is true on ClusterDeployments whose generated certificate bundles
+	// all have a certificate secret holding a valid certificate for their domains. Unlike the other
+	// conditions of the operator it is also set while false, so automation waiting for the
+	// certificates of a cluster, such as the SyncSets delivering them, can gate on it.
+	CertificatesReadyCondition hivev1.ClusterDeploymentConditionType = "CertificatesReady"
+
+	// reasons of the CertificatesReadyCondition
+	certificatesValid              = "CertificatesValid"
+	certificateRequestPending      = "CertificateRequestPending"
+	certificateNotIssued           = "CertificateNotIssued"
+	certificateSecretNotFound      = "CertificateSecretNotFound"
+	certificateInvalid             = "InvalidCertificate"
+	certificateExpired             = "CertificateExpired"
+	certificateMissingDomains      = "CertificateMissingDomains"
+	noCertificateBundlesToGenerate = "NoCertificateBundles"
+)
+
+// certificatesReadiness tells whether the certificates of a ClusterDeployment are ready.
+type certificatesReadiness struct {
+	ready   bool
+	reason  string
+	message string
+	// notAfter is the earliest expiration of the certificates, when they are ready
+	notAfter time.Time
+}
+
+// validFor returns how long the certificates stay ready after now, or 0 when they aren't ready.
+func (c certificatesReadiness) validFor(now time.Time) time.Duration {
+	if !c.ready || c.notAfter.IsZero() || !c.notAfter.After(now) {
+		return 0
+	}
+	return c.notAfter.Sub(now) + time.Second
+}
+
+// certificatesReadiness checks the certificate secret of each CertificateRequest generated for the
+// certificate bundles of cd. The first problem found is the reason the certificates aren't ready.
+func (r *ClusterDeploymentReconciler) certificatesReadiness(cd *hivev1.ClusterDeployment, logger logr.Logger) (certificatesReadiness, error) {
+	crs, err := r.getCurrentCertificateRequests(cd, logger)
+	if err != nil {
+		return certificatesReadiness{}, err
+	}
+
+	now := r.now()
+	readiness := certificatesReadiness{ready: true}
+	notReady := func(reason, format string, args ...interface{}) (certificatesReadiness, error) {
+		return certificatesReadiness{reason: reason, message: fmt.Sprintf(format, args...)}, nil
+	}
+
+	bundles := 0
+	for _, cb := range cd.Spec.CertificateBundles {
+		if !cb.Generate {
+			continue
+		}
+		bundles++
+
+		parts := 0
+		for i := range crs {
+			cr := &crs[i]
+			if !metav1.IsControlledBy(cr, cd) || !isCertificateBundlePart(cd, *cr, cb.Name) {
+				continue
+			}
+			parts++
+
+			if !cr.Status.Issued {
+				return notReady(certificateNotIssued, "CertificateRequest %s of certificate bundle %s is not issued yet", cr.Name, cb.Name)
+			}
+			certificate, err := certificaterequest.GetCertificate(r.Client, cr)
+			if err != nil {
+				var status errors.APIStatus
+				switch {
+				case errors.IsNotFound(err):
+					return notReady(certificateSecretNotFound, "secret %s of CertificateRequest %s does not exist", cr.Spec.CertificateSecret.Name, cr.Name)
+				case goerrors.As(err, &status):
+					return certificatesReadiness{}, err
+				default:
+					return notReady(certificateInvalid, "secret %s of CertificateRequest %s does not hold a valid certificate: %v", cr.Spec.CertificateSecret.Name, cr.Name, err)
+				}
+			}
+			if !now.Before(certificate.NotAfter) {
+				return notReady(certificateExpired, "certificate of CertificateRequest %s expired at %s", cr.Name, certificate.NotAfter.UTC().Format(time.RFC3339))
+			}
+			if now.Before(certificate.NotBefore) {
+				return notReady(certificateInvalid, "certificate of CertificateRequest %s is not valid before %s", cr.Name, certificate.NotBefore.UTC().Format(time.RFC3339))
+			}
+			missing := []string{}
+			for _, domain := range cr.Spec.DnsNames {
+				if !utils.ContainsString(certificate.DNSNames, domain) {
+					missing = append(missing, domain)
+				}
+			}
+			if len(missing) > 0 {
+				return notReady(certificateMissingDomains, "certificate of CertificateRequest %s does not cover %s", cr.Name, strings.Join(missing, ", "))
+			}
+			if readiness.notAfter.IsZero() || certificate.NotAfter.Before(readiness.notAfter) {
+				readiness.notAfter = certificate.NotAfter
+			}
+		}
+		if parts == 0 {
+			return notReady(certificateRequestPending, "no CertificateRequest exists for certificate bundle %s yet", cb.Name)
+		}
+	}
+
+	if bundles == 0 {
+		readiness.reason = noCertificateBundlesToGenerate
+		readiness.message = "no certificate bundle is generated by certman"
+		return readiness, nil
+	}
+	readiness.reason = certificatesValid
+	readiness.message = "the certificates of the generated certificate bundles are valid"
+	return readiness, nil
+}
+
+// setCertificatesReady reports readiness in the CertificatesReadyCondition of cd.
+func (r *ClusterDeploymentReconciler) setCertificatesReady(cd *hivev1.ClusterDeployment, readiness certificatesReadiness) error {
+	status := corev1.ConditionFalse
+	if readiness.ready {
+		status = corev1.ConditionTrue
+	}
+	return r.putCondition(cd, CertificatesReadyCondition, status, readiness.reason, readiness.message)
+}
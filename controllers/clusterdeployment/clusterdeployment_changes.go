@@ -0,0 +1,155 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdeployment
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	// certificateRequestChangesEventReason is the reason of the event listing the changes the
+	// controller is about to make to the CertificateRequests of a ClusterDeployment
+	certificateRequestChangesEventReason = "CertificateRequestChanges"
+	// maxListedDomains is the number of domains of a change listed in its event, the log entry
+	// lists all of them
+	maxListedDomains = 5
+)
+
+// certificateRequestChange is a change to a CertificateRequest of a ClusterDeployment.
+type certificateRequestChange struct {
+	Name string
+	// Action is create, update or delete
+	Action string
+	// AddedDomains are the SANs of a created CertificateRequest, or the SANs added to an updated one
+	AddedDomains []string
+	// RemovedDomains are the SANs removed from an updated CertificateRequest
+	RemovedDomains []string
+}
+
+// String describes c in a few words.
+func (c certificateRequestChange) String() string {
+	switch c.Action {
+	case "create":
+		return fmt.Sprintf("create %s with %s", c.Name, listDomains(c.AddedDomains, ""))
+	case "delete":
+		return fmt.Sprintf("delete %s", c.Name)
+	}
+
+	diff := []string{}
+	if len(c.AddedDomains) > 0 {
+		diff = append(diff, listDomains(c.AddedDomains, "+"))
+	}
+	if len(c.RemovedDomains) > 0 {
+		diff = append(diff, listDomains(c.RemovedDomains, "-"))
+	}
+	if len(diff) == 0 {
+		return fmt.Sprintf("update %s, same SANs", c.Name)
+	}
+	return fmt.Sprintf("update %s: %s", c.Name, strings.Join(diff, " "))
+}
+
+// listDomains lists the first maxListedDomains domains, each with prefix.
+func listDomains(domains []string, prefix string) string {
+	listed := []string{}
+	for i, domain := range domains {
+		if i == maxListedDomains {
+			listed = append(listed, fmt.Sprintf("and %d more", len(domains)-maxListedDomains))
+			break
+		}
+		listed = append(listed, prefix+domain)
+	}
+	return strings.Join(listed, " ")
+}
+
+// planCertificateRequestChanges returns the changes syncing the current CertificateRequests of a
+// ClusterDeployment to the desired ones makes, deleting deleteCRs. Updates only changing labels
+// don't change any certificate and aren't listed.
+func planCertificateRequestChanges(currentCRs, desiredCRs, deleteCRs []certmanv1alpha1.CertificateRequest) []certificateRequestChange {
+	current := map[string]certmanv1alpha1.CertificateRequest{}
+	for _, cr := range currentCRs {
+		current[cr.Name] = cr
+	}
+
+	changes := []certificateRequestChange{}
+	for _, desired := range desiredCRs {
+		existing, ok := current[desired.Name]
+		if !ok {
+			changes = append(changes, certificateRequestChange{Name: desired.Name, Action: "create", AddedDomains: desired.Spec.DnsNames})
+			continue
+		}
+		if reflect.DeepEqual(existing.Spec, desired.Spec) {
+			continue
+		}
+		changes = append(changes, certificateRequestChange{
+			Name:           desired.Name,
+			Action:         "update",
+			AddedDomains:   domainsNotIn(desired.Spec.DnsNames, existing.Spec.DnsNames),
+			RemovedDomains: domainsNotIn(existing.Spec.DnsNames, desired.Spec.DnsNames),
+		})
+	}
+	for _, cr := range deleteCRs {
+		changes = append(changes, certificateRequestChange{Name: cr.Name, Action: "delete", RemovedDomains: cr.Spec.DnsNames})
+	}
+	return changes
+}
+
+// domainsNotIn returns the domains of domains missing from others.
+func domainsNotIn(domains, others []string) []string {
+	missing := []string{}
+	for _, domain := range domains {
+		found := false
+		for _, other := range others {
+			if strings.EqualFold(domain, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, domain)
+		}
+	}
+	return missing
+}
+
+// reportCertificateRequestChanges logs changes and records them in an event on cd before they are
+// made, so that the certificate changes a change of the cluster configuration causes can be
+// reviewed.
+func (r *ClusterDeploymentReconciler) reportCertificateRequestChanges(cd *hivev1.ClusterDeployment, changes []certificateRequestChange, logger logr.Logger) {
+	if len(changes) == 0 {
+		return
+	}
+
+	descriptions := make([]string, 0, len(changes))
+	for _, change := range changes {
+		descriptions = append(descriptions, change.String())
+		logger.Info("changing CertificateRequest", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, change.Name), "action", change.Action,
+			"addedDomains", change.AddedDomains, "removedDomains", change.RemovedDomains)
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Event(cd, corev1.EventTypeNormal, certificateRequestChangesEventReason, strings.Join(descriptions, "; "))
+	}
+}
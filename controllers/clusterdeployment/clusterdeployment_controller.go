@@ -18,10 +18,15 @@ package clusterdeployment
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
+	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
@@ -31,25 +36,62 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/controllers/utils"
+	cClient "github.com/openshift/certman-operator/pkg/clients"
+	"github.com/openshift/certman-operator/pkg/clock"
 	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
 )
 
-var log = logf.Log.WithName("controller_clusterdeployment")
+const controllerName = "controller_clusterdeployment"
 
 const (
 	ClusterDeploymentManagedLabel   = "api.openshift.com/managed"
-	hiveRelocationAnnotation        = "hive.openshift.io/relocate"
-	hiveRelocationOutgoingValue     = "outgoing"
 	fakeClusterDeploymentAnnotation = "managed.openshift.com/fake"
+	clusterDeploymentType           = "ClusterDeployment"
+
+	// NotificationEmailAnnotation sets the notification email of a ClusterDeployment's certificates
+	// when the operator has no default notification email configured.
+	NotificationEmailAnnotation = "certman.managed.openshift.io/notification-email"
+
+	// IngressDomainPolicyAnnotation overrides the operator's IngressDomainPolicy for the ingress
+	// domains of a ClusterDeployment.
+	IngressDomainPolicyAnnotation = "certman.managed.openshift.io/ingress-domain-policy"
+
+	// ConsoleDomainAnnotation and OAuthDomainAnnotation list the comma separated custom hostnames
+	// of the console and OAuth routes of a cluster, set through the componentRoutes of its ingress
+	// config. They are added to the certificate of the default ingress.
+	ConsoleDomainAnnotation = "certman.managed.openshift.io/console-domain"
+	OAuthDomainAnnotation   = "certman.managed.openshift.io/oauth-domain"
+
+	// defaultIngressName is the name of the ingress serving the console and OAuth routes
+	defaultIngressName = "default"
+
+	// certmanDegradedCondition is set on ClusterDeployments whose CertificateRequests can't be synced
+	certmanDegradedCondition  hivev1.ClusterDeploymentConditionType = "CertmanDegraded"
+	notificationEmailNotFound                                       = "NotificationEmailNotFound"
+
+	// certmanInvalidDomainsCondition is set on ClusterDeployments with certificate domains outside
+	// of their base domain and the allowed DNS zones, or outside of the approved base domains
+	certmanInvalidDomainsCondition hivev1.ClusterDeploymentConditionType = "CertmanInvalidDomains"
+
+	// certmanMissingDependencyCondition is set on ClusterDeployments whose credentials or admin
+	// kubeconfig secrets don't exist yet
+	certmanMissingDependencyCondition hivev1.ClusterDeploymentConditionType = "MissingDependency"
+	secretNotFound                                                          = "SecretNotFound"
+
+	// reasons reported when the deletion of a ClusterDeployment is blocked by the finalizer
+	finalizerBlockedCertificateRequestDeletion = "certificaterequest_deletion_failed"
+	finalizerBlockedFinalizerRemoval           = "finalizer_removal_failed"
 )
 
 var _ reconcile.Reconciler = &ClusterDeploymentReconciler{}
@@ -58,12 +100,95 @@ var _ reconcile.Reconciler = &ClusterDeploymentReconciler{}
 type ClusterDeploymentReconciler struct {
 	Client client.Client
 	Scheme *runtime.Scheme
+	// ClientBuilder builds the platform clients cleaning up the challenge records of a deleted
+	// ClusterDeployment. The cleanup is skipped when it is nil.
+	ClientBuilder func(reqLogger logr.Logger, kubeClient client.Client, platfromSecret certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (cClient.Client, error)
+	// Clock tells the time requeues and finalizer metrics are computed at, it defaults to the
+	// system clock
+	Clock clock.Clock
+	// Recorder records the changes about to be made to the CertificateRequests of a
+	// ClusterDeployment as events on it. No events are recorded when it is nil.
+	Recorder record.EventRecorder
+	// RequeueIntervals overrides how long a ClusterDeployment waiting on something outside of the
+	// operator waits before it is reconciled again
+	RequeueIntervals utils.RequeueIntervals
+	// ObservationMode reports the changes the controller would make to the CertificateRequests of
+	// ClusterDeployments, in a condition, a metric and events, without making them. Finalizers
+	// aren't added or removed either.
+	ObservationMode bool
+}
+
+// now returns the current time of the Clock of r.
+func (r *ClusterDeploymentReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
 }
 
 // Reconcile reads that state of the cluster for a ClusterDeployment object and sets up
-// any needed CertificateRequest objects.
-func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
-	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+// any needed CertificateRequest objects. A panic while reconciling is turned into an error
+// rather than crashing the operator.
+func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+	ctx = logging.IntoContext(ctx, logging.WithCluster(logging.FromContext(ctx), request.Namespace, request.Name))
+	defer func() {
+		if p := recover(); p != nil {
+			result, err = reconcile.Result{}, r.handleReconcilePanic(ctx, request, p)
+		}
+	}()
+
+	result, err = r.reconcileClusterDeployment(ctx, request)
+	if err == nil {
+		r.clearReconcilePanics(ctx, request)
+	}
+	return result, err
+}
+
+// clearReconcilePanics forgets the panics recorded on the ClusterDeployment of request after it was
+// reconciled successfully. Failures are logged, the count is cleared by a later reconcile.
+func (r *ClusterDeploymentReconciler) clearReconcilePanics(ctx context.Context, request reconcile.Request) {
+	cd := &hivev1.ClusterDeployment{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, cd); err != nil {
+		if !errors.IsNotFound(err) {
+			logging.FromContext(ctx).Error(err, "unable to clear the panics recorded on ClusterDeployment")
+		}
+		return
+	}
+	if err := utils.ClearReconcilePanics(r.Client, cd); err != nil && !errors.IsNotFound(err) {
+		logging.FromContext(ctx).Error(err, "unable to clear the panics recorded on ClusterDeployment")
+	}
+}
+
+// handleReconcilePanic logs a panic recovered while reconciling request with the logger of ctx and
+// records it on the ClusterDeployment, which is marked as a poison pill and skipped by further
+// reconciles once it has panicked too many times.
+func (r *ClusterDeploymentReconciler) handleReconcilePanic(ctx context.Context, request reconcile.Request, p interface{}) error {
+	reqLogger := logging.FromContext(ctx)
+	panicErr := fmt.Errorf("recovered from panic: %v", p)
+	reqLogger.Error(panicErr, "panic while reconciling ClusterDeployment", "stacktrace", string(debug.Stack()))
+	localmetrics.IncrementReconcilePanicCount(controllerName)
+
+	cd := &hivev1.ClusterDeployment{}
+	if err := r.Client.Get(context.TODO(), request.NamespacedName, cd); err != nil {
+		reqLogger.Error(err, "unable to record panic on ClusterDeployment")
+		return panicErr
+	}
+
+	poisoned, err := utils.RecordReconcilePanic(r.Client, cd)
+	if err != nil {
+		reqLogger.Error(err, "unable to record panic on ClusterDeployment")
+		return panicErr
+	}
+	if poisoned {
+		reqLogger.Info(fmt.Sprintf("ClusterDeployment panicked %d times and is marked with the %s annotation, it will not be reconciled until the annotation is removed", utils.MaxReconcilePanics, utils.PoisonPillAnnotation))
+	}
+
+	return panicErr
+}
+
+// reconcileClusterDeployment does the actual work of Reconcile.
+func (r *ClusterDeploymentReconciler) reconcileClusterDeployment(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := logging.FromContext(ctx)
 	reqLogger.Info("reconciling ClusterDeployment")
 
 	timer := prometheus.NewTimer(localmetrics.MetricClusterDeploymentReconcileDuration)
@@ -87,6 +212,12 @@ func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, request rec
 		return reconcile.Result{}, err
 	}
 
+	// Opting out is one way, the annotations requesting it are ignored once it is done.
+	if isOptedOut(cd) {
+		reqLogger.Info(fmt.Sprintf("not reconciling: ClusterDeployment is marked with the %s annotation", OptedOutAnnotation))
+		return reconcile.Result{}, nil
+	}
+
 	// Do not make certificate request if the cluster is not a Red Hat managed cluster.
 	val, ok := cd.Labels[ClusterDeploymentManagedLabel]
 	if !ok || val != "true" {
@@ -108,20 +239,24 @@ func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, request rec
 	}
 
 	// Do not reconcile if the cluster is being relocated
-	for a, v := range cd.Annotations {
-		if a == hiveRelocationAnnotation && strings.Split(v, "/")[1] == hiveRelocationOutgoingValue {
-			reqLogger.Info(fmt.Sprintf("Not reconciling: ClusterDeployment %s is relocating", cd.Name))
-			return reconcile.Result{}, nil
-		}
+	if utils.IsRelocating(cd) {
+		reqLogger.Info(fmt.Sprintf("Not reconciling: ClusterDeployment %s is relocating", cd.Name))
+		return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitRelocation)}, nil
 	}
 
 	// Check if CertificateResource is being deleted, if it's deleted remove the finalizer if it exists.
 	if !cd.DeletionTimestamp.IsZero() {
+		if r.ObservationMode {
+			reqLogger.Info("observation mode: not deleting the CertificateRequests of the ClusterDeployment")
+			localmetrics.ClearObservedCertificateRequestChanges(cd.Namespace, cd.Name)
+			return reconcile.Result{}, nil
+		}
 		// The object is being deleted
 		if utils.ContainsString(cd.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
 			reqLogger.Info("deleting the CertificateRequest for the ClusterDeployment")
 			if err := r.handleDelete(cd, reqLogger); err != nil {
 				reqLogger.Error(err, "error deleting CertificateRequests")
+				localmetrics.UpdateFinalizerBlockedDeletion(clusterDeploymentType, cd.Namespace, cd.Name, finalizerBlockedCertificateRequestDeletion, cd.DeletionTimestamp.Time, r.now())
 				return reconcile.Result{}, err
 			}
 
@@ -130,13 +265,45 @@ func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, request rec
 			cd.ObjectMeta.Finalizers = utils.RemoveString(cd.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
 			if err := r.Client.Patch(context.TODO(), cd, baseToPatch); err != nil {
 				reqLogger.Error(err, "error removing finalizer from ClusterDeployment")
+				localmetrics.UpdateFinalizerBlockedDeletion(clusterDeploymentType, cd.Namespace, cd.Name, finalizerBlockedFinalizerRemoval, cd.DeletionTimestamp.Time, r.now())
 				return reconcile.Result{}, err
 			}
+			localmetrics.ClearFinalizerBlockedDeletion(clusterDeploymentType, cd.Namespace, cd.Name)
+			localmetrics.ClearClusterMissingDependencies(cd.Namespace, cd.Name)
 		}
 		return reconcile.Result{}, nil
 	}
+
+	// Skip objects that keep crashing the reconcile loop. Deletions still go through so the
+	// finalizer of a poison pill doesn't block the deletion of the cluster.
+	if utils.IsPoisonPill(cd) {
+		reqLogger.Info(fmt.Sprintf("not reconciling: ClusterDeployment is marked with the %s annotation", utils.PoisonPillAnnotation))
+		localmetrics.IncrementPoisonPillSkipCount(controllerName)
+		return reconcile.Result{}, nil
+	}
+
+	policy, optOutRequested, err := optOutPolicy(cd)
+	if !optOutRequested {
+		if err := r.removeCondition(cd, certmanOptOutCondition); err != nil {
+			reqLogger.Error(err, "error removing opt-out condition from ClusterDeployment")
+			return reconcile.Result{}, err
+		}
+	} else if err != nil {
+		// Keep managing the certificates until the opt-out is fixed or confirmed.
+		reqLogger.Info(fmt.Sprintf("not opting out: %v", err))
+		if err := r.setCondition(cd, certmanOptOutCondition, corev1.ConditionTrue, optOutNotConfirmed, err.Error()); err != nil {
+			reqLogger.Error(err, "error setting opt-out condition on ClusterDeployment")
+			return reconcile.Result{}, err
+		}
+	} else if r.ObservationMode {
+		reqLogger.Info("observation mode: not opting out of certman", "policy", policy)
+	} else {
+		reqLogger.Info("opting out of certman", "policy", policy)
+		return r.optOut(cd, policy, reqLogger)
+	}
+
 	// add finalizer
-	if !utils.ContainsString(cd.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
+	if !r.ObservationMode && !utils.ContainsString(cd.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
 		reqLogger.Info("adding CertmanOperator finalizer to the ClusterDeployment")
 		baseToPatch := client.MergeFrom(cd.DeepCopy())
 		cd.ObjectMeta.Finalizers = append(cd.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
@@ -146,13 +313,70 @@ func (r *ClusterDeploymentReconciler) Reconcile(ctx context.Context, request rec
 		}
 	}
 
+	// Secrets are often created after the ClusterDeployment, so wait for them rather than failing
+	// every reconcile until they show up.
+	missing, err := r.missingDependencies(cd)
+	if err != nil {
+		reqLogger.Error(err, "error looking up ClusterDeployment dependencies")
+		return reconcile.Result{}, err
+	}
+	if len(missing) > 0 {
+		message := missingDependenciesMessage(missing)
+		reqLogger.Info(fmt.Sprintf("not syncing CertificateRequests: %s", message))
+		dependencies := []string{}
+		for _, dependency := range missing {
+			dependencies = append(dependencies, dependency.name)
+		}
+		localmetrics.SetClusterMissingDependencies(cd.Namespace, cd.Name, dependencies)
+		if err := r.setCondition(cd, certmanMissingDependencyCondition, corev1.ConditionTrue, secretNotFound, message); err != nil {
+			reqLogger.Error(err, "error setting missing dependency condition on ClusterDeployment")
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: missingDependencyRequeueDelay(cd, r.now())}, nil
+	}
+	localmetrics.ClearClusterMissingDependencies(cd.Namespace, cd.Name)
+	if err := r.setCondition(cd, certmanMissingDependencyCondition, corev1.ConditionFalse, "DependenciesFound", "all referenced secrets exist"); err != nil {
+		reqLogger.Error(err, "error clearing missing dependency condition on ClusterDeployment")
+		return reconcile.Result{}, err
+	}
+
 	if err := r.syncCertificateRequests(cd, reqLogger); err != nil {
+		if goerrors.Is(err, utils.ErrNotificationEmailNotFound) {
+			// Nothing can be issued until an email is configured, so report it on the
+			// ClusterDeployment and check again later instead of failing the reconcile.
+			reqLogger.Info(fmt.Sprintf("not syncing CertificateRequests: %v", err))
+			if err := r.setCondition(cd, certmanDegradedCondition, corev1.ConditionTrue, notificationEmailNotFound, err.Error()); err != nil {
+				reqLogger.Error(err, "error setting degraded condition on ClusterDeployment")
+				return reconcile.Result{}, err
+			}
+			return reconcile.Result{RequeueAfter: r.RequeueIntervals.After(utils.WaitNotificationEmail)}, nil
+		}
 		reqLogger.Error(err, "error syncing CertificateRequests")
 		return reconcile.Result{}, err
 	}
 
+	if r.ObservationMode {
+		reqLogger.Info("done observing")
+		return reconcile.Result{}, nil
+	}
+
+	if err := r.setCondition(cd, certmanDegradedCondition, corev1.ConditionFalse, "CertificateRequestsSynced", "CertificateRequests are in sync"); err != nil {
+		reqLogger.Error(err, "error clearing degraded condition on ClusterDeployment")
+		return reconcile.Result{}, err
+	}
+
+	readiness, err := r.certificatesReadiness(cd, reqLogger)
+	if err != nil {
+		reqLogger.Error(err, "error checking the certificates of the ClusterDeployment")
+		return reconcile.Result{}, err
+	}
+	if err := r.setCertificatesReady(cd, readiness); err != nil {
+		reqLogger.Error(err, "error setting certificates ready condition on ClusterDeployment")
+		return reconcile.Result{}, err
+	}
+
 	reqLogger.Info("done syncing")
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: readiness.validFor(r.now())}, nil
 }
 
 // syncCertificateRequests generates/updates a CertificateRequest for each CertificateBundle
@@ -168,6 +392,21 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 		return err
 	}
 
+	emailAddress := ""
+	ingressPolicy := ingressDomainPolicy(cd, utils.GetIngressDomainPolicy(r.Client), logger)
+	allowedZones := append([]string{cd.Spec.BaseDomain}, utils.GetAllowedDNSZones(r.Client)...)
+	approvedDomains, err := utils.GetApprovedBaseDomains(r.Client)
+	if err != nil {
+		logger.Error(err, "error reading the approved base domains")
+		return err
+	}
+	splitBundles := utils.GetFeatures(r.Client, cd.Annotations).Enabled(utils.FeatureSplitCertificates)
+
+	// CertificateRequests of bundles with invalid or unapproved domains are neither updated nor deleted
+	invalidDomains := []string{}
+	unapprovedDomains := []string{}
+	skippedBundles := []string{}
+
 	// for each certbundle with generate==true make a CertificateRequest
 	for _, cb := range cd.Spec.CertificateBundles {
 
@@ -177,17 +416,32 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 		)
 
 		if cb.Generate {
-			domains := getDomainsForCertBundle(cb, cd, logger)
+			domains := getDomainsForCertBundle(cb, cd, ingressPolicy, logger)
 
-			emailAddress, err := utils.GetDefaultNotificationEmailAddress(r.Client)
-			if err != nil {
-				logger.Error(err, err.Error())
-				return err
+			if emailAddress == "" {
+				emailAddress, err = r.getNotificationEmailAddress(cd)
+				if err != nil {
+					return err
+				}
+			}
+
+			if invalid := utils.DomainsOutsideZones(domains, allowedZones); len(invalid) > 0 {
+				logger.Info(fmt.Sprintf("not syncing certificate bundle %v: domains %v are not in the allowed DNS zones %v", cb.Name, invalid, allowedZones))
+				invalidDomains = append(invalidDomains, invalid...)
+				skippedBundles = append(skippedBundles, cb.Name)
+				continue
+			}
+			if len(approvedDomains) > 0 {
+				if unapproved := utils.DomainsOutsideZones(domains, approvedDomains); len(unapproved) > 0 {
+					logger.Info(fmt.Sprintf("not syncing certificate bundle %v: domains %v are not under the approved base domains %v", cb.Name, unapproved, approvedDomains))
+					unapprovedDomains = append(unapprovedDomains, unapproved...)
+					skippedBundles = append(skippedBundles, cb.Name)
+					continue
+				}
 			}
 
 			if len(domains) > 0 {
-				certReq := createCertificateRequest(cb.Name, cb.CertificateSecretRef.Name, domains, cd, emailAddress)
-				desiredCRs = append(desiredCRs, certReq)
+				desiredCRs = append(desiredCRs, createCertificateRequests(cb, domains, cd, emailAddress, splitBundles)...)
 			} else {
 				err := fmt.Errorf("no domains provided for certificate bundle %v in the cluster deployment %v", cb.Name, cd.Name)
 				logger.Error(err, err.Error())
@@ -195,10 +449,38 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 		}
 	}
 
+	switch {
+	case len(invalidDomains) > 0:
+		message := fmt.Sprintf("domains %s are not subdomains of %s", strings.Join(invalidDomains, ", "), strings.Join(allowedZones, ", "))
+		if len(unapprovedDomains) > 0 {
+			message += fmt.Sprintf("; domains %s are not under the approved base domains %s", strings.Join(unapprovedDomains, ", "), strings.Join(approvedDomains, ", "))
+		}
+		err = r.setCondition(cd, certmanInvalidDomainsCondition, corev1.ConditionTrue, "DomainsNotInAllowedZones", message)
+	case len(unapprovedDomains) > 0:
+		message := fmt.Sprintf("domains %s are not under the approved base domains %s", strings.Join(unapprovedDomains, ", "), strings.Join(approvedDomains, ", "))
+		err = r.setCondition(cd, certmanInvalidDomainsCondition, corev1.ConditionTrue, "DomainsNotApproved", message)
+	default:
+		err = r.setCondition(cd, certmanInvalidDomainsCondition, corev1.ConditionFalse, "DomainsValid", "all certificate domains are in allowed DNS zones")
+	}
+	if err != nil {
+		logger.Error(err, "error setting invalid domains condition on ClusterDeployment")
+		return err
+	}
+
 	deleteCRs := []certmanv1alpha1.CertificateRequest{}
 
 	// find any extra certificateRequests and mark them for deletion
 	for i, currentCR := range currentCRs {
+		skipped := false
+		for _, bundle := range skippedBundles {
+			if isCertificateBundlePart(cd, currentCR, bundle) {
+				skipped = true
+				break
+			}
+		}
+		if skipped {
+			continue
+		}
 		found := false
 		for _, desiredCR := range desiredCRs {
 			if desiredCR.Name == currentCR.Name {
@@ -211,7 +493,24 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 		}
 	}
 
+	changes := planCertificateRequestChanges(currentCRs, desiredCRs, deleteCRs)
+	if r.ObservationMode {
+		if err := r.reportObservedChanges(cd, changes, logger); err != nil {
+			logger.Error(err, "error reporting observed changes on ClusterDeployment")
+			return err
+		}
+		return nil
+	}
+	if err := r.clearObservedChanges(cd); err != nil {
+		logger.Error(err, "error clearing observation mode condition on ClusterDeployment")
+		return err
+	}
+	r.reportCertificateRequestChanges(cd, changes, logger)
+
 	certBundleStatusList := []hivev1.CertificateBundleStatus{}
+	// the index of each bundle in certBundleStatusList, a bundle split across several
+	// CertificateRequests is generated once all of them are issued
+	certBundleStatusIndex := map[string]int{}
 	errs := []error{}
 	// create/update the desired certificaterequests
 	for _, desiredCR := range desiredCRs {
@@ -219,13 +518,13 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 		currentCR := &certmanv1alpha1.CertificateRequest{}
 		searchKey := types.NamespacedName{Name: desiredCR.Name, Namespace: desiredCR.Namespace}
 		certBundleStatus := hivev1.CertificateBundleStatus{}
-		certBundleStatus.Name = strings.TrimPrefix(desiredCR.Name, cd.Name+"-")
+		certBundleStatus.Name = certificateBundleStatusName(cd, desiredCR)
 		if err := r.Client.Get(context.TODO(), searchKey, currentCR); err != nil {
 			certBundleStatus.Generated = false
 			if errors.IsNotFound(err) {
 				// create
 				if err := controllerutil.SetControllerReference(cd, &desiredCR, r.Scheme); err != nil {
-					logger.Error(err, "error setting owner reference", "certrequest", desiredCR.Name)
+					logger.Error(err, "error setting owner reference", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, desiredCR.Name))
 					errs = append(errs, err)
 					continue
 				}
@@ -243,11 +542,25 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 			}
 		} else {
 			// update or no update needed
-			if !reflect.DeepEqual(currentCR.Spec, desiredCR.Spec) {
-				certBundleStatus.Generated = false
+			specChanged := !reflect.DeepEqual(currentCR.Spec, desiredCR.Spec)
+			if specChanged || certificateBundleLabelsChanged(currentCR, &desiredCR) {
+				// relabeling doesn't reissue the certificate
+				certBundleStatus.Generated = !specChanged && currentCR.Status.Issued
+				if currentCR.Spec.ACMEDNSDomain != desiredCR.Spec.ACMEDNSDomain {
+					if err := r.migrateBaseDomain(cd, currentCR, logger); err != nil {
+						errs = append(errs, err)
+						continue
+					}
+				}
 				currentCR.Spec = desiredCR.Spec
+				if currentCR.Labels == nil {
+					currentCR.Labels = map[string]string{}
+				}
+				for label, value := range desiredCR.Labels {
+					currentCR.Labels[label] = value
+				}
 				if err := r.Client.Update(context.TODO(), currentCR); err != nil {
-					logger.Error(err, "error updating certificaterequest", "certrequest", currentCR.Name)
+					logger.Error(err, "error updating certificaterequest", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, currentCR.Name))
 					errs = append(errs, err)
 					continue
 				}
@@ -258,9 +571,14 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 					certBundleStatus.Generated = false
 				}
 
-				logger.Info("no update needed for certificaterequest", "certrequest", desiredCR.Name)
+				logger.Info("no update needed for certificaterequest", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, desiredCR.Name))
 			}
 		}
+		if i, ok := certBundleStatusIndex[certBundleStatus.Name]; ok {
+			certBundleStatusList[i].Generated = certBundleStatusList[i].Generated && certBundleStatus.Generated
+			continue
+		}
+		certBundleStatusIndex[certBundleStatus.Name] = len(certBundleStatusList)
 		certBundleStatusList = append(certBundleStatusList, certBundleStatus)
 	}
 	cd.Status.CertificateBundles = certBundleStatusList
@@ -273,7 +591,7 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 		deleteCR := deleteCR
 		logger.Info(fmt.Sprintf("deleting CertificateRequest resource config  %v", deleteCR.Name))
 		if err := r.Client.Delete(context.TODO(), &deleteCR); err != nil {
-			logger.Error(err, "error deleting CertificateRequest that is no longer needed", "certrequest", deleteCR.Name)
+			logger.Error(err, "error deleting CertificateRequest that is no longer needed", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, deleteCR.Name))
 			return err
 		}
 	}
@@ -291,6 +609,103 @@ func (r *ClusterDeploymentReconciler) syncCertificateRequests(cd *hivev1.Cluster
 	return nil
 }
 
+// getNotificationEmailAddress returns the operator's default notification email, or the one set in
+// the NotificationEmailAnnotation of cd when there is no default.
+func (r *ClusterDeploymentReconciler) getNotificationEmailAddress(cd *hivev1.ClusterDeployment) (string, error) {
+	emailAddress, err := utils.GetDefaultNotificationEmailAddress(r.Client)
+	if err == nil {
+		return emailAddress, nil
+	}
+	if !goerrors.Is(err, utils.ErrNotificationEmailNotFound) {
+		return "", err
+	}
+
+	if emailAddress := cd.Annotations[NotificationEmailAnnotation]; emailAddress != "" {
+		return emailAddress, nil
+	}
+
+	return "", err
+}
+
+// setCondition sets the conditionType condition of cd, patching the ClusterDeployment only if the
+// condition changes. A condition that was never set is not added just to be cleared.
+func (r *ClusterDeploymentReconciler) setCondition(cd *hivev1.ClusterDeployment, conditionType hivev1.ClusterDeploymentConditionType, status corev1.ConditionStatus, reason string, message string) error {
+	if status == corev1.ConditionFalse && findCondition(cd, conditionType) == nil {
+		return nil
+	}
+	return r.putCondition(cd, conditionType, status, reason, message)
+}
+
+// putCondition sets the conditionType condition of cd like setCondition, adding it even when it is
+// false, for conditions whose absence doesn't mean false.
+func (r *ClusterDeploymentReconciler) putCondition(cd *hivev1.ClusterDeployment, conditionType hivev1.ClusterDeploymentConditionType, status corev1.ConditionStatus, reason string, message string) error {
+	index := -1
+	for i, condition := range cd.Status.Conditions {
+		if condition.Type == conditionType {
+			index = i
+			break
+		}
+	}
+
+	if index != -1 {
+		existing := cd.Status.Conditions[index]
+		if existing.Status == status && existing.Reason == reason && existing.Message == message {
+			return nil
+		}
+	}
+
+	now := metav1.Now()
+	condition := hivev1.ClusterDeploymentCondition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	}
+
+	baseToPatch := client.MergeFrom(cd.DeepCopy())
+	if index == -1 {
+		cd.Status.Conditions = append(cd.Status.Conditions, condition)
+	} else {
+		if cd.Status.Conditions[index].Status == status {
+			condition.LastTransitionTime = cd.Status.Conditions[index].LastTransitionTime
+		}
+		cd.Status.Conditions[index] = condition
+	}
+
+	return r.Client.Status().Patch(context.TODO(), cd, baseToPatch)
+}
+
+// removeCondition removes the conditionType condition of cd, for conditions that are only set while
+// what they report applies. The ClusterDeployment is patched only if the condition is set.
+func (r *ClusterDeploymentReconciler) removeCondition(cd *hivev1.ClusterDeployment, conditionType hivev1.ClusterDeploymentConditionType) error {
+	if findCondition(cd, conditionType) == nil {
+		return nil
+	}
+
+	baseToPatch := client.MergeFrom(cd.DeepCopy())
+	conditions := []hivev1.ClusterDeploymentCondition{}
+	for _, condition := range cd.Status.Conditions {
+		if condition.Type != conditionType {
+			conditions = append(conditions, condition)
+		}
+	}
+	cd.Status.Conditions = conditions
+
+	return r.Client.Status().Patch(context.TODO(), cd, baseToPatch)
+}
+
+// findCondition returns the conditionType condition of cd, or nil when it isn't set.
+func findCondition(cd *hivev1.ClusterDeployment, conditionType hivev1.ClusterDeploymentConditionType) *hivev1.ClusterDeploymentCondition {
+	for i := range cd.Status.Conditions {
+		if cd.Status.Conditions[i].Type == conditionType {
+			return &cd.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
 // getCurrentCertificateRequests returns an array of CertificateRequests owned by the cluster, within the clusters namespace.
 func (r *ClusterDeploymentReconciler) getCurrentCertificateRequests(cd *hivev1.ClusterDeployment, logger logr.Logger) ([]certmanv1alpha1.CertificateRequest, error) {
 	certReqsForCluster := []certmanv1alpha1.CertificateRequest{}
@@ -309,8 +724,9 @@ func (r *ClusterDeploymentReconciler) getCurrentCertificateRequests(cd *hivev1.C
 
 // getDomainsForCertBundle returns a slice of domains after validating if CertificateBundleSpec.Name
 // matches the default control plane name and appending any other matching domain names from the rest
-// of the control plane and ingress list to the domain slice.
-func getDomainsForCertBundle(cb hivev1.CertificateBundleSpec, cd *hivev1.ClusterDeployment, logger logr.Logger) []string {
+// of the control plane and ingress list to the domain slice. Ingress domains are added according to
+// ingressPolicy.
+func getDomainsForCertBundle(cb hivev1.CertificateBundleSpec, cd *hivev1.ClusterDeployment, ingressPolicy certmanv1alpha1.IngressDomainPolicy, logger logr.Logger) []string {
 	// declare a slice to hold domains
 	domains := []string{}
 	dLogger := logger.WithValues("CertificateBundle", cb.Name)
@@ -330,6 +746,12 @@ func getDomainsForCertBundle(cb hivev1.CertificateBundleSpec, cd *hivev1.Cluster
 			dLogger.Info("RH private control plane config DNS name: " + extraDomain)
 			domains = append(domains, extraDomain)
 		}
+
+		// Hive moves to the API URL override once it answers, so its host must be on the certificate too
+		if overrideDomain := apiURLOverrideDomain(cd.Spec.ControlPlaneConfig.APIURLOverride); overrideDomain != "" && !utils.ContainsString(domains, overrideDomain) {
+			dLogger.Info("API URL override DNS name: " + overrideDomain)
+			domains = append(domains, overrideDomain)
+		}
 	}
 
 	// now check the rest of the control plane
@@ -343,30 +765,108 @@ func getDomainsForCertBundle(cb hivev1.CertificateBundleSpec, cd *hivev1.Cluster
 	// and lastly the ingress list
 	for _, ingress := range cd.Spec.Ingress {
 		if ingress.ServingCertificate == cb.Name {
-			ingressDomain := ingress.Domain
-
-			// always request wildcard certificates for the ingress domain
-			if !strings.HasPrefix(ingressDomain, "*.") {
-				ingressDomain = fmt.Sprintf("*.%s", ingress.Domain)
+			for _, ingressDomain := range ingressDomains(ingress.Domain, ingressPolicy) {
+				dLogger.Info("ingress domain added to certificate request: " + ingressDomain)
+				domains = append(domains, ingressDomain)
+			}
+			if ingress.Name == defaultIngressName {
+				for _, routeDomain := range componentRouteDomains(cd) {
+					if !utils.ContainsString(domains, routeDomain) {
+						dLogger.Info("component route domain added to certificate request: " + routeDomain)
+						domains = append(domains, routeDomain)
+					}
+				}
 			}
-
-			dLogger.Info("ingress domain added to certificate request: " + ingressDomain)
-			domains = append(domains, ingressDomain)
 		}
 	}
 
 	return domains
 }
 
+// componentRouteDomains returns the custom console and OAuth hostnames set by the
+// ConsoleDomainAnnotation and OAuthDomainAnnotation of cd.
+func componentRouteDomains(cd *hivev1.ClusterDeployment) []string {
+	domains := []string{}
+	for _, annotation := range []string{ConsoleDomainAnnotation, OAuthDomainAnnotation} {
+		for _, domain := range strings.Split(cd.Annotations[annotation], ",") {
+			domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+			if domain != "" && !utils.ContainsString(domains, domain) {
+				domains = append(domains, domain)
+			}
+		}
+	}
+	return domains
+}
+
+// apiURLOverrideDomain returns the host of the apiURLOverride of a ClusterDeployment, which may
+// be set with or without a scheme and port. An empty string is returned when there is no override
+// or its host is an IP address, which can't be on a Let's Encrypt certificate.
+func apiURLOverrideDomain(apiURLOverride string) string {
+	if apiURLOverride == "" {
+		return ""
+	}
+	if !strings.Contains(apiURLOverride, "://") {
+		apiURLOverride = "https://" + apiURLOverride
+	}
+
+	u, err := url.Parse(apiURLOverride)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+
+	return host
+}
+
+// ingressDomains returns the SANs to request for the ingress domain according to policy. Domains that
+// are declared as a wildcard are always requested as declared.
+func ingressDomains(domain string, policy certmanv1alpha1.IngressDomainPolicy) []string {
+	if strings.HasPrefix(domain, "*.") {
+		return []string{domain}
+	}
+
+	wildcardDomain := fmt.Sprintf("*.%s", domain)
+	switch policy {
+	case certmanv1alpha1.IngressDomainPolicyExact:
+		return []string{domain}
+	case certmanv1alpha1.IngressDomainPolicyBoth:
+		return []string{wildcardDomain, domain}
+	default:
+		return []string{wildcardDomain}
+	}
+}
+
+// ingressDomainPolicy returns the IngressDomainPolicy set by the IngressDomainPolicyAnnotation of cd,
+// or defaultPolicy when the annotation is missing or invalid.
+func ingressDomainPolicy(cd *hivev1.ClusterDeployment, defaultPolicy certmanv1alpha1.IngressDomainPolicy, logger logr.Logger) certmanv1alpha1.IngressDomainPolicy {
+	annotation, ok := cd.Annotations[IngressDomainPolicyAnnotation]
+	if !ok {
+		return defaultPolicy
+	}
+
+	policy := certmanv1alpha1.IngressDomainPolicy(annotation)
+	if !utils.ValidIngressDomainPolicy(policy) {
+		logger.Info(fmt.Sprintf("ignoring invalid %s annotation %q, using %s", IngressDomainPolicyAnnotation, annotation, defaultPolicy))
+		return defaultPolicy
+	}
+
+	return policy
+}
+
+// certificateRequestName returns the name of the CertificateRequest for a certificate bundle of cd.
+func certificateRequestName(cd *hivev1.ClusterDeployment, certBundleName string) string {
+	return strings.ToLower(fmt.Sprintf("%s-%s", cd.Name, certBundleName))
+}
+
 // createCertificateRequest constructs a CertificateRequest constructed by the
 // certmanv1alpha1.CertificateRequest schema.
 func createCertificateRequest(certBundleName string, secretName string, domains []string, cd *hivev1.ClusterDeployment, emailAddress string) certmanv1alpha1.CertificateRequest {
-	name := fmt.Sprintf("%s-%s", cd.Name, certBundleName)
-	name = strings.ToLower(name)
-
 	cr := certmanv1alpha1.CertificateRequest{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
+			Name:      certificateRequestName(cd, certBundleName),
 			Namespace: cd.Namespace,
 		},
 		Spec: certmanv1alpha1.CertificateRequestSpec{
@@ -423,7 +923,8 @@ func createCertificateRequest(certBundleName string, secretName string, domains
 // SetupWithManager sets up the controller with the Manager.
 func (r *ClusterDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&hivev1.ClusterDeployment{}).
+		// the events of a relocating cluster would only be discarded by the reconcile
+		For(&hivev1.ClusterDeployment{}, builder.WithPredicates(utils.NotRelocatingPredicate)).
 		Owns(&certmanv1alpha1.CertificateRequest{}).
 		Complete(r)
 }
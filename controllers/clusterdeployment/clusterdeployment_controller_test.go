@@ -18,19 +18,38 @@ package clusterdeployment
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/controllers/utils"
+	cClient "github.com/openshift/certman-operator/pkg/clients"
+	mockclient "github.com/openshift/certman-operator/pkg/clients/mock"
 	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/testutils"
 	hiveapis "github.com/openshift/hive/apis"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	hivev1aws "github.com/openshift/hive/apis/hive/v1/aws"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -194,7 +213,7 @@ func TestClusterDeploymentReconciler(t *testing.T) {
 
 			// Create a NewFakeClient to interact with Reconcile functionality.
 			// localObjects are defined within each test
-			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(test.localObjects...).Build()
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(test.localObjects...).WithStatusSubresource(&hivev1.ClusterDeployment{}).Build()
 
 			// Instantiate a ClusterDeploymentReconciler type to act as a reconcile client
 			rcd := &ClusterDeploymentReconciler{
@@ -296,6 +315,995 @@ func TestCertificateRequestDeletion(t *testing.T) {
 		err = fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testClusterName}, cd)
 		assert.NotNil(t, err, "unable to delete ClusterDeployment: %q", err)
 	})
+
+	t.Run("Test a conflict removing the finalizer is retried", func(t *testing.T) {
+		fakeClient := testutils.NewFaultClient(
+			fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(testObjects(testhandleDeleteClusterDeployment())...).Build(),
+			testutils.Fault{Verb: testutils.Patch, Object: &hivev1.ClusterDeployment{}, Times: 1, Class: testutils.Conflict})
+		rcd := &ClusterDeploymentReconciler{
+			Client: fakeClient,
+			Scheme: scheme.Scheme,
+		}
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+		_, err := rcd.Reconcile(context.TODO(), request)
+		assert.True(t, errors.IsConflict(err), "expected the conflict to be returned, got %v", err)
+		cd := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd), "the ClusterDeployment should keep its finalizer")
+
+		_, err = rcd.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+		err = fakeClient.Get(context.TODO(), request.NamespacedName, cd)
+		assert.True(t, errors.IsNotFound(err), "expected the ClusterDeployment to be deleted, got %v", err)
+	})
+
+	t.Run("Test a poison pill ClusterDeployment is still finalized", func(t *testing.T) {
+		poisoned := testhandleDeleteClusterDeployment()
+		poisoned.Annotations = map[string]string{utils.PoisonPillAnnotation: "true"}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(testObjects(poisoned)...).Build()
+		rcd := &ClusterDeploymentReconciler{
+			Client: fakeClient,
+			Scheme: scheme.Scheme,
+		}
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+		_, err := rcd.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+		err = fakeClient.Get(context.TODO(), request.NamespacedName, &hivev1.ClusterDeployment{})
+		assert.True(t, errors.IsNotFound(err), "expected the ClusterDeployment to be deleted, got %v", err)
+	})
+
+	t.Run("Test a successful reconcile clears the recorded panics", func(t *testing.T) {
+		panicked := testUnmanagedClusterDeployment()
+		panicked.Annotations = map[string]string{utils.ReconcilePanicCountAnnotation: "2"}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(testObjects(panicked)...).Build()
+		rcd := &ClusterDeploymentReconciler{
+			Client: fakeClient,
+			Scheme: scheme.Scheme,
+		}
+		request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+		_, err := rcd.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+		cd := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+		assert.NotContains(t, cd.Annotations, utils.ReconcilePanicCountAnnotation)
+	})
+}
+
+// TestHandleDeleteCleansUpChallengeRecords tests that the challenge records of the
+// CertificateRequests are cleaned up on every platform when a ClusterDeployment is deleted, and
+// that a failed cleanup doesn't block the deletion.
+func TestOptOut(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+	// testOptOutCluster returns a fake client with an opted out ClusterDeployment whose
+	// CertificateRequest has a finalizer, as the CertificateRequest controller adds.
+	testOptOutCluster := func(annotations map[string]string) (client.Client, *ClusterDeploymentReconciler) {
+		cd := testClusterDeploymentAws()
+		cd.Annotations = annotations
+		cd.Finalizers = []string{certmanv1alpha1.CertmanOperatorFinalizerLabel}
+		cr := testCertificateRequest(cd)
+		cr.Finalizers = []string{certmanv1alpha1.CertmanOperatorFinalizerLabel}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd, cr)...).WithStatusSubresource(cd).Build()
+		return fakeClient, &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+	}
+
+	t.Run("an unconfirmed opt-out keeps the certificates", func(t *testing.T) {
+		fakeClient, rcd := testOptOutCluster(map[string]string{OptOutAnnotation: OptOutPolicyRetain, OptOutConfirmAnnotation: "another-cluster"})
+
+		_, err := rcd.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+
+		// the CertificateRequest is left to the usual sync, with the revocation policy of the operator
+		cr := &certmanv1alpha1.CertificateRequest{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "test-cert-request"}, cr))
+		assert.Nil(t, cr.Spec.RevokeOnDelete)
+
+		cd := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+		assert.Contains(t, cd.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+		assert.NotContains(t, cd.Annotations, OptedOutAnnotation)
+		condition := findCondition(cd, certmanOptOutCondition)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, corev1.ConditionTrue, condition.Status)
+			assert.Equal(t, optOutNotConfirmed, condition.Reason)
+			assert.Contains(t, condition.Message, OptOutConfirmAnnotation)
+		}
+	})
+
+	t.Run("withdrawing an opt-out removes its condition", func(t *testing.T) {
+		fakeClient, rcd := testOptOutCluster(map[string]string{OptOutAnnotation: OptOutPolicyRetain})
+
+		_, err := rcd.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+
+		cd := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+		assert.NotNil(t, findCondition(cd, certmanOptOutCondition))
+
+		delete(cd.Annotations, OptOutAnnotation)
+		assert.NoError(t, fakeClient.Update(context.TODO(), cd))
+
+		_, err = rcd.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+		assert.Nil(t, findCondition(cd, certmanOptOutCondition))
+		assert.Contains(t, cd.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+	})
+
+	t.Run("an invalid policy keeps the certificates", func(t *testing.T) {
+		fakeClient, rcd := testOptOutCluster(map[string]string{OptOutAnnotation: "delete", OptOutConfirmAnnotation: testClusterName})
+
+		_, err := rcd.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+
+		cd := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+		condition := findCondition(cd, certmanOptOutCondition)
+		if assert.NotNil(t, condition) {
+			assert.Equal(t, optOutNotConfirmed, condition.Reason)
+			assert.Contains(t, condition.Message, `"delete"`)
+		}
+	})
+
+	for policy, revoke := range map[string]bool{OptOutPolicyRetain: false, OptOutPolicyRevoke: true} {
+		t.Run(fmt.Sprintf("a confirmed opt-out deletes the CertificateRequests with policy %s", policy), func(t *testing.T) {
+			fakeClient, rcd := testOptOutCluster(map[string]string{OptOutAnnotation: policy, OptOutConfirmAnnotation: testClusterName})
+
+			result, err := rcd.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+			assert.Equal(t, optOutRecheckInterval, result.RequeueAfter)
+
+			// the CertificateRequest waits for its finalizer, with the revocation policy of the opt-out
+			cr := &certmanv1alpha1.CertificateRequest{}
+			assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: "test-cert-request"}, cr))
+			assert.False(t, cr.DeletionTimestamp.IsZero())
+			if assert.NotNil(t, cr.Spec.RevokeOnDelete) {
+				assert.Equal(t, revoke, *cr.Spec.RevokeOnDelete)
+			}
+
+			cd := &hivev1.ClusterDeployment{}
+			assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+			assert.Contains(t, cd.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+			condition := findCondition(cd, certmanOptOutCondition)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, optOutInProgress, condition.Reason)
+			}
+
+			// the CertificateRequest controller removes its finalizer
+			cr.Finalizers = nil
+			assert.NoError(t, fakeClient.Update(context.TODO(), cr))
+
+			result, err = rcd.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+			assert.Zero(t, result.RequeueAfter)
+
+			assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+			assert.NotContains(t, cd.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+			assert.Contains(t, cd.Annotations, OptedOutAnnotation)
+			condition = findCondition(cd, certmanOptOutCondition)
+			if assert.NotNil(t, condition) {
+				assert.Equal(t, optedOut, condition.Reason)
+			}
+
+			// removing the opt-out annotations doesn't opt the cluster back in
+			delete(cd.Annotations, OptOutAnnotation)
+			delete(cd.Annotations, OptOutConfirmAnnotation)
+			assert.NoError(t, fakeClient.Update(context.TODO(), cd))
+
+			_, err = rcd.Reconcile(context.TODO(), request)
+			assert.NoError(t, err)
+
+			crList := certmanv1alpha1.CertificateRequestList{}
+			assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+			assert.Empty(t, crList.Items)
+			assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, cd))
+			assert.NotContains(t, cd.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+		})
+	}
+}
+
+func TestHandleDeleteCleansUpChallengeRecords(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	tests := []struct {
+		name             string
+		platform         certmanv1alpha1.Platform
+		cleanupError     string
+		expectedPlatform string
+	}{
+		{
+			name: "Test cleanup on aws",
+			platform: certmanv1alpha1.Platform{
+				AWS: &certmanv1alpha1.AWSPlatformSecrets{Credentials: corev1.LocalObjectReference{Name: testAWSCredentialsSecret}, Region: "dreamland"},
+			},
+			expectedPlatform: "aws",
+		},
+		{
+			name: "Test cleanup on gcp",
+			platform: certmanv1alpha1.Platform{
+				GCP: &certmanv1alpha1.GCPPlatformSecrets{Credentials: corev1.LocalObjectReference{Name: "gcp"}},
+			},
+			expectedPlatform: "gcp",
+		},
+		{
+			name: "Test cleanup on azure",
+			platform: certmanv1alpha1.Platform{
+				Azure: &certmanv1alpha1.AzurePlatformSecrets{Credentials: corev1.LocalObjectReference{Name: "azure"}, ResourceGroupName: "dreamland"},
+			},
+			expectedPlatform: "azure",
+		},
+		{
+			name: "Test failed cleanup on gcp",
+			platform: certmanv1alpha1.Platform{
+				GCP: &certmanv1alpha1.GCPPlatformSecrets{Credentials: corev1.LocalObjectReference{Name: "gcp"}},
+			},
+			cleanupError:     "zone not found",
+			expectedPlatform: "gcp",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cd := testhandleDeleteClusterDeployment()
+			cr := testCertificateRequest(cd)
+			cr.Spec.Platform = test.platform
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(cd, cr).Build()
+
+			cleanedUp := []string{}
+			rcd := &ClusterDeploymentReconciler{
+				Client: fakeClient,
+				Scheme: scheme.Scheme,
+				ClientBuilder: func(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (cClient.Client, error) {
+					cleanedUp = append(cleanedUp, string(platform.Provider()))
+					return mockclient.NewMockClient(&mockclient.MockClientOptions{
+						DeleteAcmeChallengeResourceRecordsErrorString: test.cleanupError,
+					}), nil
+				},
+			}
+
+			err := rcd.handleDelete(cd, logr.Discard())
+			assert.Nil(t, err, "Error returned while attempting to handle the deletion: %q", err)
+			assert.Equal(t, []string{test.expectedPlatform}, cleanedUp)
+
+			crList := &certmanv1alpha1.CertificateRequestList{}
+			err = fakeClient.List(context.TODO(), crList, client.InNamespace(testNamespace))
+			assert.Nil(t, err, "Error returned while listing CertificateRequests: %q", err)
+			assert.Empty(t, crList.Items, "expected the CertificateRequest to be deleted")
+		})
+	}
+}
+
+// TestNotificationEmailFallback tests reconciling a ClusterDeployment when the operator has no
+// default notification email configured.
+func TestNotificationEmailFallback(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	// testObjects without the operator configmap
+	objectsWithoutConfigMap := func(obj runtime.Object) []runtime.Object {
+		objList := []runtime.Object{}
+		for _, o := range testObjects() {
+			if _, ok := o.(*corev1.ConfigMap); !ok {
+				objList = append(objList, o)
+			}
+		}
+		return append(objList, obj)
+	}
+
+	t.Run("email from ClusterDeployment annotation", func(t *testing.T) {
+		cd := testClusterDeploymentWithGenerateAPI()
+		cd.Annotations = map[string]string{NotificationEmailAnnotation: "owner@example.com"}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objectsWithoutConfigMap(cd)...).WithStatusSubresource(cd).Build()
+
+		rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme}
+		_, err := rcd.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}})
+		assert.NoError(t, err)
+
+		crList := certmanv1alpha1.CertificateRequestList{}
+		assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+		if assert.Len(t, crList.Items, 1) {
+			assert.Equal(t, "owner@example.com", crList.Items[0].Spec.Email)
+		}
+	})
+
+	t.Run("no email sets the degraded condition", func(t *testing.T) {
+		cd := testClusterDeploymentWithGenerateAPI()
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objectsWithoutConfigMap(cd)...).WithStatusSubresource(cd).Build()
+
+		rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme}
+		result, err := rcd.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}})
+		assert.NoError(t, err)
+		assert.Equal(t, utils.DefaultRequeueIntervals[utils.WaitNotificationEmail], result.RequeueAfter)
+
+		crList := certmanv1alpha1.CertificateRequestList{}
+		assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+		assert.Empty(t, crList.Items)
+
+		actualCD := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testClusterName}, actualCD))
+		found := false
+		for _, condition := range actualCD.Status.Conditions {
+			if condition.Type == certmanDegradedCondition {
+				found = true
+				assert.Equal(t, corev1.ConditionTrue, condition.Status)
+				assert.Equal(t, notificationEmailNotFound, condition.Reason)
+			}
+		}
+		assert.True(t, found, "didn't find the %s condition", certmanDegradedCondition)
+	})
+}
+
+// TestMissingDependencies tests reconciling a ClusterDeployment whose referenced secrets don't exist.
+func TestMissingDependencies(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	// testObjects without the credentials secret of the ClusterDeployment
+	objectsWithoutCredentials := func(objs ...runtime.Object) []runtime.Object {
+		objList := []runtime.Object{}
+		for _, o := range testObjects() {
+			if s, ok := o.(*corev1.Secret); ok && s.Namespace == testNamespace {
+				continue
+			}
+			objList = append(objList, o)
+		}
+		return append(objList, objs...)
+	}
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	cd.Spec.ClusterMetadata = &hivev1.ClusterMetadata{
+		AdminKubeconfigSecretRef: corev1.LocalObjectReference{Name: "admin-kubeconfig"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objectsWithoutCredentials(cd)...).WithStatusSubresource(cd).Build()
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+	result, err := rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, missingDependencyMinRequeueDelay, result.RequeueAfter)
+
+	crList := certmanv1alpha1.CertificateRequestList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+	assert.Empty(t, crList.Items)
+
+	actualCD := &hivev1.ClusterDeployment{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actualCD))
+	condition := findCondition(actualCD, certmanMissingDependencyCondition)
+	if !assert.NotNil(t, condition, "didn't find the %s condition", certmanMissingDependencyCondition) {
+		t.FailNow()
+	}
+	assert.Equal(t, corev1.ConditionTrue, condition.Status)
+	assert.Equal(t, secretNotFound, condition.Reason)
+	assert.Contains(t, condition.Message, testAWSCredentialsSecret)
+	assert.Contains(t, condition.Message, "admin-kubeconfig")
+	assert.Equal(t, 1.0, testutil.ToFloat64(localmetrics.MetricClusterMissingDependency.WithLabelValues(testNamespace, testClusterName, dependencyPlatformCredentials)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(localmetrics.MetricClusterMissingDependency.WithLabelValues(testNamespace, testClusterName, dependencyAdminKubeconfig)))
+
+	// the requeue delay grows with the time the secrets have been missing, up to the maximum
+	now := condition.LastTransitionTime.Time
+	assert.Equal(t, 5*time.Minute, missingDependencyRequeueDelay(actualCD, now.Add(5*time.Minute)))
+	assert.Equal(t, missingDependencyMaxRequeueDelay, missingDependencyRequeueDelay(actualCD, now.Add(24*time.Hour)))
+
+	// a day later the reconciler backs off to the maximum delay
+	rcd.Clock = clock.NewFake(now.Add(24 * time.Hour))
+	result, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, missingDependencyMaxRequeueDelay, result.RequeueAfter)
+	rcd.Clock = nil
+
+	// the secrets show up
+	for _, name := range []string{testAWSCredentialsSecret, "admin-kubeconfig"} {
+		assert.NoError(t, fakeClient.Create(context.TODO(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}}))
+	}
+
+	result, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+	assert.Len(t, crList.Items, 1)
+
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actualCD))
+	condition = findCondition(actualCD, certmanMissingDependencyCondition)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionFalse, condition.Status)
+	}
+	assert.Zero(t, testutil.CollectAndCount(localmetrics.MetricClusterMissingDependency))
+}
+
+// TestIngressDomainPolicy tests the SANs requested for ingress domains under each IngressDomainPolicy.
+func TestObservationMode(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd)...).WithStatusSubresource(cd).Build()
+	recorder := record.NewFakeRecorder(10)
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme, Recorder: recorder, ObservationMode: true}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	crList := certmanv1alpha1.CertificateRequestList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+	assert.Empty(t, crList.Items)
+
+	actualCD := &hivev1.ClusterDeployment{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actualCD))
+	assert.NotContains(t, actualCD.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+	condition := findCondition(actualCD, certmanObservationModeCondition)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status)
+		assert.Equal(t, observedChangesPending, condition.Reason)
+		assert.Contains(t, condition.Message, "would create")
+	}
+	assert.Equal(t, 1.0, testutil.ToFloat64(localmetrics.MetricObservedCertificateRequestChanges.WithLabelValues(testNamespace, testClusterName, "create")))
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Contains(t, <-recorder.Events, observedChangesEventReason)
+	}
+
+	// the same changes aren't recorded again
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+
+	// leaving observation mode makes the changes
+	rcd.ObservationMode = false
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+	assert.Len(t, crList.Items, 1)
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actualCD))
+	assert.Contains(t, actualCD.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+	condition = findCondition(actualCD, certmanObservationModeCondition)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionFalse, condition.Status)
+	}
+	assert.Zero(t, testutil.CollectAndCount(localmetrics.MetricObservedCertificateRequestChanges))
+}
+
+// TestCertificatesReady tests the condition reporting whether the certificates of a ClusterDeployment
+// are ready.
+func TestCertificatesReady(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd)...).WithStatusSubresource(cd, &certmanv1alpha1.CertificateRequest{}).Build()
+	now := time.Now()
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme, Clock: clock.NewFake(now)}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+	assertCondition := func(status corev1.ConditionStatus, reason string) {
+		t.Helper()
+		actualCD := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actualCD))
+		condition := findCondition(actualCD, CertificatesReadyCondition)
+		if assert.NotNil(t, condition, "didn't find the %s condition", CertificatesReadyCondition) {
+			assert.Equal(t, status, condition.Status)
+			assert.Equal(t, reason, condition.Reason)
+		}
+	}
+
+	// the CertificateRequest is created but not issued yet
+	result, err := rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assertCondition(corev1.ConditionFalse, certificateNotIssued)
+
+	crList := certmanv1alpha1.CertificateRequestList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+	if !assert.Len(t, crList.Items, 1) {
+		t.FailNow()
+	}
+	cr := &crList.Items[0]
+	cr.Status.Issued = true
+	assert.NoError(t, fakeClient.Status().Update(context.TODO(), cr))
+
+	// issued, but its secret doesn't exist
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assertCondition(corev1.ConditionFalse, certificateSecretNotFound)
+
+	// a certificate missing one of the domains
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: cr.Spec.CertificateSecret.Name},
+		Data:       map[string][]byte{corev1.TLSCertKey: testCertificate(t, now.Add(-time.Hour), now.Add(24*time.Hour), "other.example.com")},
+	}
+	assert.NoError(t, fakeClient.Create(context.TODO(), secret))
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assertCondition(corev1.ConditionFalse, certificateMissingDomains)
+
+	// a valid certificate, the ClusterDeployment is checked again once it expires
+	notAfter := now.Add(24 * time.Hour)
+	secret.Data[corev1.TLSCertKey] = testCertificate(t, now.Add(-time.Hour), notAfter, cr.Spec.DnsNames...)
+	assert.NoError(t, fakeClient.Update(context.TODO(), secret))
+	result, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, notAfter.Truncate(time.Second).Sub(now)+time.Second, result.RequeueAfter)
+	assertCondition(corev1.ConditionTrue, certificatesValid)
+
+	// the certificate expires
+	rcd.Clock = clock.NewFake(notAfter.Add(time.Minute))
+	result, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	assertCondition(corev1.ConditionFalse, certificateExpired)
+}
+
+// testCertificate returns a PEM encoded self-signed certificate for domains, valid from notBefore
+// to notAfter.
+func testCertificate(t *testing.T, notBefore, notAfter time.Time, domains ...string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     domains,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestIngressDomainPolicy(t *testing.T) {
+	tests := []struct {
+		name            string
+		annotation      string
+		defaultPolicy   certmanv1alpha1.IngressDomainPolicy
+		ingressDomain   string
+		expectedDomains []string
+	}{
+		{
+			name:            "wildcard by default",
+			defaultPolicy:   certmanv1alpha1.IngressDomainPolicyWildcard,
+			ingressDomain:   testIngressDefaultDomain,
+			expectedDomains: []string{"*." + testIngressDefaultDomain},
+		},
+		{
+			name:            "exact from the operator default",
+			defaultPolicy:   certmanv1alpha1.IngressDomainPolicyExact,
+			ingressDomain:   testIngressDefaultDomain,
+			expectedDomains: []string{testIngressDefaultDomain},
+		},
+		{
+			name:            "both from the annotation",
+			annotation:      string(certmanv1alpha1.IngressDomainPolicyBoth),
+			defaultPolicy:   certmanv1alpha1.IngressDomainPolicyWildcard,
+			ingressDomain:   testIngressDefaultDomain,
+			expectedDomains: []string{"*." + testIngressDefaultDomain, testIngressDefaultDomain},
+		},
+		{
+			name:            "invalid annotation uses the operator default",
+			annotation:      "Sometimes",
+			defaultPolicy:   certmanv1alpha1.IngressDomainPolicyExact,
+			ingressDomain:   testIngressDefaultDomain,
+			expectedDomains: []string{testIngressDefaultDomain},
+		},
+		{
+			name:            "declared wildcard is kept with the exact policy",
+			defaultPolicy:   certmanv1alpha1.IngressDomainPolicyExact,
+			ingressDomain:   "*." + testIngressDefaultDomain,
+			expectedDomains: []string{"*." + testIngressDefaultDomain},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cd := testClusterDeploymentAws()
+			if test.annotation != "" {
+				cd.Annotations = map[string]string{IngressDomainPolicyAnnotation: test.annotation}
+			}
+			cd.Spec.Ingress = []hivev1.ClusterIngress{
+				{
+					Name:               "default",
+					Domain:             test.ingressDomain,
+					ServingCertificate: testCertBundleName,
+				},
+			}
+			cb := hivev1.CertificateBundleSpec{Name: testCertBundleName, Generate: true}
+
+			policy := ingressDomainPolicy(cd, test.defaultPolicy, logr.Discard())
+			assert.Equal(t, test.expectedDomains, getDomainsForCertBundle(cb, cd, policy, logr.Discard()))
+		})
+	}
+}
+
+func TestAPIURLOverrideDomains(t *testing.T) {
+	defaultDomain := fmt.Sprintf("api.%s.%s", testClusterName, testBaseDomain)
+	overrideDomain := fmt.Sprintf("rh-api.%s.%s", testClusterName, testBaseDomain)
+
+	tests := []struct {
+		name            string
+		apiURLOverride  string
+		extraRecord     string
+		expectedDomains []string
+	}{
+		{
+			name:            "no override",
+			expectedDomains: []string{defaultDomain},
+		},
+		{
+			name:            "override URL",
+			apiURLOverride:  "https://" + overrideDomain + ":6443",
+			expectedDomains: []string{defaultDomain, overrideDomain},
+		},
+		{
+			name:            "override host and port",
+			apiURLOverride:  overrideDomain + ":6443",
+			expectedDomains: []string{defaultDomain, overrideDomain},
+		},
+		{
+			name:            "override of the default domain",
+			apiURLOverride:  "https://" + defaultDomain + ":6443",
+			expectedDomains: []string{defaultDomain},
+		},
+		{
+			name:            "override of the extra record",
+			apiURLOverride:  "https://" + overrideDomain + ":6443",
+			extraRecord:     "rh-api",
+			expectedDomains: []string{defaultDomain, overrideDomain},
+		},
+		{
+			name:            "override by IP address",
+			apiURLOverride:  "https://10.0.0.1:6443",
+			expectedDomains: []string{defaultDomain},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("EXTRA_RECORD", test.extraRecord)
+
+			cd := testClusterDeploymentWithGenerateAPI()
+			cd.Spec.Ingress = nil
+			cd.Spec.ControlPlaneConfig.APIURLOverride = test.apiURLOverride
+			cb := hivev1.CertificateBundleSpec{Name: testCertBundleName, Generate: true}
+
+			assert.Equal(t, test.expectedDomains, getDomainsForCertBundle(cb, cd, certmanv1alpha1.IngressDomainPolicyWildcard, logr.Discard()))
+		})
+	}
+}
+
+// TestComponentRouteDomains tests that the custom console and OAuth hostnames are requested
+// with the default ingress domain.
+func TestComponentRouteDomains(t *testing.T) {
+	consoleDomain := "console." + testBaseDomain
+	oauthDomain := "oauth." + testBaseDomain
+
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		ingressName     string
+		expectedDomains []string
+	}{
+		{
+			name:            "no custom domains",
+			ingressName:     "default",
+			expectedDomains: []string{"*." + testIngressDefaultDomain},
+		},
+		{
+			name: "console and oauth domains",
+			annotations: map[string]string{
+				ConsoleDomainAnnotation: consoleDomain,
+				OAuthDomainAnnotation:   " " + strings.ToUpper(oauthDomain) + ".",
+			},
+			ingressName:     "default",
+			expectedDomains: []string{"*." + testIngressDefaultDomain, consoleDomain, oauthDomain},
+		},
+		{
+			name: "several and repeated domains",
+			annotations: map[string]string{
+				ConsoleDomainAnnotation: consoleDomain + ",,console2." + testBaseDomain,
+				OAuthDomainAnnotation:   consoleDomain,
+			},
+			ingressName:     "default",
+			expectedDomains: []string{"*." + testIngressDefaultDomain, consoleDomain, "console2." + testBaseDomain},
+		},
+		{
+			name:            "other ingress",
+			annotations:     map[string]string{ConsoleDomainAnnotation: consoleDomain},
+			ingressName:     "apps2",
+			expectedDomains: []string{"*." + testIngressDefaultDomain},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cd := testClusterDeploymentAws()
+			cd.Annotations = test.annotations
+			cd.Spec.Ingress = []hivev1.ClusterIngress{
+				{
+					Name:               test.ingressName,
+					Domain:             testIngressDefaultDomain,
+					ServingCertificate: testCertBundleName,
+				},
+			}
+			cb := hivev1.CertificateBundleSpec{Name: testCertBundleName, Generate: true}
+
+			assert.Equal(t, test.expectedDomains, getDomainsForCertBundle(cb, cd, certmanv1alpha1.IngressDomainPolicyWildcard, logr.Discard()))
+		})
+	}
+}
+
+// TestInvalidCertificateDomains tests that certificate bundles with domains outside of the
+// cluster's base domain are reported on the ClusterDeployment instead of being requested.
+func TestInvalidCertificateDomains(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	cd.Spec.ControlPlaneConfig.ServingCertificates.Additional = []hivev1.ControlPlaneAdditionalCertificate{
+		{
+			Name:   testCertBundleName,
+			Domain: "api.testing.exmaple.com",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd)...).WithStatusSubresource(cd).Build()
+
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme}
+	_, err = rcd.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}})
+	assert.NoError(t, err)
+
+	crList := certmanv1alpha1.CertificateRequestList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+	assert.Empty(t, crList.Items)
+
+	actualCD := &hivev1.ClusterDeployment{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testClusterName}, actualCD))
+	found := false
+	for _, condition := range actualCD.Status.Conditions {
+		if condition.Type == certmanInvalidDomainsCondition {
+			found = true
+			assert.Equal(t, corev1.ConditionTrue, condition.Status)
+			assert.Contains(t, condition.Message, "api.testing.exmaple.com")
+		}
+	}
+	assert.True(t, found, "didn't find the %s condition", certmanInvalidDomainsCondition)
+}
+
+// TestUnapprovedBaseDomain tests that certificate bundles of a ClusterDeployment whose base domain
+// isn't under the approved base domains are reported instead of being requested.
+func TestUnapprovedBaseDomain(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	objects := testObjects()
+	objects[0].(*corev1.ConfigMap).Data[cTypes.ApprovedBaseDomains] = "openshiftapps.com, devshift.org"
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(objects, cd)...).WithStatusSubresource(cd).Build()
+
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme}
+	_, err = rcd.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}})
+	assert.NoError(t, err)
+
+	crList := certmanv1alpha1.CertificateRequestList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), &crList, client.InNamespace(testNamespace)))
+	assert.Empty(t, crList.Items)
+
+	actualCD := &hivev1.ClusterDeployment{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testClusterName}, actualCD))
+	found := false
+	for _, condition := range actualCD.Status.Conditions {
+		if condition.Type == certmanInvalidDomainsCondition {
+			found = true
+			assert.Equal(t, corev1.ConditionTrue, condition.Status)
+			assert.Equal(t, "DomainsNotApproved", condition.Reason)
+			assert.Contains(t, condition.Message, testBaseDomain)
+		}
+	}
+	assert.True(t, found, "didn't find the %s condition", certmanInvalidDomainsCondition)
+}
+
+// TestBaseDomainMigration tests that the CertificateRequests of a ClusterDeployment whose base domain
+// changed are moved to the new domain and reissued.
+func TestBaseDomainMigration(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd)...).WithStatusSubresource(cd, &certmanv1alpha1.CertificateRequest{}).Build()
+
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	cleanedUp := []string{}
+	rcd := &ClusterDeploymentReconciler{
+		Client: fakeClient,
+		Scheme: scheme.Scheme,
+		ClientBuilder: func(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (cClient.Client, error) {
+			cleanedUp = append(cleanedUp, string(platform.Provider()))
+			return mockclient.NewMockClient(&mockclient.MockClientOptions{}), nil
+		},
+		Clock: clock.NewFake(now),
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	// an issuance for the old domain is in progress
+	crKey := types.NamespacedName{Namespace: testNamespace, Name: certificateRequestName(cd, testCertBundleName)}
+	cr := &certmanv1alpha1.CertificateRequest{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), crKey, cr))
+	cr.Status.IssuanceStage = certmanv1alpha1.IssuanceStageChallengesPlaced
+	cr.Status.OrderURL = "proto://a.fake.order"
+	assert.NoError(t, fakeClient.Status().Update(context.TODO(), cr))
+	assert.Empty(t, cleanedUp, "expected no cleanup before the base domain changed")
+
+	actualCD := &hivev1.ClusterDeployment{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, actualCD))
+	actualCD.Spec.BaseDomain = "migrated.example.com"
+	assert.NoError(t, fakeClient.Update(context.TODO(), actualCD))
+
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	assert.NoError(t, fakeClient.Get(context.TODO(), crKey, cr))
+	assert.Equal(t, "migrated.example.com", cr.Spec.ACMEDNSDomain)
+	assert.Equal(t, []string{fmt.Sprintf("api.%s.migrated.example.com", testClusterName)}, cr.Spec.DnsNames)
+	assert.Equal(t, now.Format(time.RFC3339), cr.Annotations[certificaterequest.RenewRequestedAtAnnotation])
+	assert.Empty(t, cr.Status.OrderURL)
+	assert.Empty(t, cr.Status.IssuanceStage)
+	assert.Equal(t, []string{"aws"}, cleanedUp)
+}
+
+func TestPackCertificateDomains(t *testing.T) {
+	tests := []struct {
+		name     string
+		domains  []string
+		expected [][]string
+	}{
+		{
+			name:     "under the limit",
+			domains:  []string{"api.example.com", "b.example.com"},
+			expected: [][]string{{"api.example.com", "b.example.com"}},
+		},
+		{
+			name:     "at the limit",
+			domains:  []string{"api.example.com", "b.example.com", "c.example.com"},
+			expected: [][]string{{"api.example.com", "b.example.com", "c.example.com"}},
+		},
+		{
+			name:    "over the limit",
+			domains: []string{"api.example.com", "z.example.com", "y.example.com", "e.example.com", "d.example.com", "c.example.com", "b.example.com"},
+			expected: [][]string{
+				{"api.example.com", "z.example.com", "y.example.com"},
+				{"b.example.com", "c.example.com", "d.example.com"},
+				{"e.example.com"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, packCertificateDomains(test.domains, 3))
+		})
+	}
+}
+
+// TestSplitCertificateBundle tests that the domains of a bundle over the SAN limit are requested
+// by several labeled CertificateRequests, and that the bundle is generated once all are issued.
+func TestSplitCertificateBundle(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	for i := 0; i < maxCertificateDomains+10; i++ {
+		cd.Spec.ControlPlaneConfig.ServingCertificates.Additional = append(cd.Spec.ControlPlaneConfig.ServingCertificates.Additional, hivev1.ControlPlaneAdditionalCertificate{
+			Name:   testCertBundleName,
+			Domain: fmt.Sprintf("extra%03d.%s", i, testBaseDomain),
+		})
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd)...).WithStatusSubresource(cd, &certmanv1alpha1.CertificateRequest{}).Build()
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: testClusterName, Namespace: testNamespace}}
+
+	_, err = rcd.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	name := fmt.Sprintf("%s-%s", testClusterName, testCertBundleName)
+	first := &certmanv1alpha1.CertificateRequest{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: name}, first))
+	assert.Len(t, first.Spec.DnsNames, maxCertificateDomains)
+	assert.Equal(t, fmt.Sprintf("api.%s.%s", testClusterName, testBaseDomain), first.Spec.DnsNames[0])
+	assert.Equal(t, "testBundleSecret", first.Spec.CertificateSecret.Name)
+	assert.Equal(t, map[string]string{
+		certmanv1alpha1.CertificateBundleLabel:      testCertBundleName,
+		certmanv1alpha1.CertificateBundlePartLabel:  "1",
+		certmanv1alpha1.CertificateBundlePartsLabel: "2",
+	}, first.Labels)
+
+	second := &certmanv1alpha1.CertificateRequest{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: name + "-part-2"}, second))
+	assert.Len(t, second.Spec.DnsNames, 11)
+	assert.Equal(t, "testBundleSecret-part-2", second.Spec.CertificateSecret.Name)
+	assert.Equal(t, "2", second.Labels[certmanv1alpha1.CertificateBundlePartLabel])
+
+	// the bundle is generated once both parts are issued
+	for i, cr := range []*certmanv1alpha1.CertificateRequest{first, second} {
+		cr.Status.Issued = true
+		assert.NoError(t, fakeClient.Status().Update(context.TODO(), cr))
+
+		synced := &hivev1.ClusterDeployment{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), request.NamespacedName, synced))
+		assert.NoError(t, rcd.syncCertificateRequests(synced, logr.Discard()))
+		assert.Equal(t, []hivev1.CertificateBundleStatus{{Name: testCertBundleName, Generated: i == 1}}, synced.Status.CertificateBundles)
+	}
+}
+
+// TestSplitCertificateBundleFeatureDisabled tests that a cluster with the split-certificates feature
+// disabled requests all the domains of a bundle over the SAN limit with a single CertificateRequest.
+func TestSplitCertificateBundleFeatureDisabled(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	cd.Annotations = map[string]string{utils.FeaturesAnnotation: "-" + utils.FeatureSplitCertificates}
+	for i := 0; i < maxCertificateDomains+10; i++ {
+		cd.Spec.ControlPlaneConfig.ServingCertificates.Additional = append(cd.Spec.ControlPlaneConfig.ServingCertificates.Additional, hivev1.ControlPlaneAdditionalCertificate{
+			Name:   testCertBundleName,
+			Domain: fmt.Sprintf("extra%03d.%s", i, testBaseDomain),
+		})
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd)...).WithStatusSubresource(cd, &certmanv1alpha1.CertificateRequest{}).Build()
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme}
+	assert.NoError(t, rcd.syncCertificateRequests(cd, logr.Discard()))
+
+	certRequests := &certmanv1alpha1.CertificateRequestList{}
+	assert.NoError(t, fakeClient.List(context.TODO(), certRequests))
+	if assert.Len(t, certRequests.Items, 1) {
+		assert.Len(t, certRequests.Items[0].Spec.DnsNames, maxCertificateDomains+11)
+		assert.Equal(t, "1", certRequests.Items[0].Labels[certmanv1alpha1.CertificateBundlePartsLabel])
+	}
 }
 
 func validateCertificateRequest(t *testing.T, expectedCertReq CertificateRequestEntry, actualCR certmanv1alpha1.CertificateRequest, cd *hivev1.ClusterDeployment) {
@@ -477,6 +1485,11 @@ func testObjects() []runtime.Object {
 	}
 	objects = append(objects, sAws.DeepCopyObject())
 
+	// the credentials secret referenced by the ClusterDeployment
+	sClusterAws := sAws.DeepCopy()
+	sClusterAws.Namespace = testNamespace
+	objects = append(objects, sClusterAws)
+
 	sGcp := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "gcp-secret",
@@ -492,3 +1505,66 @@ func testObjects() []runtime.Object {
 	return objects
 
 }
+
+func TestReportCertificateRequestChanges(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	cd := testClusterDeploymentWithGenerateAPI()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(append(testObjects(), cd)...).WithStatusSubresource(cd, &certmanv1alpha1.CertificateRequest{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	rcd := &ClusterDeploymentReconciler{Client: fakeClient, Scheme: scheme.Scheme, Recorder: recorder}
+
+	synced := &hivev1.ClusterDeployment{}
+	assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: testClusterName, Namespace: testNamespace}, synced))
+	assert.NoError(t, rcd.syncCertificateRequests(synced, logr.Discard()))
+
+	name := fmt.Sprintf("%s-%s", testClusterName, testCertBundleName)
+	apiDomain := fmt.Sprintf("api.%s.%s", testClusterName, testBaseDomain)
+	assert.Equal(t, fmt.Sprintf("Normal %s create %s with %s", certificateRequestChangesEventReason, name, apiDomain), <-recorder.Events)
+
+	// an unchanged configuration changes nothing
+	assert.NoError(t, rcd.syncCertificateRequests(synced, logr.Discard()))
+	assert.Empty(t, recorder.Events)
+
+	synced.Spec.ControlPlaneConfig.ServingCertificates.Additional = append(synced.Spec.ControlPlaneConfig.ServingCertificates.Additional, hivev1.ControlPlaneAdditionalCertificate{
+		Name:   testCertBundleName,
+		Domain: "extra." + testBaseDomain,
+	})
+	assert.NoError(t, rcd.syncCertificateRequests(synced, logr.Discard()))
+	assert.Equal(t, fmt.Sprintf("Normal %s update %s: +extra.%s", certificateRequestChangesEventReason, name, testBaseDomain), <-recorder.Events)
+}
+
+func TestPlanCertificateRequestChanges(t *testing.T) {
+	cr := func(name string, domains ...string) certmanv1alpha1.CertificateRequest {
+		return certmanv1alpha1.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       certmanv1alpha1.CertificateRequestSpec{DnsNames: domains},
+		}
+	}
+	relabeled := cr("same", "a.example.com")
+	relabeled.Labels = map[string]string{certmanv1alpha1.CertificateBundleLabel: "same"}
+	many := []string{}
+	for i := 0; i < maxListedDomains+2; i++ {
+		many = append(many, fmt.Sprintf("d%d.example.com", i))
+	}
+
+	changes := planCertificateRequestChanges(
+		[]certmanv1alpha1.CertificateRequest{cr("same", "a.example.com"), cr("changed", "a.example.com", "b.example.com"), cr("gone", "c.example.com")},
+		[]certmanv1alpha1.CertificateRequest{relabeled, cr("changed", "a.example.com", "d.example.com"), cr("new", many...)},
+		[]certmanv1alpha1.CertificateRequest{cr("gone", "c.example.com")},
+	)
+
+	descriptions := []string{}
+	for _, change := range changes {
+		descriptions = append(descriptions, change.String())
+	}
+	assert.Equal(t, []string{
+		"update changed: +d.example.com -b.example.com",
+		"create new with d0.example.com d1.example.com d2.example.com d3.example.com d4.example.com and 2 more",
+		"delete gone",
+	}, descriptions)
+}
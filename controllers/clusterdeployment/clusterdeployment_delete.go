@@ -18,10 +18,16 @@ package clusterdeployment
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/go-logr/logr"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
 )
 
 // handleDelete accepts a ClusterDeployment arg from which is lists out all related CertificateRequests.
@@ -38,12 +44,39 @@ func (r *ClusterDeploymentReconciler) handleDelete(cd *hivev1.ClusterDeployment,
 	// delete the certificaterequests
 	for _, deleteCR := range currentCRs {
 		deleteCR := deleteCR
+		r.cleanUpChallengeRecords(cd, &deleteCR, logger)
 		logger.Info(fmt.Sprintf("deleting CertificateRequest resource config %v", deleteCR.Name))
 		if err := r.Client.Delete(context.TODO(), &deleteCR); err != nil {
-			logger.Error(err, "error deleting CertificateRequest", "certrequest", deleteCR.Name)
+			logger.Error(err, "error deleting CertificateRequest", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, deleteCR.Name))
 			return err
 		}
 	}
 
 	return nil
 }
+
+// cleanUpChallengeRecords deletes the ACME challenge records cr left in the DNS zone of its platform.
+// The CertificateRequest finalizer does the same, but it may not get to run before the credentials
+// of the ClusterDeployment are gone, so this is done first on a best-effort basis: failures are
+// logged and don't block the deletion.
+func (r *ClusterDeploymentReconciler) cleanUpChallengeRecords(cd *hivev1.ClusterDeployment, cr *certmanv1alpha1.CertificateRequest, logger logr.Logger) {
+	if r.ClientBuilder == nil {
+		return
+	}
+	crLogger := logging.WithCertificateRequest(logger, cr)
+
+	dnsClient, err := r.ClientBuilder(crLogger, r.Client, cr.Spec.Platform, cr.Namespace, cd.Name)
+	if err != nil {
+		crLogger.Error(err, "could not build the platform client to clean up the challenge records")
+		return
+	}
+	err = dnsClient.DeleteAcmeChallengeResourceRecords(crLogger, cr)
+	if errors.Is(err, cTypes.ErrZoneNotFound) {
+		// Hive deleted the zone, and the records with it, first
+		crLogger.Info(fmt.Sprintf("not cleaning up the challenge records: %v", err))
+		localmetrics.IncrementSkippedDNSCleanupCount("challenge_records", "zone_not_found")
+	} else if err != nil {
+		crLogger.Error(err, "could not clean up the challenge records")
+		localmetrics.IncrementFailedDNSCleanupCount("challenge_records")
+	}
+}
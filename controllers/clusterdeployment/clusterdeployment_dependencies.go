@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdeployment
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// dependencies of a ClusterDeployment reported by the certmanMissingDependencyCondition and the
+	// missing dependency metric
+	dependencyPlatformCredentials = "platform_credentials"
+	dependencyAdminKubeconfig     = "admin_kubeconfig"
+
+	// missingDependencyMinRequeueDelay and missingDependencyMaxRequeueDelay bound the delay between
+	// checks of the dependencies of a ClusterDeployment, which grows with the time they've been missing
+	missingDependencyMinRequeueDelay = 30 * time.Second
+	missingDependencyMaxRequeueDelay = 30 * time.Minute
+)
+
+// clusterDependency is a secret a ClusterDeployment needs before its certificates can be issued.
+type clusterDependency struct {
+	name       string
+	secretName string
+}
+
+// clusterDependencies returns the secrets referenced by cd that must exist in its namespace. Clusters
+// without static platform credentials, such as AWS STS clusters, have no credentials secret.
+func clusterDependencies(cd *hivev1.ClusterDeployment) []clusterDependency {
+	dependencies := []clusterDependency{}
+
+	credentialsSecretName := ""
+	switch {
+	case cd.Spec.Platform.AWS != nil:
+		credentialsSecretName = cd.Spec.Platform.AWS.CredentialsSecretRef.Name
+	case cd.Spec.Platform.GCP != nil:
+		credentialsSecretName = cd.Spec.Platform.GCP.CredentialsSecretRef.Name
+	case cd.Spec.Platform.Azure != nil:
+		credentialsSecretName = cd.Spec.Platform.Azure.CredentialsSecretRef.Name
+	}
+	if credentialsSecretName != "" {
+		dependencies = append(dependencies, clusterDependency{name: dependencyPlatformCredentials, secretName: credentialsSecretName})
+	}
+
+	if cd.Spec.ClusterMetadata != nil && cd.Spec.ClusterMetadata.AdminKubeconfigSecretRef.Name != "" {
+		dependencies = append(dependencies, clusterDependency{name: dependencyAdminKubeconfig, secretName: cd.Spec.ClusterMetadata.AdminKubeconfigSecretRef.Name})
+	}
+
+	return dependencies
+}
+
+// missingDependencies returns the dependencies of cd whose secret doesn't exist.
+func (r *ClusterDeploymentReconciler) missingDependencies(cd *hivev1.ClusterDeployment) ([]clusterDependency, error) {
+	missing := []clusterDependency{}
+	for _, dependency := range clusterDependencies(cd) {
+		secret := &corev1.Secret{}
+		err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: cd.Namespace, Name: dependency.secretName}, secret)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				missing = append(missing, dependency)
+				continue
+			}
+			return nil, err
+		}
+	}
+
+	return missing, nil
+}
+
+// missingDependenciesMessage describes the missing dependencies in the certmanMissingDependencyCondition.
+func missingDependenciesMessage(missing []clusterDependency) string {
+	secrets := []string{}
+	for _, dependency := range missing {
+		secrets = append(secrets, fmt.Sprintf("%s secret %s", dependency.name, dependency.secretName))
+	}
+	return fmt.Sprintf("waiting for %s", strings.Join(secrets, ", "))
+}
+
+// missingDependencyRequeueDelay returns how long to wait before checking the dependencies of cd again.
+// Secrets that are late during provisioning are picked up quickly, while clusters that lost them
+// are only checked every missingDependencyMaxRequeueDelay.
+func missingDependencyRequeueDelay(cd *hivev1.ClusterDeployment, now time.Time) time.Duration {
+	delay := missingDependencyMinRequeueDelay
+	for _, condition := range cd.Status.Conditions {
+		if condition.Type == certmanMissingDependencyCondition && condition.Status == corev1.ConditionTrue {
+			if missingFor := now.Sub(condition.LastTransitionTime.Time); missingFor > delay {
+				delay = missingFor
+			}
+			break
+		}
+	}
+
+	if delay > missingDependencyMaxRequeueDelay {
+		return missingDependencyMaxRequeueDelay
+	}
+	return delay
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdeployment
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	// certmanObservationModeCondition reports the changes to the CertificateRequests of a
+	// ClusterDeployment that the controller would make outside of observation mode
+	certmanObservationModeCondition hivev1.ClusterDeploymentConditionType = "CertmanObservationMode"
+	observedChangesPending                                                = "ChangesPending"
+	observedInSync                                                        = "InSync"
+
+	// observedChangesEventReason is the reason of the event listing the changes found in
+	// observation mode, recorded when they differ from the ones last reported
+	observedChangesEventReason = "CertificateRequestChangesObserved"
+)
+
+// reportObservedChanges reports changes, which aren't made in observation mode, in the
+// certmanObservationModeCondition of cd, in a metric and, when they differ from the ones the
+// condition reported so far, in an event.
+func (r *ClusterDeploymentReconciler) reportObservedChanges(cd *hivev1.ClusterDeployment, changes []certificateRequestChange, logger logr.Logger) error {
+	counts := map[string]int{}
+	descriptions := make([]string, 0, len(changes))
+	for _, change := range changes {
+		counts[change.Action]++
+		descriptions = append(descriptions, change.String())
+		logger.Info("observation mode: not changing CertificateRequest", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, change.Name), "action", change.Action,
+			"addedDomains", change.AddedDomains, "removedDomains", change.RemovedDomains)
+	}
+	localmetrics.SetObservedCertificateRequestChanges(cd.Namespace, cd.Name, counts)
+
+	if len(changes) == 0 {
+		return r.setCondition(cd, certmanObservationModeCondition, corev1.ConditionTrue, observedInSync, "the CertificateRequests are in sync")
+	}
+
+	message := fmt.Sprintf("would %s", strings.Join(descriptions, "; "))
+	if condition := findCondition(cd, certmanObservationModeCondition); r.Recorder != nil && (condition == nil || condition.Message != message) {
+		r.Recorder.Event(cd, corev1.EventTypeNormal, observedChangesEventReason, message)
+	}
+	return r.setCondition(cd, certmanObservationModeCondition, corev1.ConditionTrue, observedChangesPending, message)
+}
+
+// clearObservedChanges removes the reports of observation mode from cd once it is disabled.
+func (r *ClusterDeploymentReconciler) clearObservedChanges(cd *hivev1.ClusterDeployment) error {
+	localmetrics.ClearObservedCertificateRequestChanges(cd.Namespace, cd.Name)
+	return r.setCondition(cd, certmanObservationModeCondition, corev1.ConditionFalse, "ObservationModeDisabled", "the CertificateRequests are managed")
+}
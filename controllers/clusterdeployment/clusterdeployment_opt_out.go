@@ -0,0 +1,146 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdeployment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	// OptOutAnnotation opts a ClusterDeployment out of the operator, such as when its cluster moves
+	// to certificates managed by the customer. Its value is what becomes of the certificates:
+	// OptOutPolicyRevoke or OptOutPolicyRetain.
+	OptOutAnnotation = "certman.managed.openshift.io/opt-out"
+	// OptOutConfirmAnnotation confirms the opt-out of a ClusterDeployment, it must be set to the
+	// name of the ClusterDeployment.
+	OptOutConfirmAnnotation = "certman.managed.openshift.io/opt-out-confirm"
+	// OptedOutAnnotation is set by the operator, to the time in RFC 3339, once the certman resources
+	// of an opted out ClusterDeployment are deleted. The ClusterDeployment isn't reconciled while it
+	// is set, even when the opt-out annotations are removed.
+	OptedOutAnnotation = "certman.managed.openshift.io/opted-out"
+
+	// OptOutPolicyRevoke revokes the certificates of the deleted CertificateRequests
+	OptOutPolicyRevoke = "revoke"
+	// OptOutPolicyRetain leaves the certificates valid until they expire
+	OptOutPolicyRetain = "retain"
+
+	// certmanOptOutCondition is set on ClusterDeployments with an OptOutAnnotation, its reason tells
+	// how far the opt-out went
+	certmanOptOutCondition hivev1.ClusterDeploymentConditionType = "CertmanOptOut"
+	optOutNotConfirmed                                           = "OptOutNotConfirmed"
+	optOutInProgress                                             = "OptOutInProgress"
+	optedOut                                                     = "OptedOut"
+
+	// optOutRecheckInterval is how often an opt-out checks whether the finalizers of its
+	// CertificateRequests are done
+	optOutRecheckInterval = 30 * time.Second
+)
+
+// isOptedOut returns true once the opt-out of cd is complete.
+func isOptedOut(cd *hivev1.ClusterDeployment) bool {
+	_, ok := cd.Annotations[OptedOutAnnotation]
+	return ok
+}
+
+// optOutPolicy returns the policy of the opt-out of cd, and whether an opt-out is requested. An
+// error tells why a requested opt-out can't be acted on.
+func optOutPolicy(cd *hivev1.ClusterDeployment) (string, bool, error) {
+	policy, ok := cd.Annotations[OptOutAnnotation]
+	if !ok {
+		return "", false, nil
+	}
+	if policy != OptOutPolicyRevoke && policy != OptOutPolicyRetain {
+		return policy, true, fmt.Errorf("the %s annotation must be %s or %s, got %q", OptOutAnnotation, OptOutPolicyRevoke, OptOutPolicyRetain, policy)
+	}
+	if cd.Annotations[OptOutConfirmAnnotation] != cd.Name {
+		return policy, true, fmt.Errorf("the opt-out must be confirmed by setting the %s annotation to %s", OptOutConfirmAnnotation, cd.Name)
+	}
+	return policy, true, nil
+}
+
+// optOut deletes the CertificateRequests of cd, revoking their certificates or not according to
+// policy, and then removes the finalizer of cd and marks it with the OptedOutAnnotation. The
+// certificate secrets are garbage collected with their CertificateRequests. The ClusterDeployment is
+// requeued until the finalizers of the CertificateRequests are done.
+func (r *ClusterDeploymentReconciler) optOut(cd *hivev1.ClusterDeployment, policy string, logger logr.Logger) (reconcile.Result, error) {
+	currentCRs, err := r.getCurrentCertificateRequests(cd, logger)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	revoke := policy == OptOutPolicyRevoke
+	for i := range currentCRs {
+		cr := &currentCRs[i]
+		if !cr.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if cr.Spec.RevokeOnDelete == nil || *cr.Spec.RevokeOnDelete != revoke {
+			baseToPatch := client.MergeFrom(cr.DeepCopy())
+			cr.Spec.RevokeOnDelete = &revoke
+			if err := r.Client.Patch(context.TODO(), cr, baseToPatch); err != nil {
+				logger.Error(err, "error setting the revocation policy of the opt-out", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, cr.Name))
+				return reconcile.Result{}, err
+			}
+		}
+		logger.Info(fmt.Sprintf("opting out: deleting CertificateRequest %v", cr.Name), "revoke", revoke)
+		if err := r.Client.Delete(context.TODO(), cr); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "error deleting CertificateRequest", logging.CertificateRequestKey, logging.ObjectName(cd.Namespace, cr.Name))
+			return reconcile.Result{}, err
+		}
+	}
+
+	remaining, err := r.getCurrentCertificateRequests(cd, logger)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if len(remaining) > 0 {
+		message := fmt.Sprintf("waiting for %d CertificateRequests to be deleted", len(remaining))
+		if err := r.setCondition(cd, certmanOptOutCondition, corev1.ConditionTrue, optOutInProgress, message); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: optOutRecheckInterval}, nil
+	}
+
+	logger.Info("opted out: removing CertmanOperator finalizer from the ClusterDeployment")
+	baseToPatch := client.MergeFrom(cd.DeepCopy())
+	cd.ObjectMeta.Finalizers = utils.RemoveString(cd.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel)
+	cd.Annotations[OptedOutAnnotation] = r.now().UTC().Format(time.RFC3339)
+	if err := r.Client.Patch(context.TODO(), cd, baseToPatch); err != nil {
+		logger.Error(err, "error marking the ClusterDeployment as opted out")
+		return reconcile.Result{}, err
+	}
+	localmetrics.ClearClusterMissingDependencies(cd.Namespace, cd.Name)
+
+	message := fmt.Sprintf("the CertificateRequests are deleted and their certificates were %s", map[bool]string{true: "revoked", false: "retained"}[revoke])
+	if r.Recorder != nil {
+		r.Recorder.Event(cd, corev1.EventTypeNormal, optedOut, message)
+	}
+	return reconcile.Result{}, r.setCondition(cd, certmanOptOutCondition, corev1.ConditionTrue, optedOut, message)
+}
@@ -0,0 +1,353 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterpush
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	// SyncSetPauseAnnotation is set to "true" on ClusterDeployments whose SyncSets Hive doesn't
+	// apply, the certificates of their clusters are pushed by the Pusher instead
+	SyncSetPauseAnnotation = "hive.openshift.io/syncset-pause"
+
+	// CertificatesPushedCondition reports on ClusterDeployments whose SyncSets are paused whether
+	// their certificates were last pushed to their cluster. It is removed once the SyncSets are
+	// applied again.
+	CertificatesPushedCondition hivev1.ClusterDeploymentConditionType = "CertificatesPushed"
+
+	// pushInterval is how often the certificates are pushed to the clusters
+	pushInterval = 10 * time.Minute
+	// spokeTimeout bounds each request to a cluster, so an unreachable one doesn't hold up the others
+	spokeTimeout = 30 * time.Second
+	// kubeconfigSecretKey is the key of the admin kubeconfig in the secret Hive writes it to
+	kubeconfigSecretKey = "kubeconfig"
+	// spokeCertificateNamespace is the namespace of the cluster the certificate secrets are pushed to
+	spokeCertificateNamespace = "openshift-config"
+	// apiServerName is the name of the APIServer configuration of the cluster
+	apiServerName = "cluster"
+)
+
+var log = logf.Log.WithName("clusterpush")
+
+var _ manager.LeaderElectionRunnable = &Pusher{}
+
+// Pusher pushes the issued certificates of the ClusterDeployments whose SyncSets are paused, such
+// as during Hive maintenance or in end-to-end tests, directly to their cluster with its admin
+// kubeconfig. Each certificate secret is applied to the openshift-config namespace of the cluster,
+// and the ones serving the control plane are named in its APIServer configuration, as Hive would.
+// The outcome is reported by the CertificatesPushedCondition of each ClusterDeployment and the
+// certman_operator_cluster_certificate_push_success metric.
+type Pusher struct {
+	Client client.Client
+	// NewSpokeClient builds a client of a cluster from its admin kubeconfig, it defaults to
+	// NewSpokeClient
+	NewSpokeClient func(kubeconfig []byte) (client.Client, error)
+}
+
+// Start pushes the certificates every pushInterval until ctx is done.
+func (p *Pusher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+
+	for {
+		p.push(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns true, the certificates are only pushed by the leader.
+func (p *Pusher) NeedLeaderElection() bool {
+	return true
+}
+
+// push pushes the certificates of each installed ClusterDeployment whose SyncSets are paused.
+func (p *Pusher) push(ctx context.Context) {
+	cdList := &hivev1.ClusterDeploymentList{}
+	if err := p.Client.List(ctx, cdList); err != nil {
+		log.Error(err, "could not list ClusterDeployments to push their certificates")
+		return
+	}
+
+	for i := range cdList.Items {
+		cd := &cdList.Items[i]
+		logger := log.WithValues("clusterdeployment", cd.Namespace+"/"+cd.Name)
+		if !syncSetsPaused(cd) || !cd.Spec.Installed || cd.DeletionTimestamp != nil {
+			localmetrics.ClearClusterCertificatePush(cd.Namespace, cd.Name)
+			if err := p.clearCondition(ctx, cd); err != nil {
+				logger.Error(err, "could not remove the CertificatesPushed condition")
+			}
+			continue
+		}
+
+		pushed, err := p.pushCluster(ctx, cd)
+		localmetrics.SetClusterCertificatePush(cd.Namespace, cd.Name, err == nil)
+		status, reason, message := corev1.ConditionTrue, "Pushed", fmt.Sprintf("pushed %d certificates to the cluster", pushed)
+		if err != nil {
+			logger.Error(err, "could not push the certificates to the cluster")
+			status, reason, message = corev1.ConditionFalse, "PushFailed", err.Error()
+		}
+		if err := p.setCondition(ctx, cd, status, reason, message); err != nil {
+			logger.Error(err, "could not set the CertificatesPushed condition")
+		}
+	}
+}
+
+// pushCluster applies the certificate secret of each issued CertificateRequest of cd to its
+// cluster, and names the ones serving the control plane in the APIServer configuration of the
+// cluster. It returns the number of certificates pushed.
+func (p *Pusher) pushCluster(ctx context.Context, cd *hivev1.ClusterDeployment) (int, error) {
+	if cd.Spec.ClusterMetadata == nil || cd.Spec.ClusterMetadata.AdminKubeconfigSecretRef.Name == "" {
+		return 0, fmt.Errorf("the ClusterDeployment has no admin kubeconfig")
+	}
+	kubeconfigSecret := &corev1.Secret{}
+	if err := p.Client.Get(ctx, types.NamespacedName{Namespace: cd.Namespace, Name: cd.Spec.ClusterMetadata.AdminKubeconfigSecretRef.Name}, kubeconfigSecret); err != nil {
+		return 0, fmt.Errorf("could not read the admin kubeconfig: %w", err)
+	}
+	if len(kubeconfigSecret.Data[kubeconfigSecretKey]) == 0 {
+		return 0, fmt.Errorf("secret %s has no %s", kubeconfigSecret.Name, kubeconfigSecretKey)
+	}
+
+	newSpokeClient := p.NewSpokeClient
+	if newSpokeClient == nil {
+		newSpokeClient = NewSpokeClient
+	}
+	spoke, err := newSpokeClient(kubeconfigSecret.Data[kubeconfigSecretKey])
+	if err != nil {
+		return 0, fmt.Errorf("could not connect to the cluster: %w", err)
+	}
+
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := p.Client.List(ctx, crList, client.InNamespace(cd.Namespace)); err != nil {
+		return 0, err
+	}
+
+	pushed := 0
+	namedCertificates := []configv1.APIServerNamedServingCert{}
+	for i := range crList.Items {
+		cr := &crList.Items[i]
+		if !cr.Status.Issued || cr.DeletionTimestamp != nil || !metav1.IsControlledBy(cr, cd) {
+			continue
+		}
+
+		secret := &corev1.Secret{}
+		if err := p.Client.Get(ctx, types.NamespacedName{Namespace: cr.Namespace, Name: cr.Spec.CertificateSecret.Name}, secret); err != nil {
+			return pushed, fmt.Errorf("could not read the certificate secret of CertificateRequest %s: %w", cr.Name, err)
+		}
+		if err := applySecret(ctx, spoke, secret); err != nil {
+			return pushed, fmt.Errorf("could not push the certificate secret %s: %w", secret.Name, err)
+		}
+		pushed++
+
+		if servesControlPlane(cd, cr) {
+			namedCertificates = append(namedCertificates, configv1.APIServerNamedServingCert{
+				Names:              controlPlaneNames(cr.Spec.DnsNames),
+				ServingCertificate: configv1.SecretNameReference{Name: secret.Name},
+			})
+		}
+	}
+
+	if len(namedCertificates) > 0 {
+		if err := applyNamedCertificates(ctx, spoke, namedCertificates); err != nil {
+			return pushed, fmt.Errorf("could not name the control plane certificates in the APIServer configuration: %w", err)
+		}
+	}
+	return pushed, nil
+}
+
+// applySecret creates or updates a copy of secret in the openshift-config namespace of spoke.
+func applySecret(ctx context.Context, spoke client.Client, secret *corev1.Secret) error {
+	existing := &corev1.Secret{}
+	err := spoke.Get(ctx, types.NamespacedName{Namespace: spokeCertificateNamespace, Name: secret.Name}, existing)
+	if errors.IsNotFound(err) {
+		return spoke.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: spokeCertificateNamespace, Name: secret.Name},
+			Type:       secret.Type,
+			Data:       secret.Data,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(existing.Data, secret.Data) {
+		return nil
+	}
+	existing.Data = secret.Data
+	return spoke.Update(ctx, existing)
+}
+
+// applyNamedCertificates replaces, or adds, the named certificates of the APIServer configuration
+// of spoke serving the secrets of namedCertificates. The other named certificates are kept.
+func applyNamedCertificates(ctx context.Context, spoke client.Client, namedCertificates []configv1.APIServerNamedServingCert) error {
+	apiServer := &configv1.APIServer{}
+	if err := spoke.Get(ctx, types.NamespacedName{Name: apiServerName}, apiServer); err != nil {
+		return err
+	}
+
+	current := apiServer.Spec.ServingCerts.NamedCertificates
+	desired := append([]configv1.APIServerNamedServingCert{}, current...)
+	for _, named := range namedCertificates {
+		found := false
+		for i := range desired {
+			if desired[i].ServingCertificate.Name == named.ServingCertificate.Name {
+				desired[i] = named
+				found = true
+			}
+		}
+		if !found {
+			desired = append(desired, named)
+		}
+	}
+	if reflect.DeepEqual(current, desired) {
+		return nil
+	}
+
+	apiServer.Spec.ServingCerts.NamedCertificates = desired
+	return spoke.Update(ctx, apiServer)
+}
+
+// servesControlPlane returns true when cr was generated for a certificate bundle serving the
+// control plane of cd.
+func servesControlPlane(cd *hivev1.ClusterDeployment, cr *certmanv1alpha1.CertificateRequest) bool {
+	bundle := cr.Labels[certmanv1alpha1.CertificateBundleLabel]
+	if bundle == "" {
+		return false
+	}
+	servingCertificates := cd.Spec.ControlPlaneConfig.ServingCertificates
+	if strings.EqualFold(servingCertificates.Default, bundle) {
+		return true
+	}
+	for _, additional := range servingCertificates.Additional {
+		if strings.EqualFold(additional.Name, bundle) {
+			return true
+		}
+	}
+	return false
+}
+
+// controlPlaneNames returns the DNS names the API servers serve a certificate for, leaving out the
+// wildcards the certificate may also cover.
+func controlPlaneNames(dnsNames []string) []string {
+	names := []string{}
+	for _, name := range dnsNames {
+		if !strings.HasPrefix(name, "*.") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// syncSetsPaused returns true when Hive doesn't apply the SyncSets of cd.
+func syncSetsPaused(cd *hivev1.ClusterDeployment) bool {
+	return cd.Annotations[SyncSetPauseAnnotation] == "true"
+}
+
+// setCondition sets the CertificatesPushedCondition of cd, keeping its transition time while the
+// status doesn't change. An unchanged condition isn't written again.
+func (p *Pusher) setCondition(ctx context.Context, cd *hivev1.ClusterDeployment, status corev1.ConditionStatus, reason, message string) error {
+	index := -1
+	for i, condition := range cd.Status.Conditions {
+		if condition.Type == CertificatesPushedCondition {
+			index = i
+			break
+		}
+	}
+	if index != -1 {
+		existing := cd.Status.Conditions[index]
+		if existing.Status == status && existing.Reason == reason && existing.Message == message {
+			return nil
+		}
+	}
+
+	now := metav1.Now()
+	condition := hivev1.ClusterDeploymentCondition{
+		Type:               CertificatesPushedCondition,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	}
+
+	baseToPatch := client.MergeFrom(cd.DeepCopy())
+	if index == -1 {
+		cd.Status.Conditions = append(cd.Status.Conditions, condition)
+	} else {
+		if cd.Status.Conditions[index].Status == status {
+			condition.LastTransitionTime = cd.Status.Conditions[index].LastTransitionTime
+		}
+		cd.Status.Conditions[index] = condition
+	}
+	return p.Client.Status().Patch(ctx, cd, baseToPatch)
+}
+
+// clearCondition removes the CertificatesPushedCondition of cd, if set.
+func (p *Pusher) clearCondition(ctx context.Context, cd *hivev1.ClusterDeployment) error {
+	for i, condition := range cd.Status.Conditions {
+		if condition.Type == CertificatesPushedCondition {
+			baseToPatch := client.MergeFrom(cd.DeepCopy())
+			cd.Status.Conditions = append(cd.Status.Conditions[:i], cd.Status.Conditions[i+1:]...)
+			return p.Client.Status().Patch(ctx, cd, baseToPatch)
+		}
+	}
+	return nil
+}
+
+// spokeScheme holds the types written to the clusters
+var spokeScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(spokeScheme))
+	utilruntime.Must(configv1.AddToScheme(spokeScheme))
+}
+
+// NewSpokeClient returns a client of the cluster of the admin kubeconfig.
+func NewSpokeClient(kubeconfig []byte) (client.Client, error) {
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Timeout = spokeTimeout
+	return client.New(cfg, client.Options{Scheme: spokeScheme})
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterpush
+
+import (
+	"context"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	testNamespace = "uhc-production-1234"
+	testCluster   = "mycluster"
+)
+
+func TestPush(t *testing.T) {
+	tests := []struct {
+		name            string
+		paused          bool
+		kubeconfig      []byte
+		expectCondition corev1.ConditionStatus
+		expectPushed    bool
+	}{
+		{
+			name:            "paused cluster",
+			paused:          true,
+			kubeconfig:      []byte("kubeconfig"),
+			expectCondition: corev1.ConditionTrue,
+			expectPushed:    true,
+		},
+		{
+			name:            "paused cluster without admin kubeconfig",
+			paused:          true,
+			expectCondition: corev1.ConditionFalse,
+		},
+		{
+			name:       "cluster whose SyncSets are applied",
+			kubeconfig: []byte("kubeconfig"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hub, spoke := testClients(t, test.paused, test.kubeconfig)
+			p := &Pusher{
+				Client: hub,
+				NewSpokeClient: func(kubeconfig []byte) (client.Client, error) {
+					assert.Equal(t, test.kubeconfig, kubeconfig)
+					return spoke, nil
+				},
+			}
+			p.push(context.TODO())
+
+			cd := &hivev1.ClusterDeployment{}
+			assert.NoError(t, hub.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testCluster}, cd))
+			var condition *hivev1.ClusterDeploymentCondition
+			for i := range cd.Status.Conditions {
+				if cd.Status.Conditions[i].Type == CertificatesPushedCondition {
+					condition = &cd.Status.Conditions[i]
+				}
+			}
+			if test.expectCondition == "" {
+				assert.Nil(t, condition)
+			} else if assert.NotNil(t, condition) {
+				assert.Equal(t, test.expectCondition, condition.Status)
+			}
+
+			secret := &corev1.Secret{}
+			err := spoke.Get(context.TODO(), types.NamespacedName{Namespace: spokeCertificateNamespace, Name: "primary-cert-bundle-secret"}, secret)
+			apiServer := &configv1.APIServer{}
+			assert.NoError(t, spoke.Get(context.TODO(), types.NamespacedName{Name: apiServerName}, apiServer))
+			if !test.expectPushed {
+				assert.Error(t, err)
+				assert.Empty(t, apiServer.Spec.ServingCerts.NamedCertificates)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("certificate"), secret.Data[corev1.TLSCertKey])
+			assert.Equal(t, []configv1.APIServerNamedServingCert{{
+				Names:              []string{"api.mycluster.example.com"},
+				ServingCertificate: configv1.SecretNameReference{Name: "primary-cert-bundle-secret"},
+			}}, apiServer.Spec.ServingCerts.NamedCertificates)
+			assert.Equal(t, 1.0, testutil.ToFloat64(localmetrics.MetricClusterCertificatePush.WithLabelValues(testNamespace, testCluster)))
+		})
+	}
+}
+
+// testClients returns a hub client holding a ClusterDeployment with an issued CertificateRequest
+// for its control plane, and the client of its cluster.
+func testClients(t *testing.T, paused bool, kubeconfig []byte) (client.Client, client.Client) {
+	t.Helper()
+
+	hubScheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(hubScheme))
+	assert.NoError(t, hivev1.AddToScheme(hubScheme))
+	assert.NoError(t, certmanv1alpha1.AddToScheme(hubScheme))
+
+	cd := &hivev1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testCluster, UID: "cd-uid"},
+		Spec: hivev1.ClusterDeploymentSpec{
+			Installed: true,
+			ControlPlaneConfig: hivev1.ControlPlaneConfigSpec{
+				ServingCertificates: hivev1.ControlPlaneServingCertificateSpec{Default: "primary-cert-bundle"},
+			},
+		},
+	}
+	if paused {
+		cd.Annotations = map[string]string{SyncSetPauseAnnotation: "true"}
+	}
+	objects := []runtime.Object{cd}
+	if kubeconfig != nil {
+		cd.Spec.ClusterMetadata = &hivev1.ClusterMetadata{AdminKubeconfigSecretRef: corev1.LocalObjectReference{Name: "admin-kubeconfig"}}
+		objects = append(objects, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "admin-kubeconfig"},
+			Data:       map[string][]byte{kubeconfigSecretKey: kubeconfig},
+		})
+	}
+
+	cr := &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       testNamespace,
+			Name:            testCluster + "-primary-cert-bundle",
+			Labels:          map[string]string{certmanv1alpha1.CertificateBundleLabel: "primary-cert-bundle"},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cd, hivev1.SchemeGroupVersion.WithKind("ClusterDeployment"))},
+		},
+		Spec: certmanv1alpha1.CertificateRequestSpec{
+			DnsNames:          []string{"api.mycluster.example.com", "*.apps.mycluster.example.com"},
+			CertificateSecret: corev1.ObjectReference{Name: "primary-cert-bundle-secret"},
+		},
+		Status: certmanv1alpha1.CertificateRequestStatus{Issued: true},
+	}
+	objects = append(objects, cr, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "primary-cert-bundle-secret"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("certificate"), corev1.TLSPrivateKeyKey: []byte("key")},
+	})
+
+	hub := fake.NewClientBuilder().WithScheme(hubScheme).WithRuntimeObjects(objects...).WithStatusSubresource(&hivev1.ClusterDeployment{}).Build()
+	spoke := fake.NewClientBuilder().WithScheme(spokeScheme).WithObjects(&configv1.APIServer{ObjectMeta: metav1.ObjectMeta{Name: apiServerName}}).Build()
+	return hub, spoke
+}
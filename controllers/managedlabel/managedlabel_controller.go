@@ -0,0 +1,105 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managedlabel
+
+import (
+	"context"
+	"fmt"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/clusterdeployment"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const controllerName = "controller_managedlabel"
+
+// ManagedProductLabel is set by OCM on the ClusterDeployments it provisions to the product of the cluster
+const ManagedProductLabel = "api.openshift.com/product"
+
+// managedProducts are the products whose clusters are Red Hat managed and should have certificates
+var managedProducts = []string{"osd", "osdtrial", "rosa"}
+
+var _ reconcile.Reconciler = &ManagedLabelReconciler{}
+
+// ManagedLabelReconciler notices ClusterDeployments of managed products that lack the managed label,
+// which the ClusterDeployment controller requires before requesting any certificate.
+type ManagedLabelReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile applies the ManagedLabelPolicy to a ClusterDeployment of a managed product without the
+// managed label: it either sets the label or reports the ClusterDeployment through the
+// certman_operator_unlabeled_managed_cluster metric. A label that is set, to any value, is left alone.
+func (r *ManagedLabelReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := logging.FromContext(ctx, logging.ClusterKey, logging.ObjectName(request.Namespace, request.Name))
+
+	cd := &hivev1.ClusterDeployment{}
+	err := r.Client.Get(ctx, request.NamespacedName, cd)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			localmetrics.ClearUnlabeledManagedCluster(request.Namespace, request.Name)
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if _, ok := cd.Labels[clusterdeployment.ClusterDeploymentManagedLabel]; ok || !isManagedProduct(cd) || !cd.DeletionTimestamp.IsZero() {
+		localmetrics.ClearUnlabeledManagedCluster(cd.Namespace, cd.Name)
+		return reconcile.Result{}, nil
+	}
+
+	if utils.GetManagedLabelPolicy(r.Client) != certmanv1alpha1.ManagedLabelPolicyDefault {
+		reqLogger.Info(fmt.Sprintf("ClusterDeployment of managed product %v has no %v label, no certificates will be requested for it", cd.Labels[ManagedProductLabel], clusterdeployment.ClusterDeploymentManagedLabel))
+		localmetrics.SetUnlabeledManagedCluster(cd.Namespace, cd.Name)
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info(fmt.Sprintf("setting the %v label on the ClusterDeployment of managed product %v", clusterdeployment.ClusterDeploymentManagedLabel, cd.Labels[ManagedProductLabel]))
+	baseToPatch := client.MergeFrom(cd.DeepCopy())
+	cd.Labels[clusterdeployment.ClusterDeploymentManagedLabel] = "true"
+	if err := r.Client.Patch(ctx, cd, baseToPatch); err != nil {
+		reqLogger.Error(err, "error setting the managed label on the ClusterDeployment")
+		return reconcile.Result{}, err
+	}
+	localmetrics.ClearUnlabeledManagedCluster(cd.Namespace, cd.Name)
+
+	return reconcile.Result{}, nil
+}
+
+// isManagedProduct returns true if cd is labelled by OCM as a cluster of a managed product.
+func isManagedProduct(cd *hivev1.ClusterDeployment) bool {
+	product, ok := cd.Labels[ManagedProductLabel]
+	return ok && utils.ContainsString(managedProducts, product)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ManagedLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("managedlabel").
+		For(&hivev1.ClusterDeployment{}).
+		Complete(r)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package managedlabel
+
+import (
+	"context"
+	"testing"
+
+	hiveapis "github.com/openshift/hive/apis"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/clusterdeployment"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	testNamespace   = "uhc-doesntexist-123456"
+	testClusterName = "test-cluster"
+)
+
+func TestReconcileManagedLabel(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	err = hiveapis.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	tests := []struct {
+		name            string
+		labels          map[string]string
+		policy          certmanv1alpha1.ManagedLabelPolicy
+		expectedManaged string
+		expectedMetric  float64
+	}{
+		{
+			name:           "unlabelled managed product is reported",
+			labels:         map[string]string{ManagedProductLabel: "rosa"},
+			policy:         certmanv1alpha1.ManagedLabelPolicyWarn,
+			expectedMetric: 1,
+		},
+		{
+			name:            "unlabelled managed product is labelled by default policy",
+			labels:          map[string]string{ManagedProductLabel: "osd"},
+			policy:          certmanv1alpha1.ManagedLabelPolicyDefault,
+			expectedManaged: "true",
+		},
+		{
+			name:            "explicitly unmanaged cluster is left alone",
+			labels:          map[string]string{ManagedProductLabel: "osd", clusterdeployment.ClusterDeploymentManagedLabel: "false"},
+			policy:          certmanv1alpha1.ManagedLabelPolicyDefault,
+			expectedManaged: "false",
+		},
+		{
+			name:   "cluster of another product is left alone",
+			labels: map[string]string{ManagedProductLabel: "ocp"},
+			policy: certmanv1alpha1.ManagedLabelPolicyDefault,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cd := &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      testClusterName,
+					Namespace: testNamespace,
+					Labels:    test.labels,
+				},
+			}
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.OperatorName,
+					Namespace: config.OperatorNamespace,
+				},
+				Data: map[string]string{
+					cTypes.ManagedLabelPolicy: string(test.policy),
+				},
+			}
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects([]runtime.Object{cd, cm}...).Build()
+			localmetrics.ClearUnlabeledManagedCluster(testNamespace, testClusterName)
+
+			r := &ManagedLabelReconciler{Client: kubeClient, Scheme: scheme.Scheme}
+			_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: testClusterName}})
+			assert.NoError(t, err)
+
+			actual := &hivev1.ClusterDeployment{}
+			err = kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: testNamespace, Name: testClusterName}, actual)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedManaged, actual.Labels[clusterdeployment.ClusterDeploymentManagedLabel])
+
+			metric := localmetrics.MetricUnlabeledManagedCluster.WithLabelValues(testNamespace, testClusterName)
+			assert.Equal(t, test.expectedMetric, testutil.ToFloat64(metric))
+		})
+	}
+}
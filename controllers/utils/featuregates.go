@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+
+	"github.com/openshift/certman-operator/config"
+)
+
+// FeatureGatesConfigMapName is the configmap of the operator namespace holding the feature gates.
+// Unlike the rest of the configuration it is read even when a CertmanOperatorConfig exists, so a
+// gate can always be flipped with a single edit.
+const FeatureGatesConfigMapName = "certman-operator-feature-gates"
+
+// FeatureGates stop a class of operations fleet-wide, such as during an incident of the CA or of a
+// cloud provider, while the operator keeps reconciling the status and metrics of every object.
+type FeatureGates struct {
+	// DisableIssuance stops new certificates and renewals from being requested
+	DisableIssuance bool
+	// DisableRevocation stops certificates from being revoked, the deletion of their
+	// CertificateRequests waits for the gate to be lifted
+	DisableRevocation bool
+	// DisabledProviders are the platforms, aws, gcp or azure, whose DNS API isn't called
+	DisabledProviders []string
+}
+
+// ProviderDisabled returns true when the DNS API of platform must not be called.
+func (g FeatureGates) ProviderDisabled(platform certmanv1alpha1.Platform) bool {
+	// the mock platform doesn't call any DNS API
+	provider := platform.Provider()
+	if provider == certmanv1alpha1.PlatformProviderMock {
+		return false
+	}
+	for _, disabled := range g.DisabledProviders {
+		if string(provider) == disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// GetFeatureGates returns the feature gates set in the FeatureGatesConfigMapName configmap. A missing
+// configmap, or a value that isn't a boolean, disables nothing. DisableProvider lists the disabled
+// platforms separated by commas.
+func GetFeatureGates(kubeClient client.Client) FeatureGates {
+	gates := FeatureGates{}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: FeatureGatesConfigMapName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return gates
+	}
+
+	gates.DisableIssuance, _ = strconv.ParseBool(cm.Data[cTypes.DisableIssuance])
+	gates.DisableRevocation, _ = strconv.ParseBool(cm.Data[cTypes.DisableRevocation])
+	for _, provider := range strings.Split(cm.Data[cTypes.DisableProvider], ",") {
+		if provider = strings.ToLower(strings.TrimSpace(provider)); provider != "" {
+			gates.DisabledProviders = append(gates.DisabledProviders, provider)
+		}
+	}
+
+	return gates
+}
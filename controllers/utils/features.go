@@ -0,0 +1,106 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+// FeaturesAnnotation lists, on a ClusterDeployment, the features enabled or, prefixed with "-",
+// disabled for the cluster, such as "propagation-check,-split-certificates".
+const FeaturesAnnotation = "certman.managed.openshift.io/features"
+
+const (
+	// FeaturePropagationCheck waits for the challenge records to be served by the nameservers of
+	// the DNS provider before having the ACME server validate them
+	FeaturePropagationCheck = "propagation-check"
+	// FeatureSplitCertificates splits the certificate bundles with more domains than Let's Encrypt
+	// allows on a certificate across several CertificateRequests
+	FeatureSplitCertificates = "split-certificates"
+)
+
+// defaultFeatures are the features of the clusters neither the feature gates configmap nor their
+// annotation say anything about. A new feature starts disabled, it is enabled on canary clusters
+// with their annotation, then for the fleet with the configmap, before its default flips here.
+var defaultFeatures = map[string]bool{
+	FeaturePropagationCheck:  true,
+	FeatureSplitCertificates: true,
+}
+
+// Features are the features enabled for a cluster. They roll risky behavior changes out
+// gradually, where the feature gates stop whole classes of operations.
+type Features map[string]bool
+
+// Enabled returns true when feature is enabled. Unknown features are disabled.
+func (f Features) Enabled(feature string) bool {
+	return f[feature]
+}
+
+// ParseFeatures applies the features listed in value, separated by commas, to a copy of defaults.
+// A name enables its feature, and disables it when prefixed with "-". Names the operator doesn't
+// know are kept, so a cluster can be annotated before the operator shipping its feature is.
+func ParseFeatures(value string, defaults Features) Features {
+	features := Features{}
+	for feature, enabled := range defaults {
+		features[feature] = enabled
+	}
+	for _, feature := range strings.Split(value, ",") {
+		feature = strings.ToLower(strings.TrimSpace(feature))
+		disabled := strings.HasPrefix(feature, "-")
+		if feature = strings.TrimPrefix(feature, "-"); feature != "" {
+			features[feature] = !disabled
+		}
+	}
+	return features
+}
+
+// GetFeatures returns the features of a cluster whose ClusterDeployment has annotations: the
+// defaults, overridden by the Features key of the FeatureGatesConfigMapName configmap for the whole
+// fleet, then by the FeaturesAnnotation. Nil annotations, such as for the canary, get the features of
+// the fleet.
+func GetFeatures(kubeClient client.Client, annotations map[string]string) Features {
+	features := Features(defaultFeatures)
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: FeatureGatesConfigMapName, Namespace: config.OperatorNamespace})
+	if err == nil {
+		features = ParseFeatures(cm.Data[cTypes.Features], features)
+	}
+	return ParseFeatures(annotations[FeaturesAnnotation], features)
+}
+
+// featuresKey is the context key of the features of the cluster being reconciled
+type featuresKey struct{}
+
+// FeaturesIntoContext returns a copy of ctx carrying features, for the functions called with ctx.
+func FeaturesIntoContext(ctx context.Context, features Features) context.Context {
+	return context.WithValue(ctx, featuresKey{}, features)
+}
+
+// FeaturesFromContext returns the features carried by ctx, or the defaults when it carries none.
+func FeaturesFromContext(ctx context.Context) Features {
+	if features, ok := ctx.Value(featuresKey{}).(Features); ok {
+		return features
+	}
+	return ParseFeatures("", defaultFeatures)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"strconv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ReconcilePanicCountAnnotation holds the number of times reconciling an object has panicked.
+	ReconcilePanicCountAnnotation = "certman.managed.openshift.io/reconcile-panic-count"
+	// PoisonPillAnnotation marks an object the controllers stop reconciling after repeated panics.
+	// Removing it (together with the panic count) lets the object be reconciled again.
+	PoisonPillAnnotation = "certman.managed.openshift.io/poison-pill"
+	// MaxReconcilePanics is the number of panics after which an object is marked as a poison pill.
+	MaxReconcilePanics = 3
+)
+
+// IsPoisonPill returns true if obj has been flagged with the poison pill annotation.
+func IsPoisonPill(obj client.Object) bool {
+	return obj.GetAnnotations()[PoisonPillAnnotation] == "true"
+}
+
+// RecordReconcilePanic increments the panic count annotation of obj and flags it with the
+// poison pill annotation once it reaches MaxReconcilePanics. It returns true if obj is now
+// a poison pill.
+func RecordReconcilePanic(kubeClient client.Client, obj client.Object) (bool, error) {
+	baseToPatch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	// an unparsable count is treated as no previous panics
+	panics, _ := strconv.Atoi(annotations[ReconcilePanicCountAnnotation])
+	panics++
+	annotations[ReconcilePanicCountAnnotation] = strconv.Itoa(panics)
+
+	poisoned := panics >= MaxReconcilePanics
+	if poisoned {
+		annotations[PoisonPillAnnotation] = "true"
+	}
+	obj.SetAnnotations(annotations)
+
+	if err := kubeClient.Patch(context.TODO(), obj, baseToPatch); err != nil {
+		return false, err
+	}
+
+	return poisoned, nil
+}
+
+// ClearReconcilePanics removes the panic count annotation of obj once it has been reconciled
+// successfully, so that only consecutive panics make it a poison pill. A poison pill keeps its
+// count until both annotations are removed.
+func ClearReconcilePanics(kubeClient client.Client, obj client.Object) error {
+	if _, ok := obj.GetAnnotations()[ReconcilePanicCountAnnotation]; !ok || IsPoisonPill(obj) {
+		return nil
+	}
+
+	baseToPatch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	annotations := obj.GetAnnotations()
+	delete(annotations, ReconcilePanicCountAnnotation)
+	obj.SetAnnotations(annotations)
+
+	return kubeClient.Patch(context.TODO(), obj, baseToPatch)
+}
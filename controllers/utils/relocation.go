@@ -0,0 +1,48 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// HiveRelocationAnnotation is set by Hive on a ClusterDeployment moved to another Hive, as
+	// "<hive>/<state>".
+	HiveRelocationAnnotation = "hive.openshift.io/relocate"
+	// hiveRelocationOutgoingValue is the state of a ClusterDeployment being moved away.
+	hiveRelocationOutgoingValue = "outgoing"
+)
+
+// IsRelocating returns true if obj is annotated with an outgoing Hive relocation.
+func IsRelocating(obj client.Object) bool {
+	value, ok := obj.GetAnnotations()[HiveRelocationAnnotation]
+	if !ok {
+		return false
+	}
+	_, state, found := strings.Cut(value, "/")
+	return found && state == hiveRelocationOutgoingValue
+}
+
+// NotRelocatingPredicate filters out the events of objects with an outgoing Hive relocation, which
+// aren't reconciled until the relocation completes or is cancelled.
+var NotRelocatingPredicate = predicate.NewPredicateFuncs(func(obj client.Object) bool {
+	return !IsRelocating(obj)
+})
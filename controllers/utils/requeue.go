@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WaitState is a reason for a reconcile to stop until something outside of the operator changes.
+// The object is requeued after the interval of its wait state instead of waiting for an unrelated
+// event.
+type WaitState string
+
+const (
+	// WaitFedrampConfig waits for the FedRAMP hosted zone of the operator to be configured
+	WaitFedrampConfig WaitState = "fedramp-config"
+	// WaitRelocation waits for the Hive relocation of a ClusterDeployment to complete or be cancelled
+	WaitRelocation WaitState = "relocation"
+	// WaitNotAuthorized waits for the policy of the operator to allow a CertificateRequest
+	WaitNotAuthorized WaitState = "not-authorized"
+	// WaitFeatureGate waits for a feature gate to allow issuance again
+	WaitFeatureGate WaitState = "feature-gate"
+	// WaitZoneDelegation waits for the public DNS to delegate the base domain of a new DNS zone
+	WaitZoneDelegation WaitState = "zone-delegation"
+	// WaitNotificationEmail waits for a notification email to be configured
+	WaitNotificationEmail WaitState = "notification-email"
+	// WaitIssuanceCeiling waits for older issuances of the ACME account to leave its weekly ceiling
+	WaitIssuanceCeiling WaitState = "issuance-ceiling"
+	// WaitDuplicate waits for the CertificateRequest a duplicate CertificateRequest defers to to go away
+	WaitDuplicate WaitState = "duplicate"
+	// WaitReconcileDeadline lets the other objects of the workers be reconciled before an issuance
+	// checkpointed at the reconcile deadline goes on
+	WaitReconcileDeadline WaitState = "reconcile-deadline"
+	// WaitIssuanceWorkers waits for the issuance workers to give a CertificateRequest handed over to
+	// them back to the controller
+	WaitIssuanceWorkers WaitState = "issuance-workers"
+)
+
+// DefaultRequeueIntervals are the requeue intervals of the wait states RequeueIntervals doesn't set.
+var DefaultRequeueIntervals = map[WaitState]time.Duration{
+	WaitFedrampConfig:     10 * time.Minute,
+	WaitRelocation:        10 * time.Minute,
+	WaitNotAuthorized:     5 * time.Minute,
+	WaitFeatureGate:       5 * time.Minute,
+	WaitZoneDelegation:    time.Minute,
+	WaitNotificationEmail: 5 * time.Minute,
+	WaitIssuanceCeiling:   time.Hour,
+	WaitDuplicate:         10 * time.Minute,
+	WaitReconcileDeadline: 10 * time.Second,
+	WaitIssuanceWorkers:   30 * time.Second,
+}
+
+// RequeueIntervals overrides the requeue intervals of some wait states. It is a flag.Value set
+// from a comma separated list of state=duration, such as "relocation=30m,feature-gate=1m".
+type RequeueIntervals map[WaitState]time.Duration
+
+// After returns the requeue interval of state.
+func (i RequeueIntervals) After(state WaitState) time.Duration {
+	if interval, ok := i[state]; ok {
+		return interval
+	}
+	return DefaultRequeueIntervals[state]
+}
+
+// String lists the overridden intervals, sorted by wait state.
+func (i RequeueIntervals) String() string {
+	intervals := make([]string, 0, len(i))
+	for state, interval := range i {
+		intervals = append(intervals, fmt.Sprintf("%s=%s", state, interval))
+	}
+	sort.Strings(intervals)
+	return strings.Join(intervals, ",")
+}
+
+// Set overrides the intervals listed in value. Unknown wait states and intervals that aren't
+// positive are rejected.
+func (i *RequeueIntervals) Set(value string) error {
+	if *i == nil {
+		*i = RequeueIntervals{}
+	}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, duration, found := strings.Cut(item, "=")
+		if !found {
+			return fmt.Errorf("%q is not a state=duration pair", item)
+		}
+		state := WaitState(strings.TrimSpace(name))
+		if _, ok := DefaultRequeueIntervals[state]; !ok {
+			return fmt.Errorf("unknown wait state %q", state)
+		}
+		interval, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil {
+			return fmt.Errorf("invalid interval of wait state %s: %w", state, err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("the interval of wait state %s must be positive, got %s", state, interval)
+		}
+		(*i)[state] = interval
+	}
+	return nil
+}
@@ -16,6 +16,8 @@ limitations under the License.
 
 package utils
 
+import "strings"
+
 // ContainsString returns true to caller if string arguments
 // is found in slice string arguments.
 func ContainsString(slice []string, s string) bool {
@@ -38,3 +40,23 @@ func RemoveString(slice []string, s string) (result []string) {
 	}
 	return
 }
+
+// DomainsOutsideZones returns the domains that are neither one of zones nor a subdomain of one.
+func DomainsOutsideZones(domains []string, zones []string) []string {
+	outside := []string{}
+	for _, domain := range domains {
+		name := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."))
+		found := false
+		for _, zone := range zones {
+			zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+			if zone != "" && (name == zone || strings.HasSuffix(name, "."+zone)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			outside = append(outside, domain)
+		}
+	}
+	return outside
+}
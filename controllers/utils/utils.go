@@ -18,33 +18,624 @@ package utils
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/oauth2/google"
 	dnsv1 "google.golang.org/api/dns/v1"
 	iamv1 "google.golang.org/api/iam/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
 
 	"github.com/openshift/certman-operator/config"
 )
 
-// After instantiating a configmap object, GetDefaultNotificationEmailAddress validates
-// if there is a default email address present or not.
+// DefaultNotificationEmailAddressEnvVar is the operator environment variable holding the
+// notification email used when neither the CertmanOperatorConfig nor the configmap set one.
+const DefaultNotificationEmailAddressEnvVar = "DEFAULT_NOTIFICATION_EMAIL_ADDRESS"
+
+// ErrNotificationEmailNotFound is returned when no default notification email is configured.
+var ErrNotificationEmailNotFound = goerrors.New("default notification email not found in CertmanOperatorConfig, configmap or " + DefaultNotificationEmailAddressEnvVar)
+
+// GetDefaultNotificationEmailAddress returns the default notification email address from the
+// CertmanOperatorConfig, falling back to the legacy operator configmap when it doesn't set one and
+// to the DEFAULT_NOTIFICATION_EMAIL_ADDRESS environment variable when neither sets it.
 func GetDefaultNotificationEmailAddress(kubeClient client.Client) (string, error) {
-	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	operatorConfig, err := getOperatorConfig(kubeClient)
 	if err != nil {
 		return "", err
 	}
+	if operatorConfig != nil && operatorConfig.Spec.DefaultNotificationEmailAddress != "" {
+		return operatorConfig.Spec.DefaultNotificationEmailAddress, nil
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	if cm != nil && cm.Data[cTypes.DefaultNotificationEmailAddress] != "" {
+		return cm.Data[cTypes.DefaultNotificationEmailAddress], nil
+	}
+
+	if email := os.Getenv(DefaultNotificationEmailAddressEnvVar); email != "" {
+		return email, nil
+	}
+
+	return "", ErrNotificationEmailNotFound
+}
+
+// GetDefaultReissueBeforeDays returns the number of days before expiry to reissue certificates
+// as set in the CertmanOperatorConfig, or 0 when it isn't configured.
+func GetDefaultReissueBeforeDays(kubeClient client.Client) int {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil || operatorConfig == nil {
+		return 0
+	}
+
+	return operatorConfig.Spec.ReissueBeforeDays
+}
+
+// KeepAcmeChallengeRecords returns true when the operator configuration asks for ACME challenge
+// records to be left in the DNS zone after issuance. This is only meant for debugging, so a
+// missing configuration or an unparsable value leaves the records to be cleaned up.
+func KeepAcmeChallengeRecords(kubeClient client.Client) bool {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return false
+	}
+	if operatorConfig != nil && operatorConfig.Spec.KeepAcmeChallengeRecords != nil {
+		return *operatorConfig.Spec.KeepAcmeChallengeRecords
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return false
+	}
+
+	keep, err := strconv.ParseBool(cm.Data[cTypes.KeepAcmeChallengeRecords])
+	if err != nil {
+		return false
+	}
+
+	return keep
+}
+
+// GetIngressDomainPolicy returns the default IngressDomainPolicy from the operator configuration.
+// A missing or unknown policy results in IngressDomainPolicyWildcard.
+func GetIngressDomainPolicy(kubeClient client.Client) certmanv1alpha1.IngressDomainPolicy {
+	policy := certmanv1alpha1.IngressDomainPolicyWildcard
+
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return policy
+	}
+	if operatorConfig != nil && ValidIngressDomainPolicy(operatorConfig.Spec.IngressDomainPolicy) {
+		return operatorConfig.Spec.IngressDomainPolicy
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return policy
+	}
+	if configured := certmanv1alpha1.IngressDomainPolicy(cm.Data[cTypes.IngressDomainPolicy]); ValidIngressDomainPolicy(configured) {
+		policy = configured
+	}
+
+	return policy
+}
+
+// GetManagedLabelPolicy returns the ManagedLabelPolicy from the operator configuration. A missing
+// or unknown policy results in ManagedLabelPolicyWarn.
+func GetManagedLabelPolicy(kubeClient client.Client) certmanv1alpha1.ManagedLabelPolicy {
+	policy := certmanv1alpha1.ManagedLabelPolicyWarn
+
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return policy
+	}
+	if operatorConfig != nil && operatorConfig.Spec.ManagedLabelPolicy != "" {
+		if operatorConfig.Spec.ManagedLabelPolicy == certmanv1alpha1.ManagedLabelPolicyDefault {
+			policy = operatorConfig.Spec.ManagedLabelPolicy
+		}
+		return policy
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return policy
+	}
+	if configured := certmanv1alpha1.ManagedLabelPolicy(cm.Data[cTypes.ManagedLabelPolicy]); configured == certmanv1alpha1.ManagedLabelPolicyDefault {
+		policy = configured
+	}
+
+	return policy
+}
+
+// GetACMEEnvironment returns the ACME environment certificates are requested from and, for the
+// Custom environment, the URL of its directory. An empty environment is returned when the operator
+// configuration doesn't select one.
+func GetACMEEnvironment(kubeClient client.Client) (certmanv1alpha1.ACMEEnvironment, string, error) {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return "", "", err
+	}
+	if operatorConfig != nil && operatorConfig.Spec.ACMEEnvironment != "" {
+		return operatorConfig.Spec.ACMEEnvironment, operatorConfig.Spec.ACMEDirectoryURL, nil
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+
+	return certmanv1alpha1.ACMEEnvironment(cm.Data[cTypes.ACMEEnvironment]), cm.Data[cTypes.ACMEDirectoryURL], nil
+}
+
+// GetCanaryConfig returns the canary configuration of the operator, or nil when the canary is
+// disabled. The canary can only be configured with a CertmanOperatorConfig.
+func GetCanaryConfig(kubeClient client.Client) *certmanv1alpha1.CanaryConfig {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil || operatorConfig == nil {
+		return nil
+	}
+
+	return operatorConfig.Spec.Canary
+}
+
+// GetCertificateQualityPolicy returns the checks the issued certificates must pass, or nil when
+// they aren't checked. The policy can only be configured with a CertmanOperatorConfig.
+func GetCertificateQualityPolicy(kubeClient client.Client) (*certmanv1alpha1.CertificateQualityPolicy, error) {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil || operatorConfig == nil {
+		return nil, err
+	}
 
-	if cm.Data[cTypes.DefaultNotificationEmailAddress] == "" {
-		return "", fmt.Errorf("Default notification email not found in configmap.")
+	return operatorConfig.Spec.CertificateQualityPolicy, nil
+}
+
+// GetDNSChangeMetadata returns how the DNS changes of the operator are described to the DNS
+// providers, or nil when the defaults apply. It can only be configured with a CertmanOperatorConfig.
+func GetDNSChangeMetadata(kubeClient client.Client) (*certmanv1alpha1.DNSChangeMetadata, error) {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil || operatorConfig == nil {
+		return nil, err
+	}
+
+	return operatorConfig.Spec.DNSChangeMetadata, nil
+}
+
+// GetPlatformDefaults returns the regions and clouds of the platforms that don't set them, empty
+// when the defaults of the DNS providers apply. It can only be configured with a
+// CertmanOperatorConfig.
+func GetPlatformDefaults(kubeClient client.Client) (certmanv1alpha1.PlatformDefaults, error) {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil || operatorConfig == nil || operatorConfig.Spec.PlatformDefaults == nil {
+		return certmanv1alpha1.PlatformDefaults{}, err
+	}
+
+	return *operatorConfig.Spec.PlatformDefaults, nil
+}
+
+// DefaultChallengeValidationTimeout is how long the ACME challenge of a domain is waited for when
+// the operator configuration doesn't set a timeout.
+const DefaultChallengeValidationTimeout = 5 * time.Minute
+
+// GetChallengeValidationTimeout returns how long to wait for the ACME challenge of each domain to
+// be validated. A missing or invalid timeout results in DefaultChallengeValidationTimeout.
+func GetChallengeValidationTimeout(kubeClient client.Client) time.Duration {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return DefaultChallengeValidationTimeout
+	}
+	if operatorConfig != nil && operatorConfig.Spec.ChallengeValidationTimeout != nil {
+		if operatorConfig.Spec.ChallengeValidationTimeout.Duration <= 0 {
+			return DefaultChallengeValidationTimeout
+		}
+		return operatorConfig.Spec.ChallengeValidationTimeout.Duration
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return DefaultChallengeValidationTimeout
 	}
 
-	return cm.Data[cTypes.DefaultNotificationEmailAddress], nil
+	timeout, err := time.ParseDuration(cm.Data[cTypes.ChallengeValidationTimeout])
+	if err != nil || timeout <= 0 {
+		return DefaultChallengeValidationTimeout
+	}
+
+	return timeout
+}
+
+// DefaultReconcileDeadline is how long a reconcile issuing a certificate runs before checkpointing
+// the issuance when the operator configuration doesn't set a deadline.
+const DefaultReconcileDeadline = 10 * time.Minute
+
+// GetReconcileDeadline returns how long a reconcile issuing a certificate runs before the issuance
+// is checkpointed and requeued. A missing or invalid deadline results in DefaultReconcileDeadline.
+func GetReconcileDeadline(kubeClient client.Client) time.Duration {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return DefaultReconcileDeadline
+	}
+	if operatorConfig != nil && operatorConfig.Spec.ReconcileDeadline != nil {
+		if operatorConfig.Spec.ReconcileDeadline.Duration <= 0 {
+			return DefaultReconcileDeadline
+		}
+		return operatorConfig.Spec.ReconcileDeadline.Duration
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return DefaultReconcileDeadline
+	}
+
+	deadline, err := time.ParseDuration(cm.Data[cTypes.ReconcileDeadline])
+	if err != nil || deadline <= 0 {
+		return DefaultReconcileDeadline
+	}
+
+	return deadline
+}
+
+// AllowPartialIssuance returns true when the operator configuration allows issuing certificates
+// for the subset of domains of a CertificateRequest that were validated.
+func AllowPartialIssuance(kubeClient client.Client) bool {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return false
+	}
+	if operatorConfig != nil && operatorConfig.Spec.AllowPartialIssuance != nil {
+		return *operatorConfig.Spec.AllowPartialIssuance
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return false
+	}
+
+	allow, err := strconv.ParseBool(cm.Data[cTypes.AllowPartialIssuance])
+	if err != nil {
+		return false
+	}
+
+	return allow
+}
+
+// RevokeOnDelete returns false when the operator configuration disables revoking the certificates
+// of deleted CertificateRequests, as staging environments may. Certificates are revoked otherwise,
+// including when the configuration can't be read.
+func RevokeOnDelete(kubeClient client.Client) bool {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return true
+	}
+	if operatorConfig != nil && operatorConfig.Spec.RevokeOnDelete != nil {
+		return *operatorConfig.Spec.RevokeOnDelete
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return true
+	}
+
+	revoke, err := strconv.ParseBool(cm.Data[cTypes.RevokeOnDelete])
+	if err != nil {
+		return true
+	}
+
+	return revoke
+}
+
+// SyncCertificatesToClusters returns true when the operator configuration has the certificates of
+// clusters copied to them with Hive SyncSets.
+func SyncCertificatesToClusters(kubeClient client.Client) bool {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return false
+	}
+	if operatorConfig != nil && operatorConfig.Spec.SyncCertificatesToClusters != nil {
+		return *operatorConfig.Spec.SyncCertificatesToClusters
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return false
+	}
+
+	sync, err := strconv.ParseBool(cm.Data[cTypes.SyncCertificatesToClusters])
+	if err != nil {
+		return false
+	}
+
+	return sync
+}
+
+// GetMaintenanceWindow returns the maintenance window renewals are restricted to in the operator
+// configuration, or an empty string when renewals aren't restricted.
+func GetMaintenanceWindow(kubeClient client.Client) string {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return ""
+	}
+	if operatorConfig != nil && strings.TrimSpace(operatorConfig.Spec.MaintenanceWindow) != "" {
+		return strings.TrimSpace(operatorConfig.Spec.MaintenanceWindow)
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(cm.Data[cTypes.MaintenanceWindow])
+}
+
+// DefaultUrgentRenewalDays is the number of days before expiry from which certificates are renewed
+// outside of the maintenance window when the operator configuration doesn't set it.
+const DefaultUrgentRenewalDays = 14
+
+// GetUrgentRenewalDays returns the number of days before expiry from which certificates are renewed
+// outside of the maintenance window. A missing or invalid value results in DefaultUrgentRenewalDays.
+func GetUrgentRenewalDays(kubeClient client.Client) int {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return DefaultUrgentRenewalDays
+	}
+	if operatorConfig != nil && operatorConfig.Spec.UrgentRenewalDays > 0 {
+		return operatorConfig.Spec.UrgentRenewalDays
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return DefaultUrgentRenewalDays
+	}
+
+	days, err := strconv.Atoi(cm.Data[cTypes.UrgentRenewalDays])
+	if err != nil || days <= 0 {
+		return DefaultUrgentRenewalDays
+	}
+
+	return days
+}
+
+// GetACMEAccountWeeklyIssuanceCeiling returns the number of certificates issued with an ACME
+// account in 7 days beyond which issuance waits, or 0 when issuance isn't limited.
+func GetACMEAccountWeeklyIssuanceCeiling(kubeClient client.Client) int {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return 0
+	}
+	if operatorConfig != nil && operatorConfig.Spec.ACMEAccountWeeklyIssuanceCeiling > 0 {
+		return operatorConfig.Spec.ACMEAccountWeeklyIssuanceCeiling
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return 0
+	}
+
+	ceiling, err := strconv.Atoi(cm.Data[cTypes.ACMEAccountWeeklyCeiling])
+	if err != nil || ceiling < 0 {
+		return 0
+	}
+
+	return ceiling
+}
+
+// GetDelegatedZoneCredentials returns the names of the Secrets holding the AWS credentials of the
+// accounts subdomains of clusters are delegated to. The legacy configmap lists them separated by commas.
+func GetDelegatedZoneCredentials(kubeClient client.Client) []string {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return nil
+	}
+	if operatorConfig != nil && len(operatorConfig.Spec.DelegatedZoneCredentials) > 0 {
+		return operatorConfig.Spec.DelegatedZoneCredentials
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return nil
+	}
+
+	secretNames := []string{}
+	for _, name := range strings.Split(cm.Data[cTypes.DelegatedZoneCredentials], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			secretNames = append(secretNames, name)
+		}
+	}
+
+	return secretNames
+}
+
+// ManageZoneRecords returns true when the operator configuration enables the CAA and ownership
+// TXT records of the clusters.
+func ManageZoneRecords(kubeClient client.Client) bool {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return false
+	}
+	if operatorConfig != nil && operatorConfig.Spec.ManageZoneRecords != nil {
+		return *operatorConfig.Spec.ManageZoneRecords
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return false
+	}
+
+	manage, err := strconv.ParseBool(cm.Data[cTypes.ManageZoneRecords])
+	if err != nil {
+		return false
+	}
+
+	return manage
+}
+
+// StrictDelegationCheck returns true when the operator configuration requires the base domain of a
+// CertificateRequest to be delegated to its cloud provider zone before issuing certificates.
+func StrictDelegationCheck(kubeClient client.Client) bool {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return false
+	}
+	if operatorConfig != nil && operatorConfig.Spec.StrictDelegationCheck != nil {
+		return *operatorConfig.Spec.StrictDelegationCheck
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return false
+	}
+
+	strict, err := strconv.ParseBool(cm.Data[cTypes.StrictDelegationCheck])
+	if err != nil {
+		return false
+	}
+
+	return strict
+}
+
+// GetAllowedDNSZones returns the DNS zones, besides the base domain of a cluster, that certificate
+// domains may be requested in. The legacy configmap lists them separated by commas.
+func GetAllowedDNSZones(kubeClient client.Client) []string {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return nil
+	}
+	if operatorConfig != nil && len(operatorConfig.Spec.AllowedDNSZones) > 0 {
+		return operatorConfig.Spec.AllowedDNSZones
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		return nil
+	}
+
+	zones := []string{}
+	for _, zone := range strings.Split(cm.Data[cTypes.AllowedDNSZones], ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+
+	return zones
+}
+
+// GetApprovedBaseDomains returns the domains that certificates may only be issued under, or nil
+// when issuance isn't restricted. The legacy configmap lists them separated by commas. Unlike the
+// allowed DNS zones, an error reading the configuration is returned so callers don't issue
+// certificates unrestricted while it can't be read.
+func GetApprovedBaseDomains(kubeClient client.Client) ([]string, error) {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	if operatorConfig != nil && len(operatorConfig.Spec.ApprovedBaseDomains) > 0 {
+		return operatorConfig.Spec.ApprovedBaseDomains, nil
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var domains []string
+	for _, domain := range strings.Split(cm.Data[cTypes.ApprovedBaseDomains], ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	return domains, nil
+}
+
+// GetCertificateRequestPolicy returns the policy restricting the CertificateRequests the operator
+// acts on, or nil when every CertificateRequest is acted on. The legacy configmap lists the allowed
+// namespaces separated by commas, the selector as a label selector string and the requester quotas
+// as requester=limit pairs separated by commas.
+func GetCertificateRequestPolicy(kubeClient client.Client) (*certmanv1alpha1.CertificateRequestPolicy, error) {
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	if operatorConfig != nil && operatorConfig.Spec.CertificateRequestPolicy != nil {
+		return operatorConfig.Spec.CertificateRequestPolicy, nil
+	}
+
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	namespaces, hasNamespaces := cm.Data[cTypes.AllowedNamespaces]
+	selector, hasSelector := cm.Data[cTypes.CertificateRequestSelector]
+	quotas, hasQuotas := cm.Data[cTypes.RequesterQuotas]
+	if !hasNamespaces && !hasSelector && !hasQuotas {
+		return nil, nil
+	}
+
+	policy := &certmanv1alpha1.CertificateRequestPolicy{}
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			policy.AllowedNamespaces = append(policy.AllowedNamespaces, namespace)
+		}
+	}
+	if strings.TrimSpace(selector) != "" {
+		policy.Selector, err = metav1.ParseToLabelSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, quota := range strings.Split(quotas, ",") {
+		if quota = strings.TrimSpace(quota); quota == "" {
+			continue
+		}
+		requester, limit, found := strings.Cut(quota, "=")
+		count, err := strconv.Atoi(strings.TrimSpace(limit))
+		if !found || err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid requester quota %q, expected requester=limit", quota)
+		}
+		if policy.RequesterQuotas == nil {
+			policy.RequesterQuotas = map[string]int{}
+		}
+		policy.RequesterQuotas[strings.TrimSpace(requester)] = count
+	}
+
+	return policy, nil
+}
+
+// ValidIngressDomainPolicy returns true if policy is one of the known IngressDomainPolicies.
+func ValidIngressDomainPolicy(policy certmanv1alpha1.IngressDomainPolicy) bool {
+	switch policy {
+	case certmanv1alpha1.IngressDomainPolicyWildcard, certmanv1alpha1.IngressDomainPolicyExact, certmanv1alpha1.IngressDomainPolicyBoth:
+		return true
+	}
+	return false
 }
 
 func GetCredentialsJSON(kubeClient client.Client, namespacesedName types.NamespacedName) (*google.Credentials, error) {
@@ -63,6 +654,43 @@ func GetCredentialsJSON(kubeClient client.Client, namespacesedName types.Namespa
 	return cred, err
 }
 
+// ConfigHash returns a short hash of the operator configuration in use: the spec of the
+// CertmanOperatorConfig, the data of the legacy configmap, or both when the configmap still sets
+// the fields the CertmanOperatorConfig leaves unset. It returns an empty string when the operator
+// isn't configured.
+func ConfigHash(kubeClient client.Client) (string, error) {
+	var configuration interface{}
+
+	operatorConfig, err := getOperatorConfig(kubeClient)
+	if err != nil {
+		return "", err
+	}
+	cm, err := getConfig(kubeClient, types.NamespacedName{Name: config.OperatorName, Namespace: config.OperatorNamespace})
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	switch {
+	case operatorConfig != nil && cm != nil:
+		configuration = struct {
+			Spec      certmanv1alpha1.CertmanOperatorConfigSpec
+			ConfigMap map[string]string
+		}{operatorConfig.Spec, cm.Data}
+	case operatorConfig != nil:
+		configuration = operatorConfig.Spec
+	case cm != nil:
+		configuration = cm.Data
+	default:
+		return "", nil
+	}
+
+	data, err := json.Marshal(configuration)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
 // getConfig retrieves config from kubernetes and returns a ConfigMap object.
 func getConfig(kubeClient client.Client, namespacesedName types.NamespacedName) (*corev1.ConfigMap, error) {
 	cm := &corev1.ConfigMap{}
@@ -74,6 +702,23 @@ func getConfig(kubeClient client.Client, namespacesedName types.NamespacedName)
 	return cm, nil
 }
 
+// getOperatorConfig retrieves the cluster scoped CertmanOperatorConfig named after the operator.
+// It returns nil without an error when the object or its CRD doesn't exist. Callers fall back to
+// the legacy configmap for the fields it leaves unset, so the settings can be moved over one at a
+// time.
+func getOperatorConfig(kubeClient client.Client) (*certmanv1alpha1.CertmanOperatorConfig, error) {
+	operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{}
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Name: config.OperatorName}, operatorConfig)
+	if err != nil {
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return operatorConfig, nil
+}
+
 // getSecret retrieves config from kubernetes and returns a ConfigMap object.
 func getSecret(kubeClient client.Client, namespacesedName types.NamespacedName) (*corev1.Secret, error) {
 	secret := &corev1.Secret{}
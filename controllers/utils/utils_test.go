@@ -19,10 +19,12 @@ package utils
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/openshift/certman-operator/config"
 	"github.com/stretchr/testify/assert"
 
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,6 +33,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 /* Fake objects/vars */
@@ -186,6 +189,111 @@ func TestGetDefaultNotificationEmailAddress(t *testing.T) {
 	}
 }
 
+func TestOperatorConfigOverridesConfigMap(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	legacyConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.OperatorName,
+			Namespace: config.OperatorNamespace,
+		},
+		Data: map[string]string{
+			cTypes.DefaultNotificationEmailAddress: fakeEmailAddress,
+			cTypes.KeepAcmeChallengeRecords:        "true",
+			cTypes.RevokeOnDelete:                  "false",
+			cTypes.MaintenanceWindow:               " 0 2 * * 6 4h ",
+			cTypes.UrgentRenewalDays:               "7",
+		},
+	}
+	keep, revoke := false, true
+	operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: config.OperatorName,
+		},
+		Spec: certmanv1alpha1.CertmanOperatorConfigSpec{
+			DefaultNotificationEmailAddress: "config@example.com",
+			ReissueBeforeDays:               30,
+			KeepAcmeChallengeRecords:        &keep,
+			RevokeOnDelete:                  &revoke,
+			MaintenanceWindow:               "0 3 * * 0 2h",
+			UrgentRenewalDays:               21,
+		},
+	}
+
+	t.Run("CertmanOperatorConfig takes precedence", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(legacyConfigMap, operatorConfig).Build()
+
+		email, err := GetDefaultNotificationEmailAddress(fakeClient)
+		assert.NoError(t, err)
+		assert.Equal(t, "config@example.com", email)
+		assert.False(t, KeepAcmeChallengeRecords(fakeClient))
+		assert.Equal(t, 30, GetDefaultReissueBeforeDays(fakeClient))
+		assert.True(t, RevokeOnDelete(fakeClient))
+		assert.Equal(t, "0 3 * * 0 2h", GetMaintenanceWindow(fakeClient))
+		assert.Equal(t, 21, GetUrgentRenewalDays(fakeClient))
+	})
+
+	t.Run("ConfigMap fills in the fields the CertmanOperatorConfig leaves unset", func(t *testing.T) {
+		partial := &certmanv1alpha1.CertmanOperatorConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName},
+			Spec:       certmanv1alpha1.CertmanOperatorConfigSpec{ReissueBeforeDays: 30},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(legacyConfigMap, partial).Build()
+
+		email, err := GetDefaultNotificationEmailAddress(fakeClient)
+		assert.NoError(t, err)
+		assert.Equal(t, fakeEmailAddress, email)
+		assert.True(t, KeepAcmeChallengeRecords(fakeClient))
+		assert.Equal(t, 30, GetDefaultReissueBeforeDays(fakeClient))
+		assert.False(t, RevokeOnDelete(fakeClient))
+		assert.Equal(t, "0 2 * * 6 4h", GetMaintenanceWindow(fakeClient))
+		assert.Equal(t, 7, GetUrgentRenewalDays(fakeClient))
+	})
+
+	t.Run("ConfigMap is used without a CertmanOperatorConfig", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(legacyConfigMap).Build()
+
+		email, err := GetDefaultNotificationEmailAddress(fakeClient)
+		assert.NoError(t, err)
+		assert.Equal(t, fakeEmailAddress, email)
+		assert.True(t, KeepAcmeChallengeRecords(fakeClient))
+		assert.Equal(t, 0, GetDefaultReissueBeforeDays(fakeClient))
+		assert.False(t, RevokeOnDelete(fakeClient))
+		assert.Equal(t, "0 2 * * 6 4h", GetMaintenanceWindow(fakeClient))
+		assert.Equal(t, 7, GetUrgentRenewalDays(fakeClient))
+	})
+}
+
+func TestConfigHash(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	assert.Nil(t, err, "Error returned while attempting to AddToScheme: %q", err)
+
+	operatorConfig := &certmanv1alpha1.CertmanOperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName},
+		Spec:       certmanv1alpha1.CertmanOperatorConfigSpec{DefaultNotificationEmailAddress: fakeEmailAddress},
+	}
+
+	unconfigured, err := ConfigHash(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build())
+	assert.NoError(t, err)
+	assert.Empty(t, unconfigured)
+
+	fromConfigMap, err := ConfigHash(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(testConfigMap).Build())
+	assert.NoError(t, err)
+	assert.Len(t, fromConfigMap, 16)
+
+	fromOperatorConfig, err := ConfigHash(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(testConfigMap, operatorConfig).Build())
+	assert.NoError(t, err)
+	assert.Len(t, fromOperatorConfig, 16)
+	assert.NotEqual(t, fromConfigMap, fromOperatorConfig)
+
+	changed := operatorConfig.DeepCopy()
+	changed.Spec.ReissueBeforeDays = 30
+	fromChangedConfig, err := ConfigHash(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(changed).Build())
+	assert.NoError(t, err)
+	assert.NotEqual(t, fromOperatorConfig, fromChangedConfig)
+}
+
 func TestGetCredentialsJSON(t *testing.T) {
 
 	testUnits := []struct {
@@ -248,3 +356,166 @@ func TestRemoveString(t *testing.T) {
 		assert.Equal(t, result, sliceOfStrings)
 	})
 }
+
+func TestDomainsOutsideZones(t *testing.T) {
+	zones := []string{"testing.example.com", "Allowed.Example.org."}
+	domains := []string{
+		"api.testing.example.com",
+		"*.apps.testing.example.com",
+		"testing.example.com",
+		"custom.allowed.example.org",
+		"notallowed.example.org",
+		"fooexample.com",
+	}
+
+	assert.Equal(t, []string{"notallowed.example.org", "fooexample.com"}, DomainsOutsideZones(domains, zones))
+}
+
+func TestRecordReconcilePanic(t *testing.T) {
+	t.Run("Validate RecordReconcilePanic marks a poison pill after MaxReconcilePanics", func(t *testing.T) {
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fakeOperatorName,
+				Namespace: fakeOperatorNamespace,
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(cm).Build()
+
+		for i := 1; i <= MaxReconcilePanics; i++ {
+			obj := &v1.ConfigMap{}
+			err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: fakeOperatorName, Namespace: fakeOperatorNamespace}, obj)
+			assert.NoError(t, err)
+			assert.False(t, IsPoisonPill(obj))
+
+			poisoned, err := RecordReconcilePanic(fakeClient, obj)
+			assert.NoError(t, err)
+			assert.Equal(t, i == MaxReconcilePanics, poisoned)
+		}
+
+		obj := &v1.ConfigMap{}
+		err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: fakeOperatorName, Namespace: fakeOperatorNamespace}, obj)
+		assert.NoError(t, err)
+		assert.True(t, IsPoisonPill(obj))
+		assert.Equal(t, "3", obj.Annotations[ReconcilePanicCountAnnotation])
+	})
+
+	t.Run("Validate ClearReconcilePanics only forgets the panics of objects that aren't poison pills", func(t *testing.T) {
+		panicked := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "panicked",
+				Namespace:   fakeOperatorNamespace,
+				Annotations: map[string]string{ReconcilePanicCountAnnotation: "2"},
+			},
+		}
+		poisoned := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "poisoned",
+				Namespace:   fakeOperatorNamespace,
+				Annotations: map[string]string{ReconcilePanicCountAnnotation: "3", PoisonPillAnnotation: "true"},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(panicked, poisoned).Build()
+
+		for _, name := range []string{"panicked", "poisoned"} {
+			obj := &v1.ConfigMap{}
+			assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: fakeOperatorNamespace}, obj))
+			assert.NoError(t, ClearReconcilePanics(fakeClient, obj))
+		}
+
+		obj := &v1.ConfigMap{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "panicked", Namespace: fakeOperatorNamespace}, obj))
+		assert.NotContains(t, obj.Annotations, ReconcilePanicCountAnnotation)
+
+		assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "poisoned", Namespace: fakeOperatorNamespace}, obj))
+		assert.True(t, IsPoisonPill(obj))
+		assert.Equal(t, "3", obj.Annotations[ReconcilePanicCountAnnotation])
+	})
+}
+
+func TestIsRelocating(t *testing.T) {
+	tests := map[string]bool{
+		"newhive/outgoing": true,
+		"newhive/incoming": false,
+		"newhive/complete": false,
+		"outgoing":         false,
+	}
+	for value, expected := range tests {
+		t.Run("Validate IsRelocating with "+value, func(t *testing.T) {
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{HiveRelocationAnnotation: value}}}
+			assert.Equal(t, expected, IsRelocating(cm))
+			assert.Equal(t, !expected, NotRelocatingPredicate.Generic(event.GenericEvent{Object: cm}))
+		})
+	}
+
+	assert.False(t, IsRelocating(&v1.ConfigMap{}))
+}
+
+func TestRequeueIntervals(t *testing.T) {
+	t.Run("defaults to the default intervals", func(t *testing.T) {
+		var intervals RequeueIntervals
+		assert.Equal(t, DefaultRequeueIntervals[WaitRelocation], intervals.After(WaitRelocation))
+	})
+
+	t.Run("overrides the listed intervals", func(t *testing.T) {
+		intervals := RequeueIntervals{}
+		assert.NoError(t, intervals.Set("relocation=30m, feature-gate=1m"))
+		assert.Equal(t, 30*time.Minute, intervals.After(WaitRelocation))
+		assert.Equal(t, time.Minute, intervals.After(WaitFeatureGate))
+		assert.Equal(t, DefaultRequeueIntervals[WaitZoneDelegation], intervals.After(WaitZoneDelegation))
+		assert.Equal(t, "feature-gate=1m0s,relocation=30m0s", intervals.String())
+	})
+
+	for _, value := range []string{"relocation", "unknown=1m", "relocation=soon", "relocation=0s"} {
+		t.Run("rejects "+value, func(t *testing.T) {
+			intervals := RequeueIntervals{}
+			assert.Error(t, intervals.Set(value))
+		})
+	}
+}
+
+func TestGetFeatures(t *testing.T) {
+	fleet := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: FeatureGatesConfigMapName, Namespace: config.OperatorNamespace},
+		Data:       map[string]string{cTypes.Features: "ari, -split-certificates"},
+	}
+
+	tests := []struct {
+		name        string
+		objects     []runtime.Object
+		annotations map[string]string
+		expected    Features
+	}{
+		{
+			name:     "defaults",
+			expected: Features{FeaturePropagationCheck: true, FeatureSplitCertificates: true},
+		},
+		{
+			name:     "fleet features",
+			objects:  []runtime.Object{fleet},
+			expected: Features{FeaturePropagationCheck: true, FeatureSplitCertificates: false, "ari": true},
+		},
+		{
+			name:        "cluster features override the fleet",
+			objects:     []runtime.Object{fleet},
+			annotations: map[string]string{FeaturesAnnotation: "Split-Certificates,-ari,,-propagation-check"},
+			expected:    Features{FeaturePropagationCheck: false, FeatureSplitCertificates: true, "ari": false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(test.objects...).Build()
+			features := GetFeatures(kubeClient, test.annotations)
+			assert.Equal(t, test.expected, features)
+
+			ctx := FeaturesIntoContext(context.TODO(), features)
+			for feature, enabled := range test.expected {
+				assert.Equal(t, enabled, FeaturesFromContext(ctx).Enabled(feature))
+			}
+		})
+	}
+
+	// parsing never changes the defaults
+	assert.Equal(t, Features{FeaturePropagationCheck: true, FeatureSplitCertificates: true}, FeaturesFromContext(context.TODO()))
+	assert.False(t, FeaturesFromContext(context.TODO()).Enabled("ari"))
+}
@@ -18,9 +18,11 @@ package main
 
 import (
 	"context"
+	"embed"
 	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"runtime"
 	"strings"
@@ -36,11 +38,15 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -56,31 +62,52 @@ import (
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	operatorconfig "github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/acmeaccount"
+	"github.com/openshift/certman-operator/controllers/buildinfo"
+	"github.com/openshift/certman-operator/controllers/canary"
 	"github.com/openshift/certman-operator/controllers/certificaterequest"
+	"github.com/openshift/certman-operator/controllers/certificaterotation"
+	"github.com/openshift/certman-operator/controllers/certmanoperatorconfig"
 	"github.com/openshift/certman-operator/controllers/clusterdeployment"
+	"github.com/openshift/certman-operator/controllers/clusterpush"
+	"github.com/openshift/certman-operator/controllers/managedlabel"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/chaos"
 	cClient "github.com/openshift/certman-operator/pkg/clients"
+	awsclient "github.com/openshift/certman-operator/pkg/clients/aws"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/crds"
+	"github.com/openshift/certman-operator/pkg/exporters"
 	"github.com/openshift/certman-operator/pkg/k8sutil"
 	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/migrations"
+	"github.com/openshift/certman-operator/pkg/rbaccheck"
+	"github.com/openshift/certman-operator/pkg/sharding"
 	"github.com/openshift/certman-operator/pkg/version"
 	//+kubebuilder:scaffold:imports
 )
 
 var (
-	hours       int = 4
-	metricsPath     = "/metrics"
-	metricsPort     = "8080"
-	scheme          = apiruntime.NewScheme()
-	setupLog        = ctrl.Log.WithName("setup")
+	metricsPath = "/metrics"
+	metricsPort = "8080"
+	scheme      = apiruntime.NewScheme()
+	setupLog    = ctrl.Log.WithName("setup")
 )
 
 var log = logf.Log.WithName("cmd")
 
+// crdManifests are the CustomResourceDefinitions applied at startup with --install-crds
+//
+//go:embed deploy/crds/*.yaml
+var crdManifests embed.FS
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(certmanv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(routev1.Install(scheme))
 	utilruntime.Must(hivev1.AddToScheme(scheme))
 	utilruntime.Must(aaov1alpha1.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -88,17 +115,66 @@ func printVersion() {
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
 	log.Info(fmt.Sprintf("Version of operator-sdk: %v", version.SDKVersion))
+	log.Info(fmt.Sprintf("Operator Version: %s (%s)", version.Version, version.Commit()))
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == debugChallengeCommand {
+		os.Exit(debugChallenge(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == accountInventoryCommand {
+		os.Exit(accountInventory(os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var installCRDs bool
+	var shardByNamespace bool
+	var issuedCertificatesRefreshInterval time.Duration
+	var stalledThreshold time.Duration
+	var deleteOrphanedSecrets bool
+	var pushToPausedClusters bool
+	var reconcileWorkers int
+	var issuanceWorkers int
+	var enableWebhooks bool
+	var observationMode bool
+	requeueIntervals := utils.RequeueIntervals{}
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":"+metricsPort, "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&shardByNamespace, "shard-by-namespace", false,
+		"Run the CertificateRequest controller on every replica, each owning a share of the namespaces. "+
+			"The other controllers keep running on the leader only, through leader election.")
+	flag.BoolVar(&installCRDs, "install-crds", false,
+		"Create or update the CustomResourceDefinitions of the operator at startup. "+
+			"Requires permission to get, create and update customresourcedefinitions, as granted by deploy-extras/10_install_crds_role.yaml.")
+	flag.DurationVar(&issuedCertificatesRefreshInterval, "issued-certificates-refresh-interval", 5*time.Minute,
+		"How often the issued certificate metrics are refreshed so certificates age out of their day and week windows.")
+	flag.DurationVar(&stalledThreshold, "stalled-certificate-request-threshold", certificaterequest.DefaultStalledThreshold,
+		"How long a CertificateRequest may exist without ever being issued a certificate before it is reported as stalled.")
+	flag.BoolVar(&deleteOrphanedSecrets, "delete-orphaned-certificate-secrets", false,
+		"Delete the certificate secrets whose CertificateRequest no longer exists. "+
+			"Without it they are only reported.")
+	flag.BoolVar(&pushToPausedClusters, "push-certificates-to-paused-clusters", false,
+		"Push the certificates of the ClusterDeployments whose SyncSets are paused directly to their cluster "+
+			"with its admin kubeconfig.")
+	flag.IntVar(&reconcileWorkers, "reconcile-workers", certificaterequest.DefaultReconcileWorkers,
+		"How many CertificateRequests are reconciled at a time.")
+	flag.IntVar(&issuanceWorkers, "issuance-workers", certificaterequest.DefaultIssuanceWorkers,
+		"How many CertificateRequests have their certificates issued or revoked at a time, apart from the reconciles. "+
+			"0 issues and revokes certificates within the reconciles.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the validating webhook of CertificateRequests. "+
+			"Requires a serving certificate in the certificate directory of the webhook server.")
+	flag.BoolVar(&observationMode, "observation-mode", false,
+		"Report the CertificateRequests the ClusterDeployment controller would create, update or delete "+
+			"without changing them, such as on a newly onboarded Hive shard.")
+	flag.Var(&requeueIntervals, "requeue-intervals",
+		"How long objects waiting on something outside of the operator wait before they are reconciled again, "+
+			"as a comma separated list of state=duration such as relocation=30m,feature-gate=1m.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -117,10 +193,18 @@ func main() {
 
 	printVersion()
 
-	namespace, err := k8sutil.GetWatchNamespace()
-	if err != nil {
-		log.Error(err, "Failed to get watch namespace")
-		os.Exit(1)
+	namespace := k8sutil.GetWatchNamespace()
+	operatorconfig.OperatorNamespace = k8sutil.ResolveOperatorNamespace(operatorconfig.OperatorNamespace)
+	log.Info(fmt.Sprintf("Operator namespace: %s", operatorconfig.OperatorNamespace))
+
+	fedramp := cTypes.FedrampFromEnv()
+	log.Info(fmt.Sprintf("running in FedRAMP environment: %t", fedramp.Enabled))
+	clientBuilder := cClient.Builder{Fedramp: fedramp}
+
+	if enabled, err := chaos.Enabled(); err != nil {
+		log.Error(err, "chaos mode is disabled")
+	} else if enabled {
+		log.Info(fmt.Sprintf("CHAOS MODE: faults are injected into the ACME and DNS calls: %s", chaos.Describe()))
 	}
 
 	// Get a config to talk to the apiserver
@@ -131,19 +215,34 @@ func main() {
 	}
 
 	ctx := context.TODO()
-	// Ensure lock for leader election
+
+	// Install the CRDs before any controller watches them
+	if installCRDs {
+		if err := installCustomResourceDefinitions(ctx, cfg); err != nil {
+			setupLog.Error(err, "failed to install CustomResourceDefinitions")
+			os.Exit(1)
+		}
+	}
+
+	// Ensure lock for leader election. Sharded replicas all run, and elect the leader running the
+	// other controllers with the leader election of the manager instead.
 	_, err = k8sutil.GetOperatorNamespace()
-	if err == nil {
+	runningLocally := err == k8sutil.ErrRunLocal || err == k8sutil.ErrNoNamespace
+	if err != nil && !runningLocally {
+		setupLog.Error(err, "Failed to get operator namespace")
+		os.Exit(1)
+	}
+	if shardByNamespace {
+		setupLog.Info("Sharding CertificateRequests by namespace; skipping the leader-for-life lock.")
+		enableLeaderElection = true
+	} else if runningLocally {
+		setupLog.Info("Skipping leader election; not running in a cluster.")
+	} else {
 		err = leader.Become(ctx, "certman-operator-lock")
 		if err != nil {
 			setupLog.Error(err, "failed to create leader lock")
 			os.Exit(1)
 		}
-	} else if err == k8sutil.ErrRunLocal || err == k8sutil.ErrNoNamespace {
-		setupLog.Info("Skipping leader election; not running in a cluster.")
-	} else {
-		setupLog.Error(err, "Failed to get operator namespace")
-		os.Exit(1)
 	}
 
 	// Set default manager options
@@ -160,14 +259,17 @@ func main() {
 	// cacheOptions := cache.Options{
 	// 	Scheme: options.Scheme,
 	// }
-	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
+	// Add support for a namespace or MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
 	// Note that this is not intended to be used for excluding namespaces, this is better done via a Predicate
 	// Also note that you may face performance issues when using this with a high number of namespaces.
 	// More Info:  https://sdk.operatorframework.io/docs/building-operators/golang/operator-scope/#watching-resources-in-a-set-of-namespaces
-	if strings.Contains(namespace, ",") {
-		ccMap := map[string]cache.Config{}
+	// The operator namespace is always watched, as it holds the configuration and accounts of the operator.
+	if namespace != "" {
+		ccMap := map[string]cache.Config{operatorconfig.OperatorNamespace: {}}
 		for _, ns := range strings.Split(namespace, ",") {
-			ccMap[ns] = cache.Config{}
+			if ns = strings.TrimSpace(ns); ns != "" {
+				ccMap[ns] = cache.Config{}
+			}
 		}
 		options.Cache.DefaultNamespaces = ccMap
 	}
@@ -178,11 +280,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Share the CertificateRequests with the other replicas
+	var shard *sharding.Shard
+	if shardByNamespace {
+		shard = sharding.New(mgr.GetClient())
+		if err := mgr.Add(shard); err != nil {
+			setupLog.Error(err, "unable to add shard")
+			os.Exit(1)
+		}
+	}
+
 	// Add CertificateRequest controller to the manager
 	if err = (&certificaterequest.CertificateRequestReconciler{
-		Client:        mgr.GetClient(),
-		Scheme:        mgr.GetScheme(),
-		ClientBuilder: cClient.NewClient,
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		ClientBuilder:    clientBuilder.NewClient,
+		Recorder:         mgr.GetEventRecorderFor("certificaterequest-controller"),
+		Shard:            shard,
+		Fedramp:          fedramp,
+		ReconcileWorkers: reconcileWorkers,
+		IssuanceWorkers:  issuanceWorkers,
+		ExporterBuilder:  exporters.NewExporter,
+		RequeueIntervals: requeueIntervals,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CertificateRequest")
 		os.Exit(1)
@@ -190,15 +309,121 @@ func main() {
 
 	// Add ClusterDeployment controller to the manager
 	if err = (&clusterdeployment.ClusterDeploymentReconciler{
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		ClientBuilder:    clientBuilder.NewClient,
+		Recorder:         mgr.GetEventRecorderFor("clusterdeployment-controller"),
+		RequeueIntervals: requeueIntervals,
+		ObservationMode:  observationMode,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterDeployment")
+		os.Exit(1)
+	}
+
+	// Add CertmanOperatorConfig controller to the manager
+	if err = (&certmanoperatorconfig.CertmanOperatorConfigReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ClusterDeployment")
+		setupLog.Error(err, "unable to create controller", "controller", "CertmanOperatorConfig")
+		os.Exit(1)
+	}
+
+	// Add CertificateRotationRequest controller to the manager
+	if err = (&certificaterotation.CertificateRotationReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("certificaterotation-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CertificateRotationRequest")
 		os.Exit(1)
 	}
 
+	// Add managed label controller to the manager
+	if err = (&managedlabel.ManagedLabelReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ManagedLabel")
+		os.Exit(1)
+	}
+
+	// Reject CertificateRequests whose platform doesn't set exactly one provider
+	if enableWebhooks {
+		if err = (&certmanv1alpha1.CertificateRequest{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CertificateRequest")
+			os.Exit(1)
+		}
+	}
+
 	//+kubebuilder:scaffold:builder
 
+	// Apply pending data migrations once the caches are running
+	if err := mgr.Add(&migrations.Runner{
+		Client:     mgr.GetClient(),
+		Migrations: migrations.Migrations,
+	}); err != nil {
+		setupLog.Error(err, "unable to add migration runner")
+		os.Exit(1)
+	}
+
+	// Maintain the renewal canary of the operator configuration
+	if err := mgr.Add(&canary.Canary{Client: mgr.GetClient()}); err != nil {
+		setupLog.Error(err, "unable to add canary")
+		os.Exit(1)
+	}
+
+	// Keep the contact of the ACME account in line with the default notification email
+	if err := mgr.Add(&acmeaccount.Syncer{Client: mgr.GetClient()}); err != nil {
+		setupLog.Error(err, "unable to add ACME account syncer")
+		os.Exit(1)
+	}
+
+	// Report the build and configuration of the operator once elected
+	if err := mgr.Add(&buildinfo.Reporter{
+		Client:  mgr.GetClient(),
+		Fedramp: fedramp.Enabled,
+	}); err != nil {
+		setupLog.Error(err, "unable to add build info reporter")
+		os.Exit(1)
+	}
+
+	// Record the certificates already issued once elected, then keep the issued certificate metrics current
+	if err := mgr.Add(&localmetrics.IssuedCertificatesRecorder{
+		Client:          mgr.GetClient(),
+		RefreshInterval: issuedCertificatesRefreshInterval,
+	}); err != nil {
+		setupLog.Error(err, "unable to add issued certificates recorder")
+		os.Exit(1)
+	}
+
+	// Report the CertificateRequests that never got a certificate
+	if err := mgr.Add(&certificaterequest.StalledWatchdog{
+		Client:    mgr.GetClient(),
+		Recorder:  mgr.GetEventRecorderFor("certificaterequest-controller"),
+		Threshold: stalledThreshold,
+	}); err != nil {
+		setupLog.Error(err, "unable to add stalled CertificateRequest watchdog")
+		os.Exit(1)
+	}
+
+	// Report, and when enabled delete, the certificate secrets left behind by deleted CertificateRequests
+	if err := mgr.Add(&certificaterequest.OrphanedSecretCollector{
+		Client: mgr.GetClient(),
+		DryRun: !deleteOrphanedSecrets,
+	}); err != nil {
+		setupLog.Error(err, "unable to add orphaned certificate secret collector")
+		os.Exit(1)
+	}
+
+	// Push the certificates of the clusters Hive doesn't apply the SyncSets of
+	if pushToPausedClusters {
+		if err := mgr.Add(&clusterpush.Pusher{Client: mgr.GetClient()}); err != nil {
+			setupLog.Error(err, "unable to add cluster certificate pusher")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -208,6 +433,30 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Hold readiness while the service account lacks permissions every reconcile needs
+	permissionCheck := &rbaccheck.Check{Client: mgr.GetClient()}
+	if err := mgr.Add(permissionCheck); err != nil {
+		setupLog.Error(err, "unable to add RBAC check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("rbac", permissionCheck.Ready); err != nil {
+		setupLog.Error(err, "unable to set up RBAC ready check")
+		os.Exit(1)
+	}
+
+	// In FedRAMP, hold readiness until the hosted zone is known to be usable
+	if fedramp.Enabled {
+		zoneCheck := &awsclient.FedrampZoneCheck{Client: mgr.GetClient(), Fedramp: fedramp}
+		if err := mgr.Add(zoneCheck); err != nil {
+			setupLog.Error(err, "unable to add FedRAMP hosted zone check")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("fedramp-hosted-zone", zoneCheck.Ready); err != nil {
+			setupLog.Error(err, "unable to set up FedRAMP hosted zone ready check")
+			os.Exit(1)
+		}
+	}
+
 	// Instantiate metricsServer object configured with variables defined in
 	// localmetrics package.
 	metricsServer := metrics.NewBuilder(operatorconfig.OperatorNamespace, operatorconfig.OperatorName).
@@ -232,8 +481,6 @@ func main() {
 		log.Info("Successfully configured Metrics")
 	}
 
-	// Invoke UpdateMetrics at a frequency defined as hours within a goroutine.
-	go localmetrics.UpdateMetrics(hours)
 	log.Info("Starting the Cmd.")
 
 	setupLog.Info("starting manager")
@@ -242,3 +489,104 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// installCustomResourceDefinitions applies the embedded CRD manifests with a client that doesn't
+// depend on the manager's cache.
+func installCustomResourceDefinitions(ctx context.Context, cfg *rest.Config) error {
+	kubeClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	manifests, err := fs.Sub(crdManifests, "deploy/crds")
+	if err != nil {
+		return err
+	}
+
+	return crds.Apply(ctx, kubeClient, manifests)
+}
+
+// debugChallengeCommand runs the DNS challenge checks of a CertificateRequest instead of the operator.
+// It is meant to be run in the operator pod with oc exec, so the checks use the operator's credentials
+// and network, and access is granted by the RBAC on pods/exec.
+const debugChallengeCommand = "debug-challenge"
+
+// debugChallenge reports the DNS challenge checks of the CertificateRequest named by args, given as
+// namespace/name, and returns the exit code of the command.
+func debugChallenge(args []string) int {
+	namespace, name, found := "", "", false
+	if len(args) == 1 {
+		namespace, name, found = strings.Cut(args[0], "/")
+	}
+	if !found || namespace == "" || name == "" {
+		fmt.Fprintf(os.Stderr, "usage: %s %s <namespace>/<certificaterequest>\n", os.Args[0], debugChallengeCommand)
+		return 2
+	}
+
+	kubeClient, err := newCommandClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fedramp := cTypes.FedrampFromEnv()
+	r := &certificaterequest.CertificateRequestReconciler{
+		Client:        kubeClient,
+		Scheme:        scheme,
+		ClientBuilder: cClient.Builder{Fedramp: fedramp}.NewClient,
+		Fedramp:       fedramp,
+	}
+	ok, err := r.DebugChallenges(logf.Log.WithName(debugChallengeCommand), os.Stdout, types.NamespacedName{Namespace: namespace, Name: name})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !ok {
+		return 1
+	}
+	return 0
+}
+
+// accountInventoryCommand prints the certificates issued with each ACME account of the operator in
+// the last day and week, as recorded in the issuance inventory, instead of running the operator.
+const accountInventoryCommand = "account-inventory"
+
+// accountInventory prints the issuance inventory of the ACME accounts and returns the exit code of
+// the command.
+func accountInventory(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "usage: %s %s\n", os.Args[0], accountInventoryCommand)
+		return 2
+	}
+
+	kubeClient, err := newCommandClient()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	inventory := &acmeaccount.Inventory{Client: kubeClient}
+	accounts, err := inventory.Accounts(context.TODO())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := acmeaccount.WriteInventory(os.Stdout, accounts, time.Now(), utils.GetACMEAccountWeeklyIssuanceCeiling(kubeClient)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// newCommandClient returns a client for the commands run instead of the operator. Their output
+// goes to stdout, the logs of the clients are kept apart on stderr.
+func newCommandClient() (client.Client, error) {
+	logf.SetLogger(zap.New(zap.WriteTo(os.Stderr)))
+	operatorconfig.OperatorNamespace = k8sutil.ResolveOperatorNamespace(operatorconfig.OperatorNamespace)
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
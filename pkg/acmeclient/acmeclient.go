@@ -16,14 +16,14 @@ import (
 type AcmeClientInterface interface {
 	//AccountKeyChange(acme.Account, crypto.Signer) (acme.Account, error)
 	//DeactivateAccount(acme.Account) (acme.Account, error)
-	//DeactivateAuthorization(acme.Account, string) (acme.Authorization, error)
+	DeactivateAuthorization(acme.Account, string) (acme.Authorization, error)
 	//Directory() acme.Directory
 	FetchAuthorization(acme.Account, string) (acme.Authorization, error)
 	FetchCertificates(acme.Account, string) ([]*x509.Certificate, error)
 	//FetchChallenge(acme.Account, string) (acme.Challenge, error)
-	//FetchOrder(acme.Account, string) (acme.Order, error)
+	FetchOrder(acme.Account, string) (acme.Order, error)
 	FinalizeOrder(acme.Account, acme.Order, *x509.CertificateRequest) (acme.Order, error)
-	//NewAccount(crypto.Signer, bool, bool, ...string) (acme.Account, error)
+	NewAccount(crypto.Signer, bool, bool, ...string) (acme.Account, error)
 	NewOrder(acme.Account, []acme.Identifier) (acme.Order, error)
 	//NewOrderDomains(acme.Account, ...string) (acme.Order, error)
 	RevokeCertificate(acme.Account, *x509.Certificate, crypto.Signer, int) error
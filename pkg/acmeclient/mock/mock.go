@@ -14,40 +14,59 @@ type FakeAcmeClient struct {
 	Available                bool
 	NewOrderResult           acme.Order
 	FetchAuthorizationResult acme.Authorization
+	// authorizations returned by URL, instead of FetchAuthorizationResult
+	FetchAuthorizationResults map[string]acme.Authorization
+	// authorizations returned by successive calls instead of the above, the last one repeated
+	FetchAuthorizationSequence []acme.Authorization
 
+	// the account registered at the ACME server, returned by NewAccount
+	Account     acme.Account
 	Challenge   acme.Challenge
 	Contacts    []string
 	Identifiers []acme.Identifier
-
-	FetchAuthorizationCalled bool
-	FetchCertificatesCalled  bool
-	FinalizeOrderCalled      bool
-	NewOrderCalled           bool
-	RevokeCertificateCalled  bool
-	UpdateAccountCalled      bool
-	UpdateChallengeCalled    bool
+	// the CSR the order was finalized with
+	FinalizeOrderCSR *x509.CertificateRequest
+	// the URLs of the authorizations deactivated
+	DeactivatedAuthorizations []string
+
+	DeactivateAuthorizationCalled bool
+	FetchAuthorizationCalled      bool
+	FetchCertificatesCalled       bool
+	FetchOrderCalled              bool
+	FinalizeOrderCalled           bool
+	NewAccountCalled              bool
+	NewOrderCalled                bool
+	RevokeCertificateCalled       bool
+	UpdateAccountCalled           bool
+	UpdateChallengeCalled         bool
 }
 
 type FakeAcmeClientOptions struct {
-	Available                bool
-	NewOrderResult           acme.Order
-	FetchAuthorizationResult acme.Authorization
-	UpdateAccountCalled      bool
-	NewOrderCalled           bool
-	FetchAuthorizationCalled bool
-	UpdateChallengeCalled    bool
-	FinalizeOrderCalled      bool
-	FetchCertificatesCalled  bool
-	RevokeCertificateCalled  bool
+	Available                  bool
+	NewOrderResult             acme.Order
+	FetchAuthorizationResult   acme.Authorization
+	FetchAuthorizationResults  map[string]acme.Authorization
+	FetchAuthorizationSequence []acme.Authorization
+	UpdateAccountCalled        bool
+	NewOrderCalled             bool
+	FetchAuthorizationCalled   bool
+	UpdateChallengeCalled      bool
+	FinalizeOrderCalled        bool
+	FetchCertificatesCalled    bool
+	FetchOrderCalled           bool
+	RevokeCertificateCalled    bool
 }
 
 func NewFakeAcmeClient(opts *FakeAcmeClientOptions) (fac *FakeAcmeClient) {
 	fac = &FakeAcmeClient{}
 	fac.NewOrderResult = opts.NewOrderResult
 	fac.FetchAuthorizationResult = opts.FetchAuthorizationResult
+	fac.FetchAuthorizationResults = opts.FetchAuthorizationResults
+	fac.FetchAuthorizationSequence = opts.FetchAuthorizationSequence
 	fac.Available = opts.Available
 	fac.FetchAuthorizationCalled = opts.FetchAuthorizationCalled
 	fac.FetchCertificatesCalled = opts.FetchCertificatesCalled
+	fac.FetchOrderCalled = opts.FetchOrderCalled
 	fac.FinalizeOrderCalled = opts.FinalizeOrderCalled
 	fac.NewOrderCalled = opts.NewOrderCalled
 	fac.RevokeCertificateCalled = opts.RevokeCertificateCalled
@@ -64,6 +83,39 @@ func (fac *FakeAcmeClient) UpdateAccount(account acme.Account, tosAgreed bool, c
 
 	if !fac.Available {
 		err = errors.New("acme: error code 0 \"urn:acme:error:serverInternal\": The service is down for maintenance or had an internal error. Check https://letsencrypt.status.io/ for more details")
+	} else {
+		fac.Account.Contact = contacts
+		rAccount = account
+		rAccount.Contact = contacts
+	}
+
+	return
+}
+
+// NewAccount returns the registered Account, as when looking up an existing account.
+func (fac *FakeAcmeClient) NewAccount(privateKey crypto.Signer, onlyReturnExisting, termsOfServiceAgreed bool, contacts ...string) (account acme.Account, err error) {
+	fac.NewAccountCalled = true
+
+	if !fac.Available {
+		err = errors.New("acme: error code 0 \"urn:acme:error:serverInternal\": The service is down for maintenance or had an internal error. Check https://letsencrypt.status.io/ for more details")
+	} else {
+		account = fac.Account
+	}
+
+	return
+}
+
+// DeactivateAuthorization records url as deactivated and returns its authorization with the
+// deactivated status.
+func (fac *FakeAcmeClient) DeactivateAuthorization(a acme.Account, url string) (aAuth acme.Authorization, err error) {
+	fac.DeactivateAuthorizationCalled = true
+
+	if !fac.Available {
+		err = errors.New("acme: error code 0 \"urn:acme:error:serverInternal\": The service is down for maintenance or had an internal error. Check https://letsencrypt.status.io/ for more details")
+	} else {
+		fac.DeactivatedAuthorizations = append(fac.DeactivatedAuthorizations, url)
+		aAuth = fac.FetchAuthorizationResults[url]
+		aAuth.Status = "deactivated"
 	}
 
 	return
@@ -74,6 +126,13 @@ func (fac *FakeAcmeClient) FetchAuthorization(a acme.Account, url string) (aAuth
 
 	if !fac.Available {
 		err = errors.New("acme: error code 0 \"urn:acme:error:serverInternal\": The service is down for maintenance or had an internal error. Check https://letsencrypt.status.io/ for more details")
+	} else if len(fac.FetchAuthorizationSequence) > 0 {
+		aAuth = fac.FetchAuthorizationSequence[0]
+		if len(fac.FetchAuthorizationSequence) > 1 {
+			fac.FetchAuthorizationSequence = fac.FetchAuthorizationSequence[1:]
+		}
+	} else if auth, ok := fac.FetchAuthorizationResults[url]; ok {
+		aAuth = auth
 	} else {
 		aAuth = fac.FetchAuthorizationResult
 	}
@@ -118,8 +177,21 @@ VoZplnP9BdVECzSa
 	return
 }
 
-func (fac *FakeAcmeClient) FinalizeOrder(acme.Account, acme.Order, *x509.CertificateRequest) (order acme.Order, err error) {
+func (fac *FakeAcmeClient) FetchOrder(a acme.Account, url string) (order acme.Order, err error) {
+	fac.FetchOrderCalled = true
+
+	if !fac.Available {
+		err = errors.New("acme: error code 0 \"urn:acme:error:serverInternal\": The service is down for maintenance or had an internal error. Check https://letsencrypt.status.io/ for more details")
+	} else {
+		order = fac.NewOrderResult
+	}
+
+	return
+}
+
+func (fac *FakeAcmeClient) FinalizeOrder(a acme.Account, o acme.Order, csr *x509.CertificateRequest) (order acme.Order, err error) {
 	fac.FinalizeOrderCalled = true
+	fac.FinalizeOrderCSR = csr
 
 	if !fac.Available {
 		err = errors.New("acme: error code 0 \"urn:acme:error:serverInternal\": The service is down for maintenance or had an internal error. Check https://letsencrypt.status.io/ for more details")
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"crypto"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/eggsampler/acme"
+
+	"github.com/openshift/certman-operator/pkg/acmeclient"
+)
+
+// NewACMEClient returns an ACME client failing the calls as configured by config, and passing the
+// others to next. The faults are the problem documents of the ACME server for rate limited
+// requests and internal errors.
+func NewACMEClient(next acmeclient.AcmeClientInterface, config Config) acmeclient.AcmeClientInterface {
+	return &acmeClient{next: next, injector: newInjector(TargetACME, config)}
+}
+
+type acmeClient struct {
+	next     acmeclient.AcmeClientInterface
+	injector *injector
+}
+
+// inject returns the problem of the fault injected into a call, if any.
+func (c *acmeClient) inject() error {
+	switch c.injector.inject() {
+	case faultThrottle:
+		return acme.Problem{
+			Type:   "urn:ietf:params:acme:error:rateLimited",
+			Detail: "rate limited by certman-operator chaos mode",
+			Status: http.StatusTooManyRequests,
+		}
+	case faultServerError:
+		return acme.Problem{
+			Type:   "urn:ietf:params:acme:error:serverInternal",
+			Detail: "internal error injected by certman-operator chaos mode",
+			Status: http.StatusInternalServerError,
+		}
+	}
+	return nil
+}
+
+func (c *acmeClient) DeactivateAuthorization(account acme.Account, url string) (acme.Authorization, error) {
+	if err := c.inject(); err != nil {
+		return acme.Authorization{}, err
+	}
+	return c.next.DeactivateAuthorization(account, url)
+}
+
+func (c *acmeClient) FetchAuthorization(account acme.Account, url string) (acme.Authorization, error) {
+	if err := c.inject(); err != nil {
+		return acme.Authorization{}, err
+	}
+	return c.next.FetchAuthorization(account, url)
+}
+
+func (c *acmeClient) FetchCertificates(account acme.Account, url string) ([]*x509.Certificate, error) {
+	if err := c.inject(); err != nil {
+		return nil, err
+	}
+	return c.next.FetchCertificates(account, url)
+}
+
+func (c *acmeClient) FetchOrder(account acme.Account, url string) (acme.Order, error) {
+	if err := c.inject(); err != nil {
+		return acme.Order{}, err
+	}
+	return c.next.FetchOrder(account, url)
+}
+
+func (c *acmeClient) FinalizeOrder(account acme.Account, order acme.Order, csr *x509.CertificateRequest) (acme.Order, error) {
+	if err := c.inject(); err != nil {
+		return acme.Order{}, err
+	}
+	return c.next.FinalizeOrder(account, order, csr)
+}
+
+func (c *acmeClient) NewAccount(privateKey crypto.Signer, onlyReturnExisting, termsOfServiceAgreed bool, contact ...string) (acme.Account, error) {
+	if err := c.inject(); err != nil {
+		return acme.Account{}, err
+	}
+	return c.next.NewAccount(privateKey, onlyReturnExisting, termsOfServiceAgreed, contact...)
+}
+
+func (c *acmeClient) NewOrder(account acme.Account, identifiers []acme.Identifier) (acme.Order, error) {
+	if err := c.inject(); err != nil {
+		return acme.Order{}, err
+	}
+	return c.next.NewOrder(account, identifiers)
+}
+
+func (c *acmeClient) RevokeCertificate(account acme.Account, certificate *x509.Certificate, key crypto.Signer, reason int) error {
+	if err := c.inject(); err != nil {
+		return err
+	}
+	return c.next.RevokeCertificate(account, certificate, key, reason)
+}
+
+func (c *acmeClient) UpdateAccount(account acme.Account, termsOfServiceAgreed bool, contact ...string) (acme.Account, error) {
+	if err := c.inject(); err != nil {
+		return acme.Account{}, err
+	}
+	return c.next.UpdateAccount(account, termsOfServiceAgreed, contact...)
+}
+
+func (c *acmeClient) UpdateChallenge(account acme.Account, challenge acme.Challenge) (acme.Challenge, error) {
+	if err := c.inject(); err != nil {
+		return acme.Challenge{}, err
+	}
+	return c.next.UpdateChallenge(account, challenge)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chaos injects latency, throttling and server errors into the calls of the ACME and DNS
+// clients, to check how the controllers back off and recover in long running test environments.
+// Chaos mode is only compiled into the builds with the chaos tag, where it is configured by the
+// CERTMAN_CHAOS environment variable; the other builds pass every call through untouched.
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// EnvVar is the environment variable holding the Config of chaos mode, such as
+// "latency=2s,throttle=0.05,error=0.05,seed=1"
+const EnvVar = "CERTMAN_CHAOS"
+
+// The targets the faults are injected into besides the DNS providers
+const (
+	TargetACME = "acme"
+)
+
+// fault is a failure injected into a call.
+type fault string
+
+// The faults reported in the metrics
+const (
+	faultNone        fault = ""
+	faultLatency     fault = "latency"
+	faultThrottle    fault = "throttle"
+	faultServerError fault = "server_error"
+)
+
+// Config is the configuration of chaos mode.
+type Config struct {
+	// MaxLatency is the maximum of the random delay added to every call, none when 0
+	MaxLatency time.Duration
+	// ThrottleRatio is the share of the calls failed as throttled
+	ThrottleRatio float64
+	// ErrorRatio is the share of the calls failed with a server error
+	ErrorRatio float64
+	// Seed seeds the random faults, the current time when 0
+	Seed int64
+}
+
+// ParseConfig parses the comma separated key=value settings of value, the keys being latency,
+// throttle, error and seed.
+func ParseConfig(value string) (Config, error) {
+	config := Config{}
+	for _, setting := range strings.Split(value, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(setting, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("%s: %q isn't a key=value setting", EnvVar, setting)
+		}
+
+		var err error
+		switch key {
+		case "latency":
+			config.MaxLatency, err = time.ParseDuration(val)
+		case "throttle":
+			config.ThrottleRatio, err = parseRatio(val)
+		case "error":
+			config.ErrorRatio, err = parseRatio(val)
+		case "seed":
+			config.Seed, err = strconv.ParseInt(val, 10, 64)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: invalid setting %q: %w", EnvVar, setting, err)
+		}
+	}
+
+	if config.ThrottleRatio+config.ErrorRatio > 1 {
+		return Config{}, fmt.Errorf("%s: the throttle and error ratios add up to more than 1", EnvVar)
+	}
+	return config, nil
+}
+
+func parseRatio(value string) (float64, error) {
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("the ratio must be between 0 and 1")
+	}
+	return ratio, nil
+}
+
+// injector draws the faults of the calls to a target.
+type injector struct {
+	target string
+	config Config
+	sleep  func(time.Duration)
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newInjector(target string, config Config) *injector {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &injector{
+		target: target,
+		config: config,
+		sleep:  time.Sleep,
+		rand:   rand.New(rand.NewSource(seed)), //#nosec - G404: the faults don't need a secure random source
+	}
+}
+
+// inject delays the call by a random latency and returns the fault it fails with, if any.
+func (i *injector) inject() fault {
+	i.mu.Lock()
+	var latency time.Duration
+	if i.config.MaxLatency > 0 {
+		latency = time.Duration(i.rand.Int63n(int64(i.config.MaxLatency)))
+	}
+	draw := i.rand.Float64()
+	i.mu.Unlock()
+
+	if latency > 0 {
+		localmetrics.IncrementChaosInjectedFaultCount(i.target, string(faultLatency))
+		i.sleep(latency)
+	}
+
+	switch {
+	case draw < i.config.ThrottleRatio:
+		localmetrics.IncrementChaosInjectedFaultCount(i.target, string(faultThrottle))
+		return faultThrottle
+	case draw < i.config.ThrottleRatio+i.config.ErrorRatio:
+		localmetrics.IncrementChaosInjectedFaultCount(i.target, string(faultServerError))
+		return faultServerError
+	}
+	return faultNone
+}
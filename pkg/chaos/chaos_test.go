@@ -0,0 +1,117 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eggsampler/acme"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/certman-operator/pkg/acmeclient/mock"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig("latency=2s, throttle=0.1,error=0.05,seed=7")
+	assert.NoError(t, err)
+	assert.Equal(t, Config{MaxLatency: 2 * time.Second, ThrottleRatio: 0.1, ErrorRatio: 0.05, Seed: 7}, config)
+
+	for _, invalid := range []string{"latency", "latency=soon", "throttle=2", "error=-0.1", "throttle=0.6,error=0.6", "unknown=1"} {
+		_, err := ParseConfig(invalid)
+		assert.Error(t, err, "expected %q to be invalid", invalid)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransport(t *testing.T) {
+	sent := 0
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	tests := []struct {
+		name     string
+		config   Config
+		expected int
+	}{
+		{name: "no fault", config: Config{Seed: 1}, expected: http.StatusOK},
+		{name: "throttle", config: Config{ThrottleRatio: 1, Seed: 1}, expected: http.StatusTooManyRequests},
+		{name: "server error", config: Config{ErrorRatio: 1, Seed: 1}, expected: http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sent = 0
+			transport := NewTransport("test-"+test.name, next, test.config)
+
+			req, _ := http.NewRequest(http.MethodPost, "https://dns.example.com", strings.NewReader("{}"))
+			resp, err := transport.RoundTrip(req)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, resp.StatusCode)
+			if test.expected == http.StatusOK {
+				assert.Equal(t, 1, sent, "the request should be sent")
+			} else {
+				assert.Equal(t, 0, sent, "the request should fail before being sent")
+				assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestLatency(t *testing.T) {
+	i := newInjector("test-latency", Config{MaxLatency: time.Second, Seed: 1})
+	var slept time.Duration
+	i.sleep = func(d time.Duration) { slept += d }
+
+	for n := 0; n < 10; n++ {
+		assert.Equal(t, faultNone, i.inject())
+	}
+	assert.Greater(t, slept, time.Duration(0))
+	assert.Less(t, slept, 10*time.Second)
+}
+
+func TestACMEClient(t *testing.T) {
+	next := mock.NewFakeAcmeClient(&mock.FakeAcmeClientOptions{Available: true})
+
+	throttled := NewACMEClient(next, Config{ThrottleRatio: 1, Seed: 1})
+	_, err := throttled.NewOrder(acme.Account{}, nil)
+	problem := acme.Problem{}
+	assert.True(t, errors.As(err, &problem), "expected an ACME problem, got %v", err)
+	assert.Equal(t, "urn:ietf:params:acme:error:rateLimited", problem.Type)
+	assert.False(t, next.NewOrderCalled, "the call should fail before reaching the ACME server")
+
+	failing := NewACMEClient(next, Config{ErrorRatio: 1, Seed: 1})
+	_, err = failing.FetchOrder(acme.Account{}, "https://acme.example.com/order/1")
+	assert.True(t, errors.As(err, &problem), "expected an ACME problem, got %v", err)
+	assert.Equal(t, http.StatusInternalServerError, problem.Status)
+
+	passing := NewACMEClient(next, Config{Seed: 1})
+	_, err = passing.NewOrder(acme.Account{}, nil)
+	assert.NoError(t, err)
+	assert.True(t, next.NewOrderCalled)
+}
@@ -0,0 +1,46 @@
+//go:build !chaos
+// +build !chaos
+
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"net/http"
+
+	"github.com/openshift/certman-operator/pkg/acmeclient"
+)
+
+// Enabled returns false, chaos mode isn't compiled into this build.
+func Enabled() (bool, error) {
+	return false, nil
+}
+
+// Describe returns an empty string.
+func Describe() string {
+	return ""
+}
+
+// Transport returns next.
+func Transport(provider string, next http.RoundTripper) http.RoundTripper {
+	return next
+}
+
+// ACMEClient returns next.
+func ACMEClient(next acmeclient.AcmeClientInterface) acmeclient.AcmeClientInterface {
+	return next
+}
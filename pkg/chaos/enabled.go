@@ -0,0 +1,74 @@
+//go:build chaos
+// +build chaos
+
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/openshift/certman-operator/pkg/acmeclient"
+)
+
+// config is the configuration of chaos mode read from EnvVar, nil when it is unset or invalid
+var config, configErr = loadConfig()
+
+func loadConfig() (*Config, error) {
+	value, ok := os.LookupEnv(EnvVar)
+	if !ok || value == "" {
+		return nil, nil
+	}
+	parsed, err := ParseConfig(value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// Enabled returns true when faults are injected into the calls of the clients, and the error of
+// an invalid EnvVar, which disables chaos mode.
+func Enabled() (bool, error) {
+	return config != nil, configErr
+}
+
+// Describe returns the faults injected in chaos mode.
+func Describe() string {
+	if config == nil {
+		return ""
+	}
+	return fmt.Sprintf("latency up to %s, %.0f%% of the calls throttled and %.0f%% failed",
+		config.MaxLatency, config.ThrottleRatio*100, config.ErrorRatio*100)
+}
+
+// Transport returns next, failing the requests to the DNS API of provider in chaos mode.
+func Transport(provider string, next http.RoundTripper) http.RoundTripper {
+	if config == nil {
+		return next
+	}
+	return NewTransport(provider, next, *config)
+}
+
+// ACMEClient returns next, failing its calls in chaos mode.
+func ACMEClient(next acmeclient.AcmeClientInterface) acmeclient.AcmeClientInterface {
+	if config == nil {
+		return next
+	}
+	return NewACMEClient(next, *config)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// injectedBody is the body of the responses of the injected faults
+const injectedBody = `{"error":{"code":"ChaosInjected","message":"injected by certman-operator chaos mode"}}`
+
+// NewTransport returns a RoundTripper failing the requests to the DNS API of provider as
+// configured by config, and sending the others through next. The faults are HTTP responses, so
+// that the SDKs retry them and the throttles are recorded like real ones.
+func NewTransport(provider string, next http.RoundTripper, config Config) http.RoundTripper {
+	return &transport{next: next, injector: newInjector(provider, config)}
+}
+
+type transport struct {
+	next     http.RoundTripper
+	injector *injector
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	switch t.injector.inject() {
+	case faultThrottle:
+		status = http.StatusTooManyRequests
+	case faultServerError:
+		status = http.StatusServiceUnavailable
+	default:
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper always closes the body of the request
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}, "Retry-After": []string{"1"}},
+		Body:          io.NopCloser(strings.NewReader(injectedBody)),
+		ContentLength: int64(len(injectedBody)),
+		Request:       req,
+	}, nil
+}
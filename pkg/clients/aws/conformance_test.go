@@ -0,0 +1,46 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+
+	"github.com/openshift/certman-operator/pkg/clients/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Provider{
+		NewClient: func(t *testing.T, backend *conformance.Backend) conformance.Client {
+			server := conformance.NewRoute53Server(t, backend)
+			sess, err := session.NewSession(&aws.Config{
+				Endpoint:    aws.String(server.URL),
+				Region:      aws.String("us-east-1"),
+				Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+				MaxRetries:  aws.Int(0),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return &awsClient{client: route53.New(sess)}
+		},
+	})
+}
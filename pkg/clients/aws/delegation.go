@@ -0,0 +1,219 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+// hostedZoneLookupMaxItems bounds the zones listed per name, a name has at most a few zones
+// with the same name and ListHostedZonesByName returns them first.
+const hostedZoneLookupMaxItems = "10"
+
+// GetAuthoritativeZone returns the ID of the public hosted zone authoritative for fqdn. The labels of
+// fqdn are walked from the deepest one up, looking for a zone of that name in the account of the
+// cluster and then in the accounts of the delegated zone credentials, so a subdomain delegated to
+// another account is answered in its own zone. dnsZone is returned when no zone is found.
+func (c *awsClient) GetAuthoritativeZone(reqLogger logr.Logger, fqdn string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error) {
+	// The fedramp hosted zone holds the records of every cluster
+	if c.fedramp.Enabled {
+		return dnsZone, nil
+	}
+
+	zone, err := c.findAuthoritativeZone(reqLogger, fqdn, append([]route53iface.Route53API{c.client}, c.delegateClients...))
+	if err != nil {
+		return "", err
+	}
+	if zone == nil {
+		reqLogger.Info(fmt.Sprintf("no hosted zone found for %v, using hosted zone %v", fqdn, dnsZone))
+		return dnsZone, nil
+	}
+
+	return path.Base(*zone.Id), nil
+}
+
+// GetZoneNameservers returns the nameservers of the hosted zone dnsZone, or of the public hosted zone
+// named after the base domain of cr when dnsZone is empty.
+func (c *awsClient) GetZoneNameservers(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string) ([]string, error) {
+	zoneID := dnsZone
+	if zoneID == "" {
+		zone, err := findPublicHostedZone(c.client, strings.TrimSuffix(cr.Spec.ACMEDNSDomain, ".")+".")
+		if err != nil {
+			return nil, err
+		}
+		if zone == nil {
+			return nil, fmt.Errorf("no public hosted zone named %v", cr.Spec.ACMEDNSDomain)
+		}
+		zoneID = path.Base(aws.StringValue(zone.Id))
+	}
+
+	output, err := c.zoneClient(zoneID).GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(zoneID)})
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("error getting hosted zone %v", zoneID))
+		return nil, err
+	}
+	if output.DelegationSet == nil {
+		return []string{}, nil
+	}
+
+	return aws.StringValueSlice(output.DelegationSet.NameServers), nil
+}
+
+// findAuthoritativeZone returns the deepest public hosted zone found in clients whose name is a
+// parent of fqdn, or nil when there is none. The client of the account holding the zone is
+// remembered for the later calls on the zone. Failing to search the account of the cluster is an
+// error, while failing to search a delegated account is only logged so that a broken delegation
+// doesn't stop every issuance.
+func (c *awsClient) findAuthoritativeZone(reqLogger logr.Logger, fqdn string, clients []route53iface.Route53API) (*route53.HostedZone, error) {
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+
+	// The top level domain is never a hosted zone
+	for i := 0; i < len(labels)-1; i++ {
+		name := strings.Join(labels[i:], ".") + "."
+		for _, r53 := range clients {
+			zone, err := findPublicHostedZone(r53, name)
+			if err != nil {
+				if r53 == c.client {
+					return nil, err
+				}
+				reqLogger.Error(err, fmt.Sprintf("could not search delegated account for hosted zone %v", name))
+				continue
+			}
+			if zone != nil {
+				reqLogger.Info(fmt.Sprintf("hosted zone %v is authoritative for %v", *zone.Id, fqdn))
+				c.rememberZoneClient(*zone.Id, r53)
+				return zone, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// findPublicHostedZone returns the public hosted zone of r53 named name, or nil when there is none.
+func findPublicHostedZone(r53 route53iface.Route53API, name string) (*route53.HostedZone, error) {
+	output, err := r53.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{
+		DNSName:  aws.String(name),
+		MaxItems: aws.String(hostedZoneLookupMaxItems),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Zones are sorted by name, the first one of another name ends the zones of name
+	for _, zone := range output.HostedZones {
+		if !strings.EqualFold(aws.StringValue(zone.Name), name) {
+			break
+		}
+		if zone.Config != nil && aws.BoolValue(zone.Config.PrivateZone) {
+			continue
+		}
+		return zone, nil
+	}
+
+	return nil, nil
+}
+
+// rememberZoneClient records r53 as the client of the account holding the hosted zone zoneID.
+func (c *awsClient) rememberZoneClient(zoneID string, r53 route53iface.Route53API) {
+	if c.zoneClients == nil {
+		c.zoneClients = map[string]route53iface.Route53API{}
+	}
+	c.zoneClients[path.Base(zoneID)] = r53
+}
+
+// zoneClient returns the client of the account holding the hosted zone zoneID. Zones that weren't
+// found by GetAuthoritativeZone, for example by an earlier reconcile, are looked up in the account
+// of the cluster and then in the delegated accounts. The client of the cluster's account is
+// returned when no account holds the zone, so that its error is the one reported.
+func (c *awsClient) zoneClient(zoneID string) route53iface.Route53API {
+	if r53, ok := c.zoneClients[path.Base(zoneID)]; ok {
+		return r53
+	}
+	if len(c.delegateClients) == 0 {
+		return c.client
+	}
+
+	for _, r53 := range append([]route53iface.Route53API{c.client}, c.delegateClients...) {
+		if _, err := r53.GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(zoneID)}); err == nil {
+			c.rememberZoneClient(zoneID, r53)
+			return r53
+		}
+	}
+
+	return c.client
+}
+
+// newDelegateClients returns a Route53 client for the account of each of the delegated zone
+// credentials Secrets in the operator namespace.
+func newDelegateClients(kubeClient client.Client, region string) ([]route53iface.Route53API, error) {
+	delegateClients := []route53iface.Route53API{}
+	for _, secretName := range utils.GetDelegatedZoneCredentials(kubeClient) {
+		secret := &corev1.Secret{}
+		err := kubeClient.Get(context.TODO(), types.NamespacedName{
+			Name:      secretName,
+			Namespace: config.OperatorNamespace,
+		}, secret)
+		if err != nil {
+			return nil, fmt.Errorf("could not get delegated zone credentials secret %v: %w", secretName, err)
+		}
+
+		accessKeyID, ok := secret.Data[awsCredsSecretIDKey]
+		if !ok {
+			return nil, fmt.Errorf("AWS credentials secret %v did not contain key %v",
+				secretName, awsCredsSecretIDKey)
+		}
+
+		secretAccessKey, ok := secret.Data[awsCredsSecretAccessKey]
+		if !ok {
+			return nil, fmt.Errorf("AWS credentials secret %v did not contain key %v",
+				secretName, awsCredsSecretAccessKey)
+		}
+
+		awsConfig := newAWSConfig(region)
+		awsConfig.Credentials = credentials.NewStaticCredentials(
+			strings.Trim(string(accessKeyID), "\n"),
+			strings.Trim(string(secretAccessKey), "\n"),
+			"",
+		)
+		s, err := session.NewSession(awsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		delegateClients = append(delegateClients, route53.New(s))
+	}
+
+	return delegateClients, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// fedrampZoneCheckRetryInterval is how long to wait before checking the FedRAMP hosted zone again
+// after a failed check.
+const fedrampZoneCheckRetryInterval = time.Minute
+
+var errFedrampZoneCheckPending = errors.New("FedRAMP hosted zone has not been checked yet")
+
+var _ manager.Runnable = &FedrampZoneCheck{}
+var _ manager.LeaderElectionRunnable = &FedrampZoneCheck{}
+
+// FedrampZoneCheck verifies at startup that the FedRAMP hosted zone can be used to
+// answer ACME challenges, so a misconfigured environment is caught at deploy time instead of at
+// the first issuance. Until the check succeeds, Ready fails and the
+// certman_operator_fedramp_zone_check_success metric is 0.
+type FedrampZoneCheck struct {
+	Client  client.Client
+	Fedramp cTypes.Fedramp
+
+	// newClient builds the Route53 client, it is replaced in tests.
+	newClient func(kubeClient client.Client) (*awsClient, error)
+
+	mu      sync.RWMutex
+	checked bool
+	err     error
+}
+
+// Start checks the hosted zone, retrying failed checks until one succeeds or ctx is cancelled.
+func (c *FedrampZoneCheck) Start(ctx context.Context) error {
+	logger := logf.Log.WithName("fedramp_zone_check").WithValues("HostedZoneID", c.Fedramp.HostedZoneID)
+
+	for {
+		err := c.check(logger)
+		c.setResult(err)
+		if err == nil {
+			logger.Info("FedRAMP hosted zone is usable")
+			return nil
+		}
+		logger.Error(err, "FedRAMP hosted zone check failed, retrying", "RetryInterval", fedrampZoneCheckRetryInterval)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(fedrampZoneCheckRetryInterval):
+		}
+	}
+}
+
+// NeedLeaderElection returns false so every replica reports its own readiness.
+func (c *FedrampZoneCheck) NeedLeaderElection() bool {
+	return false
+}
+
+// Ready is a healthz.Checker returning the error of the last check.
+func (c *FedrampZoneCheck) Ready(_ *http.Request) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.checked {
+		return errFedrampZoneCheckPending
+	}
+	return c.err
+}
+
+func (c *FedrampZoneCheck) check(logger logr.Logger) error {
+	newClient := c.newClient
+	if newClient == nil {
+		newClient = func(kubeClient client.Client) (*awsClient, error) {
+			return NewClient(logger, kubeClient, awsCredsSecretName, "", c.Fedramp.Region, "", "", c.Fedramp)
+		}
+	}
+
+	r53, err := newClient(c.Client)
+	if err != nil {
+		return err
+	}
+
+	return r53.ValidateFedrampHostedZone(logger, c.Fedramp.HostedZoneID)
+}
+
+func (c *FedrampZoneCheck) setResult(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked = true
+	c.err = err
+
+	if err == nil {
+		localmetrics.MetricFedrampZoneCheckSuccess.Set(1)
+	} else {
+		localmetrics.MetricFedrampZoneCheckSuccess.Set(0)
+	}
+}
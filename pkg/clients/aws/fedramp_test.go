@@ -0,0 +1,62 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/certman-operator/pkg/clients/aws/mockroute53"
+	"github.com/openshift/certman-operator/pkg/clients/nameserver"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestFedrampZoneCheck(t *testing.T) {
+	lookupTXT = func(ns string, fqdn string) ([]string, error) {
+		return []string{}, nil
+	}
+	defer func() { lookupTXT = nameserver.LookupTXT }()
+
+	check := &FedrampZoneCheck{
+		Fedramp: cTypes.Fedramp{Enabled: true, HostedZoneID: testDnsZoneID},
+		newClient: func(kubeClient client.Client) (*awsClient, error) {
+			return &awsClient{
+				client: &mockroute53.MockRoute53Client{NameServers: []string{"ns-1.example.com"}},
+			}, nil
+		},
+	}
+
+	if err := check.Ready(nil); err == nil {
+		t.Error("expected the check not to be ready before it ran")
+	}
+
+	if err := check.Start(context.TODO()); err != nil {
+		t.Fatalf("unexpected error running the check: %s", err)
+	}
+
+	if err := check.Ready(nil); err != nil {
+		t.Errorf("expected the check to be ready, got %s", err)
+	}
+
+	if value := testutil.ToFloat64(localmetrics.MetricFedrampZoneCheckSuccess); value != 1 {
+		t.Errorf("expected the zone check metric to be 1, got %v", value)
+	}
+}
@@ -13,7 +13,12 @@ import (
 
 type MockRoute53Client struct {
 	route53iface.Route53API
-	ZoneCount int
+	ZoneCount   int
+	NameServers []string
+	// NamedZones are the zones returned by ListHostedZonesByName
+	NamedZones []*route53.HostedZone
+	// ChangeErr is returned by ChangeResourceRecordSets when set
+	ChangeErr error
 }
 
 func (m *MockRoute53Client) GetFedrampHostedZoneIDPath(fedrampHostedZoneID string) (string, error) {
@@ -85,11 +90,28 @@ func (m *MockRoute53Client) ListHostedZones(lhzi *route53.ListHostedZonesInput)
 	return output, nil
 }
 
+// ListHostedZonesByName returns the NamedZones of the requested name.
+func (m *MockRoute53Client) ListHostedZonesByName(input *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+	hostedZones := []*route53.HostedZone{}
+	for _, zone := range m.NamedZones {
+		if strings.EqualFold(aws.StringValue(zone.Name), aws.StringValue(input.DNSName)) {
+			hostedZones = append(hostedZones, zone)
+		}
+	}
+
+	return &route53.ListHostedZonesByNameOutput{
+		DNSName:     input.DNSName,
+		HostedZones: hostedZones,
+		IsTruncated: aws.Bool(false),
+		MaxItems:    input.MaxItems,
+	}, nil
+}
+
 func (c *MockRoute53Client) GetHostedZone(input *route53.GetHostedZoneInput) (output *route53.GetHostedZoneOutput, err error) {
 	idNumber := strings.Split(*input.Id, "id")[1]
 	output = &route53.GetHostedZoneOutput{
 		DelegationSet: &route53.DelegationSet{
-			NameServers: []*string{},
+			NameServers: aws.StringSlice(c.NameServers),
 		},
 		HostedZone: &route53.HostedZone{
 			CallerReference: aws.String(fmt.Sprintf("zone%s", idNumber)),
@@ -104,6 +126,9 @@ func (c *MockRoute53Client) GetHostedZone(input *route53.GetHostedZoneInput) (ou
 }
 
 func (c *MockRoute53Client) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (output *route53.ChangeResourceRecordSetsOutput, err error) {
+	if c.ChangeErr != nil {
+		return nil, c.ChangeErr
+	}
 	output = &route53.ChangeResourceRecordSetsOutput{
 		ChangeInfo: &route53.ChangeInfo{
 			Id:          aws.String("mockchangeid"),
@@ -131,3 +156,14 @@ func (c *MockRoute53Client) ListResourceRecordSets(input *route53.ListResourceRe
 
 	return
 }
+
+func (c *MockRoute53Client) GetHostedZoneLimit(input *route53.GetHostedZoneLimitInput) (output *route53.GetHostedZoneLimitOutput, err error) {
+	output = &route53.GetHostedZoneLimitOutput{
+		Count: aws.Int64(1),
+		Limit: &route53.HostedZoneLimit{
+			Type:  input.Type,
+			Value: aws.Int64(10000),
+		},
+	}
+	return
+}
@@ -18,8 +18,9 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
 	"strings"
 	"time"
 
@@ -27,6 +28,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	awsclient "github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/aws/aws-sdk-go/service/route53/route53iface"
@@ -34,12 +37,21 @@ import (
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	aaov1alpha1 "github.com/openshift/aws-account-operator/api/v1alpha1"
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/chaos"
+	"github.com/openshift/certman-operator/pkg/clients/credentialhealth"
+	"github.com/openshift/certman-operator/pkg/clients/nameserver"
+	"github.com/openshift/certman-operator/pkg/clients/quota"
 	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 )
 
@@ -47,9 +59,6 @@ const (
 	awsCredsSecretIDKey         = "aws_access_key_id"
 	awsCredsSecretAccessKey     = "aws_secret_access_key" //#nosec - G101: Potential hardcoded credentials
 	awsCredsSecretName          = "certman-operator-aws-credentials"
-	fedrampEnvVariable          = "FEDRAMP"
-	fedrampHostedZoneIDVariable = "HOSTED_ZONE_ID"
-	fedrampAWSRegion            = "us-east-1"
 	resourceRecordTTL           = 60
 	clientMaxRetries            = 25
 	retryerMaxRetries           = 10
@@ -60,12 +69,31 @@ const (
 	configMapSTSJumpRoleField   = "sts-jump-role"
 )
 
-var fedramp = os.Getenv(fedrampEnvVariable) == "true"
-var fedrampHostedZoneID = os.Getenv(fedrampHostedZoneIDVariable)
+// challengeRecordDeleteBackoff is how the deletion of a challenge record is retried before it is
+// reported as failed, on top of the retries of the SDK.
+var challengeRecordDeleteBackoff = wait.Backoff{Steps: 3, Duration: time.Second, Factor: 2}
+
+// partitionDefaultRegions holds the region the global endpoints of each AWS partition are signed in.
+var partitionDefaultRegions = map[string]string{
+	endpoints.AwsPartitionID:      endpoints.UsEast1RegionID,
+	endpoints.AwsUsGovPartitionID: endpoints.UsGovWest1RegionID,
+	endpoints.AwsCnPartitionID:    endpoints.CnNorthwest1RegionID,
+}
+
+// lookupTXT queries a nameserver directly, it is a variable so tests don't need a real nameserver.
+var lookupTXT = nameserver.LookupTXT
 
 // awsClient implements the Client interface
 type awsClient struct {
 	client route53iface.Route53API
+	// delegateClients are the clients of the accounts subdomains of clusters are delegated to
+	delegateClients []route53iface.Route53API
+	// zoneClients holds the client of the account of each hosted zone found in a lookup
+	zoneClients map[string]route53iface.Route53API
+	// fedramp, when enabled, has the records of every cluster written to its hosted zone
+	fedramp cTypes.Fedramp
+	// metadata describes the change batches in the audit trail of Route53
+	metadata cTypes.ChangeMetadata
 }
 
 func (c *awsClient) GetDNSName() string {
@@ -84,6 +112,15 @@ func (c *awsClient) AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeToken
 	fqdn = fmt.Sprintf("%s.%s", cTypes.AcmeChallengeSubDomain, domain)
 	reqLogger.Info(fmt.Sprintf("fqdn acme challenge domain is %v", fqdn))
 
+	answered, err := c.challengeAnswered(dnsZone, fqdn, acmeChallengeToken)
+	if err != nil {
+		return "", err
+	}
+	if answered {
+		reqLogger.Info(fmt.Sprintf("resource record %v already holds the challenge token", fqdn))
+		return fqdn, nil
+	}
+
 	input := &route53.ChangeResourceRecordSetsInput{
 		ChangeBatch: &route53.ChangeBatch{
 			Changes: []*route53.Change{
@@ -101,28 +138,50 @@ func (c *awsClient) AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeToken
 					},
 				},
 			},
-			Comment: aws.String(""),
+			Comment: aws.String(c.metadata.Comment(cr)),
 		},
 	}
 
 	input.HostedZoneId = &dnsZone
-	result, err := c.client.ChangeResourceRecordSets(input)
+	result, err := c.zoneClient(dnsZone).ChangeResourceRecordSets(input)
 	if err != nil {
-		reqLogger.Error(err, result.GoString(), "fqdn", fqdn)
+		reqLogger.Error(err, result.GoString(), logging.DomainKey, fqdn)
 		return "", err
 	}
 	reqLogger.Info(fmt.Sprintf("updating hosted zone %v", input.HostedZoneId))
 	return fqdn, nil
 }
 
+// challengeAnswered returns true when the TXT record fqdn of the hosted zone dnsZone holds nothing
+// but acmeChallengeToken, so the challenge needs no write.
+func (c *awsClient) challengeAnswered(dnsZone string, fqdn string, acmeChallengeToken string) (bool, error) {
+	resp, err := c.zoneClient(dnsZone).ListResourceRecordSets(&route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(dnsZone),
+		StartRecordName: aws.String(fqdn),
+		StartRecordType: aws.String(route53.RRTypeTxt),
+		MaxItems:        aws.String("1"),
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(resp.ResourceRecordSets) == 0 {
+		return false, nil
+	}
+	rs := resp.ResourceRecordSets[0]
+	return strings.TrimSuffix(aws.StringValue(rs.Name), ".") == strings.TrimSuffix(fqdn, ".") &&
+		aws.StringValue(rs.Type) == route53.RRTypeTxt &&
+		len(rs.ResourceRecords) == 1 &&
+		aws.StringValue(rs.ResourceRecords[0].Value) == fmt.Sprintf("\"%s\"", acmeChallengeToken), nil
+}
+
 // ValidateDnsWriteAccess spawns a route53 client to retrieve the baseDomain's hostedZoneOutput
 // and attempts to write a test TXT ResourceRecord to it. If successful, will return `true, nil`.
 func (c *awsClient) ValidateDNSWriteAccess(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (bool, error) {
 
 	var hostedZones []*route53.HostedZone
 	var err error
-	if fedramp {
-		zone, err := c.client.GetHostedZone(&route53.GetHostedZoneInput{Id: &fedrampHostedZoneID})
+	if c.fedramp.Enabled {
+		zone, err := c.client.GetHostedZone(&route53.GetHostedZoneInput{Id: &c.fedramp.HostedZoneID})
 		if err != nil {
 			reqLogger.Error(err, err.Error())
 			return false, err
@@ -132,43 +191,10 @@ func (c *awsClient) ValidateDNSWriteAccess(reqLogger logr.Logger, cr *certmanv1a
 			baseDomain = baseDomain + "."
 		}
 		// the fedramp recordset includes the subdomain because one isn't created by hive
-		input := &route53.ChangeResourceRecordSetsInput{
-			ChangeBatch: &route53.ChangeBatch{
-				Changes: []*route53.Change{
-					{
-						Action: aws.String(route53.ChangeActionUpsert),
-						ResourceRecordSet: &route53.ResourceRecordSet{
-							Name: aws.String("_certman_access_test." + baseDomain),
-							ResourceRecords: []*route53.ResourceRecord{
-								{
-									Value: aws.String("\"txt_entry\""),
-								},
-							},
-							TTL:  aws.Int64(resourceRecordTTL),
-							Type: aws.String(route53.RRTypeTxt),
-						},
-					},
-				},
-				Comment: aws.String(""),
-			},
-			HostedZoneId: zone.HostedZone.Id,
-		}
-
-		reqLogger.Info(fmt.Sprintf("updating hosted zone %v", zone.HostedZone.Name))
-
-		// Initiate the Write test
-		_, err = c.client.ChangeResourceRecordSets(input)
-		if err != nil {
-			return false, err
-		}
-
-		// After successful write test clean up the test record and test deletion of that record.
-		input.ChangeBatch.Changes[0].Action = aws.String(route53.ChangeActionDelete)
-		_, err = c.client.ChangeResourceRecordSets(input)
-		if err != nil {
-			reqLogger.Error(err, "Error while deleting Write Access record")
+		if err := c.testRecordWrite(reqLogger, zone.HostedZone, "_certman_access_test."+baseDomain, cr); err != nil {
 			return false, err
 		}
+		c.reportRecordSetUsage(reqLogger, zone.HostedZone)
 		// If Write and Delete are successful return clean.
 		return true, nil
 	}
@@ -194,51 +220,113 @@ func (c *awsClient) ValidateDNSWriteAccess(reqLogger logr.Logger, cr *certmanv1a
 			}
 
 			if !*zone.HostedZone.Config.PrivateZone {
-				// Build the test record
-				input := &route53.ChangeResourceRecordSetsInput{
-					ChangeBatch: &route53.ChangeBatch{
-						Changes: []*route53.Change{
+				if err := c.testRecordWrite(reqLogger, hostedzone, "_certman_access_test."+*hostedzone.Name, cr); err != nil {
+					return false, err
+				}
+				c.reportRecordSetUsage(reqLogger, hostedzone)
+				// If Write and Delete are successful return clean.
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// testRecordWrite writes a test TXT record named name to hostedzone for cr, which is nil when the
+// access isn't checked for a CertificateRequest, and deletes it again.
+func (c *awsClient) testRecordWrite(reqLogger logr.Logger, hostedzone *route53.HostedZone, name string, cr *certmanv1alpha1.CertificateRequest) error {
+	input := &route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action: aws.String(route53.ChangeActionUpsert),
+					ResourceRecordSet: &route53.ResourceRecordSet{
+						Name: aws.String(name),
+						ResourceRecords: []*route53.ResourceRecord{
 							{
-								Action: aws.String(route53.ChangeActionUpsert),
-								ResourceRecordSet: &route53.ResourceRecordSet{
-									Name: aws.String("_certman_access_test." + *hostedzone.Name),
-									ResourceRecords: []*route53.ResourceRecord{
-										{
-											Value: aws.String("\"txt_entry\""),
-										},
-									},
-									TTL:  aws.Int64(resourceRecordTTL),
-									Type: aws.String(route53.RRTypeTxt),
-								},
+								Value: aws.String("\"txt_entry\""),
 							},
 						},
-						Comment: aws.String(""),
+						TTL:  aws.Int64(resourceRecordTTL),
+						Type: aws.String(route53.RRTypeTxt),
 					},
-					HostedZoneId: hostedzone.Id,
-				}
+				},
+			},
+			Comment: aws.String(c.metadata.Comment(cr)),
+		},
+		HostedZoneId: hostedzone.Id,
+	}
 
-				reqLogger.Info(fmt.Sprintf("updating hosted zone %v", hostedzone.Name))
+	reqLogger.Info(fmt.Sprintf("updating hosted zone %v", aws.StringValue(hostedzone.Name)))
 
-				// Initiate the Write test
-				_, err := c.client.ChangeResourceRecordSets(input)
-				if err != nil {
-					return false, err
-				}
+	// Initiate the Write test
+	_, err := c.client.ChangeResourceRecordSets(input)
+	if err != nil {
+		return err
+	}
 
-				// After successful write test clean up the test record and test deletion of that record.
-				input.ChangeBatch.Changes[0].Action = aws.String(route53.ChangeActionDelete)
-				_, err = c.client.ChangeResourceRecordSets(input)
-				if err != nil {
-					reqLogger.Error(err, "Error while deleting Write Access record")
-					return false, err
-				}
-				// If Write and Delete are successful return clean.
-				return true, nil
-			}
+	// After successful write test clean up the test record and test deletion of that record.
+	input.ChangeBatch.Changes[0].Action = aws.String(route53.ChangeActionDelete)
+	_, err = c.client.ChangeResourceRecordSets(input)
+	if err != nil {
+		reqLogger.Error(err, "Error while deleting Write Access record")
+		return err
+	}
+
+	return nil
+}
+
+// reportRecordSetUsage reports the number of record sets of hostedzone against its limit. The usage
+// is only informative, so failing to read it, for example without the permission, is only logged.
+func (c *awsClient) reportRecordSetUsage(reqLogger logr.Logger, hostedzone *route53.HostedZone) {
+	output, err := c.client.GetHostedZoneLimit(&route53.GetHostedZoneLimitInput{
+		HostedZoneId: hostedzone.Id,
+		Type:         aws.String(route53.HostedZoneLimitTypeMaxRrsetsByZone),
+	})
+	if err != nil {
+		reqLogger.Info(fmt.Sprintf("could not read the record set limit of hosted zone %v: %v", aws.StringValue(hostedzone.Name), err))
+		return
+	}
+	if output.Limit == nil {
+		return
+	}
+
+	quota.RecordRecordSetUsage(quota.ProviderAWS, aws.StringValue(hostedzone.Name), aws.Int64Value(output.Count), aws.Int64Value(output.Limit.Value))
+}
+
+// ValidateFedrampHostedZone checks that the FedRAMP hosted zone hostedZoneID exists, is public,
+// that every nameserver of its delegation set answers queries and that records can be written to it.
+func (c *awsClient) ValidateFedrampHostedZone(reqLogger logr.Logger, hostedZoneID string) error {
+	if hostedZoneID == "" {
+		return fmt.Errorf("%v environment variable is unset but is required in FedRAMP environment", cTypes.FedrampHostedZoneIDVariable)
+	}
+
+	zone, err := c.client.GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(hostedZoneID)})
+	if err != nil {
+		return fmt.Errorf("unable to get hosted zone %v: %w", hostedZoneID, err)
+	}
+
+	if zone.HostedZone.Config != nil && aws.BoolValue(zone.HostedZone.Config.PrivateZone) {
+		return fmt.Errorf("hosted zone %v is private, ACME challenges can't be answered from it", hostedZoneID)
+	}
+
+	if zone.DelegationSet == nil || len(zone.DelegationSet.NameServers) == 0 {
+		return fmt.Errorf("hosted zone %v has no delegation set", hostedZoneID)
+	}
+
+	zoneName := aws.StringValue(zone.HostedZone.Name)
+	for _, ns := range aws.StringValueSlice(zone.DelegationSet.NameServers) {
+		if _, err := lookupTXT(ns, zoneName); err != nil {
+			return fmt.Errorf("nameserver %v of hosted zone %v is not reachable: %w", ns, hostedZoneID, err)
 		}
 	}
 
-	return false, nil
+	if err := c.testRecordWrite(reqLogger, zone.HostedZone, "_certman_access_test."+zoneName, nil); err != nil {
+		return fmt.Errorf("unable to write to hosted zone %v: %w", hostedZoneID, err)
+	}
+
+	return nil
 }
 
 // DeleteAcmeChallengeResourceRecords spawns an AWS client, constructs baseDomain to retrieve the HostedZones. The ResourceRecordSets are
@@ -247,19 +335,19 @@ func (c *awsClient) DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger, cr
 
 	var hostedZones []*route53.HostedZone
 
-	if fedramp {
-		zone, err := c.client.GetHostedZone(&route53.GetHostedZoneInput{Id: &fedrampHostedZoneID})
+	if c.fedramp.Enabled {
+		zone, err := c.client.GetHostedZone(&route53.GetHostedZoneInput{Id: &c.fedramp.HostedZoneID})
 		if err != nil {
 			reqLogger.Error(err, err.Error())
-			return err
+			return zoneNotFound(err)
 		}
 		hostedZones = []*route53.HostedZone{zone.HostedZone}
 	} else {
-		hostedZoneOutput, err := c.client.ListHostedZones(&route53.ListHostedZonesInput{})
+		var err error
+		hostedZones, err = listAllHostedZones(c.client, &route53.ListHostedZonesInput{})
 		if err != nil {
 			return err
 		}
-		hostedZones = hostedZoneOutput.HostedZones
 	}
 
 	baseDomain := cr.Spec.ACMEDNSDomain
@@ -267,103 +355,307 @@ func (c *awsClient) DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger, cr
 		baseDomain = baseDomain + "."
 	}
 
+	// the records of every domain are cleaned up even when some of them can't be
+	errs := []error{}
 	for _, hostedzone := range hostedZones {
 		// For fedramp clusters, there will only be one hostedZone and the baseDomain won't match
 		// the hostedZone name, so just use the first hostedZone in the loop.
-		if strings.EqualFold(baseDomain, *hostedzone.Name) || fedramp {
+		if strings.EqualFold(baseDomain, *hostedzone.Name) || c.fedramp.Enabled {
 			zone, err := c.client.GetHostedZone(&route53.GetHostedZoneInput{Id: hostedzone.Id})
 			if err != nil {
-				return err
+				errs = append(errs, zoneNotFound(err))
+				continue
 			}
 
 			if !*zone.HostedZone.Config.PrivateZone {
-
 				for _, domain := range cr.Spec.DnsNames {
-					// Format domain strings, no leading '*', must lead with '.'
-					domain = strings.TrimPrefix(domain, "*")
-					if !strings.HasPrefix(domain, ".") {
-						domain = "." + domain
+					if err := c.deleteAcmeChallengeResourceRecord(reqLogger, hostedzone, domain, cr); err != nil {
+						errs = append(errs, zoneNotFound(err))
 					}
-					fqdn := cTypes.AcmeChallengeSubDomain + domain
-					fqdnWithDot := fqdn + "."
+				}
+			}
+		}
+	}
 
-					reqLogger.Info(fmt.Sprintf("deleting resource record %v", fqdn))
+	// The challenges of subdomains delegated to other accounts were answered in their zones
+	for _, domain := range cr.Spec.DnsNames {
+		fqdn := fmt.Sprintf("%s.%s", cTypes.AcmeChallengeSubDomain, strings.TrimPrefix(domain, "*."))
+		hostedzone, err := c.findAuthoritativeZone(reqLogger, fqdn, c.delegateClients)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if hostedzone != nil {
+			if err := c.deleteAcmeChallengeResourceRecord(reqLogger, hostedzone, domain, cr); err != nil {
+				errs = append(errs, zoneNotFound(err))
+			}
+		}
+	}
 
-					resp, err := c.client.ListResourceRecordSets(&route53.ListResourceRecordSetsInput{
-						HostedZoneId:    aws.String(*hostedzone.Id), // Required
-						StartRecordName: aws.String(fqdn),
-						StartRecordType: aws.String(route53.RRTypeTxt),
-					})
+	return errors.Join(errs...)
+}
 
-					if err != nil {
-						return err
-					}
-					if len(resp.ResourceRecordSets) > 0 &&
-						*resp.ResourceRecordSets[0].Name == fqdnWithDot &&
-						*resp.ResourceRecordSets[0].Type == route53.RRTypeTxt &&
-						len(resp.ResourceRecordSets[0].ResourceRecords) > 0 {
-						for _, rr := range resp.ResourceRecordSets[0].ResourceRecords {
-							input := &route53.ChangeResourceRecordSetsInput{
-								ChangeBatch: &route53.ChangeBatch{
-									Changes: []*route53.Change{
-										{
-											Action: aws.String(route53.ChangeActionDelete),
-											ResourceRecordSet: &route53.ResourceRecordSet{
-												Name: aws.String(fqdn),
-												ResourceRecords: []*route53.ResourceRecord{
-													{
-														Value: aws.String(*rr.Value),
-													},
-												},
-												TTL:  aws.Int64(resourceRecordTTL),
-												Type: aws.String(route53.RRTypeTxt),
-											},
-										},
-									},
-									Comment: aws.String(""),
-								},
-								HostedZoneId: hostedzone.Id,
-							}
-
-							reqLogger.Info(fmt.Sprintf("updating hosted zone %v", hostedzone.Name))
-
-							result, err := c.client.ChangeResourceRecordSets(input)
-							if err != nil {
-								reqLogger.Error(err, result.GoString())
-								return nil
-							}
-						}
-					}
-				}
+// DeleteAcmeChallengeResourceRecord removes the ACME challenge record of a single domain from the
+// hosted zone the challenge was answered in.
+func (c *awsClient) DeleteAcmeChallengeResourceRecord(reqLogger logr.Logger, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) error {
+	zone, err := c.zoneClient(dnsZone).GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(dnsZone)})
+	if err != nil {
+		return zoneNotFound(err)
+	}
+
+	return zoneNotFound(c.deleteAcmeChallengeResourceRecord(reqLogger, zone.HostedZone, domain, cr))
+}
+
+// VerifyRecordVisible checks that the nameservers of the hosted zone's delegation set serve value for fqdn.
+func (c *awsClient) VerifyRecordVisible(reqLogger logr.Logger, fqdn string, value string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (bool, error) {
+	zone, err := c.zoneClient(dnsZone).GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(dnsZone)})
+	if err != nil {
+		return false, err
+	}
+
+	if zone.DelegationSet == nil {
+		return false, fmt.Errorf("hosted zone %v has no delegation set", dnsZone)
+	}
+
+	return nameserver.RecordVisible(reqLogger, aws.StringValueSlice(zone.DelegationSet.NameServers), fqdn, value)
+}
+
+// EnsureZoneRecords writes the CAA record allowing Let's Encrypt to issue certificates and the
+// ownership TXT record of clusterID for the base domain of cr to the hosted zone dnsZone.
+func (c *awsClient) EnsureZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	reqLogger.Info(fmt.Sprintf("writing CAA and ownership records of %v to hosted zone %v", cr.Spec.ACMEDNSDomain, dnsZone))
+	_, err := c.client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: zoneRecordChanges(route53.ChangeActionUpsert, cr.Spec.ACMEDNSDomain, clusterID),
+			Comment: aws.String(c.metadata.Comment(cr)),
+		},
+		HostedZoneId: aws.String(dnsZone),
+	})
+	return err
+}
+
+// DeleteZoneRecords removes the records written by EnsureZoneRecords. Records that are already gone
+// are ignored, a hosted zone that is gone is reported with cTypes.ErrZoneNotFound.
+func (c *awsClient) DeleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	reqLogger.Info(fmt.Sprintf("deleting CAA and ownership records of %v from hosted zone %v", cr.Spec.ACMEDNSDomain, dnsZone))
+	for _, change := range zoneRecordChanges(route53.ChangeActionDelete, cr.Spec.ACMEDNSDomain, clusterID) {
+		_, err := c.client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: []*route53.Change{change},
+				Comment: aws.String(c.metadata.Comment(cr)),
+			},
+			HostedZoneId: aws.String(dnsZone),
+		})
+		if err != nil {
+			// Route53 rejects the deletion of a record that doesn't exist
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == route53.ErrCodeInvalidChangeBatch {
+				reqLogger.Info(fmt.Sprintf("%v record of %v already deleted", aws.StringValue(change.ResourceRecordSet.Type), cr.Spec.ACMEDNSDomain))
+				continue
 			}
+			return zoneNotFound(err)
 		}
 	}
-
 	return nil
 }
 
+// zoneNotFound wraps the NoSuchHostedZone errors of Route53 with cTypes.ErrZoneNotFound and returns
+// the other errors as they are.
+func zoneNotFound(err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == route53.ErrCodeNoSuchHostedZone {
+		return fmt.Errorf("%w: %v", cTypes.ErrZoneNotFound, err)
+	}
+	return err
+}
+
+// zoneRecordChanges returns the changes applying action to the CAA and ownership TXT records of domain.
+func zoneRecordChanges(action string, domain string, clusterID string) []*route53.Change {
+	name := strings.TrimSuffix(domain, ".") + "."
+	return []*route53.Change{
+		{
+			Action: aws.String(action),
+			ResourceRecordSet: &route53.ResourceRecordSet{
+				Name: aws.String(name),
+				ResourceRecords: []*route53.ResourceRecord{
+					{
+						Value: aws.String(fmt.Sprintf("0 issue \"%s\"", cTypes.CAAIssuer)),
+					},
+				},
+				TTL:  aws.Int64(resourceRecordTTL),
+				Type: aws.String(route53.RRTypeCaa),
+			},
+		},
+		{
+			Action: aws.String(action),
+			ResourceRecordSet: &route53.ResourceRecordSet{
+				Name: aws.String(name),
+				ResourceRecords: []*route53.ResourceRecord{
+					{
+						Value: aws.String(fmt.Sprintf("\"%s%s\"", cTypes.OwnershipRecordPrefix, clusterID)),
+					},
+				},
+				TTL:  aws.Int64(resourceRecordTTL),
+				Type: aws.String(route53.RRTypeTxt),
+			},
+		},
+	}
+}
+
+// deleteAcmeChallengeResourceRecord looks up the TXT record sets answering the ACME challenge of
+// domain in hostedzone and deletes them with every value they hold. Route53 only deletes a record
+// set whose values all match, so the listed record sets are deleted as a whole. The errors of every
+// deletion are returned.
+func (c *awsClient) deleteAcmeChallengeResourceRecord(reqLogger logr.Logger, hostedzone *route53.HostedZone, domain string, cr *certmanv1alpha1.CertificateRequest) error {
+	// Format domain strings, no leading '*', must lead with '.'
+	domain = strings.TrimPrefix(domain, "*")
+	if !strings.HasPrefix(domain, ".") {
+		domain = "." + domain
+	}
+	fqdn := cTypes.AcmeChallengeSubDomain + domain
+	fqdnWithDot := fqdn + "."
+
+	reqLogger.Info(fmt.Sprintf("deleting resource record %v", fqdn))
+
+	r53 := c.zoneClient(*hostedzone.Id)
+	recordSets, err := listRecordSets(r53, *hostedzone.Id, fqdnWithDot, route53.RRTypeTxt)
+	if err != nil {
+		return fmt.Errorf("could not list the %s records of hosted zone %s: %w", fqdn, aws.StringValue(hostedzone.Name), err)
+	}
+
+	errs := []error{}
+	for _, recordSet := range recordSets {
+		if len(recordSet.ResourceRecords) == 0 {
+			continue
+		}
+		input := &route53.ChangeResourceRecordSetsInput{
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: []*route53.Change{
+					{
+						Action:            aws.String(route53.ChangeActionDelete),
+						ResourceRecordSet: recordSet,
+					},
+				},
+				Comment: aws.String(c.metadata.Comment(cr)),
+			},
+			HostedZoneId: hostedzone.Id,
+		}
+
+		reqLogger.Info(fmt.Sprintf("updating hosted zone %v", aws.StringValue(hostedzone.Name)))
+
+		err := retry.OnError(challengeRecordDeleteBackoff, retriableChange, func() error {
+			_, err := r53.ChangeResourceRecordSets(input)
+			return err
+		})
+		if recordSetNotFound(err) {
+			reqLogger.Info(fmt.Sprintf("%v record already deleted", fqdn))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not delete the %s record of hosted zone %s: %w", fqdn, aws.StringValue(hostedzone.Name), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// retriableChange returns true when a change rejected with err may succeed when sent again, as
+// after a PriorRequestNotComplete or a throttling the SDK gave up on. Invalid change batches and
+// missing hosted zones aren't retried.
+func retriableChange(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() != route53.ErrCodeInvalidChangeBatch && aerr.Code() != route53.ErrCodeNoSuchHostedZone
+	}
+	return true
+}
+
+// recordSetNotFound returns true when err rejects the deletion of a record set that was already
+// deleted, by a concurrent cleanup for instance. The SDK reports an InvalidChangeBatch with a
+// generic message, the reason of each rejected change is one of its batched errors.
+func recordSetNotFound(err error) bool {
+	batch, ok := err.(awserr.BatchedErrors)
+	if !ok || batch.Code() != route53.ErrCodeInvalidChangeBatch {
+		return false
+	}
+	for _, origErr := range batch.OrigErrs() {
+		if aerr, ok := origErr.(awserr.Error); ok && strings.Contains(aerr.Message(), "not found") {
+			return true
+		}
+	}
+	return false
+}
+
+// listRecordSets returns the record sets of type rrType named name in the hosted zone zoneID,
+// following the pages of the listing. Several record sets share a name and type when they have
+// routing policies. The listing starts at name and the record sets are sorted by name and type,
+// so it stops at the first record set of another name or type.
+func listRecordSets(r53 route53iface.Route53API, zoneID string, name string, rrType string) ([]*route53.ResourceRecordSet, error) {
+	recordSets := []*route53.ResourceRecordSet{}
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneID),
+		StartRecordName: aws.String(name),
+		StartRecordType: aws.String(rrType),
+	}
+
+	for {
+		output, err := r53.ListResourceRecordSets(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, recordSet := range output.ResourceRecordSets {
+			if !recordSetNameEqual(aws.StringValue(recordSet.Name), name) || aws.StringValue(recordSet.Type) != rrType {
+				return recordSets, nil
+			}
+			recordSets = append(recordSets, recordSet)
+		}
+
+		if !aws.BoolValue(output.IsTruncated) {
+			return recordSets, nil
+		}
+		input.StartRecordName = output.NextRecordName
+		input.StartRecordType = output.NextRecordType
+		input.StartRecordIdentifier = output.NextRecordIdentifier
+	}
+}
+
+// recordSetNameEqual returns true when the record names a and b are the same, whatever their case
+// and trailing dot.
+func recordSetNameEqual(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
+
 // NewClient returns an awsclient.Client object to the caller. If NewClient is passed a non-null
 // secretName, an attempt to retrieve the secret from the namespace argument will be performed.
 // AWS credentials are returned as these secrets and a new session is initiated prior to returning
 // a client. If secrets fail to return, the IAM role of the masters is used to create a
-// new session for the client.
-func NewClient(reqLogger logr.Logger, kubeClient client.Client, secretName, namespace, region, clusterDeploymentName string) (*awsClient, error) {
-	awsConfig := &aws.Config{
-		Region: aws.String(region),
-		// MaxRetries to limit the number of attempts on failed API calls
-		MaxRetries: aws.Int(clientMaxRetries),
-		// Set MinThrottleDelay to 1 second
-		Retryer: awsclient.DefaultRetryer{
-			// Set NumMaxRetries to 10 (default is 3) for failed retries
-			NumMaxRetries: retryerMaxRetries,
-			// Set MinThrottleDelay to 1s (default is 500ms)
-			MinThrottleDelay: retryerMinThrottleDelaySec * time.Second,
-		},
+// new session for the client. The sessions are built in region, which must belong to partition
+// when it is set. When fedramp is enabled, the client writes to the FedRAMP hosted zone instead.
+func NewClient(reqLogger logr.Logger, kubeClient client.Client, secretName, namespace, region, partition, clusterDeploymentName string, fedramp cTypes.Fedramp) (*awsClient, error) {
+	platformDefaults, err := utils.GetPlatformDefaults(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fedramp hosted zone is in the operator's account, whose partition follows its region
+	if fedramp.Enabled {
+		region = fedramp.RegionOr(platformDefaults.FedrampAWSRegion)
+		partition = ""
+	} else {
+		region, partition = withPlatformDefaults(region, partition, platformDefaults)
+	}
+
+	region, err = resolvePartitionRegion(region, partition)
+	if err != nil {
+		return nil, err
+	}
+	awsConfig := newAWSConfig(region)
+
+	changeMetadata, err := utils.GetDNSChangeMetadata(kubeClient)
+	if err != nil {
+		return nil, err
 	}
 
 	// If this is a fedramp cluster, get AWS credentials from 'certman-operator' namespace
-	if fedramp {
-		awsConfig.Region = aws.String(fedrampAWSRegion)
+	if fedramp.Enabled {
 		secret := &corev1.Secret{}
 		err := kubeClient.Get(context.TODO(),
 			types.NamespacedName{
@@ -399,21 +691,66 @@ func NewClient(reqLogger logr.Logger, kubeClient client.Client, secretName, name
 		}
 
 		c := &awsClient{
-			client: route53.New(s),
+			client:   route53.New(s),
+			fedramp:  fedramp,
+			metadata: cTypes.NewChangeMetadata(changeMetadata),
 		}
 
 		return c, err
 	}
 
-	// Check if ClusterDeployment is labelled for STS
-	clusterDeployment := &hivev1.ClusterDeployment{}
-	err := kubeClient.Get(context.TODO(), types.NamespacedName{
-		Name:      clusterDeploymentName,
-		Namespace: namespace,
-	}, clusterDeployment)
+	s, err := clusterSession(reqLogger, kubeClient, secretName, namespace, awsConfig, clusterDeploymentName)
 	if err != nil {
 		return nil, err
 	}
+
+	delegateClients, err := newDelegateClients(kubeClient, region)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &awsClient{
+		client:          route53.New(s),
+		delegateClients: delegateClients,
+		metadata:        cTypes.NewChangeMetadata(changeMetadata),
+	}
+	return c, err
+}
+
+// NewSession returns a session in the account of the cluster, built like the sessions of the
+// clients returned by NewClient outside of FedRAMP, for the other AWS services used for the
+// cluster such as Secrets Manager.
+func NewSession(reqLogger logr.Logger, kubeClient client.Client, secretName, namespace, region, partition, clusterDeploymentName string) (*session.Session, error) {
+	platformDefaults, err := utils.GetPlatformDefaults(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	region, err = resolvePartitionRegion(withPlatformDefaults(region, partition, platformDefaults))
+	if err != nil {
+		return nil, err
+	}
+	return clusterSession(reqLogger, kubeClient, secretName, namespace, newAWSConfig(region), clusterDeploymentName)
+}
+
+// clusterSession returns a session of awsConfig in the account of the cluster: the customer role of
+// an STS cluster, assumed through the jump role, the credentials of secretName, or the IAM role of
+// the masters.
+func clusterSession(reqLogger logr.Logger, kubeClient client.Client, secretName, namespace string, awsConfig *aws.Config, clusterDeploymentName string) (*session.Session, error) {
+	region := aws.StringValue(awsConfig.Region)
+
+	// Check if ClusterDeployment is labelled for STS. Clients of the operator's own canary have no
+	// ClusterDeployment.
+	clusterDeployment := &hivev1.ClusterDeployment{}
+	if clusterDeploymentName != "" {
+		err := kubeClient.Get(context.TODO(), types.NamespacedName{
+			Name:      clusterDeploymentName,
+			Namespace: namespace,
+		}, clusterDeployment)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if stsEnabled, ok := clusterDeployment.Labels[clusterDeploymentSTSLabel]; ok && stsEnabled == "true" {
 		// Get STS jump role from from aws-account-operator ConfigMap
 		cm := &corev1.ConfigMap{}
@@ -471,22 +808,16 @@ func NewClient(reqLogger logr.Logger, kubeClient client.Client, secretName, name
 
 		jumpRoleCreds, err := getSTSCredentials(reqLogger, hiveAwsClient, stsAccessARN, "", "certmanOperator")
 		if err != nil {
+			credentialhealth.RecordFailure(quota.ProviderAWS, err)
 			return nil, fmt.Errorf("unable to assume jump role %s: %v", stsAccessARN, err)
 		}
 
-		jumpConfig := &aws.Config{
-			Region:     aws.String(region),
-			MaxRetries: aws.Int(clientMaxRetries),
-			Retryer: awsclient.DefaultRetryer{
-				NumMaxRetries:    retryerMaxRetries,
-				MinThrottleDelay: retryerMinThrottleDelaySec * time.Second,
-			},
-			Credentials: credentials.NewStaticCredentials(
-				*jumpRoleCreds.Credentials.AccessKeyId,
-				*jumpRoleCreds.Credentials.SecretAccessKey,
-				*jumpRoleCreds.Credentials.SessionToken,
-			),
-		}
+		jumpConfig := newAWSConfig(region)
+		jumpConfig.Credentials = credentials.NewStaticCredentials(
+			*jumpRoleCreds.Credentials.AccessKeyId,
+			*jumpRoleCreds.Credentials.SecretAccessKey,
+			*jumpRoleCreds.Credentials.SessionToken,
+		)
 
 		js, err := session.NewSession(jumpConfig)
 		if err != nil {
@@ -515,33 +846,24 @@ func NewClient(reqLogger logr.Logger, kubeClient client.Client, secretName, name
 		customerAccountCreds, err := getSTSCredentials(reqLogger, jumpRoleClient, accountClaim.Spec.STSRoleARN, accountClaim.Spec.STSExternalID, "RH-Account-Initilization")
 
 		if err != nil {
+			credentialhealth.RecordFailure(quota.ProviderAWS, err)
 			return nil, fmt.Errorf("unable to assume customer role %s: %v", accountClaim.Spec.STSRoleARN, err)
 		}
+		credentialhealth.RecordAcquired(quota.ProviderAWS, aws.TimeValue(customerAccountCreds.Credentials.Expiration))
 
-		customerAccountConfig := &aws.Config{
-			Region:     aws.String(region),
-			MaxRetries: aws.Int(clientMaxRetries),
-			Retryer: awsclient.DefaultRetryer{
-				NumMaxRetries:    retryerMaxRetries,
-				MinThrottleDelay: retryerMinThrottleDelaySec * time.Second,
-			},
-			Credentials: credentials.NewStaticCredentials(
-				*customerAccountCreds.Credentials.AccessKeyId,
-				*customerAccountCreds.Credentials.SecretAccessKey,
-				*customerAccountCreds.Credentials.SessionToken,
-			),
-		}
+		customerAccountConfig := newAWSConfig(region)
+		customerAccountConfig.Credentials = credentials.NewStaticCredentials(
+			*customerAccountCreds.Credentials.AccessKeyId,
+			*customerAccountCreds.Credentials.SecretAccessKey,
+			*customerAccountCreds.Credentials.SessionToken,
+		)
 
 		cs, err := session.NewSession(customerAccountConfig)
 		if err != nil {
 			return nil, fmt.Errorf("unable to setup AWS client with customer role credentials %s: %v", accountClaim.Spec.STSRoleARN, err)
 		}
 
-		c := &awsClient{
-			client: route53.New(cs),
-		}
-
-		return c, err
+		return cs, nil
 	}
 
 	if secretName != "" {
@@ -577,15 +899,84 @@ func NewClient(reqLogger logr.Logger, kubeClient client.Client, secretName, name
 	}
 
 	//// Otherwise default to relying on the IAM role of the masters where the actuator is running:
-	s, err := session.NewSession(awsConfig)
-	if err != nil {
-		return nil, err
+	return session.NewSession(awsConfig)
+}
+
+// newAWSConfig returns the configuration of the sessions in region. STS is called on its regional
+// endpoint, as the global one only exists in the aws partition.
+func newAWSConfig(region string) *aws.Config {
+	return &aws.Config{
+		Region: aws.String(region),
+		// in chaos mode the requests are failed before they reach AWS, and retried by the retryer
+		HTTPClient: &http.Client{Transport: chaos.Transport(quota.ProviderAWS, http.DefaultTransport)},
+		// MaxRetries to limit the number of attempts on failed API calls
+		MaxRetries: aws.Int(clientMaxRetries),
+		// Set MinThrottleDelay to 1 second
+		Retryer: throttleRecordingRetryer{awsclient.DefaultRetryer{
+			// Set NumMaxRetries to 10 (default is 3) for failed retries
+			NumMaxRetries: retryerMaxRetries,
+			// Set MinThrottleDelay to 1s (default is 500ms)
+			MinThrottleDelay: retryerMinThrottleDelaySec * time.Second,
+		}},
+		STSRegionalEndpoint: endpoints.RegionalSTSEndpoint,
 	}
+}
 
-	c := &awsClient{
-		client: route53.New(s),
+// throttleRecordingRetryer retries like the default retryer and records the throttled requests.
+type throttleRecordingRetryer struct {
+	awsclient.DefaultRetryer
+}
+
+// ShouldRetry is called for every failed attempt of a request.
+func (r throttleRecordingRetryer) ShouldRetry(req *request.Request) bool {
+	if req.IsErrorThrottle() {
+		quota.RecordThrottle(quota.ProviderAWS)
 	}
-	return c, err
+	return r.DefaultRetryer.ShouldRetry(req)
+}
+
+// withPlatformDefaults returns region and partition completed with the AWS platform defaults of
+// the operator configuration. A platform without a region gets the AWSRegion default when its
+// partition, if set, holds it, and one without a partition either gets the AWSPartition default.
+func withPlatformDefaults(region, partition string, defaults certmanv1alpha1.PlatformDefaults) (string, string) {
+	if region != "" {
+		return region, partition
+	}
+	if defaults.AWSRegion != "" {
+		defaultPartition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), defaults.AWSRegion)
+		if partition == "" || (ok && defaultPartition.ID() == partition) {
+			return defaults.AWSRegion, partition
+		}
+	}
+	if partition == "" {
+		partition = defaults.AWSPartition
+	}
+	return region, partition
+}
+
+// resolvePartitionRegion returns the region to build sessions in. Route53 is a global service
+// signed in the default region of its partition, so the region must belong to the partition of
+// the account. When partition is empty it is derived from region, and a region of an unknown
+// partition is used as is. When region is empty the default region of partition is used.
+func resolvePartitionRegion(region string, partition string) (string, error) {
+	if partition == "" {
+		return region, nil
+	}
+
+	defaultRegion, ok := partitionDefaultRegions[partition]
+	if !ok {
+		return "", fmt.Errorf("unsupported AWS partition %v", partition)
+	}
+	if region == "" {
+		return defaultRegion, nil
+	}
+
+	regionPartition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region)
+	if ok && regionPartition.ID() != partition {
+		return "", fmt.Errorf("AWS region %v is in partition %v, not %v", region, regionPartition.ID(), partition)
+	}
+
+	return region, nil
 }
 
 func getSTSCredentials(reqLogger logr.Logger, client *sts.STS, roleArn string, externalID string, roleSessionName string) (*sts.AssumeRoleOutput, error) {
@@ -653,6 +1044,7 @@ func listAllHostedZones(r53 route53iface.Route53API, lhzi *route53.ListHostedZon
 		if err != nil {
 			return []*route53.HostedZone{}, err
 		}
+		localmetrics.IncrementDNSZoneListPageCount(quota.ProviderAWS)
 
 		if output.IsTruncated == nil {
 			more = false
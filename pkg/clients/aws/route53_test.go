@@ -17,11 +17,19 @@ limitations under the License.
 package aws
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 
@@ -36,6 +44,7 @@ import (
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/pkg/clients/aws/mockroute53"
+	"github.com/openshift/certman-operator/pkg/clients/nameserver"
 	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
 )
 
@@ -97,7 +106,7 @@ func TestNewClient(t *testing.T) {
 		testClient := setUpEmptyTestClient(t)
 		reqLogger := log.WithValues("Request.Namespace", testHiveNamespace, "Request.Name", testHiveCertificateRequestName)
 
-		_, actual := NewClient(reqLogger, testClient, testHiveAWSSecretName, testHiveNamespace, testHiveAWSRegion, testHiveClusterDeploymentName)
+		_, actual := NewClient(reqLogger, testClient, testHiveAWSSecretName, testHiveNamespace, testHiveAWSRegion, "", testHiveClusterDeploymentName, cTypes.Fedramp{})
 
 		if actual == nil {
 			t.Error("expected an error when attempting to get missing account secret")
@@ -108,7 +117,7 @@ func TestNewClient(t *testing.T) {
 		testClient := setUpTestClient(t)
 		reqLogger := log.WithValues("Request.Namespace", testHiveNamespace, "Request.Name", testHiveCertificateRequestName)
 
-		_, err := NewClient(reqLogger, testClient, testHiveAWSSecretName, testHiveNamespace, testHiveAWSRegion, testHiveClusterDeploymentName)
+		_, err := NewClient(reqLogger, testClient, testHiveAWSSecretName, testHiveNamespace, testHiveAWSRegion, "", testHiveClusterDeploymentName, cTypes.Fedramp{})
 
 		if err != nil {
 			t.Errorf("unexpected error when creating the client: %q", err)
@@ -116,6 +125,117 @@ func TestNewClient(t *testing.T) {
 	})
 }
 
+func TestResolvePartitionRegion(t *testing.T) {
+	tests := []struct {
+		Name           string
+		Region         string
+		Partition      string
+		ExpectedRegion string
+		ExpectError    bool
+	}{
+		{
+			Name:           "derives the partition from the region",
+			Region:         "us-gov-west-1",
+			ExpectedRegion: "us-gov-west-1",
+		},
+		{
+			Name:           "keeps a region of the partition",
+			Region:         "us-gov-east-1",
+			Partition:      "aws-us-gov",
+			ExpectedRegion: "us-gov-east-1",
+		},
+		{
+			Name:           "uses the default region of the partition",
+			Partition:      "aws-cn",
+			ExpectedRegion: "cn-northwest-1",
+		},
+		{
+			Name:           "keeps a region of an unknown partition",
+			Region:         testHiveAWSRegion,
+			Partition:      "aws",
+			ExpectedRegion: testHiveAWSRegion,
+		},
+		{
+			Name:        "rejects a region of another partition",
+			Region:      "us-east-1",
+			Partition:   "aws-us-gov",
+			ExpectError: true,
+		},
+		{
+			Name:        "rejects an unsupported partition",
+			Region:      "us-east-1",
+			Partition:   "aws-iso",
+			ExpectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			region, err := resolvePartitionRegion(test.Region, test.Partition)
+			if test.ExpectError == (err == nil) {
+				t.Errorf("resolvePartitionRegion() %s: ExpectError: %t, actual error: %s\n", test.Name, test.ExpectError, err)
+			}
+			if region != test.ExpectedRegion {
+				t.Errorf("resolvePartitionRegion() %s: expected region %q, got %q\n", test.Name, test.ExpectedRegion, region)
+			}
+		})
+	}
+}
+
+func TestWithPlatformDefaults(t *testing.T) {
+	defaults := certmanv1alpha1.PlatformDefaults{AWSRegion: "us-gov-east-1", AWSPartition: "aws-us-gov"}
+	tests := []struct {
+		Name              string
+		Region            string
+		Partition         string
+		Defaults          certmanv1alpha1.PlatformDefaults
+		ExpectedRegion    string
+		ExpectedPartition string
+	}{
+		{
+			Name:           "keeps the region of the platform",
+			Region:         testHiveAWSRegion,
+			Defaults:       defaults,
+			ExpectedRegion: testHiveAWSRegion,
+		},
+		{
+			Name:           "uses the default region",
+			Defaults:       defaults,
+			ExpectedRegion: "us-gov-east-1",
+		},
+		{
+			Name:              "uses the default region in its partition",
+			Partition:         "aws-us-gov",
+			Defaults:          defaults,
+			ExpectedRegion:    "us-gov-east-1",
+			ExpectedPartition: "aws-us-gov",
+		},
+		{
+			Name:              "leaves the region of another partition to its default",
+			Partition:         "aws-cn",
+			Defaults:          defaults,
+			ExpectedPartition: "aws-cn",
+		},
+		{
+			Name:              "uses the default partition",
+			Defaults:          certmanv1alpha1.PlatformDefaults{AWSPartition: "aws-us-gov"},
+			ExpectedPartition: "aws-us-gov",
+		},
+		{
+			Name: "leaves the platform as is without defaults",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			region, partition := withPlatformDefaults(test.Region, test.Partition, test.Defaults)
+			if region != test.ExpectedRegion || partition != test.ExpectedPartition {
+				t.Errorf("withPlatformDefaults() %s: expected %q in %q, got %q in %q\n", test.Name, test.ExpectedRegion, test.ExpectedPartition, region, partition)
+			}
+		})
+	}
+}
+
 func TestListAllHostedZones(t *testing.T) {
 	r53 := &mockroute53.MockRoute53Client{
 		ZoneCount: 550,
@@ -221,10 +341,20 @@ func TestDeleteAcmeChallengeResourceRecords(t *testing.T) {
 			CertificateRequest: certRequest,
 			ExpectError:        false,
 		},
+		{
+			Name: "returns the errors of the deletions",
+			TestClient: &mockroute53.MockRoute53Client{
+				ZoneCount: 1,
+				ChangeErr: awserr.New("ServiceUnavailable", "Service Unavailable", nil),
+			},
+			CertificateRequest: certRequest,
+			ExpectError:        true,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.Name, func(t *testing.T) {
+			fastChallengeRecordDeleteRetries(t)
 			r53 := &awsClient{
 				client: test.TestClient,
 			}
@@ -237,6 +367,429 @@ func TestDeleteAcmeChallengeResourceRecords(t *testing.T) {
 	}
 }
 
+// pagedRecordSetsClient lists its record sets pageSize at a time and records the deleted ones.
+type pagedRecordSetsClient struct {
+	route53iface.Route53API
+
+	recordSets []*route53.ResourceRecordSet
+	pageSize   int
+	deleteErr  error
+	// deleteFailures, when set, has only the first deleteFailures deletions fail with deleteErr
+	deleteFailures int
+
+	listCalls   int
+	deleteCalls int
+	deleted     []*route53.ResourceRecordSet
+}
+
+func (m *pagedRecordSetsClient) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	m.listCalls++
+
+	// the record sets are sorted, the page starts at the first one matching the start of the input
+	start := 0
+	for i, recordSet := range m.recordSets {
+		if *recordSet.Name == *input.StartRecordName && *recordSet.Type == *input.StartRecordType &&
+			aws.StringValue(recordSet.SetIdentifier) == aws.StringValue(input.StartRecordIdentifier) {
+			start = i
+			break
+		}
+	}
+
+	end := start + m.pageSize
+	if end >= len(m.recordSets) {
+		return &route53.ListResourceRecordSetsOutput{ResourceRecordSets: m.recordSets[start:], IsTruncated: aws.Bool(false)}, nil
+	}
+	next := m.recordSets[end]
+	return &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets:   m.recordSets[start:end],
+		IsTruncated:          aws.Bool(true),
+		NextRecordName:       next.Name,
+		NextRecordType:       next.Type,
+		NextRecordIdentifier: next.SetIdentifier,
+	}, nil
+}
+
+func (m *pagedRecordSetsClient) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.deleteCalls++
+	if m.deleteErr != nil && (m.deleteFailures == 0 || m.deleteCalls <= m.deleteFailures) {
+		return nil, m.deleteErr
+	}
+	for _, change := range input.ChangeBatch.Changes {
+		m.deleted = append(m.deleted, change.ResourceRecordSet)
+	}
+	return &route53.ChangeResourceRecordSetsOutput{}, nil
+}
+
+// fastChallengeRecordDeleteRetries retries the deletions of challenge records without waiting for
+// the rest of the test.
+func fastChallengeRecordDeleteRetries(t *testing.T) {
+	backoff := challengeRecordDeleteBackoff
+	challengeRecordDeleteBackoff.Duration = time.Millisecond
+	t.Cleanup(func() { challengeRecordDeleteBackoff = backoff })
+}
+
+func testRecordSet(name, rrType, setIdentifier string) *route53.ResourceRecordSet {
+	recordSet := &route53.ResourceRecordSet{
+		Name:            aws.String(name),
+		Type:            aws.String(rrType),
+		ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("\"challenge\"")}},
+	}
+	if setIdentifier != "" {
+		recordSet.SetIdentifier = aws.String(setIdentifier)
+	}
+	return recordSet
+}
+
+func TestDeleteAcmeChallengeResourceRecordPages(t *testing.T) {
+	recordSets := []*route53.ResourceRecordSet{
+		testRecordSet("_acme-challenge.api.example.com.", route53.RRTypeTxt, "a"),
+		testRecordSet("_acme-challenge.api.example.com.", route53.RRTypeTxt, "b"),
+		testRecordSet("_acme-challenge.api.example.com.", route53.RRTypeTxt, "c"),
+		testRecordSet("_acme-challenge.api.example.com.", route53.RRTypeTxt, "d"),
+		testRecordSet("_acme-challenge.api.example.com.", route53.RRTypeTxt, "e"),
+		testRecordSet("_acme-challenge.api.example.com.mirror.", route53.RRTypeTxt, ""),
+		testRecordSet("_acme-challenge.apps.example.com.", route53.RRTypeTxt, ""),
+	}
+	hostedZone := &route53.HostedZone{Id: aws.String("/hostedzone/id1"), Name: aws.String("example.com.")}
+
+	tests := []struct {
+		Name              string
+		Domain            string
+		DeleteErr         error
+		DeleteFailures    int
+		ExpectedListCalls int
+		// ExpectedDeletes is the number of deletions sent, retries included, ExpectedDeleted by default
+		ExpectedDeletes int
+		ExpectedDeleted int
+		ExpectError     bool
+	}{
+		{
+			Name:              "deletes the record sets of every page",
+			Domain:            "api.example.com",
+			ExpectedListCalls: 3,
+			ExpectedDeleted:   5,
+		},
+		{
+			Name:              "deletes the record set named exactly",
+			Domain:            "apps.example.com",
+			ExpectedListCalls: 1,
+			ExpectedDeleted:   1,
+		},
+		{
+			Name:              "deletes nothing without a record set",
+			Domain:            "console.example.com",
+			ExpectedListCalls: 1,
+			ExpectedDeleted:   0,
+		},
+		{
+			Name:              "returns the deletion errors",
+			Domain:            "api.example.com",
+			DeleteErr:         fmt.Errorf("throttled"),
+			ExpectedListCalls: 3,
+			ExpectedDeletes:   15,
+			ExpectError:       true,
+		},
+		{
+			Name:              "retries a failed deletion",
+			Domain:            "api.example.com",
+			DeleteErr:         awserr.New(route53.ErrCodePriorRequestNotComplete, "The request was rejected because Route 53 was still processing a prior request", nil),
+			DeleteFailures:    1,
+			ExpectedListCalls: 3,
+			ExpectedDeletes:   6,
+			ExpectedDeleted:   5,
+		},
+		{
+			Name:              "doesn't retry an invalid change batch",
+			Domain:            "apps.example.com",
+			DeleteErr:         awserr.New(route53.ErrCodeInvalidChangeBatch, "Invalid Resource Record: FATAL problem", nil),
+			ExpectedListCalls: 1,
+			ExpectedDeletes:   1,
+			ExpectError:       true,
+		},
+		{
+			Name:   "ignores a record set deleted meanwhile",
+			Domain: "apps.example.com",
+			DeleteErr: awserr.NewBatchError(route53.ErrCodeInvalidChangeBatch, "ChangeBatch errors occurred", []error{
+				awserr.New(route53.ErrCodeInvalidChangeBatch, "Tried to delete resource record set [name='_acme-challenge.apps.example.com.', type='TXT'] but it was not found", nil),
+			}),
+			ExpectedListCalls: 1,
+			ExpectedDeletes:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			fastChallengeRecordDeleteRetries(t)
+			testClient := &pagedRecordSetsClient{recordSets: recordSets, pageSize: 2, deleteErr: test.DeleteErr, deleteFailures: test.DeleteFailures}
+			r53 := &awsClient{client: testClient}
+
+			err := r53.deleteAcmeChallengeResourceRecord(logr.Discard(), hostedZone, test.Domain, nil)
+			if test.ExpectError == (err == nil) {
+				t.Errorf("deleteAcmeChallengeResourceRecord() %s: ExpectError: %t, actual error: %v", test.Name, test.ExpectError, err)
+			}
+			if testClient.listCalls != test.ExpectedListCalls {
+				t.Errorf("deleteAcmeChallengeResourceRecord() %s: expected %d pages listed, got %d", test.Name, test.ExpectedListCalls, testClient.listCalls)
+			}
+			if test.ExpectedDeletes == 0 {
+				test.ExpectedDeletes = test.ExpectedDeleted
+			}
+			if testClient.deleteCalls != test.ExpectedDeletes {
+				t.Errorf("deleteAcmeChallengeResourceRecord() %s: expected %d deletions sent, got %d", test.Name, test.ExpectedDeletes, testClient.deleteCalls)
+			}
+			if len(testClient.deleted) != test.ExpectedDeleted {
+				t.Errorf("deleteAcmeChallengeResourceRecord() %s: expected %d record sets deleted, got %d", test.Name, test.ExpectedDeleted, len(testClient.deleted))
+			}
+			for _, recordSet := range testClient.deleted {
+				if *recordSet.Name != "_acme-challenge."+test.Domain+"." {
+					t.Errorf("deleteAcmeChallengeResourceRecord() %s: deleted the record set %s", test.Name, *recordSet.Name)
+				}
+			}
+		})
+	}
+}
+
+// TestRecordSetNotFound tests recordSetNotFound against the errors the SDK unmarshals from the
+// InvalidChangeBatch responses of Route53.
+func TestRecordSetNotFound(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Status   int
+		Body     string
+		NotFound bool
+	}{
+		{
+			Name:     "record set already deleted",
+			Status:   http.StatusBadRequest,
+			Body:     `<InvalidChangeBatch xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><Messages><Message>Tried to delete resource record set [name='_acme-challenge.apps.example.com.', type='TXT'] but it was not found</Message></Messages><RequestId>request</RequestId></InvalidChangeBatch>`,
+			NotFound: true,
+		},
+		{
+			Name:   "record set not matching",
+			Status: http.StatusBadRequest,
+			Body:   `<InvalidChangeBatch xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><Messages><Message>Tried to delete resource record set [name='_acme-challenge.apps.example.com.', type='TXT'] but the values provided do not match the current values</Message></Messages><RequestId>request</RequestId></InvalidChangeBatch>`,
+		},
+		{
+			Name:   "missing hosted zone",
+			Status: http.StatusNotFound,
+			Body:   `<ErrorResponse xmlns="https://route53.amazonaws.com/doc/2013-04-01/"><Error><Type>Sender</Type><Code>NoSuchHostedZone</Code><Message>No hosted zone found with ID: Z0123456789, the record set was not found</Message></Error><RequestId>request</RequestId></ErrorResponse>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/xml")
+				w.WriteHeader(test.Status)
+				_, _ = w.Write([]byte(test.Body))
+			}))
+			defer server.Close()
+
+			sess, err := session.NewSession(&aws.Config{
+				Endpoint:    aws.String(server.URL),
+				Region:      aws.String("us-east-1"),
+				Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+				MaxRetries:  aws.Int(0),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = route53.New(sess).ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+				HostedZoneId: aws.String("Z0123456789"),
+				ChangeBatch: &route53.ChangeBatch{
+					Changes: []*route53.Change{
+						{
+							Action: aws.String(route53.ChangeActionDelete),
+							ResourceRecordSet: &route53.ResourceRecordSet{
+								Name:            aws.String("_acme-challenge.apps.example.com."),
+								Type:            aws.String(route53.RRTypeTxt),
+								TTL:             aws.Int64(60),
+								ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(`"token"`)}},
+							},
+						},
+					},
+				},
+			})
+			if err == nil {
+				t.Fatalf("ChangeResourceRecordSets() %s: expected an error", test.Name)
+			}
+			if recordSetNotFound(err) != test.NotFound {
+				t.Errorf("recordSetNotFound() %s: expected %t for %v", test.Name, test.NotFound, err)
+			}
+		})
+	}
+}
+
+func TestZoneRecordChanges(t *testing.T) {
+	changes := zoneRecordChanges(route53.ChangeActionUpsert, "cluster.example.com", "fake-cluster-id")
+	if len(changes) != 2 {
+		t.Fatalf("zoneRecordChanges(): expected 2 changes, got %d", len(changes))
+	}
+
+	expected := map[string]string{
+		route53.RRTypeCaa: "0 issue \"letsencrypt.org\"",
+		route53.RRTypeTxt: "\"certman-managed=fake-cluster-id\"",
+	}
+	for _, change := range changes {
+		set := change.ResourceRecordSet
+		if *set.Name != "cluster.example.com." {
+			t.Errorf("zoneRecordChanges(): expected name cluster.example.com., got %s", *set.Name)
+		}
+		if value := *set.ResourceRecords[0].Value; value != expected[*set.Type] {
+			t.Errorf("zoneRecordChanges(): expected %s record %s, got %s", *set.Type, expected[*set.Type], value)
+		}
+	}
+}
+
+func TestDeleteZoneRecordsZoneNotFound(t *testing.T) {
+	tests := []struct {
+		Name              string
+		DeleteErr         error
+		ExpectZoneMissing bool
+	}{
+		{
+			Name:              "hosted zone deleted",
+			DeleteErr:         awserr.New(route53.ErrCodeNoSuchHostedZone, "No hosted zone found with ID: Z0123456789", nil),
+			ExpectZoneMissing: true,
+		},
+		{
+			Name:      "other error",
+			DeleteErr: awserr.New("Throttling", "Rate exceeded", nil),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			r53 := &awsClient{client: &pagedRecordSetsClient{deleteErr: test.DeleteErr}}
+
+			err := r53.DeleteZoneRecords(logr.Discard(), certRequest, "Z0123456789", "fake-cluster-id")
+			if err == nil {
+				t.Fatal("DeleteZoneRecords(): expected an error")
+			}
+			if errors.Is(err, cTypes.ErrZoneNotFound) != test.ExpectZoneMissing {
+				t.Errorf("DeleteZoneRecords(): expected the zone to be reported missing: %t, got %s", test.ExpectZoneMissing, err)
+			}
+		})
+	}
+}
+
+func TestValidateFedrampHostedZone(t *testing.T) {
+	tests := []struct {
+		Name          string
+		TestClient    *mockroute53.MockRoute53Client
+		HostedZoneID  string
+		UnreachableNS bool
+		ExpectError   bool
+	}{
+		{
+			Name:         "validates a public, reachable and writable zone",
+			TestClient:   &mockroute53.MockRoute53Client{NameServers: []string{"ns-1.example.com"}},
+			HostedZoneID: testDnsZoneID,
+		},
+		{
+			Name:         "fails when the hosted zone ID is unset",
+			TestClient:   &mockroute53.MockRoute53Client{NameServers: []string{"ns-1.example.com"}},
+			HostedZoneID: "",
+			ExpectError:  true,
+		},
+		{
+			Name:         "fails when the zone has no nameservers",
+			TestClient:   &mockroute53.MockRoute53Client{},
+			HostedZoneID: testDnsZoneID,
+			ExpectError:  true,
+		},
+		{
+			Name:          "fails when a nameserver is unreachable",
+			TestClient:    &mockroute53.MockRoute53Client{NameServers: []string{"ns-1.example.com"}},
+			HostedZoneID:  testDnsZoneID,
+			UnreachableNS: true,
+			ExpectError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			lookupTXT = func(ns string, fqdn string) ([]string, error) {
+				if test.UnreachableNS {
+					return nil, fmt.Errorf("dial udp %s: i/o timeout", ns)
+				}
+				return []string{}, nil
+			}
+			defer func() { lookupTXT = nameserver.LookupTXT }()
+
+			r53 := &awsClient{
+				client: test.TestClient,
+			}
+
+			err := r53.ValidateFedrampHostedZone(logr.Discard(), test.HostedZoneID)
+			if test.ExpectError == (err == nil) {
+				t.Errorf("ValidateFedrampHostedZone() %s: ExpectError: %t, actual error: %s\n", test.Name, test.ExpectError, err)
+			}
+		})
+	}
+}
+
+func TestGetAuthoritativeZone(t *testing.T) {
+	publicZone := func(id string, name string) *route53.HostedZone {
+		return &route53.HostedZone{Id: aws.String(id), Name: aws.String(name), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(false)}}
+	}
+	privateZone := func(id string, name string) *route53.HostedZone {
+		return &route53.HostedZone{Id: aws.String(id), Name: aws.String(name), Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)}}
+	}
+
+	tests := []struct {
+		Name             string
+		ClusterZones     []*route53.HostedZone
+		DelegateZones    []*route53.HostedZone
+		ExpectedZone     string
+		ExpectedDelegate bool
+	}{
+		{
+			Name:         "finds the cluster zone in the cluster account",
+			ClusterZones: []*route53.HostedZone{publicZone("/hostedzone/id1", "cluster.example.com.")},
+			ExpectedZone: "id1",
+		},
+		{
+			Name:             "finds a subdomain delegated to another account",
+			ClusterZones:     []*route53.HostedZone{publicZone("/hostedzone/id1", "cluster.example.com.")},
+			DelegateZones:    []*route53.HostedZone{publicZone("/hostedzone/id2", "apps.cluster.example.com.")},
+			ExpectedZone:     "id2",
+			ExpectedDelegate: true,
+		},
+		{
+			Name:         "skips private zones",
+			ClusterZones: []*route53.HostedZone{privateZone("/hostedzone/id3", "apps.cluster.example.com."), publicZone("/hostedzone/id1", "cluster.example.com.")},
+			ExpectedZone: "id1",
+		},
+		{
+			Name:         "falls back to the dns zone when no zone is found",
+			ExpectedZone: "id0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			clusterClient := &mockroute53.MockRoute53Client{NamedZones: test.ClusterZones}
+			delegateClient := &mockroute53.MockRoute53Client{NamedZones: test.DelegateZones}
+			r53 := &awsClient{
+				client:          clusterClient,
+				delegateClients: []route53iface.Route53API{delegateClient},
+			}
+
+			zone, err := r53.GetAuthoritativeZone(logr.Discard(), "_acme-challenge.apps.cluster.example.com", certRequest, "id0")
+			if err != nil {
+				t.Fatalf("GetAuthoritativeZone() %s: unexpected error: %s\n", test.Name, err)
+			}
+			if zone != test.ExpectedZone {
+				t.Errorf("GetAuthoritativeZone() %s: expected zone %s, got %s\n", test.Name, test.ExpectedZone, zone)
+			}
+			if test.ExpectedDelegate && r53.zoneClient(zone) != delegateClient {
+				t.Errorf("GetAuthoritativeZone() %s: expected zone %s to use the delegated account\n", test.Name, zone)
+			}
+		})
+	}
+}
+
 // helpers
 var testHiveName = "doesntexist"
 var testHiveNamespace = "uhc-doesntexist-123456"
@@ -0,0 +1,46 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns" //nolint
+
+	"github.com/openshift/certman-operator/pkg/clients/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Provider{
+		NewClient: func(t *testing.T, backend *conformance.Backend) conformance.Client {
+			server := conformance.NewAzureDNSServer(t, backend)
+			// a single attempt: the SDK sends no request at all with no attempts, and the fake
+			// answers conflicts that aren't about resource provider registration
+			recordSetsClient := dns.NewRecordSetsClientWithBaseURI(server.URL, "subscription")
+			recordSetsClient.RetryAttempts = 1
+			recordSetsClient.SkipResourceProviderRegistration = true
+			zonesClient := dns.NewZonesClientWithBaseURI(server.URL, "subscription")
+			zonesClient.RetryAttempts = 1
+			zonesClient.SkipResourceProviderRegistration = true
+			return &azureClient{
+				resourceGroupName: "conformance",
+				recordSetsClient:  &recordSetsClient,
+				zonesClient:       &zonesClient,
+			}
+		},
+	})
+}
@@ -20,9 +20,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/services/dns/mgmt/2018-05-01/dns" //nolint
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/v7.0/keyvault"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/Azure/go-autorest/autorest/to"
@@ -33,25 +37,44 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/chaos"
+	"github.com/openshift/certman-operator/pkg/clients/credentialhealth"
+	"github.com/openshift/certman-operator/pkg/clients/nameserver"
+	"github.com/openshift/certman-operator/pkg/clients/quota"
 	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/clients/zonecache"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
 )
 
 const (
 	resourceRecordTTL = 60
 	azureCredsSPKey   = "osServicePrincipal.json" //nolint:gosec // not a hard-coded credential
+	// zoneApexRecordName is the relative name of the records at the apex of a DNS zone
+	zoneApexRecordName = "@"
 )
 
+// dnsZones caches the DNS zones of each resource group across the clients built by the reconciles
+var dnsZones = zonecache.New[dns.Zone](quota.ProviderAzure, zonecache.DefaultTTL)
+
 // client implements the Client interface
 type azureClient struct {
 	resourceGroupName string
 	recordSetsClient  *dns.RecordSetsClient
 	zonesClient       *dns.ZonesClient
+	// zones caches the DNS zones of the resource group, nil lists them on every lookup
+	zones *zonecache.Cache[dns.Zone]
+	// metadata tags the record sets written for CertificateRequests
+	metadata cTypes.ChangeMetadata
 }
 
-func (c *azureClient) createTxtRecord(reqLogger logr.Logger, recordKey string, recordValue string, zoneName string) (result dns.RecordSet, err error) {
+// createTxtRecord writes the TXT record set recordKey holding recordValue to the zone zoneName,
+// tagged for cr.
+func (c *azureClient) createTxtRecord(reqLogger logr.Logger, recordKey string, recordValue string, zoneName string, cr *certmanv1alpha1.CertificateRequest) (result dns.RecordSet, err error) {
 	recordSetProperties := &dns.RecordSet{
 		RecordSetProperties: &dns.RecordSetProperties{
-			TTL: to.Int64Ptr(resourceRecordTTL),
+			Metadata: *to.StringMapPtr(c.metadata.Tags(cr)),
+			TTL:      to.Int64Ptr(resourceRecordTTL),
 			TxtRecords: &[]dns.TxtRecord{
 				{
 					Value: &[]string{
@@ -65,6 +88,23 @@ func (c *azureClient) createTxtRecord(reqLogger logr.Logger, recordKey string, r
 	return c.recordSetsClient.CreateOrUpdate(context.TODO(), c.resourceGroupName, zoneName, recordKey, dns.TXT, *recordSetProperties, "", "")
 }
 
+// hasTxtRecord returns true when the TXT record set recordKey of the zone zoneName holds nothing but
+// recordValue.
+func (c *azureClient) hasTxtRecord(recordKey string, recordValue string, zoneName string) (bool, error) {
+	existing, err := c.recordSetsClient.Get(context.TODO(), c.resourceGroupName, zoneName, recordKey, dns.TXT)
+	if existing.Response.Response != nil && existing.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if existing.RecordSetProperties == nil || existing.TxtRecords == nil || len(*existing.TxtRecords) != 1 {
+		return false, nil
+	}
+	value := (*existing.TxtRecords)[0].Value
+	return value != nil && strings.Join(*value, "") == recordValue, nil
+}
+
 func (c *azureClient) generateTxtRecordName(domain string, rootDomain string) string {
 	// Remove base domain
 	domain = strings.TrimSuffix(domain, rootDomain)
@@ -90,14 +130,24 @@ func (c *azureClient) GetFedrampHostedZoneIDPath(_ string) (string, error) {
 }
 
 func (c *azureClient) AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeToken string, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (fqdn string, err error) {
-	zone, err := c.zonesClient.Get(context.TODO(), c.resourceGroupName, cr.Spec.ACMEDNSDomain)
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
 	if err != nil {
 		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
 		return "", err
 	}
 
 	txtRecordName := c.generateTxtRecordName(domain, *zone.Name)
-	_, err = c.createTxtRecord(reqLogger, txtRecordName, acmeChallengeToken, *zone.Name)
+	answered, err := c.hasTxtRecord(txtRecordName, acmeChallengeToken, *zone.Name)
+	if err != nil {
+		reqLogger.Error(err, "Error reading acme challenge DNS entry")
+		return "", err
+	}
+	if answered {
+		reqLogger.Info(fmt.Sprintf("record set %v in DNS Zone %v already holds the challenge token", txtRecordName, *zone.Name))
+		return txtRecordName + "." + cr.Spec.ACMEDNSDomain, nil
+	}
+
+	_, err = c.createTxtRecord(reqLogger, txtRecordName, acmeChallengeToken, *zone.Name, cr)
 
 	if err != nil {
 		reqLogger.Error(err, "Error adding acme challenge DNS entry")
@@ -110,7 +160,7 @@ func (c *azureClient) AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeTok
 }
 
 func (c *azureClient) DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error {
-	zone, err := c.zonesClient.Get(context.TODO(), c.resourceGroupName, cr.Spec.ACMEDNSDomain)
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
 	if err != nil {
 		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
 		return err
@@ -131,25 +181,132 @@ func (c *azureClient) DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger,
 	return nil
 }
 
+// DeleteAcmeChallengeResourceRecord removes the ACME challenge record set of a single domain from the DNS zone.
+func (c *azureClient) DeleteAcmeChallengeResourceRecord(reqLogger logr.Logger, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) error {
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
+		return err
+	}
+
+	txtRecordName := c.generateTxtRecordName(domain, *zone.Name)
+
+	reqLogger.Info(fmt.Sprintf("Deleting record set %v in DNS ZONE: %v", txtRecordName, *zone.Name))
+	_, err = c.recordSetsClient.Delete(context.TODO(), c.resourceGroupName, *zone.Name, txtRecordName, dns.TXT, "")
+
+	return err
+}
+
+// VerifyRecordVisible checks that the nameservers of the DNS zone serve value for fqdn.
+func (c *azureClient) VerifyRecordVisible(reqLogger logr.Logger, fqdn string, value string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (bool, error) {
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
+		return false, err
+	}
+
+	if zone.ZoneProperties == nil || zone.ZoneProperties.NameServers == nil {
+		return false, fmt.Errorf("dns zone %v has no nameservers", cr.Spec.ACMEDNSDomain)
+	}
+
+	return nameserver.RecordVisible(reqLogger, *zone.ZoneProperties.NameServers, fqdn, value)
+}
+
+// GetAuthoritativeZone returns dnsZone, delegation of subdomains to other subscriptions isn't supported.
+func (c *azureClient) GetAuthoritativeZone(reqLogger logr.Logger, fqdn string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error) {
+	return dnsZone, nil
+}
+
+// GetZoneNameservers returns the nameservers of the DNS zone of cr.
+func (c *azureClient) GetZoneNameservers(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string) ([]string, error) {
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
+		return nil, err
+	}
+
+	if zone.ZoneProperties == nil || zone.ZoneProperties.NameServers == nil {
+		return []string{}, nil
+	}
+
+	return *zone.ZoneProperties.NameServers, nil
+}
+
+// EnsureZoneRecords writes the CAA record allowing Let's Encrypt to issue certificates and the
+// ownership TXT record of clusterID at the apex of the DNS zone of cr.
+func (c *azureClient) EnsureZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
+		return err
+	}
+
+	reqLogger.Info(fmt.Sprintf("writing CAA and ownership records to DNS Zone: %v", *zone.Name))
+	caaRecordSet := dns.RecordSet{
+		RecordSetProperties: &dns.RecordSetProperties{
+			Metadata: *to.StringMapPtr(c.metadata.Tags(cr)),
+			TTL:      to.Int64Ptr(resourceRecordTTL),
+			CaaRecords: &[]dns.CaaRecord{
+				{
+					Flags: to.Int32Ptr(0),
+					Tag:   to.StringPtr("issue"),
+					Value: to.StringPtr(cTypes.CAAIssuer),
+				},
+			},
+		},
+	}
+	_, err = c.recordSetsClient.CreateOrUpdate(context.TODO(), c.resourceGroupName, *zone.Name, zoneApexRecordName, dns.CAA, caaRecordSet, "", "")
+	if err != nil {
+		return err
+	}
+
+	_, err = c.createTxtRecord(reqLogger, zoneApexRecordName, cTypes.OwnershipRecordPrefix+clusterID, *zone.Name, cr)
+	return err
+}
+
+// DeleteZoneRecords removes the records written by EnsureZoneRecords.
+func (c *azureClient) DeleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
+		return err
+	}
+
+	reqLogger.Info(fmt.Sprintf("deleting CAA and ownership records from DNS Zone: %v", *zone.Name))
+	for _, recordType := range []dns.RecordType{dns.CAA, dns.TXT} {
+		_, err = c.recordSetsClient.Delete(context.TODO(), c.resourceGroupName, *zone.Name, zoneApexRecordName, recordType, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ValidateDnsWriteAccess spawns a zones client to retrieve the baseDomain's hostedZoneOutput
 // and attempts to write a test TXT ResourceRecord to it. If successful, will return `true, nil`.
 func (c *azureClient) ValidateDNSWriteAccess(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (bool, error) {
 
-	zone, err := c.zonesClient.Get(context.TODO(), c.resourceGroupName, cr.Spec.ACMEDNSDomain)
+	zone, err := c.getZone(cr.Spec.ACMEDNSDomain)
 
 	if err != nil {
 		reqLogger.Error(err, fmt.Sprintf("Error getting dns zone %v", cr.Spec.ACMEDNSDomain))
 		return false, err
 	}
 
-	recordKey := "_certman_access_test." + *zone.Name
+	// the record name is relative to the zone
+	recordKey := cTypes.WriteValidationSubDomain
 
 	if zone.ZoneType == "Private" {
 		reqLogger.Error(err, "Private DNS zone is not allowed")
 		return false, nil
 	}
+
+	if zone.ZoneProperties != nil && zone.NumberOfRecordSets != nil && zone.MaxNumberOfRecordSets != nil {
+		quota.RecordRecordSetUsage(quota.ProviderAzure, *zone.Name, *zone.NumberOfRecordSets, *zone.MaxNumberOfRecordSets)
+	}
 	// Build the test record
-	_, err = c.createTxtRecord(reqLogger, recordKey, "\"txt_entry\"", *zone.Name)
+	_, err = c.createTxtRecord(reqLogger, recordKey, "\"txt_entry\"", *zone.Name, cr)
 
 	if err != nil {
 		return false, err
@@ -197,8 +354,110 @@ func getAzureCredentialsFromSecret(secret corev1.Secret) (clientID string, clien
 	return clientID, clientSecret, tenantID, subscriptionID, nil
 }
 
-// NewClient returns new Azure DNS client
-func NewClient(kubeClient client.Client, secretName string, namespace string, resourceGroupName string) (*azureClient, error) {
+// getZone returns the DNS zone of the resource group named name.
+func (c *azureClient) getZone(name string) (dns.Zone, error) {
+	name = strings.TrimSuffix(name, ".")
+	zone, ok, err := c.zones.Find(c.zonesClient.SubscriptionID+"/"+c.resourceGroupName, func() ([]dns.Zone, error) {
+		return listAllZones(c.zonesClient, c.resourceGroupName)
+	}, func(zone dns.Zone) bool {
+		return zone.Name != nil && strings.EqualFold(strings.TrimSuffix(*zone.Name, "."), name)
+	})
+	if err != nil {
+		return dns.Zone{}, err
+	}
+	if !ok {
+		return dns.Zone{}, fmt.Errorf("unable to find DNS zone %s in resource group %s", name, c.resourceGroupName)
+	}
+	return zone, nil
+}
+
+// listAllZones returns the DNS zones of resourceGroupName, following the pages of the listing
+func listAllZones(zonesClient *dns.ZonesClient, resourceGroupName string) ([]dns.Zone, error) {
+	ctx := context.TODO()
+	page, err := zonesClient.ListByResourceGroup(ctx, resourceGroupName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var zones []dns.Zone
+	for page.NotDone() {
+		localmetrics.IncrementDNSZoneListPageCount(quota.ProviderAzure)
+		zones = append(zones, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return zones, nil
+}
+
+// NewClient returns new Azure DNS client of the subscription of secretName in cloud, see Environment.
+func NewClient(kubeClient client.Client, secretName string, namespace string, resourceGroupName string, cloud string) (*azureClient, error) {
+	env, err := Environment(kubeClient, cloud)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizer, subscriptionID, err := newAuthorizer(kubeClient, secretName, namespace, env, env.ResourceManagerEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	recordSetsClient := dns.NewRecordSetsClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID)
+	recordSetsClient.Authorizer = authorizer
+	recordSetsClient.Sender = newSender()
+
+	zonesClient := dns.NewZonesClientWithBaseURI(env.ResourceManagerEndpoint, subscriptionID)
+	zonesClient.Authorizer = authorizer
+	zonesClient.Sender = newSender()
+
+	changeMetadata, err := utils.GetDNSChangeMetadata(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureClient{
+		resourceGroupName: resourceGroupName,
+		recordSetsClient:  &recordSetsClient,
+		zonesClient:       &zonesClient,
+		zones:             dnsZones,
+		metadata:          cTypes.NewChangeMetadata(changeMetadata),
+	}, nil
+}
+
+// NewKeyVaultClient returns a client of the key vaults of env authorized with the service principal
+// of the secretName secret.
+func NewKeyVaultClient(kubeClient client.Client, secretName string, namespace string, env azure.Environment) (*keyvault.BaseClient, error) {
+	authorizer, _, err := newAuthorizer(kubeClient, secretName, namespace, env, env.ResourceIdentifiers.KeyVault)
+	if err != nil {
+		return nil, err
+	}
+
+	keyVaultClient := keyvault.New()
+	keyVaultClient.Authorizer = authorizer
+	keyVaultClient.Sender = newSender()
+	return &keyVaultClient, nil
+}
+
+// Environment returns the Azure environment of cloud, such as AzureUSGovernmentCloud. When cloud is
+// empty, the azureCloud platform default of the operator configuration is used, and the public
+// cloud when that isn't set either.
+func Environment(kubeClient client.Client, cloud string) (azure.Environment, error) {
+	if cloud == "" {
+		platformDefaults, err := utils.GetPlatformDefaults(kubeClient)
+		if err != nil {
+			return azure.Environment{}, err
+		}
+		cloud = platformDefaults.AzureCloud
+	}
+	if cloud == "" {
+		return azure.PublicCloud, nil
+	}
+	return azure.EnvironmentFromName(cloud)
+}
+
+// newAuthorizer returns the authorizer of requests to resource of env with the service principal
+// of the secretName secret, along with the subscription of the service principal.
+func newAuthorizer(kubeClient client.Client, secretName string, namespace string, env azure.Environment, resource string) (autorest.Authorizer, string, error) {
 	secret := &corev1.Secret{}
 
 	err := kubeClient.Get(context.TODO(),
@@ -209,31 +468,53 @@ func NewClient(kubeClient client.Client, secretName string, namespace string, re
 		secret)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	clientID, clientSecret, tenantID, subscriptionID, err := getAzureCredentialsFromSecret(*secret)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	config := auth.NewClientCredentialsConfig(clientID, clientSecret, tenantID)
+	config.AADEndpoint = env.ActiveDirectoryEndpoint
+	config.Resource = resource
 
-	authorizer, err := config.Authorizer()
+	token, err := config.ServicePrincipalToken()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	// the token is refreshed before the first request and before it expires
+	token.SetRefreshCallbacks([]adal.TokenRefreshCallback{func(t adal.Token) error {
+		credentialhealth.RecordAcquired(quota.ProviderAzure, t.Expires())
+		return nil
+	}})
+	return credentialRecordingAuthorizer{autorest.NewBearerAuthorizer(token)}, subscriptionID, nil
+}
 
-	recordSetsClient := dns.NewRecordSetsClientWithBaseURI(azure.PublicCloud.ResourceManagerEndpoint, subscriptionID)
-	recordSetsClient.Authorizer = authorizer
+// newSender returns the sender of the requests of a client. Every attempt, retries included, is
+// sent through the transport recording throttled requests.
+func newSender() autorest.Sender {
+	return &http.Client{Transport: quota.NewTransport(quota.ProviderAzure, chaos.Transport(quota.ProviderAzure, http.DefaultTransport))}
+}
 
-	zonesClient := dns.NewZonesClientWithBaseURI(azure.PublicCloud.ResourceManagerEndpoint, subscriptionID)
-	zonesClient.Authorizer = authorizer
+// credentialRecordingAuthorizer authorizes requests like its Authorizer and records the failures
+// to do so, which are failures to refresh the access token of the service principal, such as
+// once its client secret expired.
+type credentialRecordingAuthorizer struct {
+	autorest.Authorizer
+}
 
-	return &azureClient{
-		resourceGroupName: resourceGroupName,
-		recordSetsClient:  &recordSetsClient,
-		zonesClient:       &zonesClient,
-	}, nil
+func (a credentialRecordingAuthorizer) WithAuthorization() autorest.PrepareDecorator {
+	authorize := a.Authorizer.WithAuthorization()
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			r, err := authorize(p).Prepare(r)
+			if err != nil {
+				credentialhealth.RecordFailure(quota.ProviderAzure, err)
+			}
+			return r, err
+		})
+	}
 }
@@ -53,7 +53,7 @@ func TestNewClient(t *testing.T) {
 		t.Run(tt.description, func(t *testing.T) {
 			testClient := setUpTestClient(t, tt.secret)
 
-			client, err := NewClient(testClient, testHiveAzureSecretName, testHiveNamespace, testHiveResourceGroupName)
+			client, err := NewClient(testClient, testHiveAzureSecretName, testHiveNamespace, testHiveResourceGroupName, "")
 
 			if tt.wantError {
 				if err == nil || tt.err.Error() != err.Error() {
@@ -1,22 +1,26 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/utils"
 	"github.com/openshift/certman-operator/pkg/clients/aws"
 	"github.com/openshift/certman-operator/pkg/clients/azure"
 	"github.com/openshift/certman-operator/pkg/clients/gcp"
 	mockclient "github.com/openshift/certman-operator/pkg/clients/mock"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
 )
 
-var (
-	log logr.Logger = logf.Log.WithName("client")
-)
+// ErrProviderDisabled is returned instead of the client of a platform disabled by the
+// DisableProvider feature gate.
+var ErrProviderDisabled = errors.New("the DNS provider is disabled by the " + cTypes.DisableProvider + " feature gate")
 
 // Client is a wrapper object for actual AWS SDK clients to allow for easier testing.
 type Client interface {
@@ -26,28 +30,48 @@ type Client interface {
 	AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeToken string, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error)
 	ValidateDNSWriteAccess(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (bool, error)
 	DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error
+	DeleteAcmeChallengeResourceRecord(reqLogger logr.Logger, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) error
+	VerifyRecordVisible(reqLogger logr.Logger, fqdn string, value string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (bool, error)
+	EnsureZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error
+	DeleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error
+	GetAuthoritativeZone(reqLogger logr.Logger, fqdn string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error)
+	GetZoneNameservers(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string) ([]string, error)
+}
+
+// Builder builds the clients of the DNS providers with the settings of the environment the
+// operator runs in.
+type Builder struct {
+	// Fedramp has the AWS clients write to the FedRAMP hosted zone when enabled
+	Fedramp cTypes.Fedramp
 }
 
 // NewClient returns an individual cloud implementation based on CertificateRequest cloud coniguration
-func NewClient(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (Client, error) {
-	// TODO: Add multicloud checking here
-	if platform.AWS != nil {
-		log.Info("build aws client")
-		return aws.NewClient(reqLogger, kubeClient, platform.AWS.Credentials.Name, namespace, platform.AWS.Region, clusterDeploymentName)
-	}
-	if platform.GCP != nil {
-		log.Info("build gcp client")
-		// TODO: Add project as configurable
-		return gcp.NewClient(kubeClient, platform.GCP.Credentials.Name, namespace)
+func (b Builder) NewClient(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string) (Client, error) {
+	if err := platform.Validate(); err != nil {
+		return nil, fmt.Errorf("Platform not supported: %w", err)
 	}
-	if platform.Azure != nil {
-		log.Info("Build Azure client")
-		return azure.NewClient(kubeClient, platform.Azure.Credentials.Name, namespace, platform.Azure.ResourceGroupName)
+
+	if utils.GetFeatureGates(kubeClient).ProviderDisabled(platform) {
+		localmetrics.IncrementFeatureGateSkipCount(cTypes.DisableProvider)
+		return nil, ErrProviderDisabled
 	}
-	// NOTE this allows a mock client to be created from a Mock platform secret defined in the platform
-	// this allows for better testing of controllers but should be avoided in a live system for obvious reasons
-	if platform.Mock != nil {
-		log.Info("Build Mock client")
+
+	reqLogger = logging.WithProvider(reqLogger, platform)
+	switch platform.Provider() {
+	case certmanv1alpha1.PlatformProviderAWS:
+		reqLogger.Info("build aws client")
+		return aws.NewClient(reqLogger, kubeClient, platform.AWS.Credentials.Name, namespace, platform.AWS.Region, platform.AWS.Partition, clusterDeploymentName, b.Fedramp)
+	case certmanv1alpha1.PlatformProviderGCP:
+		reqLogger.Info("build gcp client")
+		// TODO: Add project as configurable
+		return gcp.NewClient(kubeClient, platform.GCP.Credentials.Name, namespace, clusterDeploymentName)
+	case certmanv1alpha1.PlatformProviderAzure:
+		reqLogger.Info("Build Azure client")
+		return azure.NewClient(kubeClient, platform.Azure.Credentials.Name, namespace, platform.Azure.ResourceGroupName, platform.Azure.Cloud)
+	case certmanv1alpha1.PlatformProviderMock:
+		// NOTE this allows a mock client to be created from a Mock platform secret defined in the platform
+		// this allows for better testing of controllers but should be avoided in a live system for obvious reasons
+		reqLogger.Info("Build Mock client")
 		opts := &mockclient.MockClientOptions{}
 		opts.AnswerDNSChallengeFQDN = platform.Mock.AnswerDNSChallengeFQDN
 		opts.AnswerDNSChallengeErrorString = platform.Mock.AnswerDNSChallengeErrorString
@@ -67,6 +67,20 @@ func TestNewClient(t *testing.T) {
 			ClusterDeployment: testClusterDeployment,
 			ExpectError:       false,
 		},
+		{
+			Name: "error on multiple platforms",
+			Platform: certmanv1alpha1.Platform{
+				AWS: &certmanv1alpha1.AWSPlatformSecrets{},
+				GCP: &certmanv1alpha1.GCPPlatformSecrets{
+					Credentials: corev1.LocalObjectReference{
+						Name: "gcp",
+					},
+				},
+			},
+			ClusterDeployment:   testClusterDeployment,
+			ExpectError:         true,
+			ExpectedErrorString: "Platform not supported: more than one platform is set: aws, gcp",
+		},
 		{
 			Name:                "error on unsupported platform",
 			ClusterDeployment:   &hivev1.ClusterDeployment{},
@@ -80,7 +94,7 @@ func TestNewClient(t *testing.T) {
 			s := scheme.Scheme
 			s.AddKnownTypes(hivev1.SchemeGroupVersion, &hivev1.ClusterDeployment{})
 
-			actualClient, err := NewClient(logr.Discard(), fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(test.ClusterDeployment, &testGCPPlatformSecret, &testAzurePlatformSecret).Build(), test.Platform, test.ClusterDeployment.ObjectMeta.Namespace, test.ClusterDeployment.ObjectMeta.Name)
+			actualClient, err := Builder{}.NewClient(logr.Discard(), fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(test.ClusterDeployment, &testGCPPlatformSecret, &testAzurePlatformSecret).Build(), test.Platform, test.ClusterDeployment.ObjectMeta.Namespace, test.ClusterDeployment.ObjectMeta.Name)
 			if err != nil {
 				if !test.ExpectError {
 					t.Errorf("NewClient() %s: got unexpected error \"%s\"\n", test.Name, err)
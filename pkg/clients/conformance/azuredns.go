@@ -0,0 +1,198 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// azureRecordSetLimit is the default maximum number of record sets of a DNS zone
+const azureRecordSetLimit = 10000
+
+type azureRecordSet struct {
+	ID         string                   `json:"id"`
+	Name       string                   `json:"name"`
+	Type       string                   `json:"type"`
+	Properties azureRecordSetProperties `json:"properties"`
+}
+
+type azureRecordSetProperties struct {
+	TTL        int64            `json:"TTL"`
+	FQDN       string           `json:"fqdn,omitempty"`
+	TXTRecords []azureTXTRecord `json:"TXTRecords,omitempty"`
+}
+
+type azureTXTRecord struct {
+	Value []string `json:"value"`
+}
+
+// NewAzureDNSServer returns a server answering the Azure DNS API calls of the Azure client with the
+// zones of backend, which are listed by resource group and looked up by name. It is closed when
+// the test ends.
+func NewAzureDNSServer(t *testing.T, backend *Backend) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveAzureDNS(w, r, backend)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serveAzureDNS(w http.ResponseWriter, r *http.Request, backend *Backend) {
+	// .../providers/Microsoft.Network/dnsZones lists the zones of the resource group
+	if strings.HasSuffix(r.URL.Path, "/dnsZones") && r.Method == http.MethodGet {
+		page, next := backend.zonesPage(r.URL.Query().Get("$skipToken"))
+		zones := []map[string]interface{}{}
+		for _, z := range page {
+			zones = append(zones, azureZone(backend, z, r.URL.Path+"/"+strings.TrimSuffix(z.name, ".")))
+		}
+		response := map[string]interface{}{"value": zones}
+		if next != "" {
+			query := r.URL.Query()
+			query.Set("$skipToken", next)
+			response["nextLink"] = "http://" + r.Host + r.URL.Path + "?" + query.Encode()
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	// .../providers/Microsoft.Network/dnsZones/{zone}[/{type}/{relative name}]
+	index := strings.Index(r.URL.Path, "dnsZones/")
+	if index == -1 {
+		writeAzureError(w, http.StatusBadRequest, "BadRequest", "unsupported request "+r.Method+" "+r.URL.Path)
+		return
+	}
+	resourceID := r.URL.Path[:index] + "dnsZones/"
+	parts := strings.Split(strings.Trim(r.URL.Path[index+len("dnsZones/"):], "/"), "/")
+
+	z := backend.zoneByName(parts[0])
+	if z == nil {
+		writeAzureError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("The Resource 'Microsoft.Network/dnszones/%s' was not found.", parts[0]))
+		return
+	}
+	zoneName := strings.TrimSuffix(z.name, ".")
+	resourceID += zoneName
+
+	if len(parts) == 1 && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, azureZone(backend, z, resourceID))
+		return
+	}
+
+	if len(parts) != 3 || parts[1] != "TXT" {
+		writeAzureError(w, http.StatusBadRequest, "BadRequest", "unsupported request "+r.Method+" "+r.URL.Path)
+		return
+	}
+
+	// the relative name of the records at the apex of a zone is @
+	name := parts[2] + "." + z.name
+	if parts[2] == "@" {
+		name = z.name
+	}
+	existing := backend.findRecord(z, name, "TXT")
+
+	switch r.Method {
+	case http.MethodGet:
+		if existing == nil {
+			writeAzureError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("The resource record '%s' does not exist in resource group.", parts[2]))
+			return
+		}
+		resource := azureRecordSet{
+			ID:         resourceID + "/TXT/" + parts[2],
+			Name:       parts[2],
+			Type:       "Microsoft.Network/dnszones/TXT",
+			Properties: azureRecordSetProperties{TTL: existing.ttl, FQDN: name},
+		}
+		for _, value := range existing.values {
+			resource.Properties.TXTRecords = append(resource.Properties.TXTRecords, azureTXTRecord{Value: []string{value}})
+		}
+		writeJSON(w, http.StatusOK, resource)
+
+	case http.MethodPut:
+		resource := azureRecordSet{}
+		if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+			writeAzureError(w, http.StatusBadRequest, "BadRequest", err.Error())
+			return
+		}
+		values := []string{}
+		for _, record := range resource.Properties.TXTRecords {
+			values = append(values, strings.Join(record.Value, ""))
+		}
+
+		// a PUT replaces the record set
+		if err := backend.change(z, nil, []*recordSet{{name: name, recordType: "TXT", ttl: resource.Properties.TTL, values: values}}, true); err != nil {
+			writeAzureError(w, http.StatusBadRequest, "BadRequest", err.Error())
+			return
+		}
+
+		resource.ID = resourceID + "/TXT/" + parts[2]
+		resource.Name = parts[2]
+		resource.Type = "Microsoft.Network/dnszones/TXT"
+		resource.Properties.FQDN = name
+		writeJSON(w, http.StatusOK, resource)
+
+	case http.MethodDelete:
+		// deleting a missing record set succeeds without content
+		if existing == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		err := backend.change(z, []*recordSet{existing}, nil, false)
+		switch {
+		case errors.Is(err, errNotFound):
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case err != nil:
+			writeAzureError(w, http.StatusConflict, "Conflict", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		writeAzureError(w, http.StatusBadRequest, "BadRequest", "unsupported request "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// azureZone returns the Azure DNS zone resource of z, whose ID is resourceID.
+func azureZone(backend *Backend, z *zone, resourceID string) map[string]interface{} {
+	zoneType := "Public"
+	if z.private {
+		zoneType = "Private"
+	}
+	return map[string]interface{}{
+		"id":       resourceID,
+		"name":     strings.TrimSuffix(z.name, "."),
+		"type":     "Microsoft.Network/dnszones",
+		"location": "global",
+		"properties": map[string]interface{}{
+			"nameServers":           z.nameservers,
+			"zoneType":              zoneType,
+			"numberOfRecordSets":    len(backend.sortedRecords(z)),
+			"maxNumberOfRecordSets": azureRecordSetLimit,
+		},
+	}
+}
+
+func writeAzureError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// zonesPageSize is the number of zones in a page of the zone listings of the fakes, small enough
+// for the clients to have to follow the pages
+const zonesPageSize = 1
+
+// Backend holds the DNS zones served by the fake provider APIs. Record names are stored fully
+// qualified, in lower case, and TXT values without the quotes some APIs require.
+type Backend struct {
+	mu     sync.Mutex
+	zones  []*zone
+	writes []string
+	// deletedMeanwhile are the records removed right before the next change is applied
+	deletedMeanwhile []recordKey
+}
+
+type zone struct {
+	id          string
+	name        string
+	private     bool
+	nameservers []string
+	records     map[recordKey]*recordSet
+}
+
+type recordKey struct {
+	name       string
+	recordType string
+}
+
+type recordSet struct {
+	name       string
+	recordType string
+	ttl        int64
+	values     []string
+}
+
+// NewBackend returns a Backend without zones.
+func NewBackend() *Backend {
+	return &Backend{}
+}
+
+// AddZone adds a zone named name and returns its ID.
+func (b *Backend) AddZone(name string, private bool) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	z := &zone{
+		id:          fmt.Sprintf("Z%d", len(b.zones)+1),
+		name:        canonicalName(name),
+		private:     private,
+		nameservers: []string{"ns-1.conformance.test.", "ns-2.conformance.test."},
+		records:     map[recordKey]*recordSet{},
+	}
+	b.zones = append(b.zones, z)
+	return z.id
+}
+
+// SetTXT sets the TXT record fqdn of the zone zoneID to values.
+func (b *Backend) SetTXT(zoneID string, fqdn string, values ...string) {
+	z := b.zoneByID(zoneID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	z.records[recordKey{canonicalName(fqdn), "TXT"}] = &recordSet{name: canonicalName(fqdn), recordType: "TXT", ttl: 60, values: values}
+}
+
+// DeleteTXTBeforeNextChange removes the TXT record fqdn right before the next change is applied,
+// as if it was deleted by someone else between its listing and its deletion by the client.
+func (b *Backend) DeleteTXTBeforeNextChange(fqdn string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deletedMeanwhile = append(b.deletedMeanwhile, recordKey{canonicalName(fqdn), "TXT"})
+}
+
+// TXT returns the values of the TXT record fqdn, or nil when no zone holds it.
+func (b *Backend) TXT(fqdn string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, z := range b.zones {
+		if rs, ok := z.records[recordKey{canonicalName(fqdn), "TXT"}]; ok {
+			return append([]string{}, rs.values...)
+		}
+	}
+	return nil
+}
+
+// Writes returns the names of the records written through the fake APIs, in order.
+func (b *Backend) Writes() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]string{}, b.writes...)
+}
+
+// zoneByID returns the zone whose ID is id, with or without the /hostedzone/ prefix of Route53.
+func (b *Backend) zoneByID(id string) *zone {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id = strings.TrimPrefix(id, "/hostedzone/")
+	for _, z := range b.zones {
+		if z.id == id {
+			return z
+		}
+	}
+	return nil
+}
+
+// zoneByName returns the zone named name.
+func (b *Backend) zoneByName(name string) *zone {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, z := range b.zones {
+		if z.name == canonicalName(name) {
+			return z
+		}
+	}
+	return nil
+}
+
+// sortedZones returns the zones sorted by name as Route53 lists them.
+func (b *Backend) sortedZones() []*zone {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	zones := append([]*zone{}, b.zones...)
+	sort.SliceStable(zones, func(i, j int) bool {
+		return reversedLabels(zones[i].name) < reversedLabels(zones[j].name)
+	})
+	return zones
+}
+
+// zonesPage returns the page of the sorted zones starting at the offset token, and the token of the
+// next page, empty for the last one.
+func (b *Backend) zonesPage(token string) ([]*zone, string) {
+	zones := b.sortedZones()
+	start, _ := strconv.Atoi(token)
+	if start > len(zones) {
+		start = len(zones)
+	}
+	end := start + zonesPageSize
+	if end >= len(zones) {
+		return zones[start:], ""
+	}
+	return zones[start:end], strconv.Itoa(end)
+}
+
+// sortedRecords returns the record sets of z sorted by name and type as Route53 lists them.
+func (b *Backend) sortedRecords(z *zone) []*recordSet {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	records := []*recordSet{}
+	for _, rs := range z.records {
+		records = append(records, rs)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].name != records[j].name {
+			return reversedLabels(records[i].name) < reversedLabels(records[j].name)
+		}
+		return records[i].recordType < records[j].recordType
+	})
+	return records
+}
+
+// findRecord returns a copy of the record set of z named name of type recordType, or nil when there is none.
+func (b *Backend) findRecord(z *zone, name string, recordType string) *recordSet {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rs, ok := z.records[recordKey{canonicalName(name), recordType}]
+	if !ok {
+		return nil
+	}
+	return &recordSet{name: rs.name, recordType: rs.recordType, ttl: rs.ttl, values: append([]string{}, rs.values...)}
+}
+
+// change applies the deletions and then the additions of a change to z. Deleted record sets must
+// match an existing one exactly and added ones must not exist, unless upsert is set. Nothing is
+// changed when the change is rejected.
+func (b *Backend) change(z *zone, deletions []*recordSet, additions []*recordSet, upsert bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range b.deletedMeanwhile {
+		delete(z.records, key)
+	}
+	b.deletedMeanwhile = nil
+
+	records := map[recordKey]*recordSet{}
+	for key, rs := range z.records {
+		records[key] = rs
+	}
+
+	for _, rs := range deletions {
+		key := recordKey{canonicalName(rs.name), rs.recordType}
+		existing, ok := records[key]
+		if !ok {
+			return fmt.Errorf("%w: tried to delete resource record set [name='%s', type='%s'] but it was not found", errNotFound, rs.name, rs.recordType)
+		}
+		if existing.ttl != rs.ttl || !sameValues(existing.values, rs.values) {
+			return fmt.Errorf("%w: %s %s doesn't match an existing record set", errConflict, rs.name, rs.recordType)
+		}
+		delete(records, key)
+	}
+
+	for _, rs := range additions {
+		name := canonicalName(rs.name)
+		if name != z.name && !strings.HasSuffix(name, "."+z.name) {
+			return fmt.Errorf("%w: %s is not in zone %s", errInvalid, rs.name, z.name)
+		}
+		key := recordKey{name, rs.recordType}
+		if _, ok := records[key]; ok && !upsert {
+			return fmt.Errorf("%w: %s %s already exists", errConflict, rs.name, rs.recordType)
+		}
+		records[key] = &recordSet{name: name, recordType: rs.recordType, ttl: rs.ttl, values: append([]string{}, rs.values...)}
+	}
+
+	z.records = records
+	for _, rs := range additions {
+		b.writes = append(b.writes, canonicalName(rs.name))
+	}
+	return nil
+}
+
+// canonicalName returns name in lower case with a trailing dot.
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+}
+
+// reversedLabels returns the labels of name from the top level domain down, the order Route53 sorts names in.
+func reversedLabels(name string) string {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(labels, ".")
+}
+
+// unquote removes the quotes around a TXT value.
+func unquote(value string) string {
+	return strings.Trim(value, "\"")
+}
+
+func sameValues(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type cloudDNSManagedZone struct {
+	Kind        string   `json:"kind"`
+	Name        string   `json:"name"`
+	DNSName     string   `json:"dnsName"`
+	Visibility  string   `json:"visibility"`
+	NameServers []string `json:"nameServers"`
+}
+
+type cloudDNSRecordSet struct {
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int64    `json:"ttl"`
+	Rrdatas []string `json:"rrdatas"`
+}
+
+type cloudDNSChange struct {
+	Kind      string              `json:"kind"`
+	ID        string              `json:"id"`
+	Status    string              `json:"status"`
+	Additions []cloudDNSRecordSet `json:"additions,omitempty"`
+	Deletions []cloudDNSRecordSet `json:"deletions,omitempty"`
+}
+
+// NewCloudDNSServer returns a server answering the Cloud DNS API calls of the GCP client with the
+// zones of backend, the name of a managed zone being its ID. It is closed when the test ends.
+func NewCloudDNSServer(t *testing.T, backend *Backend) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveCloudDNS(w, r, backend)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serveCloudDNS(w http.ResponseWriter, r *http.Request, backend *Backend) {
+	// projects/{project}/managedZones[/{zone}[/rrsets|/changes]]
+	index := strings.Index(r.URL.Path, "projects/")
+	if index == -1 {
+		writeCloudDNSError(w, http.StatusNotFound, "unsupported request "+r.Method+" "+r.URL.Path)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path[index:], "/"), "/")
+	if len(parts) < 3 || parts[2] != "managedZones" {
+		writeCloudDNSError(w, http.StatusNotFound, "unsupported request "+r.Method+" "+r.URL.Path)
+		return
+	}
+
+	if len(parts) == 3 && r.Method == http.MethodGet {
+		page, next := backend.zonesPage(r.URL.Query().Get("pageToken"))
+		zones := []cloudDNSManagedZone{}
+		for _, z := range page {
+			zones = append(zones, cloudDNSZone(z))
+		}
+		response := map[string]interface{}{"kind": "dns#managedZonesListResponse", "managedZones": zones}
+		if next != "" {
+			response["nextPageToken"] = next
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	z := backend.zoneByID(parts[3])
+	if z == nil {
+		writeCloudDNSError(w, http.StatusNotFound, fmt.Sprintf("The 'parameters.managedZone' resource named '%s' does not exist.", parts[3]))
+		return
+	}
+
+	switch {
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, cloudDNSZone(z))
+
+	case len(parts) == 5 && parts[4] == "rrsets" && r.Method == http.MethodGet:
+		// like Cloud DNS, the type filter only applies along with the name filter
+		name, recordType := r.URL.Query().Get("name"), r.URL.Query().Get("type")
+		rrsets := []cloudDNSRecordSet{}
+		for _, rs := range backend.sortedRecords(z) {
+			if name != "" && (rs.name != canonicalName(name) || (recordType != "" && rs.recordType != recordType)) {
+				continue
+			}
+			rrdatas := []string{}
+			for _, value := range rs.values {
+				if rs.recordType == "TXT" {
+					value = fmt.Sprintf("%q", value)
+				}
+				rrdatas = append(rrdatas, value)
+			}
+			rrsets = append(rrsets, cloudDNSRecordSet{Kind: "dns#resourceRecordSet", Name: rs.name, Type: rs.recordType, TTL: rs.ttl, Rrdatas: rrdatas})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"kind": "dns#resourceRecordSetsListResponse", "rrsets": rrsets})
+
+	case len(parts) == 5 && parts[4] == "changes" && r.Method == http.MethodPost:
+		change := cloudDNSChange{}
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			writeCloudDNSError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Cloud DNS rejects additions of existing record sets, an update is a deletion and an addition
+		err := backend.change(z, cloudDNSRecordSets(change.Deletions), cloudDNSRecordSets(change.Additions), false)
+		switch {
+		case errors.Is(err, errNotFound):
+			writeCloudDNSError(w, http.StatusNotFound, err.Error())
+			return
+		case errors.Is(err, errConflict):
+			writeCloudDNSError(w, http.StatusConflict, err.Error())
+			return
+		case err != nil:
+			writeCloudDNSError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		change.Kind, change.ID, change.Status = "dns#change", "1", "done"
+		writeJSON(w, http.StatusOK, change)
+
+	default:
+		writeCloudDNSError(w, http.StatusNotFound, "unsupported request "+r.Method+" "+r.URL.Path)
+	}
+}
+
+func cloudDNSZone(z *zone) cloudDNSManagedZone {
+	visibility := "public"
+	if z.private {
+		visibility = "private"
+	}
+	return cloudDNSManagedZone{Kind: "dns#managedZone", Name: z.id, DNSName: z.name, Visibility: visibility, NameServers: z.nameservers}
+}
+
+func cloudDNSRecordSets(records []cloudDNSRecordSet) []*recordSet {
+	recordSets := []*recordSet{}
+	for _, record := range records {
+		values := []string{}
+		for _, value := range record.Rrdatas {
+			if record.Type == "TXT" {
+				value = unquote(value)
+			}
+			values = append(values, value)
+		}
+		recordSets = append(recordSets, &recordSet{name: record.Name, recordType: record.Type, ttl: record.TTL, values: values})
+	}
+	return recordSets
+}
+
+func writeCloudDNSError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": message},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conformance runs the same DNS scenarios against every cloud provider client, each
+// talking to an httptest fake of its provider API backed by a shared in-memory Backend. A new
+// provider ships with a fake of its API and a test calling Run.
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+const (
+	baseDomain = "example.com"
+	token      = "conformance-token"
+)
+
+var (
+	// errConflict is returned by a change that doesn't match the records of a zone
+	errConflict = errors.New("conflicting change")
+	// errInvalid is returned by a change that a provider would reject outright
+	errInvalid = errors.New("invalid change")
+	// errNotFound is returned by a change deleting a record set that doesn't exist
+	errNotFound = errors.New("record set not found")
+)
+
+// Client is the part of the DNS client interface whose behaviour must be the same for every provider.
+type Client interface {
+	AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeToken string, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error)
+	ValidateDNSWriteAccess(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) (bool, error)
+	DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest) error
+	DeleteAcmeChallengeResourceRecord(reqLogger logr.Logger, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) error
+}
+
+// Provider builds the client under test against a fake of its provider API serving backend.
+type Provider struct {
+	NewClient func(t *testing.T, backend *Backend) Client
+}
+
+// Run runs every scenario against the client of provider, each with a new Backend.
+func Run(t *testing.T, provider Provider) {
+	t.Run("answer challenge", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		client := provider.NewClient(t, backend)
+
+		fqdn, err := client.AnswerDNSChallenge(logr.Discard(), token, "api."+baseDomain, newCertificateRequest(), zoneID)
+		assert.NoError(t, err)
+		assert.Equal(t, challengeName("api."+baseDomain), strings.TrimSuffix(fqdn, "."))
+		assert.Equal(t, []string{token}, backend.TXT(challengeName("api."+baseDomain)))
+	})
+
+	t.Run("dual TXT values are replaced", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		backend.SetTXT(zoneID, challengeName("api."+baseDomain), "stale-1", "stale-2")
+		client := provider.NewClient(t, backend)
+
+		_, err := client.AnswerDNSChallenge(logr.Discard(), token, "api."+baseDomain, newCertificateRequest(), zoneID)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{token}, backend.TXT(challengeName("api."+baseDomain)))
+	})
+
+	t.Run("answered challenge is not rewritten", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		backend.SetTXT(zoneID, challengeName("api."+baseDomain), token)
+		client := provider.NewClient(t, backend)
+
+		fqdn, err := client.AnswerDNSChallenge(logr.Discard(), token, "api."+baseDomain, newCertificateRequest(), zoneID)
+		assert.NoError(t, err)
+		assert.Equal(t, challengeName("api."+baseDomain), strings.TrimSuffix(fqdn, "."))
+		assert.Empty(t, backend.Writes())
+	})
+
+	t.Run("delete a challenge record", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		backend.SetTXT(zoneID, challengeName("api."+baseDomain), token)
+		backend.SetTXT(zoneID, challengeName("apps."+baseDomain), token)
+		client := provider.NewClient(t, backend)
+
+		assert.NoError(t, client.DeleteAcmeChallengeResourceRecord(logr.Discard(), "api."+baseDomain, newCertificateRequest(), zoneID))
+		assert.Empty(t, backend.TXT(challengeName("api."+baseDomain)))
+		assert.Equal(t, []string{token}, backend.TXT(challengeName("apps."+baseDomain)))
+	})
+
+	t.Run("delete a challenge record that is already gone", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		backend.SetTXT(zoneID, challengeName("apps."+baseDomain), token)
+		client := provider.NewClient(t, backend)
+
+		assert.NoError(t, client.DeleteAcmeChallengeResourceRecord(logr.Discard(), "api."+baseDomain, newCertificateRequest(), zoneID))
+		assert.Equal(t, []string{token}, backend.TXT(challengeName("apps."+baseDomain)))
+	})
+
+	t.Run("delete a challenge record deleted meanwhile", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		backend.SetTXT(zoneID, challengeName("api."+baseDomain), token)
+		backend.SetTXT(zoneID, challengeName("apps."+baseDomain), token)
+		backend.DeleteTXTBeforeNextChange(challengeName("api." + baseDomain))
+		client := provider.NewClient(t, backend)
+
+		assert.NoError(t, client.DeleteAcmeChallengeResourceRecord(logr.Discard(), "api."+baseDomain, newCertificateRequest(), zoneID))
+		assert.Empty(t, backend.TXT(challengeName("api."+baseDomain)))
+		assert.Equal(t, []string{token}, backend.TXT(challengeName("apps."+baseDomain)))
+	})
+
+	t.Run("delete every challenge record", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		backend.SetTXT(zoneID, challengeName("api."+baseDomain), "value-1", "value-2")
+		backend.SetTXT(zoneID, challengeName("apps."+baseDomain), token)
+		backend.SetTXT(zoneID, "unrelated."+baseDomain, "keep")
+		client := provider.NewClient(t, backend)
+
+		assert.NoError(t, client.DeleteAcmeChallengeResourceRecords(logr.Discard(), newCertificateRequest()))
+		assert.Empty(t, backend.TXT(challengeName("api."+baseDomain)))
+		assert.Empty(t, backend.TXT(challengeName("apps."+baseDomain)))
+		assert.Equal(t, []string{"keep"}, backend.TXT("unrelated."+baseDomain))
+	})
+
+	t.Run("delete every challenge record when some are deleted meanwhile", func(t *testing.T) {
+		backend := NewBackend()
+		zoneID := backend.AddZone(baseDomain, false)
+		backend.SetTXT(zoneID, challengeName("api."+baseDomain), token)
+		backend.SetTXT(zoneID, challengeName("apps."+baseDomain), token)
+		backend.SetTXT(zoneID, "unrelated."+baseDomain, "keep")
+		backend.DeleteTXTBeforeNextChange(challengeName("api." + baseDomain))
+		client := provider.NewClient(t, backend)
+
+		assert.NoError(t, client.DeleteAcmeChallengeResourceRecords(logr.Discard(), newCertificateRequest()))
+		assert.Empty(t, backend.TXT(challengeName("api."+baseDomain)))
+		assert.Empty(t, backend.TXT(challengeName("apps."+baseDomain)))
+		assert.Equal(t, []string{"keep"}, backend.TXT("unrelated."+baseDomain))
+	})
+
+	t.Run("validate write access", func(t *testing.T) {
+		backend := NewBackend()
+		backend.AddZone(baseDomain, false)
+		client := provider.NewClient(t, backend)
+
+		ok, err := client.ValidateDNSWriteAccess(logr.Discard(), newCertificateRequest())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		testRecord := fmt.Sprintf("%s.%s", cTypes.WriteValidationSubDomain, baseDomain)
+		assert.Contains(t, backend.Writes(), canonicalName(testRecord))
+		assert.Empty(t, backend.TXT(testRecord), "the write test record should be deleted")
+	})
+
+	t.Run("zones listed over several pages", func(t *testing.T) {
+		backend := NewBackend()
+		// listed before the zone of the base domain
+		backend.AddZone("example.ca", false)
+		backend.AddZone(baseDomain, false)
+		backend.AddZone("example.net", false)
+		backend.AddZone("other."+baseDomain, false)
+		client := provider.NewClient(t, backend)
+
+		ok, err := client.ValidateDNSWriteAccess(logr.Discard(), newCertificateRequest())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		testRecord := fmt.Sprintf("%s.%s", cTypes.WriteValidationSubDomain, baseDomain)
+		assert.Contains(t, backend.Writes(), canonicalName(testRecord))
+	})
+
+	t.Run("private zone", func(t *testing.T) {
+		backend := NewBackend()
+		backend.AddZone(baseDomain, true)
+		client := provider.NewClient(t, backend)
+
+		ok, _ := client.ValidateDNSWriteAccess(logr.Discard(), newCertificateRequest())
+		assert.False(t, ok, "write access to a private zone should not be validated")
+		assert.Empty(t, backend.Writes())
+	})
+
+	t.Run("missing zone", func(t *testing.T) {
+		backend := NewBackend()
+		backend.AddZone("other."+baseDomain, false)
+		client := provider.NewClient(t, backend)
+
+		ok, _ := client.ValidateDNSWriteAccess(logr.Discard(), newCertificateRequest())
+		assert.False(t, ok)
+
+		_, err := client.AnswerDNSChallenge(logr.Discard(), token, "api."+baseDomain, newCertificateRequest(), "ZMISSING")
+		assert.Error(t, err)
+		assert.Empty(t, backend.Writes())
+	})
+}
+
+// newCertificateRequest returns a CertificateRequest for an API and a wildcard ingress name of baseDomain.
+func newCertificateRequest() *certmanv1alpha1.CertificateRequest {
+	return &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "conformance", Namespace: "conformance"},
+		Spec: certmanv1alpha1.CertificateRequestSpec{
+			ACMEDNSDomain: baseDomain,
+			DnsNames:      []string{"api." + baseDomain, "*.apps." + baseDomain},
+		},
+	}
+}
+
+func challengeName(domain string) string {
+	return fmt.Sprintf("%s.%s", cTypes.AcmeChallengeSubDomain, domain)
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	route53APIVersion = "/2013-04-01/"
+	// route53RecordSetLimit is the default maximum number of record sets of a hosted zone
+	route53RecordSetLimit = 10000
+)
+
+type route53HostedZone struct {
+	ID                     string `xml:"Id"`
+	Name                   string `xml:"Name"`
+	CallerReference        string `xml:"CallerReference"`
+	PrivateZone            bool   `xml:"Config>PrivateZone"`
+	ResourceRecordSetCount int    `xml:"ResourceRecordSetCount"`
+}
+
+type route53RecordSet struct {
+	Name            string                  `xml:"Name"`
+	Type            string                  `xml:"Type"`
+	TTL             int64                   `xml:"TTL"`
+	ResourceRecords []route53ResourceRecord `xml:"ResourceRecords>ResourceRecord"`
+}
+
+// route53ResourceRecord is one value of a record set, each in its own ResourceRecord element
+type route53ResourceRecord struct {
+	Value string `xml:"Value"`
+}
+
+type route53ListHostedZonesResponse struct {
+	XMLName     xml.Name            `xml:"ListHostedZonesResponse"`
+	HostedZones []route53HostedZone `xml:"HostedZones>HostedZone"`
+	IsTruncated bool                `xml:"IsTruncated"`
+	NextMarker  string              `xml:"NextMarker,omitempty"`
+	MaxItems    string              `xml:"MaxItems"`
+}
+
+type route53ListHostedZonesByNameResponse struct {
+	XMLName     xml.Name            `xml:"ListHostedZonesByNameResponse"`
+	HostedZones []route53HostedZone `xml:"HostedZones>HostedZone"`
+	DNSName     string              `xml:"DNSName"`
+	IsTruncated bool                `xml:"IsTruncated"`
+	MaxItems    string              `xml:"MaxItems"`
+}
+
+type route53GetHostedZoneResponse struct {
+	XMLName     xml.Name          `xml:"GetHostedZoneResponse"`
+	HostedZone  route53HostedZone `xml:"HostedZone"`
+	NameServers []string          `xml:"DelegationSet>NameServers>NameServer"`
+}
+
+type route53ListResourceRecordSetsResponse struct {
+	XMLName            xml.Name           `xml:"ListResourceRecordSetsResponse"`
+	ResourceRecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated        bool               `xml:"IsTruncated"`
+	MaxItems           string             `xml:"MaxItems"`
+}
+
+type route53ChangeResourceRecordSetsRequest struct {
+	Changes []struct {
+		Action            string           `xml:"Action"`
+		ResourceRecordSet route53RecordSet `xml:"ResourceRecordSet"`
+	} `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53ChangeResourceRecordSetsResponse struct {
+	XMLName     xml.Name `xml:"ChangeResourceRecordSetsResponse"`
+	ID          string   `xml:"ChangeInfo>Id"`
+	Status      string   `xml:"ChangeInfo>Status"`
+	SubmittedAt string   `xml:"ChangeInfo>SubmittedAt"`
+}
+
+type route53GetHostedZoneLimitResponse struct {
+	XMLName   xml.Name `xml:"GetHostedZoneLimitResponse"`
+	LimitType string   `xml:"Limit>Type"`
+	Value     int64    `xml:"Limit>Value"`
+	Count     int      `xml:"Count"`
+}
+
+type route53ErrorResponse struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Type      string   `xml:"Error>Type"`
+	Code      string   `xml:"Error>Code"`
+	Message   string   `xml:"Error>Message"`
+	RequestID string   `xml:"RequestId"`
+}
+
+type route53InvalidChangeBatch struct {
+	XMLName   xml.Name `xml:"InvalidChangeBatch"`
+	Messages  []string `xml:"Messages>Message"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// NewRoute53Server returns a server answering the Route53 API calls of the AWS client with the
+// zones of backend. It is closed when the test ends.
+func NewRoute53Server(t *testing.T, backend *Backend) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveRoute53(w, r, backend)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serveRoute53(w http.ResponseWriter, r *http.Request, backend *Backend) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, route53APIVersion), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case r.Method == http.MethodGet && path == "hostedzone":
+		page, next := backend.zonesPage(r.URL.Query().Get("marker"))
+		response := route53ListHostedZonesResponse{MaxItems: strconv.Itoa(zonesPageSize), IsTruncated: next != "", NextMarker: next}
+		for _, z := range page {
+			response.HostedZones = append(response.HostedZones, route53Zone(backend, z))
+		}
+		writeXML(w, http.StatusOK, response)
+
+	case r.Method == http.MethodGet && path == "hostedzonesbyname":
+		dnsName := r.URL.Query().Get("dnsname")
+		response := route53ListHostedZonesByNameResponse{DNSName: dnsName, MaxItems: "100"}
+		for _, z := range backend.sortedZones() {
+			if dnsName == "" || reversedLabels(z.name) >= reversedLabels(canonicalName(dnsName)) {
+				response.HostedZones = append(response.HostedZones, route53Zone(backend, z))
+			}
+		}
+		writeXML(w, http.StatusOK, response)
+
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "hostedzonelimit":
+		z := backend.zoneByID(parts[1])
+		if z == nil {
+			writeXML(w, http.StatusNotFound, route53ErrorResponse{Type: "Sender", Code: "NoSuchHostedZone", Message: fmt.Sprintf("No hosted zone found with ID: %s", parts[1])})
+			return
+		}
+		writeXML(w, http.StatusOK, route53GetHostedZoneLimitResponse{LimitType: parts[2], Value: route53RecordSetLimit, Count: len(backend.sortedRecords(z))})
+
+	case len(parts) >= 2 && parts[0] == "hostedzone":
+		z := backend.zoneByID(parts[1])
+		if z == nil {
+			writeXML(w, http.StatusNotFound, route53ErrorResponse{Type: "Sender", Code: "NoSuchHostedZone", Message: fmt.Sprintf("No hosted zone found with ID: %s", parts[1])})
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && len(parts) == 2:
+			writeXML(w, http.StatusOK, route53GetHostedZoneResponse{HostedZone: route53Zone(backend, z), NameServers: z.nameservers})
+		case r.Method == http.MethodGet && len(parts) == 3 && parts[2] == "rrset":
+			serveRoute53ListRecordSets(w, r, backend, z)
+		case r.Method == http.MethodPost && len(parts) == 3 && parts[2] == "rrset":
+			serveRoute53Change(w, r, backend, z)
+		default:
+			writeXML(w, http.StatusBadRequest, route53ErrorResponse{Type: "Sender", Code: "InvalidInput", Message: "unsupported request " + r.Method + " " + r.URL.Path})
+		}
+
+	default:
+		writeXML(w, http.StatusBadRequest, route53ErrorResponse{Type: "Sender", Code: "InvalidInput", Message: "unsupported request " + r.Method + " " + r.URL.Path})
+	}
+}
+
+// serveRoute53ListRecordSets lists the record sets of z from the name and type of the request on.
+func serveRoute53ListRecordSets(w http.ResponseWriter, r *http.Request, backend *Backend, z *zone) {
+	startName := r.URL.Query().Get("name")
+	startType := r.URL.Query().Get("type")
+
+	response := route53ListResourceRecordSetsResponse{MaxItems: "100"}
+	for _, rs := range backend.sortedRecords(z) {
+		if startName != "" {
+			name, start := reversedLabels(rs.name), reversedLabels(canonicalName(startName))
+			if name < start || (name == start && rs.recordType < startType) {
+				continue
+			}
+		}
+		records := []route53ResourceRecord{}
+		for _, value := range rs.values {
+			if rs.recordType == "TXT" {
+				value = fmt.Sprintf("%q", value)
+			}
+			records = append(records, route53ResourceRecord{Value: value})
+		}
+		response.ResourceRecordSets = append(response.ResourceRecordSets, route53RecordSet{Name: rs.name, Type: rs.recordType, TTL: rs.ttl, ResourceRecords: records})
+	}
+	writeXML(w, http.StatusOK, response)
+}
+
+// serveRoute53Change applies a change batch to z. Like Route53, a DELETE must match the whole
+// record set and a CREATE fails for an existing one.
+func serveRoute53Change(w http.ResponseWriter, r *http.Request, backend *Backend, z *zone) {
+	request := route53ChangeResourceRecordSetsRequest{}
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeXML(w, http.StatusBadRequest, route53ErrorResponse{Type: "Sender", Code: "InvalidInput", Message: err.Error()})
+		return
+	}
+
+	deletions, additions, upsert := []*recordSet{}, []*recordSet{}, false
+	for _, change := range request.Changes {
+		values := []string{}
+		for _, record := range change.ResourceRecordSet.ResourceRecords {
+			value := record.Value
+			if change.ResourceRecordSet.Type == "TXT" {
+				value = unquote(value)
+			}
+			values = append(values, value)
+		}
+		rs := &recordSet{name: change.ResourceRecordSet.Name, recordType: change.ResourceRecordSet.Type, ttl: change.ResourceRecordSet.TTL, values: values}
+
+		switch change.Action {
+		case "DELETE":
+			deletions = append(deletions, rs)
+		case "UPSERT":
+			upsert = true
+			additions = append(additions, rs)
+		case "CREATE":
+			additions = append(additions, rs)
+		default:
+			writeXML(w, http.StatusBadRequest, route53ErrorResponse{Type: "Sender", Code: "InvalidInput", Message: "unknown action " + change.Action})
+			return
+		}
+	}
+
+	if err := backend.change(z, deletions, additions, upsert); err != nil {
+		if errors.Is(err, errConflict) || errors.Is(err, errInvalid) || errors.Is(err, errNotFound) {
+			writeXML(w, http.StatusBadRequest, route53InvalidChangeBatch{Messages: []string{err.Error()}})
+			return
+		}
+		writeXML(w, http.StatusInternalServerError, route53ErrorResponse{Type: "Receiver", Code: "InternalError", Message: err.Error()})
+		return
+	}
+
+	writeXML(w, http.StatusOK, route53ChangeResourceRecordSetsResponse{ID: "/change/C1", Status: "INSYNC", SubmittedAt: "2020-01-01T00:00:00Z"})
+}
+
+func route53Zone(backend *Backend, z *zone) route53HostedZone {
+	return route53HostedZone{
+		ID:                     "/hostedzone/" + z.id,
+		Name:                   z.name,
+		CallerReference:        z.id,
+		PrivateZone:            z.private,
+		ResourceRecordSetCount: len(backend.sortedRecords(z)),
+	}
+}
+
+func writeXML(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(body)
+}
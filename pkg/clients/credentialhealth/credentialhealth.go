@@ -0,0 +1,86 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialhealth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	// NearingExpiry is the remaining lifetime of newly acquired credentials under which a warning is
+	// logged, as an issuance may outlive them
+	NearingExpiry = 15 * time.Minute
+	// RepeatedFailureCount is the number of consecutive failures to acquire the credentials of a
+	// provider from which each failure is logged as a warning
+	RepeatedFailureCount = 3
+)
+
+var (
+	log = logf.Log.WithName("credentialhealth")
+
+	// healthClock tells the time credentials are acquired at
+	healthClock clock.Clock = clock.Real{}
+
+	mu sync.Mutex
+	// failures holds the consecutive failures of each provider since its credentials were last acquired
+	failures = map[string]int{}
+)
+
+// SetClock makes the acquisitions read the current time from c, such as a clock.Fake in tests.
+func SetClock(c clock.Clock) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	healthClock = c
+}
+
+// RecordAcquired reports that credentials of provider expiring at expiry were acquired, and logs a
+// warning when they expire within NearingExpiry.
+func RecordAcquired(provider string, expiry time.Time) {
+	mu.Lock()
+	failures[provider] = 0
+	acquired := healthClock.Now()
+	mu.Unlock()
+
+	localmetrics.SetCloudCredentialAcquired(provider, acquired, expiry)
+
+	if remaining := expiry.Sub(acquired); remaining < NearingExpiry {
+		log.Info(fmt.Sprintf("WARNING: the %v credentials acquired expire in %v", provider, remaining.Round(time.Second)))
+	}
+}
+
+// RecordFailure counts a failure to acquire the credentials of provider, and logs a warning from
+// the RepeatedFailureCount consecutive failure on.
+func RecordFailure(provider string, err error) {
+	mu.Lock()
+	failures[provider]++
+	consecutive := failures[provider]
+	mu.Unlock()
+
+	localmetrics.SetCloudCredentialFailure(provider, consecutive)
+
+	if consecutive >= RepeatedFailureCount {
+		log.Info(fmt.Sprintf("WARNING: the %v credentials failed to be acquired %d times in a row: %v", provider, consecutive, err))
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialhealth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestRecordCredentials(t *testing.T) {
+	current := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetClock(clock.NewFake(current))
+	defer SetClock(clock.Real{})
+
+	provider := "test-provider"
+	for i := 1; i <= RepeatedFailureCount; i++ {
+		RecordFailure(provider, errors.New("access denied"))
+		assert.Equal(t, float64(i), testutil.ToFloat64(localmetrics.MetricCloudCredentialConsecutiveFailures.WithLabelValues(provider)))
+	}
+	assert.Equal(t, float64(RepeatedFailureCount), testutil.ToFloat64(localmetrics.MetricCloudCredentialFailureCount.WithLabelValues(provider)))
+
+	expiry := current.Add(time.Hour)
+	RecordAcquired(provider, expiry)
+	assert.Equal(t, float64(current.Unix()), testutil.ToFloat64(localmetrics.MetricCloudCredentialAcquired.WithLabelValues(provider)))
+	assert.Equal(t, float64(expiry.Unix()), testutil.ToFloat64(localmetrics.MetricCloudCredentialExpiry.WithLabelValues(provider)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(localmetrics.MetricCloudCredentialConsecutiveFailures.WithLabelValues(provider)))
+
+	// the failures are counted again from the last acquisition
+	RecordFailure(provider, errors.New("access denied"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(localmetrics.MetricCloudCredentialConsecutiveFailures.WithLabelValues(provider)))
+	assert.Equal(t, float64(RepeatedFailureCount+1), testutil.ToFloat64(localmetrics.MetricCloudCredentialFailureCount.WithLabelValues(provider)))
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"testing"
+
+	dnsv1 "google.golang.org/api/dns/v1"
+	option "google.golang.org/api/option"
+
+	"github.com/openshift/certman-operator/pkg/clients/conformance"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, conformance.Provider{
+		NewClient: func(t *testing.T, backend *conformance.Backend) conformance.Client {
+			server := conformance.NewCloudDNSServer(t, backend)
+			service, err := dnsv1.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return &gcpClient{client: *service, project: "conformance"}
+		},
+	})
+}
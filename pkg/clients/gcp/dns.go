@@ -18,29 +18,41 @@ package gcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/go-logr/logr"
 	dnsv1 "google.golang.org/api/dns/v1"
+	"google.golang.org/api/googleapi"
 	option "google.golang.org/api/option"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	htransport "google.golang.org/api/transport/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
-	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/chaos"
+	"github.com/openshift/certman-operator/pkg/clients/nameserver"
+	"github.com/openshift/certman-operator/pkg/clients/quota"
 	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+	"github.com/openshift/certman-operator/pkg/clients/zonecache"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
 )
 
 const (
 	resourceRecordTTL = 60
 )
 
+// managedZones caches the managed zones of each project across the clients built by the reconciles
+var managedZones = zonecache.New[*dnsv1.ManagedZone](quota.ProviderGCP, zonecache.DefaultTTL)
+
 // client implements the Client interface
 type gcpClient struct {
 	client  dnsv1.Service
 	project string
+	// zones caches the managed zones of project, nil lists them on every lookup
+	zones *zonecache.Cache[*dnsv1.ManagedZone]
 }
 
 func (c *gcpClient) GetDNSName() string {
@@ -77,6 +89,15 @@ func (c *gcpClient) AnswerDNSChallenge(reqLogger logr.Logger, acmeChallengeToken
 		Type:    "TXT",
 	}
 
+	answered, err := c.hasDnsRecord(zone, dnsRecord)
+	if err != nil {
+		return "", err
+	}
+	if answered {
+		reqLogger.Info(fmt.Sprintf("resource record %v already holds the challenge token", fqdn))
+		return fqdn, nil
+	}
+
 	// add/update challenge record
 	err = c.upsertDnsRecord(zone, dnsRecord)
 	if err != nil {
@@ -158,21 +179,65 @@ func (c *gcpClient) DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger, cr
 	return nil
 }
 
-// NewClient reuturn new GCP DNS client
-func NewClient(kubeClient client.Client, secretName, namespace string) (*gcpClient, error) {
+// DeleteAcmeChallengeResourceRecord deletes the ACME challenge record of a single domain from the managed zone
+func (c *gcpClient) DeleteAcmeChallengeResourceRecord(reqLogger logr.Logger, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) error {
+	fqdn := fmt.Sprintf("%s.%s", cTypes.AcmeChallengeSubDomain, strings.TrimPrefix(domain, "*."))
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn = fqdn + "."
+	}
+
+	// Calls function to get the hostedzone of the domain of our CertificateRequest
+	zone, err := c.getManagedZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, "Unable to find appropriate managedzone")
+		return err
+	}
+
+	var changes []*dnsv1.ResourceRecordSet
+	req := c.client.ResourceRecordSets.List(c.project, zone.Name)
+	if err := req.Pages(context.Background(), func(page *dnsv1.ResourceRecordSetsListResponse) error {
+		for _, resourceRecordSet := range page.Rrsets {
+			if resourceRecordSet.Type == "TXT" && strings.EqualFold(resourceRecordSet.Name, fqdn) {
+				changes = append(changes, resourceRecordSet)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	reqLogger.Info(fmt.Sprintf("deleting resource record %v", fqdn))
+	return c.deleteDnsRecords(zone, changes)
+}
+
+// VerifyRecordVisible checks that the nameservers of the managed zone serve value for fqdn
+func (c *gcpClient) VerifyRecordVisible(reqLogger logr.Logger, fqdn string, value string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (bool, error) {
+	// Calls function to get the hostedzone of the domain of our CertificateRequest
+	zone, err := c.getManagedZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, "Unable to find appropriate managedzone")
+		return false, err
+	}
+
+	return nameserver.RecordVisible(reqLogger, zone.NameServers, fqdn, value)
+}
+
+// NewClient reuturn new GCP DNS client of the project of the cluster, see ClusterCredentials.
+func NewClient(kubeClient client.Client, secretName, namespace, clusterDeploymentName string) (*gcpClient, error) {
 	ctx := context.Background()
-	secret := &corev1.Secret{}
-	err := kubeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: secretName}, secret)
+	config, err := ClusterCredentials(ctx, kubeClient, secretName, namespace, clusterDeploymentName)
 	if err != nil {
 		return nil, err
 	}
 
-	config, err := utils.GetCredentialsJSON(kubeClient, types.NamespacedName{Namespace: namespace, Name: secretName})
+	// the authenticated transport sends through the transport recording throttled requests
+	transport, err := htransport.NewTransport(ctx, quota.NewTransport(quota.ProviderGCP, chaos.Transport(quota.ProviderGCP, http.DefaultTransport)),
+		option.WithCredentials(config), option.WithScopes(dnsv1.NdevClouddnsReadwriteScope))
 	if err != nil {
 		return nil, err
 	}
 
-	service, err := dnsv1.NewService(context.Background(), option.WithCredentials(config))
+	service, err := dnsv1.NewService(ctx, option.WithHTTPClient(&http.Client{Transport: transport}))
 	if err != nil {
 		return nil, err
 	}
@@ -180,32 +245,144 @@ func NewClient(kubeClient client.Client, secretName, namespace string) (*gcpClie
 	return &gcpClient{
 		client:  *service,
 		project: config.ProjectID,
+		zones:   managedZones,
 	}, nil
 }
 
-// getManagedZone finds and returns the ManagedZone matching the baseDomain provided
-func (c *gcpClient) getManagedZone(baseDomain string) (*dnsv1.ManagedZone, error) {
-	// list DNS zones in the project
-	zoneList, err := c.client.ManagedZones.List(c.project).Do()
+// GetAuthoritativeZone returns dnsZone, delegation of subdomains to other projects isn't supported.
+func (c *gcpClient) GetAuthoritativeZone(reqLogger logr.Logger, fqdn string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error) {
+	return dnsZone, nil
+}
+
+// GetZoneNameservers returns the nameservers of the managed zone of the base domain of cr.
+func (c *gcpClient) GetZoneNameservers(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string) ([]string, error) {
+	zone, err := c.getManagedZone(cr.Spec.ACMEDNSDomain)
 	if err != nil {
+		reqLogger.Error(err, "Unable to find appropriate managedzone")
 		return nil, err
 	}
 
+	return zone.NameServers, nil
+}
+
+// EnsureZoneRecords writes the CAA record allowing Let's Encrypt to issue certificates and the
+// ownership TXT record of clusterID for the base domain of cr to its managed zone.
+func (c *gcpClient) EnsureZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	zone, err := c.getManagedZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, "Unable to find appropriate managedzone")
+		return err
+	}
+
+	reqLogger.Info(fmt.Sprintf("writing CAA and ownership records of %v to managed zone %v", cr.Spec.ACMEDNSDomain, zone.Name))
+	for _, record := range zoneRecords(zone, clusterID) {
+		if err := c.upsertDnsRecord(zone, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteZoneRecords removes the records written by EnsureZoneRecords
+func (c *gcpClient) DeleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	zone, err := c.getManagedZone(cr.Spec.ACMEDNSDomain)
+	if err != nil {
+		reqLogger.Error(err, "Unable to find appropriate managedzone")
+		return err
+	}
+
+	// only delete the records that still hold the values we wrote
+	var changes []*dnsv1.ResourceRecordSet
+	wanted := zoneRecords(zone, clusterID)
+	req := c.client.ResourceRecordSets.List(c.project, zone.Name)
+	if err := req.Pages(context.Background(), func(page *dnsv1.ResourceRecordSetsListResponse) error {
+		for _, resourceRecordSet := range page.Rrsets {
+			for _, record := range wanted {
+				if resourceRecordSet.Type == record.Type && strings.EqualFold(resourceRecordSet.Name, record.Name) &&
+					len(resourceRecordSet.Rrdatas) == 1 && resourceRecordSet.Rrdatas[0] == record.Rrdatas[0] {
+					changes = append(changes, resourceRecordSet)
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	reqLogger.Info(fmt.Sprintf("deleting CAA and ownership records of %v from managed zone %v", cr.Spec.ACMEDNSDomain, zone.Name))
+	return c.deleteDnsRecords(zone, changes)
+}
+
+// zoneRecords returns the CAA and ownership TXT records of clusterID at the apex of zone
+func zoneRecords(zone *dnsv1.ManagedZone, clusterID string) []*dnsv1.ResourceRecordSet {
+	return []*dnsv1.ResourceRecordSet{
+		{
+			Kind:    "dns#resourceRecordSet",
+			Name:    zone.DnsName,
+			Rrdatas: []string{fmt.Sprintf("0 issue \"%s\"", cTypes.CAAIssuer)},
+			Ttl:     int64(resourceRecordTTL),
+			Type:    "CAA",
+		},
+		{
+			Kind:    "dns#resourceRecordSet",
+			Name:    zone.DnsName,
+			Rrdatas: []string{fmt.Sprintf("\"%s%s\"", cTypes.OwnershipRecordPrefix, clusterID)},
+			Ttl:     int64(resourceRecordTTL),
+			Type:    "TXT",
+		},
+	}
+}
+
+// getManagedZone finds and returns the public ManagedZone matching the baseDomain provided
+func (c *gcpClient) getManagedZone(baseDomain string) (*dnsv1.ManagedZone, error) {
 	// ensure base domain has a trailing dot
 	if !strings.HasSuffix(baseDomain, ".") {
 		baseDomain = baseDomain + "."
 	}
 
-	// loop through all zones, and return the matching zone
-	for _, zone := range zoneList.ManagedZones {
-		// Find our specific zone
-		if strings.EqualFold(baseDomain, zone.DnsName) && zone.Visibility == "public" {
-			// always return from this, as we only expect one managed zone from the baseDomain
-			return c.client.ManagedZones.Get(c.project, zone.Name).Do()
-		}
+	zone, ok, err := c.zones.Find(c.project, func() ([]*dnsv1.ManagedZone, error) {
+		return listAllManagedZones(&c.client, c.project)
+	}, func(zone *dnsv1.ManagedZone) bool {
+		return strings.EqualFold(baseDomain, zone.DnsName) && zone.Visibility == "public"
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("unable to find zone matching baseDomain: %s", baseDomain)
+	}
+	return zone, nil
+}
+
+// listAllManagedZones returns the managed zones of project, following the pages of the listing
+func listAllManagedZones(service *dnsv1.Service, project string) ([]*dnsv1.ManagedZone, error) {
+	var zones []*dnsv1.ManagedZone
+	err := service.ManagedZones.List(project).Pages(context.Background(), func(page *dnsv1.ManagedZonesListResponse) error {
+		localmetrics.IncrementDNSZoneListPageCount(quota.ProviderGCP)
+		zones = append(zones, page.ManagedZones...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// hasDnsRecord returns true when zone holds a record set with the name, type and data of record
+func (c *gcpClient) hasDnsRecord(zone *dnsv1.ManagedZone, record *dnsv1.ResourceRecordSet) (bool, error) {
+	res, err := c.client.ResourceRecordSets.List(c.project, zone.Name).Name(record.Name).Type(record.Type).Do()
+	if err != nil {
+		return false, fmt.Errorf("Error retrieving record sets for %q: %s", zone.Name, err)
 	}
 
-	return nil, fmt.Errorf("unable to find zone matching baseDomain: %s", baseDomain)
+	for _, existingRecord := range res.Rrsets {
+		if existingRecord.Type != record.Type || existingRecord.Name != record.Name {
+			continue
+		}
+		return reflect.DeepEqual(existingRecord.Rrdatas, record.Rrdatas), nil
+	}
+	return false, nil
 }
 
 // upsertDnsRecord takes a DNS record set, and ensures that it exists
@@ -247,7 +424,8 @@ func (c *gcpClient) upsertDnsRecord(zone *dnsv1.ManagedZone, record *dnsv1.Resou
 	return nil
 }
 
-// deleteDnsRecords takes a slice of DNS record sets, and deletes them
+// deleteDnsRecords takes a slice of DNS record sets, and deletes them. Record sets deleted since
+// they were listed are not an error.
 func (c *gcpClient) deleteDnsRecords(zone *dnsv1.ManagedZone, records []*dnsv1.ResourceRecordSet) error {
 	var err error
 
@@ -263,9 +441,24 @@ func (c *gcpClient) deleteDnsRecords(zone *dnsv1.ManagedZone, records []*dnsv1.R
 
 	// submit change
 	_, err = c.client.Changes.Create(c.project, zone.Name, change).Do()
-	if err != nil {
+	if !recordSetNotFound(err) {
 		return err
 	}
 
+	// a single missing record set fails the whole change, so the others are deleted one at a time
+	for _, record := range records {
+		change := &dnsv1.Change{Deletions: []*dnsv1.ResourceRecordSet{record}}
+		if _, err := c.client.Changes.Create(c.project, zone.Name, change).Do(); err != nil && !recordSetNotFound(err) {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// recordSetNotFound returns true when err is Cloud DNS rejecting a change because a record set it
+// deletes doesn't exist.
+func recordSetNotFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusNotFound
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"golang.org/x/oauth2/google"
+	dnsv1 "google.golang.org/api/dns/v1"
+	iamv1 "google.golang.org/api/iam/v1"
+	"google.golang.org/api/impersonate"
+	option "google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/clients/credentialhealth"
+	"github.com/openshift/certman-operator/pkg/clients/quota"
+)
+
+const (
+	// clusterDeploymentWIFLabel is set to "true" on the ClusterDeployments of GCP clusters using
+	// workload identity federation, which have no service account key
+	clusterDeploymentWIFLabel = "api.openshift.com/wif"
+	// DNSServiceAccountAnnotation names, on a ClusterDeployment using workload identity federation,
+	// the service account of the project of the cluster allowed to write to its managed zones
+	DNSServiceAccountAnnotation = "certman.managed.openshift.io/gcp-dns-service-account"
+
+	// wifCredentialsSecretName is the secret of the operator namespace holding the credential
+	// configuration the operator federates its identity with
+	wifCredentialsSecretName = "certman-operator-gcp-wif"
+	// wifCredentialConfigKey is the key of the external_account credential configuration
+	wifCredentialConfigKey = "credential_config.json"
+	// wifJumpServiceAccountKey is the optional key of the service account impersonated first, the
+	// one the service accounts of the clusters trust
+	wifJumpServiceAccountKey = "jump_service_account"
+)
+
+// credentialScopes are the scopes of the credentials of the clients and exporters
+var credentialScopes = []string{dnsv1.NdevClouddnsReadwriteScope, iamv1.CloudPlatformScope}
+
+// ClusterCredentials returns the credentials of the project of a cluster. The ClusterDeployment of
+// a GCP cluster labelled for workload identity federation has no service account key: its DNS
+// service account is impersonated with the federated identity of the operator, through the jump
+// service account when one is configured, as the AWS clients assume the role of STS clusters
+// through the jump role. The service account key of the secretName secret is used otherwise, and
+// for the canary, which has no ClusterDeployment.
+func ClusterCredentials(ctx context.Context, kubeClient client.Client, secretName, namespace, clusterDeploymentName string) (*google.Credentials, error) {
+	clusterDeployment := &hivev1.ClusterDeployment{}
+	if clusterDeploymentName != "" {
+		err := kubeClient.Get(ctx, types.NamespacedName{Name: clusterDeploymentName, Namespace: namespace}, clusterDeployment)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if clusterDeployment.Labels[clusterDeploymentWIFLabel] != "true" {
+		return utils.GetCredentialsJSON(kubeClient, types.NamespacedName{Namespace: namespace, Name: secretName})
+	}
+
+	serviceAccount := clusterDeployment.Annotations[DNSServiceAccountAnnotation]
+	project, err := serviceAccountProject(serviceAccount)
+	if err != nil {
+		return nil, fmt.Errorf("ClusterDeployment %s uses workload identity federation: %w", clusterDeploymentName, err)
+	}
+
+	secret := &corev1.Secret{}
+	err = kubeClient.Get(ctx, types.NamespacedName{Name: wifCredentialsSecretName, Namespace: config.OperatorNamespace}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the workload identity federation credentials of the operator: %w", err)
+	}
+	credentialConfig, ok := secret.Data[wifCredentialConfigKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s did not contain key %s", wifCredentialsSecretName, wifCredentialConfigKey)
+	}
+	federated, err := google.CredentialsFromJSON(ctx, credentialConfig, iamv1.CloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential configuration in secret %s: %w", wifCredentialsSecretName, err)
+	}
+
+	delegates := []string{}
+	if jump := strings.TrimSpace(string(secret.Data[wifJumpServiceAccountKey])); jump != "" {
+		delegates = append(delegates, jump)
+	}
+	tokenSource, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: serviceAccount,
+		Scopes:          credentialScopes,
+		Delegates:       delegates,
+	}, option.WithCredentials(federated))
+	if err != nil {
+		return nil, err
+	}
+
+	// the token is fetched now so a missing permission fails the client rather than its first call
+	token, err := tokenSource.Token()
+	if err != nil {
+		credentialhealth.RecordFailure(quota.ProviderGCP, err)
+		return nil, fmt.Errorf("unable to impersonate service account %s: %w", serviceAccount, err)
+	}
+	credentialhealth.RecordAcquired(quota.ProviderGCP, token.Expiry)
+
+	return &google.Credentials{ProjectID: project, TokenSource: tokenSource}, nil
+}
+
+// serviceAccountProject returns the project of the user-managed service account serviceAccount,
+// named <name>@<project>.iam.gserviceaccount.com.
+func serviceAccountProject(serviceAccount string) (string, error) {
+	if serviceAccount == "" {
+		return "", fmt.Errorf("annotation %s is not set", DNSServiceAccountAnnotation)
+	}
+	_, domain, found := strings.Cut(serviceAccount, "@")
+	project, isServiceAccount := strings.CutSuffix(domain, ".iam.gserviceaccount.com")
+	if !found || !isServiceAccount || project == "" {
+		return "", fmt.Errorf("%s is not the email of a user-managed service account", serviceAccount)
+	}
+	return project, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/certman-operator/config"
+)
+
+func TestServiceAccountProject(t *testing.T) {
+	tests := []struct {
+		serviceAccount  string
+		expectedProject string
+		expectError     bool
+	}{
+		{serviceAccount: "certman-dns@my-project.iam.gserviceaccount.com", expectedProject: "my-project"},
+		{serviceAccount: "", expectError: true},
+		{serviceAccount: "certman-dns", expectError: true},
+		{serviceAccount: "123456-compute@developer.gserviceaccount.com", expectError: true},
+		{serviceAccount: "certman-dns@.iam.gserviceaccount.com", expectError: true},
+	}
+
+	for _, test := range tests {
+		project, err := serviceAccountProject(test.serviceAccount)
+		if test.expectError != (err != nil) {
+			t.Errorf("serviceAccountProject(%q): expected an error: %t, got %v", test.serviceAccount, test.expectError, err)
+		}
+		if project != test.expectedProject {
+			t.Errorf("serviceAccountProject(%q): expected project %q, got %q", test.serviceAccount, test.expectedProject, project)
+		}
+	}
+}
+
+func TestClusterCredentialsWIF(t *testing.T) {
+	const namespace = "uhc-production-1234"
+
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		operatorSecret  *corev1.Secret
+		expectedMessage string
+	}{
+		{
+			name:            "service account not annotated",
+			operatorSecret:  wifSecret(map[string][]byte{wifCredentialConfigKey: []byte("{}")}),
+			expectedMessage: DNSServiceAccountAnnotation,
+		},
+		{
+			name:            "federated credentials missing",
+			annotations:     map[string]string{DNSServiceAccountAnnotation: "certman-dns@my-project.iam.gserviceaccount.com"},
+			expectedMessage: "workload identity federation credentials",
+		},
+		{
+			name:            "credential configuration missing",
+			annotations:     map[string]string{DNSServiceAccountAnnotation: "certman-dns@my-project.iam.gserviceaccount.com"},
+			operatorSecret:  wifSecret(map[string][]byte{wifJumpServiceAccountKey: []byte("jump@operator.iam.gserviceaccount.com")}),
+			expectedMessage: wifCredentialConfigKey,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := scheme.Scheme
+			if err := hivev1.AddToScheme(s); err != nil {
+				t.Fatal(err)
+			}
+			objects := []runtime.Object{&hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "mycluster",
+					Namespace:   namespace,
+					Labels:      map[string]string{clusterDeploymentWIFLabel: "true"},
+					Annotations: test.annotations,
+				},
+			}}
+			if test.operatorSecret != nil {
+				objects = append(objects, test.operatorSecret)
+			}
+			kubeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objects...).Build()
+
+			// the service account key secret isn't needed
+			_, err := ClusterCredentials(context.TODO(), kubeClient, "", namespace, "mycluster")
+			if err == nil || !strings.Contains(err.Error(), test.expectedMessage) {
+				t.Errorf("ClusterCredentials(): expected an error about %s, got %v", test.expectedMessage, err)
+			}
+		})
+	}
+}
+
+func wifSecret(data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: wifCredentialsSecretName, Namespace: config.OperatorNamespace},
+		Data:       data,
+	}
+}
@@ -15,6 +15,8 @@ type MockClient struct {
 	FedrampHostedZoneIDErrorString    string
 	ValidateDNSWriteAccessBool        bool
 	ValidateDNSWriteAccessErrorString string
+	VerifyRecordVisibleErrorString    string
+	EnsureZoneRecordsErrorString      string
 
 	DeleteAcmeChallengeResourceRecordsErrorString string
 }
@@ -25,6 +27,8 @@ type MockClientOptions struct {
 	FedrampHostedZoneID               string
 	ValidateDNSWriteAccessBool        bool
 	ValidateDNSWriteAccessErrorString string
+	VerifyRecordVisibleErrorString    string
+	EnsureZoneRecordsErrorString      string
 
 	DeleteAcmeChallengeResourceRecordsErrorString string
 }
@@ -36,6 +40,8 @@ func NewMockClient(opts *MockClientOptions) (c *MockClient) {
 	c.AnswerDNSChallengeErrorString = opts.AnswerDNSChallengeErrorString
 	c.ValidateDNSWriteAccessBool = opts.ValidateDNSWriteAccessBool
 	c.ValidateDNSWriteAccessErrorString = opts.ValidateDNSWriteAccessErrorString
+	c.VerifyRecordVisibleErrorString = opts.VerifyRecordVisibleErrorString
+	c.EnsureZoneRecordsErrorString = opts.EnsureZoneRecordsErrorString
 	c.DeleteAcmeChallengeResourceRecordsErrorString = opts.DeleteAcmeChallengeResourceRecordsErrorString
 	return
 }
@@ -80,3 +86,41 @@ func (c *MockClient) DeleteAcmeChallengeResourceRecords(reqLogger logr.Logger, c
 
 	return
 }
+
+func (c *MockClient) DeleteAcmeChallengeResourceRecord(reqLogger logr.Logger, domain string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (err error) {
+	if c.DeleteAcmeChallengeResourceRecordsErrorString != "" {
+		err = errors.New(c.DeleteAcmeChallengeResourceRecordsErrorString)
+	}
+
+	return
+}
+
+func (c *MockClient) VerifyRecordVisible(reqLogger logr.Logger, fqdn string, value string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (b bool, err error) {
+	if c.VerifyRecordVisibleErrorString != "" {
+		err = errors.New(c.VerifyRecordVisibleErrorString)
+		return
+	}
+
+	b = true
+	return
+}
+
+func (c *MockClient) GetAuthoritativeZone(reqLogger logr.Logger, fqdn string, cr *certmanv1alpha1.CertificateRequest, dnsZone string) (string, error) {
+	return dnsZone, nil
+}
+
+func (c *MockClient) GetZoneNameservers(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (c *MockClient) EnsureZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) (err error) {
+	if c.EnsureZoneRecordsErrorString != "" {
+		err = errors.New(c.EnsureZoneRecordsErrorString)
+	}
+
+	return
+}
+
+func (c *MockClient) DeleteZoneRecords(reqLogger logr.Logger, cr *certmanv1alpha1.CertificateRequest, dnsZone string, clusterID string) error {
+	return nil
+}
@@ -167,3 +167,39 @@ func TestValidateDNSWriteAccess(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyRecordVisible(t *testing.T) {
+	tests := []struct {
+		Name                                   string
+		TestClient                             *MockClient
+		ExpectedVerifyRecordVisibleBool        bool
+		ExpectedVerifyRecordVisibleErrorString string
+	}{
+		{
+			Name:                            "mocks success",
+			TestClient:                      NewMockClient(&MockClientOptions{}),
+			ExpectedVerifyRecordVisibleBool: true,
+		},
+		{
+			Name: "mocks error",
+			TestClient: NewMockClient(&MockClientOptions{
+				VerifyRecordVisibleErrorString: "mock verification error",
+			}),
+			ExpectedVerifyRecordVisibleBool:        false,
+			ExpectedVerifyRecordVisibleErrorString: "mock verification error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			actualBool, err := test.TestClient.VerifyRecordVisible(logr.Discard(), "_acme-challenge.an.arbitrary.url", "token", &certmanv1alpha1.CertificateRequest{}, "")
+			if err != nil && err.Error() != test.ExpectedVerifyRecordVisibleErrorString {
+				t.Errorf("VerifyRecordVisible() %s: expected error \"%s\", got error \"%s\"\n", test.Name, test.ExpectedVerifyRecordVisibleErrorString, err.Error())
+			}
+
+			if actualBool != test.ExpectedVerifyRecordVisibleBool {
+				t.Errorf("VerifyRecordVisible() %s: expected %t, got %t\n", test.Name, test.ExpectedVerifyRecordVisibleBool, actualBool)
+			}
+		})
+	}
+}
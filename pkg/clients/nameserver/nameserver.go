@@ -0,0 +1,161 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nameserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const queryTimeout = 10 * time.Second
+
+// Resolver queries nameservers directly for the records they serve, and the resolvers of the
+// operator pod for the delegations of zones.
+type Resolver struct {
+	// Port is the port the nameservers are queried on
+	Port string
+	// Timeout bounds each query
+	Timeout time.Duration
+	// System looks up the nameservers the public DNS delegates zones to
+	System *net.Resolver
+}
+
+// defaultResolver queries the nameservers on the DNS port and the delegations with the resolvers of
+// the operator pod
+var defaultResolver = &Resolver{Port: "53", Timeout: queryTimeout, System: net.DefaultResolver}
+
+// RecordVisible returns true when every one of nameservers answers a TXT query for fqdn with value.
+// The nameservers are queried directly, so the result does not depend on the resolvers configured
+// for the operator pod.
+func RecordVisible(reqLogger logr.Logger, nameservers []string, fqdn string, value string) (bool, error) {
+	return defaultResolver.RecordVisible(reqLogger, nameservers, fqdn, value)
+}
+
+// LookupTXT queries nameserver for the TXT records of fqdn. A name that does not exist yet is
+// returned as an empty list rather than an error.
+func LookupTXT(nameserver string, fqdn string) ([]string, error) {
+	return defaultResolver.LookupTXT(nameserver, fqdn)
+}
+
+// AuthoritativeNameservers returns the deepest zone that is a parent of fqdn, or fqdn itself, and has
+// NS records, along with its nameservers. The resolvers of the operator pod are used, so the zone is
+// the one that the public DNS delegates fqdn to.
+func AuthoritativeNameservers(fqdn string) (string, []string, error) {
+	return defaultResolver.AuthoritativeNameservers(fqdn)
+}
+
+// RecordVisible returns true when every one of nameservers answers a TXT query for fqdn with value.
+func (r *Resolver) RecordVisible(reqLogger logr.Logger, nameservers []string, fqdn string, value string) (bool, error) {
+	if len(nameservers) == 0 {
+		return false, fmt.Errorf("no nameservers to query for %v", fqdn)
+	}
+
+	for _, ns := range nameservers {
+		values, err := r.LookupTXT(ns, fqdn)
+		if err != nil {
+			return false, err
+		}
+
+		found := false
+		for _, v := range values {
+			if strings.Trim(v, "\"") == strings.Trim(value, "\"") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			reqLogger.Info(fmt.Sprintf("nameserver %v does not serve the expected value for %v yet", ns, fqdn))
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// LookupTXT queries nameserver for the TXT records of fqdn. A name that does not exist yet is
+// returned as an empty list rather than an error.
+func (r *Resolver) LookupTXT(nameserver string, fqdn string) ([]string, error) {
+	address := net.JoinHostPort(strings.TrimSuffix(nameserver, "."), r.Port)
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: r.Timeout}
+			return d.DialContext(ctx, network, address)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	values, err := resolver.LookupTXT(ctx, strings.TrimSuffix(fqdn, ".")+".")
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// AuthoritativeNameservers returns the deepest zone that is a parent of fqdn, or fqdn itself, and has
+// NS records, along with its nameservers, as delegated by the System resolver.
+func (r *Resolver) AuthoritativeNameservers(fqdn string) (string, []string, error) {
+	labels := strings.Split(strings.TrimSuffix(fqdn, "."), ".")
+
+	// The top level domain is never the zone of a certificate domain
+	for i := 0; i < len(labels)-1; i++ {
+		zone := strings.Join(labels[i:], ".") + "."
+		nameservers, err := r.lookupNS(zone)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(nameservers) > 0 {
+			return zone, nameservers, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("no nameservers found for %v", fqdn)
+}
+
+// lookupNS returns the nameservers of zone, or none when zone has no NS records.
+func (r *Resolver) lookupNS(zone string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	records, err := r.System.LookupNS(ctx, zone)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	nameservers := []string{}
+	for _, record := range records {
+		nameservers = append(nameservers, record.Host)
+	}
+	return nameservers, nil
+}
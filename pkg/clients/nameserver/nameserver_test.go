@@ -0,0 +1,138 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nameserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/certman-operator/pkg/clients/nameserver"
+	"github.com/openshift/certman-operator/test/e2e/framework"
+)
+
+const (
+	// failingName is answered with SERVFAIL by the fake nameserver
+	failingName = "fail.example.com."
+	// silentName is never answered by the fake nameserver
+	silentName = "silent.example.com."
+)
+
+// newFakeNameserver starts a fake nameserver failing on failingName and silent on silentName.
+func newFakeNameserver(t *testing.T) *framework.DNSServer {
+	s := framework.NewDNSServer(t)
+	s.Fail(failingName)
+	s.Silence(silentName)
+	return s
+}
+
+// resolver returns a Resolver that sends both its direct queries and its delegation lookups to s.
+func resolver(s *framework.DNSServer, timeout time.Duration) *nameserver.Resolver {
+	return &nameserver.Resolver{
+		Port:    s.Port(),
+		Timeout: timeout,
+		System:  s.NetResolver(),
+	}
+}
+
+func TestLookupTXT(t *testing.T) {
+	s := newFakeNameserver(t)
+	s.SetTXT("_acme-challenge.api.example.com.", "token")
+	r := resolver(s, time.Second)
+
+	t.Run("returns the values the nameserver serves", func(t *testing.T) {
+		values, err := r.LookupTXT("127.0.0.1", "_acme-challenge.api.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"token"}, values)
+	})
+
+	t.Run("returns no values for a name that does not exist yet", func(t *testing.T) {
+		values, err := r.LookupTXT("127.0.0.1.", "_acme-challenge.apps.example.com.")
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+	})
+
+	t.Run("returns the error of a failing nameserver", func(t *testing.T) {
+		_, err := r.LookupTXT("127.0.0.1", failingName)
+		assert.Error(t, err)
+	})
+
+	t.Run("gives up on a nameserver that does not answer", func(t *testing.T) {
+		r := resolver(s, 100*time.Millisecond)
+		start := time.Now()
+		_, err := r.LookupTXT("127.0.0.1", silentName)
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestRecordVisible(t *testing.T) {
+	s := newFakeNameserver(t)
+	s.SetTXT("_acme-challenge.api.example.com.", "\"token\"")
+	r := resolver(s, time.Second)
+
+	visible, err := r.RecordVisible(logr.Discard(), []string{"127.0.0.1"}, "_acme-challenge.api.example.com", "token")
+	assert.NoError(t, err)
+	assert.True(t, visible, "a value served with quotes should match")
+
+	visible, err = r.RecordVisible(logr.Discard(), []string{"127.0.0.1"}, "_acme-challenge.api.example.com", "other")
+	assert.NoError(t, err)
+	assert.False(t, visible, "another value should not match")
+
+	visible, err = r.RecordVisible(logr.Discard(), []string{"127.0.0.1"}, "_acme-challenge.apps.example.com", "token")
+	assert.NoError(t, err)
+	assert.False(t, visible, "a missing record should not be visible")
+
+	_, err = r.RecordVisible(logr.Discard(), []string{}, "_acme-challenge.api.example.com", "token")
+	assert.Error(t, err, "no nameservers should be an error")
+
+	_, err = r.RecordVisible(logr.Discard(), []string{"127.0.0.1"}, failingName, "token")
+	assert.Error(t, err, "a failing nameserver should be an error")
+}
+
+func TestAuthoritativeNameservers(t *testing.T) {
+	s := newFakeNameserver(t)
+	s.SetNS("example.com.", "ns1.example.net.", "ns2.example.net.")
+	s.SetNS("delegated.example.com.", "ns.delegated.example.org.")
+	r := resolver(s, time.Second)
+
+	t.Run("walks up to the deepest zone with nameservers", func(t *testing.T) {
+		zone, nameservers, err := r.AuthoritativeNameservers("_acme-challenge.api.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "example.com.", zone)
+		assert.ElementsMatch(t, []string{"ns1.example.net.", "ns2.example.net."}, nameservers)
+	})
+
+	t.Run("prefers a delegated subdomain over its parent", func(t *testing.T) {
+		zone, nameservers, err := r.AuthoritativeNameservers("_acme-challenge.api.delegated.example.com.")
+		assert.NoError(t, err)
+		assert.Equal(t, "delegated.example.com.", zone)
+		assert.Equal(t, []string{"ns.delegated.example.org."}, nameservers)
+	})
+
+	t.Run("returns an error when no zone has nameservers", func(t *testing.T) {
+		_, _, err := r.AuthoritativeNameservers("_acme-challenge.api.example.org")
+		assert.Error(t, err)
+	})
+
+	t.Run("returns the error of a failing lookup", func(t *testing.T) {
+		_, _, err := r.AuthoritativeNameservers(failingName)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// The providers the DNS API usage is reported for
+const (
+	ProviderAWS   = "aws"
+	ProviderAzure = "azure"
+	ProviderGCP   = "gcp"
+)
+
+const (
+	// ThrottleWindow is the period throttled requests are counted over to detect sustained throttling
+	ThrottleWindow = 5 * time.Minute
+	// SustainedThrottleCount is the number of throttled requests of a provider within ThrottleWindow
+	// that is logged as sustained throttling
+	SustainedThrottleCount = 20
+	// NearingLimitRatio is the share of a zone's record set limit above which its usage is logged
+	NearingLimitRatio = 0.8
+)
+
+var (
+	log = logf.Log.WithName("quota")
+
+	// quotaClock tells the time throttled requests are counted at
+	quotaClock clock.Clock = clock.Real{}
+
+	mu sync.Mutex
+	// throttles holds the times of the throttled requests of each provider within ThrottleWindow
+	throttles = map[string][]time.Time{}
+	// lastWarnings holds when sustained throttling was last logged for each provider
+	lastWarnings = map[string]time.Time{}
+)
+
+// SetClock makes the throttle window read the current time from c, such as a clock.Fake in tests.
+func SetClock(c clock.Clock) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	quotaClock = c
+}
+
+// RecordThrottle counts a request throttled by the DNS API of provider, and logs a warning at most
+// once per ThrottleWindow while the provider throttled SustainedThrottleCount requests in the window.
+func RecordThrottle(provider string) {
+	localmetrics.IncrementDNSThrottledRequestCount(provider)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	current := quotaClock.Now()
+	recent := []time.Time{}
+	for _, t := range append(throttles[provider], current) {
+		if current.Sub(t) < ThrottleWindow {
+			recent = append(recent, t)
+		}
+	}
+	throttles[provider] = recent
+
+	if len(recent) >= SustainedThrottleCount && current.Sub(lastWarnings[provider]) >= ThrottleWindow {
+		lastWarnings[provider] = current
+		log.Info(fmt.Sprintf("WARNING: the %v DNS API throttled %d requests in the last %v, the operator is nearing the API rate limits of the account", provider, len(recent), ThrottleWindow))
+	}
+}
+
+// RecordRecordSetUsage reports the number of record sets of the DNS zone of provider against the
+// maximum it may hold, and logs a warning when it is above NearingLimitRatio of the maximum.
+func RecordRecordSetUsage(provider string, zone string, count int64, limit int64) {
+	zone = strings.TrimSuffix(zone, ".")
+	localmetrics.SetDNSZoneRecordSetUsage(provider, zone, count, limit)
+
+	if limit > 0 && float64(count) >= NearingLimitRatio*float64(limit) {
+		log.Info(fmt.Sprintf("WARNING: %v DNS zone %v holds %d of its %d record sets", provider, zone, count, limit))
+	}
+}
+
+// NewTransport returns a RoundTripper sending requests with base that records the responses of
+// the DNS API of provider that report throttling.
+func NewTransport(provider string, base http.RoundTripper) http.RoundTripper {
+	return &throttleTransport{provider: provider, base: base}
+}
+
+type throttleTransport struct {
+	provider string
+	base     http.RoundTripper
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && isThrottled(resp) {
+		RecordThrottle(t.provider)
+	}
+	return resp, err
+}
+
+// isThrottled returns true for a 429 response, and for the 403 responses Cloud DNS answers some
+// rate limited requests with, whose reason is rateLimitExceeded.
+func isThrottled(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode != http.StatusForbidden || resp.Body == nil {
+		return false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return err == nil && bytes.Contains(body, []byte("rateLimitExceeded"))
+}
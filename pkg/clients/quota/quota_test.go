@@ -0,0 +1,102 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportRecordsThrottledResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		throttled bool
+	}{
+		{name: "success", status: http.StatusOK, body: "{}"},
+		{name: "too many requests", status: http.StatusTooManyRequests, body: "{}", throttled: true},
+		{name: "rate limit exceeded", status: http.StatusForbidden, body: `{"error":{"errors":[{"reason":"rateLimitExceeded"}]}}`, throttled: true},
+		{name: "forbidden", status: http.StatusForbidden, body: `{"error":{"errors":[{"reason":"forbidden"}]}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := "test-" + test.name
+			transport := NewTransport(provider, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: test.status, Body: io.NopCloser(strings.NewReader(test.body))}, nil
+			}))
+
+			req, _ := http.NewRequest(http.MethodGet, "https://dns.example.com", nil)
+			resp, err := transport.RoundTrip(req)
+			assert.NoError(t, err)
+
+			// the body is still readable by the client
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, test.body, string(body))
+
+			expected := 0.0
+			if test.throttled {
+				expected = 1
+			}
+			assert.Equal(t, expected, testutil.ToFloat64(localmetrics.MetricDNSThrottledRequestCount.WithLabelValues(provider)))
+		})
+	}
+}
+
+func TestRecordThrottleDetectsSustainedThrottling(t *testing.T) {
+	fake := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	SetClock(fake)
+	defer SetClock(clock.Real{})
+
+	provider := "test-sustained"
+	for i := 0; i < SustainedThrottleCount; i++ {
+		RecordThrottle(provider)
+		fake.Step(time.Second)
+	}
+	assert.Len(t, throttles[provider], SustainedThrottleCount)
+	assert.Equal(t, fake.Now().Add(-time.Second), lastWarnings[provider])
+
+	// throttles older than the window are forgotten
+	fake.Step(ThrottleWindow)
+	RecordThrottle(provider)
+	assert.Len(t, throttles[provider], 1)
+	assert.Equal(t, float64(SustainedThrottleCount+1), testutil.ToFloat64(localmetrics.MetricDNSThrottledRequestCount.WithLabelValues(provider)))
+}
+
+func TestRecordRecordSetUsage(t *testing.T) {
+	RecordRecordSetUsage("test", "example.com.", 8500, 10000)
+
+	assert.Equal(t, 8500.0, testutil.ToFloat64(localmetrics.MetricDNSZoneRecordSets.WithLabelValues("test", "example.com")))
+	assert.Equal(t, 10000.0, testutil.ToFloat64(localmetrics.MetricDNSZoneRecordSetsLimit.WithLabelValues("test", "example.com")))
+}
@@ -0,0 +1,44 @@
+package types
+
+import "os"
+
+const (
+	fedrampEnvVariable          = "FEDRAMP"
+	FedrampHostedZoneIDVariable = "HOSTED_ZONE_ID"
+	fedrampRegionVariable       = "FEDRAMP_AWS_REGION"
+	fedrampDefaultAWSRegion     = "us-east-1"
+)
+
+// Fedramp holds the settings of a FedRAMP environment, where the challenge records of every
+// cluster are written to a single hosted zone in the AWS account of the operator.
+type Fedramp struct {
+	Enabled bool
+	// HostedZoneID is the hosted zone the records are written to
+	HostedZoneID string
+	// Region is the region of the account holding the hosted zone, a GovCloud region for instance.
+	// It is empty when FEDRAMP_AWS_REGION isn't set, see RegionOr.
+	Region string
+}
+
+// FedrampFromEnv returns the Fedramp settings of the FEDRAMP, HOSTED_ZONE_ID and FEDRAMP_AWS_REGION
+// environment variables.
+func FedrampFromEnv() Fedramp {
+	return Fedramp{
+		Enabled:      os.Getenv(fedrampEnvVariable) == "true",
+		HostedZoneID: os.Getenv(FedrampHostedZoneIDVariable),
+		Region:       os.Getenv(fedrampRegionVariable),
+	}
+}
+
+// RegionOr returns the region of the account holding the hosted zone: Region when
+// FEDRAMP_AWS_REGION sets it, else defaultRegion, the fedrampAWSRegion platform default of the
+// operator configuration, else us-east-1.
+func (f Fedramp) RegionOr(defaultRegion string) string {
+	if f.Region != "" {
+		return f.Region
+	}
+	if defaultRegion != "" {
+		return defaultRegion
+	}
+	return fedrampDefaultAWSRegion
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"strings"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/version"
+)
+
+const (
+	// DefaultChangeComment is the comment of the DNS changes when the DNSChangeMetadata of the
+	// operator doesn't set one.
+	DefaultChangeComment = "certman-operator {version}: {certificaterequest} of cluster {cluster}"
+	// maxChangeCommentLength is the longest comment of a Route53 change batch
+	maxChangeCommentLength = 256
+
+	// ClusterTag, CertificateRequestTag and OperatorVersionTag are the tags of the record sets
+	// written for a CertificateRequest, where the provider tags record sets.
+	ClusterTag            = "certman_cluster"
+	CertificateRequestTag = "certman_certificaterequest"
+	OperatorVersionTag    = "certman_operator_version"
+)
+
+// ChangeMetadata describes the DNS changes made for CertificateRequests in the audit trails of the
+// DNS providers, as configured by the DNSChangeMetadata of the operator.
+type ChangeMetadata struct {
+	comment string
+	tags    map[string]string
+}
+
+// NewChangeMetadata returns the ChangeMetadata of config, which may be nil.
+func NewChangeMetadata(config *certmanv1alpha1.DNSChangeMetadata) ChangeMetadata {
+	metadata := ChangeMetadata{comment: DefaultChangeComment}
+	if config == nil {
+		return metadata
+	}
+	if config.Comment != "" {
+		metadata.comment = config.Comment
+	}
+	metadata.tags = config.Tags
+	return metadata
+}
+
+// Comment returns the comment of the changes made for cr. cr is nil for changes that aren't made
+// for a CertificateRequest, such as the test records written to check write access to a zone.
+func (m ChangeMetadata) Comment(cr *certmanv1alpha1.CertificateRequest) string {
+	comment := strings.NewReplacer(
+		"{cluster}", clusterName(cr),
+		"{certificaterequest}", certificateRequestName(cr),
+		"{version}", version.Version,
+	).Replace(m.comment)
+	if len(comment) > maxChangeCommentLength {
+		comment = comment[:maxChangeCommentLength]
+	}
+	return comment
+}
+
+// Tags returns the tags of the record sets written for cr: the configured ones along with the
+// cluster, the CertificateRequest and the version of the operator.
+func (m ChangeMetadata) Tags(cr *certmanv1alpha1.CertificateRequest) map[string]string {
+	tags := make(map[string]string, len(m.tags)+3)
+	for key, value := range m.tags {
+		tags[key] = value
+	}
+	tags[ClusterTag] = clusterName(cr)
+	tags[CertificateRequestTag] = certificateRequestName(cr)
+	tags[OperatorVersionTag] = version.Version
+	return tags
+}
+
+// clusterName returns the namespace/name of the ClusterDeployment controlling cr, or "none" for
+// the CertificateRequests that aren't part of a cluster, such as the canary.
+func clusterName(cr *certmanv1alpha1.CertificateRequest) string {
+	if cr == nil {
+		return "none"
+	}
+	for _, owner := range cr.OwnerReferences {
+		if owner.Kind == "ClusterDeployment" && owner.Controller != nil && *owner.Controller {
+			return cr.Namespace + "/" + owner.Name
+		}
+	}
+	return "none"
+}
+
+// certificateRequestName returns the namespace/name of cr, or "none".
+func certificateRequestName(cr *certmanv1alpha1.CertificateRequest) string {
+	if cr == nil {
+		return "none"
+	}
+	return cr.Namespace + "/" + cr.Name
+}
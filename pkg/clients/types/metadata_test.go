@@ -0,0 +1,93 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/version"
+)
+
+func TestChangeMetadata(t *testing.T) {
+	controller := true
+	cr := &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mycluster-primary-cert-bundle",
+			Namespace: "uhc-production-1234",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ClusterDeployment", Name: "mycluster", Controller: &controller},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		config          *certmanv1alpha1.DNSChangeMetadata
+		cr              *certmanv1alpha1.CertificateRequest
+		expectedComment string
+		expectedTags    map[string]string
+	}{
+		{
+			name:            "defaults",
+			cr:              cr,
+			expectedComment: "certman-operator " + version.Version + ": uhc-production-1234/mycluster-primary-cert-bundle of cluster uhc-production-1234/mycluster",
+			expectedTags: map[string]string{
+				ClusterTag:            "uhc-production-1234/mycluster",
+				CertificateRequestTag: "uhc-production-1234/mycluster-primary-cert-bundle",
+				OperatorVersionTag:    version.Version,
+			},
+		},
+		{
+			name: "configured",
+			config: &certmanv1alpha1.DNSChangeMetadata{
+				Comment: "cluster {cluster} by certman",
+				Tags:    map[string]string{"team": "sre"},
+			},
+			cr:              cr,
+			expectedComment: "cluster uhc-production-1234/mycluster by certman",
+			expectedTags: map[string]string{
+				"team":                "sre",
+				ClusterTag:            "uhc-production-1234/mycluster",
+				CertificateRequestTag: "uhc-production-1234/mycluster-primary-cert-bundle",
+				OperatorVersionTag:    version.Version,
+			},
+		},
+		{
+			name:            "not for a CertificateRequest",
+			config:          &certmanv1alpha1.DNSChangeMetadata{Comment: "{certificaterequest} of {cluster}"},
+			expectedComment: "none of none",
+			expectedTags: map[string]string{
+				ClusterTag:            "none",
+				CertificateRequestTag: "none",
+				OperatorVersionTag:    version.Version,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadata := NewChangeMetadata(test.config)
+			if comment := metadata.Comment(test.cr); comment != test.expectedComment {
+				t.Errorf("expected the comment %q, got %q", test.expectedComment, comment)
+			}
+			tags := metadata.Tags(test.cr)
+			if len(tags) != len(test.expectedTags) {
+				t.Errorf("expected the tags %v, got %v", test.expectedTags, tags)
+			}
+			for key, value := range test.expectedTags {
+				if tags[key] != value {
+					t.Errorf("expected the tag %s to be %q, got %q", key, value, tags[key])
+				}
+			}
+		})
+	}
+}
+
+func TestChangeCommentTruncated(t *testing.T) {
+	metadata := NewChangeMetadata(&certmanv1alpha1.DNSChangeMetadata{Comment: strings.Repeat("a", 300)})
+	if comment := metadata.Comment(nil); len(comment) != maxChangeCommentLength {
+		t.Errorf("expected the comment to be truncated to %d characters, got %d", maxChangeCommentLength, len(comment))
+	}
+}
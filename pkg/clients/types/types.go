@@ -1,7 +1,44 @@
 package types
 
+import "errors"
+
+// ErrZoneNotFound is wrapped by the errors of DNS providers about a zone that doesn't exist, such
+// as one deleted by Hive while a cluster is deprovisioned.
+var ErrZoneNotFound = errors.New("the DNS zone does not exist")
+
 const (
 	AcmeChallengeSubDomain          = "_acme-challenge"
 	WriteValidationSubDomain        = "_certman_access_test"
 	DefaultNotificationEmailAddress = "default_notification_email_address"
+	KeepAcmeChallengeRecords        = "keep_acme_challenge_records"
+	IngressDomainPolicy             = "ingress_domain_policy"
+	AllowedDNSZones                 = "allowed_dns_zones"
+	ApprovedBaseDomains             = "approved_base_domains"
+	ChallengeValidationTimeout      = "challenge_validation_timeout"
+	ReconcileDeadline               = "reconcile_deadline"
+	AllowPartialIssuance            = "allow_partial_issuance"
+	ManageZoneRecords               = "manage_zone_records"
+	DelegatedZoneCredentials        = "delegated_zone_credentials"
+	ManagedLabelPolicy              = "managed_label_policy"
+	StrictDelegationCheck           = "strict_delegation_check"
+	ACMEEnvironment                 = "acme_environment"
+	ACMEDirectoryURL                = "acme_directory_url"
+	AllowedNamespaces               = "certificate_request_allowed_namespaces"
+	CertificateRequestSelector      = "certificate_request_selector"
+	RequesterQuotas                 = "certificate_request_requester_quotas"
+	RevokeOnDelete                  = "revoke_on_delete"
+	SyncCertificatesToClusters      = "sync_certificates_to_clusters"
+	MaintenanceWindow               = "maintenance_window"
+	UrgentRenewalDays               = "urgent_renewal_days"
+	ACMEAccountWeeklyCeiling        = "acme_account_weekly_issuance_ceiling"
+	// DisableIssuance, DisableRevocation and DisableProvider are the keys of the feature gates configmap.
+	DisableIssuance   = "DisableIssuance"
+	DisableRevocation = "DisableRevocation"
+	DisableProvider   = "DisableProvider"
+	// Features is the key of the feature gates configmap listing the features of the whole fleet.
+	Features = "Features"
+	// CAAIssuer is the issuer domain allowed by the CAA records written for clusters.
+	CAAIssuer = "letsencrypt.org"
+	// OwnershipRecordPrefix precedes the cluster ID in the ownership TXT records written for clusters.
+	OwnershipRecordPrefix = "certman-managed="
 )
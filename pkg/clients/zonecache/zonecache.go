@@ -0,0 +1,117 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package zonecache caches the DNS zones listed from a provider, so that the lookups of the zone of
+// a base domain don't list every zone of an account on each call. The DNS clients are built again
+// on every reconcile, the caches live in their packages.
+package zonecache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// DefaultTTL is how long the listed zones are used before being listed again
+const DefaultTTL = 5 * time.Minute
+
+// The results of the lookups reported in the metrics
+const (
+	resultHit  = "hit"
+	resultMiss = "miss"
+)
+
+// Cache holds the zones listed from a provider by key, the account or resource group they were
+// listed from. A nil Cache lists the zones on every lookup.
+type Cache[T any] struct {
+	provider string
+	ttl      time.Duration
+	// Clock tells the age of the listed zones
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]entry[T]
+}
+
+type entry[T any] struct {
+	zones  []T
+	listed time.Time
+}
+
+// New returns an empty Cache of the zones of provider, listed again once older than ttl.
+func New[T any](provider string, ttl time.Duration) *Cache[T] {
+	return &Cache[T]{
+		provider: provider,
+		ttl:      ttl,
+		Clock:    clock.Real{},
+		entries:  map[string]entry[T]{},
+	}
+}
+
+// Find returns the zone of key for which match returns true, and whether one matched. The zones are
+// listed with list when they aren't cached or are older than the TTL, and listed again when none of
+// the cached zones match, in case the zone was created since. Errors of list aren't cached.
+func (c *Cache[T]) Find(key string, list func() ([]T, error), match func(T) bool) (T, bool, error) {
+	if c == nil {
+		return find(list, match)
+	}
+
+	c.mu.Lock()
+	cached, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && c.Clock.Now().Sub(cached.listed) < c.ttl {
+		for _, zone := range cached.zones {
+			if match(zone) {
+				localmetrics.IncrementDNSZoneCacheLookup(c.provider, resultHit)
+				return zone, true, nil
+			}
+		}
+	}
+	localmetrics.IncrementDNSZoneCacheLookup(c.provider, resultMiss)
+
+	zones, err := list()
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	c.mu.Lock()
+	c.entries[key] = entry[T]{zones: zones, listed: c.Clock.Now()}
+	c.mu.Unlock()
+
+	return first(zones, match)
+}
+
+func find[T any](list func() ([]T, error), match func(T) bool) (T, bool, error) {
+	zones, err := list()
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	return first(zones, match)
+}
+
+func first[T any](zones []T, match func(T) bool) (T, bool, error) {
+	for _, zone := range zones {
+		if match(zone) {
+			return zone, true, nil
+		}
+	}
+	var zero T
+	return zero, false, nil
+}
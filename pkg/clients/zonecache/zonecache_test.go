@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zonecache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+func TestFind(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	zones := []string{"example.com."}
+	lists := 0
+	list := func() ([]string, error) {
+		lists++
+		return zones, nil
+	}
+	matches := func(name string) func(string) bool {
+		return func(zone string) bool { return zone == name }
+	}
+
+	cache := New[string]("test-find", time.Minute)
+	cache.Clock = fake
+	hits := localmetrics.MetricDNSZoneCacheLookupCount.WithLabelValues("test-find", resultHit)
+	misses := localmetrics.MetricDNSZoneCacheLookupCount.WithLabelValues("test-find", resultMiss)
+
+	zone, ok, err := cache.Find("project", list, matches("example.com."))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "example.com.", zone)
+	assert.Equal(t, 1, lists)
+	assert.Equal(t, 1.0, testutil.ToFloat64(misses))
+
+	// the zones are cached
+	_, ok, _ = cache.Find("project", list, matches("example.com."))
+	assert.True(t, ok)
+	assert.Equal(t, 1, lists)
+	assert.Equal(t, 1.0, testutil.ToFloat64(hits))
+
+	// a zone created since the zones were listed is found by listing them again
+	zones = append(zones, "example.org.")
+	_, ok, _ = cache.Find("project", list, matches("example.org."))
+	assert.True(t, ok)
+	assert.Equal(t, 2, lists)
+
+	// a missing zone is listed every time
+	_, ok, _ = cache.Find("project", list, matches("example.net."))
+	assert.False(t, ok)
+	assert.Equal(t, 3, lists)
+
+	// the zones are listed again once older than the TTL
+	fake.Step(time.Minute)
+	_, ok, _ = cache.Find("project", list, matches("example.com."))
+	assert.True(t, ok)
+	assert.Equal(t, 4, lists)
+
+	// the zones are cached by key
+	_, _, _ = cache.Find("other-project", list, matches("example.com."))
+	assert.Equal(t, 5, lists)
+}
+
+func TestFindErrorsAreNotCached(t *testing.T) {
+	cache := New[string]("test-errors", time.Minute)
+	listErr := errors.New("throttled")
+	lists := 0
+	list := func() ([]string, error) {
+		lists++
+		if lists == 1 {
+			return nil, listErr
+		}
+		return []string{"example.com."}, nil
+	}
+	match := func(zone string) bool { return zone == "example.com." }
+
+	_, _, err := cache.Find("project", list, match)
+	assert.Equal(t, listErr, err)
+
+	zone, ok, err := cache.Find("project", list, match)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "example.com.", zone)
+}
+
+func TestNilCacheListsEveryTime(t *testing.T) {
+	var cache *Cache[string]
+	lists := 0
+	list := func() ([]string, error) {
+		lists++
+		return []string{"example.com."}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, ok, err := cache.Find("project", list, func(zone string) bool { return zone == "example.com." })
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	assert.Equal(t, 2, lists)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	if !f.Now().Equal(start) {
+		t.Errorf("expected %s, got %s", start, f.Now())
+	}
+
+	f.Step(time.Hour)
+	if want := start.Add(time.Hour); !f.Now().Equal(want) {
+		t.Errorf("expected %s, got %s", want, f.Now())
+	}
+
+	f.Set(start)
+	if !f.Now().Equal(start) {
+		t.Errorf("expected %s, got %s", start, f.Now())
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package crds installs the CustomResourceDefinitions of the operator, for installs that aren't
+// managed by OLM.
+package crds
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+var log = logf.Log.WithName("crds")
+
+// Apply creates the CustomResourceDefinitions of the yaml manifests at the root of fsys, and updates
+// the ones that differ from their manifest. Labels and annotations set on the cluster by others are kept.
+func Apply(ctx context.Context, kubeClient client.Client, fsys fs.FS) error {
+	manifests, err := fs.Glob(fsys, "*.yaml")
+	if err != nil {
+		return err
+	}
+
+	for _, manifest := range manifests {
+		crd, err := readManifest(fsys, manifest)
+		if err != nil {
+			return err
+		}
+		if err := apply(ctx, kubeClient, crd); err != nil {
+			return fmt.Errorf("could not apply CustomResourceDefinition %s: %w", crd.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// readManifest returns the CustomResourceDefinition of the manifest name in fsys.
+func readManifest(fsys fs.FS, name string) (*apiextensionsv1.CustomResourceDefinition, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(data, crd); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path.Base(name), err)
+	}
+	if crd.Kind != "CustomResourceDefinition" || crd.Name == "" {
+		return nil, fmt.Errorf("%s is not a CustomResourceDefinition manifest", path.Base(name))
+	}
+
+	return crd, nil
+}
+
+// apply creates crd, or updates the existing CustomResourceDefinition when it differs from crd.
+func apply(ctx context.Context, kubeClient client.Client, crd *apiextensionsv1.CustomResourceDefinition) error {
+	existing := &apiextensionsv1.CustomResourceDefinition{}
+	err := kubeClient.Get(ctx, types.NamespacedName{Name: crd.Name}, existing)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info(fmt.Sprintf("creating CustomResourceDefinition %s", crd.Name))
+		return kubeClient.Create(ctx, crd)
+	}
+
+	changed := !equality.Semantic.DeepEqual(existing.Spec, crd.Spec)
+	for key, value := range crd.Annotations {
+		if existing.Annotations[key] != value {
+			if existing.Annotations == nil {
+				existing.Annotations = map[string]string{}
+			}
+			existing.Annotations[key] = value
+			changed = true
+		}
+	}
+	for key, value := range crd.Labels {
+		if existing.Labels[key] != value {
+			if existing.Labels == nil {
+				existing.Labels = map[string]string{}
+			}
+			existing.Labels[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		log.V(1).Info(fmt.Sprintf("CustomResourceDefinition %s is up to date", crd.Name))
+		return nil
+	}
+
+	log.Info(fmt.Sprintf("updating CustomResourceDefinition %s", crd.Name))
+	existing.Spec = crd.Spec
+	return kubeClient.Update(ctx, existing)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crds
+
+import (
+	"context"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const certificateRequestsCRD = "certificaterequests.certman.managed.openshift.io"
+
+func TestApply(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, apiextensionsv1.AddToScheme(scheme))
+
+	manifests := os.DirFS("../../deploy/crds")
+
+	t.Run("creates missing CRDs", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		assert.NoError(t, Apply(context.TODO(), fakeClient, manifests))
+
+		crdList := &apiextensionsv1.CustomResourceDefinitionList{}
+		assert.NoError(t, fakeClient.List(context.TODO(), crdList))
+		assert.Len(t, crdList.Items, 3)
+	})
+
+	t.Run("updates outdated CRDs and keeps their labels", func(t *testing.T) {
+		outdated := &apiextensionsv1.CustomResourceDefinition{}
+		outdated.Name = certificateRequestsCRD
+		outdated.Labels = map[string]string{"owner": "someone-else"}
+		outdated.Spec.Group = "certman.managed.openshift.io"
+		outdated.Spec.Versions = []apiextensionsv1.CustomResourceDefinitionVersion{{Name: "v1alpha0", Served: true, Storage: true}}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(outdated).Build()
+
+		assert.NoError(t, Apply(context.TODO(), fakeClient, manifests))
+
+		actual := &apiextensionsv1.CustomResourceDefinition{}
+		assert.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: certificateRequestsCRD}, actual))
+		if assert.Len(t, actual.Spec.Versions, 1) {
+			assert.Equal(t, "v1alpha1", actual.Spec.Versions[0].Name)
+		}
+		assert.Equal(t, "someone-else", actual.Labels["owner"])
+	})
+
+	t.Run("rejects other manifests", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		notACRD := fstest.MapFS{"role.yaml": {Data: []byte("apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: certman-operator\n")}}
+		assert.Error(t, Apply(context.TODO(), fakeClient, notACRD))
+	})
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporters
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	certmanaws "github.com/openshift/certman-operator/pkg/clients/aws"
+)
+
+// secretDescription describes the secrets created in AWS Secrets Manager
+const secretDescription = "TLS certificate managed by certman-operator"
+
+// newAWSExporter returns the Exporter of an AWS export, in the account and region of the cluster.
+func newAWSExporter(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.AWSPlatformSecrets, namespace string, clusterDeploymentName string, export certmanv1alpha1.CertificateExport) (Exporter, error) {
+	s, err := certmanaws.NewSession(reqLogger, kubeClient, platform.Credentials.Name, namespace, platform.Region, platform.Partition, clusterDeploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	if export.ACM != nil {
+		return &acmExporter{client: acm.New(s)}, nil
+	}
+	return &secretsManagerExporter{client: secretsmanager.New(s), secretName: export.AWSSecretsManager.SecretName}, nil
+}
+
+// secretsManagerExporter stores certificates in a secret of AWS Secrets Manager, the reference
+// being the ARN of the secret.
+type secretsManagerExporter struct {
+	client     secretsmanageriface.SecretsManagerAPI
+	secretName string
+}
+
+func (e *secretsManagerExporter) Export(reqLogger logr.Logger, certificate Certificate, reference string) (string, error) {
+	value, err := secretValue(certificate)
+	if err != nil {
+		return "", err
+	}
+
+	if reference != "" {
+		output, err := e.client.PutSecretValue(&secretsmanager.PutSecretValueInput{
+			SecretId:     aws.String(reference),
+			SecretString: aws.String(string(value)),
+		})
+		if err == nil {
+			return aws.StringValue(output.ARN), nil
+		}
+		if !isAWSNotFound(err) {
+			return "", err
+		}
+		reqLogger.Info(fmt.Sprintf("secret %s was deleted, creating it again", reference))
+	}
+
+	output, err := e.client.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(e.secretName),
+		Description:  aws.String(secretDescription),
+		SecretString: aws.String(string(value)),
+	})
+	if err == nil {
+		return aws.StringValue(output.ARN), nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != secretsmanager.ErrCodeResourceExistsException {
+		return "", err
+	}
+
+	// the status of the CertificateRequest was lost, by a restore for instance
+	reqLogger.Info(fmt.Sprintf("secret %s already exists, storing the certificate in it", e.secretName))
+	putOutput, err := e.client.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(e.secretName),
+		SecretString: aws.String(string(value)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(putOutput.ARN), nil
+}
+
+func (e *secretsManagerExporter) Delete(reqLogger logr.Logger, reference string) error {
+	// the certificate is reissued rather than recovered, and the name is free to use again
+	_, err := e.client.DeleteSecret(&secretsmanager.DeleteSecretInput{
+		SecretId:                   aws.String(reference),
+		ForceDeleteWithoutRecovery: aws.Bool(true),
+	})
+	if err != nil && !isAWSNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// acmExporter imports certificates into AWS Certificate Manager, the reference being the ARN of
+// the certificate. Renewals are imported over the previous certificate, keeping the ARN the load
+// balancers refer to. ACM only tags certificates on their first import, the tags of reimported
+// ones are added separately.
+type acmExporter struct {
+	client acmiface.ACMAPI
+}
+
+func (e *acmExporter) Export(reqLogger logr.Logger, certificate Certificate, reference string) (string, error) {
+	if len(certificate.PrivateKey) == 0 {
+		return "", ErrPrivateKeyRequired
+	}
+
+	leaf, issuers, err := splitChain(certificate.Chain)
+	if err != nil {
+		return "", err
+	}
+	input := &acm.ImportCertificateInput{
+		Certificate: leaf,
+		PrivateKey:  certificate.PrivateKey,
+	}
+	if len(issuers) > 0 {
+		input.CertificateChain = issuers
+	}
+
+	if reference != "" {
+		input.CertificateArn = aws.String(reference)
+		output, err := e.client.ImportCertificate(input)
+		if err == nil {
+			return aws.StringValue(output.CertificateArn), e.tag(reference, certificate.Tags)
+		}
+		if !isAWSNotFound(err) {
+			return "", err
+		}
+		reqLogger.Info(fmt.Sprintf("certificate %s was deleted, importing it again", reference))
+		input.CertificateArn = nil
+	}
+
+	input.Tags = acmTags(certificate.Tags)
+	output, err := e.client.ImportCertificate(input)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.CertificateArn), nil
+}
+
+func (e *acmExporter) Delete(reqLogger logr.Logger, reference string) error {
+	// a certificate still used by a load balancer can't be deleted and blocks the deletion
+	_, err := e.client.DeleteCertificate(&acm.DeleteCertificateInput{
+		CertificateArn: aws.String(reference),
+	})
+	if err != nil && !isAWSNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// tag adds tags to the certificate of reference.
+func (e *acmExporter) tag(reference string, tags map[string]string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := e.client.AddTagsToCertificate(&acm.AddTagsToCertificateInput{
+		CertificateArn: aws.String(reference),
+		Tags:           acmTags(tags),
+	})
+	return err
+}
+
+// acmTags returns tags as ACM tags, sorted by key.
+func acmTags(tags map[string]string) []*acm.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	acmTags := make([]*acm.Tag, 0, len(keys))
+	for _, key := range keys {
+		acmTags = append(acmTags, &acm.Tag{Key: aws.String(key), Value: aws.String(tags[key])})
+	}
+	return acmTags
+}
+
+// isAWSNotFound returns true when err is the error of ACM and Secrets Manager for a missing
+// resource.
+func isAWSNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporters
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/services/keyvault/v7.0/keyvault"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	certmanazure "github.com/openshift/certman-operator/pkg/clients/azure"
+)
+
+// pemContentType has Key Vault import the certificate and its key as PEM
+const pemContentType = "application/x-pem-file"
+
+// newKeyVaultExporter returns the Exporter of export in cloud, authenticated with the service
+// principal of the secretName secret.
+func newKeyVaultExporter(kubeClient client.Client, secretName string, namespace string, cloud string, export certmanv1alpha1.AzureKeyVaultExport) (Exporter, error) {
+	env, err := certmanazure.Environment(kubeClient, cloud)
+	if err != nil {
+		return nil, err
+	}
+
+	keyVaultClient, err := certmanazure.NewKeyVaultClient(kubeClient, secretName, namespace, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyVaultExporter{
+		client:          keyVaultClient,
+		vaultBaseURL:    fmt.Sprintf("https://%s.%s", export.VaultName, env.KeyVaultDNSSuffix),
+		certificateName: export.CertificateName,
+	}, nil
+}
+
+// keyVaultExporter imports certificates into an Azure Key Vault, the reference being the ID of the
+// imported version. Renewals are imported as new versions of the certificate.
+type keyVaultExporter struct {
+	client          *keyvault.BaseClient
+	vaultBaseURL    string
+	certificateName string
+}
+
+func (e *keyVaultExporter) Export(reqLogger logr.Logger, certificate Certificate, reference string) (string, error) {
+	if len(certificate.PrivateKey) == 0 {
+		return "", ErrPrivateKeyRequired
+	}
+
+	// Key Vault only reads PKCS #8 private keys
+	privateKey, err := pkcs8PrivateKey(certificate.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	bundle, err := e.client.ImportCertificate(context.TODO(), e.vaultBaseURL, e.certificateName, keyvault.CertificateImportParameters{
+		Base64EncodedCertificate: to.StringPtr(string(privateKey) + string(certificate.Chain)),
+		CertificatePolicy: &keyvault.CertificatePolicy{
+			SecretProperties: &keyvault.SecretProperties{ContentType: to.StringPtr(pemContentType)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return to.String(bundle.ID), nil
+}
+
+// Delete deletes the certificate with all its versions. The vault keeps it recoverable when soft
+// delete is enabled.
+func (e *keyVaultExporter) Delete(reqLogger logr.Logger, reference string) error {
+	_, err := e.client.DeleteCertificate(context.TODO(), e.vaultBaseURL, e.certificateName)
+	if derr, ok := err.(autorest.DetailedError); ok && derr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// pkcs8PrivateKey returns the PEM encoded private key in PKCS #8.
+func pkcs8PrivateKey(privateKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, fmt.Errorf("the private key isn't PEM encoded")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		return pem.EncodeToMemory(block), nil
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package exporters stores issued certificates in the secret stores of the cloud providers, for
+// the load balancers and services terminating TLS outside of the cluster.
+package exporters
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+const (
+	awsSecretsManagerKind = "aws-secrets-manager"
+	acmKind               = "acm"
+	azureKeyVaultKind     = "azure-key-vault"
+	gcpSecretManagerKind  = "gcp-secret-manager"
+
+	// certificateKey and privateKeyKey are the keys of the JSON documents stored in the secrets,
+	// the ones of a kubernetes TLS secret
+	certificateKey = "tls.crt"
+	privateKeyKey  = "tls.key"
+)
+
+// ErrPrivateKeyRequired is returned by the exporters storing the private key along with the
+// certificate when the CSR of the CertificateRequest was supplied, which keeps the key outside of
+// the cluster.
+var ErrPrivateKeyRequired = errors.New("the secret store needs the private key, which isn't known for a supplied CSR")
+
+// Certificate is a certificate stored by an Exporter.
+type Certificate struct {
+	// Chain is the PEM encoded certificate followed by its issuers
+	Chain []byte
+	// PrivateKey is the PEM encoded private key, it is empty for a supplied CSR
+	PrivateKey []byte
+	// Tags are set on the stored certificate by the secret stores supporting them, ACM for now
+	Tags map[string]string
+}
+
+// Exporter stores certificates in a secret store.
+type Exporter interface {
+	// Export stores certificate, replacing the one of reference when it isn't empty, and returns
+	// the reference of the stored certificate.
+	Export(reqLogger logr.Logger, certificate Certificate, reference string) (string, error)
+	// Delete removes the certificate of reference from the secret store. A missing certificate
+	// isn't an error.
+	Delete(reqLogger logr.Logger, reference string) error
+}
+
+// Builder returns the Exporter of export, authenticated with the credentials of platform.
+type Builder func(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string, export certmanv1alpha1.CertificateExport) (Exporter, error)
+
+// NewExporter returns the Exporter of export, which must be on the cloud provider of platform.
+func NewExporter(reqLogger logr.Logger, kubeClient client.Client, platform certmanv1alpha1.Platform, namespace string, clusterDeploymentName string, export certmanv1alpha1.CertificateExport) (Exporter, error) {
+	switch {
+	case export.AWSSecretsManager != nil || export.ACM != nil:
+		if platform.AWS == nil {
+			return nil, fmt.Errorf("%s needs the AWS platform", Name(export))
+		}
+		return newAWSExporter(reqLogger, kubeClient, *platform.AWS, namespace, clusterDeploymentName, export)
+	case export.AzureKeyVault != nil:
+		if platform.Azure == nil {
+			return nil, fmt.Errorf("%s needs the Azure platform", Name(export))
+		}
+		return newKeyVaultExporter(kubeClient, platform.Azure.Credentials.Name, namespace, platform.Azure.Cloud, *export.AzureKeyVault)
+	case export.GCPSecretManager != nil:
+		if platform.GCP == nil {
+			return nil, fmt.Errorf("%s needs the GCP platform", Name(export))
+		}
+		return newSecretManagerExporter(kubeClient, platform.GCP.Credentials.Name, namespace, clusterDeploymentName, *export.GCPSecretManager)
+	}
+	return nil, fmt.Errorf("the export has no secret store")
+}
+
+// Name returns the name of export in the status of the CertificateRequest, which identifies the
+// secret store and the certificate in it.
+func Name(export certmanv1alpha1.CertificateExport) string {
+	switch {
+	case export.AWSSecretsManager != nil:
+		return awsSecretsManagerKind + "/" + export.AWSSecretsManager.SecretName
+	case export.ACM != nil:
+		return acmKind
+	case export.AzureKeyVault != nil:
+		return azureKeyVaultKind + "/" + export.AzureKeyVault.VaultName + "/" + export.AzureKeyVault.CertificateName
+	case export.GCPSecretManager != nil:
+		if export.GCPSecretManager.Project == "" {
+			return gcpSecretManagerKind + "/" + export.GCPSecretManager.SecretID
+		}
+		return gcpSecretManagerKind + "/" + export.GCPSecretManager.Project + "/" + export.GCPSecretManager.SecretID
+	}
+	return ""
+}
+
+// ExportFromName returns the export named name by Name, so that the certificate of an export
+// removed from the spec of a CertificateRequest can still be deleted.
+func ExportFromName(name string) (certmanv1alpha1.CertificateExport, error) {
+	parts := strings.Split(name, "/")
+	switch {
+	case parts[0] == awsSecretsManagerKind && len(parts) == 2:
+		return certmanv1alpha1.CertificateExport{
+			AWSSecretsManager: &certmanv1alpha1.AWSSecretsManagerExport{SecretName: parts[1]},
+		}, nil
+	case parts[0] == acmKind && len(parts) == 1:
+		return certmanv1alpha1.CertificateExport{ACM: &certmanv1alpha1.ACMExport{}}, nil
+	case parts[0] == azureKeyVaultKind && len(parts) == 3:
+		return certmanv1alpha1.CertificateExport{
+			AzureKeyVault: &certmanv1alpha1.AzureKeyVaultExport{VaultName: parts[1], CertificateName: parts[2]},
+		}, nil
+	case parts[0] == gcpSecretManagerKind && len(parts) == 2:
+		return certmanv1alpha1.CertificateExport{
+			GCPSecretManager: &certmanv1alpha1.GCPSecretManagerExport{SecretID: parts[1]},
+		}, nil
+	case parts[0] == gcpSecretManagerKind && len(parts) == 3:
+		return certmanv1alpha1.CertificateExport{
+			GCPSecretManager: &certmanv1alpha1.GCPSecretManagerExport{Project: parts[1], SecretID: parts[2]},
+		}, nil
+	}
+	return certmanv1alpha1.CertificateExport{}, fmt.Errorf("unknown export %q", name)
+}
+
+// secretValue returns the JSON document holding certificate under the keys of a TLS secret.
+func secretValue(certificate Certificate) ([]byte, error) {
+	value := map[string]string{certificateKey: string(certificate.Chain)}
+	if len(certificate.PrivateKey) > 0 {
+		value[privateKeyKey] = string(certificate.PrivateKey)
+	}
+	return json.Marshal(value)
+}
+
+// splitChain returns the first certificate of chain and the PEM encoded certificates following it.
+func splitChain(chain []byte) ([]byte, []byte, error) {
+	block, rest := pem.Decode(chain)
+	if block == nil {
+		return nil, nil, fmt.Errorf("the certificate chain isn't PEM encoded")
+	}
+	return pem.EncodeToMemory(block), []byte(strings.TrimSpace(string(rest))), nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporters
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/go-logr/logr"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestExportFromName(t *testing.T) {
+	tests := []certmanv1alpha1.CertificateExport{
+		{AWSSecretsManager: &certmanv1alpha1.AWSSecretsManagerExport{SecretName: "api-certificate"}},
+		{ACM: &certmanv1alpha1.ACMExport{}},
+		{AzureKeyVault: &certmanv1alpha1.AzureKeyVaultExport{VaultName: "vault", CertificateName: "api"}},
+		{GCPSecretManager: &certmanv1alpha1.GCPSecretManagerExport{SecretID: "api-certificate"}},
+		{GCPSecretManager: &certmanv1alpha1.GCPSecretManagerExport{Project: "project", SecretID: "api-certificate"}},
+	}
+
+	for _, export := range tests {
+		name := Name(export)
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ExportFromName(name)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(parsed, export) {
+				t.Errorf("expected %+v, got %+v", export, parsed)
+			}
+		})
+	}
+
+	if _, err := ExportFromName("unknown/export"); err == nil {
+		t.Error("expected an error for an unknown export")
+	}
+}
+
+// fakeSecretsManager holds the secrets of AWS Secrets Manager by ARN.
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	secrets map[string]string
+}
+
+func (f *fakeSecretsManager) CreateSecret(input *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	arn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:" + aws.StringValue(input.Name)
+	if _, ok := f.secrets[arn]; ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException, "exists", nil)
+	}
+	f.secrets[arn] = aws.StringValue(input.SecretString)
+	return &secretsmanager.CreateSecretOutput{ARN: aws.String(arn)}, nil
+}
+
+func (f *fakeSecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	arn := aws.StringValue(input.SecretId)
+	if _, ok := f.secrets[arn]; !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+	}
+	f.secrets[arn] = aws.StringValue(input.SecretString)
+	return &secretsmanager.PutSecretValueOutput{ARN: aws.String(arn)}, nil
+}
+
+func TestSecretsManagerExporter(t *testing.T) {
+	fake := &fakeSecretsManager{secrets: map[string]string{}}
+	exporter := &secretsManagerExporter{client: fake, secretName: "api-certificate"}
+	certificate := Certificate{Chain: []byte("chain"), PrivateKey: []byte("key")}
+
+	reference, err := exporter.Export(logr.Discard(), certificate, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	value := map[string]string{}
+	if err := json.Unmarshal([]byte(fake.secrets[reference]), &value); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if value[certificateKey] != "chain" || value[privateKeyKey] != "key" {
+		t.Errorf("unexpected secret value %v", value)
+	}
+
+	// a renewal is stored in the same secret
+	certificate.Chain = []byte("renewed")
+	renewed, err := exporter.Export(logr.Discard(), certificate, reference)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if renewed != reference || len(fake.secrets) != 1 {
+		t.Errorf("expected the renewal to be stored in %s, got %s", reference, renewed)
+	}
+}
+
+// fakeACM holds the certificates imported into ACM and their tags by ARN.
+type fakeACM struct {
+	acmiface.ACMAPI
+	certificates map[string][]byte
+	tags         map[string][]*acm.Tag
+}
+
+func (f *fakeACM) ImportCertificate(input *acm.ImportCertificateInput) (*acm.ImportCertificateOutput, error) {
+	arn := aws.StringValue(input.CertificateArn)
+	if arn == "" {
+		if len(input.Tags) == 0 {
+			return nil, awserr.New(acm.ErrCodeInvalidParameterException, "expected tags", nil)
+		}
+		arn = "arn:aws:acm:us-east-1:123456789012:certificate/imported"
+		f.tags[arn] = input.Tags
+	} else if input.Tags != nil {
+		return nil, awserr.New(acm.ErrCodeInvalidParameterException, "tags can't be set when reimporting", nil)
+	}
+	f.certificates[arn] = input.Certificate
+	return &acm.ImportCertificateOutput{CertificateArn: aws.String(arn)}, nil
+}
+
+func (f *fakeACM) AddTagsToCertificate(input *acm.AddTagsToCertificateInput) (*acm.AddTagsToCertificateOutput, error) {
+	f.tags[aws.StringValue(input.CertificateArn)] = input.Tags
+	return &acm.AddTagsToCertificateOutput{}, nil
+}
+
+// testCertificate returns a PEM encoded self-signed certificate with serialNumber and its key.
+func testCertificate(t *testing.T, serialNumber int64) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "api.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestACMExporter(t *testing.T) {
+	fake := &fakeACM{certificates: map[string][]byte{}, tags: map[string][]*acm.Tag{}}
+	exporter := &acmExporter{client: fake}
+	tags := map[string]string{"certman.managed.openshift.io/cluster-id": "cluster-id"}
+
+	chain, key := testCertificate(t, 1)
+	if _, err := exporter.Export(logr.Discard(), Certificate{Chain: chain}, ""); err != ErrPrivateKeyRequired {
+		t.Errorf("expected ErrPrivateKeyRequired without a private key, got %v", err)
+	}
+
+	reference, err := exporter.Export(logr.Discard(), Certificate{Chain: chain, PrivateKey: key, Tags: tags}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a renewal is reimported over the certificate, which keeps its ARN and tags
+	renewed, renewedKey := testCertificate(t, 2)
+	reimported, err := exporter.Export(logr.Discard(), Certificate{Chain: renewed, PrivateKey: renewedKey, Tags: tags}, reference)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if reimported != reference || len(fake.certificates) != 1 || string(fake.certificates[reference]) != string(renewed) {
+		t.Errorf("expected the renewal to be reimported into %s, got %s", reference, reimported)
+	}
+	if len(fake.tags[reference]) != 1 || aws.StringValue(fake.tags[reference][0].Value) != "cluster-id" {
+		t.Errorf("expected the certificate to be tagged with the cluster ID, got %v", fake.tags[reference])
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporters
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/api/googleapi"
+	option "google.golang.org/api/option"
+	secretmanager "google.golang.org/api/secretmanager/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	certmangcp "github.com/openshift/certman-operator/pkg/clients/gcp"
+)
+
+// newSecretManagerExporter returns the Exporter of export, authenticated with the credentials of
+// the cluster, see gcp.ClusterCredentials.
+func newSecretManagerExporter(kubeClient client.Client, secretName string, namespace string, clusterDeploymentName string, export certmanv1alpha1.GCPSecretManagerExport) (Exporter, error) {
+	config, err := certmangcp.ClusterCredentials(context.Background(), kubeClient, secretName, namespace, clusterDeploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := secretmanager.NewService(context.Background(), option.WithCredentials(config))
+	if err != nil {
+		return nil, err
+	}
+
+	project := export.Project
+	if project == "" {
+		project = config.ProjectID
+	}
+	return &secretManagerExporter{
+		secrets: service.Projects.Secrets,
+		project: project,
+		secret:  fmt.Sprintf("projects/%s/secrets/%s", project, export.SecretID),
+		id:      export.SecretID,
+	}, nil
+}
+
+// secretManagerExporter stores certificates in a secret of GCP Secret Manager, the reference being
+// the resource name of the secret. Renewals are added as new versions of the secret.
+type secretManagerExporter struct {
+	secrets *secretmanager.ProjectsSecretsService
+	project string
+	// secret is the resource name of the secret
+	secret string
+	id     string
+}
+
+func (e *secretManagerExporter) Export(reqLogger logr.Logger, certificate Certificate, reference string) (string, error) {
+	value, err := secretValue(certificate)
+	if err != nil {
+		return "", err
+	}
+
+	if reference == "" {
+		if err := e.createSecret(); err != nil {
+			return "", err
+		}
+	}
+
+	err = e.addVersion(value)
+	if isGoogleAPIError(err, http.StatusNotFound) {
+		reqLogger.Info(fmt.Sprintf("secret %s was deleted, creating it again", e.secret))
+		if err := e.createSecret(); err != nil {
+			return "", err
+		}
+		err = e.addVersion(value)
+	}
+	if err != nil {
+		return "", err
+	}
+	return e.secret, nil
+}
+
+func (e *secretManagerExporter) Delete(reqLogger logr.Logger, reference string) error {
+	_, err := e.secrets.Delete(reference).Do()
+	if err != nil && !isGoogleAPIError(err, http.StatusNotFound) {
+		return err
+	}
+	return nil
+}
+
+// createSecret creates the secret, unless it already exists.
+func (e *secretManagerExporter) createSecret() error {
+	secret := &secretmanager.Secret{
+		Replication: &secretmanager.Replication{Automatic: &secretmanager.Automatic{}},
+		Labels:      map[string]string{"managed-by": "certman-operator"},
+	}
+	_, err := e.secrets.Create("projects/"+e.project, secret).SecretId(e.id).Do()
+	if err != nil && !isGoogleAPIError(err, http.StatusConflict) {
+		return err
+	}
+	return nil
+}
+
+// addVersion adds value as the latest version of the secret.
+func (e *secretManagerExporter) addVersion(value []byte) error {
+	_, err := e.secrets.AddVersion(e.secret, &secretmanager.AddSecretVersionRequest{
+		Payload: &secretmanager.SecretPayload{Data: base64.StdEncoding.EncodeToString(value)},
+	}).Do()
+	return err
+}
+
+// isGoogleAPIError returns true when err is an error of a Google API with the code status.
+func isGoogleAPIError(err error, status int) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == status
+}
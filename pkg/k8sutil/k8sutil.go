@@ -24,6 +24,10 @@ const (
 	// which is the name of the current operator
 	OperatorNameEnvVar = "OPERATOR_NAME"
 
+	// OperatorNamespaceEnvVar is the constant for env variable OPERATOR_NAMESPACE
+	// which is the namespace of the current operator, usually set from the downward API.
+	OperatorNamespaceEnvVar = "OPERATOR_NAMESPACE"
+
 	// WatchNamespaceEnvVar is the constant for env variable WATCH_NAMESPACE
 	// which is the namespace where the watch activity happens.
 	// this value is empty if the operator is running with clusterScope.
@@ -42,13 +46,10 @@ func isRunModeLocal() bool {
 	return os.Getenv(ForceRunModeEnv) == string(LocalRunMode)
 }
 
-// GetWatchNamespace returns the namespace the operator should be watching for changes
-func GetWatchNamespace() (string, error) {
-	ns, found := os.LookupEnv(WatchNamespaceEnvVar)
-	if !found {
-		return "", fmt.Errorf("%s must be set", WatchNamespaceEnvVar)
-	}
-	return ns, nil
+// GetWatchNamespace returns the namespaces the operator should be watching for changes, as a comma
+// separated list. All namespaces are watched when WATCH_NAMESPACE is unset or empty.
+func GetWatchNamespace() string {
+	return strings.TrimSpace(os.Getenv(WatchNamespaceEnvVar))
 }
 
 // GetOperatorNamespace returns the namespace the operator should be running in.
@@ -68,6 +69,18 @@ func GetOperatorNamespace() (string, error) {
 	return ns, nil
 }
 
+// ResolveOperatorNamespace returns the namespace set in OPERATOR_NAMESPACE, else the namespace of the
+// service account of the pod, else defaultNamespace.
+func ResolveOperatorNamespace(defaultNamespace string) string {
+	if ns := strings.TrimSpace(os.Getenv(OperatorNamespaceEnvVar)); ns != "" {
+		return ns
+	}
+	if ns, err := GetOperatorNamespace(); err == nil && ns != "" {
+		return ns
+	}
+	return defaultNamespace
+}
+
 // GetOperatorName return the operator name
 func GetOperatorName() (string, error) {
 	operatorName, found := os.LookupEnv(OperatorNameEnvVar)
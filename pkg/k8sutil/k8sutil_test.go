@@ -0,0 +1,47 @@
+package k8sutil
+
+import (
+	"testing"
+)
+
+func TestResolveOperatorNamespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		expected string
+	}{
+		{
+			name:     "from OPERATOR_NAMESPACE",
+			env:      "certman-test",
+			expected: "certman-test",
+		},
+		{
+			name:     "default outside a cluster",
+			expected: "certman-operator",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(OperatorNamespaceEnvVar, test.env)
+			// never read the service account namespace of the host running the tests
+			t.Setenv(ForceRunModeEnv, string(LocalRunMode))
+
+			if got := ResolveOperatorNamespace("certman-operator"); got != test.expected {
+				t.Errorf("ResolveOperatorNamespace() = %q, want %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestGetWatchNamespace(t *testing.T) {
+	t.Setenv(WatchNamespaceEnvVar, " ns1,ns2 ")
+	if got := GetWatchNamespace(); got != "ns1,ns2" {
+		t.Errorf("GetWatchNamespace() = %q, want %q", got, "ns1,ns2")
+	}
+
+	t.Setenv(WatchNamespaceEnvVar, "")
+	if got := GetWatchNamespace(); got != "" {
+		t.Errorf("GetWatchNamespace() = %q, want all namespaces", got)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"sync"
+
+	"github.com/eggsampler/acme"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/openshift/certman-operator/pkg/acmeclient"
+)
+
+// sharedAccounts are the acme clients and accounts of the account secrets, shared by the
+// concurrent reconciles so that the directory is fetched and the key parsed once per secret
+// version rather than on every reconcile.
+var sharedAccounts = &accountCaches{caches: map[string]*accountCache{}}
+
+// accountCaches holds an accountCache per account secret name, so that the CertificateRequests
+// using the accounts of different issuers don't evict each other's.
+type accountCaches struct {
+	mu     sync.Mutex
+	caches map[string]*accountCache
+}
+
+// of returns the accountCache of the account secret secretName.
+func (c *accountCaches) of(secretName string) *accountCache {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cache, ok := c.caches[secretName]
+	if !ok {
+		cache = &accountCache{}
+		c.caches[secretName] = cache
+	}
+	return cache
+}
+
+// accountCache holds the acme client and account built from one version of the account secret
+// for one ACME directory.
+type accountCache struct {
+	mu              sync.Mutex
+	uid             types.UID
+	resourceVersion string
+	directoryURL    string
+	client          acmeclient.AcmeClientInterface
+	account         acme.Account
+}
+
+// get returns the cached acme client and account when they were built from this version of secret
+// for directoryURL.
+func (c *accountCache) get(secret *corev1.Secret, directoryURL string) (acmeclient.AcmeClientInterface, acme.Account, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == nil || c.uid != secret.UID || c.resourceVersion != secret.ResourceVersion || c.directoryURL != directoryURL {
+		return nil, acme.Account{}, false
+	}
+	return c.client, c.account, true
+}
+
+// set caches the acme client and account built from secret for directoryURL, replacing those of an
+// older version or of another directory.
+func (c *accountCache) set(secret *corev1.Secret, directoryURL string, client acmeclient.AcmeClientInterface, account acme.Account) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.uid = secret.UID
+	c.resourceVersion = secret.ResourceVersion
+	c.directoryURL = directoryURL
+	c.client = client
+	c.account = account
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"testing"
+
+	"github.com/eggsampler/acme"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	acmemock "github.com/openshift/certman-operator/pkg/acmeclient/mock"
+)
+
+func TestAccountCache(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{UID: "1", ResourceVersion: "10"}}
+	client := acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{Available: true})
+	account := acme.Account{URL: testAccountURL}
+
+	cache := &accountCache{}
+	if _, _, ok := cache.get(secret, acme.LetsEncryptStaging); ok {
+		t.Fatalf("empty cache returned an account")
+	}
+
+	cache.set(secret, acme.LetsEncryptStaging, client, account)
+
+	tests := []struct {
+		name         string
+		secret       *v1.Secret
+		directoryURL string
+		expected     bool
+	}{
+		{
+			name:         "same secret version",
+			secret:       secret.DeepCopy(),
+			directoryURL: acme.LetsEncryptStaging,
+			expected:     true,
+		},
+		{
+			name:         "updated secret",
+			secret:       &v1.Secret{ObjectMeta: metav1.ObjectMeta{UID: "1", ResourceVersion: "11"}},
+			directoryURL: acme.LetsEncryptStaging,
+			expected:     false,
+		},
+		{
+			name:         "recreated secret",
+			secret:       &v1.Secret{ObjectMeta: metav1.ObjectMeta{UID: "2", ResourceVersion: "10"}},
+			directoryURL: acme.LetsEncryptStaging,
+			expected:     false,
+		},
+		{
+			name:         "other directory",
+			secret:       secret.DeepCopy(),
+			directoryURL: acme.LetsEncryptProduction,
+			expected:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotClient, gotAccount, ok := cache.get(test.secret, test.directoryURL)
+			if ok != test.expected {
+				t.Fatalf("get() = %v, want %v", ok, test.expected)
+			}
+			if ok && (gotClient != client || gotAccount.URL != account.URL) {
+				t.Errorf("get() returned a different client or account")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// acmeRegistration is the registration resource of an ACME account as kept by certbot in
+// regr.json and by lego in the registration of account.json.
+type acmeRegistration struct {
+	URI string `json:"uri"`
+}
+
+// legoAccountState is the account.json of lego.
+type legoAccountState struct {
+	Registration *acmeRegistration `json:"registration"`
+}
+
+// accountURLFromSecret returns the account URL of the account secret. account-url is used when
+// set, otherwise the URL of certbot's regr.json or of lego's account.json is imported. An empty
+// URL is returned when the secret holds none of them.
+func accountURLFromSecret(secret *corev1.Secret) (string, error) {
+	if accountURL, ok := secret.Data[letsEncryptAccountUrl]; ok {
+		return strings.TrimRight(string(accountURL), "\n"), nil
+	}
+
+	if data, ok := secret.Data[certbotAccountRegistration]; ok {
+		registration := acmeRegistration{}
+		if err := json.Unmarshal(data, &registration); err != nil {
+			return "", fmt.Errorf("could not parse certbot account %v: %w", certbotAccountRegistration, err)
+		}
+		if registration.URI == "" {
+			return "", fmt.Errorf("certbot account %v has no uri", certbotAccountRegistration)
+		}
+		return registration.URI, nil
+	}
+
+	if data, ok := secret.Data[legoAccount]; ok {
+		account := legoAccountState{}
+		if err := json.Unmarshal(data, &account); err != nil {
+			return "", fmt.Errorf("could not parse lego account %v: %w", legoAccount, err)
+		}
+		if account.Registration == nil || account.Registration.URI == "" {
+			return "", fmt.Errorf("lego account %v has no registration uri", legoAccount)
+		}
+		return account.Registration.URI, nil
+	}
+
+	return "", nil
+}
+
+// accountPrivateKeyFromSecret returns the account private key of the account secret, from
+// private-key or else from certbot's private_key.json, or nil when there is none. lego keeps its
+// key in a PEM file of its own, which goes in private-key.
+func accountPrivateKeyFromSecret(secret *corev1.Secret) []byte {
+	if keyBytes := secret.Data[letsEncryptAccountPrivateKey]; keyBytes != nil {
+		return keyBytes
+	}
+
+	return secret.Data[certbotAccountPrivateKey]
+}
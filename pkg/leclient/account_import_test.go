@@ -0,0 +1,138 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/certman-operator/config"
+)
+
+const testAccountURL = "https://acme-staging-v02.api.letsencrypt.org/acme/acct/12345"
+
+func TestAccountURLFromSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          map[string][]byte
+		expectedURL   string
+		expectedError bool
+	}{
+		{
+			name:        "account-url",
+			data:        map[string][]byte{letsEncryptAccountUrl: []byte(testAccountURL + "\n")},
+			expectedURL: testAccountURL,
+		},
+		{
+			name: "account-url takes precedence over imported state",
+			data: map[string][]byte{
+				letsEncryptAccountUrl:      []byte(testAccountURL),
+				certbotAccountRegistration: []byte(`{"uri": "https://example.com/other"}`),
+			},
+			expectedURL: testAccountURL,
+		},
+		{
+			name:        "certbot regr.json",
+			data:        map[string][]byte{certbotAccountRegistration: []byte(`{"body": {"contact": ["mailto:foo@bar.com"]}, "uri": "` + testAccountURL + `"}`)},
+			expectedURL: testAccountURL,
+		},
+		{
+			name:        "lego account.json",
+			data:        map[string][]byte{legoAccount: []byte(`{"email": "foo@bar.com", "registration": {"body": {"status": "valid"}, "uri": "` + testAccountURL + `"}}`)},
+			expectedURL: testAccountURL,
+		},
+		{
+			name:          "certbot regr.json without uri",
+			data:          map[string][]byte{certbotAccountRegistration: []byte(`{"body": {}}`)},
+			expectedError: true,
+		},
+		{
+			name:          "malformed lego account.json",
+			data:          map[string][]byte{legoAccount: []byte(`{"registration": `)},
+			expectedError: true,
+		},
+		{
+			name: "no account",
+			data: map[string][]byte{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := accountURLFromSecret(&v1.Secret{Data: test.data})
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("accountURLFromSecret() expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("accountURLFromSecret() unexpected error: %v", err)
+			}
+			if got != test.expectedURL {
+				t.Errorf("accountURLFromSecret() = %q, want %q", got, test.expectedURL)
+			}
+		})
+	}
+}
+
+func TestNewClientFromCertbotAccount(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: config.OperatorNamespace,
+			Name:      letsEncryptAccountSecretName,
+		},
+		Data: map[string][]byte{
+			certbotAccountRegistration: []byte(`{"body": {}, "uri": "` + mockAcmeAccountUrl + `"}`),
+			certbotAccountPrivateKey:   []byte(`{"kty": "EC", "crv": "P-256", "x": "ejflvU67Dt2u8Edg7wmcrG2GCKt7VKRL0Iy9LN8LILk", "y": "hIQqmGjOOWIruAGyX9yElHT49EJVjnMNJBhFywv8Nnc", "d": "qOPPRJnB_cymjXSLVVc9k-__z8MJZfSJDuYGNaGNjo4"}`),
+		},
+	}
+	testClient := fake.NewClientBuilder().WithRuntimeObjects([]runtime.Object{secret}...).Build()
+
+	accountSecret, err := GetSecret(testClient, letsEncryptAccountSecretName, config.OperatorNamespace)
+	if err != nil {
+		t.Fatalf("unexpected error getting the account secret: %q", err)
+	}
+
+	accountURL, err := accountURLFromSecret(accountSecret)
+	if err != nil {
+		t.Fatalf("unexpected error getting the account url: %q", err)
+	}
+	if accountURL != mockAcmeAccountUrl {
+		t.Errorf("account url = %q, want %q", accountURL, mockAcmeAccountUrl)
+	}
+
+	privateKey, err := getLetsEncryptAccountPrivateKey(accountSecret)
+	if err != nil {
+		t.Fatalf("unexpected error getting the account private key: %q", err)
+	}
+	if privateKey == nil {
+		t.Errorf("account private key not imported")
+	}
+
+	leclient, err := NewClient(testClient)
+	if err != nil {
+		t.Fatalf("unexpected error creating the leclient: %q", err)
+	}
+	if leclient == nil {
+		t.Errorf("leclient failed to set up")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// JWS algorithms of the account keys accepted by Let's Encrypt
+const (
+	jwsAlgorithmRS256 = "RS256"
+	jwsAlgorithmES256 = "ES256"
+	jwsAlgorithmES384 = "ES384"
+)
+
+// jsonWebKey is a private JSON Web Key (RFC 7517), as written by certbot in private_key.json.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	D string `json:"d"`
+	P string `json:"p"`
+	Q string `json:"q"`
+	// EC, D is shared with RSA
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseAccountPrivateKey parses an ACME account private key held in PEM (PKCS #1, SEC 1 or
+// PKCS #8) or as a JSON Web Key. Only the RSA and ECDSA P-256 and P-384 keys that Let's Encrypt
+// accepts are returned, the JWS algorithm of the requests follows from the type of the key.
+func parseAccountPrivateKey(data []byte) (crypto.Signer, error) {
+	data = bytes.TrimSpace(data)
+
+	var privateKey crypto.Signer
+	var err error
+	if bytes.HasPrefix(data, []byte("{")) {
+		privateKey, err = parseJSONWebKey(data)
+	} else {
+		privateKey, err = parsePEMPrivateKey(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := jwsAlgorithm(privateKey); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// parsePEMPrivateKey parses the first PEM block of data as a private key.
+func parsePEMPrivateKey(data []byte) (crypto.Signer, error) {
+	keyBlock, _ := pem.Decode(data)
+	if keyBlock == nil {
+		return nil, errors.New("account private key is neither PEM nor a JSON Web Key")
+	}
+
+	switch keyBlock.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(keyBlock.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported account private key type %T", key)
+		}
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("unsupported account private key PEM block %q", keyBlock.Type)
+}
+
+// parseJSONWebKey parses data as a private RSA or EC JSON Web Key.
+func parseJSONWebKey(data []byte) (crypto.Signer, error) {
+	jwk := jsonWebKey{}
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, fmt.Errorf("could not parse account private key JSON Web Key: %w", err)
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		return jwk.rsaPrivateKey()
+	case "EC":
+		return jwk.ecdsaPrivateKey()
+	}
+
+	return nil, fmt.Errorf("unsupported account private key JSON Web Key type %q", jwk.Kty)
+}
+
+// rsaPrivateKey returns the RSA private key of jwk.
+func (jwk jsonWebKey) rsaPrivateKey() (*rsa.PrivateKey, error) {
+	values, err := decodeJWKValues(map[string]string{"n": jwk.N, "e": jwk.E, "d": jwk.D, "p": jwk.P, "q": jwk.Q})
+	if err != nil {
+		return nil, err
+	}
+	if !values["e"].IsInt64() {
+		return nil, errors.New("JSON Web Key RSA exponent is too large")
+	}
+
+	privateKey := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: values["n"],
+			E: int(values["e"].Int64()),
+		},
+		D:      values["d"],
+		Primes: []*big.Int{values["p"], values["q"]},
+	}
+	if err := privateKey.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid JSON Web Key RSA private key: %w", err)
+	}
+	// dp, dq and qi are recomputed rather than trusted
+	privateKey.Precompute()
+
+	return privateKey, nil
+}
+
+// ecdsaPrivateKey returns the ECDSA private key of jwk.
+func (jwk jsonWebKey) ecdsaPrivateKey() (*ecdsa.PrivateKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	default:
+		return nil, fmt.Errorf("unsupported JSON Web Key curve %q", jwk.Crv)
+	}
+
+	values, err := decodeJWKValues(map[string]string{"x": jwk.X, "y": jwk.Y, "d": jwk.D})
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     values["x"],
+			Y:     values["y"],
+		},
+		D: values["d"],
+	}
+	if !curve.IsOnCurve(privateKey.X, privateKey.Y) {
+		return nil, errors.New("invalid JSON Web Key EC private key: point is not on the curve")
+	}
+	// the public key must be the one of d
+	x, y := curve.ScalarBaseMult(privateKey.D.Bytes())
+	if x.Cmp(privateKey.X) != 0 || y.Cmp(privateKey.Y) != 0 {
+		return nil, errors.New("invalid JSON Web Key EC private key: public key doesn't match the private key")
+	}
+
+	return privateKey, nil
+}
+
+// decodeJWKValues decodes the base64url encoded integers of a JSON Web Key, all of which are required.
+func decodeJWKValues(encoded map[string]string) (map[string]*big.Int, error) {
+	values := map[string]*big.Int{}
+	for name, value := range encoded {
+		if value == "" {
+			return nil, fmt.Errorf("JSON Web Key is missing %q", name)
+		}
+		// certbot writes the values unpadded, other tools may not
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return nil, fmt.Errorf("could not decode JSON Web Key %q: %w", name, err)
+		}
+		values[name] = new(big.Int).SetBytes(b)
+	}
+
+	return values, nil
+}
+
+// jwsAlgorithm returns the JWS algorithm signing the ACME requests of privateKey, or an error when
+// Let's Encrypt doesn't accept privateKey as an account key.
+func jwsAlgorithm(privateKey crypto.Signer) (string, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return jwsAlgorithmRS256, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwsAlgorithmES256, nil
+		case elliptic.P384():
+			return jwsAlgorithmES384, nil
+		}
+		return "", fmt.Errorf("unsupported account private key curve %v", key.Curve.Params().Name)
+	}
+
+	return "", fmt.Errorf("unsupported account private key type %T", privateKey)
+}
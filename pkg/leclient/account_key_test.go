@@ -0,0 +1,191 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+)
+
+func TestParseAccountPrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256Key := generateECKey(t, elliptic.P256())
+	p384Key := generateECKey(t, elliptic.P384())
+	p521Key := generateECKey(t, elliptic.P521())
+
+	tests := []struct {
+		name          string
+		data          []byte
+		expectedKey   crypto.Signer
+		expectedAlg   string
+		expectedError bool
+	}{
+		{
+			name:        "RSA PKCS #1 PEM",
+			data:        pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			expectedKey: rsaKey,
+			expectedAlg: jwsAlgorithmRS256,
+		},
+		{
+			name:        "RSA PKCS #8 PEM",
+			data:        pkcs8PEM(t, rsaKey),
+			expectedKey: rsaKey,
+			expectedAlg: jwsAlgorithmRS256,
+		},
+		{
+			name:        "RSA JSON Web Key",
+			data:        rsaJWK(t, rsaKey),
+			expectedKey: rsaKey,
+			expectedAlg: jwsAlgorithmRS256,
+		},
+		{
+			name:        "P-256 SEC 1 PEM",
+			data:        sec1PEM(t, p256Key),
+			expectedKey: p256Key,
+			expectedAlg: jwsAlgorithmES256,
+		},
+		{
+			name:        "P-384 PKCS #8 PEM",
+			data:        pkcs8PEM(t, p384Key),
+			expectedKey: p384Key,
+			expectedAlg: jwsAlgorithmES384,
+		},
+		{
+			name:        "P-256 JSON Web Key",
+			data:        ecJWK(t, p256Key, "P-256"),
+			expectedKey: p256Key,
+			expectedAlg: jwsAlgorithmES256,
+		},
+		{
+			name:        "P-384 JSON Web Key",
+			data:        ecJWK(t, p384Key, "P-384"),
+			expectedKey: p384Key,
+			expectedAlg: jwsAlgorithmES384,
+		},
+		{
+			name:          "P-521 key is rejected",
+			data:          sec1PEM(t, p521Key),
+			expectedError: true,
+		},
+		{
+			name:          "JSON Web Key with mismatched public key is rejected",
+			data:          ecJWK(t, &ecdsa.PrivateKey{PublicKey: p256Key.PublicKey, D: new(big.Int).Add(p256Key.D, big.NewInt(1))}, "P-256"),
+			expectedError: true,
+		},
+		{
+			name:          "garbage is rejected",
+			data:          []byte("not a key"),
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseAccountPrivateKey(test.data)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("parseAccountPrivateKey() expected an error, got key %T", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAccountPrivateKey() unexpected error: %v", err)
+			}
+
+			if !test.expectedKey.(interface{ Equal(crypto.PrivateKey) bool }).Equal(got) {
+				t.Errorf("parseAccountPrivateKey() returned a different key")
+			}
+
+			alg, err := jwsAlgorithm(got)
+			if err != nil {
+				t.Fatalf("jwsAlgorithm() unexpected error: %v", err)
+			}
+			if alg != test.expectedAlg {
+				t.Errorf("jwsAlgorithm() = %v, want %v", alg, test.expectedAlg)
+			}
+		})
+	}
+}
+
+func generateECKey(t *testing.T, curve elliptic.Curve) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func sec1PEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func pkcs8PEM(t *testing.T, key crypto.Signer) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func rsaJWK(t *testing.T, key *rsa.PrivateKey) []byte {
+	return marshalJWK(t, map[string]string{
+		"kty": "RSA",
+		"n":   encodeJWKValue(key.N),
+		"e":   encodeJWKValue(big.NewInt(int64(key.E))),
+		"d":   encodeJWKValue(key.D),
+		"p":   encodeJWKValue(key.Primes[0]),
+		"q":   encodeJWKValue(key.Primes[1]),
+	})
+}
+
+func ecJWK(t *testing.T, key *ecdsa.PrivateKey, crv string) []byte {
+	return marshalJWK(t, map[string]string{
+		"kty": "EC",
+		"crv": crv,
+		"x":   encodeJWKValue(key.X),
+		"y":   encodeJWKValue(key.Y),
+		"d":   encodeJWKValue(key.D),
+	})
+}
+
+func marshalJWK(t *testing.T, jwk map[string]string) []byte {
+	data, err := json.Marshal(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encodeJWKValue(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
@@ -19,6 +19,11 @@ package leclient
 const (
 	letsEncryptAccountPrivateKey = "private-key"
 	letsEncryptAccountUrl        = "account-url"
+	// certbot's account registration and JSON Web Key, imported when account-url and private-key aren't set
+	certbotAccountRegistration = "regr.json"
+	certbotAccountPrivateKey   = "private_key.json"
+	// lego's account, imported when account-url isn't set
+	legoAccount = "account.json"
 	// if letsEncryptAccountUrl is this value then a mock acme client will be used
 	mockAcmeAccountUrl = "proto://use.mock.acme.client"
 	// Deprecated, use letsEncryptAccountSecretName instead
@@ -27,3 +32,11 @@ const (
 	letsEncryptStagingAccountSecretName = "lets-encrypt-account-staging" //#nosec - G101: Potential hardcoded credentials
 	letsEncryptAccountSecretName        = "lets-encrypt-account"         //#nosec - G101: Potential hardcoded credentials
 )
+
+// ACME order, authorization and challenge statuses (RFC 8555 section 7.1.6)
+const (
+	StatusPending    = "pending"
+	StatusProcessing = "processing"
+	StatusReady      = "ready"
+	StatusValid      = "valid"
+)
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/eggsampler/acme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/controllers/utils"
+)
+
+// mockEnvironment is the environment of the mock acme client
+const mockEnvironment certmanv1alpha1.ACMEEnvironment = "Mock"
+
+var log = logf.Log.WithName("leclient")
+
+// acmeDirectory returns the ACME environment selected by the operator configuration and the URL
+// of its directory. The account of accountURL must belong to that directory. When no environment
+// is selected, the environment is guessed from the host of accountURL as older releases did.
+func acmeDirectory(kubeClient client.Client, accountURL string) (certmanv1alpha1.ACMEEnvironment, string, error) {
+	environment, customDirectoryURL, err := utils.GetACMEEnvironment(kubeClient)
+	if err != nil {
+		return "", "", err
+	}
+
+	if environment == "" {
+		u, err := url.Parse(accountURL)
+		if err != nil {
+			return "", "", err
+		}
+		directoryURL := ""
+		if strings.Contains(acme.LetsEncryptStaging, u.Host) {
+			environment, directoryURL = certmanv1alpha1.ACMEEnvironmentStaging, acme.LetsEncryptStaging
+		} else if strings.Contains(acme.LetsEncryptProduction, u.Host) {
+			environment, directoryURL = certmanv1alpha1.ACMEEnvironmentProduction, acme.LetsEncryptProduction
+		} else {
+			return "", "", errors.New("cannot found let's encrypt directory url")
+		}
+		log.Info(fmt.Sprintf("DEPRECATED: no ACME environment is configured, using %s as guessed from the account URL. "+
+			"Set acmeEnvironment in the CertmanOperatorConfig to select it explicitly", environment))
+		return environment, directoryURL, nil
+	}
+
+	return issuerDirectory(environment, customDirectoryURL, letsEncryptAccountSecretName, accountURL)
+}
+
+// issuerDirectory returns the URL of the directory of the ACME environment, customDirectoryURL for
+// the Custom environment. The account of accountURL, held by the secretName secret, must belong to
+// that directory.
+func issuerDirectory(environment certmanv1alpha1.ACMEEnvironment, customDirectoryURL, secretName, accountURL string) (certmanv1alpha1.ACMEEnvironment, string, error) {
+	u, err := url.Parse(accountURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	directoryURL := ""
+	switch environment {
+	case certmanv1alpha1.ACMEEnvironmentStaging:
+		directoryURL = acme.LetsEncryptStaging
+	case certmanv1alpha1.ACMEEnvironmentProduction:
+		directoryURL = acme.LetsEncryptProduction
+	case certmanv1alpha1.ACMEEnvironmentCustom:
+		if customDirectoryURL == "" {
+			return "", "", errors.New("the Custom ACME environment requires an ACME directory URL")
+		}
+		directoryURL = customDirectoryURL
+	default:
+		return "", "", fmt.Errorf("unknown ACME environment %q, use %s, %s or %s", environment,
+			certmanv1alpha1.ACMEEnvironmentStaging, certmanv1alpha1.ACMEEnvironmentProduction, certmanv1alpha1.ACMEEnvironmentCustom)
+	}
+
+	directory, err := url.Parse(directoryURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Host != directory.Host {
+		return "", "", fmt.Errorf("the account in the %s secret belongs to %s, not to the %s ACME directory %s",
+			secretName, u.Host, environment, directoryURL)
+	}
+
+	return environment, directoryURL, nil
+}
+
+// warnDeprecatedAccountSecrets logs the account secrets of older releases, which selected the
+// environment by their name and are no longer read.
+func warnDeprecatedAccountSecrets(kubeClient client.Client) {
+	deprecated := map[string]certmanv1alpha1.ACMEEnvironment{
+		letsEncryptStagingAccountSecretName:    certmanv1alpha1.ACMEEnvironmentStaging,
+		letsEncryptProductionAccountSecretName: certmanv1alpha1.ACMEEnvironmentProduction,
+	}
+	for name, environment := range deprecated {
+		if _, err := GetSecret(kubeClient, name, config.OperatorNamespace); err == nil {
+			log.Info(fmt.Sprintf("DEPRECATED: the %s secret is no longer used. Rename it to %s and set acmeEnvironment to %s in the CertmanOperatorConfig",
+				name, letsEncryptAccountSecretName, environment))
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2020 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leclient
+
+import (
+	"testing"
+
+	"github.com/eggsampler/acme"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+func TestACMEDirectory(t *testing.T) {
+	stagingAccount := "https://acme-staging-v02.api.letsencrypt.org/acme/acct/1"
+	productionAccount := "https://acme-v02.api.letsencrypt.org/acme/acct/1"
+
+	tests := []struct {
+		name                string
+		config              map[string]string
+		accountURL          string
+		expectErr           bool
+		expectedEnvironment certmanv1alpha1.ACMEEnvironment
+		expectedDirectory   string
+	}{
+		{
+			name:                "guessed from the account",
+			accountURL:          productionAccount,
+			expectedEnvironment: certmanv1alpha1.ACMEEnvironmentProduction,
+			expectedDirectory:   acme.LetsEncryptProduction,
+		},
+		{
+			name:                "explicit staging",
+			config:              map[string]string{cTypes.ACMEEnvironment: "Staging"},
+			accountURL:          stagingAccount,
+			expectedEnvironment: certmanv1alpha1.ACMEEnvironmentStaging,
+			expectedDirectory:   acme.LetsEncryptStaging,
+		},
+		{
+			name:       "account of another environment",
+			config:     map[string]string{cTypes.ACMEEnvironment: "Production"},
+			accountURL: stagingAccount,
+			expectErr:  true,
+		},
+		{
+			name: "custom directory",
+			config: map[string]string{
+				cTypes.ACMEEnvironment:  "Custom",
+				cTypes.ACMEDirectoryURL: "https://acme.example.com/directory",
+			},
+			accountURL:          "https://acme.example.com/acct/1",
+			expectedEnvironment: certmanv1alpha1.ACMEEnvironmentCustom,
+			expectedDirectory:   "https://acme.example.com/directory",
+		},
+		{
+			name:       "custom without directory",
+			config:     map[string]string{cTypes.ACMEEnvironment: "Custom"},
+			accountURL: "https://acme.example.com/acct/1",
+			expectErr:  true,
+		},
+		{
+			name:       "unknown environment",
+			config:     map[string]string{cTypes.ACMEEnvironment: "Testing"},
+			accountURL: stagingAccount,
+			expectErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder()
+			if test.config != nil {
+				builder = builder.WithObjects(&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName, Namespace: config.OperatorNamespace},
+					Data:       test.config,
+				})
+			}
+
+			environment, directoryURL, err := acmeDirectory(builder.Build(), test.accountURL)
+			if test.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, got environment %q", environment)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if environment != test.expectedEnvironment || directoryURL != test.expectedDirectory {
+				t.Errorf("expected %s %s, got %s %s", test.expectedEnvironment, test.expectedDirectory, environment, directoryURL)
+			}
+		})
+	}
+}
@@ -19,37 +19,51 @@ package leclient
 import (
 	"crypto"
 	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
-	"net/url"
-	"strings"
+	"time"
 
 	"github.com/eggsampler/acme"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/config"
 	"github.com/openshift/certman-operator/pkg/acmeclient"
 	acmemock "github.com/openshift/certman-operator/pkg/acmeclient/mock"
+	"github.com/openshift/certman-operator/pkg/chaos"
 )
 
 // define the LetsEncryptClientInterface interface
 type LetsEncryptClientInterface interface {
 	UpdateAccount(string) error
+	FetchAccount() error
+	GetAccountURL() string
+	GetAccountContact() []string
+	GetAccountStatus() string
 	CreateOrder([]string) error
+	FetchOrder(string) error
 	GetOrderURL() string
+	GetOrderStatus() string
+	GetOrderExpires() time.Time
 	OrderAuthorization() []string
 	FetchAuthorization(string) error
+	DeactivateAuthorization(string) error
 	GetAuthorizationURL() string
 	GetAuthorizationIndentifier() (string, error)
+	GetAuthorizationStatus() string
 	SetChallengeType()
 	GetChallengeURL() string
+	GetChallengeStatus() string
 	GetDNS01KeyAuthorization() (string, error)
 	UpdateChallenge() error
 	FinalizeOrder(*x509.CertificateRequest) error
 	GetOrderEndpoint() string
 	FetchCertificates() ([]*x509.Certificate, error)
 	RevokeCertificate(*x509.Certificate) error
+	GetEnvironment() certmanv1alpha1.ACMEEnvironment
+	GetIssuer() *certmanv1alpha1.ACMEIssuerReference
 }
 
 type LetsEncryptClient struct {
@@ -58,6 +72,21 @@ type LetsEncryptClient struct {
 	Order         acme.Order
 	Authorization acme.Authorization
 	Challenge     acme.Challenge
+	// Environment is the ACME environment of the directory Client uses
+	Environment certmanv1alpha1.ACMEEnvironment
+	// Issuer is the ACME directory and account Client uses, nil for the mock client
+	Issuer *certmanv1alpha1.ACMEIssuerReference
+}
+
+// GetEnvironment returns the ACME environment certificates are requested from.
+func (c *LetsEncryptClient) GetEnvironment() certmanv1alpha1.ACMEEnvironment {
+	return c.Environment
+}
+
+// GetIssuer returns the ACME directory and account certificates are requested from, or nil when
+// they aren't known.
+func (c *LetsEncryptClient) GetIssuer() *certmanv1alpha1.ACMEIssuerReference {
+	return c.Issuer
 }
 
 // UpdateAccount updates the ACME clients account by accepting
@@ -78,6 +107,40 @@ func (c *LetsEncryptClient) UpdateAccount(email string) (err error) {
 	return err
 }
 
+// FetchAccount loads the registration of the account of the ACME client from the ACME server,
+// with its current contacts and status. If an error occurs, it is returned.
+func (c *LetsEncryptClient) FetchAccount() (err error) {
+	account, err := c.Client.NewAccount(c.Account.PrivateKey, true, true)
+	if err != nil {
+		return err
+	}
+
+	// keep the key and URL the client was built with when the server doesn't return them
+	if account.PrivateKey == nil {
+		account.PrivateKey = c.Account.PrivateKey
+	}
+	if account.URL == "" {
+		account.URL = c.Account.URL
+	}
+	c.Account = account
+	return nil
+}
+
+// GetAccountURL returns the URL field from the ACME Account struct.
+func (c *LetsEncryptClient) GetAccountURL() string {
+	return c.Account.URL
+}
+
+// GetAccountContact returns the Contact field from the ACME Account struct.
+func (c *LetsEncryptClient) GetAccountContact() []string {
+	return c.Account.Contact
+}
+
+// GetAccountStatus returns the Status field from the ACME Account struct.
+func (c *LetsEncryptClient) GetAccountStatus() string {
+	return c.Account.Status
+}
+
 // CreateOrder accepts and appends domain names to the acme.Identifier.
 // It then calls acme.Client.NewOrder and returns nil if successful
 // and an error if an error occurs.
@@ -94,6 +157,24 @@ func (c *LetsEncryptClient) CreateOrder(domains []string) (err error) {
 	return nil
 }
 
+// FetchOrder accepts an orderURL and loads the existing ACME order at that URL, so an
+// issuance can continue with it. If an error occurs it is returned.
+func (c *LetsEncryptClient) FetchOrder(orderURL string) (err error) {
+	c.Order, err = c.Client.FetchOrder(c.Account, orderURL)
+	return err
+}
+
+// GetOrderStatus returns the Status field from the ACME Order struct.
+func (c *LetsEncryptClient) GetOrderStatus() string {
+	return c.Order.Status
+}
+
+// GetOrderExpires returns the Expires field from the ACME Order struct, the zero time when the
+// ACME server didn't set it.
+func (c *LetsEncryptClient) GetOrderExpires() time.Time {
+	return c.Order.Expires
+}
+
 // GetOrderURL returns the URL field from the ACME Order struct.
 func (c *LetsEncryptClient) GetOrderURL() string {
 	return c.Order.URL
@@ -113,6 +194,14 @@ func (c *LetsEncryptClient) FetchAuthorization(authURL string) (err error) {
 	return err
 }
 
+// DeactivateAuthorization deactivates the pending authorization at authURL, so that it no longer
+// counts against the pending authorizations limit of the account. If an error occurs it is
+// returned.
+func (c *LetsEncryptClient) DeactivateAuthorization(authURL string) (err error) {
+	c.Authorization, err = c.Client.DeactivateAuthorization(c.Account, authURL)
+	return err
+}
+
 // GetAuthorizationURL returns the URL from from the ACME Authorization struct.
 func (c *LetsEncryptClient) GetAuthorizationURL() string {
 	return c.Authorization.URL
@@ -129,6 +218,11 @@ func (c *LetsEncryptClient) GetAuthorizationIndentifier() (AuthID string, err er
 	return AuthID, err
 }
 
+// GetAuthorizationStatus returns the Status field from the ACME Authorization struct.
+func (c *LetsEncryptClient) GetAuthorizationStatus() string {
+	return c.Authorization.Status
+}
+
 // SetChallengeType sets the local ACME structs challenge
 // via the acme pkgs ChallengeMap.
 func (c *LetsEncryptClient) SetChallengeType() {
@@ -151,6 +245,11 @@ func (c *LetsEncryptClient) GetChallengeURL() string {
 	return c.Challenge.URL
 }
 
+// GetChallengeStatus returns the Status field from the ACME Challenge struct.
+func (c *LetsEncryptClient) GetChallengeStatus() string {
+	return c.Challenge.Status
+}
+
 // UpdateChallenge calls the acme UpdateChallenge func with the local ACME
 // structs Account and Challenge. If an error occurs, it is returned.
 func (c *LetsEncryptClient) UpdateChallenge() (err error) {
@@ -187,49 +286,55 @@ func (c *LetsEncryptClient) RevokeCertificate(certificate *x509.Certificate) (er
 	return err
 }
 
-// getLetsEncryptAccountPrivateKey accepts client.Client as kubeClient and retrieves the
-// letsEncrypt account secret. The PrivateKey is de
-func getLetsEncryptAccountPrivateKey(kubeClient client.Client) (privateKey crypto.Signer, err error) {
-	secret, err := GetSecret(kubeClient, letsEncryptAccountSecretName, config.OperatorNamespace)
-	if err != nil {
-		return privateKey, err
-	}
-	if secret.Data[letsEncryptAccountPrivateKey] == nil {
+// getLetsEncryptAccountPrivateKey accepts the letsEncrypt account secret and retrieves its
+// PrivateKey. The PrivateKey is decoded from PEM or a JSON Web Key, RSA and ECDSA P-256 and
+// P-384 keys are supported.
+func getLetsEncryptAccountPrivateKey(secret *corev1.Secret) (privateKey crypto.Signer, err error) {
+	keyBytes := accountPrivateKeyFromSecret(secret)
+	if keyBytes == nil {
 		return nil, fmt.Errorf("lets encrypt private key not found")
 	}
-	keyBytes := secret.Data[letsEncryptAccountPrivateKey]
 
-	keyBlock, _ := pem.Decode(keyBytes)
+	return parseAccountPrivateKey(keyBytes)
+}
 
-	switch keyBlock.Type {
-	case "RSA PRIVATE KEY":
-		privateKey, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
-		return privateKey, err
-	case "EC PRIVATE KEY":
-		privateKey, err = x509.ParseECPrivateKey(keyBlock.Bytes)
-		return privateKey, err
-	}
+// NewClient accepts a client.Client as kubeClient and calls the acme NewClient func.
+// A LetsEncryptClient is returned, along with any error that occurs. The acme client and
+// account built from the letsEncrypt account secret are shared by the LetsEncryptClients
+// until the secret changes, each LetsEncryptClient keeps its own order, authorization and
+// challenge.
+func NewClient(kubeClient client.Client) (*LetsEncryptClient, error) {
+	return newClient(kubeClient, letsEncryptAccountSecretName, func(accountURL string) (certmanv1alpha1.ACMEEnvironment, string, error) {
+		return acmeDirectory(kubeClient, accountURL)
+	})
+}
 
-	return privateKey, nil
+// NewIssuerClient returns a LetsEncryptClient for the ACME directory of issuer, using the account
+// of its account secret, or the client of the operator configuration when issuer is nil.
+func NewIssuerClient(kubeClient client.Client, issuer *certmanv1alpha1.ACMEIssuerReference) (*LetsEncryptClient, error) {
+	if issuer == nil {
+		return NewClient(kubeClient)
+	}
+	if issuer.AccountSecretName == "" {
+		return nil, errors.New("the ACME issuer has no account secret")
+	}
+	return newClient(kubeClient, issuer.AccountSecretName, func(accountURL string) (certmanv1alpha1.ACMEEnvironment, string, error) {
+		return issuerDirectory(issuer.Environment, issuer.DirectoryURL, issuer.AccountSecretName, accountURL)
+	})
 }
 
-func getLetsEncryptAccountURL(kubeClient client.Client) (url string, err error) {
-	secret, err := GetSecret(kubeClient, letsEncryptAccountSecretName, config.OperatorNamespace)
+// newClient returns a LetsEncryptClient using the account of the secretName secret, in the ACME
+// directory returned by directory for the URL of the account.
+func newClient(kubeClient client.Client, secretName string, directory func(accountURL string) (certmanv1alpha1.ACMEEnvironment, string, error)) (*LetsEncryptClient, error) {
+	secret, err := GetSecret(kubeClient, secretName, config.OperatorNamespace)
 	if err != nil {
-		return url, err
+		if kerrors.IsNotFound(err) && secretName == letsEncryptAccountSecretName {
+			warnDeprecatedAccountSecrets(kubeClient)
+		}
+		return nil, err
 	}
 
-	urlBytes := secret.Data[letsEncryptAccountUrl]
-	url = string(urlBytes)
-	url = strings.TrimRight(url, "\n")
-
-	return url, nil
-}
-
-// NewClient accepts a client.Client as kubeClient and calls the acme NewClient func.
-// A LetsEncryptClient is returned, along with any error that occurs.
-func NewClient(kubeClient client.Client) (*LetsEncryptClient, error) {
-	accountURL, err := getLetsEncryptAccountURL(kubeClient)
+	accountURL, err := accountURLFromSecret(secret)
 	if err != nil {
 		return nil, err
 	}
@@ -240,32 +345,36 @@ func NewClient(kubeClient client.Client) (*LetsEncryptClient, error) {
 			Client: acmemock.NewFakeAcmeClient(&acmemock.FakeAcmeClientOptions{
 				Available: true,
 			}),
+			Environment: mockEnvironment,
 		}
 		return &mockLEClient, err
 	}
 
-	u, err := url.Parse(accountURL)
+	environment, directoryURL, err := directory(accountURL)
 	if err != nil {
 		return nil, err
 	}
 
-	acmeClient := &LetsEncryptClient{}
+	issuer := &certmanv1alpha1.ACMEIssuerReference{Environment: environment, AccountSecretName: secretName}
+	if environment == certmanv1alpha1.ACMEEnvironmentCustom {
+		issuer.DirectoryURL = directoryURL
+	}
 
-	directoryURL := ""
-	if strings.Contains(acme.LetsEncryptStaging, u.Host) {
-		directoryURL = acme.LetsEncryptStaging
-	} else if strings.Contains(acme.LetsEncryptProduction, u.Host) {
-		directoryURL = acme.LetsEncryptProduction
-	} else {
-		return nil, errors.New("cannot found let's encrypt directory url")
+	sharedAccount := sharedAccounts.of(secretName)
+	if cachedClient, cachedAccount, ok := sharedAccount.get(secret, directoryURL); ok {
+		return &LetsEncryptClient{Client: cachedClient, Account: cachedAccount, Environment: environment, Issuer: issuer}, nil
 	}
 
-	acmeClient.Client, err = acme.NewClient(directoryURL)
+	log.Info(fmt.Sprintf("requesting certificates from the %s ACME environment at %s", environment, directoryURL))
+	acmeClient := &LetsEncryptClient{Environment: environment, Issuer: issuer}
+
+	directoryClient, err := acme.NewClient(directoryURL)
 	if err != nil {
 		return nil, err
 	}
+	acmeClient.Client = chaos.ACMEClient(directoryClient)
 
-	privateKey, err := getLetsEncryptAccountPrivateKey(kubeClient)
+	privateKey, err := getLetsEncryptAccountPrivateKey(secret)
 	if err != nil {
 		return nil, err
 	}
@@ -274,6 +383,7 @@ func NewClient(kubeClient client.Client) (*LetsEncryptClient, error) {
 		return nil, errors.New("private key cannot be empty")
 	}
 	acmeClient.Account = acme.Account{PrivateKey: privateKey, URL: accountURL}
+	sharedAccount.set(secret, directoryURL, acmeClient.Client, acmeClient.Account)
 
 	return acmeClient, nil
 }
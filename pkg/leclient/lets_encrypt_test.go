@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/eggsampler/acme"
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/config"
 	acmemock "github.com/openshift/certman-operator/pkg/acmeclient/mock"
 	v1 "k8s.io/api/core/v1"
@@ -87,6 +88,81 @@ func TestNewClient(t *testing.T) {
 	})
 }
 
+func TestNewIssuerClient(t *testing.T) {
+	pebble := &certmanv1alpha1.ACMEIssuerReference{
+		Environment:       certmanv1alpha1.ACMEEnvironmentCustom,
+		DirectoryURL:      "https://pebble.example.com/dir",
+		AccountSecretName: "pebble-account",
+	}
+
+	tests := []struct {
+		name        string
+		issuer      *certmanv1alpha1.ACMEIssuerReference
+		accountURL  string
+		expectErr   bool
+		expectedEnv certmanv1alpha1.ACMEEnvironment
+	}{
+		{
+			name:      "account secret missing",
+			issuer:    &certmanv1alpha1.ACMEIssuerReference{Environment: certmanv1alpha1.ACMEEnvironmentStaging, AccountSecretName: "staging-account"},
+			expectErr: true,
+		},
+		{
+			name:      "issuer without account secret",
+			issuer:    &certmanv1alpha1.ACMEIssuerReference{Environment: certmanv1alpha1.ACMEEnvironmentStaging},
+			expectErr: true,
+		},
+		{
+			name:       "account of another directory",
+			issuer:     pebble,
+			accountURL: "https://acme-v02.api.letsencrypt.org/acme/acct/1",
+			expectErr:  true,
+		},
+		{
+			name:        "account of the issuer",
+			issuer:      pebble,
+			accountURL:  mockAcmeAccountUrl,
+			expectedEnv: mockEnvironment,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder()
+			if test.accountURL != "" {
+				builder = builder.WithObjects(&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: pebble.AccountSecretName},
+					Data: map[string][]byte{
+						letsEncryptAccountUrl:        []byte(test.accountURL),
+						letsEncryptAccountPrivateKey: leAccountPrivKey,
+					},
+				})
+			}
+
+			leClient, err := NewIssuerClient(builder.Build(), test.issuer)
+			if test.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, got a client for %s", leClient.GetEnvironment())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %q", err)
+			}
+			if leClient.GetEnvironment() != test.expectedEnv {
+				t.Errorf("expected environment %s, got %s", test.expectedEnv, leClient.GetEnvironment())
+			}
+		})
+	}
+
+	t.Run("without an issuer", func(t *testing.T) {
+		_, err := NewIssuerClient(setUpEmptyTestClient(t), nil)
+		if !kerr.IsNotFound(err) {
+			t.Errorf("expected the missing %s secret, got %v", letsEncryptAccountSecretName, err)
+		}
+	})
+}
+
 func TestUpdateAccount(t *testing.T) {
 	tests := []struct {
 		Name                string
@@ -146,6 +222,36 @@ func TestUpdateAccount(t *testing.T) {
 	}
 }
 
+func TestFetchAccount(t *testing.T) {
+	registered := acme.Account{
+		URL:     "proto://account.url",
+		Contact: []string{"mailto:doesn't@ma.tter"},
+		Status:  "valid",
+	}
+	testLEClient := &LetsEncryptClient{
+		Client:  &acmemock.FakeAcmeClient{Available: true, Account: registered},
+		Account: acme.Account{URL: "proto://account.url"},
+	}
+
+	if err := testLEClient.FetchAccount(); err != nil {
+		t.Fatalf("FetchAccount(): got unexpected error: %s\n", err)
+	}
+	if testLEClient.GetAccountURL() != registered.URL {
+		t.Errorf("FetchAccount(): expected URL %s, got %s\n", registered.URL, testLEClient.GetAccountURL())
+	}
+	if !reflect.DeepEqual(testLEClient.GetAccountContact(), registered.Contact) {
+		t.Errorf("FetchAccount(): expected contact %v, got %v\n", registered.Contact, testLEClient.GetAccountContact())
+	}
+	if testLEClient.GetAccountStatus() != registered.Status {
+		t.Errorf("FetchAccount(): expected status %s, got %s\n", registered.Status, testLEClient.GetAccountStatus())
+	}
+
+	testLEClient.Client = &acmemock.FakeAcmeClient{Available: false}
+	if err := testLEClient.FetchAccount(); err == nil {
+		t.Errorf("FetchAccount(): expected an error when Let's Encrypt is down\n")
+	}
+}
+
 func TestCreateOrder(t *testing.T) {
 	tests := []struct {
 		Name                string
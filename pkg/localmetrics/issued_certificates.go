@@ -0,0 +1,197 @@
+// Copyright 2019 RedHat
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localmetrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/clock"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+const (
+	devshiftOrgDomain      = "devshift.org"
+	openshiftAppsComDomain = "openshiftapps.com"
+	issuedInLastDayWindow  = 24 * time.Hour
+	issuedInLastWeekWindow = 7 * 24 * time.Hour
+	defaultRefreshInterval = 5 * time.Minute
+)
+
+// metricsClock tells the time the issued certificates are counted at
+var metricsClock clock.Clock = clock.Real{}
+
+// SetClock makes the metrics read the current time from c, such as a clock.Fake in tests.
+func SetClock(c clock.Clock) {
+	metricsClock = c
+}
+
+// issuedCertificate is the current certificate of a CertificateRequest, by the base domain it was
+// issued for.
+type issuedCertificate struct {
+	domain    string
+	notBefore time.Time
+}
+
+// issuedCertificates holds the current certificate of each CertificateRequest. Recording the
+// certificate of a CertificateRequest again replaces it, so reconciles and resyncs observing the
+// same certificate don't change the counts.
+var issuedCertificates = struct {
+	sync.Mutex
+	certificates map[types.NamespacedName]issuedCertificate
+}{certificates: map[types.NamespacedName]issuedCertificate{}}
+
+// RecordCertificateIssuance records that the CertificateRequest name in namespace holds a
+// certificate for dnsNames valid from notBefore, and updates the issued certificate gauges.
+// Certificates outside of devshift.org and openshiftapps.com aren't counted.
+func RecordCertificateIssuance(namespace, name string, dnsNames []string, notBefore time.Time) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+
+	issuedCertificates.Lock()
+	if domain := issuedCertificateDomain(dnsNames); domain != "" {
+		issuedCertificates.certificates[key] = issuedCertificate{domain: domain, notBefore: notBefore}
+	} else {
+		delete(issuedCertificates.certificates, key)
+	}
+	issuedCertificates.Unlock()
+
+	RefreshIssuedCertificates(metricsClock.Now())
+}
+
+// ForgetCertificateIssuance stops counting the certificate of the deleted CertificateRequest name
+// in namespace, and updates the issued certificate gauges.
+func ForgetCertificateIssuance(namespace, name string) {
+	issuedCertificates.Lock()
+	delete(issuedCertificates.certificates, types.NamespacedName{Namespace: namespace, Name: name})
+	issuedCertificates.Unlock()
+
+	RefreshIssuedCertificates(metricsClock.Now())
+}
+
+// RefreshIssuedCertificates sets the issued certificate gauges to the number of recorded
+// certificates issued in the day and in the week before now.
+func RefreshIssuedCertificates(now time.Time) {
+	counts := map[string]map[time.Duration]float64{
+		devshiftOrgDomain:      {},
+		openshiftAppsComDomain: {},
+	}
+
+	issuedCertificates.Lock()
+	for _, certificate := range issuedCertificates.certificates {
+		for _, window := range []time.Duration{issuedInLastDayWindow, issuedInLastWeekWindow} {
+			if !certificate.notBefore.Before(now.Add(-window)) {
+				counts[certificate.domain][window]++
+			}
+		}
+	}
+	issuedCertificates.Unlock()
+
+	labels := prometheus.Labels{"name": "certman-operator"}
+	MetricCertsIssuedInLastDayDevshiftOrg.With(labels).Set(counts[devshiftOrgDomain][issuedInLastDayWindow])
+	MetricCertsIssuedInLastDayOpenshiftAppsCom.With(labels).Set(counts[openshiftAppsComDomain][issuedInLastDayWindow])
+	MetricCertsIssuedInLastWeekDevshiftOrg.With(labels).Set(counts[devshiftOrgDomain][issuedInLastWeekWindow])
+	MetricCertsIssuedInLastWeekOpenshiftAppsCom.With(labels).Set(counts[openshiftAppsComDomain][issuedInLastWeekWindow])
+}
+
+// issuedCertificateDomain returns the counted base domain the first of dnsNames belongs to, or ""
+// when it belongs to none.
+func issuedCertificateDomain(dnsNames []string) string {
+	if len(dnsNames) == 0 {
+		return ""
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(dnsNames[0]), ".")
+	for _, domain := range []string{devshiftOrgDomain, openshiftAppsComDomain} {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return domain
+		}
+	}
+	return ""
+}
+
+var _ manager.LeaderElectionRunnable = &IssuedCertificatesRecorder{}
+
+// IssuedCertificatesRecorder records the certificates already held by CertificateRequests when the
+// operator becomes the leader, so the issued certificate gauges are right from the start instead of
+// waiting for each CertificateRequest to be reconciled. Issuances are then recorded by the
+// CertificateRequest controller, and the gauges are refreshed every RefreshInterval so certificates
+// age out of the day and week windows.
+type IssuedCertificatesRecorder struct {
+	Client client.Client
+	// RefreshInterval defaults to 5 minutes
+	RefreshInterval time.Duration
+}
+
+// Start records the issued CertificateRequests, then refreshes the gauges every RefreshInterval
+// until ctx is done.
+func (r *IssuedCertificatesRecorder) Start(ctx context.Context) error {
+	r.warmUp(ctx)
+
+	interval := r.RefreshInterval
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			RefreshIssuedCertificates(metricsClock.Now())
+		}
+	}
+}
+
+// NeedLeaderElection returns true, certificates are only issued by the leader.
+func (r *IssuedCertificatesRecorder) NeedLeaderElection() bool {
+	return true
+}
+
+// warmUp records the certificate of each issued CertificateRequest from its status.
+func (r *IssuedCertificatesRecorder) warmUp(ctx context.Context) {
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := r.Client.List(ctx, crList); err != nil {
+		logger.Error(err, "could not list CertificateRequests to record their certificates")
+		return
+	}
+
+	issuedCertificates.Lock()
+	for _, cr := range crList.Items {
+		if !cr.Status.Issued || cr.DeletionTimestamp != nil {
+			continue
+		}
+		notBefore, err := time.Parse(certmanv1alpha1.StatusTimeLayout, cr.Status.NotBefore)
+		if err != nil {
+			logger.Error(err, "could not parse the notBefore of the certificate", logging.CertificateRequestKey, logging.ObjectName(cr.Namespace, cr.Name))
+			continue
+		}
+		if domain := issuedCertificateDomain(cr.Spec.DnsNames); domain != "" {
+			issuedCertificates.certificates[types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}] = issuedCertificate{domain: domain, notBefore: notBefore}
+		}
+	}
+	issuedCertificates.Unlock()
+
+	RefreshIssuedCertificates(metricsClock.Now())
+}
@@ -0,0 +1,79 @@
+package localmetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/pkg/clock"
+)
+
+func TestIssuedCertificates(t *testing.T) {
+	labels := prometheus.Labels{"name": "certman-operator"}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(now)
+	SetClock(fakeClock)
+	defer SetClock(clock.Real{})
+
+	scheme := runtime.NewScheme()
+	if err := certmanv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	issued := func(name string, dnsName string, notBefore time.Time) *certmanv1alpha1.CertificateRequest {
+		return &certmanv1alpha1.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "uhc-cluster", Name: name},
+			Spec:       certmanv1alpha1.CertificateRequestSpec{DnsNames: []string{dnsName}},
+			Status:     certmanv1alpha1.CertificateRequestStatus{Issued: true, NotBefore: notBefore.UTC().Truncate(time.Second).String()},
+		}
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		issued("today", "api.cluster.abcd.s1.openshiftapps.com", now.Add(-time.Hour)),
+		issued("this-week", "api.cluster.abcd.s1.openshiftapps.com", now.Add(-72*time.Hour)),
+		issued("last-month", "api.cluster.abcd.s1.devshift.org", now.Add(-30*24*time.Hour)),
+		issued("elsewhere", "api.cluster.example.com", now.Add(-time.Hour)),
+	).Build()
+
+	recorder := &IssuedCertificatesRecorder{Client: fakeClient}
+	recorder.warmUp(context.TODO())
+
+	if actual := testutil.ToFloat64(MetricCertsIssuedInLastDayOpenshiftAppsCom.With(labels)); actual != 1 {
+		t.Errorf("expected 1 openshiftapps.com certificate in the last day after warm-up, got %v", actual)
+	}
+	if actual := testutil.ToFloat64(MetricCertsIssuedInLastWeekOpenshiftAppsCom.With(labels)); actual != 2 {
+		t.Errorf("expected 2 openshiftapps.com certificates in the last week after warm-up, got %v", actual)
+	}
+	if actual := testutil.ToFloat64(MetricCertsIssuedInLastWeekDevshiftOrg.With(labels)); actual != 0 {
+		t.Errorf("expected no devshift.org certificate in the last week after warm-up, got %v", actual)
+	}
+
+	// observing the same certificate again doesn't count it twice
+	RecordCertificateIssuance("uhc-cluster", "today", []string{"api.cluster.abcd.s1.openshiftapps.com"}, now.Add(-time.Hour))
+	if actual := testutil.ToFloat64(MetricCertsIssuedInLastDayOpenshiftAppsCom.With(labels)); actual != 1 {
+		t.Errorf("expected 1 openshiftapps.com certificate in the last day after a resync, got %v", actual)
+	}
+
+	// a renewal replaces the previous certificate of the CertificateRequest
+	RecordCertificateIssuance("uhc-cluster", "last-month", []string{"api.cluster.abcd.s1.devshift.org"}, now)
+	if actual := testutil.ToFloat64(MetricCertsIssuedInLastDayDevshiftOrg.With(labels)); actual != 1 {
+		t.Errorf("expected 1 devshift.org certificate in the last day after a renewal, got %v", actual)
+	}
+
+	ForgetCertificateIssuance("uhc-cluster", "this-week")
+	if actual := testutil.ToFloat64(MetricCertsIssuedInLastWeekOpenshiftAppsCom.With(labels)); actual != 1 {
+		t.Errorf("expected 1 openshiftapps.com certificate in the last week after a deletion, got %v", actual)
+	}
+
+	// certificates age out of the windows
+	fakeClock.Step(8 * 24 * time.Hour)
+	ForgetCertificateIssuance("uhc-cluster", "elsewhere")
+	if actual := testutil.ToFloat64(MetricCertsIssuedInLastWeekOpenshiftAppsCom.With(labels)); actual != 0 {
+		t.Errorf("expected no openshiftapps.com certificate in the last week a week later, got %v", actual)
+	}
+}
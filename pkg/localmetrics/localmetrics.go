@@ -18,10 +18,14 @@ import (
 	"context"
 	"crypto/x509"
 	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 	"github.com/openshift/certman-operator/controllers/utils"
+	"github.com/openshift/certman-operator/pkg/logging"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -30,6 +34,9 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// FinalizerBlockedDeletionThreshold is how long the certman finalizer may hold up a deletion before it is reported.
+const FinalizerBlockedDeletionThreshold = 15 * time.Minute
+
 var (
 	MetricCertsIssuedInLastDayDevshiftOrg = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "certman_operator_certs_in_last_day_devshift_org",
@@ -74,7 +81,7 @@ var (
 	MetricCertIssuanceRate = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "certman_operator_issued_certificates_count",
 		Help: "Counter on the number of issued certificate",
-	}, []string{"name", "action"})
+	}, []string{"name", "action", "environment"})
 	MetricCertValidDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name:        "certman_operator_certificate_valid_duration_days",
 		Help:        "The number of days for which the certificate remains valid",
@@ -88,6 +95,177 @@ var (
 		Name: "cloudflare_failed_requests_count",
 		Help: "Counter on the number of failed DNS requests",
 	})
+	MetricReconcilePanicCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_reconcile_panics_count",
+		Help: "Counter on the number of panics recovered while reconciling",
+	}, []string{"controller"})
+	MetricFinalizerBlockedDeletionDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_finalizer_blocked_deletion_duration_seconds",
+		Help: "How long the deletion of an object has been blocked by the certman finalizer, once past the reporting threshold",
+	}, []string{"kind", "namespace", "name", "reason"})
+	MetricFinalizerBlockedDeletionCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_finalizer_blocked_deletions_count",
+		Help: "Counter on the number of deletions that became blocked by the certman finalizer past the reporting threshold",
+	}, []string{"kind", "namespace", "name", "reason"})
+	MetricSkippedDNSCleanupCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_skipped_dns_cleanups_count",
+		Help: "Counter on the number of DNS record cleanups skipped because the DNS zone was already deleted",
+	}, []string{"records", "reason"})
+	MetricFailedDNSCleanupCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_failed_dns_cleanups_count",
+		Help: "Counter on the number of DNS record cleanups that failed, leaving records behind",
+	}, []string{"records"})
+	MetricReconcileDeadlineExceededCount = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "certman_operator_reconcile_deadline_exceeded_count",
+		Help: "Counter on the number of issuances checkpointed and requeued for running past the reconcile deadline",
+	})
+	MetricDNSZoneLockWaitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "certman_operator_dns_zone_lock_wait_duration_seconds",
+		Help:        "The duration spent waiting for the lock of a DNS zone before writing challenge records",
+		ConstLabels: prometheus.Labels{"name": "certman-operator"},
+		Buckets:     []float64{0.1, 1, 5, 15, 30, 60, 120, 300, 600, 900},
+	})
+	MetricFedrampZoneCheckSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "certman_operator_fedramp_zone_check_success",
+		Help:        "Report whether the startup check of the FedRAMP hosted zone succeeded (1) or not (0)",
+		ConstLabels: prometheus.Labels{"name": "certman-operator"},
+	})
+	MetricMissingPermission = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_missing_permission",
+		Help: "Report the permissions the operator needs that its service account lacks, as found by the startup RBAC check",
+	}, []string{"group", "resource", "verb", "critical"})
+	MetricUnlabeledManagedCluster = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_unlabeled_managed_cluster",
+		Help: "Report ClusterDeployments of managed products that lack the managed label and get no certificates",
+	}, []string{"namespace", "name"})
+	MetricClusterMissingDependency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_cluster_missing_dependency",
+		Help: "Report ClusterDeployments waiting for a referenced secret before their certificates can be synced",
+	}, []string{"namespace", "name", "dependency"})
+	MetricObservedCertificateRequestChanges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_observed_certificate_request_changes",
+		Help: "Report the changes to the CertificateRequests of ClusterDeployments that observation mode doesn't make",
+	}, []string{"namespace", "name", "action"})
+	MetricDelegationMismatch = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_dns_delegation_mismatch",
+		Help: "Report CertificateRequests whose base domain isn't delegated to the nameservers of its cloud provider zone",
+	}, []string{"namespace", "name"})
+	MetricCertificateRequestNotAuthorized = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_certificate_request_not_authorized",
+		Help: "Report CertificateRequests the CertificateRequest policy of the operator doesn't allow",
+	}, []string{"namespace", "name"})
+	MetricCertificateRequestStalled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_certificate_request_stalled",
+		Help: "Report CertificateRequests that existed longer than the stalled threshold without ever being issued a certificate",
+	}, []string{"namespace", "name"})
+	MetricOrphanedCertificateSecret = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_orphaned_certificate_secret",
+		Help: "Report the certificate secrets whose CertificateRequest no longer exists and that weren't deleted",
+	}, []string{"namespace", "name"})
+	MetricClusterCertificatePush = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_cluster_certificate_push_success",
+		Help: "Report whether the certificates were last pushed directly to the cluster of a ClusterDeployment whose SyncSets are paused (1) or not (0)",
+	}, []string{"namespace", "name"})
+	MetricCertificateUnhealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_certificate_unhealthy",
+		Help: "Report whether the certificate secret of a CertificateRequest is missing, can't be parsed or holds an expired certificate (1) or not (0)",
+	}, []string{"namespace", "cr"})
+	MetricCorruptCertificateRecoveryCount = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "certman_operator_corrupt_certificate_recoveries_count",
+		Help: "Counter on the number of corrupt certificates stored in secrets that were replaced by a reissued one",
+	})
+	MetricAbandonedOrderCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_abandoned_orders_count",
+		Help: "Counter on the number of ACME orders abandoned before being finalized",
+	}, []string{"reason"})
+	MetricCertificateSecretSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_certificate_secret_size_bytes",
+		Help: "The size of the data of the certificate secret of a CertificateRequest, as limited by the apiserver",
+	}, []string{"namespace", "name"})
+	MetricDNSThrottledRequestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_dns_api_throttled_requests_count",
+		Help: "Counter on the number of DNS provider API requests throttled by the provider",
+	}, []string{"provider"})
+	MetricDNSZoneRecordSets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_dns_zone_record_sets",
+		Help: "The number of record sets of a DNS zone, where the provider reports it",
+	}, []string{"provider", "zone"})
+	MetricDNSZoneRecordSetsLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_dns_zone_record_sets_limit",
+		Help: "The maximum number of record sets of a DNS zone, where the provider reports it",
+	}, []string{"provider", "zone"})
+	MetricDNSZoneCacheLookupCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_dns_zone_cache_lookups_count",
+		Help: "Counter on the number of DNS zone lookups of a provider answered from the zone cache (hit) or by listing the zones (miss)",
+	}, []string{"provider", "result"})
+	MetricDNSZoneListPageCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_dns_zone_list_pages_count",
+		Help: "Counter on the number of pages of DNS zones listed from a provider",
+	}, []string{"provider"})
+	MetricChaosInjectedFaultCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_chaos_injected_faults_count",
+		Help: "Counter on the number of faults injected into the calls of the ACME and DNS clients in chaos mode",
+	}, []string{"target", "fault"})
+	MetricSecretOwnerReferenceRepairCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_secret_owner_reference_repairs_count",
+		Help: "Counter on the number of secrets whose owner references to a previous CertificateRequest were repaired",
+	}, []string{"secret"})
+	MetricCanarySuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_canary_success",
+		Help: "Report whether the canary certificate is issued and renewed on schedule (1) or not (0)",
+	}, []string{"domain"})
+	MetricCanaryLastIssuance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_canary_last_issuance_timestamp_seconds",
+		Help: "The notBefore time of the current canary certificate",
+	}, []string{"domain"})
+	MetricBuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_build_info",
+		Help: "Report the build and the configuration of the running operator, always 1",
+	}, []string{"version", "git_sha", "go_version", "fedramp", "config_hash"})
+	MetricACMEAccountStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_acme_account_status",
+		Help: "Report the status of the ACME account registration of the operator, always 1",
+	}, []string{"environment", "status"})
+	MetricACMEAccountContactDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_acme_account_contact_drift",
+		Help: "Report whether the contact of the ACME account differs from the default notification email (1) or not (0)",
+	}, []string{"environment"})
+	MetricACMEAccountContactUpdateCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_acme_account_contact_updates_count",
+		Help: "Counter on the number of times the contact of the ACME account was updated to the default notification email",
+	}, []string{"environment"})
+	MetricACMEAccountIssuedInLastWeek = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_acme_account_issued_certificates_in_last_week",
+		Help: "Report the number of certificates the operator issued with each ACME account in the last 7 days",
+	}, []string{"account"})
+	MetricACMEAccountIssuanceCeilingCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_acme_account_issuance_ceiling_deferrals_count",
+		Help: "Counter on the number of issuances deferred because the ACME account reached its weekly issuance ceiling",
+	}, []string{"account"})
+	MetricPoisonPillSkipCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_poison_pill_skipped_reconciles_count",
+		Help: "Counter on the number of reconciles skipped because the object is marked as a poison pill",
+	}, []string{"controller"})
+	MetricFeatureGateSkipCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_feature_gate_skipped_operations_count",
+		Help: "Counter on the number of operations skipped because a feature gate disables them",
+	}, []string{"gate"})
+	MetricCloudCredentialAcquired = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_cloud_credential_acquired_timestamp_seconds",
+		Help: "The time the short lived cloud credentials of a provider were last acquired",
+	}, []string{"provider"})
+	MetricCloudCredentialExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_cloud_credential_expiry_timestamp_seconds",
+		Help: "The time the cloud credentials of a provider last acquired expire at",
+	}, []string{"provider"})
+	MetricCloudCredentialFailureCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "certman_operator_cloud_credential_failures_count",
+		Help: "Counter on the number of failures to acquire or refresh the cloud credentials of a provider",
+	}, []string{"provider"})
+	MetricCloudCredentialConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "certman_operator_cloud_credential_consecutive_failures",
+		Help: "The number of failures to acquire or refresh the cloud credentials of a provider since they were last acquired",
+	}, []string{"provider"})
 
 	MetricsList = []prometheus.Collector{
 		MetricCertsIssuedInLastDayDevshiftOrg,
@@ -103,80 +281,112 @@ var (
 		MetricDnsErrorCount,
 		MetricCertValidDuration,
 		MetricLetsEncryptMaintenanceErrorCount,
+		MetricReconcilePanicCount,
+		MetricPoisonPillSkipCount,
+		MetricFinalizerBlockedDeletionDuration,
+		MetricFinalizerBlockedDeletionCount,
+		MetricSkippedDNSCleanupCount,
+		MetricFailedDNSCleanupCount,
+		MetricReconcileDeadlineExceededCount,
+		MetricDNSZoneLockWaitDuration,
+		MetricFedrampZoneCheckSuccess,
+		MetricMissingPermission,
+		MetricUnlabeledManagedCluster,
+		MetricClusterMissingDependency,
+		MetricObservedCertificateRequestChanges,
+		MetricDelegationMismatch,
+		MetricCertificateRequestNotAuthorized,
+		MetricCertificateRequestStalled,
+		MetricOrphanedCertificateSecret,
+		MetricClusterCertificatePush,
+		MetricCertificateUnhealthy,
+		MetricCorruptCertificateRecoveryCount,
+		MetricAbandonedOrderCount,
+		MetricCertificateSecretSize,
+		MetricDNSThrottledRequestCount,
+		MetricDNSZoneRecordSets,
+		MetricDNSZoneRecordSetsLimit,
+		MetricDNSZoneCacheLookupCount,
+		MetricDNSZoneListPageCount,
+		MetricChaosInjectedFaultCount,
+		MetricSecretOwnerReferenceRepairCount,
+		MetricCanarySuccess,
+		MetricCanaryLastIssuance,
+		MetricBuildInfo,
+		MetricACMEAccountStatus,
+		MetricACMEAccountContactDrift,
+		MetricACMEAccountContactUpdateCount,
+		MetricACMEAccountIssuedInLastWeek,
+		MetricACMEAccountIssuanceCeilingCount,
+		MetricFeatureGateSkipCount,
+		MetricCloudCredentialAcquired,
+		MetricCloudCredentialExpiry,
+		MetricCloudCredentialFailureCount,
+		MetricCloudCredentialConsecutiveFailures,
 	}
-	areCountInitialized = false
-	logger              = logf.Log.WithName("localmetrics")
+	logger = logf.Log.WithName("localmetrics")
 )
 
-// Init Initialize the counter at start of the operator
-// Current version does not support well multiple instances of the operator to run on the same Hive cluster
-// In case of error, we don't raise the error as not impactful and Init will be retried next call, pushing correct value
+// certRequests holds the CertificateRequests holding the certman finalizer. Recording and
+// forgetting a CertificateRequest is idempotent, so a finalizer added again after being stripped,
+// or a finalization retried after a failure, doesn't make MetricCertRequestsCount drift.
+var certRequests = struct {
+	sync.Mutex
+	initialized bool
+	names       map[types.NamespacedName]struct{}
+}{names: map[types.NamespacedName]struct{}{}}
+
+// CheckInitCounter records the CertificateRequests holding the certman finalizer the first time it
+// is called. Current version does not support well multiple instances of the operator to run on
+// the same Hive cluster. In case of error, we don't raise the error as not impactful and the list
+// is retried on the next call.
 func CheckInitCounter(c client.Client) {
-	if !areCountInitialized {
-		ctx := context.TODO()
-		counter := 0.0
+	certRequests.Lock()
+	defer certRequests.Unlock()
 
-		var certRequestList certmanv1alpha1.CertificateRequestList
+	if certRequests.initialized {
+		return
+	}
 
-		if err := c.List(ctx, &certRequestList, &client.ListOptions{}); err != nil {
-			logger.Error(err, "Failed to Init counter for Certificate Request")
-		}
+	var certRequestList certmanv1alpha1.CertificateRequestList
+	if err := c.List(context.TODO(), &certRequestList, &client.ListOptions{}); err != nil {
+		logger.Error(err, "Failed to Init counter for Certificate Request")
+		return
+	}
 
-		for _, cr := range certRequestList.Items {
-			if utils.ContainsString(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
-				counter++
-			}
+	for _, cr := range certRequestList.Items {
+		if utils.ContainsString(cr.ObjectMeta.Finalizers, certmanv1alpha1.CertmanOperatorFinalizerLabel) {
+			certRequests.names[types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}] = struct{}{}
 		}
-
-		MetricCertRequestsCount.Set(counter)
-		areCountInitialized = true
 	}
-}
 
-// UpdateCertsIssuedInLastDayGauge sets the gauge metric with the number of certs issued in last day
-func UpdateCertsIssuedInLastDayGauge() {
-
-	// Set these to certman calls
-	devshiftCertCount := GetCountOfCertsIssued("devshift.org", 1)
-	openshiftAppCertCount := GetCountOfCertsIssued("openshiftapps.com", 1)
-
-	MetricCertsIssuedInLastDayDevshiftOrg.With(prometheus.Labels{"name": "certman-operator"}).Set(float64(devshiftCertCount))
-	MetricCertsIssuedInLastDayOpenshiftAppsCom.With(prometheus.Labels{"name": "certman-operator"}).Set(float64(openshiftAppCertCount))
+	MetricCertRequestsCount.Set(float64(len(certRequests.names)))
+	certRequests.initialized = true
 }
 
-// UpdateCertsIssuedInLastWeekGauge sets the gauge metric with the number of certs issued in last week
-func UpdateCertsIssuedInLastWeekGauge() {
+// RecordCertRequest counts the CertificateRequest name in namespace, which holds the certman
+// finalizer. Recording it again doesn't change the count.
+func RecordCertRequest(namespace, name string) {
+	certRequests.Lock()
+	defer certRequests.Unlock()
 
-	// Set these to certman calls
-	devshiftCertCount := GetCountOfCertsIssued("devshift.org", 7)
-	openshiftAppCertCount := GetCountOfCertsIssued("openshiftapps.com", 7)
-
-	MetricCertsIssuedInLastWeekDevshiftOrg.With(prometheus.Labels{"name": "certman-operator"}).Set(float64(devshiftCertCount))
-	MetricCertsIssuedInLastWeekOpenshiftAppsCom.With(prometheus.Labels{"name": "certman-operator"}).Set(float64(openshiftAppCertCount))
+	certRequests.names[types.NamespacedName{Namespace: namespace, Name: name}] = struct{}{}
+	MetricCertRequestsCount.Set(float64(len(certRequests.names)))
 }
 
-// UpdateMetrics updates all the metrics every N hours
-func UpdateMetrics(hour int) {
-	d := time.Duration(hour) * time.Hour
-	for range time.Tick(d) {
-		UpdateCertsIssuedInLastDayGauge()
-		UpdateCertsIssuedInLastWeekGauge()
-	}
-}
-
-// IncrementCertRequestsCounter Increment the count of certificate requests
-func IncrementCertRequestsCounter() {
-	MetricCertRequestsCount.Inc()
-}
+// ForgetCertRequest stops counting the finalized or deleted CertificateRequest name in namespace.
+// Forgetting it again doesn't change the count.
+func ForgetCertRequest(namespace, name string) {
+	certRequests.Lock()
+	defer certRequests.Unlock()
 
-// DecrementCertRequestsCounter Decrement the count of certificate requests
-func DecrementCertRequestsCounter() {
-	MetricCertRequestsCount.Dec()
+	delete(certRequests.names, types.NamespacedName{Namespace: namespace, Name: name})
+	MetricCertRequestsCount.Set(float64(len(certRequests.names)))
 }
 
-// AddCertificateIssuance Increment the count of issued certificate
-func AddCertificateIssuance(action string) {
-	MetricCertIssuanceRate.With(prometheus.Labels{"name": "certman-operator", "action": action}).Inc()
+// AddCertificateIssuance Increment the count of certificates issued from the ACME environment
+func AddCertificateIssuance(action string, environment string) {
+	MetricCertIssuanceRate.With(prometheus.Labels{"name": "certman-operator", "action": action, "environment": strings.ToLower(environment)}).Inc()
 }
 
 // UpdateCertValidDuration updates the Prometheus metric for certificate validity duration.
@@ -187,7 +397,7 @@ func UpdateCertValidDuration(kubeClient client.Client, cert *x509.Certificate, n
 	// If kubeClient is available, check for decommissioning
 	if kubeClient != nil {
 		if isClusterDecommissioned(kubeClient, clusterName, namespace) {
-			logger.Info("Cluster is decommissioned, skipping metric update", "clusterName", clusterName)
+			logger.Info("Cluster is decommissioned, skipping metric update", logging.ClusterKey, clusterName)
 			return
 		}
 	}
@@ -212,7 +422,7 @@ func UpdateCertValidDuration(kubeClient client.Client, cert *x509.Certificate, n
 func isClusterDecommissioned(kubeClient client.Client, clusterName, namespace string) bool {
 	decommissioned, err := IsClusterDecommissioned(kubeClient, clusterName, namespace)
 	if err != nil {
-		logger.Error(err, "Error checking if cluster is decommissioned", "clusterName", clusterName)
+		logger.Error(err, "Error checking if cluster is decommissioned", logging.ClusterKey, clusterName)
 		return false
 	}
 	return decommissioned
@@ -248,3 +458,360 @@ func IncrementLetsEncryptMaintenanceErrorCount() {
 func IncrementDnsErrorCount() {
 	MetricDnsErrorCount.Inc()
 }
+
+// IncrementReconcilePanicCount Increment the count of panics recovered in the given controller
+func IncrementReconcilePanicCount(controller string) {
+	MetricReconcilePanicCount.With(prometheus.Labels{"controller": controller}).Inc()
+}
+
+// IncrementPoisonPillSkipCount Increment the count of poison pill reconciles skipped by the given controller
+func IncrementPoisonPillSkipCount(controller string) {
+	MetricPoisonPillSkipCount.With(prometheus.Labels{"controller": controller}).Inc()
+}
+
+// IncrementFeatureGateSkipCount Increment the count of operations skipped because of the given feature gate
+func IncrementFeatureGateSkipCount(gate string) {
+	MetricFeatureGateSkipCount.With(prometheus.Labels{"gate": gate}).Inc()
+}
+
+// blockedDeletions holds the objects whose deletion is reported as blocked by the certman
+// finalizer, so MetricFinalizerBlockedDeletionCount counts each blocked deletion once rather than
+// each failed attempt to finalize it.
+var blockedDeletions = struct {
+	sync.Mutex
+	objects map[blockedDeletion]struct{}
+}{objects: map[blockedDeletion]struct{}{}}
+
+type blockedDeletion struct {
+	kind string
+	types.NamespacedName
+}
+
+// UpdateFinalizerBlockedDeletion reports the kind object namespace/name whose finalizer could not be removed because
+// of reason, once its deletion has been pending for longer than FinalizerBlockedDeletionThreshold. The deletion is
+// counted when it first becomes blocked.
+func UpdateFinalizerBlockedDeletion(kind, namespace, name, reason string, deletionTimestamp, now time.Time) {
+	blocked := now.Sub(deletionTimestamp)
+	if blocked < FinalizerBlockedDeletionThreshold {
+		return
+	}
+
+	blockedDeletions.Lock()
+	defer blockedDeletions.Unlock()
+
+	// the reason may have changed since the last attempt
+	objectLabels := prometheus.Labels{"kind": kind, "namespace": namespace, "name": name}
+	MetricFinalizerBlockedDeletionDuration.DeletePartialMatch(objectLabels)
+
+	labels := prometheus.Labels{"kind": kind, "namespace": namespace, "name": name, "reason": reason}
+	MetricFinalizerBlockedDeletionDuration.With(labels).Set(blocked.Seconds())
+
+	object := blockedDeletion{kind: kind, NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+	if _, reported := blockedDeletions.objects[object]; !reported {
+		blockedDeletions.objects[object] = struct{}{}
+		MetricFinalizerBlockedDeletionCount.With(labels).Inc()
+	}
+}
+
+// ClearFinalizerBlockedDeletion removes the blocked deletion report of the kind object namespace/name.
+func ClearFinalizerBlockedDeletion(kind, namespace, name string) {
+	blockedDeletions.Lock()
+	defer blockedDeletions.Unlock()
+
+	delete(blockedDeletions.objects, blockedDeletion{kind: kind, NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}})
+	MetricFinalizerBlockedDeletionDuration.DeletePartialMatch(prometheus.Labels{"kind": kind, "namespace": namespace, "name": name})
+}
+
+// IncrementSkippedDNSCleanupCount counts a cleanup of the records kind of records skipped for reason,
+// such as the zone being deleted before the cleanup ran.
+func IncrementSkippedDNSCleanupCount(records, reason string) {
+	MetricSkippedDNSCleanupCount.With(prometheus.Labels{"records": records, "reason": reason}).Inc()
+}
+
+// IncrementFailedDNSCleanupCount counts a cleanup of the records kind of records that failed.
+func IncrementFailedDNSCleanupCount(records string) {
+	MetricFailedDNSCleanupCount.With(prometheus.Labels{"records": records}).Inc()
+}
+
+// IncrementReconcileDeadlineExceededCount counts an issuance checkpointed for running past the
+// reconcile deadline.
+func IncrementReconcileDeadlineExceededCount() {
+	MetricReconcileDeadlineExceededCount.Inc()
+}
+
+// SetUnlabeledManagedCluster reports the ClusterDeployment name in namespace as lacking the managed label.
+func SetUnlabeledManagedCluster(namespace, name string) {
+	MetricUnlabeledManagedCluster.With(prometheus.Labels{"namespace": namespace, "name": name}).Set(1)
+}
+
+// ClearUnlabeledManagedCluster removes the report of the ClusterDeployment name in namespace.
+func ClearUnlabeledManagedCluster(namespace, name string) {
+	MetricUnlabeledManagedCluster.Delete(prometheus.Labels{"namespace": namespace, "name": name})
+}
+
+// SetClusterMissingDependencies reports the dependencies the ClusterDeployment name in namespace is
+// waiting for, replacing any earlier report.
+func SetClusterMissingDependencies(namespace, name string, dependencies []string) {
+	ClearClusterMissingDependencies(namespace, name)
+	for _, dependency := range dependencies {
+		MetricClusterMissingDependency.With(prometheus.Labels{"namespace": namespace, "name": name, "dependency": dependency}).Set(1)
+	}
+}
+
+// ClearClusterMissingDependencies removes the missing dependency reports of the ClusterDeployment name in namespace.
+func ClearClusterMissingDependencies(namespace, name string) {
+	MetricClusterMissingDependency.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name})
+}
+
+// SetObservedCertificateRequestChanges reports the number of changes of each action to the
+// CertificateRequests of the ClusterDeployment name in namespace that aren't made in observation
+// mode, replacing any earlier report.
+func SetObservedCertificateRequestChanges(namespace, name string, changes map[string]int) {
+	ClearObservedCertificateRequestChanges(namespace, name)
+	for action, count := range changes {
+		MetricObservedCertificateRequestChanges.With(prometheus.Labels{"namespace": namespace, "name": name, "action": action}).Set(float64(count))
+	}
+}
+
+// ClearObservedCertificateRequestChanges removes the observed changes of the ClusterDeployment name in namespace.
+func ClearObservedCertificateRequestChanges(namespace, name string) {
+	MetricObservedCertificateRequestChanges.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name})
+}
+
+// SetMissingPermission reports whether the operator lacks the permission to verb the resource of group.
+func SetMissingPermission(group, resource, verb string, critical, missing bool) {
+	labels := prometheus.Labels{"group": group, "resource": resource, "verb": verb, "critical": strconv.FormatBool(critical)}
+	if !missing {
+		MetricMissingPermission.Delete(labels)
+		return
+	}
+	MetricMissingPermission.With(labels).Set(1)
+}
+
+// SetDelegationMismatch reports whether the base domain of the CertificateRequest name in namespace
+// is delegated elsewhere than its cloud provider zone.
+func SetDelegationMismatch(namespace, name string, mismatch bool) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	if !mismatch {
+		MetricDelegationMismatch.Delete(labels)
+		return
+	}
+	MetricDelegationMismatch.With(labels).Set(1)
+}
+
+// SetCertificateRequestNotAuthorized reports whether the CertificateRequest policy of the operator
+// rejects the CertificateRequest name in namespace.
+func SetCertificateRequestNotAuthorized(namespace, name string, notAuthorized bool) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	if !notAuthorized {
+		MetricCertificateRequestNotAuthorized.Delete(labels)
+		return
+	}
+	MetricCertificateRequestNotAuthorized.With(labels).Set(1)
+}
+
+// SetCertificateRequestStalled reports whether the CertificateRequest name in namespace stalled
+// without ever being issued a certificate.
+func SetCertificateRequestStalled(namespace, name string, stalled bool) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	if !stalled {
+		MetricCertificateRequestStalled.Delete(labels)
+		return
+	}
+	MetricCertificateRequestStalled.With(labels).Set(1)
+}
+
+// SetOrphanedCertificateSecret reports whether the certificate secret name in namespace is left
+// behind by a deleted CertificateRequest.
+func SetOrphanedCertificateSecret(namespace, name string, orphaned bool) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	if !orphaned {
+		MetricOrphanedCertificateSecret.Delete(labels)
+		return
+	}
+	MetricOrphanedCertificateSecret.With(labels).Set(1)
+}
+
+// ResetOrphanedCertificateSecrets forgets the orphaned certificate secrets reported so far.
+func ResetOrphanedCertificateSecrets() {
+	MetricOrphanedCertificateSecret.Reset()
+}
+
+// SetClusterCertificatePush reports whether the certificates of the ClusterDeployment name in
+// namespace were last pushed to its cluster.
+func SetClusterCertificatePush(namespace, name string, success bool) {
+	value := 0.0
+	if success {
+		value = 1
+	}
+	MetricClusterCertificatePush.With(prometheus.Labels{"namespace": namespace, "name": name}).Set(value)
+}
+
+// ClearClusterCertificatePush stops reporting the pushes to the cluster of the ClusterDeployment
+// name in namespace, once its SyncSets deliver the certificates again.
+func ClearClusterCertificatePush(namespace, name string) {
+	MetricClusterCertificatePush.Delete(prometheus.Labels{"namespace": namespace, "name": name})
+}
+
+// SetCertificateUnhealthy reports whether no valid certificate is served from the certificate
+// secret of the CertificateRequest name in namespace.
+func SetCertificateUnhealthy(namespace, name string, unhealthy bool) {
+	value := 0.0
+	if unhealthy {
+		value = 1
+	}
+	MetricCertificateUnhealthy.With(prometheus.Labels{"namespace": namespace, "cr": name}).Set(value)
+}
+
+// ClearCertificateUnhealthy stops reporting the certificate health of the deleted
+// CertificateRequest name in namespace.
+func ClearCertificateUnhealthy(namespace, name string) {
+	MetricCertificateUnhealthy.Delete(prometheus.Labels{"namespace": namespace, "cr": name})
+}
+
+// IncrementCorruptCertificateRecoveryCount Increment the count of corrupt certificates replaced by
+// a reissued one
+func IncrementCorruptCertificateRecoveryCount() {
+	MetricCorruptCertificateRecoveryCount.Inc()
+}
+
+// IncrementAbandonedOrderCount Increment the count of ACME orders abandoned for reason
+func IncrementAbandonedOrderCount(reason string) {
+	MetricAbandonedOrderCount.With(prometheus.Labels{"reason": reason}).Inc()
+}
+
+// SetCertificateSecretSize reports the size of the data of the certificate secret of the
+// CertificateRequest name in namespace.
+func SetCertificateSecretSize(namespace, name string, size int) {
+	MetricCertificateSecretSize.With(prometheus.Labels{"namespace": namespace, "name": name}).Set(float64(size))
+}
+
+// ClearCertificateSecretSize stops reporting the certificate secret size of the deleted
+// CertificateRequest name in namespace.
+func ClearCertificateSecretSize(namespace, name string) {
+	MetricCertificateSecretSize.Delete(prometheus.Labels{"namespace": namespace, "name": name})
+}
+
+// IncrementDNSThrottledRequestCount Increment the count of requests the DNS API of provider throttled
+func IncrementDNSThrottledRequestCount(provider string) {
+	MetricDNSThrottledRequestCount.With(prometheus.Labels{"provider": provider}).Inc()
+}
+
+// SetDNSZoneRecordSetUsage reports the number of record sets of the DNS zone of provider and the
+// maximum number of record sets it may hold.
+func SetDNSZoneRecordSetUsage(provider, zone string, count, limit int64) {
+	labels := prometheus.Labels{"provider": provider, "zone": zone}
+	MetricDNSZoneRecordSets.With(labels).Set(float64(count))
+	MetricDNSZoneRecordSetsLimit.With(labels).Set(float64(limit))
+}
+
+// IncrementDNSZoneCacheLookup Increment the count of DNS zone lookups of provider, result being
+// hit when the zones were cached and miss when they were listed.
+func IncrementDNSZoneCacheLookup(provider, result string) {
+	MetricDNSZoneCacheLookupCount.With(prometheus.Labels{"provider": provider, "result": result}).Inc()
+}
+
+// IncrementDNSZoneListPageCount Increment the count of pages of DNS zones listed from provider
+func IncrementDNSZoneListPageCount(provider string) {
+	MetricDNSZoneListPageCount.With(prometheus.Labels{"provider": provider}).Inc()
+}
+
+// IncrementChaosInjectedFaultCount Increment the count of faults injected into the calls to target
+func IncrementChaosInjectedFaultCount(target, fault string) {
+	MetricChaosInjectedFaultCount.With(prometheus.Labels{"target": target, "fault": fault}).Inc()
+}
+
+// IncrementSecretOwnerReferenceRepairCount Increment the count of repaired owner references of the
+// secret kind, certificate or ca_bundle
+func IncrementSecretOwnerReferenceRepairCount(secret string) {
+	MetricSecretOwnerReferenceRepairCount.With(prometheus.Labels{"secret": secret}).Inc()
+}
+
+// SetCloudCredentialAcquired reports that the cloud credentials of provider were acquired at
+// acquired, and expire at expiry, and resets the consecutive failures of provider.
+func SetCloudCredentialAcquired(provider string, acquired, expiry time.Time) {
+	labels := prometheus.Labels{"provider": provider}
+	MetricCloudCredentialAcquired.With(labels).Set(float64(acquired.Unix()))
+	MetricCloudCredentialExpiry.With(labels).Set(float64(expiry.Unix()))
+	MetricCloudCredentialConsecutiveFailures.With(labels).Set(0)
+}
+
+// SetCloudCredentialFailure counts a failure to acquire the cloud credentials of provider, which
+// failed consecutive times since they were last acquired.
+func SetCloudCredentialFailure(provider string, consecutive int) {
+	labels := prometheus.Labels{"provider": provider}
+	MetricCloudCredentialFailureCount.With(labels).Inc()
+	MetricCloudCredentialConsecutiveFailures.With(labels).Set(float64(consecutive))
+}
+
+// UpdateCanary reports the health of the canary certificate for domain, and its notBefore time
+// when it has been issued.
+func UpdateCanary(domain string, success bool, notBefore time.Time) {
+	// the domain may have changed since the last check
+	ClearCanary()
+
+	value := 0.0
+	if success {
+		value = 1
+	}
+	MetricCanarySuccess.With(prometheus.Labels{"domain": domain}).Set(value)
+	if !notBefore.IsZero() {
+		MetricCanaryLastIssuance.With(prometheus.Labels{"domain": domain}).Set(float64(notBefore.Unix()))
+	}
+}
+
+// ClearCanary removes the reports of the canary, when it is disabled.
+func ClearCanary() {
+	MetricCanarySuccess.Reset()
+	MetricCanaryLastIssuance.Reset()
+}
+
+// ObserveDNSZoneLockWait records how long it took to acquire the lock of a challenge record of a DNS zone
+func ObserveDNSZoneLockWait(wait time.Duration) {
+	MetricDNSZoneLockWaitDuration.Observe(wait.Seconds())
+}
+
+// SetBuildInfo reports the build of the operator and the hash of its configuration, replacing
+// the previously reported ones.
+func SetBuildInfo(version, gitSHA, goVersion string, fedramp bool, configHash string) {
+	MetricBuildInfo.Reset()
+	MetricBuildInfo.With(prometheus.Labels{
+		"version":     version,
+		"git_sha":     gitSHA,
+		"go_version":  goVersion,
+		"fedramp":     strconv.FormatBool(fedramp),
+		"config_hash": configHash,
+	}).Set(1)
+}
+
+// SetACMEAccount reports the status of the ACME account of environment and whether its contact
+// drifted from the default notification email, replacing the previously reported ones.
+func SetACMEAccount(environment, status string, contactDrift bool) {
+	MetricACMEAccountStatus.Reset()
+	MetricACMEAccountStatus.With(prometheus.Labels{"environment": strings.ToLower(environment), "status": status}).Set(1)
+
+	MetricACMEAccountContactDrift.Reset()
+	value := 0.0
+	if contactDrift {
+		value = 1
+	}
+	MetricACMEAccountContactDrift.With(prometheus.Labels{"environment": strings.ToLower(environment)}).Set(value)
+}
+
+// IncrementACMEAccountContactUpdateCount increments the number of updates of the contact of the
+// ACME account of environment.
+func IncrementACMEAccountContactUpdateCount(environment string) {
+	MetricACMEAccountContactUpdateCount.With(prometheus.Labels{"environment": strings.ToLower(environment)}).Inc()
+}
+
+// SetACMEAccountIssuedInLastWeek reports the number of certificates issued with the ACME account
+// at accountURL in the last 7 days.
+func SetACMEAccountIssuedInLastWeek(accountURL string, issued int) {
+	MetricACMEAccountIssuedInLastWeek.With(prometheus.Labels{"account": accountURL}).Set(float64(issued))
+}
+
+// IncrementACMEAccountIssuanceCeilingCount increments the number of issuances deferred because the
+// ACME account at accountURL reached its weekly issuance ceiling.
+func IncrementACMEAccountIssuanceCeilingCount(accountURL string) {
+	MetricACMEAccountIssuanceCeilingCount.With(prometheus.Labels{"account": accountURL}).Inc()
+}
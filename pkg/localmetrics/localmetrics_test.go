@@ -8,6 +8,12 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
 )
 
 func TestUpdateCertValidDuration(t *testing.T) {
@@ -78,3 +84,126 @@ func TestUpdateCertValidDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateFinalizerBlockedDeletion(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		blockedFor    time.Duration
+		expectedCount int
+	}{
+		{
+			name:          "Deletion blocked below the threshold is not reported",
+			blockedFor:    time.Minute,
+			expectedCount: 0,
+		},
+		{
+			name:          "Deletion blocked past the threshold is reported",
+			blockedFor:    FinalizerBlockedDeletionThreshold + time.Minute,
+			expectedCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MetricFinalizerBlockedDeletionDuration.Reset()
+
+			UpdateFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "test-name", "revocation_failed", now.Add(-tt.blockedFor), now)
+			if count := testutil.CollectAndCount(MetricFinalizerBlockedDeletionDuration); count != tt.expectedCount {
+				t.Errorf("Expected %d blocked deletions, but got %d", tt.expectedCount, count)
+			}
+
+			ClearFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "test-name")
+			if count := testutil.CollectAndCount(MetricFinalizerBlockedDeletionDuration); count != 0 {
+				t.Errorf("Expected blocked deletions to be cleared, but got %d", count)
+			}
+		})
+	}
+}
+
+func TestFinalizerBlockedDeletionCount(t *testing.T) {
+	MetricFinalizerBlockedDeletionDuration.Reset()
+	MetricFinalizerBlockedDeletionCount.Reset()
+	now := time.Now()
+	deletionTimestamp := now.Add(-FinalizerBlockedDeletionThreshold - time.Minute)
+	count := func(name, reason string) float64 {
+		return testutil.ToFloat64(MetricFinalizerBlockedDeletionCount.WithLabelValues("CertificateRequest", "test-namespace", name, reason))
+	}
+
+	// each failed attempt updates the duration, but the deletion is counted once
+	for i := 0; i < 3; i++ {
+		UpdateFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "first", "revocation_failed", deletionTimestamp, now.Add(time.Duration(i)*time.Minute))
+	}
+	UpdateFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "first", "finalizer_removal_failed", deletionTimestamp, now)
+	UpdateFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "second", "revocation_failed", deletionTimestamp, now)
+
+	if c := count("first", "revocation_failed"); c != 1 {
+		t.Errorf("Expected the blocked deletion to be counted once, but got %v", c)
+	}
+	if c := count("first", "finalizer_removal_failed"); c != 0 {
+		t.Errorf("Expected a new reason of a blocked deletion not to be counted, but got %v", c)
+	}
+	if c := count("second", "revocation_failed"); c != 1 {
+		t.Errorf("Expected the blocked deletion of another object to be counted, but got %v", c)
+	}
+	if c := testutil.CollectAndCount(MetricFinalizerBlockedDeletionDuration); c != 2 {
+		t.Errorf("Expected 2 blocked deletions, but got %d", c)
+	}
+
+	// the deletion is counted again when it becomes blocked again after being cleared
+	ClearFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "first")
+	UpdateFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "first", "revocation_failed", deletionTimestamp, now)
+	if c := count("first", "revocation_failed"); c != 2 {
+		t.Errorf("Expected the deletion blocked again to be counted, but got %v", c)
+	}
+	ClearFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "first")
+	ClearFinalizerBlockedDeletion("CertificateRequest", "test-namespace", "second")
+}
+
+func TestCertRequestsCount(t *testing.T) {
+	certRequests.Lock()
+	certRequests.initialized = false
+	certRequests.names = map[types.NamespacedName]struct{}{}
+	certRequests.Unlock()
+
+	scheme := runtime.NewScheme()
+	if err := certmanv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cr := func(name string, finalizers ...string) *certmanv1alpha1.CertificateRequest {
+		return &certmanv1alpha1.CertificateRequest{ObjectMeta: metav1.ObjectMeta{Namespace: "uhc-cluster", Name: name, Finalizers: finalizers}}
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		cr("finalized", certmanv1alpha1.CertmanOperatorFinalizerLabel),
+		cr("new"),
+	).Build()
+
+	CheckInitCounter(fakeClient)
+	CheckInitCounter(fakeClient)
+	if actual := testutil.ToFloat64(MetricCertRequestsCount); actual != 1 {
+		t.Errorf("expected 1 CertificateRequest after init, got %v", actual)
+	}
+
+	// the finalizer is added, stripped and added again, and each finalization is retried
+	for i := 0; i < 3; i++ {
+		RecordCertRequest("uhc-cluster", "new")
+		RecordCertRequest("uhc-cluster", "new")
+		if actual := testutil.ToFloat64(MetricCertRequestsCount); actual != 2 {
+			t.Errorf("cycle %d: expected 2 CertificateRequests with the finalizer, got %v", i, actual)
+		}
+
+		ForgetCertRequest("uhc-cluster", "new")
+		ForgetCertRequest("uhc-cluster", "new")
+		if actual := testutil.ToFloat64(MetricCertRequestsCount); actual != 1 {
+			t.Errorf("cycle %d: expected 1 CertificateRequest once finalized, got %v", i, actual)
+		}
+	}
+
+	// an initialized count isn't listed again
+	RecordCertRequest("uhc-cluster", "finalized")
+	CheckInitCounter(fakeClient)
+	if actual := testutil.ToFloat64(MetricCertRequestsCount); actual != 1 {
+		t.Errorf("expected 1 CertificateRequest, got %v", actual)
+	}
+}
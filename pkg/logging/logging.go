@@ -0,0 +1,81 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging names the keys the controllers, the ACME client and the DNS clients log the
+// objects they work on with, so the entries about a certificate can be correlated across them.
+// Loggers are passed through the context set up by controller-runtime for each reconcile.
+package logging
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+const (
+	// ClusterKey is the namespace/name of a ClusterDeployment
+	ClusterKey = "cluster"
+	// CertificateRequestKey is the namespace/name of a CertificateRequest
+	CertificateRequestKey = "certificaterequest"
+	// DomainKey is a domain of a certificate, or the name of a DNS record placed for one
+	DomainKey = "domain"
+	// ProviderKey is the DNS provider of a CertificateRequest: aws, gcp, azure or mock
+	ProviderKey = "provider"
+)
+
+// FromContext returns the logger of ctx, which controller-runtime sets up with the controller and
+// the object of the reconcile, with keysAndValues added.
+func FromContext(ctx context.Context, keysAndValues ...interface{}) logr.Logger {
+	return logf.FromContext(ctx, keysAndValues...)
+}
+
+// IntoContext returns a copy of ctx carrying logger, for the functions called with ctx.
+func IntoContext(ctx context.Context, logger logr.Logger) context.Context {
+	return logf.IntoContext(ctx, logger)
+}
+
+// ObjectName returns the value of the ClusterKey or the CertificateRequestKey of the object name in
+// namespace.
+func ObjectName(namespace, name string) string {
+	return types.NamespacedName{Namespace: namespace, Name: name}.String()
+}
+
+// WithCluster adds the ClusterKey of the ClusterDeployment name in namespace to logger.
+func WithCluster(logger logr.Logger, namespace, name string) logr.Logger {
+	return logger.WithValues(ClusterKey, ObjectName(namespace, name))
+}
+
+// WithCertificateRequest adds the CertificateRequestKey and the ProviderKey of cr to logger.
+func WithCertificateRequest(logger logr.Logger, cr *certmanv1alpha1.CertificateRequest) logr.Logger {
+	return WithProvider(logger.WithValues(CertificateRequestKey, ObjectName(cr.Namespace, cr.Name)), cr.Spec.Platform)
+}
+
+// WithProvider adds the ProviderKey of platform to logger, unless platform has no single provider.
+func WithProvider(logger logr.Logger, platform certmanv1alpha1.Platform) logr.Logger {
+	if provider := platform.Provider(); provider != certmanv1alpha1.PlatformProviderNone {
+		return logger.WithValues(ProviderKey, string(provider))
+	}
+	return logger
+}
+
+// WithDomain adds the DomainKey of domain to logger.
+func WithDomain(logger logr.Logger, domain string) logr.Logger {
+	return logger.WithValues(DomainKey, domain)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package maintenance parses the maintenance windows during which certificates may be rotated.
+// A window is written as a cron schedule of its openings followed by how long it stays open, such
+// as "0 2 * * 6,0 4h" for 02:00 to 06:00 UTC every weekend day.
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDuration is the longest a maintenance window may stay open.
+const MaxDuration = 7 * 24 * time.Hour
+
+// field is the range of values of a cron field.
+type field struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField     = field{"minute", 0, 59}
+	hourField       = field{"hour", 0, 23}
+	dayOfMonthField = field{"day of month", 1, 31}
+	monthField      = field{"month", 1, 12}
+	// 7 is accepted for Sunday as well as 0
+	dayOfWeekField = field{"day of week", 0, 7}
+)
+
+// Window is a recurring maintenance window. Its schedule is evaluated in UTC.
+type Window struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek uint64
+	// when both days are restricted, either of them matching opens the window, as with cron
+	daysOfMonthRestricted, daysOfWeekRestricted bool
+
+	// Duration is how long the window stays open
+	Duration time.Duration
+}
+
+// Parse returns the Window of spec: the five fields of a cron schedule (minute, hour, day of month,
+// month and day of week, with *, lists, ranges and steps) followed by a duration such as 4h.
+func Parse(spec string) (*Window, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("maintenance window %q must have a cron schedule of 5 fields followed by a duration", spec)
+	}
+
+	w := &Window{}
+	var err error
+	if w.minutes, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+	if w.hours, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+	if w.daysOfMonth, err = parseField(fields[2], dayOfMonthField); err != nil {
+		return nil, err
+	}
+	if w.months, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+	if w.daysOfWeek, err = parseField(fields[4], dayOfWeekField); err != nil {
+		return nil, err
+	}
+	if w.daysOfWeek&(1<<7) != 0 {
+		w.daysOfWeek |= 1
+	}
+	w.daysOfMonthRestricted = fields[2] != "*"
+	w.daysOfWeekRestricted = fields[4] != "*"
+
+	w.Duration, err = time.ParseDuration(fields[5])
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window duration %q: %w", fields[5], err)
+	}
+	if w.Duration < time.Minute || w.Duration > MaxDuration {
+		return nil, fmt.Errorf("maintenance window duration %v must be between 1m and %v", w.Duration, MaxDuration)
+	}
+
+	return w, nil
+}
+
+// Contains returns true when t is within an opening of w.
+func (w *Window) Contains(t time.Time) bool {
+	t = t.UTC()
+	for start := t.Truncate(time.Minute); t.Sub(start) < w.Duration; start = start.Add(-time.Minute) {
+		if w.opensAt(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// opensAt returns true when the schedule of w matches the minute t.
+func (w *Window) opensAt(t time.Time) bool {
+	if !has(w.minutes, t.Minute()) || !has(w.hours, t.Hour()) || !has(w.months, int(t.Month())) {
+		return false
+	}
+
+	dayOfMonth := has(w.daysOfMonth, t.Day())
+	dayOfWeek := has(w.daysOfWeek, int(t.Weekday()))
+	if w.daysOfMonthRestricted && w.daysOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// parseField returns the values of the comma separated list of *, values and ranges in value, each
+// with an optional step, as a bit set.
+func parseField(value string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(value, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i != -1 {
+			var err error
+			rangePart = item[:i]
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s %q", f.name, item)
+			}
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseValue(bounds[0], f); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(bounds[1], f); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range in %s %q", f.name, item)
+			}
+		default:
+			var err error
+			if low, err = parseValue(rangePart, f); err != nil {
+				return 0, err
+			}
+			// a single value with a step runs to the end of the range, as with cron
+			if step == 1 {
+				high = low
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// parseValue returns value when it is a number within the range of f.
+func parseValue(value string, f field) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, it must be between %d and %d", f.name, value, f.min, f.max)
+	}
+	return v, nil
+}
+
+// has returns true when v is in set.
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
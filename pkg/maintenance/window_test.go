@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec      string
+		expectErr bool
+	}{
+		{spec: "0 2 * * 6,0 4h"},
+		{spec: "*/15 1-5/2 1,15 * * 30m"},
+		{spec: "0 2 * * 7 1h"},
+		{spec: "0 2 * * 6", expectErr: true},
+		{spec: "60 2 * * * 1h", expectErr: true},
+		{spec: "0 5-2 * * * 1h", expectErr: true},
+		{spec: "0 2 * * * */5 1h", expectErr: true},
+		{spec: "0 2 * * * soon", expectErr: true},
+		{spec: "0 2 * * * 30s", expectErr: true},
+		{spec: "0 2 * * * 200h", expectErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.spec)
+		if test.expectErr && err == nil {
+			t.Errorf("expected an error parsing %q", test.spec)
+		}
+		if !test.expectErr && err != nil {
+			t.Errorf("unexpected error parsing %q: %s", test.spec, err)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	// Saturday
+	saturday := time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		at       time.Time
+		expected bool
+	}{
+		{name: "at the opening", spec: "0 2 * * 6,0 4h", at: saturday.Add(2 * time.Hour), expected: true},
+		{name: "before the closing", spec: "0 2 * * 6,0 4h", at: saturday.Add(6*time.Hour - time.Second), expected: true},
+		{name: "at the closing", spec: "0 2 * * 6,0 4h", at: saturday.Add(6 * time.Hour), expected: false},
+		{name: "before the opening", spec: "0 2 * * 6,0 4h", at: saturday.Add(time.Hour), expected: false},
+		{name: "on another day", spec: "0 2 * * 6,0 4h", at: saturday.Add(-24*time.Hour + 3*time.Hour), expected: false},
+		{name: "across midnight", spec: "0 22 * * 5 4h", at: saturday.Add(time.Hour), expected: true},
+		{name: "sunday as 7", spec: "0 2 * * 7 1h", at: saturday.Add(24*time.Hour + 2*time.Hour), expected: true},
+		{name: "day of month or day of week", spec: "0 2 15 * 6 1h", at: saturday.Add(2 * time.Hour), expected: true},
+		{name: "in another time zone", spec: "0 2 * * 6 1h", at: saturday.Add(2 * time.Hour).In(time.FixedZone("EST", -5*3600)), expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w, err := Parse(test.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if w.Contains(test.at) != test.expected {
+				t.Errorf("expected %q to contain %v: %t", test.spec, test.at, test.expected)
+			}
+		})
+	}
+}
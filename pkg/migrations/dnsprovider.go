@@ -0,0 +1,68 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// backfillDNSProviderStatus sets status.dnsProvider on CertificateRequests that were issued before the
+// field existed. The zone and challenge records are left empty as they can't be known until the next issuance.
+func backfillDNSProviderStatus(ctx context.Context, kubeClient client.Client) error {
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := kubeClient.List(ctx, crList); err != nil {
+		return err
+	}
+
+	for i := range crList.Items {
+		cr := &crList.Items[i]
+		if !cr.Status.Issued || cr.Status.DNSProvider != "" {
+			continue
+		}
+
+		provider := dnsProviderForPlatform(cr.Spec.Platform)
+		if provider == "" {
+			continue
+		}
+
+		log.Info(fmt.Sprintf("setting dnsProvider of CertificateRequest %s/%s to %s", cr.Namespace, cr.Name, provider))
+		cr.Status.DNSProvider = provider
+		if err := kubeClient.Status().Update(ctx, cr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dnsProviderForPlatform returns the name reported by the DNS client of platform.
+func dnsProviderForPlatform(platform certmanv1alpha1.Platform) string {
+	switch {
+	case platform.AWS != nil:
+		return "Route53"
+	case platform.GCP != nil:
+		return "Cloud DNS"
+	case platform.Azure != nil:
+		return "DNS Zone"
+	}
+	return ""
+}
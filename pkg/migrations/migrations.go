@@ -0,0 +1,158 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migrations runs one-time data migrations at operator startup, so changes to the
+// CertificateRequest API or to how data is stored don't require fixing up the fleet by hand.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/openshift/certman-operator/config"
+)
+
+const (
+	// StateConfigMapName is the ConfigMap in the operator namespace recording the applied migrations.
+	StateConfigMapName = "certman-operator-migrations"
+	// versionKey holds the version of the last migration applied.
+	versionKey = "version"
+)
+
+var log = logf.Log.WithName("migrations")
+
+// Migration is a one-time change applied to the data stored in the cluster.
+type Migration struct {
+	// Version orders the migrations. It must be unique and never reused.
+	Version int
+	// Description is logged when the migration runs.
+	Description string
+	// Run applies the migration. It must be safe to run again if it fails part way.
+	Run func(ctx context.Context, kubeClient client.Client) error
+}
+
+// Migrations lists every migration known to the operator. Append new migrations with the next version.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "backfill the dnsProvider status of issued CertificateRequests",
+		Run:         backfillDNSProviderStatus,
+	},
+}
+
+var _ manager.Runnable = &Runner{}
+
+// Runner applies the migrations that have not been applied yet when the manager starts.
+type Runner struct {
+	Client     client.Client
+	Migrations []Migration
+}
+
+// Start runs the pending migrations in version order, recording each one as it completes.
+func (r *Runner) Start(ctx context.Context) error {
+	applied, err := r.appliedVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending := []Migration{}
+	for _, m := range r.Migrations {
+		if m.Version > applied {
+			pending = append(pending, m)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+
+	for _, m := range pending {
+		log.Info(fmt.Sprintf("running migration %d: %s", m.Version, m.Description))
+		if err := m.Run(ctx, r.Client); err != nil {
+			log.Error(err, fmt.Sprintf("migration %d failed", m.Version))
+			return err
+		}
+
+		if err := r.recordVersion(ctx, m.Version); err != nil {
+			log.Error(err, fmt.Sprintf("failed to record migration %d", m.Version))
+			return err
+		}
+	}
+
+	log.Info(fmt.Sprintf("%d migrations applied, data is at version %d", len(pending), r.latestVersion(applied)))
+	return nil
+}
+
+// appliedVersion returns the version of the last migration applied, 0 if none has been.
+func (r *Runner) appliedVersion(ctx context.Context) (int, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: StateConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if cm.Data[versionKey] == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(cm.Data[versionKey])
+}
+
+// recordVersion stores version as the last migration applied.
+func (r *Runner) recordVersion(ctx context.Context, version int) error {
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: StateConfigMapName}, cm)
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      StateConfigMapName,
+				Namespace: config.OperatorNamespace,
+			},
+			Data: map[string]string{versionKey: strconv.Itoa(version)},
+		}
+		return r.Client.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[versionKey] = strconv.Itoa(version)
+	return r.Client.Update(ctx, cm)
+}
+
+// latestVersion returns the highest migration version known, or applied if it is higher.
+func (r *Runner) latestVersion(applied int) int {
+	latest := applied
+	for _, m := range r.Migrations {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	return latest
+}
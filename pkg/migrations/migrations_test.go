@@ -0,0 +1,130 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+)
+
+func setUpTestClient(objects ...runtime.Object) client.Client {
+	s := scheme.Scheme
+	s.AddKnownTypes(certmanv1alpha1.GroupVersion, &certmanv1alpha1.CertificateRequest{}, &certmanv1alpha1.CertificateRequestList{})
+	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objects...).WithStatusSubresource(&certmanv1alpha1.CertificateRequest{}).Build()
+}
+
+func TestRunnerStart(t *testing.T) {
+	tests := []struct {
+		name            string
+		objects         []runtime.Object
+		expectedRuns    []int
+		expectedVersion string
+	}{
+		{
+			name:            "runs every migration on a new install",
+			expectedRuns:    []int{1, 2},
+			expectedVersion: "2",
+		},
+		{
+			name: "only runs migrations newer than the recorded version",
+			objects: []runtime.Object{&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: StateConfigMapName, Namespace: config.OperatorNamespace},
+				Data:       map[string]string{versionKey: "1"},
+			}},
+			expectedRuns:    []int{2},
+			expectedVersion: "2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := setUpTestClient(test.objects...)
+
+			runs := []int{}
+			record := func(version int) func(context.Context, client.Client) error {
+				return func(context.Context, client.Client) error {
+					runs = append(runs, version)
+					return nil
+				}
+			}
+			runner := &Runner{
+				Client: kubeClient,
+				Migrations: []Migration{
+					{Version: 2, Description: "second", Run: record(2)},
+					{Version: 1, Description: "first", Run: record(1)},
+				},
+			}
+
+			if err := runner.Start(context.TODO()); err != nil {
+				t.Fatalf("Start() unexpected error: %v", err)
+			}
+
+			if len(runs) != len(test.expectedRuns) {
+				t.Fatalf("expected migrations %v to run, got %v", test.expectedRuns, runs)
+			}
+			for i := range runs {
+				if runs[i] != test.expectedRuns[i] {
+					t.Errorf("expected migrations %v to run, got %v", test.expectedRuns, runs)
+				}
+			}
+
+			cm := &corev1.ConfigMap{}
+			if err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: StateConfigMapName}, cm); err != nil {
+				t.Fatalf("unexpected error getting migration state: %v", err)
+			}
+			if cm.Data[versionKey] != test.expectedVersion {
+				t.Errorf("expected recorded version %s, got %s", test.expectedVersion, cm.Data[versionKey])
+			}
+		})
+	}
+}
+
+func TestBackfillDNSProviderStatus(t *testing.T) {
+	cr := &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cr", Namespace: "test-namespace"},
+		Spec: certmanv1alpha1.CertificateRequestSpec{
+			Platform: certmanv1alpha1.Platform{
+				AWS: &certmanv1alpha1.AWSPlatformSecrets{},
+			},
+		},
+		Status: certmanv1alpha1.CertificateRequestStatus{Issued: true},
+	}
+	kubeClient := setUpTestClient(cr)
+
+	if err := backfillDNSProviderStatus(context.TODO(), kubeClient); err != nil {
+		t.Fatalf("backfillDNSProviderStatus() unexpected error: %v", err)
+	}
+
+	actual := &certmanv1alpha1.CertificateRequest{}
+	if err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: cr.Namespace, Name: cr.Name}, actual); err != nil {
+		t.Fatalf("unexpected error getting certificaterequest: %v", err)
+	}
+	if actual.Status.DNSProvider != "Route53" {
+		t.Errorf("expected dnsProvider Route53, got %q", actual.Status.DNSProvider)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rbaccheck verifies at startup that the service account of the operator holds the
+// permissions its controllers need, so a stale ClusterRole is reported when the operator is
+// deployed rather than through the errors of the first reconciles that need it.
+package rbaccheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+	"github.com/openshift/certman-operator/pkg/logging"
+)
+
+// checkRetryInterval is how long to wait before checking the permissions again after a failed
+// check, or while critical permissions are missing.
+const checkRetryInterval = time.Minute
+
+var errCheckPending = errors.New("the permissions of the operator have not been checked yet")
+
+var _ manager.Runnable = &Check{}
+var _ manager.LeaderElectionRunnable = &Check{}
+
+// Permission is the permission to Verb the Resource of Group in all namespaces. Resource may name a
+// subresource, as in certificaterequests/status.
+type Permission struct {
+	Group    string
+	Resource string
+	Verb     string
+	// Critical permissions are needed by every reconcile, the operator isn't ready without them
+	Critical bool
+}
+
+// String returns the permission as the verb followed by the resource and its group, if any.
+func (p Permission) String() string {
+	if p.Group == "" {
+		return fmt.Sprintf("%s %s", p.Verb, p.Resource)
+	}
+	return fmt.Sprintf("%s %s.%s", p.Verb, p.Resource, p.Group)
+}
+
+// permissions returns the permissions to each of verbs the resource of group.
+func permissions(group, resource string, critical bool, verbs ...string) []Permission {
+	result := make([]Permission, 0, len(verbs))
+	for _, verb := range verbs {
+		result = append(result, Permission{Group: group, Resource: resource, Verb: verb, Critical: critical})
+	}
+	return result
+}
+
+// RequiredPermissions are the permissions the controllers of the operator use. The critical ones
+// are those of the objects every certificate goes through, the others are only needed on some
+// platforms or by optional features.
+var RequiredPermissions = concat(
+	permissions("certman.managed.openshift.io", "certificaterequests", true, "get", "list", "watch", "create", "update", "patch", "delete"),
+	permissions("certman.managed.openshift.io", "certificaterequests/status", true, "update"),
+	permissions("certman.managed.openshift.io", "certmanoperatorconfigs", true, "get", "list", "watch"),
+	permissions("certman.managed.openshift.io", "certmanoperatorconfigs/status", false, "update"),
+	permissions("certman.managed.openshift.io", "certificaterotationrequests", false, "get", "list", "watch"),
+	permissions("certman.managed.openshift.io", "certificaterotationrequests/status", false, "update"),
+	permissions("hive.openshift.io", "clusterdeployments", true, "get", "list", "watch", "update", "patch"),
+	permissions("hive.openshift.io", "dnszones", false, "get", "list", "watch"),
+	permissions("hive.openshift.io", "syncsets", false, "get", "list", "watch", "create", "update", "delete"),
+	permissions("aws.managed.openshift.io", "accountclaims", false, "get", "list", "watch"),
+	permissions("", "secrets", true, "get", "list", "watch", "create", "update", "patch", "delete"),
+	permissions("", "configmaps", true, "get", "list", "watch"),
+	permissions("", "events", false, "create", "patch"),
+	permissions("", "namespaces", false, "get"),
+	permissions("coordination.k8s.io", "leases", false, "get", "list", "create", "update", "delete"),
+)
+
+func concat(lists ...[]Permission) []Permission {
+	result := []Permission{}
+	for _, list := range lists {
+		result = append(result, list...)
+	}
+	return result
+}
+
+// Check reviews each of Permissions with a SelfSubjectAccessReview once the operator starts. The
+// missing permissions are logged and reported by the certman_operator_missing_permission metric.
+// While critical ones are missing, Ready fails and the check is repeated, so the operator becomes
+// ready once its ClusterRole is fixed.
+type Check struct {
+	Client client.Client
+	// Permissions defaults to RequiredPermissions
+	Permissions []Permission
+
+	mu      sync.RWMutex
+	checked bool
+	missing []Permission
+}
+
+// Start checks the permissions, repeating the check until it succeeds and no critical permission is
+// missing, or ctx is cancelled.
+func (c *Check) Start(ctx context.Context) error {
+	logger := logging.FromContext(ctx).WithName("rbac_check")
+
+	for {
+		missing, err := c.check(ctx)
+		if err != nil {
+			logger.Error(err, "could not check the permissions of the operator, retrying", "RetryInterval", checkRetryInterval)
+		} else {
+			c.setResult(missing)
+			for _, p := range missing {
+				logger.Info("the service account of the operator is missing a permission, check its ClusterRole", "permission", p.String(), "critical", p.Critical)
+			}
+			if len(criticalPermissions(missing)) == 0 {
+				logger.Info("the operator has the permissions it needs to run")
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(checkRetryInterval):
+		}
+	}
+}
+
+// NeedLeaderElection returns false so every replica reports its own readiness.
+func (c *Check) NeedLeaderElection() bool {
+	return false
+}
+
+// Ready is a healthz.Checker failing until the permissions were checked, and while critical ones
+// are missing.
+func (c *Check) Ready(_ *http.Request) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.checked {
+		return errCheckPending
+	}
+	if critical := criticalPermissions(c.missing); len(critical) > 0 {
+		names := make([]string, 0, len(critical))
+		for _, p := range critical {
+			names = append(names, p.String())
+		}
+		return fmt.Errorf("the operator is missing critical permissions: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// check returns the permissions the operator is missing.
+func (c *Check) check(ctx context.Context) ([]Permission, error) {
+	required := c.Permissions
+	if required == nil {
+		required = RequiredPermissions
+	}
+
+	missing := []Permission{}
+	for _, p := range required {
+		resource, subresource, _ := strings.Cut(p.Resource, "/")
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:       p.Group,
+					Resource:    resource,
+					Subresource: subresource,
+					Verb:        p.Verb,
+				},
+			},
+		}
+		if err := c.Client.Create(ctx, review); err != nil {
+			return nil, fmt.Errorf("could not review the permission to %s: %w", p, err)
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
+
+func (c *Check) setResult(missing []Permission) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	required := c.Permissions
+	if required == nil {
+		required = RequiredPermissions
+	}
+	for _, p := range required {
+		localmetrics.SetMissingPermission(p.Group, p.Resource, p.Verb, p.Critical, false)
+	}
+	for _, p := range missing {
+		localmetrics.SetMissingPermission(p.Group, p.Resource, p.Verb, p.Critical, true)
+	}
+
+	c.checked = true
+	c.missing = missing
+}
+
+// criticalPermissions returns the critical permissions of list.
+func criticalPermissions(list []Permission) []Permission {
+	critical := []Permission{}
+	for _, p := range list {
+		if p.Critical {
+			critical = append(critical, p)
+		}
+	}
+	return critical
+}
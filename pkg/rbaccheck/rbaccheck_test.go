@@ -0,0 +1,121 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbaccheck
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+// testClient returns a client answering SelfSubjectAccessReviews, denying the permissions of denied.
+func testClient(denied ...Permission) client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			review, ok := obj.(*authorizationv1.SelfSubjectAccessReview)
+			if !ok {
+				return c.Create(ctx, obj, opts...)
+			}
+			attributes := review.Spec.ResourceAttributes
+			resource := attributes.Resource
+			if attributes.Subresource != "" {
+				resource += "/" + attributes.Subresource
+			}
+			review.Status.Allowed = true
+			for _, p := range denied {
+				if p.Group == attributes.Group && p.Resource == resource && p.Verb == attributes.Verb {
+					review.Status.Allowed = false
+				}
+			}
+			return nil
+		},
+	}).Build()
+}
+
+func TestCheck(t *testing.T) {
+	dnsZones := Permission{Group: "hive.openshift.io", Resource: "dnszones", Verb: "list"}
+	crStatus := Permission{Group: "certman.managed.openshift.io", Resource: "certificaterequests/status", Verb: "update", Critical: true}
+	required := []Permission{dnsZones, crStatus}
+
+	tests := []struct {
+		name            string
+		denied          []Permission
+		expectReady     bool
+		expectedMissing []Permission
+	}{
+		{
+			name:        "all permissions",
+			expectReady: true,
+		},
+		{
+			name:            "missing permission",
+			denied:          []Permission{dnsZones},
+			expectReady:     true,
+			expectedMissing: []Permission{dnsZones},
+		},
+		{
+			name:            "missing critical permission",
+			denied:          []Permission{crStatus},
+			expectedMissing: []Permission{crStatus},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			check := &Check{Client: testClient(test.denied...), Permissions: required}
+			if err := check.Ready(nil); err == nil {
+				t.Error("expected the check not to be ready before it ran")
+			}
+
+			missing, err := check.check(context.TODO())
+			if err != nil {
+				t.Fatalf("unexpected error checking the permissions: %s", err)
+			}
+			check.setResult(missing)
+
+			err = check.Ready(nil)
+			if test.expectReady && err != nil {
+				t.Errorf("expected the check to be ready, got %s", err)
+			}
+			if !test.expectReady && (err == nil || !strings.Contains(err.Error(), "update certificaterequests/status.certman.managed.openshift.io")) {
+				t.Errorf("expected the check to report the missing critical permission, got %v", err)
+			}
+
+			if test.expectedMissing == nil {
+				assert.Empty(t, missing)
+			} else {
+				assert.Equal(t, test.expectedMissing, missing)
+			}
+			for _, p := range test.expectedMissing {
+				critical := strconv.FormatBool(p.Critical)
+				assert.Equal(t, float64(1), testutil.ToFloat64(localmetrics.MetricMissingPermission.WithLabelValues(p.Group, p.Resource, p.Verb, critical)))
+				localmetrics.SetMissingPermission(p.Group, p.Resource, p.Verb, p.Critical, false)
+			}
+		})
+	}
+}
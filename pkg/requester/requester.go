@@ -0,0 +1,216 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package requester lets other operators get certificates from certman instead of embedding ACME
+// logic. A Requester creates CertificateRequests labelled with its name, and only reads, updates
+// and revokes its own. Calls are authenticated by the Kubernetes API: the requesting operator needs
+// RBAC access to the CertificateRequests of its namespaces. The CertificateRequestPolicy of certman
+// decides which requesters are served and how many CertificateRequests each one may have.
+package requester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// ErrNotOwned is returned for a CertificateRequest that another requester, or certman itself,
+// created.
+var ErrNotOwned = errors.New("the CertificateRequest belongs to another requester")
+
+// problemConditions are the conditions that keep certman from issuing a certificate
+var problemConditions = []certmanv1alpha1.CertificateRequestConditionType{
+	certmanv1alpha1.CertificateRequestNotAuthorized,
+	certmanv1alpha1.CertificateRequestDelegationMismatch,
+	certmanv1alpha1.CertificateRequestCertificateSecretTooLarge,
+}
+
+// Requester creates and manages the CertificateRequests of one operator.
+type Requester struct {
+	Client client.Client
+	// Name identifies the operator, it is the value of the RequesterLabel of its CertificateRequests
+	Name string
+}
+
+// New returns a Requester of the CertificateRequests of the operator name through kubeClient, whose
+// scheme must have the certman types.
+func New(kubeClient client.Client, name string) *Requester {
+	return &Requester{Client: kubeClient, Name: name}
+}
+
+// Request describes the certificate an operator requests.
+type Request struct {
+	// Namespace and Name of the CertificateRequest
+	Namespace string
+	Name      string
+
+	// DNSNames are the subject alternative names of the certificate
+	DNSNames []string
+	// ACMEDNSDomain is the DNS zone the ACME challenges are answered in
+	ACMEDNSDomain string
+	// Platform holds the credentials of the cloud provider of ACMEDNSDomain
+	Platform certmanv1alpha1.Platform
+	// Email receives the expiry notifications of Let's Encrypt
+	Email string
+
+	// SecretName is the secret the certificate is stored in, it defaults to Name
+	SecretName string
+	// ReissueBeforeDays overrides the number of days before expiry the certificate is renewed
+	ReissueBeforeDays int
+}
+
+// Certificate is the state of a requested certificate.
+type Certificate struct {
+	// Issued is true once the certificate is stored in SecretName
+	Issued     bool
+	SecretName string
+	// NotAfter is the expiry of the issued certificate
+	NotAfter string
+	// Problem explains what keeps certman from issuing the certificate, such as the policy of the
+	// operator not allowing the requester or the requester being beyond its quota
+	Problem string
+}
+
+// Request creates the CertificateRequest of req, or updates it when its spec changed. It returns
+// ErrNotOwned when a CertificateRequest of another requester has the same name.
+func (r *Requester) Request(ctx context.Context, req Request) error {
+	if r.Name == "" {
+		return errors.New("the requester has no name")
+	}
+	if len(req.DNSNames) == 0 {
+		return fmt.Errorf("no DNS names requested for %s/%s", req.Namespace, req.Name)
+	}
+	secretName := req.SecretName
+	if secretName == "" {
+		secretName = req.Name
+	}
+	spec := certmanv1alpha1.CertificateRequestSpec{
+		ACMEDNSDomain: req.ACMEDNSDomain,
+		CertificateSecret: corev1.ObjectReference{
+			Kind:      "Secret",
+			Namespace: req.Namespace,
+			Name:      secretName,
+		},
+		Platform:          req.Platform,
+		DnsNames:          req.DNSNames,
+		Email:             req.Email,
+		ReissueBeforeDays: req.ReissueBeforeDays,
+	}
+
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, cr)
+	if kerrors.IsNotFound(err) {
+		cr = &certmanv1alpha1.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      req.Name,
+				Namespace: req.Namespace,
+				Labels:    map[string]string{certmanv1alpha1.RequesterLabel: r.Name},
+			},
+			Spec: spec,
+		}
+		return r.Client.Create(ctx, cr)
+	}
+	if err != nil {
+		return err
+	}
+	if !r.owns(cr) {
+		return ErrNotOwned
+	}
+
+	// keep the fields set by other means, such as revokeOnDelete
+	updated := cr.Spec.DeepCopy()
+	updated.ACMEDNSDomain = spec.ACMEDNSDomain
+	updated.CertificateSecret = spec.CertificateSecret
+	updated.Platform = spec.Platform
+	updated.DnsNames = spec.DnsNames
+	updated.Email = spec.Email
+	updated.ReissueBeforeDays = spec.ReissueBeforeDays
+	if reflect.DeepEqual(*updated, cr.Spec) {
+		return nil
+	}
+	cr.Spec = *updated
+	return r.Client.Update(ctx, cr)
+}
+
+// Get returns the state of the certificate of the CertificateRequest namespace/name.
+func (r *Requester) Get(ctx context.Context, namespace string, name string) (*Certificate, error) {
+	cr := &certmanv1alpha1.CertificateRequest{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cr); err != nil {
+		return nil, err
+	}
+	if !r.owns(cr) {
+		return nil, ErrNotOwned
+	}
+
+	certificate := &Certificate{
+		Issued:     cr.Status.Issued,
+		SecretName: cr.Spec.CertificateSecret.Name,
+		NotAfter:   cr.Status.NotAfter,
+	}
+	for _, conditionType := range problemConditions {
+		for _, condition := range cr.Status.Conditions {
+			if condition.Type == conditionType && condition.Status == corev1.ConditionTrue && condition.Message != nil {
+				certificate.Problem = *condition.Message
+				return certificate, nil
+			}
+		}
+	}
+	if cr.Status.LastFailure != nil && !cr.Status.Issued {
+		certificate.Problem = cr.Status.LastFailure.Detail
+	}
+	return certificate, nil
+}
+
+// Revoke revokes the certificate of the CertificateRequest namespace/name and deletes it, along
+// with its secret, regardless of the revokeOnDelete setting of certman. A missing CertificateRequest
+// is already revoked.
+func (r *Requester) Revoke(ctx context.Context, namespace string, name string) error {
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cr)
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !r.owns(cr) {
+		return ErrNotOwned
+	}
+
+	if cr.Spec.RevokeOnDelete == nil || !*cr.Spec.RevokeOnDelete {
+		revoke := true
+		cr.Spec.RevokeOnDelete = &revoke
+		if err := r.Client.Update(ctx, cr); err != nil {
+			return err
+		}
+	}
+	return client.IgnoreNotFound(r.Client.Delete(ctx, cr))
+}
+
+// owns returns true when cr was created by r. A Requester without a name owns nothing, rather than
+// the unlabelled CertificateRequests of certman.
+func (r *Requester) owns(cr *certmanv1alpha1.CertificateRequest) bool {
+	return r.Name != "" && cr.Labels[certmanv1alpha1.RequesterLabel] == r.Name
+}
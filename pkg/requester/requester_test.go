@@ -0,0 +1,128 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package requester
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+const testNamespace = "ingress-addon"
+
+func testRequest() Request {
+	return Request{
+		Namespace:     testNamespace,
+		Name:          "apps-cert",
+		DNSNames:      []string{"*.apps.example.com"},
+		ACMEDNSDomain: "example.com",
+		Platform: certmanv1alpha1.Platform{
+			AWS: &certmanv1alpha1.AWSPlatformSecrets{Credentials: corev1.LocalObjectReference{Name: "aws"}, Region: "us-east-1"},
+		},
+		Email: "sre@example.com",
+	}
+}
+
+func TestRequester(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a CertificateRequest of certman itself
+	clusterCR := &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "primary-cert-bundle"},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(clusterCR).Build()
+	r := New(kubeClient, "ingress-addon-operator")
+	key := types.NamespacedName{Namespace: testNamespace, Name: "apps-cert"}
+
+	if err := r.Request(context.TODO(), testRequest()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cr := &certmanv1alpha1.CertificateRequest{}
+	if err := kubeClient.Get(context.TODO(), key, cr); err != nil {
+		t.Fatalf("expected the CertificateRequest to be created: %s", err)
+	}
+	if cr.Labels[certmanv1alpha1.RequesterLabel] != "ingress-addon-operator" {
+		t.Errorf("expected the requester label, got %v", cr.Labels)
+	}
+	if cr.Spec.CertificateSecret.Name != "apps-cert" {
+		t.Errorf("expected the secret to default to the name of the request, got %s", cr.Spec.CertificateSecret.Name)
+	}
+
+	// a new DNS name updates the spec
+	req := testRequest()
+	req.DNSNames = append(req.DNSNames, "apps.example.com")
+	if err := r.Request(context.TODO(), req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := kubeClient.Get(context.TODO(), key, cr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cr.Spec.DnsNames) != 2 {
+		t.Errorf("expected the DNS names to be updated, got %v", cr.Spec.DnsNames)
+	}
+
+	// the status reports what keeps the certificate from being issued
+	message := "requester ingress-addon-operator has reached its quota of 1 CertificateRequests"
+	cr.Status.Conditions = []certmanv1alpha1.CertificateRequestCondition{{
+		Type:    certmanv1alpha1.CertificateRequestNotAuthorized,
+		Status:  corev1.ConditionTrue,
+		Message: &message,
+	}}
+	if err := kubeClient.Update(context.TODO(), cr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	certificate, err := r.Get(context.TODO(), testNamespace, "apps-cert")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if certificate.Issued || certificate.Problem != message {
+		t.Errorf("expected the quota to be reported, got %+v", certificate)
+	}
+
+	// the CertificateRequests of others are left alone
+	if _, err := r.Get(context.TODO(), testNamespace, "primary-cert-bundle"); !errors.Is(err, ErrNotOwned) {
+		t.Errorf("expected ErrNotOwned, got %v", err)
+	}
+	if err := r.Revoke(context.TODO(), testNamespace, "primary-cert-bundle"); !errors.Is(err, ErrNotOwned) {
+		t.Errorf("expected ErrNotOwned, got %v", err)
+	}
+	if err := New(kubeClient, "").Revoke(context.TODO(), testNamespace, "primary-cert-bundle"); !errors.Is(err, ErrNotOwned) {
+		t.Errorf("expected a requester without a name to own nothing, got %v", err)
+	}
+
+	if err := r.Revoke(context.TODO(), testNamespace, "apps-cert"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := kubeClient.Get(context.TODO(), key, cr); !kerrors.IsNotFound(err) {
+		t.Errorf("expected the CertificateRequest to be deleted, got %v", err)
+	}
+	if err := r.Revoke(context.TODO(), testNamespace, "apps-cert"); err != nil {
+		t.Errorf("expected revoking a deleted CertificateRequest to succeed, got %s", err)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding spreads the CertificateRequests over the operator replicas by namespace. Each
+// replica renews a Lease in the operator namespace, and the namespaces are assigned to the replicas
+// whose Lease is current by rendezvous hashing, so a replica joining or leaving only moves the
+// namespaces it gains or owned.
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+)
+
+const (
+	leaseNamePrefix = "certman-shard-"
+	// MemberLabel is set on the Leases of the replicas sharing the CertificateRequests
+	MemberLabel = "certman.managed.openshift.io/shard-member"
+	// leaseDurationSeconds is how long a replica that stopped renewing its Lease keeps its namespaces
+	leaseDurationSeconds = 30
+	// renewInterval is how often a replica renews its Lease and refreshes the members
+	renewInterval = 10 * time.Second
+	// staleLeaseAge is how long after expiring the Lease of a replica is deleted
+	staleLeaseAge = time.Hour
+)
+
+var log = logf.Log.WithName("sharding")
+
+var _ manager.LeaderElectionRunnable = &Shard{}
+
+// Shard maintains the Lease of this replica and tracks the namespaces it owns.
+type Shard struct {
+	Client client.Client
+	// Identity names this replica, it defaults to the hostname
+	Identity string
+	// Events receives a GenericEvent for each CertificateRequest of the namespaces this replica
+	// takes over, as the events the CertificateRequest controller received for them were dropped.
+	Events chan event.GenericEvent
+
+	mu      sync.RWMutex
+	ready   bool
+	members []string
+	// expiry is when the Lease of this replica, as last renewed, expires
+	expiry time.Time
+}
+
+// New returns a Shard of the replicas sharing the CertificateRequests through kubeClient.
+func New(kubeClient client.Client) *Shard {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = config.OperatorName
+	}
+	return &Shard{
+		Client:   kubeClient,
+		Identity: hostname,
+		Events:   make(chan event.GenericEvent),
+	}
+}
+
+// Start renews the Lease of this replica and refreshes the members every renewInterval until ctx
+// is done, then deletes the Lease so the other replicas take over its namespaces right away.
+func (s *Shard) Start(ctx context.Context) error {
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.refresh(ctx, time.Now()); err != nil {
+			log.Error(err, "could not refresh the shard members")
+		}
+
+		select {
+		case <-ctx.Done():
+			lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: config.OperatorNamespace, Name: s.leaseName()}}
+			if err := s.Client.Delete(context.TODO(), lease); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "could not delete the shard Lease, the other replicas take over when it expires")
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns false, every replica owns a shard.
+func (s *Shard) NeedLeaderElection() bool {
+	return false
+}
+
+// Owns returns true when this replica owns namespace. No namespace is owned until the members
+// were read, nor once the Lease of this replica couldn't be renewed or expired, as the other
+// replicas may have taken its namespaces over.
+func (s *Shard) Owns(namespace string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ready && time.Now().Before(s.expiry) && Owner(s.members, namespace) == s.Identity
+}
+
+// Owner returns the member owning namespace, the one with the highest hash of its name and the
+// namespace, or an empty string without members.
+func Owner(members []string, namespace string) string {
+	owner := ""
+	var highest uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(member + "/" + namespace))
+		if score := h.Sum64(); owner == "" || score > highest {
+			owner, highest = member, score
+		}
+	}
+	return owner
+}
+
+// refresh renews the Lease of this replica, reads the members whose Lease is current at now, and
+// sends the CertificateRequests of the namespaces this replica gained to Events. This replica owns
+// nothing until a refresh that failed to renew its Lease is followed by one that succeeds.
+func (s *Shard) refresh(ctx context.Context, now time.Time) error {
+	if err := s.renewLease(ctx, now); err != nil {
+		s.mu.Lock()
+		s.ready = false
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Lock()
+	s.expiry = now.Add(leaseDurationSeconds * time.Second)
+	s.mu.Unlock()
+
+	leases := &coordinationv1.LeaseList{}
+	if err := s.Client.List(ctx, leases, client.InNamespace(config.OperatorNamespace), client.MatchingLabels{MemberLabel: "true"}); err != nil {
+		return err
+	}
+
+	members := []string{}
+	for i := range leases.Items {
+		lease := &leases.Items[i]
+		expiry := leaseExpiry(lease)
+		switch {
+		case now.Before(expiry) && lease.Spec.HolderIdentity != nil:
+			members = append(members, *lease.Spec.HolderIdentity)
+		case now.Sub(expiry) > staleLeaseAge:
+			if err := s.Client.Delete(ctx, lease); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "could not delete the stale shard Lease", "name", lease.Name)
+			}
+		}
+	}
+	sort.Strings(members)
+
+	s.mu.Lock()
+	previous, wasReady := s.members, s.ready
+	s.members, s.ready = members, true
+	s.mu.Unlock()
+
+	if wasReady && equal(previous, members) {
+		return nil
+	}
+	log.Info(fmt.Sprintf("shard members are now %v", members))
+
+	crList := &certmanv1alpha1.CertificateRequestList{}
+	if err := s.Client.List(ctx, crList); err != nil {
+		return err
+	}
+	gained := []event.GenericEvent{}
+	for i := range crList.Items {
+		cr := &crList.Items[i]
+		if Owner(members, cr.Namespace) == s.Identity && (!wasReady || Owner(previous, cr.Namespace) != s.Identity) {
+			gained = append(gained, event.GenericEvent{Object: cr})
+		}
+	}
+	if len(gained) == 0 {
+		return nil
+	}
+
+	// the controller may not consume the events before the Lease needs renewing again
+	go func() {
+		for _, e := range gained {
+			select {
+			case s.Events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// renewLease creates or renews the Lease of this replica.
+func (s *Shard) renewLease(ctx context.Context, now time.Time) error {
+	renewTime := metav1.NewMicroTime(now)
+	duration := int32(leaseDurationSeconds)
+
+	lease := &coordinationv1.Lease{}
+	err := s.Client.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: s.leaseName()}, lease)
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.leaseName(),
+				Namespace: config.OperatorNamespace,
+				Labels:    map[string]string{MemberLabel: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.Identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+		return s.Client.Create(ctx, lease)
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec.HolderIdentity = &s.Identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.RenewTime = &renewTime
+	return s.Client.Update(ctx, lease)
+}
+
+// leaseName returns the name of the Lease of this replica.
+func (s *Shard) leaseName() string {
+	return leaseNamePrefix + s.Identity
+}
+
+// leaseExpiry returns when lease expires, or the zero time when it was never renewed.
+func leaseExpiry(lease *coordinationv1.Lease) time.Time {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return time.Time{}
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
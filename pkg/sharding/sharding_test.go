@@ -0,0 +1,180 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/pkg/testutils"
+)
+
+func memberLease(identity string, renewTime time.Time) *coordinationv1.Lease {
+	duration := int32(leaseDurationSeconds)
+	renew := metav1.NewMicroTime(renewTime)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaseNamePrefix + identity,
+			Namespace: config.OperatorNamespace,
+			Labels:    map[string]string{MemberLabel: "true"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renew,
+		},
+	}
+}
+
+func TestOwnerMovesOnlyTheNamespacesOfChangedMembers(t *testing.T) {
+	members := []string{"replica-a", "replica-b", "replica-c"}
+	withD := append(append([]string{}, members...), "replica-d")
+
+	for i := 0; i < 200; i++ {
+		namespace := fmt.Sprintf("uhc-production-%d", i)
+		before := Owner(members, namespace)
+		after := Owner(withD, namespace)
+		if before != after && after != "replica-d" {
+			t.Errorf("namespace %s moved from %s to %s when replica-d joined", namespace, before, after)
+		}
+	}
+
+	if owner := Owner(nil, "uhc-production-0"); owner != "" {
+		t.Errorf("expected no owner without members, got %s", owner)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	now := time.Now()
+
+	crs := []runtime.Object{}
+	for i := 0; i < 20; i++ {
+		crs = append(crs, &certmanv1alpha1.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: fmt.Sprintf("uhc-production-%d", i), Name: "primary-cert-bundle"},
+		})
+	}
+	objects := append(crs,
+		memberLease("replica-b", now),
+		// expired, its namespaces are taken over
+		memberLease("replica-c", now.Add(-time.Minute)),
+		// expired long ago, it is deleted
+		memberLease("replica-d", now.Add(-2*staleLeaseAge)),
+	)
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objects...).Build()
+
+	s := &Shard{Client: kubeClient, Identity: "replica-a", Events: make(chan event.GenericEvent, len(crs))}
+	if s.Owns("uhc-production-0") {
+		t.Errorf("expected no namespace to be owned before the members are read")
+	}
+
+	if err := s.refresh(context.TODO(), now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	leases := &coordinationv1.LeaseList{}
+	if err := kubeClient.List(context.TODO(), leases); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	names := map[string]bool{}
+	for _, lease := range leases.Items {
+		names[lease.Name] = true
+	}
+	if !names[leaseNamePrefix+"replica-a"] || names[leaseNamePrefix+"replica-d"] {
+		t.Errorf("expected the lease of replica-a to be created and the stale one deleted, got %v", names)
+	}
+
+	owned := 0
+	for i := 0; i < 20; i++ {
+		namespace := fmt.Sprintf("uhc-production-%d", i)
+		expected := Owner([]string{"replica-a", "replica-b"}, namespace) == "replica-a"
+		if s.Owns(namespace) != expected {
+			t.Errorf("expected ownership of %s to be %t", namespace, expected)
+		}
+		if expected {
+			owned++
+		}
+	}
+
+	// the events are sent in the background
+	for i := 0; i < owned; i++ {
+		select {
+		case e := <-s.Events:
+			if !s.Owns(e.Object.GetNamespace()) {
+				t.Errorf("got an event for %s, which isn't owned", e.Object.GetNamespace())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d events, got %d", owned, i)
+		}
+	}
+}
+
+func TestOwnsNothingWithoutACurrentLease(t *testing.T) {
+	err := certmanv1alpha1.AddToScheme(scheme.Scheme)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	now := time.Now()
+	namespace := "uhc-production-0"
+
+	kubeClient := testutils.NewFaultClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build())
+	s := &Shard{Client: kubeClient, Identity: "replica-a", Events: make(chan event.GenericEvent, 1)}
+	if err := s.refresh(context.TODO(), now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !s.Owns(namespace) {
+		t.Fatalf("expected the only replica to own %s", namespace)
+	}
+
+	// the Lease can't be renewed, the other replicas take the namespaces over once it expires
+	kubeClient.Inject(testutils.Fault{Verb: testutils.Update, Object: &coordinationv1.Lease{}, Times: 1, Class: testutils.Timeout})
+	if err := s.refresh(context.TODO(), now.Add(renewInterval)); err == nil {
+		t.Fatal("expected the failed renewal to be returned")
+	}
+	if s.Owns(namespace) {
+		t.Errorf("expected %s not to be owned after a failed renewal", namespace)
+	}
+
+	if err := s.refresh(context.TODO(), now.Add(2*renewInterval)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !s.Owns(namespace) {
+		t.Errorf("expected %s to be owned again once the Lease was renewed", namespace)
+	}
+
+	// a Lease last renewed longer ago than its duration has expired
+	if err := s.refresh(context.TODO(), now.Add(-2*leaseDurationSeconds*time.Second)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if s.Owns(namespace) {
+		t.Errorf("expected %s not to be owned once the Lease expired", namespace)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutils holds helpers shared by the tests of the controllers.
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Verb is a call of the client a Fault applies to.
+type Verb string
+
+// The verbs of the calls of the client, the status ones being the calls of its status writer
+const (
+	Get          Verb = "get"
+	List         Verb = "list"
+	Create       Verb = "create"
+	Update       Verb = "update"
+	Patch        Verb = "patch"
+	Delete       Verb = "delete"
+	StatusUpdate Verb = "status-update"
+	StatusPatch  Verb = "status-patch"
+)
+
+// ErrorClass is the class of the API error a Fault returns.
+type ErrorClass string
+
+// The classes of the errors a Fault can return, as checked by the functions of
+// k8s.io/apimachinery/pkg/api/errors
+const (
+	// InternalError is the default class, an unexpected error of the apiserver
+	InternalError   ErrorClass = "InternalError"
+	Conflict        ErrorClass = "Conflict"
+	Timeout         ErrorClass = "Timeout"
+	NotFound        ErrorClass = "NotFound"
+	Forbidden       ErrorClass = "Forbidden"
+	TooManyRequests ErrorClass = "TooManyRequests"
+)
+
+// Fault makes the calls of a FaultClient matching it fail.
+type Fault struct {
+	// Verb is the call that fails
+	Verb Verb
+	// Object is an object of the type the calls fail for, the list type for List, any type when nil
+	Object runtime.Object
+	// Name is the name of the object the calls fail for, any name when empty
+	Name string
+	// After is the number of matching calls that succeed before the fault applies
+	After int
+	// Times is the number of matching calls that fail once the fault applies, every call when 0
+	Times int
+	// Class is the class of the error returned, InternalError when empty
+	Class ErrorClass
+	// Err is returned as is instead of an error of Class when set
+	Err error
+}
+
+// fault is a Fault along with the number of calls that matched it.
+type fault struct {
+	Fault
+	calls int
+}
+
+// FaultClient is a client returning the errors of its faults for the calls matching them, and
+// passing the other calls to the client it wraps.
+type FaultClient struct {
+	client.Client
+
+	mu          sync.Mutex
+	faults      []*fault
+	calls       map[Verb]int
+	objectCalls map[Verb]map[reflect.Type]int
+	namedCalls  map[Verb]map[reflect.Type]map[string]int
+}
+
+// NewFaultClient returns a FaultClient wrapping c and failing the calls matching faults.
+func NewFaultClient(c client.Client, faults ...Fault) *FaultClient {
+	fc := &FaultClient{Client: c}
+	fc.Reset()
+	for _, f := range faults {
+		fc.Inject(f)
+	}
+	return fc
+}
+
+// Inject adds f to the faults of c.
+func (c *FaultClient) Inject(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &fault{Fault: f})
+}
+
+// Reset removes the faults of c, and the counts of its calls.
+func (c *FaultClient) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+	c.calls = map[Verb]int{}
+	c.objectCalls = map[Verb]map[reflect.Type]int{}
+	c.namedCalls = map[Verb]map[reflect.Type]map[string]int{}
+}
+
+// Calls returns the number of calls of verb made to c, failed ones included.
+func (c *FaultClient) Calls(verb Verb) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[verb]
+}
+
+// ObjectCalls returns the number of calls of verb made to c for objects of the type of obj, the
+// list type for List, failed ones included.
+func (c *FaultClient) ObjectCalls(verb Verb, obj runtime.Object) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.objectCalls[verb][reflect.TypeOf(obj)]
+}
+
+// NamedObjectCalls returns the number of calls of verb made to c for the objects named name of the
+// type of obj, failed ones included. List calls have no name.
+func (c *FaultClient) NamedObjectCalls(verb Verb, obj runtime.Object, name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.namedCalls[verb][reflect.TypeOf(obj)][name]
+}
+
+func (c *FaultClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.inject(Get, obj, key.Name); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *FaultClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.inject(List, list, ""); err != nil {
+		return err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *FaultClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.inject(Create, obj, obj.GetName()); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *FaultClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.inject(Update, obj, obj.GetName()); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *FaultClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.inject(Patch, obj, obj.GetName()); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *FaultClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.inject(Delete, obj, obj.GetName()); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *FaultClient) Status() client.SubResourceWriter {
+	return &faultStatusWriter{SubResourceWriter: c.Client.Status(), client: c}
+}
+
+// faultStatusWriter fails the status updates and patches matching the faults of client.
+type faultStatusWriter struct {
+	client.SubResourceWriter
+	client *FaultClient
+}
+
+func (w *faultStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if err := w.client.inject(StatusUpdate, obj, obj.GetName()); err != nil {
+		return err
+	}
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w *faultStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if err := w.client.inject(StatusPatch, obj, obj.GetName()); err != nil {
+		return err
+	}
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// inject counts a call of verb for obj named name, and returns the error of the first fault
+// matching it, if any.
+func (c *FaultClient) inject(verb Verb, obj runtime.Object, name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls[verb]++
+	if c.objectCalls[verb] == nil {
+		c.objectCalls[verb] = map[reflect.Type]int{}
+	}
+	c.objectCalls[verb][reflect.TypeOf(obj)]++
+	if c.namedCalls[verb] == nil {
+		c.namedCalls[verb] = map[reflect.Type]map[string]int{}
+	}
+	if c.namedCalls[verb][reflect.TypeOf(obj)] == nil {
+		c.namedCalls[verb][reflect.TypeOf(obj)] = map[string]int{}
+	}
+	c.namedCalls[verb][reflect.TypeOf(obj)][name]++
+	for _, f := range c.faults {
+		if f.Verb != verb || (f.Name != "" && f.Name != name) ||
+			(f.Object != nil && reflect.TypeOf(f.Object) != reflect.TypeOf(obj)) {
+			continue
+		}
+		f.calls++
+		if f.calls <= f.After || (f.Times > 0 && f.calls > f.After+f.Times) {
+			continue
+		}
+		if f.Err != nil {
+			return f.Err
+		}
+		return c.newError(f.Class, verb, obj, name)
+	}
+	return nil
+}
+
+// newError returns an API error of class for the call of verb for obj named name.
+func (c *FaultClient) newError(class ErrorClass, verb Verb, obj runtime.Object, name string) error {
+	resource := schema.GroupResource{Resource: "unknown"}
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		resource = schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(strings.TrimSuffix(gvk.Kind, "List")) + "s"}
+	}
+	message := fmt.Sprintf("injected %s of %s", class, verb)
+
+	switch class {
+	case Conflict:
+		return apierrors.NewConflict(resource, name, errors.New(message))
+	case Timeout:
+		return apierrors.NewTimeoutError(message, 1)
+	case NotFound:
+		return apierrors.NewNotFound(resource, name)
+	case Forbidden:
+		return apierrors.NewForbidden(resource, name, errors.New(message))
+	case TooManyRequests:
+		return apierrors.NewTooManyRequests(message, 1)
+	}
+	return apierrors.NewInternalError(errors.New(message))
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestFaultClient(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "namespace"}}
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "namespace"}}
+	secretKey := types.NamespacedName{Namespace: "namespace", Name: "secret"}
+	configMapKey := types.NamespacedName{Namespace: "namespace", Name: "config"}
+
+	t.Run("by type", func(t *testing.T) {
+		c := NewFaultClient(fake.NewClientBuilder().WithObjects(secret, configMap).Build(),
+			Fault{Verb: Get, Object: &corev1.Secret{}, Class: Conflict})
+
+		err := c.Get(context.TODO(), secretKey, &corev1.Secret{})
+		assert.True(t, apierrors.IsConflict(err), "expected a conflict, got %v", err)
+		assert.NoError(t, c.Get(context.TODO(), configMapKey, &corev1.ConfigMap{}))
+		assert.Equal(t, 2, c.Calls(Get))
+		assert.Equal(t, 1, c.ObjectCalls(Get, &corev1.Secret{}))
+		assert.Equal(t, 0, c.ObjectCalls(List, &corev1.SecretList{}))
+	})
+
+	t.Run("by name", func(t *testing.T) {
+		c := NewFaultClient(fake.NewClientBuilder().WithObjects(secret, configMap).Build(),
+			Fault{Verb: Get, Name: "config", Class: NotFound})
+
+		assert.NoError(t, c.Get(context.TODO(), secretKey, &corev1.Secret{}))
+		assert.True(t, apierrors.IsNotFound(c.Get(context.TODO(), configMapKey, &corev1.ConfigMap{})))
+	})
+
+	t.Run("by call count", func(t *testing.T) {
+		c := NewFaultClient(fake.NewClientBuilder().WithObjects(secret).Build(),
+			Fault{Verb: Get, After: 1, Times: 2, Class: Timeout})
+
+		assert.NoError(t, c.Get(context.TODO(), secretKey, &corev1.Secret{}))
+		assert.True(t, apierrors.IsTimeout(c.Get(context.TODO(), secretKey, &corev1.Secret{})))
+		assert.True(t, apierrors.IsTimeout(c.Get(context.TODO(), secretKey, &corev1.Secret{})))
+		assert.NoError(t, c.Get(context.TODO(), secretKey, &corev1.Secret{}))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		c := NewFaultClient(fake.NewClientBuilder().WithObjects(secret).Build(),
+			Fault{Verb: List, Object: &corev1.SecretList{}, Class: Forbidden})
+
+		assert.True(t, apierrors.IsForbidden(c.List(context.TODO(), &corev1.SecretList{})))
+		assert.NoError(t, c.List(context.TODO(), &corev1.ConfigMapList{}))
+	})
+
+	t.Run("writes", func(t *testing.T) {
+		injected := errors.New("injected")
+		c := NewFaultClient(fake.NewClientBuilder().WithObjects(secret).Build(),
+			Fault{Verb: Update, Err: injected},
+			Fault{Verb: StatusUpdate, Class: TooManyRequests})
+
+		updated := secret.DeepCopy()
+		assert.Equal(t, injected, c.Update(context.TODO(), updated))
+		assert.True(t, apierrors.IsTooManyRequests(c.Status().Update(context.TODO(), updated)))
+		assert.True(t, apierrors.IsInternalError(NewFaultClient(c.Client, Fault{Verb: Delete}).Delete(context.TODO(), updated)))
+
+		c.Reset()
+		assert.NoError(t, c.Update(context.TODO(), updated))
+		assert.Equal(t, 1, c.Calls(Update))
+	})
+}
@@ -1,5 +1,31 @@
 package version
 
+import "runtime/debug"
+
 var (
 	SDKVersion = "1.21.0"
+
+	// Version is the version of the operator, set when building with
+	// -ldflags "-X github.com/openshift/certman-operator/pkg/version.Version=<version>"
+	Version = "unknown"
+
+	// GitCommit is the git SHA the operator was built from, set like Version. When it isn't set, the
+	// revision stamped in the binary by the go toolchain is used.
+	GitCommit = ""
 )
+
+// Commit returns the git SHA the operator was built from, or "unknown".
+func Commit() string {
+	if GitCommit != "" {
+		return GitCommit
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package zonelock serializes writes of an ACME challenge record to a DNS zone. Within the operator
+// process a semaphore is held per record, and a Lease in the operator namespace extends the lock
+// across operator replicas. CertificateRequests answering challenges of other domains of the same
+// zone don't wait for each other.
+package zonelock
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/certman-operator/config"
+	"github.com/openshift/certman-operator/pkg/localmetrics"
+)
+
+const (
+	leaseNamePrefix = "certman-zone-"
+	// ZoneAnnotation records the zone a Lease locks, as the Lease name is derived from a hash of it.
+	ZoneAnnotation = "certman.managed.openshift.io/dns-zone"
+	// RecordAnnotation records the name of the challenge record a Lease locks in its zone.
+	RecordAnnotation = "certman.managed.openshift.io/dns-record"
+	// leaseDurationSeconds bounds how long a crashed holder can keep a record locked.
+	leaseDurationSeconds = 900
+	// renewInterval is how often a held Lease is renewed, well within its duration
+	renewInterval = leaseDurationSeconds * time.Second / 3
+	retryInterval = 5 * time.Second
+)
+
+var (
+	log      = logf.Log.WithName("zonelock")
+	identity = holderIdentity()
+
+	mu    sync.Mutex
+	locks = map[string]*recordLock{}
+)
+
+// recordLock is the in-process lock of a record, shared by the goroutines locking or waiting for
+// it. It is forgotten once none of them needs it.
+type recordLock struct {
+	sem  chan struct{}
+	refs int
+}
+
+// Lock blocks until this operator holds the lock of the challenge record called record in the DNS
+// zone zoneID, or ctx is done, and returns a function releasing it. The Lease is renewed until
+// then, however long the lock is held.
+func Lock(ctx context.Context, kubeClient client.Client, zoneID string, record string) (func(), error) {
+	start := time.Now()
+	key := zoneID + "/" + record
+	l := acquireRecordLock(key)
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		releaseRecordLock(key)
+		return nil, ctx.Err()
+	}
+	unlock := func() {
+		<-l.sem
+		releaseRecordLock(key)
+	}
+
+	name := LeaseName(zoneID, record)
+	for {
+		acquired, err := tryAcquireLease(ctx, kubeClient, name, zoneID, record, time.Now())
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+
+		log.Info(fmt.Sprintf("waiting for the lock of record %v of dns zone %v held by another replica", record, zoneID))
+		select {
+		case <-ctx.Done():
+			unlock()
+			return nil, ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+	localmetrics.ObserveDNSZoneLockWait(time.Since(start))
+
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		renewLeaseUntil(kubeClient, name, stop)
+	}()
+
+	return func() {
+		close(stop)
+		<-renewed
+		if err := releaseLease(kubeClient, name); err != nil {
+			log.Error(err, fmt.Sprintf("failed to release the lock of record %v of dns zone %v, it will expire in %v seconds", record, zoneID, leaseDurationSeconds))
+		}
+		unlock()
+	}, nil
+}
+
+// LeaseName returns the name of the Lease locking record in zoneID. Zone IDs such as
+// "/hostedzone/Z123" are not valid object names, so the name is derived from a hash of both.
+func LeaseName(zoneID string, record string) string {
+	return fmt.Sprintf("%s%x", leaseNamePrefix, sha256.Sum256([]byte(zoneID+"/"+record)))[:len(leaseNamePrefix)+16]
+}
+
+// acquireRecordLock returns the in-process lock of key, counting the caller as one of its users.
+func acquireRecordLock(key string) *recordLock {
+	mu.Lock()
+	defer mu.Unlock()
+
+	l, ok := locks[key]
+	if !ok {
+		l = &recordLock{sem: make(chan struct{}, 1)}
+		locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// releaseRecordLock forgets the in-process lock of key once its last user is done with it.
+func releaseRecordLock(key string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if l, ok := locks[key]; ok {
+		l.refs--
+		if l.refs == 0 {
+			delete(locks, key)
+		}
+	}
+}
+
+// renewLeaseUntil renews the Lease called name every renewInterval until stop is closed, so that a
+// lock held longer than the Lease duration isn't taken over by another replica.
+func renewLeaseUntil(kubeClient client.Client, name string, stop <-chan struct{}) {
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := renewLease(kubeClient, name, time.Now()); err != nil {
+				log.Error(err, fmt.Sprintf("failed to renew lease %v", name))
+			}
+		}
+	}
+}
+
+// renewLease sets the renew time of the Lease called name to now if it is still held by this operator.
+func renewLease(kubeClient client.Client, name string, now time.Time) error {
+	lease := &coordinationv1.Lease{}
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: name}, lease)
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != identity {
+		return fmt.Errorf("lease %v is no longer held by this operator", name)
+	}
+	renewTime := metav1.NewMicroTime(now)
+	lease.Spec.RenewTime = &renewTime
+	return kubeClient.Update(context.TODO(), lease)
+}
+
+// tryAcquireLease takes the Lease called name unless another holder has renewed it within its duration.
+// Losing a race with another replica is reported as not acquired rather than as an error.
+func tryAcquireLease(ctx context.Context, kubeClient client.Client, name string, zoneID string, record string, now time.Time) (bool, error) {
+	renewTime := metav1.NewMicroTime(now)
+	duration := int32(leaseDurationSeconds)
+
+	lease := &coordinationv1.Lease{}
+	err := kubeClient.Get(ctx, types.NamespacedName{Namespace: config.OperatorNamespace, Name: name}, lease)
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   config.OperatorNamespace,
+				Annotations: map[string]string{ZoneAnnotation: zoneID, RecordAnnotation: record},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+		err = kubeClient.Create(ctx, lease)
+		if errors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if heldByOther(lease, now) {
+		return false, nil
+	}
+
+	lease.Spec.HolderIdentity = &identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.AcquireTime = &renewTime
+	lease.Spec.RenewTime = &renewTime
+	err = kubeClient.Update(ctx, lease)
+	if errors.IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// releaseLease clears the holder of the Lease called name if it is still held by this operator.
+func releaseLease(kubeClient client.Client, name string) error {
+	lease := &coordinationv1.Lease{}
+	err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: name}, lease)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != identity {
+		return nil
+	}
+
+	lease.Spec.HolderIdentity = nil
+	return kubeClient.Update(context.TODO(), lease)
+}
+
+// heldByOther returns true if lease is held by another operator and has not expired at now.
+func heldByOther(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" || *lease.Spec.HolderIdentity == identity {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiry)
+}
+
+// holderIdentity identifies this operator replica as a Lease holder.
+func holderIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = config.OperatorName
+	}
+	return fmt.Sprintf("%s_%d", hostname, os.Getpid())
+}
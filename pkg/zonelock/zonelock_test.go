@@ -0,0 +1,195 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package zonelock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/certman-operator/config"
+)
+
+const (
+	testZoneID = "/hostedzone/Z10091REDACTEDW6I"
+	testRecord = "_acme-challenge.api.example.com"
+)
+
+func otherHolderLease(renewTime time.Time) *coordinationv1.Lease {
+	holder := "another-replica"
+	duration := int32(leaseDurationSeconds)
+	renew := metav1.NewMicroTime(renewTime)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      LeaseName(testZoneID, testRecord),
+			Namespace: config.OperatorNamespace,
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renew,
+		},
+	}
+}
+
+func TestTryAcquireLease(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name           string
+		objects        []runtime.Object
+		expectAcquired bool
+		expectErr      bool
+		expectedHolder string
+	}{
+		{
+			name:           "creates the lease when the zone is not locked",
+			expectAcquired: true,
+			expectedHolder: identity,
+		},
+		{
+			name:           "waits for a lease held by another replica",
+			objects:        []runtime.Object{otherHolderLease(now)},
+			expectAcquired: false,
+			expectedHolder: "another-replica",
+		},
+		{
+			name:           "takes over an expired lease",
+			objects:        []runtime.Object{otherHolderLease(now.Add(-2 * leaseDurationSeconds * time.Second))},
+			expectAcquired: true,
+			expectedHolder: identity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(test.objects...).Build()
+
+			acquired, err := tryAcquireLease(context.TODO(), kubeClient, LeaseName(testZoneID, testRecord), testZoneID, testRecord, now)
+			if (err != nil) != test.expectErr {
+				t.Fatalf("tryAcquireLease() unexpected error: %v", err)
+			}
+			if acquired != test.expectAcquired {
+				t.Errorf("tryAcquireLease() expected %t, got %t", test.expectAcquired, acquired)
+			}
+
+			lease := &coordinationv1.Lease{}
+			if err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: LeaseName(testZoneID, testRecord)}, lease); err != nil {
+				t.Fatalf("unexpected error getting lease: %v", err)
+			}
+			if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != test.expectedHolder {
+				t.Errorf("expected lease to be held by %s, got %v", test.expectedHolder, lease.Spec.HolderIdentity)
+			}
+		})
+	}
+}
+
+func TestLock(t *testing.T) {
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	unlock, err := Lock(context.TODO(), kubeClient, testZoneID, testRecord)
+	if err != nil {
+		t.Fatalf("Lock() unexpected error: %v", err)
+	}
+	unlock()
+
+	lease := &coordinationv1.Lease{}
+	if err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: LeaseName(testZoneID, testRecord)}, lease); err != nil {
+		t.Fatalf("unexpected error getting lease: %v", err)
+	}
+	if lease.Spec.HolderIdentity != nil {
+		t.Errorf("expected lease to be released, held by %s", *lease.Spec.HolderIdentity)
+	}
+	if lease.Annotations[ZoneAnnotation] != testZoneID {
+		t.Errorf("expected lease to be annotated with zone %s, got %s", testZoneID, lease.Annotations[ZoneAnnotation])
+	}
+
+	if lease.Annotations[RecordAnnotation] != testRecord {
+		t.Errorf("expected lease to be annotated with record %s, got %s", testRecord, lease.Annotations[RecordAnnotation])
+	}
+
+	// the record can be locked again once released
+	unlock, err = Lock(context.TODO(), kubeClient, testZoneID, testRecord)
+	if err != nil {
+		t.Fatalf("Lock() unexpected error: %v", err)
+	}
+	unlock()
+
+	if len(locks) != 0 {
+		t.Errorf("expected the in-process locks to be forgotten once released, got %v", locks)
+	}
+}
+
+func TestLockWaitsForContext(t *testing.T) {
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	unlock, err := Lock(context.TODO(), kubeClient, testZoneID, testRecord)
+	if err != nil {
+		t.Fatalf("Lock() unexpected error: %v", err)
+	}
+	defer unlock()
+
+	// another record of the zone isn't held back
+	unlockOther, err := Lock(context.TODO(), kubeClient, testZoneID, "_acme-challenge.apps.example.com")
+	if err != nil {
+		t.Fatalf("Lock() unexpected error locking another record: %v", err)
+	}
+	unlockOther()
+
+	// waiting for a record held in the process stops with the context
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := Lock(ctx, kubeClient, testZoneID, testRecord); err != context.DeadlineExceeded {
+		t.Errorf("Lock() expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestRenewLease(t *testing.T) {
+	now := time.Now()
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	acquired, err := tryAcquireLease(context.TODO(), kubeClient, LeaseName(testZoneID, testRecord), testZoneID, testRecord, now)
+	if err != nil || !acquired {
+		t.Fatalf("tryAcquireLease() expected the lease, got %t: %v", acquired, err)
+	}
+
+	renewed := now.Add(renewInterval)
+	if err := renewLease(kubeClient, LeaseName(testZoneID, testRecord), renewed); err != nil {
+		t.Fatalf("renewLease() unexpected error: %v", err)
+	}
+
+	lease := &coordinationv1.Lease{}
+	if err := kubeClient.Get(context.TODO(), types.NamespacedName{Namespace: config.OperatorNamespace, Name: LeaseName(testZoneID, testRecord)}, lease); err != nil {
+		t.Fatalf("unexpected error getting lease: %v", err)
+	}
+	if !lease.Spec.RenewTime.Time.Equal(renewed.Truncate(time.Microsecond)) {
+		t.Errorf("expected the lease to be renewed at %v, got %v", renewed, lease.Spec.RenewTime)
+	}
+
+	// a lease taken over by another replica isn't renewed
+	otherClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(otherHolderLease(now)).Build()
+	if err := renewLease(otherClient, LeaseName(testZoneID, testRecord), renewed); err == nil {
+		t.Error("renewLease() expected an error renewing the lease of another replica")
+	}
+}
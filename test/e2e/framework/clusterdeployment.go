@@ -0,0 +1,197 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	hivev1aws "github.com/openshift/hive/apis/hive/v1/aws"
+	hivev1azure "github.com/openshift/hive/apis/hive/v1/azure"
+	hivev1gcp "github.com/openshift/hive/apis/hive/v1/gcp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/certman-operator/controllers/clusterdeployment"
+)
+
+// Platform is a cloud platform the operator issues certificates for.
+type Platform string
+
+// The platforms whose DNS zones the operator writes challenge records to
+const (
+	AWS   Platform = "aws"
+	GCP   Platform = "gcp"
+	Azure Platform = "azure"
+)
+
+// Platforms are all the platforms, for the scenarios run against each of them.
+var Platforms = []Platform{AWS, GCP, Azure}
+
+const (
+	// certificateBundleName is the name of the bundle of the control plane and ingress certificates
+	certificateBundleName = "primary-cert-bundle"
+	// region is the region of the clusters and their resource group on Azure
+	region = "us-east-1"
+)
+
+// Cluster holds the objects of a cluster installed by hive, ready for the operator to issue its
+// certificates.
+type Cluster struct {
+	ClusterDeployment *hivev1.ClusterDeployment
+	// Credentials is the secret of the credentials of the platform the DNS zone is accessed with
+	Credentials *corev1.Secret
+	// DNSZone is the DNS zone of the base domain of the cluster, named zoneID
+	DNSZone *hivev1.DNSZone
+}
+
+// NewCluster returns the objects of the cluster name of platform in namespace, with the API and
+// default ingress of baseDomain in a certificate bundle. zoneID is the ID of the zone of
+// baseDomain in the fake of the DNS API of platform.
+func NewCluster(platform Platform, namespace, name, baseDomain, zoneID string) (*Cluster, error) {
+	credentials, err := newCredentials(platform, namespace, name+"-"+string(platform)+"-creds")
+	if err != nil {
+		return nil, err
+	}
+
+	cd := &hivev1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			UID:       types.UID(namespace + "-" + name),
+			Labels: map[string]string{
+				clusterdeployment.ClusterDeploymentManagedLabel: "true",
+			},
+		},
+		Spec: hivev1.ClusterDeploymentSpec{
+			BaseDomain:  baseDomain,
+			ClusterName: name,
+			Installed:   true,
+			ClusterMetadata: &hivev1.ClusterMetadata{
+				ClusterID: name + "-id",
+				InfraID:   name + "-infra",
+			},
+			ControlPlaneConfig: hivev1.ControlPlaneConfigSpec{
+				ServingCertificates: hivev1.ControlPlaneServingCertificateSpec{
+					Default: certificateBundleName,
+				},
+			},
+			Ingress: []hivev1.ClusterIngress{
+				{
+					Name:               "default",
+					Domain:             fmt.Sprintf("apps.%s.%s", name, baseDomain),
+					ServingCertificate: certificateBundleName,
+				},
+			},
+			CertificateBundles: []hivev1.CertificateBundleSpec{
+				{
+					Name:                 certificateBundleName,
+					Generate:             true,
+					CertificateSecretRef: corev1.LocalObjectReference{Name: name + "-" + certificateBundleName},
+				},
+			},
+		},
+	}
+
+	dnsZone := &hivev1.DNSZone{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-zone", Namespace: namespace},
+		Spec:       hivev1.DNSZoneSpec{Zone: baseDomain},
+	}
+
+	credentialsRef := corev1.LocalObjectReference{Name: credentials.Name}
+	switch platform {
+	case AWS:
+		cd.Spec.Platform.AWS = &hivev1aws.Platform{Region: region, CredentialsSecretRef: credentialsRef}
+		dnsZone.Status.AWS = &hivev1.AWSDNSZoneStatus{ZoneID: &zoneID}
+	case GCP:
+		cd.Spec.Platform.GCP = &hivev1gcp.Platform{Region: region, CredentialsSecretRef: credentialsRef}
+		dnsZone.Status.GCP = &hivev1.GCPDNSZoneStatus{ZoneName: &zoneID}
+	case Azure:
+		cd.Spec.Platform.Azure = &hivev1azure.Platform{Region: region, CredentialsSecretRef: credentialsRef, BaseDomainResourceGroupName: zoneID}
+		dnsZone.Status.Azure = &hivev1.AzureDNSZoneStatus{}
+	default:
+		return nil, fmt.Errorf("unknown platform %q", platform)
+	}
+
+	return &Cluster{ClusterDeployment: cd, Credentials: credentials, DNSZone: dnsZone}, nil
+}
+
+// Objects returns the objects of c, to create them or seed a fake client with them.
+func (c *Cluster) Objects() []client.Object {
+	return []client.Object{c.Credentials, c.DNSZone, c.ClusterDeployment}
+}
+
+// newCredentials returns the secret named name of credentials for platform that the DNS clients of
+// the operator accept. They are only good for the fakes of the provider APIs.
+func newCredentials(platform Platform, namespace, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+
+	switch platform {
+	case AWS:
+		secret.Data = map[string][]byte{
+			"aws_access_key_id":     []byte("AKIAE2EFRAMEWORK"),
+			"aws_secret_access_key": []byte("e2e-framework-secret"),
+		}
+	case GCP:
+		serviceAccount, err := newGCPServiceAccount(name)
+		if err != nil {
+			return nil, err
+		}
+		secret.Data = map[string][]byte{"osServiceAccount.json": serviceAccount}
+	case Azure:
+		servicePrincipal, err := json.Marshal(map[string]string{
+			"clientId":       "e2e-framework-client",
+			"clientSecret":   "e2e-framework-secret",
+			"tenantId":       "e2e-framework-tenant",
+			"subscriptionId": "e2e-framework-subscription",
+		})
+		if err != nil {
+			return nil, err
+		}
+		secret.Data = map[string][]byte{"osServicePrincipal.json": servicePrincipal}
+	default:
+		return nil, fmt.Errorf("unknown platform %q", platform)
+	}
+
+	return secret, nil
+}
+
+// newGCPServiceAccount returns the JSON key of a service account with a new private key.
+func newGCPServiceAccount(name string) ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "e2e-framework",
+		"private_key_id": name,
+		"private_key":    string(privateKey),
+		"client_email":   fmt.Sprintf("%s@e2e-framework.iam.gserviceaccount.com", name),
+		"client_id":      name,
+		"token_uri":      "https://oauth2.googleapis.com/token",
+	})
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"testing"
+
+	hiveapis "github.com/openshift/hive/apis"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/controllers/clusterdeployment"
+)
+
+func TestNewCluster(t *testing.T) {
+	if err := certmanv1alpha1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("failed to add to the scheme: %v", err)
+	}
+	if err := hiveapis.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("failed to add to the scheme: %v", err)
+	}
+
+	credentialKeys := map[Platform]string{
+		AWS:   "aws_secret_access_key",
+		GCP:   "osServiceAccount.json",
+		Azure: "osServicePrincipal.json",
+	}
+
+	for _, platform := range Platforms {
+		t.Run(string(platform), func(t *testing.T) {
+			cluster, err := NewCluster(platform, "e2e", "cluster", "example.com", "zone-1")
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Contains(t, cluster.Credentials.Data, credentialKeys[platform])
+
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(append(cluster.Objects(), OperatorConfigMap("sre@example.com"))...).WithStatusSubresource(&hivev1.ClusterDeployment{}).Build()
+			r := &clusterdeployment.ClusterDeploymentReconciler{Client: c, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+			_, err = r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(cluster.ClusterDeployment)})
+			assert.NoError(t, err)
+
+			crList := &certmanv1alpha1.CertificateRequestList{}
+			assert.NoError(t, c.List(context.TODO(), crList, client.InNamespace("e2e")))
+			if !assert.Len(t, crList.Items, 1, "the operator should request the certificates of the cluster") {
+				return
+			}
+			secrets := crList.Items[0].Spec.Platform
+			var credentials string
+			switch platform {
+			case AWS:
+				credentials = secrets.AWS.Credentials.Name
+			case GCP:
+				credentials = secrets.GCP.Credentials.Name
+			case Azure:
+				credentials = secrets.Azure.Credentials.Name
+				assert.Equal(t, "zone-1", secrets.Azure.ResourceGroupName)
+			}
+			assert.Equal(t, cluster.Credentials.Name, credentials)
+		})
+	}
+
+	_, err := NewCluster("openstack", "e2e", "cluster", "example.com", "zone-1")
+	assert.Error(t, err)
+}
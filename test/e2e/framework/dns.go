@@ -0,0 +1,234 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// TXTSource holds TXT records a DNSServer serves along with its own, such as the Backend of the DNS
+// provider fakes of pkg/clients/conformance, so the records the operator writes through a cloud
+// client are visible to the ACME server.
+type TXTSource interface {
+	// TXT returns the values of the TXT records of fqdn, quoted or not as the DNS API they were
+	// written through takes them
+	TXT(fqdn string) []string
+}
+
+// DNSServer is a fake authoritative nameserver answering the TXT and NS queries it receives over UDP
+// on the loopback interface. Names without records of the queried type are answered with NXDOMAIN.
+type DNSServer struct {
+	conn *net.UDPConn
+
+	mu      sync.Mutex
+	txt     map[string][]string
+	ns      map[string][]string
+	failing map[string]bool
+	silent  map[string]bool
+	sources []TXTSource
+	queries map[string]int
+}
+
+// NewDNSServer starts a DNSServer on a free port, stopped when t ends.
+func NewDNSServer(t testing.TB) *DNSServer {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	s := &DNSServer{
+		conn:    conn,
+		txt:     map[string][]string{},
+		ns:      map[string][]string{},
+		failing: map[string]bool{},
+		silent:  map[string]bool{},
+		queries: map[string]int{},
+	}
+	go s.serve()
+	return s
+}
+
+// Addr returns the host:port the server listens on.
+func (s *DNSServer) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// Port returns the port the server listens on.
+func (s *DNSServer) Port() string {
+	_, port, _ := net.SplitHostPort(s.Addr())
+	return port
+}
+
+// NetResolver returns a resolver sending all its queries to the server, whatever the nameserver
+// they are addressed to.
+func (s *DNSServer) NetResolver() *net.Resolver {
+	address := s.Addr()
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			d := net.Dialer{}
+			return d.DialContext(ctx, "udp", address)
+		},
+	}
+}
+
+// SetTXT replaces the TXT records of fqdn with values.
+func (s *DNSServer) SetTXT(fqdn string, values ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txt[canonicalName(fqdn)] = values
+}
+
+// DeleteTXT removes the TXT records of fqdn set with SetTXT.
+func (s *DNSServer) DeleteTXT(fqdn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.txt, canonicalName(fqdn))
+}
+
+// SetNS replaces the nameservers zone is delegated to with hosts.
+func (s *DNSServer) SetNS(zone string, hosts ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ns[canonicalName(zone)] = hosts
+}
+
+// Fail makes the server answer the queries for fqdn with SERVFAIL.
+func (s *DNSServer) Fail(fqdn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing[canonicalName(fqdn)] = true
+}
+
+// Silence makes the server drop the queries for fqdn without answering them.
+func (s *DNSServer) Silence(fqdn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silent[canonicalName(fqdn)] = true
+}
+
+// ServeTXTFrom adds the TXT records of source to those the server answers with.
+func (s *DNSServer) ServeTXTFrom(source TXTSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources = append(s.sources, source)
+}
+
+// Queries returns the number of queries the server received for fqdn, unanswered ones included.
+func (s *DNSServer) Queries(fqdn string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[canonicalName(fqdn)]
+}
+
+func (s *DNSServer) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if response, ok := s.answer(buf[:n]); ok {
+			_, _ = s.conn.WriteToUDP(response, addr)
+		}
+	}
+}
+
+func (s *DNSServer) answer(query []byte) ([]byte, bool) {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil, false
+	}
+	question, err := p.Question()
+	if err != nil {
+		return nil, false
+	}
+
+	name := canonicalName(question.Name.String())
+	s.mu.Lock()
+	s.queries[name]++
+	silent := s.silent[name]
+	failing := s.failing[name]
+	txt := s.txtValues(name)
+	ns := s.ns[name]
+	s.mu.Unlock()
+	if silent {
+		return nil, false
+	}
+
+	header.Response = true
+	header.Authoritative = true
+	header.RCode = dnsmessage.RCodeSuccess
+	rrHeader := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: question.Class, TTL: 60}
+
+	var answers []dnsmessage.Resource
+	switch {
+	case failing:
+		header.RCode = dnsmessage.RCodeServerFailure
+	case question.Type == dnsmessage.TypeTXT && txt != nil:
+		answers = append(answers, dnsmessage.Resource{Header: rrHeader, Body: &dnsmessage.TXTResource{TXT: txt}})
+	case question.Type == dnsmessage.TypeNS && ns != nil:
+		for _, host := range ns {
+			answers = append(answers, dnsmessage.Resource{Header: rrHeader, Body: &dnsmessage.NSResource{NS: dnsmessage.MustNewName(canonicalName(host) + ".")}})
+		}
+	default:
+		header.RCode = dnsmessage.RCodeNameError
+	}
+
+	response, err := (&dnsmessage.Message{Header: header, Questions: []dnsmessage.Question{question}, Answers: answers}).Pack()
+	if err != nil {
+		return nil, false
+	}
+	return response, true
+}
+
+// txtValues returns the TXT values of name set on the server, or else served by its sources without
+// their quotes. It is called with the lock held.
+func (s *DNSServer) txtValues(name string) []string {
+	if values, ok := s.txt[name]; ok {
+		return values
+	}
+	for _, source := range s.sources {
+		values := source.TXT(name)
+		if len(values) == 0 {
+			continue
+		}
+		unquoted := make([]string, 0, len(values))
+		for _, value := range values {
+			if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+				value = value[1 : len(value)-1]
+			}
+			unquoted = append(unquoted, value)
+		}
+		return unquoted
+	}
+	return nil
+}
+
+// canonicalName returns name in lower case without its trailing dot.
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
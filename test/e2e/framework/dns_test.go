@@ -0,0 +1,70 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/certman-operator/pkg/clients/conformance"
+)
+
+func TestDNSServer(t *testing.T) {
+	s := NewDNSServer(t)
+	r := s.NetResolver()
+
+	t.Run("serves the TXT records it was given", func(t *testing.T) {
+		s.SetTXT("_acme-challenge.API.example.com.", "token")
+		values, err := r.LookupTXT(context.TODO(), "_acme-challenge.api.example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"token"}, values)
+		assert.Equal(t, 1, s.Queries("_acme-challenge.api.example.com."))
+
+		s.DeleteTXT("_acme-challenge.api.example.com")
+		_, err = r.LookupTXT(context.TODO(), "_acme-challenge.api.example.com")
+		assert.Error(t, err, "a deleted record should not be served")
+	})
+
+	t.Run("serves the TXT records written to a DNS provider fake", func(t *testing.T) {
+		backend := conformance.NewBackend()
+		zoneID := backend.AddZone("example.org", false)
+		backend.SetTXT(zoneID, "_acme-challenge.api.example.org", "\"token\"")
+		s.ServeTXTFrom(backend)
+
+		values, err := r.LookupTXT(context.TODO(), "_acme-challenge.api.example.org")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"token"}, values)
+	})
+
+	t.Run("serves the nameservers of a zone", func(t *testing.T) {
+		s.SetNS("example.com", "ns1.example.net", "ns2.example.net.")
+		nameservers, err := r.LookupNS(context.TODO(), "example.com")
+		assert.NoError(t, err)
+		if assert.Len(t, nameservers, 2) {
+			assert.Equal(t, "ns1.example.net.", nameservers[0].Host)
+			assert.Equal(t, "ns2.example.net.", nameservers[1].Host)
+		}
+	})
+
+	t.Run("fails the queries of a failing name", func(t *testing.T) {
+		s.Fail("fail.example.com")
+		_, err := r.LookupTXT(context.TODO(), "fail.example.com")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package framework holds the building blocks of the end-to-end scenarios of the operator: a fake
+// authoritative DNS server, a pebble ACME server bootstrapper, ClusterDeployment factories for each
+// platform and polling helpers bounded by deadlines. A scenario such as a renewal or a relocation
+// starts a DNSServer and a Pebble, creates the objects of NewCluster and waits for the
+// CertificateRequests with WaitForCertificateRequest.
+package framework
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+	"github.com/openshift/certman-operator/config"
+	cTypes "github.com/openshift/certman-operator/pkg/clients/types"
+)
+
+const (
+	// DefaultPollInterval is the interval between the checks of the polling helpers
+	DefaultPollInterval = 250 * time.Millisecond
+	// DefaultTimeout bounds the waits of the scenarios that don't need more
+	DefaultTimeout = 2 * time.Minute
+)
+
+// OperatorConfigMap returns the configmap of the operator with the notification email the
+// CertificateRequests are created with, which the operator requires.
+func OperatorConfigMap(notificationEmail string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.OperatorName, Namespace: config.OperatorNamespace},
+		Data:       map[string]string{cTypes.DefaultNotificationEmailAddress: notificationEmail},
+	}
+}
+
+// Condition is checked by Poll until it returns true or an error.
+type Condition func(ctx context.Context) (bool, error)
+
+// Poll checks condition every interval until it returns true, returns an error or timeout elapses.
+// The error returned when timeout elapses includes the last error of an object not found, which is
+// retried rather than returned, so a failed wait tells what it was waiting for.
+func Poll(ctx context.Context, interval, timeout time.Duration, condition Condition) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastNotFound error
+	for {
+		done, err := condition(ctx)
+		switch {
+		case errors.IsNotFound(err):
+			lastNotFound = err
+		case err != nil:
+			return err
+		case done:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastNotFound != nil {
+				return fmt.Errorf("timed out after %v: %w", timeout, lastNotFound)
+			}
+			return fmt.Errorf("timed out after %v", timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForCertificateRequest waits until the CertificateRequest key satisfies ready, and returns it.
+func WaitForCertificateRequest(ctx context.Context, c client.Client, key types.NamespacedName, timeout time.Duration, ready func(*certmanv1alpha1.CertificateRequest) bool) (*certmanv1alpha1.CertificateRequest, error) {
+	cr := &certmanv1alpha1.CertificateRequest{}
+	err := Poll(ctx, DefaultPollInterval, timeout, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, key, cr); err != nil {
+			return false, err
+		}
+		return ready(cr), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("waiting for CertificateRequest %v: %w", key, err)
+	}
+	return cr, nil
+}
+
+// WaitForSecret waits until the secret key satisfies ready, and returns it.
+func WaitForSecret(ctx context.Context, c client.Client, key types.NamespacedName, timeout time.Duration, ready func(*corev1.Secret) bool) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := Poll(ctx, DefaultPollInterval, timeout, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, key, secret); err != nil {
+			return false, err
+		}
+		return ready(secret), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("waiting for secret %v: %w", key, err)
+	}
+	return secret, nil
+}
+
+// WaitForDeletion waits until obj, which only needs its namespace and name set, is gone.
+func WaitForDeletion(ctx context.Context, c client.Client, obj client.Object, timeout time.Duration) error {
+	key := client.ObjectKeyFromObject(obj)
+	err := Poll(ctx, DefaultPollInterval, timeout, func(ctx context.Context) (bool, error) {
+		err := c.Get(ctx, key, obj)
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for the deletion of %v: %w", key, err)
+	}
+	return nil
+}
+
+// Issued returns true when the certificate of cr was issued, a ready function of
+// WaitForCertificateRequest.
+func Issued(cr *certmanv1alpha1.CertificateRequest) bool {
+	return cr.Status.Issued
+}
+
+// IssuedAfter returns a ready function of WaitForCertificateRequest that returns true once the
+// certificate of the CertificateRequest is another one than serialNumber, such as after a renewal.
+func IssuedAfter(serialNumber string) func(*certmanv1alpha1.CertificateRequest) bool {
+	return func(cr *certmanv1alpha1.CertificateRequest) bool {
+		return cr.Status.Issued && cr.Status.SerialNumber != serialNumber
+	}
+}
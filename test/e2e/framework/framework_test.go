@@ -0,0 +1,87 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestPoll(t *testing.T) {
+	t.Run("returns once the condition is met", func(t *testing.T) {
+		checks := 0
+		err := Poll(context.TODO(), time.Millisecond, time.Second, func(ctx context.Context) (bool, error) {
+			checks++
+			return checks == 3, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, checks)
+	})
+
+	t.Run("returns the error of the condition", func(t *testing.T) {
+		failure := errors.New("failure")
+		err := Poll(context.TODO(), time.Millisecond, time.Second, func(ctx context.Context) (bool, error) {
+			return false, failure
+		})
+		assert.ErrorIs(t, err, failure)
+	})
+
+	t.Run("retries an object not found and reports it on timeout", func(t *testing.T) {
+		notFound := apierrors.NewNotFound(certmanv1alpha1.GroupVersion.WithResource("certificaterequests").GroupResource(), "missing")
+		err := Poll(context.TODO(), time.Millisecond, 20*time.Millisecond, func(ctx context.Context) (bool, error) {
+			return false, notFound
+		})
+		assert.Error(t, err)
+		assert.True(t, apierrors.IsNotFound(err), "the timeout should wrap the last object not found")
+	})
+}
+
+func TestWaitForCertificateRequest(t *testing.T) {
+	if err := certmanv1alpha1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("failed to add to the scheme: %v", err)
+	}
+	key := types.NamespacedName{Namespace: "e2e", Name: "cluster-primary-cert-bundle"}
+	cr := &certmanv1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Status:     certmanv1alpha1.CertificateRequestStatus{Issued: true, SerialNumber: "1"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(cr).Build()
+
+	got, err := WaitForCertificateRequest(context.TODO(), c, key, time.Second, Issued)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", got.Status.SerialNumber)
+
+	_, err = WaitForCertificateRequest(context.TODO(), c, key, 50*time.Millisecond, IssuedAfter("1"))
+	assert.Error(t, err, "the certificate wasn't renewed")
+
+	err = WaitForDeletion(context.TODO(), c, cr, 50*time.Millisecond)
+	assert.Error(t, err, "the CertificateRequest wasn't deleted")
+
+	assert.NoError(t, c.Delete(context.TODO(), cr))
+	assert.NoError(t, WaitForDeletion(context.TODO(), c, cr, time.Second))
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eggsampler/acme"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+// PebbleBinaryEnv names the environment variable holding the path of the pebble binary, looked up
+// in the PATH when it is unset.
+const PebbleBinaryEnv = "PEBBLE_BIN"
+
+// pebbleStartTimeout bounds the wait for pebble to serve its directory
+const pebbleStartTimeout = 30 * time.Second
+
+// Pebble is a pebble ACME server validating the DNS-01 challenges against a DNSServer.
+type Pebble struct {
+	address           string
+	managementAddress string
+	caCertFile        string
+	caCerts           *x509.CertPool
+}
+
+// StartPebble starts pebble on free ports of the loopback interface, resolving the challenge records
+// with dns, and waits for it to serve its directory. Pebble is stopped when t ends. The test is
+// skipped when the pebble binary isn't installed.
+func StartPebble(t testing.TB, dns *DNSServer) *Pebble {
+	t.Helper()
+
+	binary := os.Getenv(PebbleBinaryEnv)
+	if binary == "" {
+		var err error
+		if binary, err = exec.LookPath("pebble"); err != nil {
+			t.Skipf("pebble is not installed, set %s to its path to run this test", PebbleBinaryEnv)
+		}
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile, caCerts, err := writeServingCertificate(dir)
+	if err != nil {
+		t.Fatalf("failed to write the pebble serving certificate: %v", err)
+	}
+
+	p := &Pebble{
+		address:           freeAddress(t),
+		managementAddress: freeAddress(t),
+		caCertFile:        certFile,
+		caCerts:           caCerts,
+	}
+
+	configFile := filepath.Join(dir, "pebble-config.json")
+	config, err := json.Marshal(map[string]interface{}{
+		"pebble": map[string]interface{}{
+			"listenAddress":                  p.address,
+			"managementListenAddress":        p.managementAddress,
+			"certificate":                    certFile,
+			"privateKey":                     keyFile,
+			"httpPort":                       5002,
+			"tlsPort":                        5001,
+			"ocspResponderURL":               "",
+			"externalAccountBindingRequired": false,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal the pebble configuration: %v", err)
+	}
+	if err := os.WriteFile(configFile, config, 0600); err != nil {
+		t.Fatalf("failed to write the pebble configuration: %v", err)
+	}
+
+	cmd := exec.Command(binary, "-config", configFile, "-dnsserver", dns.Addr())
+	// validations without the random sleep pebble adds by default, and nonces that are only
+	// rejected when they are actually bad
+	cmd.Env = append(os.Environ(), "PEBBLE_VA_NOSLEEP=1", "PEBBLE_WFE_NONCEREJECT=0")
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start pebble: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	err = Poll(context.Background(), DefaultPollInterval, pebbleStartTimeout, func(ctx context.Context) (bool, error) {
+		response, err := p.HTTPClient().Get(p.DirectoryURL())
+		if err != nil {
+			return false, nil
+		}
+		response.Body.Close()
+		return response.StatusCode == http.StatusOK, nil
+	})
+	if err != nil {
+		t.Fatalf("pebble didn't serve its directory: %v", err)
+	}
+	return p
+}
+
+// DirectoryURL returns the URL of the ACME directory of p.
+func (p *Pebble) DirectoryURL() string {
+	return fmt.Sprintf("https://%s/dir", p.address)
+}
+
+// CACertFile returns the path of the PEM file of the CA of the serving certificate of p. The
+// operator trusts p when it runs with the SSL_CERT_FILE environment variable set to it.
+func (p *Pebble) CACertFile() string {
+	return p.caCertFile
+}
+
+// HTTPClient returns an HTTP client trusting the serving certificate of p.
+func (p *Pebble) HTTPClient() *http.Client {
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: p.caCerts, MinVersion: tls.VersionTLS12}},
+	}
+}
+
+// RootCertificate returns the PEM root certificate p issues certificates from, which pebble
+// generates each time it starts.
+func (p *Pebble) RootCertificate(ctx context.Context) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/roots/0", p.managementAddress), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := p.HTTPClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s getting the pebble root certificate", response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
+
+// NewAccountSecret registers a new account with p and returns the account secret named name that
+// holds it, in the format the operator reads.
+func (p *Pebble) NewAccountSecret(namespace, name string) (*corev1.Secret, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// the serving certificate of pebble was generated along with it, on the loopback interface
+	client, err := acme.NewClient(p.DirectoryURL(), acme.WithInsecureSkipVerify())
+	if err != nil {
+		return nil, err
+	}
+	account, err := client.NewAccount(key, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data: map[string][]byte{
+			"private-key": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+			"account-url": []byte(account.URL),
+		},
+	}, nil
+}
+
+// IssuerReference returns the reference to p as the ACME issuer of a CertificateRequest, with the
+// account of the accountSecretName secret of NewAccountSecret.
+func (p *Pebble) IssuerReference(accountSecretName string) *certmanv1alpha1.ACMEIssuerReference {
+	return &certmanv1alpha1.ACMEIssuerReference{
+		Environment:       certmanv1alpha1.ACMEEnvironmentCustom,
+		DirectoryURL:      p.DirectoryURL(),
+		AccountSecretName: accountSecretName,
+	}
+}
+
+// writeServingCertificate writes a self-signed certificate for the loopback interface and its key
+// to dir, and returns their paths along with a pool holding the certificate.
+func writeServingCertificate(dir string) (string, string, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pebble"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		return "", "", nil, err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return "", "", nil, err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	return certFile, keyFile, pool, nil
+}
+
+// freeAddress returns an address of the loopback interface with a port that was free.
+func freeAddress(t testing.TB) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
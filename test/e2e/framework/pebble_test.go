@@ -0,0 +1,49 @@
+/*
+Copyright 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	certmanv1alpha1 "github.com/openshift/certman-operator/api/v1alpha1"
+)
+
+func TestPebble(t *testing.T) {
+	p := StartPebble(t, NewDNSServer(t))
+
+	secret, err := p.NewAccountSecret("certman-operator", "pebble-account")
+	if !assert.NoError(t, err) {
+		return
+	}
+	block, _ := pem.Decode(secret.Data["private-key"])
+	if assert.NotNil(t, block, "the account key should be PEM encoded") {
+		assert.Equal(t, "EC PRIVATE KEY", block.Type)
+	}
+	assert.NotEmpty(t, secret.Data["account-url"])
+
+	root, err := p.RootCertificate(context.TODO())
+	assert.NoError(t, err)
+	assert.Contains(t, string(root), "BEGIN CERTIFICATE")
+
+	issuer := p.IssuerReference(secret.Name)
+	assert.Equal(t, certmanv1alpha1.ACMEEnvironmentCustom, issuer.Environment)
+	assert.Equal(t, p.DirectoryURL(), issuer.DirectoryURL)
+}